@@ -0,0 +1,95 @@
+// File: "ac.go"
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/azorg/gousers/v2/dto"
+	"github.com/azorg/gousers/v2/pkg/utmp"
+)
+
+// Show total connect time per user and/or per day (ac command)
+func Ac(fname string, args []string, opts utmp.GetUsersOpts) {
+	fs := flag.NewFlagSet("ac", flag.ExitOnError)
+	people := fs.Bool("p", false, "connect time per user")
+	daily := fs.Bool("d", false, "connect time per day")
+	csvOut := fs.Bool("csv", false, "CSV output (day,user,seconds,hours)")
+	fs.Parse(args)
+
+	// Sessions started before -since must be paired too, so whole file
+	// is read and sessions are clipped by the period
+	copts := utmp.ConnectOpts{
+		Since: opts.Since, Until: opts.Until, ByUser: *people, ByDay: *daily}
+	opts.Since, opts.Until, opts.SeekSince = time.Time{}, time.Time{}, false
+	sessions, err := utmp.GetSessions(fname, opts)
+	if err != nil {
+		log.Fatalf("fatal: can't read utmp/wtmp/btmp file: %v\n", err)
+	}
+	report := utmp.ConnectReport(sessions, copts, time.Now())
+
+	stats := []dto.ConnectTime{}
+	for _, c := range report {
+		st := dto.ConnectTime{User: c.User, Seconds: int64(c.Time / time.Second)}
+		if !c.Day.IsZero() {
+			st.Day = c.Day.Format("2006-01-02")
+		}
+		stats = append(stats, st)
+	}
+
+	if JSON {
+		data, err := json.MarshalIndent(&stats, "", "  ")
+		if err != nil {
+			log.Fatalf("fatal: json.Marshal(): %v", err)
+		}
+		fmt.Println(string(data))
+		return
+	}
+
+	if *csvOut {
+		w := csv.NewWriter(os.Stdout)
+		w.Write([]string{"day", "user", "seconds", "hours"})
+		for _, st := range stats {
+			w.Write([]string{st.Day, st.User,
+				strconv.FormatInt(st.Seconds, 10), hours(st.Seconds)})
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			log.Fatalf("fatal: %v", err)
+		}
+		return
+	}
+
+	// Text output like ac(1): hours with total line (per day if -d)
+	var total, dayTotal int64
+	for i, st := range stats {
+		if st.User != "" {
+			fmt.Printf("%-10s %-16s %10s\n", st.Day, st.User, hours(st.Seconds))
+		} else {
+			fmt.Printf("%-10s %-16s %10s\n", st.Day, "total", hours(st.Seconds))
+		}
+		total += st.Seconds
+		dayTotal += st.Seconds
+		if *daily && *people && (i+1 == len(stats) || stats[i+1].Day != st.Day) {
+			fmt.Printf("%-10s %-16s %10s\n", st.Day, "total", hours(dayTotal))
+			dayTotal = 0
+		}
+	}
+	if *daily || *people || len(stats) == 0 {
+		fmt.Printf("%-10s %-16s %10s\n", "", "total", hours(total))
+	}
+}
+
+// Format seconds as hours ("12.34")
+func hours(sec int64) string {
+	return strconv.FormatFloat(float64(sec)/3600, 'f', 2, 64)
+}
+
+// EOF: "ac.go"
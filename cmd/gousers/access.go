@@ -0,0 +1,58 @@
+// File: "access.go"
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/azorg/gousers/v2/dto"
+	"github.com/azorg/gousers/v2/pkg/utmp"
+)
+
+// Check files can be read, print capability report and exit if not
+func CheckAccess(fnames ...string) {
+	failed := false
+	for _, fname := range fnames {
+		r := utmp.CheckAccess(fname)
+		if r.Readable {
+			continue
+		}
+		failed = true
+		if JSON {
+			PrintAccessReportJSON(r)
+		} else {
+			fmt.Fprint(os.Stderr, r)
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// Print capability report as JSON line
+func PrintAccessReportJSON(r utmp.AccessReport) {
+	d := dto.AccessReport{
+		File:        r.File,
+		Readable:    r.Readable,
+		Owner:       r.Owner,
+		Group:       r.Group,
+		InGroup:     r.InGroup,
+		Suggestions: r.Suggestions}
+	if r.Err != nil {
+		d.Error = r.Err.Error()
+	}
+	if r.Owner != "" {
+		d.Mode = r.Mode.String()
+	}
+
+	data, err := json.Marshal(&d)
+	if err != nil {
+		log.Fatalf("fatal: json.Marshal(): %v", err)
+	}
+	fmt.Println(string(data))
+}
+
+// EOF: "access.go"
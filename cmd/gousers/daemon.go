@@ -0,0 +1,246 @@
+// File: "daemon.go"
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"go.yaml.in/yaml/v3"
+
+	"github.com/azorg/gousers/v2/pkg/rules"
+	"github.com/azorg/gousers/v2/pkg/signal"
+	"github.com/azorg/gousers/v2/pkg/systemd"
+	"github.com/azorg/gousers/v2/pkg/utmp"
+)
+
+// Default config file of daemon command
+const DAEMON_CONFIG = "/etc/gousers.yaml"
+
+// Default watched file of daemon command
+const DAEMON_FILE = "/var/run/utmp"
+
+// Daemon config (YAML or JSON), for example:
+//
+//	watcher:
+//	  file: /var/run/utmp
+//	  euid: true
+//	  debounce: 200ms
+//	detection:              # detection config (see utmp.Config)
+//	  privileged: [root, admin]
+//	  ignore_users: ["^ansible$"]
+//	filters:                # events of syslog/journal/webhook/publish sinks
+//	  types: [remote, remote_x]
+//	sinks:
+//	  syslog: {target: tcp://loghost:514}
+//	  webhook: {url: "https://hooks.example.com/gousers", batch: 10}
+//	  prometheus: {listen: ":9838"}
+//	  ipc: {socket: /run/gousers.sock, mode: "0660"}
+//	  desktop: {expire: 10s}  # notifications in active graphical session
+//	rules:                  # alert rules of all events (see pkg/rules)
+//	  - name: root-after-hours
+//	    match: {events: [login], users: ["^root$"], types: [remote], time: "18:00-09:00"}
+//	    actions: [{type: log}, {type: exec, command: [/usr/local/bin/page, "{user}"]}]
+type DaemonConfig struct {
+	Watcher   WatcherConfig `json:"watcher"`
+	Detection *utmp.Config  `json:"detection,omitempty"` // nil - default (or -config file)
+	Filters   FilterConfig  `json:"filters"`
+	Sinks     SinksConfig   `json:"sinks"`
+	Rules     []rules.Rule  `json:"rules,omitempty"`
+}
+
+// Login watcher settings (restart is required to apply changes)
+type WatcherConfig struct {
+	File     string   `json:"file,omitempty"`     // "" - DAEMON_FILE
+	EUID     *bool    `json:"euid,omitempty"`     // nil - true
+	Debounce Duration `json:"debounce,omitempty"` // 0 - utmp.DEBOUNCE
+	Backend  string   `json:"backend,omitempty"`  // fsnotify or poll
+}
+
+// Duration as string ("200ms", "1m") or number of nanoseconds
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if json.Unmarshal(b, &s) != nil {
+		var n int64
+		if err := json.Unmarshal(b, &n); err != nil {
+			return fmt.Errorf("bad duration %s", b)
+		}
+		*d = Duration(n)
+		return nil
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Read daemon config: YAML (JSON is YAML too) is converted to JSON and
+// decoded by json tags, unknown keys are errors
+func ReadDaemonConfig(fname string) (*DaemonConfig, error) {
+	data, err := os.ReadFile(fname)
+	if err != nil {
+		return nil, err
+	}
+	var v any
+	if err := yaml.Unmarshal(data, &v); err != nil {
+		return nil, fmt.Errorf("%s: %w", fname, err)
+	}
+	if v == nil {
+		v = map[string]any{} // empty file: defaults
+	}
+	data, err = json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fname, err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	cfg := &DaemonConfig{}
+	if err := dec.Decode(cfg); err != nil {
+		return nil, fmt.Errorf("%s: %w", fname, err)
+	}
+	if _, err := cfg.Filters.SubscribeOpts(); err != nil {
+		return nil, fmt.Errorf("%s: filters: %w", fname, err)
+	}
+	if _, err := rules.New(cfg.Rules); err != nil {
+		return nil, fmt.Errorf("%s: rules: %w", fname, err)
+	}
+	return cfg, nil
+}
+
+// Apply detection config of daemon (or -config file, or defaults)
+func (cfg *DaemonConfig) ApplyDetection() error {
+	if cfg.Detection != nil {
+		return utmp.SetConfig(*cfg.Detection)
+	}
+	if Config != "" {
+		return utmp.LoadConfig(Config)
+	}
+	return utmp.SetConfig(utmp.Config{})
+}
+
+// Daemon (daemon command): login watcher with sinks and servers from
+// config file, SIGHUP reloads config and restarts sinks (see Outputs.Reload)
+func Daemon(args []string) {
+	fs := flag.NewFlagSet("daemon", flag.ExitOnError)
+	fname := fs.String("config", DAEMON_CONFIG, "daemon config file (YAML or JSON)")
+	fs.Parse(args)
+
+	cfg, err := ReadDaemonConfig(*fname)
+	if err != nil {
+		log.Fatalf("fatal: can't load config: %v", err)
+	}
+	if err := cfg.ApplyDetection(); err != nil {
+		log.Fatalf("fatal: can't load config: %v", err)
+	}
+
+	w := cfg.Watcher
+	file := w.File
+	if file == "" {
+		file = DAEMON_FILE
+	}
+	useEUID := w.EUID == nil || *w.EUID
+	if w.Debounce != 0 {
+		Bounce = time.Duration(w.Debounce)
+	}
+	if w.Backend != "" {
+		Backend = w.Backend
+	}
+	Raw = true // host/IP for sinks
+	CheckAccess(file)
+	l := StartLogin(file, useEUID)
+	out := NewOutputs(l)
+	if err := out.Start(cfg.Sinks, cfg.Filters, cfg.Rules); err != nil {
+		l.Close()
+		log.Fatalf("fatal: %v", err)
+	}
+	log.Printf("daemon: watching %s (config %s)", file, *fname)
+	Notify(systemd.READY, systemd.Status("watching "+file))
+	if code := RunDaemon(l, out, cfg, *fname); code != 0 {
+		os.Exit(code) // set by exit action of rules
+	}
+}
+
+// Run daemon loop until Ctrl+C or SIGTERM: sinks are stopped (pending
+// events are flushed) and Login is closed, exit code of rules is returned
+func RunDaemon(l *utmp.Login, out *Outputs, cfg *DaemonConfig, fname string) int {
+	wd := systemd.NewWatchdog()
+	defer wd.Stop()
+
+Loop:
+	for {
+		select {
+		case <-l.C(): // events are forwarded by sinks
+
+		case <-wd.C: // systemd watchdog (WatchdogSec=)
+			Notify(systemd.WATCHDOG)
+
+		case err := <-l.Errors(): // already logged
+			var e *utmp.LoginError
+			if errors.As(err, &e) && e.Fatal {
+				out.Stop()
+				l.Close()
+				log.Fatalf("fatal: watcher stopped: %v", err)
+			}
+
+		case <-signal.SigHUP: // reload config, restart sinks
+			Notify(systemd.RELOADING)
+			utmp.InvalidateUserInfo()
+			next, err := ReadDaemonConfig(fname)
+			if err == nil {
+				err = next.ApplyDetection()
+			}
+			if err != nil {
+				log.Printf("error: config is not reloaded: %v", err)
+				Notify(systemd.READY)
+				continue
+			}
+			if !sameWatcher(cfg.Watcher, next.Watcher) {
+				log.Printf("warning: watcher settings are changed, restart to apply")
+			}
+			if err := out.Reload(next.Sinks, next.Filters, next.Rules); err != nil {
+				log.Printf("error: config is not reloaded: %v", err)
+				cfg.ApplyDetection()
+				if err := out.Reload(cfg.Sinks, cfg.Filters, cfg.Rules); err != nil {
+					out.Stop()
+					l.Close()
+					log.Fatalf("fatal: can't restart sinks: %v", err)
+				}
+				Notify(systemd.READY)
+				continue
+			}
+			cfg = next
+			log.Printf("daemon: config %s reloaded", fname)
+			Notify(systemd.READY)
+
+		case <-signal.CtrlC: // Ctrl+C or SIGTERM (systemctl stop)
+			break Loop
+		}
+	}
+	Notify(systemd.STOPPING)
+	out.Stop()
+	l.Close()
+	return out.ExitCode()
+}
+
+// Compare watcher settings
+func sameWatcher(a, b WatcherConfig) bool {
+	euid := func(p *bool) bool { return p == nil || *p }
+	return a.File == b.File && euid(a.EUID) == euid(b.EUID) &&
+		a.Debounce == b.Debounce && a.Backend == b.Backend
+}
+
+// EOF: "daemon.go"
@@ -0,0 +1,177 @@
+// File: "daemon_test.go"
+
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/azorg/gousers/v2/dto"
+	"github.com/azorg/gousers/v2/pkg/signal"
+	"github.com/azorg/gousers/v2/pkg/sink"
+	"github.com/azorg/gousers/v2/pkg/utmp"
+)
+
+// Write utmp record of user process
+func writeUtmp(t *testing.T, fname, line, user, host string, sec int32) {
+	writeRecord(t, fname, utmp.USER_PROCESS, 0, line, user, host, sec)
+}
+
+// Sink reporting sent events
+type sentSink struct {
+	sink.Sink
+	sent chan struct{}
+}
+
+func (s *sentSink) Send(evt utmp.LoginEvent) error {
+	err := s.Sink.Send(evt)
+	s.sent <- struct{}{}
+	return err
+}
+
+func TestDaemonSIGTERM(t *testing.T) {
+	var mx sync.Mutex
+	var got []dto.Webhook
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var req dto.Webhook
+		if json.Unmarshal(body, &req) == nil {
+			mx.Lock()
+			got = append(got, req)
+			mx.Unlock()
+		}
+	}))
+	defer srv.Close()
+
+	fname := filepath.Join(t.TempDir(), "utmp")
+	now := int32(time.Now().Unix())
+	writeUtmp(t, fname, "tty1", "root", "", now)
+	l, err := utmp.NewLoginWith(fname, utmp.LoginOpts{Debounce: time.Millisecond})
+	require.NoError(t, err)
+
+	// Batch is sent on stop only
+	wh, err := sink.NewWebhook(srv.URL, sink.WebhookOpts{
+		BatchSize: 10, Flush: time.Hour, Hostname: "h1"})
+	require.NoError(t, err)
+	s := &sentSink{Sink: wh, sent: make(chan struct{}, 1)}
+	out := NewOutputs(l)
+	out.stops = append(out.stops, sink.ForwardWith(l, s, utmp.SubscribeOpts{}, nil))
+
+	signal.Install(signal.Opts{})
+	defer signal.Reset()
+	code := make(chan int)
+	go func() { code <- RunDaemon(l, out, &DaemonConfig{}, "") }()
+
+	writeUtmp(t, fname, "pts/0", "alice", "10.0.0.5", now+1)
+	select {
+	case <-s.sent:
+	case <-time.After(5 * time.Second):
+		t.Fatal("login is not forwarded")
+	}
+	mx.Lock()
+	require.Empty(t, got)
+	mx.Unlock()
+
+	require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGTERM))
+	select {
+	case c := <-code:
+		require.Equal(t, 0, c)
+	case <-time.After(5 * time.Second):
+		t.Fatal("daemon is not stopped by SIGTERM")
+	}
+	mx.Lock()
+	defer mx.Unlock()
+	require.Len(t, got, 1)
+	require.Equal(t, "h1", got[0].Host)
+	require.Len(t, got[0].Events, 1)
+}
+
+func TestOutputsReload(t *testing.T) {
+	var mx sync.Mutex
+	got := make(map[string][]dto.Webhook)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var req dto.Webhook
+		if json.Unmarshal(body, &req) == nil {
+			mx.Lock()
+			got[r.URL.Path] = append(got[r.URL.Path], req)
+			mx.Unlock()
+		}
+	}))
+	defer srv.Close()
+	users := func(path string) []string {
+		mx.Lock()
+		defer mx.Unlock()
+		list := []string{}
+		for _, req := range got[path] {
+			for _, e := range req.Events {
+				list = append(list, e.User)
+			}
+		}
+		return list
+	}
+
+	fname := filepath.Join(t.TempDir(), "utmp")
+	now := int32(time.Now().Unix())
+	writeUtmp(t, fname, "tty1", "root", "", now)
+	l, err := utmp.NewLoginWith(fname, utmp.LoginOpts{Debounce: time.Millisecond})
+	require.NoError(t, err)
+	defer l.Close()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	ln.Close()
+	metrics := func() int {
+		resp, err := http.Get("http://" + addr + "/metrics")
+		if err != nil {
+			return 0
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	sinks := func(path string) SinksConfig {
+		return SinksConfig{
+			Webhook:    &WebhookConfig{URL: srv.URL + path, Batch: 10}, // sent on stop
+			Prometheus: &ListenConfig{Listen: addr}}
+	}
+
+	out := NewOutputs(l)
+	require.NoError(t, out.Start(sinks("/a"), FilterConfig{}, nil))
+	writeUtmp(t, fname, "pts/0", "alice", "10.0.0.5", now+1)
+	time.Sleep(100 * time.Millisecond)
+	require.Empty(t, users("/a"))
+
+	// prometheus server is kept: the address is not in use
+	require.NoError(t, out.Reload(sinks("/b"), FilterConfig{}, nil))
+	require.Equal(t, []string{"alice"}, users("/a"))
+	require.Equal(t, http.StatusOK, metrics())
+
+	// error: new sinks are stopped, old ones keep running
+	bad := sinks("/c")
+	bad.IPC = &IPCConfig{Mode: "9"}
+	require.Error(t, out.Reload(bad, FilterConfig{}, nil))
+	require.Empty(t, users("/c"))
+	require.Equal(t, http.StatusOK, metrics())
+	writeUtmp(t, fname, "pts/1", "bob", "10.0.0.6", now+2)
+	time.Sleep(100 * time.Millisecond)
+
+	// removed server is stopped
+	require.NoError(t, out.Reload(SinksConfig{}, FilterConfig{}, nil))
+	require.Equal(t, []string{"bob"}, users("/b"))
+	require.Equal(t, 0, metrics())
+	out.Stop()
+}
+
+// EOF: "daemon_test.go"
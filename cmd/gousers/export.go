@@ -0,0 +1,67 @@
+// File: "export.go"
+
+package main
+
+import (
+	"flag"
+	"log"
+
+	"github.com/azorg/gousers/v2/pkg/export"
+	"github.com/azorg/gousers/v2/pkg/utmp"
+)
+
+// Export sessions, boots and failed logins (export command)
+func Export(fname string, args []string, opts utmp.GetUsersOpts) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	sqlite := fs.String("sqlite", "", "SQLite database file")
+	parquet := fs.String("parquet", "", "output directory for Parquet files")
+	btmp := fs.String("btmp", export.DEFAULT_BTMP, `btmp file ("" - skip failed logins)`)
+	checkpoint := fs.String("checkpoint", "", "checkpoint file (incremental export)")
+	fs.Parse(args)
+
+	if *sqlite == "" && *parquet == "" {
+		log.Fatalf("fatal: no output selected (run with --help option)")
+	}
+	if *btmp != "" {
+		CheckAccess(*btmp)
+	}
+
+	var cp *export.Checkpoint
+	var d *export.Dataset
+	var err error
+	if *checkpoint != "" {
+		cp, err = export.ReadCheckpoint(*checkpoint)
+		if err != nil {
+			log.Fatalf("fatal: can't read checkpoint: %v\n", err)
+		}
+		d, err = export.LoadIncremental(fname, *btmp, opts, cp)
+	} else {
+		d, err = export.Load(fname, *btmp, opts)
+	}
+	if err != nil {
+		log.Fatalf("fatal: can't read utmp/wtmp/btmp file: %v\n", err)
+	}
+
+	if *sqlite != "" {
+		err = export.SQLite(*sqlite, d)
+		if err != nil {
+			log.Fatalf("fatal: can't export to SQLite: %v\n", err)
+		}
+	}
+
+	if *parquet != "" {
+		err = export.Parquet(*parquet, d)
+		if err != nil {
+			log.Fatalf("fatal: can't export to Parquet: %v\n", err)
+		}
+	}
+
+	if cp != nil {
+		err = cp.Write(*checkpoint)
+		if err != nil {
+			log.Fatalf("fatal: can't write checkpoint: %v\n", err)
+		}
+	}
+}
+
+// EOF: "export.go"
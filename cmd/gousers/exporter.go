@@ -0,0 +1,84 @@
+// File: "exporter.go"
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/azorg/gousers/v2/pkg/metrics"
+	"github.com/azorg/gousers/v2/pkg/signal"
+	"github.com/azorg/gousers/v2/pkg/systemd"
+	"github.com/azorg/gousers/v2/pkg/utmp"
+)
+
+// Default listen address of exporter command
+const EXPORTER_ADDR = ":9838"
+
+// Prometheus exporter (exporter command): GET /metrics
+func Exporter(fname string, args []string, opts utmp.GetUsersOpts) {
+	fs := flag.NewFlagSet("exporter", flag.ExitOnError)
+	addr := fs.String("listen", EXPORTER_ADDR, "listen address")
+	fs.Parse(args)
+
+	l := StartLogin(fname, opts.UseEUID)
+	defer l.Close()
+	exp := StartOtel(l)
+	defer exp.Close()
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.NewCollector(l))
+	srv := &http.Server{
+		Addr:              *addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second}
+	ln, err := Listen("prometheus", *addr)
+	if err != nil {
+		log.Fatalf("fatal: %v", err)
+	}
+	go func() {
+		err := srv.Serve(ln)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("fatal: %v", err)
+		}
+	}()
+	Notify(systemd.READY)
+	wd := systemd.NewWatchdog()
+	defer wd.Stop()
+
+Loop:
+	for {
+		select {
+		case <-l.C(): // counted by collector
+
+		case err := <-l.Errors(): // already logged
+			var e *utmp.LoginError
+			if errors.As(err, &e) && e.Fatal {
+				l.Close()
+				log.Fatalf("fatal: watcher stopped: %v", err)
+			}
+
+		case <-signal.SigHUP: // reload detection config, drop user info cache
+			Notify(systemd.RELOADING)
+			ReloadConfig(l)
+			Notify(systemd.READY)
+
+		case <-wd.C: // systemd watchdog (WatchdogSec=)
+			Notify(systemd.WATCHDOG)
+
+		case <-signal.CtrlC: // Ctrl+C or SIGTERM (systemctl stop)
+			break Loop
+		}
+	}
+	Notify(systemd.STOPPING)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	srv.Shutdown(ctx)
+}
+
+// EOF: "exporter.go"
@@ -0,0 +1,66 @@
+// File: "faillock.go"
+
+package main
+
+import (
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/azorg/gousers/v2/dto"
+	"github.com/azorg/gousers/v2/pkg/faillock"
+)
+
+// Number of recent failures in user info
+const FAILURES_MAX = 10
+
+// Get failed login attempts of user from pam_faillock tally directory or
+// legacy faillog (nil if there are no such files or analysis is offline)
+func UserFailures(name, uid string) *dto.Failures {
+	if Offline && Root == "" {
+		return nil // failures of other host are unknown
+	}
+	now := time.Now()
+	cfg, err := faillock.ReadConfig(filepath.Join(Root, faillock.FAILLOCK_CONF))
+	if err != nil {
+		log.Printf("warning: can't read faillock config: %v\n", err)
+	}
+	cfg.Dir = filepath.Join(Root, cfg.Dir)
+
+	var st faillock.Status
+	if _, err = os.Stat(cfg.Dir); err == nil {
+		st, err = cfg.Status(name, now)
+	} else if n, e := strconv.Atoi(uid); e == nil && n >= 0 {
+		var entry faillock.FaillogEntry
+		entry, err = faillock.ReadFaillog(filepath.Join(Root, faillock.FAILLOG), n)
+		st = entry.Status(name, now)
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		log.Printf("warning: can't read failed logins: %v\n", err)
+		return nil
+	}
+
+	f := &dto.Failures{
+		Source: st.Source,
+		Count:  st.Count,
+		Locked: st.Locked,
+		Until:  st.Until}
+	if len(st.Failures) > FAILURES_MAX {
+		st.Failures = st.Failures[len(st.Failures)-FAILURES_MAX:]
+	}
+	for _, fl := range st.Failures {
+		f.Failures = append(f.Failures, dto.Failure{
+			Time:   fl.Time,
+			Source: fl.Source,
+			Type:   fl.Type,
+			Valid:  fl.Valid})
+	}
+	return f
+}
+
+// EOF: "faillock.go"
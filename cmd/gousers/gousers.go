@@ -3,29 +3,66 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
-	"io"
 	"log"
+	"log/slog"
 	"os"
+	"strings"
 	"time"
 
-	"gousers/dto"
-	"gousers/pkg/signal"
-	"gousers/pkg/utmp"
+	"github.com/azorg/gousers/v2/dto"
+	"github.com/azorg/gousers/v2/pkg/authlog"
+	"github.com/azorg/gousers/v2/pkg/otel"
+	"github.com/azorg/gousers/v2/pkg/siem"
+	"github.com/azorg/gousers/v2/pkg/signal"
+	"github.com/azorg/gousers/v2/pkg/sink"
+	"github.com/azorg/gousers/v2/pkg/systemd"
+	"github.com/azorg/gousers/v2/pkg/utmp"
+	"github.com/azorg/gousers/v2/pkg/utmpdbus"
+	"github.com/azorg/gousers/v2/pkg/utmphttp"
+	"github.com/azorg/gousers/v2/pkg/utmpipc"
 )
 
 const DEBUG = true
 
 const FOLLOW_INTERVAL = 250 * time.Millisecond // FIXME: why 250 ms?
 
+const RESOLVE_WAIT = 3 * time.Second // max time to wait for DNS (-resolve)
+
 // Options (default values)
 var (
 	Follow  = false
 	UseEUID = false
+	Slots   = false
+	JSON    = false
+	Since   = ""
+	Until   = ""
+	Config  = ""
+	Rotated = false
+	Seek    = false
 	File    = "/var/log/wtmp"
+	Dedup   = time.Duration(0)
+	Elevate = false
+	SSH     = false
+	Offline = false
+	Mux     = "keep"
+	Stale   = false
+	Stats   = false
+	Boot    = false
+	Budget  = utmp.ENRICH_BUDGET
+	InfoTTL = utmp.USER_INFO_TTL
+	Root    = ""
+	Incr    = false
+	Bounce  = utmp.DEBOUNCE
+	Backend = "fsnotify"
+	Otel    = false
+	Resolve = false
+	AuthLog = ""
+	Raw     = false // raw utmp records in events (host/IP for sinks)
 )
 
 func Usage() {
@@ -33,22 +70,217 @@ func Usage() {
 Usage: gousers [options] [command]
 
 Options:
-  -help|--help - print full help
-  -h|--h       - print help about options only
-  -file <file> - use a specific file instead of /var/log/wtmp
-  -follow      - follow dump mode (Ctrl+C to stop) like "tail -f"
-  -euid        - use EUID (for utmp)
+  -help|--help    - print full help
+  -h|--h          - print help about options only
+  -file <file>    - use a specific file instead of /var/log/wtmp
+                    (gzip/xz/zstd compressed files are unpacked on the fly)
+  -follow         - follow dump mode (Ctrl+C to stop) like "tail -f"
+  -euid           - use EUID (for utmp)
+  -slots          - print utmp slot statistics (empty/partial/reused records)
+  -json           - dump records as JSON (one record per line)
+  -since <time>   - consider records since time ("2006-01-02[ 15:04:05]"),
+                    users and sessions logged in at this time are kept
+  -until <time>   - consider records until time ("2006-01-02[ 15:04:05]")
+  -config <file>  - detection config (JSON), reloaded by SIGHUP or on change
+  -rotated        - also read rotated files (wtmp.1, wtmp-YYYYMM.gz, ...)
+  -seek           - binary search for -since time (time ordered wtmp/btmp only,
+                    users and sessions logged in before -since are lost)
+  -dedup <duration>
+                  - collapse duplicate login records (same user, tty and PID
+                    within duration, e.g. "1s"), count is printed by -slots
+  -elevated       - detect su/sudo sessions by /proc (EUID and loginuid of
+                    session processes), print effective user (for utmp)
+  -ssh            - find TCP connection of SSH sessions by /proc (client
+                    address and port, sshd address and port, PID of sshd
+                    process owning the socket), root is needed (for utmp)
+  -mux <mode>     - tmux/screen utmp entries: keep (default), tag (mark as
+                    "Multiplexed") or collapse into other session of user
+  -stale          - mark entries with PID reused by unrelated process (by
+                    process start time in /proc), implied by -euid, -elevated,
+                    -ssh and -mux tag|collapse (for utmp)
+  -current-boot   - skip logins before current boot (utmp not cleared on reboot)
+  -budget <duration>
+                  - max time to wait for user info (monitor), login/logout
+                    events are not delayed longer, default 100ms (0 - wait)
+  -info-ttl <duration>
+                  - cache user info (NSS/LDAP lookups) for duration,
+                    default 1m (0 - no cache), SIGHUP drops the cache
+  -debounce <duration>
+                  - merge utmp updates within duration into one event
+                    (monitor), default 100ms (0 - no delay)
+  -incremental    - monitor parses only appended records (wtmp/btmp),
+                    full re-read on truncation or rewrite
+  -backend <name> - monitor change notification: fsnotify (default) or poll
+                    (stat once a second, for NFS and systems without inotify)
+  -otel           - export login/logout log records and metrics to
+                    OpenTelemetry collector (OTLP/HTTP JSON) in daemon mode
+                    (monitor, serve, grpc-serve, exporter), configured by
+                    OTEL_* environment variables (see pkg/otel)
+  -stats          - print performance statistics to stderr at the end
+                    (records/s, MB/s, cache hit rate, lookup latencies)
+  -offline        - offline analysis of files copied from another host:
+                    no /proc (EUID, cmdline) and local user database lookups,
+                    less precise login types
+  -root <dir>     - resolve users and groups by <dir>/etc/passwd and
+                    <dir>/etc/group (mounted disk image, container root)
+  -auth <file>    - sshd log to find authentication method and key
+                    fingerprint of SSH sessions (users, info, sessions):
+                    /var/log/auth.log, /var/log/secure or "journal"
+                    (journalctl -t sshd)
+  -resolve        - fill missing host names by reverse DNS (and addresses
+                    of host names) for users, sessions and sources, lookups
+                    are cached and run in parallel, at most 3s are waited
 
 Commands:
   user[s]         - show users is currently logged (default command)
+  w [-tree]       - show who is logged on and what they are doing like "w":
+                    user, tty, from, login time, idle time (by TTY access
+                    time) and current command (foreground process group
+                    of terminal or latest process of session), -tree
+                    prints process trees of sessions (for utmp)
   dump            - show full dump
   info <username> - show full information about user by username (JSON)
+                    with failed login attempts and lockout status from
+                    pam_faillock (/var/run/faillock) or legacy faillog,
+                    last login from lastlog2 database (sqlite3 is used)
+                    or legacy lastlog
   stat            - show logged user statistics (JSON)
-  monitor         - login/logout monitor
+  monitor [-syslog <addr>] [-journal] [-webhook <url>] [-publish <url>]
+          [-ipc <socket>] [-dbus <bus>] [-desktop] [-format <format>]
+                  - login/logout monitor (SIGUSR1 dumps statistics to stderr),
+                    -format prints events as text (default), cef (ArcSight
+                    CEF lines) or ecs (Elastic Common Schema JSON lines),
+                    -syslog forwards events to syslog: "local" (/dev/log),
+                    udp://host[:514], tcp://host[:514] or tls://host[:6514]
+                    (RFC 5424 with [gousers@32473 user tty host ip type]),
+                    -journal writes events to systemd-journald with fields
+                    GOUSERS_USER, GOUSERS_TTY, GOUSERS_TYPE, GOUSERS_HOST...,
+                    -webhook <url> POSTs events as JSON (with "text" field for
+                    Slack/Mattermost), retried with backoff, options:
+                    -webhook-batch <n>, -webhook-header "Name: value",
+                    HMAC-SHA256 signature (X-Gousers-Signature) with key
+                    from GOUSERS_WEBHOOK_SECRET environment variable,
+                    -publish <url> publishes each login/logout (at least
+                    once) to nats://[user:pass@]host[:4222], nats+tls://,
+                    mqtt://[user:pass@]host[:1883], mqtts:// or Kafka REST
+                    Proxy kafka+http://host:8082, options: -topic <template>
+                    ({host}, {action}, {user}, {type}; default
+                    gousers.{host}.{action}, gousers/{host}/{action} for
+                    MQTT, gousers for Kafka), -payload json|protobuf
+                    (message Entries of pkg/utmpgrpc/gousers.proto),
+                    -ipc <socket> serves local consumers on Unix socket
+                    (mode 0660, length-prefixed JSON: users, user, stat,
+                    subscribe, see pkg/utmpipc),
+                    -dbus system|session|<address> registers D-Bus service
+                    org.gousers.Sessions (ListUsers, GetActive, signal
+                    SessionsChanged; system bus needs a policy, see
+                    pkg/utmpdbus),
+                    -desktop shows desktop notifications of logins ("user
+                    alice logged in remotely from 10.0.0.5") in the active
+                    graphical session (as root: session bus of active
+                    local_x user, otherwise own session bus)
+  system          - show system events (boot, run level, clock changes)
+  sessions        - show user sessions (login/logout pairs) like "last"
+  kill [-user <name>] [-tty <tty>] [-signal <sig>] [-group] [-dry-run]
+                  - signal leaders of active sessions of user and/or tty
+                    (default signal HUP, checked that PID is not reused),
+                    -group signals process group of leader too, -dry-run
+                    only prints targets (for utmp)
+  msg [-force] [-from <name>] <user|all> [text]
+                  - write message to terminals of logged in user (or all
+                    users) like "write"/"wall", text is read from stdin if
+                    omitted, terminals with "mesg n" are skipped unless
+                    -force (for utmp)
+  groups          - show sessions and connect time by group (chargeback)
+  ac [-p] [-d] [-csv]
+                  - total connect time in hours like "ac" (sessions are
+                    clipped by -since/-until period), -p per user, -d per
+                    day, JSON with -json option or CSV with -csv
+  report [-since <time>] [-until <time>] [-top <n>] [-pacct <file>]
+         [-json] [-csv]
+                  - usage report for period: sessions and hours per user,
+                    peak concurrent users (overall and by login type),
+                    logins by hour of day histogram, top remote sources
+                    (default 10), as text table, JSON or CSV
+                    (section,name,count,seconds), -pacct adds CPU time
+                    and commands of users sessions from process accounting
+                    file (e.g. /var/log/account/pacct, by session terminal)
+  sources [-by host|network]
+                  - summarize remote sessions by source host/IP or network
+  daemon [-config <file>]
+                  - login watcher with sinks and servers (syslog, journal,
+                    webhook, publish, prometheus, http, ipc, dbus,
+                    desktop, otel), alert rules (match user, type, source
+                    network, time of day, weekday; actions log, webhook,
+                    exec, exit code, see pkg/rules), filters and detection
+                    config from YAML/JSON file (default /etc/gousers.yaml,
+                    see cmd/gousers/daemon.go), SIGHUP reloads config and
+                    restarts sinks; under systemd
+                    daemon, serve, grpc-serve and exporter support
+                    Type=notify (readiness, reload, WatchdogSec=) and socket
+                    activation (FileDescriptorName=http, prometheus, ipc or
+                    grpc; the only passed socket is used by serve commands)
+  serve [-listen <addr>]
+                  - REST API server (JSON): GET /users, /users/<name>, /stat,
+                    /sessions?since=<time>, /events (Server-Sent Events),
+                    ?format=cef|ecs: sessions and events in SIEM format,
+                    default address 127.0.0.1:8080
+  grpc-serve -cert <file> -key <file> [-listen <addr>]
+                  - gRPC server over TLS (pkg/utmpgrpc/gousers.proto):
+                    ListUsers, GetUser, GetStat, WatchEvents (stream),
+                    default address 127.0.0.1:50051
+  exporter [-listen <addr>]
+                  - Prometheus exporter (GET /metrics, see pkg/metrics),
+                    default address :9838
+  export [export options] - export sessions, boots and failed logins
+  sync [sync options]
+                  - import wtmp records and sessions to SQLite database
+                    incrementally (by file offset, rotations are followed),
+                    so history survives wtmp rotation
+  merge [merge options] <host=file>...
+                  - merge records of several hosts (clock skew corrected)
+  simulate [simulate options]
+                  - generate synthetic login/logout events (load testing)
+  watch-tty [-idle <duration>] <tty>
+                  - follow one terminal session (login, idle/active, logout)
+                    and exit when it ends, default idle threshold 5m
+
+Export options:
+  -sqlite <db>    - write to SQLite database (schema: see pkg/export/sqlite.go)
+  -parquet <dir>  - write sessions/boots/failed_logins.parquet to directory
+  -btmp <file>    - btmp file with failed logins (default /var/log/btmp, "" - skip)
+  -checkpoint <file>
+                  - incremental export: only new data after checkpoint
+                    (closed sessions only), checkpoint is updated on success
+
+Sync options:
+  -db <file>      - SQLite database (schema: see pkg/export/sync.go), required
+  -host <name>    - hostname of records (default local hostname)
+  -query <sql>    - run SQL query (read only), print rows as JSON lines
+  -sessions       - show stored sessions (-since/-until, -user <name>)
+
+Merge options:
+  -offset <host=duration>
+                  - explicit clock offset of host (e.g. "web1=-1m30s"),
+                    otherwise it's estimated by boot records (first host
+                    is the reference)
+  -tolerance <duration>
+                  - events of different hosts closer than this are flagged
+                    as ambiguous ("?" mark), default 2s
+
+Simulate options:
+  -rate <rate>    - events per second ("50/s", "600/m", "1000/h"), default 10/s
+  -users <n>      - size of synthetic user population, default 100
+  -seed <n>       - random seed (0 - by current time)
 
 Example:
   gousers --help                           - print full help
   gousers [users]                          - show users from /var/run/utmp
+  gousers -file /var/run/utmp w            - idle times and current commands
+  gousers -file /var/run/utmp kill -user alice -tty pts/3 -dry-run
+                                           - which process would be signalled
+  gousers -file /var/run/utmp msg all "reboot in 10 minutes"
+                                           - warn users before maintenance
   gousers dump                             - dump /var/run/utmp
   gousers info alice                       - show full information about user alice
   gousers stat                             - show logged user statistics
@@ -56,6 +288,46 @@ Example:
   gousers -file /var/log/wtmp -noeuid dump - dump /var/log/wtmp
   gousers -file /var/run/utmp              - show users from /var/run/utmp
   gousers -follow dump                     - follow dump /var/log/wtmp
+  gousers -file /var/run/utmp -slots dump  - dump utmp with slot statistics
+  gousers -json dump                       - dump /var/log/wtmp as JSON lines
+  gousers -since 2024-01-01 sessions       - show sessions since 2024-01-01
+  gousers -since 2024-01-01 groups         - connect time by group since 2024-01-01
+  gousers -since 2024-01-01 ac -p -d       - connect time per user and day
+  gousers report -since 2024-01-01 -until 2024-02-01
+                                           - monthly usage report
+  gousers -since 2024-01-01 sources        - where do people log in from
+  gousers -resolve sessions                - sessions with host names by DNS
+  gousers -auth /var/log/auth.log sessions - which SSH key was used for login
+  gousers -config gousers.json monitor     - monitor with reloadable config
+  gousers monitor -syslog tcp://loghost   - forward logins to remote syslog
+  gousers monitor -journal                 - login history in journalctl -t gousers
+  gousers monitor -format ecs              - ECS JSON lines for Filebeat/Elastic
+  gousers monitor -webhook https://hooks.slack.com/services/...
+                                           - login alerts to Slack channel
+  gousers monitor -publish nats://nats.example.com
+                                           - fleet login telemetry via NATS
+  gousers monitor -ipc /run/gousers.sock   - local socket for host services
+  gousers monitor -dbus system             - D-Bus service for desktop components
+  gousers monitor -desktop                 - notice unexpected logins on workstation
+  OTEL_EXPORTER_OTLP_ENDPOINT=http://otel:4318 gousers -otel monitor
+                                           - monitor with OpenTelemetry export
+  gousers -rotated sessions                - sessions from wtmp and its rotations
+  gousers -since 2024-01-01 -seek dump     - fast dump of the tail of huge wtmp
+  gousers -file /var/run/utmp -elevated    - show who is root via su/sudo
+  gousers -file /var/run/utmp -ssh         - source ports and sshd PIDs of sessions
+  gousers -file host1.wtmp -offline stat   - analyze wtmp copied from host1
+  gousers -stats sessions                  - sessions with performance report
+  gousers -file /mnt/img/var/log/wtmp -offline -root /mnt/img groups
+                                           - forensic analysis of disk image
+  gousers -file /var/run/utmp serve        - REST API for fleet tooling
+  gousers daemon -config /etc/gousers.yaml - all sinks from one watcher
+  gousers export --sqlite sessions.db      - export to SQLite for ad-hoc SQL
+  gousers sync --db sessions.db            - keep history beyond wtmp rotation
+  gousers sync -db sessions.db -query "SELECT user, count(*) FROM sessions GROUP BY user"
+                                           - query long-term history with SQL
+  gousers merge web1=w1.wtmp web2=w2.wtmp  - merge wtmp archives of two hosts
+  gousers simulate -rate 50/s -users 500  - synthetic event stream
+  gousers watch-tty pts/3                  - shadow session on /dev/pts/3
 `)
 	os.Exit(0)
 }
@@ -74,56 +346,221 @@ func main() {
 	flag.StringVar(&File, "file", File, "Input utmp/wtmp/btmp file")
 	flag.BoolVar(&Follow, "follow", Follow, "Follow dump mode (Ctrl+C to stop)")
 	flag.BoolVar(&UseEUID, "euid", UseEUID, "use EUID (for utmp)")
+	flag.BoolVar(&Slots, "slots", Slots, "print utmp slot statistics")
+	flag.BoolVar(&JSON, "json", JSON, "dump records as JSON")
+	flag.StringVar(&Since, "since", Since, "consider records since time")
+	flag.StringVar(&Until, "until", Until, "consider records until time")
+	flag.StringVar(&Config, "config", Config, "detection config file (JSON)")
+	flag.BoolVar(&Rotated, "rotated", Rotated, "also read rotated files")
+	flag.BoolVar(&Seek, "seek", Seek, "binary search for -since time")
+	flag.DurationVar(&Dedup, "dedup", Dedup, "collapse duplicate login records")
+	flag.BoolVar(&Elevate, "elevated", Elevate, "detect su/sudo sessions")
+	flag.BoolVar(&SSH, "ssh", SSH, "find TCP connections of SSH sessions")
+	flag.BoolVar(&Offline, "offline", Offline, "offline analysis (no /proc lookups)")
+	flag.StringVar(&Mux, "mux", Mux, "tmux/screen entries: keep, tag or collapse")
+	flag.BoolVar(&Stale, "stale", Stale, "check PIDs of entries are not reused")
+	flag.BoolVar(&Stats, "stats", Stats, "print performance statistics")
+	flag.BoolVar(&Boot, "current-boot", Boot, "skip logins before current boot")
+	flag.DurationVar(&Budget, "budget", Budget, "user info time budget (monitor)")
+	flag.DurationVar(&InfoTTL, "info-ttl", InfoTTL, "user info cache TTL")
+	flag.StringVar(&Root, "root", Root, "passwd/group files root directory")
+	flag.BoolVar(&Incr, "incremental", Incr, "monitor parses only appended records")
+	flag.DurationVar(&Bounce, "debounce", Bounce, "merge utmp updates within duration")
+	flag.StringVar(&Backend, "backend", Backend, "monitor backend: fsnotify or poll")
+	flag.BoolVar(&Otel, "otel", Otel, "export to OpenTelemetry (OTEL_* env)")
+	flag.BoolVar(&Resolve, "resolve", Resolve, "fill missing host names by reverse DNS")
+	flag.StringVar(&AuthLog, "auth", AuthLog, "sshd log (auth.log, secure or journal)")
 	flag.Parse()
 
+	// Take sockets of systemd socket activation (serve, exporter, daemon...)
+	Sockets = systemd.Files()
+
+	// Load detection config
+	if Config != "" {
+		err := utmp.LoadConfig(Config)
+		if err != nil {
+			log.Fatalf("fatal: can't load config: %v\n", err)
+		}
+	}
+
+	// Resolve users by passwd/group files of another system
+	if Root != "" {
+		db, err := utmp.ReadUserDBRoot(Root)
+		if err != nil {
+			log.Fatalf("fatal: can't read user database: %v\n", err)
+		}
+		utmp.SetUserDB(db)
+	}
+
+	// Cache user info lookups
+	utmp.SetUserInfoTTL(InfoTTL)
+
+	// Collect performance statistics
+	start := time.Now()
+	if Stats {
+		utmp.EnablePerfStat(true)
+	}
+
+	// Disable /proc lookups for files from another host
+	if Offline {
+		utmp.SetOffline(true)
+		fmt.Fprintln(os.Stderr,
+			"note: offline analysis, /proc and user lookups are disabled "+
+				"(no EUID/su/sudo/user info, X sessions are not refined to remote_x)")
+	}
+
+	mux, err := utmp.ParseMuxMode(Mux)
+	if err != nil {
+		log.Fatalf("fatal: %v\n", err)
+	}
+
+	// Prepare options to read utmp/wtmp/btmp file
+	opts := utmp.GetUsersOpts{
+		UseEUID:     UseEUID,
+		Since:       ParseTime(Since),
+		Until:       ParseTime(Until),
+		Rotated:     Rotated,
+		SeekSince:   Seek,
+		Dedup:       Dedup,
+		Elevated:    Elevate,
+		SSH:         SSH,
+		Mux:         mux,
+		Stale:       Stale,
+		CurrentBoot: Boot,
+		Offline:     Offline}
+
 	// Parse commands
 	args := flag.Args() // os.Args without flags
 	argc := len(args)
 
 	if argc == 0 { // show currently logged users by default
-		ShowUsers(File, UseEUID) // #1
+		CheckAccess(File)
+		ShowUsers(File, opts) // #1
 		return
 	}
 
 	arg := args[0]
 
+	// Ctrl+C, SIGHUP etc. to channels of signal package (log signals) in
+	// long-running modes only, other commands keep default handling
+	if LongRunning(arg, Follow) {
+		signal.Install(signal.Opts{Logger: log.Default()})
+	}
+
+	// Check file can be read (capability report instead of EACCES)
+	if arg != "simulate" && arg != "merge" && arg != "daemon" {
+		CheckAccess(File)
+	}
+
 	if arg == "users" || arg == "user" { // show currently logged users
-		ShowUsers(File, UseEUID) // #2
+		ShowUsers(File, opts) // #2
+	} else if arg == "w" { // who is logged on and what they are doing
+		ShowW(File, args[1:], opts)
 	} else if arg == "info" { // show full information about user (JSON)
 		if argc < 2 {
 			log.Fatalf("fatal: no user selected (run with --help option)")
 		} else {
-			ShowUser(File, args[1], UseEUID)
+			ShowUser(File, args[1], opts)
 		}
 	} else if arg == "stat" { // show logged user statistics (JSON)
-		ShowUsersStat(File, UseEUID)
+		ShowUsersStat(File, opts)
 	} else if arg == "dump" { // dump utmp/wtmp/btmp file
-		DumpUtmp(File, Follow)
+		DumpUtmp(File, Follow, opts)
 	} else if arg == "monitor" { // login/logout monitor
-		Monitor(File, UseEUID)
+		Monitor(File, args[1:], UseEUID)
+	} else if arg == "system" { // system events from wtmp
+		ShowSystemEvents(File)
+	} else if arg == "sessions" { // user sessions from wtmp
+		ShowSessions(File, opts)
+	} else if arg == "msg" { // write message to terminals
+		Msg(File, args[1:], opts)
+	} else if arg == "kill" { // terminate sessions
+		Kill(File, args[1:], opts)
+	} else if arg == "groups" { // sessions and connect time by group
+		ShowGroups(File, opts)
+	} else if arg == "ac" { // connect time per user and/or day
+		Ac(File, args[1:], opts)
+	} else if arg == "report" { // aggregate usage report
+		ShowReport(File, args[1:], opts)
+	} else if arg == "sources" { // remote sessions by source host/network
+		ShowSources(File, args[1:], opts)
+	} else if arg == "daemon" { // watcher with sinks from config file
+		Daemon(args[1:])
+	} else if arg == "serve" { // REST API server
+		Serve(File, args[1:], opts)
+	} else if arg == "grpc-serve" { // gRPC server
+		GRPCServe(File, args[1:], opts)
+	} else if arg == "exporter" { // Prometheus exporter
+		Exporter(File, args[1:], opts)
+	} else if arg == "sync" { // incremental import to SQLite database
+		Sync(File, args[1:], opts)
+	} else if arg == "export" { // export sessions/boots/failed logins
+		Export(File, args[1:], opts)
+	} else if arg == "merge" { // merge records of several hosts
+		Merge(args[1:], opts)
+	} else if arg == "simulate" { // synthetic login/logout events
+		Simulate(args[1:])
+	} else if arg == "watch-tty" { // follow one terminal session
+		WatchTTY(File, args[1:], opts)
 	} else { // show error and exit if command is unknown
 		log.Fatalf("error: unknown command '%s' (run with --help option)\n", arg)
 	}
+
+	if Stats {
+		PrintPerfStat(utmp.GetPerfStat(), time.Since(start))
+	}
 } // func main()
 
+// Commands reading signal channels (Ctrl+C, SIGHUP...) until stopped
+var longRunning = map[string]bool{
+	"monitor":    true,
+	"daemon":     true,
+	"serve":      true,
+	"grpc-serve": true,
+	"exporter":   true,
+	"simulate":   true,
+	"watch-tty":  true,
+}
+
+// Check command runs until Ctrl+C or SIGTERM (dump in follow mode too)
+func LongRunning(arg string, follow bool) bool {
+	return longRunning[arg] || arg == "dump" && follow
+}
+
 // Show active users from utmp/wtmp/btmp file
-func ShowUsers(fname string, useEUID bool) {
-	users, err := utmp.GetUsers(fname, useEUID)
+func ShowUsers(fname string, opts utmp.GetUsersOpts) {
+	users, err := utmp.GetUsersWith(fname, opts)
 	if err != nil {
 		log.Fatalf("fatal: can't read utmp/wtmp/btmp file: %v\n", err)
 	}
+	ResolveHosts(func(r *utmp.HostResolver) { r.FillUsers(users) })
+	if AuthLog != "" {
+		authlog.CorrelateUsers(users, ReadAuthLog(opts), authlog.WINDOW)
+	}
 
 	for _, u := range users {
 		u.Print(os.Stdout)
 	}
+
+	if Slots {
+		st, err := utmp.GetScanStatWith(fname, opts)
+		if err != nil {
+			log.Fatalf("fatal: can't read utmp/wtmp/btmp file: %v\n", err)
+		}
+		PrintScanStat(st)
+	}
 }
 
 // Show Full user info
-func ShowUser(fname, username string, useEUID bool) {
-	users, err := utmp.GetUsers(fname, useEUID)
+func ShowUser(fname, username string, opts utmp.GetUsersOpts) {
+	opts.Idle = true
+	users, err := utmp.GetUsersWith(fname, opts)
 	if err != nil {
 		log.Fatalf("fatal: can't read utmp/wtmp/btmp file: %v\n", err)
 	}
+	if AuthLog != "" {
+		authlog.CorrelateUsers(users, ReadAuthLog(opts), authlog.WINDOW)
+	}
 
 	li, err := users.GetLoginInfo(username)
 	if err != nil {
@@ -131,129 +568,492 @@ func ShowUser(fname, username string, useEUID bool) {
 	}
 
 	// Repack utmp.LoginInfo to dto.User
-	u := dto.User{
-		Name:        li.Name,
-		UID:         li.UID,
-		GID:         li.GID,
-		DisplayName: li.DisplayName,
-		HomeDir:     li.HomeDir,
-		Groups:      li.Groups,
-		LogonType:   dto.LogonType[li.Type],
-		LogonTime:   li.Time,
-		Logons:      li.Logons}
+	u := utmphttp.User(*li)
+	u.Failures = UserFailures(u.Name, u.UID)
+	u.LastLog = UserLastLog(u.Name, u.UID)
 
 	// Encode full user info to JSON
 	data, err := json.MarshalIndent(&u, "", "  ")
 	if err != nil {
-		log.Fatalf("fatal: json.Marshal():", err)
+		log.Fatalf("fatal: json.Marshal(): %v", err)
 	}
 
 	fmt.Println(string(data))
 }
 
 // Show logged user statistics (JSON)
-func ShowUsersStat(fname string, useEUID bool) {
-	users, err := utmp.GetUsers(fname, useEUID)
+func ShowUsersStat(fname string, opts utmp.GetUsersOpts) {
+	users, err := utmp.GetUsersWith(fname, opts)
 	if err != nil {
 		log.Fatalf("fatal: can't read utmp/wtmp/btmp file: %v\n", err)
 	}
 
 	// get logged user statistics
-	us := users.GetLoginStat()
-
-	stat := dto.UsersStat{
-		Total:      us.Total,
-		LocalX:     us.LocalX,
-		Local:      us.Local,
-		RemoteX:    us.RemoteX,
-		Remote:     us.Remote,
-		Unknown:    us.Unknown,
-		LocalRoot:  us.LocalRoot,
-		RemoteRoot: us.RemoteRoot,
-		Active:     us.Active.Name}
+	stat := utmphttp.Stat(users.GetLoginStat())
 
 	// Encode statistics to JSON
 	data, err := json.MarshalIndent(&stat, "", "  ")
 	if err != nil {
-		log.Fatalf("fatal: json.Marshal():", err)
+		log.Fatalf("fatal: json.Marshal(): %v", err)
 	}
 
 	fmt.Println(string(data))
 }
 
+// Parse time option ("" -> zero time)
+func ParseTime(s string) time.Time {
+	if s == "" {
+		return time.Time{}
+	}
+	t, err := utmphttp.ParseTime(s)
+	if err != nil {
+		log.Fatalf("fatal: %v (run with --help option)\n", err)
+	}
+	return t
+}
+
+// Show user sessions (login/logout pairs)
+func ShowSessions(fname string, opts utmp.GetUsersOpts) {
+	sessions, err := utmp.GetSessions(fname, opts)
+	if err != nil {
+		log.Fatalf("fatal: can't read utmp/wtmp/btmp file: %v\n", err)
+	}
+	ResolveHosts(func(r *utmp.HostResolver) { r.FillSessions(sessions) })
+	if AuthLog != "" {
+		authlog.Correlate(sessions, ReadAuthLog(opts), authlog.WINDOW)
+	}
+
+	now := time.Now()
+	for _, s := range sessions {
+		fmt.Printf("%-12s %-8s %-16s %s",
+			s.User, s.TTY, s.Host, s.Login.Format("2006-01-02 15:04:05"))
+		if s.End == utmp.SESSION_ACTIVE {
+			fmt.Printf(" - %-19s", "still logged in")
+		} else {
+			fmt.Printf(" - %-19s", s.Logout.Format("2006-01-02 15:04:05"))
+		}
+		fmt.Printf(" (%s) %s", s.Duration(now).Truncate(time.Second), s.End)
+		if a := s.Auth; a != nil {
+			fmt.Print(" ", a.Method)
+			if a.Fingerprint != "" {
+				fmt.Print(" ", a.KeyType, " ", a.Fingerprint)
+			}
+			if a.CertID != "" {
+				fmt.Printf(" ID '%s'", a.CertID)
+			}
+		}
+		fmt.Println()
+	}
+}
+
+// Read sshd authentications (-auth option), journal is read since
+// -since time (less correlation window)
+func ReadAuthLog(opts utmp.GetUsersOpts) []authlog.Event {
+	var events []authlog.Event
+	var err error
+	if AuthLog == authlog.JOURNAL {
+		since := opts.Since
+		if !since.IsZero() {
+			since = since.Add(-authlog.WINDOW)
+		}
+		events, err = authlog.ReadJournal(context.Background(), since, opts.Until)
+	} else {
+		events, err = authlog.ReadFile(AuthLog)
+	}
+	if err != nil {
+		log.Printf("warning: can't read sshd log: %v\n", err)
+	}
+	return events
+}
+
+// Fill missing host names by DNS (-resolve option): fill() takes names
+// from resolver cache and queues lookups, it's called again after lookups
+func ResolveHosts(fill func(r *utmp.HostResolver)) {
+	if !Resolve {
+		return
+	}
+	r := utmp.NewHostResolver(utmp.HostResolverOpts{})
+	defer r.Close()
+
+	fill(r)
+	ctx, cancel := context.WithTimeout(context.Background(), RESOLVE_WAIT)
+	defer cancel()
+	if err := r.Wait(ctx); err != nil {
+		fmt.Fprintln(os.Stderr, "note: some DNS lookups are not finished in", RESOLVE_WAIT)
+	}
+	fill(r)
+}
+
+// Show sessions and connect time by group
+func ShowGroups(fname string, opts utmp.GetUsersOpts) {
+	sessions, err := utmp.GetSessions(fname, opts)
+	if err != nil {
+		log.Fatalf("fatal: can't read utmp/wtmp/btmp file: %v\n", err)
+	}
+
+	report := utmp.GroupReport(sessions, time.Now())
+	if JSON {
+		stats := []dto.GroupStat{}
+		for _, g := range report {
+			stats = append(stats, dto.GroupStat{
+				Group:    g.Group,
+				Users:    g.Users,
+				Sessions: g.Sessions,
+				Seconds:  int64(g.Time / time.Second)})
+		}
+		data, err := json.MarshalIndent(&stats, "", "  ")
+		if err != nil {
+			log.Fatalf("fatal: json.Marshal(): %v", err)
+		}
+		fmt.Println(string(data))
+		return
+	}
+
+	fmt.Printf("%-16s %6s %9s %s\n", "GROUP", "USERS", "SESSIONS", "TIME")
+	for _, g := range report {
+		group := g.Group
+		if group == "" {
+			group = "-"
+		}
+		fmt.Printf("%-16s %6d %9d %s\n", group, g.Users, g.Sessions, g.Time.Truncate(time.Second))
+	}
+}
+
+// Show system events (boot, run level changes, clock adjustments)
+func ShowSystemEvents(fname string) {
+	events, err := utmp.SystemEvents(fname)
+	if err != nil {
+		log.Fatalf("fatal: can't read utmp/wtmp/btmp file: %v\n", err)
+	}
+
+	for _, e := range events {
+		fmt.Print(e.Time.Format("2006-01-02 15:04:05"), " ", e.Type)
+		switch e.Type {
+		case utmp.SYS_BOOT:
+			fmt.Printf(" kernel='%s'", e.Kernel)
+		case utmp.SYS_RUN_LEVEL, utmp.SYS_SHUTDOWN:
+			fmt.Print(" level=", e.RunLevel)
+			if e.PrevLevel != "" {
+				fmt.Print(" prev=", e.PrevLevel)
+			}
+		case utmp.SYS_CLOCK:
+			if !e.OldTime.IsZero() {
+				fmt.Print(" old=", e.OldTime.Format("2006-01-02 15:04:05"))
+				fmt.Print(" delta=", e.Delta)
+			}
+		}
+		fmt.Println()
+	}
+}
+
 // Dump utmp/wtmp/btmp file as plain text
-func DumpUtmp(fname string, follow bool) {
-	f, err := os.Open(fname)
+func DumpUtmp(fname string, follow bool, opts utmp.GetUsersOpts) {
+	f, err := opts.Open(fname)
 	if err != nil {
 		log.Fatalf("fatal: can't open utmp/wtmp/btmp file: %v\n", err)
 	}
 	defer f.Close()
 
+	s := opts.NewScanner(f)
 Loop:
 	for {
-		var u utmp.Utmp
-		err = utmp.Read(f, &u)
-		if err != nil {
-			if !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
-				log.Fatalf(`fatal: read "%s": %v`, fname, err)
+		for s.Scan() {
+			if !opts.InRange(utmp.Time(s.Record().TV)) {
+				continue
 			}
-
-			if !follow {
-				break
+			if JSON {
+				PrintRecordJSON(s.Record())
+			} else {
+				s.Record().PrintWith(os.Stdout, opts.Offline)
 			}
+		}
+		if err = s.Err(); err != nil {
+			log.Fatalf(`fatal: read "%s": %v`, fname, err)
+		}
 
-			select {
-			case <-time.After(FOLLOW_INTERVAL):
-			case <-signal.CtrlC:
-				break Loop
-			}
-			continue
+		if !follow {
+			break
 		}
 
-		u.Print(os.Stdout)
+		select {
+		case <-time.After(FOLLOW_INTERVAL):
+		case <-signal.CtrlC:
+			break Loop
+		}
 	} // for
+
+	if Slots {
+		PrintScanStat(s.Stat())
+	}
 }
 
-// Login/logout monitor
-func Monitor(fname string, useEUID bool) {
-	l, err := utmp.NewLogin(fname, useEUID)
+// Print one utmp/wtmp/btmp record as JSON line
+func PrintRecordJSON(u *utmp.Utmp) {
+	rec := RecordDTO(u.Decode())
+
+	data, err := json.Marshal(&rec)
+	if err != nil {
+		log.Fatalf("fatal: json.Marshal(): %v", err)
+	}
+
+	fmt.Println(string(data))
+}
+
+// Repack utmp.Record to dto.Record
+func RecordDTO(r utmp.Record) dto.Record {
+	rec := dto.Record{
+		Type:        r.Type,
+		TypeName:    r.TypeName,
+		PID:         r.PID,
+		RunLevel:    r.RunLevel,
+		Line:        r.Line,
+		ID:          r.ID,
+		User:        r.User,
+		Host:        r.Host,
+		AddrV6:      r.AddrV6,
+		Termination: r.Termination,
+		Exit:        r.Exit,
+		Session:     r.Session,
+		Time:        r.Time}
+	if len(r.IP) != 0 {
+		rec.IP = r.IP.String()
+	}
+	return rec
+}
+
+// Print utmp slot statistics to stderr
+func PrintScanStat(st utmp.ScanStat) {
+	fmt.Fprintf(os.Stderr,
+		"slots: records=%d empty=%d partial=%d dead=%d reused=%d broken=%d duplicates=%d\n",
+		st.Records, st.Empty, st.Partial, st.Dead, st.Reused, st.Broken, st.Duplicates)
+}
+
+// Print performance statistics to stderr
+func PrintPerfStat(ps utmp.PerfStat, elapsed time.Duration) {
+	sec := elapsed.Seconds()
+	avg := func(n int64, d time.Duration) time.Duration {
+		if n == 0 {
+			return 0
+		}
+		return d / time.Duration(n)
+	}
+	fmt.Fprintf(os.Stderr,
+		"stats: elapsed=%v records=%d (%.0f/s) parsed=%.1fMB (%.1fMB/s)\n",
+		elapsed.Round(time.Millisecond), ps.Records, float64(ps.Records)/sec,
+		float64(ps.Bytes)/1e6, float64(ps.Bytes)/1e6/sec)
+	fmt.Fprintf(os.Stderr,
+		"stats: intern hits=%d misses=%d (hit rate %.1f%%)\n",
+		ps.InternHits, ps.InternMisses, 100*ps.InternHitRate())
+	fmt.Fprintf(os.Stderr,
+		"stats: proc lookups=%d (avg %v) user lookups=%d (avg %v)\n",
+		ps.ProcCalls, avg(ps.ProcCalls, ps.ProcTime),
+		ps.UserCalls, avg(ps.UserCalls, ps.UserTime))
+	fmt.Fprintf(os.Stderr,
+		"stats: user cache hits=%d misses=%d (hit rate %.1f%%)\n",
+		ps.UserCacheHits, ps.UserCacheMisses, 100*ps.UserCacheHitRate())
+}
+
+// Start login/logout watcher (monitor, serve)
+func StartLogin(fname string, useEUID bool) *utmp.Login {
+	backend, err := utmp.ParseBackend(Backend)
+	if err != nil {
+		log.Fatalf("fatal: %v", err)
+	}
+	l, err := utmp.NewLoginWith(fname, utmp.LoginOpts{
+		UseEUID:     useEUID,
+		Stale:       Stale,
+		Logger:      slog.Default(), // errors to stderr
+		Debounce:    Bounce,
+		Incremental: Incr,
+		RawRecords:  Raw,
+		Backend:     backend})
+	if err != nil {
+		log.Fatalf("fatal: %v", err)
+	}
+	l.SetEnrichBudget(Budget)
+
+	// Reload detection config on change
+	if Config != "" {
+		err = l.WatchConfig(Config)
+		if err != nil {
+			log.Fatalf("fatal: %v", err)
+		}
+	}
+	return l
+}
+
+// Start OpenTelemetry export (option -otel), nil if disabled
+func StartOtel(l *utmp.Login) *otel.Exporter {
+	if !Otel {
+		return nil
+	}
+	exp, err := NewOtel(l)
 	if err != nil {
+		l.Close()
+		log.Fatalf("fatal: %v", err)
+	}
+	return exp
+}
+
+// Reload detection config, drop user info cache (SIGHUP)
+func ReloadConfig(l *utmp.Login) {
+	utmp.InvalidateUserInfo()
+	if Config != "" {
+		err := utmp.LoadConfig(Config)
+		if err != nil {
+			log.Printf("error: can't reload config: %v", err)
+			return
+		}
+	}
+	l.Reload()
+}
+
+// Login/logout monitor
+func Monitor(fname string, args []string, useEUID bool) {
+	fs := flag.NewFlagSet("monitor", flag.ExitOnError)
+	syslog := fs.String("syslog", "", "forward events to syslog (local, udp://, tcp://, tls://)")
+	journal := fs.Bool("journal", false, "write events to systemd-journald")
+	format := fs.String("format", "text", "output format: text, cef or ecs")
+	webhook := fs.String("webhook", "", "POST events as JSON to URL")
+	publish := fs.String("publish", "", "publish events to broker (nats://, mqtt://, kafka+http://)")
+	topic := fs.String("topic", "", "topic template ({host}, {action}, {user}, {type})")
+	payload := fs.String("payload", sink.PAYLOAD_JSON, "published payload: json or protobuf")
+	batch := fs.Int("webhook-batch", 1, "logins/logouts per webhook request")
+	ipc := fs.String("ipc", "", "serve local consumers on Unix socket (e.g. "+utmpipc.SOCKET+")")
+	bus := fs.String("dbus", "", "D-Bus service "+utmpdbus.NAME+": system, session or bus address")
+	desktop := fs.Bool("desktop", false, "desktop notifications of logins (active graphical session)")
+	headers := make(map[string]string)
+	fs.Func("webhook-header", "webhook request header \"Name: value\" (repeatable)", func(h string) error {
+		name, value, ok := strings.Cut(h, ":")
+		if !ok {
+			return fmt.Errorf("bad header %q", h)
+		}
+		headers[strings.TrimSpace(name)] = strings.TrimSpace(value)
+		return nil
+	})
+	fs.Parse(args)
+
+	var enc siem.Encoder
+	if *format != "text" {
+		var err error
+		enc, err = siem.NewEncoder(*format, siem.Opts{})
+		if err != nil {
+			log.Fatalf("fatal: %v", err)
+		}
+	}
+
+	var sc SinksConfig
+	if *syslog != "" {
+		sc.Syslog = &SyslogConfig{Target: *syslog}
+	}
+	if *journal {
+		sc.Journal = &JournalConfig{}
+	}
+	if *webhook != "" {
+		sc.Webhook = &WebhookConfig{URL: *webhook, Headers: headers, Batch: *batch}
+	}
+	if *publish != "" {
+		sc.Publish = &PublishConfig{URL: *publish, Topic: *topic, Payload: *payload}
+	}
+	if *ipc != "" {
+		sc.IPC = &IPCConfig{Socket: *ipc}
+	}
+	if *bus != "" {
+		sc.DBus = &DBusConfig{Bus: *bus}
+	}
+	if *desktop {
+		sc.Desktop = &DesktopConfig{}
+	}
+	Raw = sc != (SinksConfig{}) || enc != nil // host/IP by utmp records
+
+	l := StartLogin(fname, useEUID)
+	exp := StartOtel(l)
+	out := NewOutputs(l)
+	if err := out.Start(sc, FilterConfig{}, nil); err != nil {
+		exp.Close()
+		l.Close()
 		log.Fatalf("fatal: %v", err)
 	}
+	stop := out.Stop
 
 Loop:
 	for {
 		select {
 		case evt := <-l.C():
-			if len(evt.Login) != 0 {
-				fmt.Printf(evt.Time.Format("2006-01-02 15:04:05"))
-				fmt.Printf(" login:")
-				for _, ut := range evt.Login {
-					fmt.Printf(" %s[%s]", ut.User, ut.TTY)
-				}
-				if evt.Stat.Active != nil {
-					fmt.Printf(" active=%s", evt.Stat.Active.Name)
-				}
-				fmt.Println()
+			if enc == nil {
+				PrintLoginEvent(evt)
+				continue
+			}
+			for _, e := range sink.Entries(evt) {
+				fmt.Println(string(enc.Entry(e)))
 			}
 
-			if len(evt.Logout) != 0 {
-				fmt.Printf(evt.Time.Format("2006-01-02 15:04:05"))
-				fmt.Printf(" logout:")
-				for _, ut := range evt.Logout {
-					fmt.Printf(" %s[%s]", ut.User, ut.TTY)
-				}
-				if evt.Stat.Active != nil {
-					fmt.Printf(" active=%s", evt.Stat.Active.Name)
-				}
-				fmt.Println()
+		case err := <-l.Errors(): // already logged
+			var e *utmp.LoginError
+			if errors.As(err, &e) && e.Fatal {
+				stop()
+				exp.Close()
+				l.Close()
+				log.Fatalf("fatal: monitor stopped: %v", err)
 			}
 
+		case <-signal.SigHUP: // reload detection config, drop user info cache
+			ReloadConfig(l)
+
+		case <-signal.SigUSR1: // dump logged user statistics to stderr
+			stat := utmphttp.Stat(l.GetStat())
+			data, err := json.MarshalIndent(&stat, "", "  ")
+			if err != nil {
+				log.Printf("error: json.Marshal(): %v", err)
+				continue
+			}
+			fmt.Fprintln(os.Stderr, string(data))
+
 		case <-signal.CtrlC:
 			break Loop
 		}
 	}
+	stop()
+	exp.Close()
 	l.Close()
 }
 
+// Print login/logout event
+func PrintLoginEvent(evt utmp.LoginEvent) {
+	if evt.Resync {
+		fmt.Printf(evt.Time.Format("2006-01-02 15:04:05"))
+		fmt.Println(" resync: utmp file truncated or replaced")
+	}
+
+	if len(evt.Login) != 0 {
+		fmt.Printf(evt.Time.Format("2006-01-02 15:04:05"))
+		fmt.Printf(" login:")
+		for _, ut := range evt.Login {
+			fmt.Printf(" %s[%s]", ut.User, ut.TTY)
+		}
+		if evt.Stat.Active != nil {
+			fmt.Printf(" active=%s", evt.Stat.Active.Name)
+		}
+		if evt.Partial {
+			fmt.Printf(" (partial)")
+		}
+		fmt.Println()
+	}
+
+	if len(evt.Logout) != 0 {
+		fmt.Printf(evt.Time.Format("2006-01-02 15:04:05"))
+		fmt.Printf(" logout:")
+		for _, ut := range evt.Logout {
+			fmt.Printf(" %s[%s]", ut.User, ut.TTY)
+		}
+		if evt.Stat.Active != nil {
+			fmt.Printf(" active=%s", evt.Stat.Active.Name)
+		}
+		if evt.Partial {
+			fmt.Printf(" (partial)")
+		}
+		fmt.Println()
+	}
+}
+
 // EOF: "gousers.go"
@@ -0,0 +1,24 @@
+// File: "gousers_test.go"
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLongRunning(t *testing.T) {
+	for _, arg := range []string{"monitor", "daemon", "serve", "grpc-serve", "exporter",
+		"simulate", "watch-tty"} {
+		require.True(t, LongRunning(arg, false), arg)
+	}
+	require.True(t, LongRunning("dump", true))
+
+	// one-shot commands keep default signal handling
+	for _, arg := range []string{"dump", "sessions", "export", "sync", "msg", "kill", "users"} {
+		require.False(t, LongRunning(arg, false), arg)
+	}
+}
+
+// EOF: "gousers_test.go"
@@ -0,0 +1,90 @@
+// File: "grpcserve.go"
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/azorg/gousers/v2/pkg/signal"
+	"github.com/azorg/gousers/v2/pkg/systemd"
+	"github.com/azorg/gousers/v2/pkg/utmp"
+	"github.com/azorg/gousers/v2/pkg/utmpgrpc"
+)
+
+// Default listen address of grpc-serve command
+const GRPC_ADDR = "127.0.0.1:50051"
+
+// gRPC server (grpc-serve command): service gousers.v1.Gousers (see
+// pkg/utmpgrpc/gousers.proto) over TLS
+func GRPCServe(fname string, args []string, opts utmp.GetUsersOpts) {
+	fs := flag.NewFlagSet("grpc-serve", flag.ExitOnError)
+	addr := fs.String("listen", GRPC_ADDR, "listen address")
+	cert := fs.String("cert", "", "TLS certificate file (PEM)")
+	key := fs.String("key", "", "TLS private key file (PEM)")
+	fs.Parse(args)
+
+	if *cert == "" || *key == "" {
+		log.Fatalf("fatal: -cert and -key are required (gRPC over TLS)")
+	}
+
+	l := StartLogin(fname, opts.UseEUID)
+	defer l.Close()
+	exp := StartOtel(l)
+	defer exp.Close()
+
+	srv := &http.Server{
+		Addr:              *addr,
+		Handler:           utmpgrpc.Handler(l),
+		ReadHeaderTimeout: 10 * time.Second}
+	ln, err := Listen("grpc", *addr)
+	if err != nil {
+		log.Fatalf("fatal: %v", err)
+	}
+	go func() {
+		err := srv.ServeTLS(ln, *cert, *key)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("fatal: %v", err)
+		}
+	}()
+	Notify(systemd.READY)
+	wd := systemd.NewWatchdog()
+	defer wd.Stop()
+
+Loop:
+	for {
+		select {
+		case <-l.C(): // state is read by handlers
+
+		case err := <-l.Errors(): // already logged
+			var e *utmp.LoginError
+			if errors.As(err, &e) && e.Fatal {
+				l.Close()
+				log.Fatalf("fatal: watcher stopped: %v", err)
+			}
+
+		case <-signal.SigHUP: // reload detection config, drop user info cache
+			Notify(systemd.RELOADING)
+			ReloadConfig(l)
+			Notify(systemd.READY)
+
+		case <-wd.C: // systemd watchdog (WatchdogSec=)
+			Notify(systemd.WATCHDOG)
+
+		case <-signal.CtrlC: // Ctrl+C or SIGTERM (systemctl stop)
+			break Loop
+		}
+	}
+	Notify(systemd.STOPPING)
+
+	l.Close() // end WatchEvents streams
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	srv.Shutdown(ctx)
+}
+
+// EOF: "grpcserve.go"
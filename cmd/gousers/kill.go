@@ -0,0 +1,106 @@
+// File: "kill.go"
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/azorg/gousers/v2/pkg/utmp"
+)
+
+// Signal names accepted by kill command
+var signals = map[string]syscall.Signal{
+	"HUP":  syscall.SIGHUP,
+	"INT":  syscall.SIGINT,
+	"QUIT": syscall.SIGQUIT,
+	"KILL": syscall.SIGKILL,
+	"USR1": syscall.SIGUSR1,
+	"USR2": syscall.SIGUSR2,
+	"TERM": syscall.SIGTERM,
+	"CONT": syscall.SIGCONT,
+	"STOP": syscall.SIGSTOP,
+}
+
+// Terminate sessions of user and/or tty (kill command)
+func Kill(fname string, args []string, opts utmp.GetUsersOpts) {
+	fs := flag.NewFlagSet("kill", flag.ExitOnError)
+	user := fs.String("user", "", "username of sessions")
+	tty := fs.String("tty", "", "tty of session (e.g. pts/3)")
+	sig := fs.String("signal", "HUP", "signal name or number")
+	group := fs.Bool("group", false, "signal process group of session leader too")
+	dry := fs.Bool("dry-run", false, "print targets, don't send signal")
+	fs.Parse(args)
+
+	if *user == "" && *tty == "" {
+		log.Fatalf("fatal: no -user or -tty selected (run with --help option)")
+	}
+	signum, err := parseSignal(*sig)
+	if err != nil {
+		log.Fatalf("fatal: %v (run with --help option)", err)
+	}
+	*tty = strings.TrimPrefix(*tty, "/dev/")
+
+	sessions, err := utmp.GetSessions(fname, opts)
+	if err != nil {
+		log.Fatalf("fatal: can't read utmp/wtmp/btmp file: %v\n", err)
+	}
+
+	found, failed := 0, 0
+	for i := range sessions {
+		s := &sessions[i]
+		if s.End != utmp.SESSION_ACTIVE ||
+			(*user != "" && s.User != *user) || (*tty != "" && s.TTY != *tty) {
+			continue
+		}
+		found++
+		targets, err := s.KillWith(signum, utmp.KillOpts{Group: *group, DryRun: *dry})
+		what := fmt.Sprintf("%s %s%s", s.User, s.TTY, hostStr(s.Host))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s pid=%d: %v\n", what, s.PID, err)
+			failed++
+			continue
+		}
+		for _, t := range targets {
+			prefix := ""
+			if *dry {
+				prefix = "dry-run: "
+			}
+			fmt.Printf("%skill -%s %d # %s\n", prefix, signalName(signum), t, what)
+		}
+	}
+	if found == 0 {
+		log.Fatalf("fatal: no active session found")
+	}
+	if failed != 0 {
+		os.Exit(1)
+	}
+}
+
+// Parse signal name ("TERM", "SIGTERM") or number
+func parseSignal(s string) (syscall.Signal, error) {
+	if n, err := strconv.Atoi(s); err == nil && n > 0 && n < 65 {
+		return syscall.Signal(n), nil
+	}
+	if sig, ok := signals[strings.TrimPrefix(strings.ToUpper(s), "SIG")]; ok {
+		return sig, nil
+	}
+	return 0, fmt.Errorf("unknown signal '%s'", s)
+}
+
+// Signal name for output ("TERM")
+func signalName(sig syscall.Signal) string {
+	for name, s := range signals {
+		if s == sig {
+			return name
+		}
+	}
+	return strconv.Itoa(int(sig))
+}
+
+// EOF: "kill.go"
@@ -0,0 +1,40 @@
+// File: "lastlog.go"
+
+package main
+
+import (
+	"errors"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/azorg/gousers/v2/dto"
+	"github.com/azorg/gousers/v2/pkg/lastlog"
+)
+
+// Get last login of user from lastlog2 database or legacy lastlog
+// (nil if there are no such files or analysis is offline)
+func UserLastLog(name, uid string) *dto.LastLog {
+	if Offline && Root == "" {
+		return nil // last logins of other host are unknown
+	}
+	n, err := strconv.Atoi(uid)
+	if err != nil {
+		n = -1 // lastlog2 only
+	}
+	e, err := lastlog.Get(Root, name, n)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		log.Printf("warning: can't read last login: %v\n", err)
+		return nil
+	}
+	return &dto.LastLog{
+		Source:  e.Source,
+		Time:    e.Time,
+		Line:    e.Line,
+		Host:    e.Host,
+		Service: e.Service}
+}
+
+// EOF: "lastlog.go"
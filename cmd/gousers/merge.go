@@ -0,0 +1,101 @@
+// File: "merge.go"
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/azorg/gousers/v2/dto"
+	"github.com/azorg/gousers/v2/pkg/merge"
+	"github.com/azorg/gousers/v2/pkg/utmp"
+)
+
+// Explicit clock offsets of hosts (-offset host=duration)
+type offsetFlags map[string]time.Duration
+
+func (o offsetFlags) String() string { return fmt.Sprint(map[string]time.Duration(o)) }
+
+func (o offsetFlags) Set(s string) error {
+	host, dur, ok := strings.Cut(s, "=")
+	if !ok {
+		return fmt.Errorf("expected host=duration")
+	}
+	d, err := time.ParseDuration(dur)
+	if err != nil {
+		return err
+	}
+	o[host] = d
+	return nil
+}
+
+// Merge records of several hosts (merge command)
+func Merge(args []string, opts utmp.GetUsersOpts) {
+	fs := flag.NewFlagSet("merge", flag.ExitOnError)
+	offsets := offsetFlags{}
+	fs.Var(offsets, "offset", "explicit clock offset of host (host=duration)")
+	tolerance := fs.Duration("tolerance", merge.DEFAULT_TOLERANCE, "ordering tolerance")
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		log.Fatalf("fatal: no sources selected (run with --help option)")
+	}
+
+	srcs := []merge.Source{}
+	for _, arg := range fs.Args() {
+		src, err := merge.ParseSource(arg)
+		if err != nil {
+			log.Fatalf("fatal: %v", err)
+		}
+		if off, ok := offsets[src.Host]; ok {
+			src.Offset, src.Fixed = off, true
+		}
+		srcs = append(srcs, src)
+		CheckAccess(src.File)
+	}
+
+	events, err := merge.Merge(srcs, opts, *tolerance)
+	if err != nil {
+		log.Fatalf("fatal: can't read utmp/wtmp/btmp file: %v\n", err)
+	}
+
+	for _, e := range events {
+		if JSON {
+			PrintHostEventJSON(e)
+			continue
+		}
+		mark := " "
+		if e.Ambiguous {
+			mark = "?"
+		}
+		fmt.Printf("%s %s %-12s %-10s %-12s %-8s %s\n",
+			e.Corrected.Format("2006-01-02 15:04:05.000"), mark, e.Host,
+			e.TypeName, e.User, e.Line, e.Record.Host)
+	}
+}
+
+// Print event of merged stream as JSON line
+func PrintHostEventJSON(e merge.Event) {
+	evt := dto.HostEvent{
+		Host:      e.Host,
+		Time:      e.Corrected,
+		Estimated: e.Estimated,
+		Ambiguous: e.Ambiguous,
+		Record:    RecordDTO(e.Record)}
+	if e.Offset != 0 {
+		evt.Offset = e.Offset.String()
+	}
+
+	data, err := json.Marshal(&evt)
+	if err != nil {
+		log.Fatalf("fatal: json.Marshal(): %v", err)
+	}
+
+	fmt.Println(string(data))
+}
+
+// EOF: "merge.go"
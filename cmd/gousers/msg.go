@@ -0,0 +1,70 @@
+// File: "msg.go"
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/azorg/gousers/v2/pkg/utmp"
+)
+
+// Write message to terminals of logged in users (msg command)
+func Msg(fname string, args []string, opts utmp.GetUsersOpts) {
+	fs := flag.NewFlagSet("msg", flag.ExitOnError)
+	force := fs.Bool("force", false, "ignore mesg n (root)")
+	from := fs.String("from", "", "sender in message header")
+	fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		log.Fatalf("fatal: no user selected (run with --help option)")
+	}
+	who := fs.Arg(0)
+	text := strings.Join(fs.Args()[1:], " ")
+	if fs.NArg() < 2 { // read message from stdin
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			log.Fatalf("fatal: can't read message: %v\n", err)
+		}
+		text = string(data)
+	}
+	if strings.TrimSpace(text) == "" {
+		log.Fatalf("fatal: empty message")
+	}
+
+	users, err := utmp.GetUsersWith(fname, opts)
+	if err != nil {
+		log.Fatalf("fatal: can't read utmp/wtmp/btmp file: %v\n", err)
+	}
+	if who != "all" {
+		var selected utmp.Users
+		for _, u := range users {
+			if u.Name == who {
+				selected = append(selected, u)
+			}
+		}
+		if len(selected) == 0 {
+			log.Fatalf("fatal: user '%s' is not logged in", who)
+		}
+		users = selected
+	}
+
+	failed := 0
+	for _, r := range users.Message(text, utmp.MessageOpts{From: *from, Force: *force}) {
+		if r.Err != nil {
+			fmt.Fprintf(os.Stderr, "%s %s: %v\n", r.User, r.TTY, r.Err)
+			failed++
+		} else {
+			fmt.Printf("%s %s: ok\n", r.User, r.TTY)
+		}
+	}
+	if failed != 0 {
+		os.Exit(1)
+	}
+}
+
+// EOF: "msg.go"
@@ -0,0 +1,424 @@
+// File: "outputs.go"
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/azorg/gousers/v2/pkg/metrics"
+	"github.com/azorg/gousers/v2/pkg/otel"
+	"github.com/azorg/gousers/v2/pkg/rules"
+	"github.com/azorg/gousers/v2/pkg/sink"
+	"github.com/azorg/gousers/v2/pkg/systemd"
+	"github.com/azorg/gousers/v2/pkg/utmp"
+	"github.com/azorg/gousers/v2/pkg/utmpdbus"
+	"github.com/azorg/gousers/v2/pkg/utmphttp"
+	"github.com/azorg/gousers/v2/pkg/utmpipc"
+)
+
+// Sinks and servers (daemon config "sinks", monitor options)
+type SinksConfig struct {
+	Syslog     *SyslogConfig  `json:"syslog,omitempty"`     // forward to syslog
+	Journal    *JournalConfig `json:"journal,omitempty"`    // write to systemd-journald
+	Webhook    *WebhookConfig `json:"webhook,omitempty"`    // POST to URL
+	Publish    *PublishConfig `json:"publish,omitempty"`    // publish to NATS/MQTT/Kafka
+	Prometheus *ListenConfig  `json:"prometheus,omitempty"` // GET /metrics
+	HTTP       *HTTPConfig    `json:"http,omitempty"`       // REST API (see serve command)
+	IPC        *IPCConfig     `json:"ipc,omitempty"`        // Unix socket (pkg/utmpipc)
+	DBus       *DBusConfig    `json:"dbus,omitempty"`       // D-Bus service (pkg/utmpdbus)
+	Desktop    *DesktopConfig `json:"desktop,omitempty"`    // desktop notifications
+	Otel       bool           `json:"otel,omitempty"`       // OpenTelemetry export (OTEL_* env)
+}
+
+// Syslog sink (see sink.NewSyslog)
+type SyslogConfig struct {
+	Target   string `json:"target"`             // local, udp://, tcp://, tls://
+	Facility int    `json:"facility,omitempty"` // 0 - sink.SYSLOG_FACILITY
+	AppName  string `json:"app_name,omitempty"` // "" - sink.SYSLOG_APP
+}
+
+// journald sink (see sink.NewJournal)
+type JournalConfig struct {
+	Identifier string `json:"identifier,omitempty"` // "" - sink.JOURNAL_IDENTIFIER
+}
+
+// Webhook sink (see sink.NewWebhook)
+type WebhookConfig struct {
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Batch   int               `json:"batch,omitempty"`  // logins/logouts per request
+	Secret  string            `json:"secret,omitempty"` // "" - GOUSERS_WEBHOOK_SECRET env
+}
+
+// Publisher sink (see sink.NewPublisher)
+type PublishConfig struct {
+	URL     string `json:"url"`               // nats://, mqtt://, kafka+http://...
+	Topic   string `json:"topic,omitempty"`   // "" - sink.DefaultTopic()
+	Payload string `json:"payload,omitempty"` // json or protobuf
+}
+
+// HTTP server
+type ListenConfig struct {
+	Listen string `json:"listen,omitempty"` // listen address
+}
+
+// REST API server (see pkg/utmphttp)
+type HTTPConfig struct {
+	Listen   string `json:"listen,omitempty"`   // listen address
+	Sessions string `json:"sessions,omitempty"` // wtmp file for /sessions ("" - disabled)
+}
+
+// Unix socket server (see pkg/utmpipc)
+type IPCConfig struct {
+	Socket string `json:"socket,omitempty"` // "" - utmpipc.SOCKET
+	Mode   string `json:"mode,omitempty"`   // octal file mode, "" - 0660
+}
+
+// D-Bus service (see pkg/utmpdbus)
+type DBusConfig struct {
+	Bus string `json:"bus,omitempty"` // system (""), session or bus address
+}
+
+// Desktop notifications (see utmpdbus.NewNotifier)
+type DesktopConfig struct {
+	Bus     string   `json:"bus,omitempty"`     // "" - session bus of active graphical user
+	Expire  Duration `json:"expire,omitempty"`  // 0 - notification server default
+	Logouts bool     `json:"logouts,omitempty"` // also notify logouts
+}
+
+// Event filter of sinks (syslog, journal, webhook, publish, desktop)
+type FilterConfig struct {
+	Users []string `json:"users,omitempty"` // only logins/logouts of users
+	Types []string `json:"types,omitempty"` // only logon types: remote, remote_x, local, local_x
+}
+
+// Subscription options of filter
+func (f FilterConfig) SubscribeOpts() (utmp.SubscribeOpts, error) {
+	opts := utmp.SubscribeOpts{Users: f.Users}
+	for _, s := range f.Types {
+		t, err := utmp.ParseLoginType(s)
+		if err != nil {
+			return opts, err
+		}
+		opts.Types = append(opts.Types, t)
+	}
+	return opts, nil
+}
+
+// Sinks and servers running from one Login (see Start/Reload/Stop).
+// Prometheus collector and REST API handler are created once: they
+// subscribe to Login until it's closed, counters survive restart of outputs.
+type Outputs struct {
+	l       *utmp.Login
+	metrics *metrics.Collector
+	api     http.Handler
+	rules   *rules.Executor
+	code    int      // exit code set by rule actions
+	stops   []func() // sinks, rules and OpenTelemetry export
+	servers map[string]*server
+	prev    map[string]*server // servers of previous config (see Reload)
+}
+
+// Server started by config (prometheus, http, ipc, dbus)
+type server struct {
+	conf string // config, see serve()
+	stop func()
+}
+
+// Create outputs of Login
+func NewOutputs(l *utmp.Login) *Outputs {
+	return &Outputs{l: l, servers: make(map[string]*server)}
+}
+
+// Start enabled sinks and servers and alert rules (not filtered), already
+// started ones are stopped on error
+func (o *Outputs) Start(sc SinksConfig, f FilterConfig, rs []rules.Rule) error {
+	err := o.start(sc, f, rs)
+	if err != nil {
+		o.Stop()
+	}
+	return err
+}
+
+// Restart outputs with new config: new sinks are started before old ones
+// are stopped, so no events are lost (events of the short overlap may be
+// sent twice). Servers with unchanged config keep running, changed ones
+// are restarted. On error the old sinks keep running, but changed servers
+// may be stopped or started with new config: reload old config to restore
+func (o *Outputs) Reload(sc SinksConfig, f FilterConfig, rs []rules.Rule) error {
+	stops, x := o.stops, o.rules
+	o.stops, o.prev, o.servers = nil, o.servers, make(map[string]*server)
+	err := o.start(sc, f, rs)
+	for name, srv := range o.prev { // removed from config or not reached
+		if err == nil {
+			srv.stop()
+		} else {
+			o.servers[name] = srv
+		}
+	}
+	o.prev = nil
+	if err != nil {
+		for _, stop := range o.stops {
+			stop()
+		}
+		o.stops, o.rules = stops, x
+		return err
+	}
+	for _, stop := range stops {
+		stop()
+	}
+	return nil
+}
+
+func (o *Outputs) start(sc SinksConfig, f FilterConfig, rs []rules.Rule) error {
+	filter, err := f.SubscribeOpts()
+	if err != nil {
+		return err
+	}
+	forward := func(s sink.Sink) {
+		o.stops = append(o.stops, sink.ForwardWith(o.l, s, filter,
+			func(err error) { log.Printf("error: %v", err) }))
+	}
+
+	if len(rs) != 0 {
+		e, err := rules.New(rs)
+		if err != nil {
+			return err
+		}
+		x := rules.NewExecutor(e, rules.ExecOpts{
+			Logger:  slog.Default(),
+			Webhook: sink.WebhookOpts{Secret: os.Getenv("GOUSERS_WEBHOOK_SECRET")}})
+		stop := sink.ForwardWith(o.l, x, utmp.SubscribeOpts{},
+			func(err error) { log.Printf("error: %v", err) })
+		o.rules = x
+		o.stops = append(o.stops, func() {
+			stop()
+			o.code = max(o.code, x.ExitCode())
+			if o.rules == x {
+				o.rules = nil
+			}
+		})
+	}
+
+	if c := sc.Syslog; c != nil {
+		s, err := sink.NewSyslog(c.Target, sink.SyslogOpts{Facility: c.Facility, AppName: c.AppName})
+		if err != nil {
+			return err
+		}
+		forward(s)
+	}
+	if c := sc.Journal; c != nil {
+		j, err := sink.NewJournal(sink.JournalOpts{Identifier: c.Identifier})
+		if err != nil {
+			return err
+		}
+		forward(j)
+	}
+	if c := sc.Webhook; c != nil {
+		secret := c.Secret
+		if secret == "" {
+			secret = os.Getenv("GOUSERS_WEBHOOK_SECRET")
+		}
+		w, err := sink.NewWebhook(c.URL, sink.WebhookOpts{
+			Headers:   c.Headers,
+			Secret:    secret,
+			BatchSize: c.Batch})
+		if err != nil {
+			return err
+		}
+		forward(w)
+	}
+	if c := sc.Publish; c != nil {
+		topic := c.Topic
+		if topic == "" {
+			topic = sink.DefaultTopic(c.URL)
+		}
+		p, err := sink.NewPublisher(c.URL, sink.BrokerOpts{})
+		if err != nil {
+			return err
+		}
+		s, err := sink.NewPublish(p, sink.PublishOpts{Topic: topic, Payload: c.Payload})
+		if err != nil {
+			p.Close()
+			return err
+		}
+		forward(s)
+	}
+	if c := sc.Desktop; c != nil {
+		forward(utmpdbus.NewNotifier(utmpdbus.NotifierOpts{
+			Address: c.Bus,
+			Expire:  time.Duration(c.Expire),
+			Logouts: c.Logouts}))
+	}
+
+	if c := sc.Prometheus; c != nil {
+		if o.metrics == nil {
+			o.metrics = metrics.NewCollector(o.l)
+		}
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", o.metrics)
+		if err := o.serve("prometheus", *c, func() (func(), error) {
+			return listen("prometheus", c.Listen, EXPORTER_ADDR, mux)
+		}); err != nil {
+			return err
+		}
+	}
+	if c := sc.HTTP; c != nil {
+		if o.api == nil {
+			o.api = utmphttp.NewHandler(o.l, utmphttp.Opts{
+				Sessions: c.Sessions,
+				Logger:   slog.Default()})
+		}
+		if err := o.serve("http", *c, func() (func(), error) {
+			return listen("http", c.Listen, SERVE_ADDR, o.api)
+		}); err != nil {
+			return err
+		}
+	}
+	if c := sc.IPC; c != nil {
+		path, mode := c.Socket, uint64(0660)
+		if path == "" {
+			path = utmpipc.SOCKET
+		}
+		if c.Mode != "" {
+			if mode, err = strconv.ParseUint(c.Mode, 8, 32); err != nil {
+				return fmt.Errorf("ipc: bad mode %q", c.Mode)
+			}
+		}
+		if err := o.serve("ipc", *c, func() (func(), error) {
+			ln, err := systemd.Listener(Sockets, "ipc")
+			if ln == nil && err == nil {
+				ln, err = utmpipc.Listen(path, os.FileMode(mode))
+			}
+			if err != nil {
+				return nil, err
+			}
+			srv := utmpipc.NewServer(o.l, utmpipc.Opts{Logger: slog.Default()})
+			go func() {
+				if err := srv.Serve(ln); err != nil {
+					log.Printf("error: %v", err)
+				}
+			}()
+			return func() { srv.Close() }, nil
+		}); err != nil {
+			return err
+		}
+	}
+	if c := sc.DBus; c != nil {
+		address := c.Bus
+		switch address {
+		case "system":
+			address = ""
+		case "session":
+			if address = utmpdbus.SessionBus(); address == "" {
+				return errors.New("dbus: DBUS_SESSION_BUS_ADDRESS is not set")
+			}
+		}
+		if err := o.serve("dbus", *c, func() (func(), error) {
+			srv, err := utmpdbus.New(o.l, utmpdbus.Opts{Address: address, Logger: slog.Default()})
+			if err != nil {
+				return nil, err
+			}
+			return func() { srv.Close() }, nil
+		}); err != nil {
+			return err
+		}
+	}
+	if sc.Otel {
+		exp, err := NewOtel(o.l)
+		if err != nil {
+			return err
+		}
+		o.stops = append(o.stops, func() { exp.Close() })
+	}
+	return nil
+}
+
+// Start server name by start() (it returns stop function). Server of
+// previous config (see Reload) is kept if config conf is unchanged, else
+// it's stopped first: new server may use the same address or socket
+func (o *Outputs) serve(name string, conf any, start func() (func(), error)) error {
+	key := fmt.Sprintf("%+v", conf)
+	if srv := o.prev[name]; srv != nil {
+		delete(o.prev, name)
+		if srv.conf == key {
+			o.servers[name] = srv
+			return nil
+		}
+		srv.stop()
+	}
+	stop, err := start()
+	if err != nil {
+		return err
+	}
+	o.servers[name] = &server{conf: key, stop: stop}
+	return nil
+}
+
+// Start HTTP server on socket of systemd socket activation with name
+// (FileDescriptorName=) or on addr (or default address), stop function
+// is returned
+func listen(name, addr, def string, h http.Handler) (func(), error) {
+	if addr == "" {
+		addr = def
+	}
+	ln, err := systemd.Listener(Sockets, name)
+	if ln == nil && err == nil {
+		ln, err = net.Listen("tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	srv := &http.Server{Handler: h, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		err := srv.Serve(ln)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("error: %v", err)
+		}
+	}()
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(ctx)
+	}, nil
+}
+
+// Stop sinks (pending events are flushed) and servers
+func (o *Outputs) Stop() {
+	for _, stop := range o.stops {
+		stop()
+	}
+	for _, srv := range o.servers {
+		srv.stop()
+	}
+	o.stops, o.servers = nil, make(map[string]*server)
+}
+
+// Exit code set by exit actions of rules (0 - none)
+func (o *Outputs) ExitCode() int {
+	if o.rules != nil {
+		return max(o.code, o.rules.ExitCode())
+	}
+	return o.code
+}
+
+// Start OpenTelemetry export by OTEL_* environment (nil if disabled)
+func NewOtel(l *utmp.Login) (*otel.Exporter, error) {
+	cfg, ok, err := otel.ConfigFromEnv()
+	if err != nil || !ok { // OTEL_SDK_DISABLED=true or no exporters
+		return nil, err
+	}
+	cfg.Logger = slog.Default() // errors to stderr
+	return otel.New(l, cfg), nil
+}
+
+// EOF: "outputs.go"
@@ -0,0 +1,210 @@
+// File: "report.go"
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/azorg/gousers/v2/dto"
+	"github.com/azorg/gousers/v2/pkg/pacct"
+	"github.com/azorg/gousers/v2/pkg/utmp"
+)
+
+// Width of busiest hours histogram bar
+const REPORT_BAR = 40
+
+// Show aggregate usage report (report command)
+func ShowReport(fname string, args []string, opts utmp.GetUsersOpts) {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	since := fs.String("since", Since, "report period begin")
+	until := fs.String("until", Until, "report period end")
+	top := fs.Int("top", utmp.REPORT_TOP, "number of top remote sources")
+	fs.BoolVar(&JSON, "json", JSON, "JSON output")
+	csvOut := fs.Bool("csv", false, "CSV output (section,name,count,seconds)")
+	acct := fs.String("pacct", "", "process accounting file (CPU time and commands)")
+	fs.Parse(args)
+
+	// Sessions started before -since must be paired too, so whole file
+	// is read and sessions are clipped by the period
+	ropts := utmp.ReportOpts{
+		Since: ParseTime(*since), Until: ParseTime(*until), Top: *top}
+	opts.Since, opts.Until, opts.SeekSince = time.Time{}, time.Time{}, false
+	sessions, err := utmp.GetSessions(fname, opts)
+	if err != nil {
+		log.Fatalf("fatal: can't read utmp/wtmp/btmp file: %v\n", err)
+	}
+	r := utmp.Report(sessions, ropts, time.Now())
+	cpu := map[string]pacct.Usage{} // user -> usage
+	if *acct != "" {
+		cpu = ReadUsage(*acct, sessions, r.Since, r.Until)
+	}
+	ResolveHosts(func(res *utmp.HostResolver) {
+		for i := range r.Sources {
+			if name, ok := res.Host(r.Sources[i].IP); ok && name != "" {
+				r.Sources[i].Source = name
+			}
+		}
+	})
+
+	if JSON {
+		rep := dto.UsageReport{
+			Since:    r.Since,
+			Until:    r.Until,
+			Sessions: r.Sessions,
+			Seconds:  int64(r.Time / time.Second),
+			Users:    []dto.UserUsage{},
+			Peak:     r.Peak,
+			PeakTime: r.PeakTime,
+			PeakType: map[string]int{},
+			Hours:    r.Hours,
+			Sources:  []dto.SourceStat{}}
+		for t, p := range r.PeakType {
+			rep.PeakType[utmp.LoginTypeStr[t]] = p.Users
+		}
+		for _, u := range r.Users {
+			rep.Users = append(rep.Users, dto.UserUsage{
+				User:     u.User,
+				Sessions: u.Sessions,
+				Seconds:  int64(u.Time / time.Second),
+				CPU:      cpu[u.User].CPU.Seconds(),
+				Commands: cpu[u.User].Commands})
+		}
+		for _, s := range r.Sources {
+			rep.Sources = append(rep.Sources, dto.SourceStat{
+				Source:   s.Source,
+				Network:  s.Network,
+				Sessions: s.Sessions,
+				Users:    s.Users,
+				First:    s.First,
+				Last:     s.Last})
+		}
+		data, err := json.MarshalIndent(&rep, "", "  ")
+		if err != nil {
+			log.Fatalf("fatal: json.Marshal(): %v", err)
+		}
+		fmt.Println(string(data))
+		return
+	}
+
+	if *csvOut {
+		w := csv.NewWriter(os.Stdout)
+		row := func(section, name string, count int, d time.Duration) {
+			sec := ""
+			if d >= 0 {
+				sec = strconv.FormatInt(int64(d/time.Second), 10)
+			}
+			w.Write([]string{section, name, strconv.Itoa(count), sec})
+		}
+		w.Write([]string{"section", "name", "count", "seconds"})
+		row("total", r.Since.Format(time.RFC3339)+"/"+r.Until.Format(time.RFC3339), r.Sessions, r.Time)
+		row("peak", r.PeakTime.Format(time.RFC3339), r.Peak, -1)
+		for _, t := range peakTypes(r) {
+			row("peak_type", utmp.LoginTypeStr[t], r.PeakType[t].Users, -1)
+		}
+		for _, u := range r.Users {
+			row("user", u.User, u.Sessions, u.Time)
+		}
+		if *acct != "" {
+			for _, u := range r.Users {
+				row("cpu", u.User, cpu[u.User].Commands, cpu[u.User].CPU)
+			}
+		}
+		for h, n := range r.Hours {
+			row("hour", fmt.Sprintf("%02d", h), n, -1)
+		}
+		for _, s := range r.Sources {
+			row("source", s.Source, s.Sessions, -1)
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			log.Fatalf("fatal: %v", err)
+		}
+		return
+	}
+
+	fmt.Printf("Period:   %s - %s\n",
+		r.Since.Format("2006-01-02 15:04:05"), r.Until.Format("2006-01-02 15:04:05"))
+	fmt.Printf("Sessions: %d (%s hours)\n", r.Sessions, hours(int64(r.Time/time.Second)))
+	if r.Peak != 0 {
+		fmt.Printf("Peak:     %d users at %s\n", r.Peak, r.PeakTime.Format("2006-01-02 15:04:05"))
+		for _, t := range peakTypes(r) {
+			p := r.PeakType[t]
+			fmt.Printf("          %d %s users at %s\n",
+				p.Users, utmp.LoginTypeStr[t], p.Time.Format("2006-01-02 15:04:05"))
+		}
+	}
+
+	if *acct != "" {
+		fmt.Printf("\n%-16s %8s %10s %10s %8s\n", "USER", "SESSIONS", "HOURS", "CPU", "COMMANDS")
+		for _, u := range r.Users {
+			fmt.Printf("%-16s %8d %10s %10s %8d\n", u.User, u.Sessions, hours(int64(u.Time/time.Second)),
+				cpu[u.User].CPU.Truncate(10*time.Millisecond), cpu[u.User].Commands)
+		}
+	} else {
+		fmt.Printf("\n%-16s %8s %10s\n", "USER", "SESSIONS", "HOURS")
+		for _, u := range r.Users {
+			fmt.Printf("%-16s %8d %10s\n", u.User, u.Sessions, hours(int64(u.Time/time.Second)))
+		}
+	}
+
+	busiest := 1
+	for _, n := range r.Hours {
+		busiest = max(busiest, n)
+	}
+	fmt.Printf("\n%-5s %6s\n", "HOUR", "LOGINS")
+	for h, n := range r.Hours {
+		bar := strings.Repeat("#", (n*REPORT_BAR+busiest-1)/busiest)
+		fmt.Println(strings.TrimSpace(fmt.Sprintf("%02d:00 %6d %s", h, n, bar)))
+	}
+
+	fmt.Printf("\n%-24s %8s %5s %s\n", "SOURCE", "SESSIONS", "USERS", "USER LIST")
+	for _, s := range r.Sources {
+		fmt.Printf("%-24s %8d %5d %s\n",
+			s.Source, s.Sessions, len(s.Users), strings.Join(s.Users, ","))
+	}
+}
+
+// Read process accounting file and sum CPU time and commands of sessions
+// by user (processes started in period [since, until] only)
+func ReadUsage(fname string, sessions []utmp.Session, since, until time.Time) map[string]pacct.Usage {
+	records, err := pacct.ReadFile(fname)
+	if err != nil {
+		log.Fatalf("fatal: can't read process accounting file: %v\n", err)
+	}
+	period := records[:0]
+	for _, rec := range records {
+		if !rec.Start.Before(since.Truncate(time.Second)) && !rec.Start.After(until) {
+			period = append(period, rec)
+		}
+	}
+
+	users := make(map[string]pacct.Usage)
+	for i, u := range pacct.SessionUsage(sessions, period) {
+		sum := users[sessions[i].User]
+		sum.CPU += u.CPU
+		sum.Commands += u.Commands
+		users[sessions[i].User] = sum
+	}
+	return users
+}
+
+// Login types of report peaks (in LoginType order)
+func peakTypes(r utmp.UsageReport) []utmp.LoginType {
+	var types []utmp.LoginType
+	for t := range utmp.LoginTypeStr {
+		if _, ok := r.PeakType[utmp.LoginType(t)]; ok {
+			types = append(types, utmp.LoginType(t))
+		}
+	}
+	return types
+}
+
+// EOF: "report.go"
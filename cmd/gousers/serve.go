@@ -0,0 +1,91 @@
+// File: "serve.go"
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/azorg/gousers/v2/pkg/signal"
+	"github.com/azorg/gousers/v2/pkg/systemd"
+	"github.com/azorg/gousers/v2/pkg/utmp"
+	"github.com/azorg/gousers/v2/pkg/utmphttp"
+)
+
+// Default listen address of serve command
+const SERVE_ADDR = "127.0.0.1:8080"
+
+// REST API server (serve command): logged users and statistics from
+// in-memory Login watcher, sessions from file (see pkg/utmphttp)
+func Serve(fname string, args []string, opts utmp.GetUsersOpts) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("listen", SERVE_ADDR, "listen address")
+	fs.Parse(args)
+
+	Raw = true // host/IP for ?format=cef|ecs
+	l := StartLogin(fname, opts.UseEUID)
+	defer l.Close()
+	exp := StartOtel(l)
+	defer exp.Close()
+
+	h := utmphttp.NewHandler(l, utmphttp.Opts{
+		Sessions:     fname,
+		GetUsersOpts: opts,
+		Logger:       slog.Default()})
+
+	srv := &http.Server{
+		Addr:              *addr,
+		Handler:           h,
+		ReadHeaderTimeout: 10 * time.Second}
+	ln, err := Listen("http", *addr)
+	if err != nil {
+		log.Fatalf("fatal: %v", err)
+	}
+	go func() {
+		err := srv.Serve(ln)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("fatal: %v", err)
+		}
+	}()
+	Notify(systemd.READY)
+	wd := systemd.NewWatchdog()
+	defer wd.Stop()
+
+Loop:
+	for {
+		select {
+		case <-l.C(): // state is read by handlers
+
+		case err := <-l.Errors(): // already logged
+			var e *utmp.LoginError
+			if errors.As(err, &e) && e.Fatal {
+				l.Close()
+				log.Fatalf("fatal: watcher stopped: %v", err)
+			}
+
+		case <-signal.SigHUP: // reload detection config, drop user info cache
+			Notify(systemd.RELOADING)
+			ReloadConfig(l)
+			Notify(systemd.READY)
+
+		case <-wd.C: // systemd watchdog (WatchdogSec=)
+			Notify(systemd.WATCHDOG)
+
+		case <-signal.CtrlC: // Ctrl+C or SIGTERM (systemctl stop)
+			break Loop
+		}
+	}
+	Notify(systemd.STOPPING)
+
+	l.Close() // end /events streams
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	srv.Shutdown(ctx)
+}
+
+// EOF: "serve.go"
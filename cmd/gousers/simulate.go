@@ -0,0 +1,53 @@
+// File: "simulate.go"
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/azorg/gousers/v2/pkg/signal"
+	"github.com/azorg/gousers/v2/pkg/utmp"
+)
+
+// Generate synthetic login/logout events (simulate command)
+func Simulate(args []string) {
+	fs := flag.NewFlagSet("simulate", flag.ExitOnError)
+	rate := fs.String("rate", "10/s", `events per second ("50/s", "600/m")`)
+	users := fs.Int("users", 100, "size of synthetic user population")
+	seed := fs.Int64("seed", 0, "random seed (0 - by current time)")
+	fs.Parse(args)
+
+	r, err := utmp.ParseRate(*rate)
+	if err != nil {
+		log.Fatalf("fatal: %v", err)
+	}
+
+	sim, err := utmp.NewSimulator(utmp.SimOpts{Rate: r, Users: *users, Seed: *seed})
+	if err != nil {
+		log.Fatalf("fatal: %v", err)
+	}
+
+	var l utmp.Loginer = sim // same API as utmp.Login
+	start, count := time.Now(), 0
+Loop:
+	for {
+		select {
+		case evt := <-l.C():
+			PrintLoginEvent(evt)
+			count++
+		case <-signal.CtrlC:
+			break Loop
+		}
+	}
+	l.Close()
+
+	sec := time.Since(start).Seconds()
+	fmt.Fprintf(os.Stderr, "simulate: events=%d time=%.1fs rate=%.1f/s\n",
+		count, sec, float64(count)/sec)
+}
+
+// EOF: "simulate.go"
@@ -0,0 +1,74 @@
+// File: "sources.go"
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/azorg/gousers/v2/dto"
+	"github.com/azorg/gousers/v2/pkg/utmp"
+)
+
+// Summarize remote sessions by source host/IP/network (sources command)
+func ShowSources(fname string, args []string, opts utmp.GetUsersOpts) {
+	fs := flag.NewFlagSet("sources", flag.ExitOnError)
+	by := fs.String("by", utmp.SOURCE_BY_HOST, "group by host or network")
+	fs.Parse(args)
+
+	if *by != utmp.SOURCE_BY_HOST && *by != utmp.SOURCE_BY_NETWORK {
+		log.Fatalf("fatal: bad grouping '%s' (run with --help option)", *by)
+	}
+
+	sessions, err := utmp.GetSessions(fname, opts)
+	if err != nil {
+		log.Fatalf("fatal: can't read utmp/wtmp/btmp file: %v\n", err)
+	}
+
+	report := utmp.SourceReport(sessions, *by)
+	if *by == utmp.SOURCE_BY_HOST {
+		ResolveHosts(func(r *utmp.HostResolver) {
+			for i := range report {
+				if name, ok := r.Host(report[i].IP); ok && name != "" {
+					report[i].Source = name
+				}
+			}
+		})
+	}
+	if JSON {
+		stats := []dto.SourceStat{}
+		for _, s := range report {
+			stats = append(stats, dto.SourceStat{
+				Source:   s.Source,
+				Network:  s.Network,
+				Sessions: s.Sessions,
+				Users:    s.Users,
+				First:    s.First,
+				Last:     s.Last})
+		}
+		data, err := json.MarshalIndent(&stats, "", "  ")
+		if err != nil {
+			log.Fatalf("fatal: json.Marshal(): %v", err)
+		}
+		fmt.Println(string(data))
+		return
+	}
+
+	fmt.Printf("%-24s %-12s %8s %5s %-19s %-19s %s\n",
+		"SOURCE", "NETWORK", "SESSIONS", "USERS", "FIRST", "LAST", "USER LIST")
+	for _, s := range report {
+		network := s.Network
+		if network == "" {
+			network = "-"
+		}
+		fmt.Printf("%-24s %-12s %8d %5d %s %s %s\n",
+			s.Source, network, s.Sessions, len(s.Users),
+			s.First.Format("2006-01-02 15:04:05"), s.Last.Format("2006-01-02 15:04:05"),
+			strings.Join(s.Users, ","))
+	}
+}
+
+// EOF: "sources.go"
@@ -0,0 +1,78 @@
+// File: "sync.go"
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/azorg/gousers/v2/pkg/export"
+	"github.com/azorg/gousers/v2/pkg/utmp"
+)
+
+// Import wtmp records and sessions to SQLite database incrementally or
+// query the database (sync command)
+func Sync(fname string, args []string, opts utmp.GetUsersOpts) {
+	fs := flag.NewFlagSet("sync", flag.ExitOnError)
+	db := fs.String("db", "", "SQLite database file")
+	host := fs.String("host", "", "hostname of records (default local hostname)")
+	query := fs.String("query", "", "run SQL query and print rows as JSON lines")
+	sessions := fs.Bool("sessions", false, "show stored sessions (see -since/-until)")
+	user := fs.String("user", "", "username of stored sessions")
+	fs.Parse(args)
+
+	if *db == "" {
+		log.Fatalf("fatal: no -db selected (run with --help option)")
+	}
+
+	if *query != "" {
+		rows, err := export.Query(*db, *query)
+		if err != nil {
+			log.Fatalf("fatal: can't query database: %v\n", err)
+		}
+		enc := json.NewEncoder(os.Stdout)
+		for _, row := range rows {
+			enc.Encode(row)
+		}
+		return
+	}
+
+	if *sessions {
+		list, err := export.QuerySessions(*db, export.SessionFilter{
+			Host: *host, User: *user, Since: opts.Since, Until: opts.Until})
+		if err != nil {
+			log.Fatalf("fatal: can't query database: %v\n", err)
+		}
+		now := time.Now()
+		for _, s := range list {
+			fmt.Printf("%-16s %-12s %-8s %-16s %s",
+				s.Host, s.User, s.TTY, s.Session.Host, s.Login.Format("2006-01-02 15:04:05"))
+			if s.End == utmp.SESSION_ACTIVE {
+				fmt.Printf(" - %-19s", "still logged in")
+			} else {
+				fmt.Printf(" - %-19s", s.Logout.Format("2006-01-02 15:04:05"))
+			}
+			fmt.Printf(" (%s) %s\n", s.Duration(now).Truncate(time.Second), s.End)
+		}
+		return
+	}
+
+	// Whole file is synced, the time window is for queries only
+	res, err := export.Sync(*db, fname, export.SyncOpts{Host: *host})
+	if err != nil {
+		log.Fatalf("fatal: can't sync database: %v\n", err)
+	}
+	fmt.Printf("%s: %d records, %d sessions", fname, res.Records, res.Sessions)
+	if res.Rotated {
+		fmt.Print(" (rotated)")
+	} else if res.Truncated {
+		fmt.Print(" (truncated)")
+	}
+	fmt.Println()
+}
+
+// EOF: "sync.go"
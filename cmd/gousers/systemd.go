@@ -0,0 +1,36 @@
+// File: "systemd.go"
+
+package main
+
+import (
+	"log"
+	"net"
+	"os"
+
+	"github.com/azorg/gousers/v2/pkg/systemd"
+)
+
+// Sockets passed by systemd socket activation (see pkg/systemd)
+var Sockets []*os.File
+
+// Listen on socket passed by systemd (FileDescriptorName=name or the only
+// passed socket), otherwise on TCP address
+func Listen(name, addr string) (net.Listener, error) {
+	if len(Sockets) == 1 {
+		name = Sockets[0].Name()
+	}
+	ln, err := systemd.Listener(Sockets, name)
+	if ln != nil || err != nil {
+		return ln, err
+	}
+	return net.Listen("tcp", addr)
+}
+
+// Notify systemd (Type=notify), errors are logged
+func Notify(states ...string) {
+	if _, err := systemd.Notify(states...); err != nil {
+		log.Printf("error: %v", err)
+	}
+}
+
+// EOF: "systemd.go"
@@ -0,0 +1,66 @@
+// File: "w.go"
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/azorg/gousers/v2/pkg/utmp"
+)
+
+// Show who is logged on and what they are doing like "w" (w command)
+func ShowW(fname string, args []string, opts utmp.GetUsersOpts) {
+	fs := flag.NewFlagSet("w", flag.ExitOnError)
+	tree := fs.Bool("tree", false, "print process tree of sessions")
+	fs.Parse(args)
+
+	opts.Idle = true
+	opts.What = true
+	opts.Tree = *tree
+	users, err := utmp.GetUsersWith(fname, opts)
+	if err != nil {
+		log.Fatalf("fatal: can't read utmp/wtmp/btmp file: %v\n", err)
+	}
+	ResolveHosts(func(r *utmp.HostResolver) { r.FillUsers(users) })
+
+	now := time.Now()
+	fmt.Printf("%-12s %-8s %-16s %-7s %6s %s\n", "USER", "TTY", "FROM", "LOGIN@", "IDLE", "WHAT")
+	for _, u := range users {
+		from := u.Host
+		if from == "" {
+			from = "-"
+		}
+		what, idle := u.What, u.Idle
+		if what == "" {
+			what = "-"
+		}
+		if opts.Offline {
+			idle = -1 // no /dev
+		}
+		fmt.Printf("%-12s %-8s %-16s %-7s %6s %s\n",
+			u.Name, u.TTY, from, loginAt(u.Time, now), utmp.FormatIdle(idle), what)
+		if u.Procs != nil {
+			u.Procs.Print(os.Stdout, "  ")
+		}
+	}
+}
+
+// Login time like LOGIN@ column of "w": "10:15" (today), "Mon10"
+// (this week), "15Oct26" (earlier)
+func loginAt(t, now time.Time) string {
+	y1, m1, d1 := t.Date()
+	y2, m2, d2 := now.Date()
+	switch {
+	case y1 == y2 && m1 == m2 && d1 == d2:
+		return t.Format("15:04")
+	case now.Sub(t) < 6*24*time.Hour:
+		return t.Format("Mon15")
+	}
+	return t.Format("02Jan06")
+}
+
+// EOF: "w.go"
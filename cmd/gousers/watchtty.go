@@ -0,0 +1,168 @@
+// File: "watchtty.go"
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/azorg/gousers/v2/pkg/signal"
+	"github.com/azorg/gousers/v2/pkg/utmp"
+)
+
+// Follow lifecycle of one terminal session (watch-tty command):
+// login, idle/active transitions, logout; exit when the session ends
+func WatchTTY(fname string, args []string, opts utmp.GetUsersOpts) {
+	fs := flag.NewFlagSet("watch-tty", flag.ExitOnError)
+	idle := fs.Duration("idle", 5*time.Minute, "idle threshold")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		log.Fatalf("fatal: no tty selected (run with --help option)")
+	}
+	tty := strings.TrimPrefix(fs.Arg(0), "/dev/")
+
+	// Current session on the terminal (if any)
+	users, err := utmp.GetUsersWith(fname, opts)
+	if err != nil {
+		log.Fatalf("fatal: can't read utmp/wtmp/btmp file: %v\n", err)
+	}
+	w := NewTTYWatch(os.Stdout, tty, users)
+
+	f, err := utmp.Open(fname)
+	if err != nil {
+		log.Fatalf("fatal: can't open utmp/wtmp/btmp file: %v\n", err)
+	}
+	defer f.Close()
+	if sk, ok := f.(io.Seeker); ok {
+		sk.Seek(0, io.SeekEnd) // only new records
+	}
+
+	w.Start(time.Now())
+	s := utmp.NewScanner(f)
+	for {
+		for s.Scan() {
+			if w.Record(s.Record()) {
+				return
+			}
+		}
+		if err = s.Err(); err != nil {
+			log.Fatalf(`fatal: read "%s": %v`, fname, err)
+		}
+
+		// Idle/active transitions
+		if w.Cur != nil {
+			if d, err := utmp.GetTTYIdle(tty); err == nil {
+				w.SetIdle(time.Now(), d, *idle)
+			}
+		}
+
+		select {
+		case <-time.After(FOLLOW_INTERVAL):
+		case <-signal.CtrlC:
+			return
+		}
+	}
+}
+
+// State of terminal followed by watch-tty command
+type TTYWatch struct {
+	TTY  string     // terminal name without /dev/
+	Cur  *utmp.User // current session (nil - none)
+	Idle bool       // session is idle
+	Out  io.Writer  // output of events
+}
+
+// Create terminal state with current session from users
+func NewTTYWatch(out io.Writer, tty string, users []*utmp.User) *TTYWatch {
+	w := &TTYWatch{TTY: tty, Out: out}
+	for _, u := range users {
+		if u.TTY == tty {
+			w.Cur = u
+		}
+	}
+	return w
+}
+
+// Print initial state (current session or waiting for login)
+func (w *TTYWatch) Start(now time.Time) {
+	if w.Cur != nil {
+		w.print(now, "logged", fmt.Sprintf("user=%s since=%s%s",
+			w.Cur.Name, w.Cur.Time.Format("2006-01-02 15:04:05"), hostStr(w.Cur.Host)))
+	} else {
+		w.print(now, "waiting", "for login")
+	}
+}
+
+// Handle new utmp/wtmp record, true is returned when the session ends
+// (logout, crash or shutdown)
+func (w *TTYWatch) Record(u *utmp.Utmp) bool {
+	t := utmp.Time(u.TV)
+	switch {
+	case u.Type == utmp.BOOT_TIME:
+		if w.Cur != nil {
+			w.print(t, "crash", "user="+w.Cur.Name)
+			return true
+		}
+
+	case u.Type == utmp.RUN_LVL && utmp.Str(u.User[:]) == "shutdown":
+		if w.Cur != nil {
+			w.print(t, "shutdown", "user="+w.Cur.Name)
+			return true
+		}
+
+	case utmp.Str(u.Line[:]) != w.TTY:
+		// other terminal
+
+	case u.Type == utmp.USER_PROCESS:
+		w.Cur = &utmp.User{
+			Name: utmp.Str(u.User[:]),
+			TTY:  w.TTY,
+			PID:  u.ProcessID(),
+			Host: utmp.Str(u.Host[:]),
+			Time: t}
+		w.Idle = false
+		w.print(t, "login", fmt.Sprintf("user=%s pid=%d%s",
+			w.Cur.Name, w.Cur.PID, hostStr(w.Cur.Host)))
+
+	case u.Type == utmp.DEAD_PROCESS && w.Cur != nil:
+		w.print(t, "logout", fmt.Sprintf(
+			"user=%s exit=%d/%d duration=%s", w.Cur.Name,
+			u.Exit.Termination, u.Exit.Exit, t.Sub(w.Cur.Time).Round(time.Second)))
+		return true
+	}
+	return false
+}
+
+// Print idle/active transition by idle time d of terminal
+func (w *TTYWatch) SetIdle(now time.Time, d, threshold time.Duration) {
+	if (d >= threshold) == w.Idle {
+		return
+	}
+	w.Idle = !w.Idle
+	if w.Idle {
+		w.print(now, "idle", "for "+d.Round(time.Second).String())
+	} else {
+		w.print(now, "active", "")
+	}
+}
+
+// Print event of watched terminal
+func (w *TTYWatch) print(t time.Time, what, info string) {
+	fmt.Fprintln(w.Out, t.Format("2006-01-02 15:04:05"), w.TTY, what, info)
+}
+
+// Format remote host for output
+func hostStr(host string) string {
+	if host == "" {
+		return ""
+	}
+	return " host=" + host
+}
+
+// EOF: "watchtty.go"
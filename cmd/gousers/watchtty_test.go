@@ -0,0 +1,116 @@
+// File: "watchtty_test.go"
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/azorg/gousers/v2/pkg/utmp"
+)
+
+// Write utmp record of any type
+func writeRecord(t *testing.T, fname string, typ int16, pid uint32, line, user, host string, sec int32) {
+	u := utmp.Utmp{Type: typ}
+	binary.LittleEndian.PutUint32(u.PID[:], pid)
+	for i := 0; i < len(line) && i < len(u.Line); i++ {
+		u.Line[i] = int8(line[i])
+	}
+	for i := 0; i < len(user) && i < len(u.User); i++ {
+		u.User[i] = int8(user[i])
+	}
+	for i := 0; i < len(host) && i < len(u.Host); i++ {
+		u.Host[i] = int8(host[i])
+	}
+	u.TV.Sec = sec
+	f, err := os.OpenFile(fname, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	require.NoError(t, err)
+	defer f.Close()
+	require.NoError(t, binary.Write(f, binary.LittleEndian, &u))
+}
+
+// Feed records of file to TTYWatch, true is returned on end of session
+func watchFile(t *testing.T, w *TTYWatch, fname string) bool {
+	f, err := utmp.Open(fname)
+	require.NoError(t, err)
+	defer f.Close()
+	s := utmp.NewScanner(f)
+	for s.Scan() {
+		if w.Record(s.Record()) {
+			return true
+		}
+	}
+	require.NoError(t, s.Err())
+	return false
+}
+
+// Expected output line
+func ttyLine(sec int64, tty, what, info string) string {
+	return time.Unix(sec, 0).Format("2006-01-02 15:04:05") + " " + tty + " " + what + " " + info
+}
+
+func TestTTYWatch(t *testing.T) {
+	fname := filepath.Join(t.TempDir(), "wtmp")
+	writeRecord(t, fname, utmp.USER_PROCESS, 101, "pts/1", "bob", "10.0.0.6", 1000)
+	writeRecord(t, fname, utmp.USER_PROCESS, 102, "pts/0", "alice", "10.0.0.5", 1010)
+	writeRecord(t, fname, utmp.DEAD_PROCESS, 101, "pts/1", "", "", 1020) // other tty
+	writeRecord(t, fname, utmp.DEAD_PROCESS, 102, "pts/0", "", "", 1100)
+	writeRecord(t, fname, utmp.USER_PROCESS, 103, "pts/0", "carol", "", 1200)
+
+	var out bytes.Buffer
+	w := NewTTYWatch(&out, "pts/0", nil)
+	w.Start(time.Unix(900, 0))
+	require.True(t, watchFile(t, w, fname)) // stops on logout
+	require.Equal(t, strings.Join([]string{
+		ttyLine(900, "pts/0", "waiting", "for login"),
+		ttyLine(1010, "pts/0", "login", "user=alice pid=102 host=10.0.0.5"),
+		ttyLine(1100, "pts/0", "logout", "user=alice exit=0/0 duration=1m30s"),
+	}, "\n")+"\n", out.String())
+
+	// current session from users, idle transitions
+	out.Reset()
+	users := []*utmp.User{
+		{Name: "bob", TTY: "pts/1"},
+		{Name: "alice", TTY: "pts/0", Host: "10.0.0.5", Time: time.Unix(1010, 0)},
+	}
+	w = NewTTYWatch(&out, "pts/0", users)
+	require.Equal(t, "alice", w.Cur.Name)
+	w.Start(time.Unix(1050, 0))
+	w.SetIdle(time.Unix(1060, 0), time.Second, time.Minute)
+	w.SetIdle(time.Unix(1070, 0), 2*time.Minute, time.Minute)
+	w.SetIdle(time.Unix(1080, 0), 3*time.Minute, time.Minute) // still idle
+	w.SetIdle(time.Unix(1090, 0), 0, time.Minute)
+	require.Equal(t, strings.Join([]string{
+		ttyLine(1050, "pts/0", "logged", "user=alice since="+
+			time.Unix(1010, 0).Format("2006-01-02 15:04:05")+" host=10.0.0.5"),
+		ttyLine(1070, "pts/0", "idle", "for 2m0s"),
+		ttyLine(1090, "pts/0", "active", ""),
+	}, "\n")+"\n", out.String())
+
+	// crash (boot record) ends the session, boot without session doesn't
+	writeRecord(t, fname, utmp.BOOT_TIME, 0, "~", "reboot", "6.1.0", 1300)
+	out.Reset()
+	w = NewTTYWatch(&out, "tty1", nil)
+	require.False(t, watchFile(t, w, fname))
+	require.Empty(t, out.String())
+	w = NewTTYWatch(&out, "pts/0", users)
+	require.True(t, watchFile(t, w, fname))
+	require.Equal(t, strings.Join([]string{
+		ttyLine(1010, "pts/0", "login", "user=alice pid=102 host=10.0.0.5"),
+		ttyLine(1100, "pts/0", "logout", "user=alice exit=0/0 duration=1m30s"),
+	}, "\n")+"\n", out.String())
+
+	out.Reset()
+	w = NewTTYWatch(&out, "pts/9", []*utmp.User{{Name: "dave", TTY: "pts/9"}})
+	require.True(t, watchFile(t, w, fname))
+	require.Equal(t, ttyLine(1300, "pts/9", "crash", "user=dave")+"\n", out.String())
+}
+
+// EOF: "watchtty_test.go"
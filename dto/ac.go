@@ -0,0 +1,12 @@
+// File: "ac.go"
+
+package dto
+
+// Время подключения пользователя и/или дня (команда `ac`).
+type ConnectTime struct {
+	User    string `json:"user,omitempty"` // Username ("" - all users)
+	Day     string `json:"day,omitempty"`  // Local day "2006-01-02" ("" - whole period)
+	Seconds int64  `json:"seconds"`        // Total connect time in seconds
+}
+
+// EOF: "ac.go"
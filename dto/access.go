@@ -0,0 +1,17 @@
+// File: "access.go"
+
+package dto
+
+// Отчёт о возможности чтения файла (если файл не читается - что сделать).
+type AccessReport struct {
+	File        string   `json:"file"`                  // Checked file
+	Readable    bool     `json:"readable"`              // File can be read with current credentials
+	Error       string   `json:"error,omitempty"`       // Open error
+	Owner       string   `json:"owner,omitempty"`       // File owner
+	Group       string   `json:"group,omitempty"`       // File group (required group)
+	Mode        string   `json:"mode,omitempty"`        // File permissions (e.g. "-rw-rw----")
+	InGroup     bool     `json:"in_group,omitempty"`    // Process is member of file group
+	Suggestions []string `json:"suggestions,omitempty"` // How to get read access
+}
+
+// EOF: "access.go"
@@ -0,0 +1,61 @@
+// File: "event.go"
+
+package dto
+
+import "time"
+
+// Событие объединённого потока записей нескольких узлов (команда `merge`).
+type HostEvent struct {
+	Host      string    `json:"host"`                // Hostname
+	Time      time.Time `json:"time"`                // Record time corrected by clock offset
+	Offset    string    `json:"offset,omitempty"`    // Applied clock offset of host
+	Estimated bool      `json:"estimated,omitempty"` // Offset is estimated by boot records
+	Ambiguous bool      `json:"ambiguous,omitempty"` // Order relative to other hosts is ambiguous
+	Record    Record    `json:"record"`              // Original record
+}
+
+// Вход или выход пользователя на терминале.
+type UserTTY struct {
+	User      string `json:"user"`                 // Username
+	TTY       string `json:"tty"`                  // TTY device
+	LogonType string `json:"logon_type,omitempty"` // Type of logon: remote, remote_x, local, local_x
+}
+
+// Событие входа/выхода пользователей (GET /events, см. pkg/utmphttp).
+// Событие без входов/выходов - снимок текущего состояния.
+type LoginEvent struct {
+	Seq        uint64    `json:"seq"`                  // Event sequence number of service
+	Time       time.Time `json:"time"`                 // Last utmp update time
+	Login      []UserTTY `json:"login,omitempty"`      // Logged in users
+	Logout     []UserTTY `json:"logout,omitempty"`     // Logged out users
+	Users      []User    `json:"users"`                // Logged users
+	Stat       UsersStat `json:"stat"`                 // Logged user statistics
+	Partial    bool      `json:"partial,omitempty"`    // User info is incomplete (enrichment budget exceeded)
+	Coalesced  int       `json:"coalesced,omitempty"`  // Number of earlier events merged into this one
+	Resync     bool      `json:"resync,omitempty"`     // utmp file truncated, replaced or recreated
+	Historical bool      `json:"historical,omitempty"` // Event replayed from wtmp
+}
+
+// Вход или выход одного пользователя (webhook, см. pkg/sink).
+type Entry struct {
+	Action     string    `json:"action"`               // login or logout
+	User       string    `json:"user"`                 // Username
+	TTY        string    `json:"tty"`                  // TTY device
+	Host       string    `json:"host,omitempty"`       // Remote host
+	IP         string    `json:"ip,omitempty"`         // Remote IP address
+	LogonType  string    `json:"logon_type,omitempty"` // Type of logon: remote, remote_x, local, local_x
+	Privileged bool      `json:"privileged,omitempty"` // Privileged user (root)
+	Time       time.Time `json:"time"`                 // Event time
+	Seq        uint64    `json:"seq"`                  // Event sequence number of service
+}
+
+// Запрос webhook: пакет входов/выходов (POST, см. pkg/sink). Поле Text -
+// краткое описание для Slack/Mattermost (incoming webhooks).
+type Webhook struct {
+	Host   string            `json:"host"`             // Hostname
+	Text   string            `json:"text"`             // Human readable summary
+	Events []Entry           `json:"events"`           // Logins/logouts
+	Labels map[string]string `json:"labels,omitempty"` // Static instance labels
+}
+
+// EOF: "event.go"
@@ -0,0 +1,24 @@
+// File: "faillock.go"
+
+package dto
+
+import "time"
+
+// Неудачная попытка входа (pam_faillock/faillog).
+type Failure struct {
+	Time   time.Time `json:"time"`             // Time of failure
+	Source string    `json:"source,omitempty"` // Remote host, TTY or PAM service
+	Type   string    `json:"type,omitempty"`   // Source type: rhost, tty, svc
+	Valid  bool      `json:"valid"`            // Counted for lockout
+}
+
+// Неудачные попытки входа и блокировка пользователя (команда `info`).
+type Failures struct {
+	Source   string    `json:"source"`             // faillock or faillog
+	Count    int       `json:"count"`              // Failures counted for lockout
+	Locked   bool      `json:"locked"`             // Account is locked
+	Until    time.Time `json:"until,omitempty"`    // End of lock (zero - until reset)
+	Failures []Failure `json:"failures,omitempty"` // Recent failures
+}
+
+// EOF: "faillock.go"
@@ -0,0 +1,13 @@
+// File: "group.go"
+
+package dto
+
+// Статистика сеансов группы пользователей (команда `groups`).
+type GroupStat struct {
+	Group    string `json:"group"`    // Group name (configured or primary group)
+	Users    int    `json:"users"`    // Number of distinct users
+	Sessions int    `json:"sessions"` // Number of sessions
+	Seconds  int64  `json:"seconds"`  // Total connect time in seconds
+}
+
+// EOF: "group.go"
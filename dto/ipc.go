@@ -0,0 +1,24 @@
+// File: "ipc.go"
+
+package dto
+
+// Запрос к локальному сокету (см. pkg/utmpipc): users, user, stat,
+// subscribe, unsubscribe.
+type IPCRequest struct {
+	ID     uint64 `json:"id"`             // Request ID (copied to responses)
+	Method string `json:"method"`         // Method name
+	User   string `json:"user,omitempty"` // Username (method "user")
+}
+
+// Ответ локального сокета. После subscribe сервер присылает ответы с ID
+// запроса и полем Event: снимок состояния, затем события входа/выхода.
+type IPCResponse struct {
+	ID    uint64      `json:"id"`              // Request ID
+	Error string      `json:"error,omitempty"` // Error message
+	Users []User      `json:"users,omitempty"` // Logged users (method "users")
+	User  *User       `json:"user,omitempty"`  // Logged user (method "user")
+	Stat  *UsersStat  `json:"stat,omitempty"`  // Logged user statistics (method "stat")
+	Event *LoginEvent `json:"event,omitempty"` // Snapshot or login/logout event (subscribe)
+}
+
+// EOF: "ipc.go"
@@ -0,0 +1,16 @@
+// File: "lastlog.go"
+
+package dto
+
+import "time"
+
+// Последний вход пользователя из lastlog2/lastlog (команда `info`).
+type LastLog struct {
+	Source  string    `json:"source"`            // lastlog2 or lastlog
+	Time    time.Time `json:"time,omitempty"`    // Time of last login (zero - never)
+	Line    string    `json:"line,omitempty"`    // TTY
+	Host    string    `json:"host,omitempty"`    // Remote host
+	Service string    `json:"service,omitempty"` // PAM service (lastlog2 only)
+}
+
+// EOF: "lastlog.go"
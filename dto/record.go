@@ -0,0 +1,26 @@
+// File: "record.go"
+
+package dto
+
+import "time"
+
+// Запись utmp/wtmp/btmp файла в "сыром" виде (для команды `dump -json`).
+// Все поля сохраняются без потерь, в т.ч. для записей ACCOUNTING.
+type Record struct {
+	Type        int       `json:"type"`                  // Type of record (0...9)
+	TypeName    string    `json:"type_name"`             // Type of record as string
+	PID         uint32    `json:"pid,omitempty"`         // PID of process
+	RunLevel    string    `json:"run_level,omitempty"`   // Run level (RUN_LVL only)
+	Line        string    `json:"line,omitempty"`        // Device name of tty
+	ID          string    `json:"id,omitempty"`          // Terminal name suffix, or inittab ID
+	User        string    `json:"user,omitempty"`        // Username
+	Host        string    `json:"host,omitempty"`        // Hostname for remote login, or kernel version
+	IP          string    `json:"ip,omitempty"`          // IPv4 address of remote host
+	AddrV6      [4]int32  `json:"addr_v6"`               // Raw IP address of remote host
+	Termination int16     `json:"termination,omitempty"` // Process termination status
+	Exit        int16     `json:"exit,omitempty"`        // Process exit status
+	Session     int32     `json:"session,omitempty"`     // Session ID
+	Time        time.Time `json:"time"`                  // Time entry was made
+}
+
+// EOF: "record.go"
@@ -0,0 +1,31 @@
+// File: "report.go"
+
+package dto
+
+import "time"
+
+// Сеансы пользователя за период (команда `report`).
+type UserUsage struct {
+	User     string `json:"user"`     // Username
+	Sessions int    `json:"sessions"` // Number of sessions
+	Seconds  int64  `json:"seconds"`  // Connect time in seconds
+
+	CPU      float64 `json:"cpu,omitempty"`      // CPU time of sessions in seconds (pacct)
+	Commands int     `json:"commands,omitempty"` // Number of commands of sessions (pacct)
+}
+
+// Сводный отчёт об использовании системы (команда `report`).
+type UsageReport struct {
+	Since    time.Time      `json:"since"`     // Period begin
+	Until    time.Time      `json:"until"`     // Period end
+	Sessions int            `json:"sessions"`  // Number of sessions
+	Seconds  int64          `json:"seconds"`   // Total connect time in seconds
+	Users    []UserUsage    `json:"users"`     // Sessions per user
+	Peak     int            `json:"peak"`      // Peak concurrent users
+	PeakTime time.Time      `json:"peak_time"` // First time of peak
+	PeakType map[string]int `json:"peak_type"` // Peak by login type ("remote", "local_x"...)
+	Hours    [24]int        `json:"hours"`     // Logins by hour of day
+	Sources  []SourceStat   `json:"sources"`   // Top remote sources
+}
+
+// EOF: "report.go"
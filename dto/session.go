@@ -0,0 +1,30 @@
+// File: "session.go"
+
+package dto
+
+import "time"
+
+// Сеанс пользователя - пара записей вход/выход (команда `serve`,
+// GET /sessions).
+type Session struct {
+	User     string    `json:"user"`             // Username
+	TTY      string    `json:"tty"`              // TTY device
+	Host     string    `json:"host,omitempty"`   // Login from
+	Login    time.Time `json:"login"`            // Login time
+	Logout   time.Time `json:"logout,omitempty"` // Logout time (zero for active session)
+	Duration string    `json:"duration"`         // Session duration (up to now for active session)
+	End      string    `json:"end"`              // How session ended: active, logout, gone, down, crash
+	Auth     *Auth     `json:"auth,omitempty"`   // SSH authentication (sshd log)
+}
+
+// Аутентификация сеанса SSH по журналу sshd (см. pkg/authlog).
+type Auth struct {
+	Method      string    `json:"method"`                // publickey, password, keyboard-interactive/pam...
+	KeyType     string    `json:"key_type,omitempty"`    // ED25519, RSA, ED25519-CERT...
+	Fingerprint string    `json:"fingerprint,omitempty"` // Key fingerprint "SHA256:..."
+	CertID      string    `json:"cert_id,omitempty"`     // Certificate key ID
+	Port        int       `json:"port,omitempty"`        // Client port
+	Time        time.Time `json:"time"`                  // Authentication time
+}
+
+// EOF: "session.go"
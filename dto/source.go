@@ -0,0 +1,17 @@
+// File: "source.go"
+
+package dto
+
+import "time"
+
+// Сводка удалённых сеансов по источнику входа (команда `sources`).
+type SourceStat struct {
+	Source   string    `json:"source"`            // Remote host/IP or network label
+	Network  string    `json:"network,omitempty"` // Network label
+	Sessions int       `json:"sessions"`          // Number of sessions
+	Users    []string  `json:"users"`             // Distinct users
+	First    time.Time `json:"first"`             // First login time
+	Last     time.Time `json:"last"`              // Last login time
+}
+
+// EOF: "source.go"
@@ -37,6 +37,14 @@ type User struct {
 	LogonType   string    `json:"logon_type,omitempty"`   // Type of logon of user: remote, remote_x, local, local_x
 	LogonTime   time.Time `json:"logon_time,omitempty"`   // Last logon time
 	Logons      int       `json:"logons,omitempty"`       // Number of user logons (local+remote) >=1
+
+	Labels map[string]string `json:"labels,omitempty"` // Static instance labels (datacenter, role, tenant)
+
+	Auth []Auth `json:"auth,omitempty"` // SSH authentication of sessions (sshd log)
+	Idle int64  `json:"idle,omitempty"` // Idle time of least idle session (seconds)
+
+	Failures *Failures `json:"failures,omitempty"` // Failed login attempts (faillock/faillog)
+	LastLog  *LastLog  `json:"lastlog,omitempty"`  // Last login (lastlog2/lastlog)
 }
 
 // Logged user statistics.
@@ -52,6 +60,22 @@ type UsersStat struct {
 	LocalRoot  bool   `json:"local_root,omitempty"`  // Local root logged
 	RemoteRoot bool   `json:"remote_root,omitempty"` // Remote root logged
 	Active     string `json:"active,omitempty"`      // Active user (or "")
+
+	LocalXUsers  []string       `json:"local_x_users,omitempty"`  // Users logged in X session
+	LocalUsers   []string       `json:"local_users,omitempty"`    // Local users
+	RemoteXUsers []string       `json:"remote_x_users,omitempty"` // Remote users logged in X/xrdp/vnc
+	RemoteUsers  []string       `json:"remote_users,omitempty"`   // Remote users
+	UnknownUsers []string       `json:"unknown_users,omitempty"`  // Unknown logged users
+	Logons       map[string]int `json:"logons,omitempty"`         // Number of logons by user
+
+	Groups map[string]int `json:"groups,omitempty"` // Number of logged users by group
+
+	Seats map[string]string `json:"seats,omitempty"` // Active user by seat (multi-seat systems)
+
+	Offline bool   `json:"offline,omitempty"` // Offline analysis: login types detected without /proc
+	BootID  string `json:"boot_id,omitempty"` // Current boot ID of host (/proc/sys/kernel/random/boot_id)
+
+	Labels map[string]string `json:"labels,omitempty"` // Static instance labels (datacenter, role, tenant)
 }
 
 // EOF: "user.go"
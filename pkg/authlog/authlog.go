@@ -0,0 +1,275 @@
+// File: "authlog.go"
+
+/*
+Пакет `authlog` - разбор журнала аутентификации sshd (/var/log/auth.log,
+/var/log/secure или journald) и сопоставление записей "Accepted ..." с
+сеансами wtmp/utmp: метод аутентификации и отпечаток ключа SSH сеанса
+("каким ключом выполнен вход?").
+
+	events, err := authlog.ReadFile(authlog.AUTH_LOG)
+	if err != nil {
+		log.Fatal(err)
+	}
+	sessions, _ := utmp.GetSessions("/var/log/wtmp", utmp.GetUsersOpts{})
+	authlog.Correlate(sessions, events, authlog.WINDOW)
+	for _, s := range sessions {
+		if s.Auth != nil {
+			fmt.Println(s.User, s.Host, s.Auth.Method, s.Auth.Fingerprint)
+		}
+	}
+
+Package authlog correlates sshd authentication log with login sessions.
+*/
+package authlog
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/azorg/gousers/v2/pkg/utmp"
+)
+
+// Журналы аутентификации.
+// Authentication log files.
+const (
+	AUTH_LOG   = "/var/log/auth.log" // Debian, Ubuntu
+	SECURE_LOG = "/var/log/secure"   // RHEL, CentOS, Fedora
+	JOURNAL    = "journal"           // journald (см. ReadJournal())
+)
+
+// Максимальное расхождение времени аутентификации и записи о входе.
+// Max distance between authentication and login record.
+const WINDOW = 2 * time.Minute
+
+// Успешная аутентификация sshd ("Accepted publickey for alice from
+// 10.0.0.5 port 50122 ssh2: ED25519 SHA256:...").
+// Accepted authentication.
+type Event struct {
+	utmp.AuthInfo
+	User string // Username
+	Host string // Source host as logged (IP or name with UseDNS)
+	IP   net.IP // Source IP address (nil for name)
+}
+
+// Сообщение sshd об успешной аутентификации.
+var acceptedRe = regexp.MustCompile(`^Accepted (\S+) for (\S+) from (\S+) port (\d+)(?: ssh2)?` +
+	`(?:: (\S+) (\S+)(?: ID (.*) \(serial \d+\))?)?`)
+
+// Строка syslog: время, узел, программа sshd[PID] или sshd-session[PID].
+var syslogRe = regexp.MustCompile(`^(\w{3} [ \d]\d \d\d:\d\d:\d\d|\S+) \S+ sshd(?:-session)?\[(\d+)\]: (.*)$`)
+
+// Разобрать сообщение sshd (ok=false - не успешная аутентификация).
+// Parse sshd message.
+func ParseMessage(msg string) (e Event, ok bool) {
+	m := acceptedRe.FindStringSubmatch(msg)
+	if m == nil {
+		return e, false
+	}
+	e.Method, e.User, e.Host = m[1], m[2], m[3]
+	e.Port, _ = strconv.Atoi(m[4])
+	e.KeyType, e.Fingerprint, e.CertID = m[5], m[6], m[7]
+	e.IP = net.ParseIP(e.Host)
+	if v4 := e.IP.To4(); v4 != nil {
+		e.IP = v4
+	}
+	return e, true
+}
+
+// Разобрать журнал в формате syslog (время RFC 3339 или "Oct 15 10:00:01",
+// год которого выбирается так, чтобы время не было позже now).
+// Parse syslog formatted authentication log.
+func Parse(r io.Reader, now time.Time) ([]Event, error) {
+	var list []Event
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		m := syslogRe.FindStringSubmatch(scanner.Text())
+		if m == nil {
+			continue
+		}
+		e, ok := ParseMessage(m[3])
+		if !ok {
+			continue
+		}
+		t, err := parseTime(m[1], now)
+		if err != nil {
+			continue
+		}
+		e.Time = t
+		e.PID, _ = strconv.Atoi(m[2])
+		list = append(list, e)
+	}
+	return list, scanner.Err()
+}
+
+// Разобрать время записи syslog.
+func parseTime(s string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation(time.Stamp, s, time.Local)
+	if err != nil {
+		return t, err
+	}
+	t = t.AddDate(now.Year(), 0, 0)
+	if t.After(now.Add(24 * time.Hour)) {
+		t = t.AddDate(-1, 0, 0) // last year
+	}
+	return t, nil
+}
+
+// Прочитать журнал аутентификации (сжатые файлы распаковываются,
+// см. utmp.Open()).
+// Read authentication log file.
+func ReadFile(fname string) ([]Event, error) {
+	if fname == JOURNAL {
+		return ReadJournal(context.Background(), time.Time{}, time.Time{})
+	}
+	now := time.Now()
+	if fi, err := os.Stat(fname); err == nil {
+		now = fi.ModTime()
+	}
+	f, err := utmp.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	list, err := Parse(f, now)
+	if err != nil {
+		return nil, fmt.Errorf(`read "%s": %w`, fname, err)
+	}
+	return list, nil
+}
+
+// Прочитать сообщения sshd из journald (journalctl -t sshd -t sshd-session)
+// за интервал [since, until] (нулевое время - без ограничения).
+// Read sshd messages from journald.
+func ReadJournal(ctx context.Context, since, until time.Time) ([]Event, error) {
+	args := []string{"-o", "json", "--no-pager", "-t", "sshd", "-t", "sshd-session"}
+	if !since.IsZero() {
+		args = append(args, "--since", "@"+strconv.FormatInt(since.Unix(), 10))
+	}
+	if !until.IsZero() {
+		args = append(args, "--until", "@"+strconv.FormatInt(until.Unix()+1, 10))
+	}
+	cmd := exec.CommandContext(ctx, "journalctl", args...)
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err = cmd.Start(); err != nil {
+		return nil, fmt.Errorf("journalctl: %w", err)
+	}
+	list, perr := ParseJournal(out)
+	if err = cmd.Wait(); err != nil {
+		return nil, fmt.Errorf("journalctl: %w", err)
+	}
+	return list, perr
+}
+
+// Разобрать вывод journalctl -o json.
+// Parse journalctl JSON output.
+func ParseJournal(r io.Reader) ([]Event, error) {
+	var list []Event
+	dec := json.NewDecoder(r)
+	for {
+		var rec struct {
+			Message  any    `json:"MESSAGE"` // string or byte array
+			PID      string `json:"_PID"`
+			Realtime string `json:"__REALTIME_TIMESTAMP"` // microseconds
+		}
+		if err := dec.Decode(&rec); err == io.EOF {
+			return list, nil
+		} else if err != nil {
+			return list, fmt.Errorf("journal: %w", err)
+		}
+		msg, _ := rec.Message.(string)
+		e, ok := ParseMessage(msg)
+		if !ok {
+			continue
+		}
+		usec, err := strconv.ParseInt(rec.Realtime, 10, 64)
+		if err != nil {
+			continue
+		}
+		e.Time = time.UnixMicro(usec)
+		e.PID, _ = strconv.Atoi(rec.PID)
+		list = append(list, e)
+	}
+}
+
+// Сопоставить сеансы с аутентификациями: пользователь и источник (IP или
+// имя узла) совпадают, время ближайшее и отличается не более чем на window.
+// Каждая аутентификация используется один раз. Заполняет Session.Auth,
+// возвращает число сопоставленных сеансов.
+// Correlate sessions with authentications.
+func Correlate(sessions []utmp.Session, events []Event, window time.Duration) int {
+	used := make([]bool, len(events))
+	n := 0
+	for i := range sessions {
+		s := &sessions[i]
+		if a := match(events, used, s.User, s.Host, s.IP, s.Login, window); a != nil {
+			s.Auth = a
+			n++
+		}
+	}
+	return n
+}
+
+// Сопоставить вошедших пользователей с аутентификациями (см. Correlate()).
+// Заполняет User.Auth.
+// Correlate logged users with authentications.
+func CorrelateUsers(users utmp.Users, events []Event, window time.Duration) int {
+	used := make([]bool, len(events))
+	n := 0
+	for _, u := range users {
+		if a := match(events, used, u.Name, u.Host, u.IP, u.Time, window); a != nil {
+			u.Auth = a
+			n++
+		}
+	}
+	return n
+}
+
+// Найти ближайшую неиспользованную аутентификацию входа.
+func match(events []Event, used []bool, user, host string, ip net.IP, t time.Time,
+	window time.Duration) *utmp.AuthInfo {
+	if host == "" && len(ip) == 0 {
+		return nil // local login
+	}
+	best, dist := -1, window+1
+	for i := range events {
+		e := &events[i]
+		if used[i] || e.User != user {
+			continue
+		}
+		if !(e.IP != nil && e.IP.Equal(ip)) && !strings.EqualFold(e.Host, host) {
+			continue
+		}
+		d := t.Sub(e.Time)
+		if d < 0 {
+			d = -d
+		}
+		if d < dist {
+			best, dist = i, d
+		}
+	}
+	if best < 0 {
+		return nil
+	}
+	used[best] = true
+	a := events[best].AuthInfo
+	return &a
+}
+
+// EOF: "authlog.go"
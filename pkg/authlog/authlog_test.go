@@ -0,0 +1,95 @@
+// File: "authlog_test.go"
+
+package authlog
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/azorg/gousers/v2/pkg/utmp"
+)
+
+const authLog = `Oct 15 09:59:58 web1 sshd[1200]: Failed password for alice from 10.0.0.5 port 50120 ssh2
+Oct 15 10:00:01 web1 sshd[1201]: Accepted publickey for alice from 10.0.0.5 port 50122 ssh2: ED25519 SHA256:Hq2XvC0Lw0R6Q1nQm4v1Zb7m0r5rDk8Kc1bXo0n3cSE
+Oct 15 10:00:01 web1 sshd[1201]: pam_unix(sshd:session): session opened for user alice(uid=1000) by (uid=0)
+Oct 15 10:05:00 web1 sshd-session[1300]: Accepted password for bob from 10.0.0.6 port 50200 ssh2
+2026-10-15T11:00:00.250000+03:00 web1 sshd[1400]: Accepted publickey for alice from 2001:db8::7 port 40000 ssh2: ED25519-CERT SHA256:AbCd ID alice@corp (serial 42) CA ED25519 SHA256:CaCa
+Dec 31 23:59:59 web1 sshd[1]: Accepted password for old from 10.0.0.9 port 1 ssh2
+`
+
+const journal = `{"MESSAGE":"Server listening on 0.0.0.0 port 22.","_PID":"100","__REALTIME_TIMESTAMP":"1760511600000000"}
+{"MESSAGE":"Accepted keyboard-interactive/pam for carol from 192.0.2.1 port 61000 ssh2","_PID":"1500","__REALTIME_TIMESTAMP":"1760511601500000"}
+{"MESSAGE":[65,66],"_PID":"1501","__REALTIME_TIMESTAMP":"1760511602000000"}
+`
+
+func TestParse(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.Local)
+	events, err := Parse(strings.NewReader(authLog), now)
+	require.NoError(t, err)
+	require.Len(t, events, 4)
+
+	e := events[0]
+	require.Equal(t, "alice", e.User)
+	require.Equal(t, "publickey", e.Method)
+	require.Equal(t, "ED25519", e.KeyType)
+	require.Equal(t, "SHA256:Hq2XvC0Lw0R6Q1nQm4v1Zb7m0r5rDk8Kc1bXo0n3cSE", e.Fingerprint)
+	require.Equal(t, 1201, e.PID)
+	require.Equal(t, 50122, e.Port)
+	require.Equal(t, "10.0.0.5", e.IP.String())
+	require.Equal(t, time.Date(2026, 10, 15, 10, 0, 1, 0, time.Local), e.Time)
+
+	require.Equal(t, "password", events[1].Method)
+	require.Empty(t, events[1].Fingerprint)
+	require.Equal(t, 1300, events[1].PID)
+
+	require.Equal(t, "ED25519-CERT", events[2].KeyType)
+	require.Equal(t, "SHA256:AbCd", events[2].Fingerprint)
+	require.Equal(t, "alice@corp", events[2].CertID)
+	require.Equal(t, "2001:db8::7", events[2].IP.String())
+	require.Equal(t, 250*time.Millisecond, time.Duration(events[2].Time.Nanosecond()))
+
+	require.Equal(t, 2025, events[3].Time.Year()) // last year
+
+	events, err = ParseJournal(strings.NewReader(journal))
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, "keyboard-interactive/pam", events[0].Method)
+	require.Equal(t, 1500, events[0].PID)
+	require.Equal(t, time.UnixMicro(1760511601500000), events[0].Time)
+}
+
+func TestCorrelate(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.Local)
+	events, err := Parse(strings.NewReader(authLog), now)
+	require.NoError(t, err)
+
+	at := func(h, m, s int) time.Time { return time.Date(2026, 10, 15, h, m, s, 0, time.Local) }
+	sessions := []utmp.Session{
+		{User: "alice", Host: "10.0.0.5", IP: net.ParseIP("10.0.0.5"), Login: at(10, 0, 2)},
+		{User: "alice", Host: "10.0.0.5", IP: net.ParseIP("10.0.0.5"), Login: at(10, 0, 3)}, // reused event
+		{User: "bob", Host: "10.0.0.6", Login: at(10, 5, 1)},
+		{User: "bob", Host: "10.0.0.6", Login: at(10, 30, 0)}, // out of window
+		{User: "carol", TTY: "tty1", Login: at(10, 5, 1)},     // local
+	}
+	require.Equal(t, 2, Correlate(sessions, events, WINDOW))
+	require.Equal(t, "SHA256:Hq2XvC0Lw0R6Q1nQm4v1Zb7m0r5rDk8Kc1bXo0n3cSE", sessions[0].Auth.Fingerprint)
+	require.Nil(t, sessions[1].Auth)
+	require.Equal(t, "password", sessions[2].Auth.Method)
+	require.Nil(t, sessions[3].Auth)
+	require.Nil(t, sessions[4].Auth)
+
+	users := utmp.Users{{Name: "alice", Host: "2001:db8::7", Time: events[2].Time.Add(time.Second)}}
+	require.Equal(t, 1, CorrelateUsers(users, events, WINDOW))
+	require.Equal(t, "alice@corp", users[0].Auth.CertID)
+
+	li, err := users.GetLoginInfo("alice")
+	if err == nil { // user info may be unavailable
+		require.Len(t, li.Auth, 1)
+	}
+}
+
+// EOF: "authlog_test.go"
@@ -0,0 +1,97 @@
+// File: "checkpoint.go"
+
+package export
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Контрольная точка инкрементальной выгрузки: для каждого источника
+// (wtmp/btmp файла) хранится момент времени, до которого (включительно)
+// данные уже выгружены. Сохраняется в JSON файле, например:
+//
+//	{"sources": {"/var/log/wtmp": "2026-10-15T03:00:00.123456+03:00"}}
+//
+// Export checkpoint (last exported time per source).
+type Checkpoint struct {
+	Sources map[string]time.Time `json:"sources"`
+}
+
+// Прочитать контрольную точку из файла (отсутствующий файл - пустая
+// контрольная точка, т.е. выгрузка всех данных).
+// Read checkpoint file (missing file is not an error).
+func ReadCheckpoint(fname string) (*Checkpoint, error) {
+	cp := &Checkpoint{Sources: make(map[string]time.Time)}
+
+	data, err := os.ReadFile(fname)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cp, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(data, cp)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fname, err)
+	}
+	if cp.Sources == nil {
+		cp.Sources = make(map[string]time.Time)
+	}
+	return cp, nil
+}
+
+// Записать контрольную точку в файл (атомарно, через временный файл).
+// Write checkpoint file atomically.
+func (cp *Checkpoint) Write(fname string) error {
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(fname), filepath.Base(fname)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op after rename
+
+	_, err = tmp.Write(append(data, '\n'))
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), fname)
+}
+
+// Момент времени, до которого выгружены данные источника (или нулевое время).
+// Get last exported time of source.
+func (cp *Checkpoint) Get(source string) time.Time {
+	return cp.Sources[sourceKey(source)]
+}
+
+// Запомнить момент времени, до которого выгружены данные источника.
+// Set last exported time of source.
+func (cp *Checkpoint) Set(source string, t time.Time) {
+	cp.Sources[sourceKey(source)] = t
+}
+
+// Ключ источника - абсолютный путь к файлу.
+func sourceKey(source string) string {
+	if abs, err := filepath.Abs(source); err == nil {
+		return abs
+	}
+	return source
+}
+
+// EOF: "checkpoint.go"
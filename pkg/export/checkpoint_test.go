@@ -0,0 +1,66 @@
+// File: "checkpoint_test.go"
+
+package export
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	fname := filepath.Join(dir, "export.json")
+
+	// missing file: export all
+	cp, err := ReadCheckpoint(fname)
+	require.NoError(t, err)
+	require.Empty(t, cp.Sources)
+	require.True(t, cp.Get("/var/log/wtmp").IsZero())
+
+	// sources are stored by absolute path
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(dir))
+	defer os.Chdir(wd)
+	at := time.Date(2026, 10, 15, 3, 0, 0, 123456000, time.UTC)
+	cp.Set("wtmp", at)
+	cp.Set("/var/log/btmp", at.Add(time.Hour))
+	require.Equal(t, at, cp.Get(filepath.Join(dir, "wtmp")))
+	require.NoError(t, cp.Write(fname))
+
+	cp, err = ReadCheckpoint(fname)
+	require.NoError(t, err)
+	require.Len(t, cp.Sources, 2)
+	require.True(t, at.Equal(cp.Get("wtmp")))
+	require.True(t, at.Add(time.Hour).Equal(cp.Get("/var/log/btmp")))
+
+	// no temporary files are left
+	files, err := filepath.Glob(filepath.Join(dir, "export.json*"))
+	require.NoError(t, err)
+	require.Equal(t, []string{fname}, files)
+
+	// rewrite replaces file
+	cp.Set("wtmp", at.Add(2*time.Hour))
+	require.NoError(t, cp.Write(fname))
+	cp, err = ReadCheckpoint(fname)
+	require.NoError(t, err)
+	require.True(t, at.Add(2*time.Hour).Equal(cp.Get("wtmp")))
+
+	// empty object and broken file
+	require.NoError(t, os.WriteFile(fname, []byte(`{}`), 0644))
+	cp, err = ReadCheckpoint(fname)
+	require.NoError(t, err)
+	cp.Set("wtmp", at) // map is created
+	require.NoError(t, os.WriteFile(fname, []byte(`{"sources": [`), 0644))
+	_, err = ReadCheckpoint(fname)
+	require.ErrorContains(t, err, fname)
+
+	// directory of checkpoint doesn't exist
+	require.Error(t, cp.Write(filepath.Join(dir, "no", "export.json")))
+}
+
+// EOF: "checkpoint_test.go"
@@ -0,0 +1,158 @@
+// Пакет `export` - выгрузка сеансов пользователей, загрузок системы
+// и неудачных попыток входа из wtmp/btmp файлов во внешние форматы
+// для анализа сторонними средствами (SQL и т.п.).
+// File: "export.go"
+package export
+
+import (
+	"net"
+	"os"
+	"time"
+
+	"github.com/azorg/gousers/v2/pkg/utmp"
+)
+
+// Файл неудачных попыток входа по умолчанию.
+// Default file of failed logins.
+const DEFAULT_BTMP = "/var/log/btmp"
+
+// Неудачная попытка входа (запись btmp файла).
+// Failed login attempt (btmp record).
+type FailedLogin struct {
+	utmp.Record
+}
+
+// Набор данных для выгрузки.
+// Dataset to export.
+type Dataset struct {
+	Host     string             // Hostname of source host
+	Sessions []utmp.Session     // User sessions from wtmp
+	Boots    []utmp.SystemEvent // System boots from wtmp
+	Failed   []FailedLogin      // Failed logins from btmp
+
+	// Инкрементальная выгрузка: строки добавляются к ранее выгруженным
+	// (см. LoadIncremental())
+	Incremental bool      // append rows, don't replace data of the host
+	Until       time.Time // end of exported time window (incremental only)
+}
+
+// Прочитать набор данных из wtmp и btmp файлов
+// (btmp = "" - не читать неудачные попытки входа).
+// Load dataset from wtmp and btmp files.
+func Load(wtmp, btmp string, opts utmp.GetUsersOpts) (*Dataset, error) {
+	d := &Dataset{}
+	d.Host, _ = os.Hostname()
+
+	var err error
+	d.Sessions, err = utmp.GetSessions(wtmp, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := utmp.SystemEvents(wtmp)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range events {
+		if e.Type == utmp.SYS_BOOT && opts.InRange(e.Time) {
+			d.Boots = append(d.Boots, e)
+		}
+	}
+
+	if btmp != "" {
+		d.Failed, err = LoadFailed(btmp, opts)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return d, nil
+}
+
+// Прочитать только новые данные после контрольной точки `cp` и обновить
+// её (в памяти, сохранять файл нужно после успешной выгрузки).
+// Выгружаются завершенные сеансы (время выхода после контрольной точки),
+// загрузки системы и неудачные попытки входа после контрольной точки.
+// Активные сеансы не выгружаются - они попадут в выгрузку после выхода
+// пользователя, поэтому повторный запуск (например, из cron) не создает
+// дубликатов.
+// Load dataset after checkpoint (closed sessions only) and update checkpoint.
+func LoadIncremental(wtmp, btmp string, opts utmp.GetUsersOpts, cp *Checkpoint) (*Dataset, error) {
+	now := time.Now()
+	if opts.Until.IsZero() || opts.Until.After(now) {
+		opts.Until = now
+	}
+
+	d, err := Load(wtmp, "", opts)
+	if err != nil {
+		return nil, err
+	}
+	d.Incremental, d.Until = true, opts.Until
+
+	since := cp.Get(wtmp)
+	sessions := d.Sessions[:0]
+	for _, s := range d.Sessions {
+		if s.End != utmp.SESSION_ACTIVE && s.Logout.After(since) {
+			sessions = append(sessions, s)
+		}
+	}
+	d.Sessions = sessions
+
+	boots := d.Boots[:0]
+	for _, b := range d.Boots {
+		if b.Time.After(since) {
+			boots = append(boots, b)
+		}
+	}
+	d.Boots = boots
+
+	if btmp != "" {
+		bopts := opts
+		if since := cp.Get(btmp); !since.IsZero() && since.After(bopts.Since) {
+			bopts.Since = since.Add(time.Nanosecond) // (since, until]
+		}
+		d.Failed, err = LoadFailed(btmp, bopts)
+		if err != nil {
+			return nil, err
+		}
+		cp.Set(btmp, opts.Until)
+	}
+
+	cp.Set(wtmp, opts.Until)
+	return d, nil
+}
+
+// Прочитать неудачные попытки входа из btmp файла.
+// Load failed logins from btmp file.
+func LoadFailed(fname string, opts utmp.GetUsersOpts) ([]FailedLogin, error) {
+	f, err := opts.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	failed := []FailedLogin{}
+	in := utmp.NewInterner(0)
+	s := opts.NewScanner(f)
+	s.SkipEmpty = true
+	for s.Scan() {
+		u := s.Record()
+		if u.Type != utmp.USER_PROCESS && u.Type != utmp.LOGIN_PROCESS {
+			continue
+		}
+		if !opts.InRange(utmp.Time(u.TV)) {
+			continue
+		}
+		failed = append(failed, FailedLogin{u.DecodeWith(in)})
+	}
+	return failed, s.Err()
+}
+
+// IP адрес в виде строки ("" если адрес не задан).
+func ipStr(ip net.IP) string {
+	if len(ip) == 0 {
+		return ""
+	}
+	return ip.String()
+}
+
+// EOF: "export.go"
@@ -0,0 +1,158 @@
+// File: "export_test.go"
+
+package export
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/azorg/gousers/v2/pkg/utmp"
+)
+
+// Создать тестовую запись wtmp.
+func record(Type int16, pid uint32, line, user, host string, sec int32) utmp.Utmp {
+	u := utmp.Utmp{Type: Type}
+	binary.LittleEndian.PutUint32(u.PID[:], pid)
+	for i := 0; i < len(line) && i < len(u.Line); i++ {
+		u.Line[i] = int8(line[i])
+	}
+	for i := 0; i < len(user) && i < len(u.User); i++ {
+		u.User[i] = int8(user[i])
+	}
+	for i := 0; i < len(host) && i < len(u.Host); i++ {
+		u.Host[i] = int8(host[i])
+	}
+	u.TV.Sec = sec
+	return u
+}
+
+// Дописать записи в wtmp файл (flag - os.O_APPEND или os.O_TRUNC).
+func writeWtmp(t *testing.T, fname string, flag int, recs ...utmp.Utmp) {
+	f, err := os.OpenFile(fname, os.O_WRONLY|os.O_CREATE|flag, 0644)
+	require.NoError(t, err)
+	defer f.Close()
+	for i := range recs {
+		require.NoError(t, binary.Write(f, binary.LittleEndian, &recs[i]))
+	}
+}
+
+// Пользователи сеансов набора данных.
+func sessionUsers(d *Dataset) []string {
+	users := []string{}
+	for _, s := range d.Sessions {
+		users = append(users, s.User)
+	}
+	return users
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	wtmp, btmp := filepath.Join(dir, "wtmp"), filepath.Join(dir, "btmp")
+	writeWtmp(t, wtmp, os.O_APPEND,
+		record(utmp.BOOT_TIME, 0, "~", "reboot", "6.1.0", 1000),
+		record(utmp.USER_PROCESS, 101, "pts/0", "alice", "10.0.0.5", 1010),
+		record(utmp.DEAD_PROCESS, 101, "pts/0", "", "", 1100),
+		record(utmp.BOOT_TIME, 0, "~", "reboot", "6.1.1", 2000))
+	writeWtmp(t, btmp, os.O_APPEND,
+		record(utmp.LOGIN_PROCESS, 201, "ssh:notty", "root", "1.2.3.4", 1050),
+		record(utmp.LOGIN_PROCESS, 202, "ssh:notty", "admin", "1.2.3.4", 2050))
+
+	d, err := Load(wtmp, btmp, utmp.GetUsersOpts{})
+	require.NoError(t, err)
+	require.False(t, d.Incremental)
+	require.Equal(t, []string{"alice"}, sessionUsers(d))
+	require.Len(t, d.Boots, 2)
+	require.Len(t, d.Failed, 2)
+	require.Equal(t, "1.2.3.4", d.Failed[0].Host)
+
+	d, err = Load(wtmp, btmp, utmp.GetUsersOpts{Since: time.Unix(1500, 0)})
+	require.NoError(t, err)
+	require.Empty(t, d.Sessions)
+	require.Equal(t, "6.1.1", d.Boots[0].Kernel)
+	require.Len(t, d.Boots, 1)
+	require.Equal(t, "admin", d.Failed[0].User)
+	require.Len(t, d.Failed, 1)
+}
+
+func TestLoadIncremental(t *testing.T) {
+	dir := t.TempDir()
+	wtmp, btmp := filepath.Join(dir, "wtmp"), filepath.Join(dir, "btmp")
+	cp := &Checkpoint{Sources: make(map[string]time.Time)}
+	load := func(until int64, rotated bool) *Dataset {
+		d, err := LoadIncremental(wtmp, btmp, utmp.GetUsersOpts{
+			Until: time.Unix(until, 0), Rotated: rotated}, cp)
+		require.NoError(t, err)
+		require.True(t, d.Incremental)
+		require.Equal(t, time.Unix(until, 0), d.Until)
+		require.True(t, time.Unix(until, 0).Equal(cp.Get(wtmp)))
+		return d
+	}
+
+	// closed sessions only: bob is still logged in
+	writeWtmp(t, wtmp, os.O_APPEND,
+		record(utmp.BOOT_TIME, 0, "~", "reboot", "6.1.0", 1000),
+		record(utmp.USER_PROCESS, 101, "pts/0", "alice", "10.0.0.5", 1010),
+		record(utmp.USER_PROCESS, 102, "pts/1", "bob", "10.0.0.6", 1020),
+		record(utmp.DEAD_PROCESS, 101, "pts/0", "", "", 1100))
+	writeWtmp(t, btmp, os.O_APPEND,
+		record(utmp.LOGIN_PROCESS, 201, "ssh:notty", "root", "1.2.3.4", 1050))
+	d := load(1200, false)
+	require.Equal(t, []string{"alice"}, sessionUsers(d))
+	require.Len(t, d.Boots, 1)
+	require.Len(t, d.Failed, 1)
+
+	// nothing new
+	d = load(1300, false)
+	require.Empty(t, d.Sessions)
+	require.Empty(t, d.Boots)
+	require.Empty(t, d.Failed)
+
+	// appended: bob logged out, carol logged in and out
+	writeWtmp(t, wtmp, os.O_APPEND,
+		record(utmp.DEAD_PROCESS, 102, "pts/1", "", "", 1400),
+		record(utmp.USER_PROCESS, 103, "pts/0", "carol", "10.0.0.7", 1410),
+		record(utmp.DEAD_PROCESS, 103, "pts/0", "", "", 1420),
+		record(utmp.USER_PROCESS, 104, "tty1", "dave", "", 1430))
+	writeWtmp(t, btmp, os.O_APPEND,
+		record(utmp.LOGIN_PROCESS, 202, "ssh:notty", "admin", "1.2.3.4", 1450))
+	d = load(1500, false)
+	require.Equal(t, []string{"bob", "carol"}, sessionUsers(d))
+	require.Equal(t, "admin", d.Failed[0].User)
+	require.Len(t, d.Failed, 1)
+
+	// rotated: dave logged out in new wtmp, rotated file is read too
+	require.NoError(t, os.Rename(wtmp, wtmp+".1"))
+	writeWtmp(t, wtmp, os.O_APPEND,
+		record(utmp.DEAD_PROCESS, 104, "tty1", "", "", 1600),
+		record(utmp.USER_PROCESS, 105, "pts/2", "erin", "10.0.0.8", 1610),
+		record(utmp.DEAD_PROCESS, 105, "pts/2", "", "", 1620))
+	d = load(1700, true)
+	require.Equal(t, []string{"dave", "erin"}, sessionUsers(d))
+	require.Equal(t, utmp.SESSION_LOGOUT, d.Sessions[0].End)
+	require.Empty(t, d.Failed)
+
+	// truncated: only sessions after checkpoint
+	writeWtmp(t, wtmp, os.O_TRUNC,
+		record(utmp.BOOT_TIME, 0, "~", "reboot", "6.1.1", 1800),
+		record(utmp.USER_PROCESS, 106, "pts/0", "frank", "10.0.0.9", 1810),
+		record(utmp.DEAD_PROCESS, 106, "pts/0", "", "", 1820))
+	d = load(1900, false)
+	require.Equal(t, []string{"frank"}, sessionUsers(d))
+	require.Len(t, d.Boots, 1)
+
+	// checkpoint is restored from file
+	fname := filepath.Join(dir, "export.json")
+	require.NoError(t, cp.Write(fname))
+	cp, err := ReadCheckpoint(fname)
+	require.NoError(t, err)
+	d = load(2000, false)
+	require.Empty(t, d.Sessions)
+	require.Empty(t, d.Boots)
+}
+
+// EOF: "export_test.go"
@@ -0,0 +1,410 @@
+// File: "parquet.go"
+
+package export
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Минимальная реализация записи Parquet файлов (без внешних зависимостей):
+// одна группа строк (row group), одна страница данных (DATA_PAGE v1)
+// на колонку, кодирование PLAIN, сжатие GZIP.
+// Колонки времени - INT64 (TIMESTAMP_MILLIS, UTC).
+// Minimal Parquet writer (one row group, PLAIN encoding, GZIP codec).
+
+// Таблицы, выгружаемые в Parquet (по файлу на таблицу, см. SQLITE_SCHEMA).
+// Parquet tables (one file per table).
+var ParquetTables = []string{"sessions", "boots", "failed_logins"}
+
+// Parquet constants (see parquet.thrift)
+const (
+	pqINT64      = 2 // Type
+	pqBYTE_ARRAY = 6 // Type
+
+	pqREQUIRED = 0 // FieldRepetitionType
+	pqOPTIONAL = 1 // FieldRepetitionType
+
+	pqUTF8             = 0 // ConvertedType
+	pqTIMESTAMP_MILLIS = 9 // ConvertedType
+	pqNONE             = -1
+
+	pqPLAIN = 0 // Encoding
+	pqRLE   = 3 // Encoding
+
+	pqGZIP      = 2 // CompressionCodec
+	pqDATA_PAGE = 0 // PageType
+)
+
+// Колонка Parquet файла.
+type pqColumn struct {
+	name  string
+	kind  int32    // pqINT64 | pqBYTE_ARRAY
+	conv  int32    // converted type or pqNONE
+	ints  []int64  // values of INT64 column
+	strs  []string // values of BYTE_ARRAY column
+	nulls []bool   // null flags (optional column only)
+}
+
+func pqString(name string) *pqColumn {
+	return &pqColumn{name: name, kind: pqBYTE_ARRAY, conv: pqUTF8}
+}
+
+func pqInt(name string) *pqColumn {
+	return &pqColumn{name: name, kind: pqINT64, conv: pqNONE}
+}
+
+func pqTime(name string) *pqColumn {
+	return &pqColumn{name: name, kind: pqINT64, conv: pqTIMESTAMP_MILLIS}
+}
+
+func (c *pqColumn) optional() *pqColumn {
+	c.nulls = []bool{}
+	return c
+}
+
+func (c *pqColumn) addStr(s string) { c.strs = append(c.strs, s) }
+func (c *pqColumn) addInt(v int64)  { c.ints = append(c.ints, v) }
+
+func (c *pqColumn) addTime(t time.Time) {
+	if c.nulls != nil {
+		c.nulls = append(c.nulls, t.IsZero())
+		if t.IsZero() {
+			return
+		}
+	}
+	c.ints = append(c.ints, t.UnixMilli())
+}
+
+func (c *pqColumn) addOptInt(v int64, null bool) {
+	c.nulls = append(c.nulls, null)
+	if !null {
+		c.ints = append(c.ints, v)
+	}
+}
+
+// Колонки таблицы набора данных.
+func parquetColumns(table string, d *Dataset) ([]*pqColumn, int, error) {
+	switch table {
+	case "sessions":
+		host, user, tty, id := pqString("host"), pqString("user"), pqString("tty"), pqString("id")
+		pid, rhost, ip := pqInt("pid"), pqString("remote_host"), pqString("ip")
+		login, logout := pqTime("login"), pqTime("logout").optional()
+		duration, end := pqInt("duration").optional(), pqString("end_type")
+		for _, s := range d.Sessions {
+			host.addStr(d.Host)
+			user.addStr(s.User)
+			tty.addStr(s.TTY)
+			id.addStr(s.ID)
+			pid.addInt(int64(s.PID))
+			rhost.addStr(s.Host)
+			ip.addStr(ipStr(s.IP))
+			login.addTime(s.Login)
+			logout.addTime(s.Logout)
+			duration.addOptInt(int64(s.Logout.Sub(s.Login)/time.Second), s.Logout.IsZero())
+			end.addStr(s.End.String())
+		}
+		return []*pqColumn{host, user, tty, id, pid, rhost, ip, login, logout, duration, end},
+			len(d.Sessions), nil
+
+	case "boots":
+		host, tm, kernel := pqString("host"), pqTime("time"), pqString("kernel")
+		for _, b := range d.Boots {
+			host.addStr(d.Host)
+			tm.addTime(b.Time)
+			kernel.addStr(b.Kernel)
+		}
+		return []*pqColumn{host, tm, kernel}, len(d.Boots), nil
+
+	case "failed_logins":
+		host, tm, user := pqString("host"), pqTime("time"), pqString("user")
+		tty, rhost, ip := pqString("tty"), pqString("remote_host"), pqString("ip")
+		for _, f := range d.Failed {
+			host.addStr(d.Host)
+			tm.addTime(f.Time)
+			user.addStr(f.User)
+			tty.addStr(f.Line)
+			rhost.addStr(f.Host)
+			ip.addStr(ipStr(f.IP))
+		}
+		return []*pqColumn{host, tm, user, tty, rhost, ip}, len(d.Failed), nil
+	}
+	return nil, 0, fmt.Errorf("unknown table '%s'", table)
+}
+
+// Записать таблицу набора данных в формате Parquet.
+// Write dataset table in Parquet format.
+func WriteParquet(w io.Writer, table string, d *Dataset) error {
+	cols, rows, err := parquetColumns(table, d)
+	if err != nil {
+		return err
+	}
+	return writeParquet(w, cols, rows)
+}
+
+// Выгрузить все таблицы набора данных в каталог `dir` в виде
+// Parquet файлов "<table>.parquet" (при инкрементальной выгрузке -
+// "<table>-YYYYMMDDhhmmss.uuuuuu.parquet" по времени окончания окна
+// выгрузки, пустые таблицы не записываются).
+// Export dataset to Parquet files in directory.
+func Parquet(dir string, d *Dataset) error {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	for _, table := range ParquetTables {
+		name := table
+		if d.Incremental {
+			if _, rows, _ := parquetColumns(table, d); rows == 0 {
+				continue // nothing new
+			}
+			name += d.Until.UTC().Format("-20060102150405.000000")
+		}
+		fname := filepath.Join(dir, name+".parquet")
+		f, err := os.Create(fname)
+		if err != nil {
+			return err
+		}
+		err = WriteParquet(f, table, d)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", fname, err)
+		}
+	}
+	return nil
+}
+
+// Записать Parquet файл.
+func writeParquet(w io.Writer, cols []*pqColumn, rows int) error {
+	out := &countWriter{w: w}
+	out.Write([]byte("PAR1"))
+
+	type chunk struct {
+		offset, size, rawSize int64
+	}
+	chunks := make([]chunk, len(cols))
+
+	for i, c := range cols {
+		raw := c.page()
+
+		var zbuf bytes.Buffer
+		z := gzip.NewWriter(&zbuf)
+		z.Write(raw)
+		z.Close()
+
+		// PageHeader
+		var hdr thriftWriter
+		hdr.fieldI32(1, pqDATA_PAGE)
+		hdr.fieldI32(2, int32(len(raw)))
+		hdr.fieldI32(3, int32(zbuf.Len()))
+		hdr.fieldStruct(5) // DataPageHeader
+		hdr.fieldI32(1, int32(rows))
+		hdr.fieldI32(2, pqPLAIN)
+		hdr.fieldI32(3, pqRLE)
+		hdr.fieldI32(4, pqRLE)
+		hdr.endStruct()
+		hdr.endStruct()
+
+		chunks[i].offset = out.n
+		out.Write(hdr.Bytes())
+		out.Write(zbuf.Bytes())
+		chunks[i].size = out.n - chunks[i].offset
+		chunks[i].rawSize = int64(hdr.Len() + len(raw))
+	}
+
+	// FileMetaData
+	var meta thriftWriter
+	meta.fieldI32(1, 1) // version
+	meta.fieldList(2, tSTRUCT, len(cols)+1)
+	meta.beginStruct() // root SchemaElement
+	meta.fieldBinary(4, "schema")
+	meta.fieldI32(5, int32(len(cols)))
+	meta.endStruct()
+	for _, c := range cols {
+		meta.beginStruct() // SchemaElement
+		meta.fieldI32(1, c.kind)
+		if c.nulls != nil {
+			meta.fieldI32(3, pqOPTIONAL)
+		} else {
+			meta.fieldI32(3, pqREQUIRED)
+		}
+		meta.fieldBinary(4, c.name)
+		if c.conv != pqNONE {
+			meta.fieldI32(6, c.conv)
+		}
+		meta.endStruct()
+	}
+	meta.fieldI64(3, int64(rows))
+	meta.fieldList(4, tSTRUCT, 1)
+	meta.beginStruct() // RowGroup
+	var total int64
+	meta.fieldList(1, tSTRUCT, len(cols))
+	for i, c := range cols {
+		meta.beginStruct()                 // ColumnChunk
+		meta.fieldI64(2, chunks[i].offset) // file_offset
+		meta.fieldStruct(3)                // ColumnMetaData
+		meta.fieldI32(1, c.kind)
+		meta.fieldList(2, tI32, 2)
+		meta.i32(pqPLAIN)
+		meta.i32(pqRLE)
+		meta.fieldList(3, tBINARY, 1)
+		meta.binary(c.name)
+		meta.fieldI32(4, pqGZIP)
+		meta.fieldI64(5, int64(rows))
+		meta.fieldI64(6, chunks[i].rawSize)
+		meta.fieldI64(7, chunks[i].size)
+		meta.fieldI64(9, chunks[i].offset) // data_page_offset
+		meta.endStruct()
+		meta.endStruct()
+		total += chunks[i].rawSize
+	}
+	meta.fieldI64(2, total)
+	meta.fieldI64(3, int64(rows))
+	meta.endStruct()
+	meta.fieldBinary(6, "gousers")
+	meta.endStruct()
+
+	out.Write(meta.Bytes())
+	binary.Write(out, binary.LittleEndian, uint32(meta.Len()))
+	out.Write([]byte("PAR1"))
+	return out.err
+}
+
+// Содержимое страницы данных (уровни определения + значения PLAIN).
+func (c *pqColumn) page() []byte {
+	var buf bytes.Buffer
+
+	if c.nulls != nil { // definition levels (RLE, bit width 1)
+		var rle bytes.Buffer
+		for i := 0; i < len(c.nulls); {
+			j := i
+			for j < len(c.nulls) && c.nulls[j] == c.nulls[i] {
+				j++
+			}
+			putUvarint(&rle, uint64(j-i)<<1) // RLE run header
+			if c.nulls[i] {
+				rle.WriteByte(0)
+			} else {
+				rle.WriteByte(1)
+			}
+			i = j
+		}
+		binary.Write(&buf, binary.LittleEndian, uint32(rle.Len()))
+		buf.Write(rle.Bytes())
+	}
+
+	switch c.kind {
+	case pqINT64:
+		for _, v := range c.ints {
+			binary.Write(&buf, binary.LittleEndian, v)
+		}
+	case pqBYTE_ARRAY:
+		for _, s := range c.strs {
+			binary.Write(&buf, binary.LittleEndian, uint32(len(s)))
+			buf.WriteString(s)
+		}
+	}
+	return buf.Bytes()
+}
+
+// Thrift compact protocol types
+const (
+	tI32    = 5
+	tI64    = 6
+	tBINARY = 8
+	tLIST   = 9
+	tSTRUCT = 12
+)
+
+// Запись структур Thrift (compact protocol).
+type thriftWriter struct {
+	bytes.Buffer
+	last  int16   // last field id of current struct
+	stack []int16 // last field ids of outer structs
+}
+
+func (t *thriftWriter) field(id int16, typ byte) {
+	if delta := id - t.last; delta > 0 && delta <= 15 {
+		t.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		t.WriteByte(typ)
+		putUvarint(&t.Buffer, zigzag(int64(id)))
+	}
+	t.last = id
+}
+
+func (t *thriftWriter) i32(v int32)                    { putUvarint(&t.Buffer, zigzag(int64(v))) }
+func (t *thriftWriter) i64(v int64)                    { putUvarint(&t.Buffer, zigzag(v)) }
+func (t *thriftWriter) binary(s string)                { putUvarint(&t.Buffer, uint64(len(s))); t.WriteString(s) }
+func (t *thriftWriter) fieldI32(id int16, v int32)     { t.field(id, tI32); t.i32(v) }
+func (t *thriftWriter) fieldI64(id int16, v int64)     { t.field(id, tI64); t.i64(v) }
+func (t *thriftWriter) fieldBinary(id int16, s string) { t.field(id, tBINARY); t.binary(s) }
+
+// Начать вложенную структуру (завершается вызовом endStruct()).
+func (t *thriftWriter) fieldStruct(id int16) {
+	t.field(id, tSTRUCT)
+	t.beginStruct()
+}
+
+// Начать список (элементы-структуры: beginStruct() ... endStruct()).
+func (t *thriftWriter) fieldList(id int16, elem byte, size int) {
+	t.field(id, tLIST)
+	if size < 15 {
+		t.WriteByte(byte(size)<<4 | elem)
+	} else {
+		t.WriteByte(0xF0 | elem)
+		putUvarint(&t.Buffer, uint64(size))
+	}
+}
+
+// Начать структуру (элемент списка или вложенную структуру).
+func (t *thriftWriter) beginStruct() {
+	t.stack = append(t.stack, t.last)
+	t.last = 0
+}
+
+// Завершить структуру (в т.ч. корневую).
+func (t *thriftWriter) endStruct() {
+	t.WriteByte(0) // STOP
+	if n := len(t.stack); n != 0 {
+		t.last = t.stack[n-1]
+		t.stack = t.stack[:n-1]
+	}
+}
+
+func zigzag(v int64) uint64 {
+	return uint64(v<<1) ^ uint64(v>>63)
+}
+
+func putUvarint(buf *bytes.Buffer, v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	buf.Write(b[:binary.PutUvarint(b[:], v)])
+}
+
+// Подсчет числа записанных байт.
+type countWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	c.err = err
+	return n, err
+}
+
+// EOF: "parquet.go"
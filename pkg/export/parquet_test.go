@@ -0,0 +1,286 @@
+// File: "parquet_test.go"
+
+package export
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/azorg/gousers/v2/pkg/utmp"
+)
+
+// Чтение структур Thrift (compact protocol) в виде дерева:
+// структура - map[int16]any, список - []any, целые - int64,
+// binary - string.
+type thriftReader struct {
+	b   []byte
+	pos int
+}
+
+func (r *thriftReader) byte() byte {
+	c := r.b[r.pos]
+	r.pos++
+	return c
+}
+
+func (r *thriftReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.b[r.pos:])
+	if n <= 0 {
+		panic("bad varint")
+	}
+	r.pos += n
+	return v
+}
+
+func (r *thriftReader) value(typ byte) any {
+	switch typ {
+	case 1, 2: // bool in field header
+		return typ == 1
+	case 3:
+		return int64(int8(r.byte()))
+	case 4, 5, 6: // i16, i32, i64
+		v := r.uvarint()
+		return int64(v>>1) ^ -int64(v&1)
+	case 7:
+		v := binary.LittleEndian.Uint64(r.b[r.pos:])
+		r.pos += 8
+		return math.Float64frombits(v)
+	case tBINARY:
+		n := int(r.uvarint())
+		s := string(r.b[r.pos : r.pos+n])
+		r.pos += n
+		return s
+	case tLIST, 10: // list, set
+		hdr := r.byte()
+		size := int(hdr >> 4)
+		if size == 15 {
+			size = int(r.uvarint())
+		}
+		list := make([]any, size)
+		for i := range list {
+			list[i] = r.value(hdr & 0x0F)
+		}
+		return list
+	case tSTRUCT:
+		return r.structure()
+	}
+	panic("unsupported thrift type")
+}
+
+func (r *thriftReader) structure() map[int16]any {
+	s := make(map[int16]any)
+	var last int16
+	for {
+		hdr := r.byte()
+		if hdr == 0 { // STOP
+			return s
+		}
+		id := last + int16(hdr>>4)
+		if hdr>>4 == 0 {
+			v := r.uvarint()
+			id = int16(int64(v>>1) ^ -int64(v&1))
+		}
+		s[id] = r.value(hdr & 0x0F)
+		last = id
+	}
+}
+
+// Колонка, прочитанная из Parquet файла.
+type pqRead struct {
+	name   string
+	kind   int64
+	rep    int64
+	conv   int64 // -1 - none
+	values []any // nil - null
+}
+
+// Прочитать Parquet файл: число строк и колонки.
+func readParquet(t *testing.T, data []byte) (int64, []pqRead) {
+	require.Equal(t, "PAR1", string(data[:4]))
+	require.Equal(t, "PAR1", string(data[len(data)-4:]))
+	n := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	footer := data[len(data)-8-n : len(data)-8]
+	r := &thriftReader{b: footer}
+	meta := r.structure()
+	require.Equal(t, len(footer), r.pos)
+	require.Equal(t, int64(1), meta[1]) // version
+	rows := meta[3].(int64)
+
+	schema := meta[2].([]any)
+	root := schema[0].(map[int16]any)
+	require.Equal(t, "schema", root[4])
+	require.Equal(t, int64(len(schema)-1), root[5])
+
+	groups := meta[4].([]any)
+	require.Len(t, groups, 1)
+	group := groups[0].(map[int16]any)
+	require.Equal(t, rows, group[3])
+	chunks := group[1].([]any)
+	require.Len(t, chunks, len(schema)-1)
+
+	var cols []pqRead
+	for i, el := range schema[1:] {
+		e := el.(map[int16]any)
+		c := pqRead{name: e[4].(string), kind: e[1].(int64), rep: e[3].(int64), conv: -1}
+		if v, ok := e[6]; ok {
+			c.conv = v.(int64)
+		}
+
+		cm := chunks[i].(map[int16]any)[3].(map[int16]any) // ColumnMetaData
+		require.Equal(t, c.kind, cm[1])
+		require.Equal(t, []any{c.name}, cm[3])
+		require.Equal(t, int64(pqGZIP), cm[4])
+		require.Equal(t, rows, cm[5])
+
+		// PageHeader and page
+		off := int(cm[9].(int64))
+		pr := &thriftReader{b: data[off:]}
+		hdr := pr.structure()
+		require.Equal(t, int64(pqDATA_PAGE), hdr[1])
+		require.Equal(t, int64(pr.pos)+hdr[3].(int64), cm[7]) // header + page
+		dp := hdr[5].(map[int16]any)
+		require.Equal(t, rows, dp[1])
+		require.Equal(t, int64(pqPLAIN), dp[2])
+
+		z, err := gzip.NewReader(bytes.NewReader(data[off+pr.pos : off+pr.pos+int(hdr[3].(int64))]))
+		require.NoError(t, err)
+		page, err := io.ReadAll(z)
+		require.NoError(t, err)
+		require.Equal(t, hdr[2], int64(len(page)))
+
+		// definition levels (RLE runs of bit width 1)
+		defined := make([]bool, rows)
+		for j := range defined {
+			defined[j] = true
+		}
+		if c.rep == pqOPTIONAL {
+			n := int(binary.LittleEndian.Uint32(page))
+			lr := &thriftReader{b: page[4 : 4+n]}
+			j := 0
+			for lr.pos < n {
+				run := lr.uvarint()
+				require.Zero(t, run&1, "bit-packed run")
+				v := lr.byte()
+				for k := 0; k < int(run>>1); k++ {
+					defined[j] = v == 1
+					j++
+				}
+			}
+			require.Equal(t, int(rows), j)
+			page = page[4+n:]
+		}
+
+		for _, ok := range defined {
+			if !ok {
+				c.values = append(c.values, nil)
+				continue
+			}
+			switch c.kind {
+			case pqINT64:
+				c.values = append(c.values, int64(binary.LittleEndian.Uint64(page)))
+				page = page[8:]
+			case pqBYTE_ARRAY:
+				n := binary.LittleEndian.Uint32(page)
+				c.values = append(c.values, string(page[4:4+n]))
+				page = page[4+n:]
+			}
+		}
+		require.Empty(t, page)
+		cols = append(cols, c)
+	}
+	return rows, cols
+}
+
+func TestWriteParquet(t *testing.T) {
+	login := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	d := &Dataset{
+		Host: "h1",
+		Sessions: []utmp.Session{
+			{User: "alice", TTY: "pts/0", ID: "ts/0", PID: 101, Host: "10.0.0.5",
+				IP: net.IPv4(10, 0, 0, 5), Login: login, Logout: login.Add(90 * time.Second),
+				End: utmp.SESSION_LOGOUT},
+			{User: "бобр", TTY: "tty1", ID: "tty1", PID: 102,
+				Login: login.Add(time.Hour), End: utmp.SESSION_ACTIVE},
+		}}
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteParquet(&buf, "sessions", d))
+	rows, cols := readParquet(t, buf.Bytes())
+	require.Equal(t, int64(2), rows)
+
+	names := []string{}
+	for _, c := range cols {
+		names = append(names, c.name)
+	}
+	require.Equal(t, []string{"host", "user", "tty", "id", "pid", "remote_host", "ip",
+		"login", "logout", "duration", "end_type"}, names)
+
+	col := func(name string) pqRead {
+		for _, c := range cols {
+			if c.name == name {
+				return c
+			}
+		}
+		t.Fatalf("no column %s", name)
+		return pqRead{}
+	}
+	require.Equal(t, []any{"h1", "h1"}, col("host").values)
+	require.Equal(t, []any{"alice", "бобр"}, col("user").values)
+	require.Equal(t, int64(pqUTF8), col("user").conv)
+	require.Equal(t, int64(pqREQUIRED), col("user").rep)
+	require.Equal(t, []any{int64(101), int64(102)}, col("pid").values)
+	require.Equal(t, int64(pqINT64), col("pid").kind)
+	require.Equal(t, int64(-1), col("pid").conv)
+	require.Equal(t, []any{"10.0.0.5", ""}, col("ip").values)
+
+	login1 := col("login")
+	require.Equal(t, int64(pqTIMESTAMP_MILLIS), login1.conv)
+	require.Equal(t, []any{login.UnixMilli(), login.Add(time.Hour).UnixMilli()}, login1.values)
+
+	logout := col("logout")
+	require.Equal(t, int64(pqOPTIONAL), logout.rep)
+	require.Equal(t, []any{login.Add(90 * time.Second).UnixMilli(), nil}, logout.values)
+	require.Equal(t, []any{int64(90), nil}, col("duration").values)
+	require.Equal(t, []any{"logout", "active"}, col("end_type").values)
+
+	// unknown table
+	require.Error(t, WriteParquet(&buf, "users", d))
+}
+
+func TestParquet(t *testing.T) {
+	d := &Dataset{Host: "h1",
+		Boots: []utmp.SystemEvent{{Type: utmp.SYS_BOOT, Time: time.Unix(1000, 0), Kernel: "6.1.0"}}}
+	dir := filepath.Join(t.TempDir(), "out")
+	require.NoError(t, Parquet(dir, d))
+	for _, table := range ParquetTables {
+		data, err := os.ReadFile(filepath.Join(dir, table+".parquet"))
+		require.NoError(t, err)
+		rows, cols := readParquet(t, data)
+		if table == "boots" {
+			require.Equal(t, int64(1), rows)
+			require.Equal(t, []any{"6.1.0"}, cols[2].values)
+		} else {
+			require.Zero(t, rows)
+		}
+	}
+
+	// incremental: empty tables are skipped, names by window end
+	d.Incremental, d.Until = true, time.Date(2026, 10, 15, 3, 0, 0, 0, time.UTC)
+	dir = filepath.Join(t.TempDir(), "inc")
+	require.NoError(t, Parquet(dir, d))
+	files, err := filepath.Glob(filepath.Join(dir, "*.parquet"))
+	require.NoError(t, err)
+	require.Equal(t, []string{filepath.Join(dir, "boots-20261015030000.000000.parquet")}, files)
+}
+
+// EOF: "parquet_test.go"
@@ -0,0 +1,168 @@
+// File: "sqlite.go"
+
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Программа sqlite3 (CLI), используемая для записи в базу данных.
+// SQLite command line shell.
+var SQLITE3_CMD = "sqlite3"
+
+// Схема базы данных SQLite.
+// Все моменты времени хранятся как Unix time (секунды, UTC), например:
+//
+//	SELECT user, datetime(login, 'unixepoch') FROM sessions;
+//
+// Таблица `sessions` - сеансы пользователей (пары вход/выход из wtmp):
+//
+//	host        - имя узла, с которого выгружены данные
+//	user        - имя пользователя
+//	tty         - терминал (например, "pts/0")
+//	id          - суффикс имени терминала (поле ID utmp)
+//	pid         - PID процесса входа
+//	remote_host - откуда выполнен вход (или "")
+//	ip          - IP адрес удаленного узла (или "")
+//	login       - время входа
+//	logout      - время выхода (NULL для активного сеанса)
+//	duration    - длительность сеанса в секундах (NULL для активного сеанса)
+//	end_type    - способ завершения: active, logout, gone, down, crash
+//
+// Таблица `boots` - загрузки системы (BOOT_TIME из wtmp):
+//
+//	host, time, kernel
+//
+// Таблица `failed_logins` - неудачные попытки входа (btmp):
+//
+//	host, time, user, tty, remote_host, ip
+//
+// SQLite database schema.
+const SQLITE_SCHEMA = `
+CREATE TABLE IF NOT EXISTS sessions (
+  host        TEXT NOT NULL,
+  user        TEXT NOT NULL,
+  tty         TEXT NOT NULL,
+  id          TEXT NOT NULL,
+  pid         INTEGER NOT NULL,
+  remote_host TEXT NOT NULL,
+  ip          TEXT NOT NULL,
+  login       INTEGER NOT NULL,
+  logout      INTEGER,
+  duration    INTEGER,
+  end_type    TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS sessions_user ON sessions (user, login);
+CREATE INDEX IF NOT EXISTS sessions_login ON sessions (login);
+
+CREATE TABLE IF NOT EXISTS boots (
+  host   TEXT NOT NULL,
+  time   INTEGER NOT NULL,
+  kernel TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS failed_logins (
+  host        TEXT NOT NULL,
+  time        INTEGER NOT NULL,
+  user        TEXT NOT NULL,
+  tty         TEXT NOT NULL,
+  remote_host TEXT NOT NULL,
+  ip          TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS failed_logins_time ON failed_logins (time);
+`
+
+// Записать набор данных в виде SQL скрипта (схема + данные в одной
+// транзакции). Ранее выгруженные данные того же узла заменяются
+// (при инкрементальной выгрузке - дополняются; в базе синхронизации,
+// см. Sync(), сеанс с тем же ключом заменяется).
+// Write dataset as SQL script (schema and data in one transaction).
+func WriteSQL(w io.Writer, d *Dataset) error {
+	bw := bufio.NewWriter(w)
+	host := sqlStr(d.Host)
+
+	fmt.Fprintln(bw, "BEGIN;")
+	fmt.Fprint(bw, SQLITE_SCHEMA)
+	if !d.Incremental {
+		fmt.Fprintf(bw, "DELETE FROM sessions WHERE host = %s;\n", host)
+		fmt.Fprintf(bw, "DELETE FROM boots WHERE host = %s;\n", host)
+		fmt.Fprintf(bw, "DELETE FROM failed_logins WHERE host = %s;\n", host)
+	}
+	writeRows(bw, d)
+	fmt.Fprintln(bw, "COMMIT;")
+
+	return bw.Flush()
+}
+
+// Записать строки данных (INSERT).
+func writeRows(w io.Writer, d *Dataset) {
+	host := sqlStr(d.Host)
+
+	for _, s := range d.Sessions {
+		logout, duration := "NULL", "NULL"
+		if !s.Logout.IsZero() {
+			logout = fmt.Sprint(s.Logout.Unix())
+			duration = fmt.Sprint(int64(s.Logout.Sub(s.Login) / time.Second))
+		}
+		fmt.Fprintf(w, "INSERT OR REPLACE INTO sessions VALUES (%s, %s, %s, %s, %d, %s, %s, %d, %s, %s, %s);\n",
+			host, sqlStr(s.User), sqlStr(s.TTY), sqlStr(s.ID), s.PID,
+			sqlStr(s.Host), sqlIP(s.IP), s.Login.Unix(), logout, duration,
+			sqlStr(s.End.String()))
+	}
+
+	for _, b := range d.Boots {
+		fmt.Fprintf(w, "INSERT INTO boots VALUES (%s, %d, %s);\n",
+			host, b.Time.Unix(), sqlStr(b.Kernel))
+	}
+
+	for _, f := range d.Failed {
+		fmt.Fprintf(w, "INSERT INTO failed_logins VALUES (%s, %d, %s, %s, %s, %s);\n",
+			host, f.Time.Unix(), sqlStr(f.User), sqlStr(f.Line),
+			sqlStr(f.Host), sqlIP(f.IP))
+	}
+}
+
+// Выгрузить набор данных в базу данных SQLite (с помощью программы sqlite3).
+// Export dataset to SQLite database (by sqlite3 CLI).
+func SQLite(dbname string, d *Dataset) error {
+	var script bytes.Buffer
+	err := WriteSQL(&script, d)
+	if err != nil {
+		return err
+	}
+	return execSQLite(dbname, &script)
+}
+
+// Выполнить SQL скрипт программой sqlite3.
+func execSQLite(dbname string, script io.Reader) error {
+	cmd := exec.Command(SQLITE3_CMD, "-bail", dbname)
+	cmd.Stdin = script
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		msg := strings.TrimSpace(string(out))
+		if msg != "" {
+			return fmt.Errorf("%s: %w: %s", SQLITE3_CMD, err, msg)
+		}
+		return fmt.Errorf("%s: %w", SQLITE3_CMD, err)
+	}
+	return nil
+}
+
+// Строковый литерал SQL.
+func sqlStr(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// IP адрес как строковый литерал SQL ("" если адрес не задан).
+func sqlIP(ip net.IP) string {
+	return sqlStr(ipStr(ip))
+}
+
+// EOF: "sqlite.go"
@@ -0,0 +1,108 @@
+// File: "sqlite_test.go"
+
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/azorg/gousers/v2/pkg/utmp"
+)
+
+// Пропустить тест без программы sqlite3.
+func needSQLite(t *testing.T) {
+	if _, err := exec.LookPath(SQLITE3_CMD); err != nil {
+		t.Skipf("%s is not found", SQLITE3_CMD)
+	}
+}
+
+// Набор данных со строками, требующими экранирования.
+func quotedDataset() *Dataset {
+	login := time.Unix(1000, 0)
+	return &Dataset{
+		Host: "o'hara",
+		Sessions: []utmp.Session{
+			{User: "x'); DROP TABLE sessions; --", TTY: "pts/0", ID: "ts/0", PID: 101,
+				Host: "it's\nhost", IP: net.IPv4(10, 0, 0, 5),
+				Login: login, Logout: login.Add(90 * time.Second), End: utmp.SESSION_LOGOUT},
+			{User: "bob", TTY: "tty1", ID: "tty1", PID: 102,
+				Login: login.Add(time.Hour), End: utmp.SESSION_ACTIVE},
+		},
+		Boots: []utmp.SystemEvent{{Type: utmp.SYS_BOOT, Time: login, Kernel: "6.1.0'"}},
+		Failed: []FailedLogin{{utmp.Record{
+			User: "''", Line: "ssh:notty", Host: "1.2.3.4",
+			IP: net.IPv4(1, 2, 3, 4), Time: login.Add(time.Minute)}}},
+	}
+}
+
+func TestWriteSQL(t *testing.T) {
+	require.Equal(t, `'it''s'`, sqlStr("it's"))
+	require.Equal(t, `''''''`, sqlStr("''"))
+	require.Equal(t, `''`, sqlIP(nil))
+	require.Equal(t, `'10.0.0.5'`, sqlIP(net.IPv4(10, 0, 0, 5)))
+
+	var buf bytes.Buffer
+	d := quotedDataset()
+	require.NoError(t, WriteSQL(&buf, d))
+	script := buf.String()
+	require.True(t, strings.HasPrefix(script, "BEGIN;\n"))
+	require.True(t, strings.HasSuffix(script, "COMMIT;\n"))
+	require.Contains(t, script, "DELETE FROM sessions WHERE host = 'o''hara';")
+	require.Contains(t, script, "INSERT OR REPLACE INTO sessions VALUES ('o''hara', "+
+		"'x''); DROP TABLE sessions; --', 'pts/0', 'ts/0', 101, 'it''s\nhost', "+
+		"'10.0.0.5', 1000, 1090, 90, 'logout');")
+	require.Contains(t, script, "'bob', 'tty1', 'tty1', 102, '', '', 4600, NULL, NULL, 'active');")
+
+	// incremental: rows are appended
+	buf.Reset()
+	d.Incremental = true
+	require.NoError(t, WriteSQL(&buf, d))
+	require.NotContains(t, buf.String(), "DELETE")
+}
+
+func TestSQLite(t *testing.T) {
+	needSQLite(t)
+	db := filepath.Join(t.TempDir(), "logins.db")
+	d := quotedDataset()
+	require.NoError(t, SQLite(db, d))
+	require.NoError(t, SQLite(db, d)) // data of host is replaced
+
+	rows, err := Query(db, "SELECT * FROM sessions ORDER BY login")
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	require.Equal(t, "o'hara", rows[0]["host"])
+	require.Equal(t, "x'); DROP TABLE sessions; --", rows[0]["user"])
+	require.Equal(t, "it's\nhost", rows[0]["remote_host"])
+	require.Equal(t, json.Number("90"), rows[0]["duration"])
+	require.Nil(t, rows[1]["logout"])
+	require.Equal(t, "active", rows[1]["end_type"])
+
+	rows, err = Query(db, "SELECT kernel FROM boots")
+	require.NoError(t, err)
+	require.Equal(t, []map[string]any{{"kernel": "6.1.0'"}}, rows)
+
+	rows, err = Query(db, "SELECT user, ip FROM failed_logins")
+	require.NoError(t, err)
+	require.Equal(t, []map[string]any{{"user": "''", "ip": "1.2.3.4"}}, rows)
+
+	// incremental export appends rows
+	d.Incremental = true
+	d.Boots, d.Failed = nil, nil
+	require.NoError(t, SQLite(db, d))
+	rows, err = Query(db, "SELECT count(*) AS n FROM sessions")
+	require.NoError(t, err)
+	require.Equal(t, json.Number("4"), rows[0]["n"])
+
+	// sqlite3 errors are returned
+	require.Error(t, SQLite(t.TempDir(), d))
+}
+
+// EOF: "sqlite_test.go"
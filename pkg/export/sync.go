@@ -0,0 +1,409 @@
+// File: "sync.go"
+
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/azorg/gousers/v2/pkg/utmp"
+)
+
+// Дополнительная схема базы данных для синхронизации (см. Sync()),
+// применяется вместе с SQLITE_SCHEMA.
+//
+// Таблица `records` - записи wtmp как есть (time - Unix time в секундах,
+// usec - микросекунды), повторный импорт записи игнорируется:
+//
+//	host, time, usec, type, pid, line, id, user, remote_host, ip,
+//	session, termination, exit
+//
+// Таблица `sync_files` - состояние синхронизации файла узла: inode,
+// смещение после последней импортированной записи, время первой записи
+// файла (мкс, для обнаружения ротации и усечения) и время последней
+// импортированной записи (мкс):
+//
+//	host, path, inode, offset, first, last, synced
+//
+// Сеансы (таблица `sessions`) уникальны по (host, tty, login, user):
+// активные сеансы обновляются после выхода пользователя. Дубликаты,
+// оставленные ранее выгрузкой в ту же базу (см. SQLite()), удаляются
+// перед созданием уникального индекса (остаётся завершённый сеанс).
+//
+// Sync schema.
+const SYNC_SCHEMA = `
+CREATE TABLE IF NOT EXISTS records (
+  host        TEXT NOT NULL,
+  time        INTEGER NOT NULL,
+  usec        INTEGER NOT NULL,
+  type        TEXT NOT NULL,
+  pid         INTEGER NOT NULL,
+  line        TEXT NOT NULL,
+  id          TEXT NOT NULL,
+  user        TEXT NOT NULL,
+  remote_host TEXT NOT NULL,
+  ip          TEXT NOT NULL,
+  session     INTEGER NOT NULL,
+  termination INTEGER NOT NULL,
+  exit        INTEGER NOT NULL,
+  UNIQUE (host, time, usec, type, pid, line, id, user)
+);
+CREATE INDEX IF NOT EXISTS records_time ON records (time);
+
+CREATE TABLE IF NOT EXISTS sync_files (
+  host   TEXT NOT NULL,
+  path   TEXT NOT NULL,
+  inode  INTEGER NOT NULL,
+  offset INTEGER NOT NULL,
+  first  INTEGER NOT NULL,
+  last   INTEGER NOT NULL,
+  synced INTEGER NOT NULL,
+  PRIMARY KEY (host, path)
+);
+
+DELETE FROM sessions WHERE NOT EXISTS (
+  SELECT 1 FROM sqlite_master WHERE type = 'index' AND name = 'sessions_key'
+) AND rowid IN (
+  SELECT rowid FROM (
+    SELECT rowid, row_number() OVER (
+      PARTITION BY host, tty, login, user
+      ORDER BY end_type = 'active', rowid DESC) AS n
+    FROM sessions)
+  WHERE n > 1);
+CREATE UNIQUE INDEX IF NOT EXISTS sessions_key ON sessions (host, tty, login, user);
+`
+
+// Опции синхронизации.
+// Sync options.
+type SyncOpts struct {
+	Host string // Hostname ("" - os.Hostname())
+}
+
+// Результат синхронизации.
+// Sync result.
+type SyncResult struct {
+	Records   int  // Records read (already imported are ignored)
+	Sessions  int  // Sessions inserted or updated
+	Rotated   bool // File is rotated since last sync
+	Truncated bool // File is truncated since last sync
+}
+
+// Состояние синхронизации файла.
+type syncState struct {
+	Inode  uint64 `json:"inode"`
+	Offset int64  `json:"offset"`
+	First  int64  `json:"first"` // usec
+	Last   int64  `json:"last"`  // usec
+}
+
+// Инкрементально импортировать записи и сеансы wtmp файла в базу данных
+// SQLite (с помощью программы sqlite3): читаются только записи после
+// смещения прошлой синхронизации. Если файл заменён ротацией (другое
+// время первой записи или inode), сначала дочитывается хвост прежнего
+// файла среди файлов ротации (в т.ч. сжатых), затем новый файл с начала;
+// усечённый файл читается с начала. Сеансы сопоставляются только по
+// новым записям, начиная с активных сеансов узла из базы (см. Pairer).
+// Записи, сеансы и новое состояние пишутся одной транзакцией, повторный
+// запуск не создаёт дубликатов.
+// Incrementally import wtmp records and sessions to SQLite database.
+func Sync(dbname, fname string, opts SyncOpts) (SyncResult, error) {
+	var res SyncResult
+	if opts.Host == "" {
+		opts.Host, _ = os.Hostname()
+	}
+	path, err := filepath.Abs(fname)
+	if err != nil {
+		return res, err
+	}
+	err = execSQLite(dbname, strings.NewReader(SQLITE_SCHEMA+SYNC_SCHEMA))
+	if err != nil {
+		return res, err
+	}
+
+	var states []syncState
+	err = queryJSON(dbname, fmt.Sprintf(
+		"SELECT inode, offset, first, last FROM sync_files WHERE host = %s AND path = %s;",
+		sqlStr(opts.Host), sqlStr(path)), &states)
+	if err != nil {
+		return res, err
+	}
+	prev := syncState{}
+	if len(states) != 0 {
+		prev = states[0]
+	}
+
+	pr := utmp.NewPairer()
+	active, err := QuerySessions(dbname, SessionFilter{Host: opts.Host, Active: true})
+	if err != nil {
+		return res, err
+	}
+	for _, s := range active {
+		pr.Restore(s.Session)
+	}
+
+	fi, err := os.Stat(fname)
+	if err != nil {
+		return res, err
+	}
+	cur := syncState{}
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		cur.Inode = st.Ino
+	}
+	first, err := firstRecord(fname)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return res, err
+	}
+	cur.First = first
+
+	var script bytes.Buffer
+	w := bufio.NewWriter(&script)
+	fmt.Fprintln(w, "BEGIN;")
+
+	offset := prev.Offset
+	cur.Last = prev.Last
+	if prev.First != 0 && (prev.First != cur.First || prev.Inode != cur.Inode) {
+		res.Rotated = true
+		for _, f := range rotatedFiles(fname) {
+			if t, err := firstRecord(f); err == nil && t == prev.First {
+				_, n, last, err := syncRecords(w, pr, opts.Host, f, prev.Offset)
+				if err != nil {
+					return res, err
+				}
+				res.Records += n
+				cur.Last = max(cur.Last, last)
+				break
+			}
+		}
+		offset = 0
+	} else if fi.Size() < offset {
+		res.Truncated = true
+		offset = 0
+	}
+
+	off, n, last, err := syncRecords(w, pr, opts.Host, fname, offset)
+	if err != nil {
+		return res, err
+	}
+	res.Records += n
+	cur.Offset, cur.Last = off, max(cur.Last, last)
+
+	sessions := pr.Sessions()
+	for i := range sessions {
+		writeSession(w, opts.Host, &sessions[i])
+	}
+	res.Sessions = len(sessions)
+
+	fmt.Fprintf(w, "INSERT OR REPLACE INTO sync_files VALUES (%s, %s, %d, %d, %d, %d, %d);\n",
+		sqlStr(opts.Host), sqlStr(path), cur.Inode, cur.Offset, cur.First, cur.Last, time.Now().Unix())
+	fmt.Fprintln(w, "COMMIT;")
+	if err = w.Flush(); err != nil {
+		return res, err
+	}
+	return res, execSQLite(dbname, &script)
+}
+
+// Время первой записи файла (мкс), io.EOF для пустого файла.
+func firstRecord(fname string) (int64, error) {
+	f, err := utmp.Open(fname)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	var u utmp.Utmp
+	if err = utmp.Read(f, &u); err != nil {
+		return 0, err
+	}
+	return utmp.Time(u.TV).UnixMicro(), nil
+}
+
+// Файлы ротации fname (новые первыми, без самого fname).
+func rotatedFiles(fname string) []string {
+	files, _ := utmp.FindRotated(fname)
+	var list []string
+	for i := len(files) - 1; i >= 0; i-- {
+		if filepath.Clean(files[i]) != filepath.Clean(fname) {
+			list = append(list, files[i])
+		}
+	}
+	return list
+}
+
+// Записать INSERT записей файла начиная со смещения offset (только
+// целые записи) и передать записи в pr. Возвращает смещение после
+// последней целой записи, число записей и время последней записи (мкс).
+func syncRecords(w io.Writer, pr *utmp.Pairer, host, fname string, offset int64) (off int64, n int, last int64, err error) {
+	f, err := utmp.Open(fname) // compressed rotated file too
+	if err != nil {
+		return offset, 0, 0, err
+	}
+	defer f.Close()
+	if rs, ok := f.(io.Seeker); ok {
+		_, err = rs.Seek(offset, io.SeekStart)
+	} else {
+		_, err = io.CopyN(io.Discard, f, offset)
+	}
+	if err != nil {
+		return offset, 0, 0, err
+	}
+
+	h := sqlStr(host)
+	r := bufio.NewReaderSize(f, utmp.READ_BUF_SIZE)
+	buf := make([]byte, utmp.RECORD_SIZE)
+	var u utmp.Utmp
+	for off = offset; ; off += utmp.RECORD_SIZE {
+		if _, err = io.ReadFull(r, buf); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				err = nil // incomplete record is read on next sync
+			}
+			return off, n, last, err
+		}
+		if utmp.DecodeUtmp(buf, &u) != nil || u.IsEmpty() {
+			continue // skip corrupted record
+		}
+		rec := u.Decode()
+		fmt.Fprintf(w, "INSERT OR IGNORE INTO records VALUES (%s, %d, %d, %s, %d, %s, %s, %s, %s, %s, %d, %d, %d);\n",
+			h, rec.Time.Unix(), rec.Time.Nanosecond()/1000, sqlStr(rec.TypeName), rec.PID,
+			sqlStr(rec.Line), sqlStr(rec.ID), sqlStr(rec.User), sqlStr(rec.Host), sqlIP(rec.IP),
+			rec.Session, rec.Termination, rec.Exit)
+		pr.Add(&u)
+		n++
+		last = max(last, rec.Time.UnixMicro())
+	}
+}
+
+// Записать сеанс (вставка или обновление активного сеанса).
+func writeSession(w io.Writer, host string, s *utmp.Session) {
+	logout, duration := "NULL", "NULL"
+	if !s.Logout.IsZero() {
+		logout = fmt.Sprint(s.Logout.Unix())
+		duration = fmt.Sprint(int64(s.Logout.Sub(s.Login) / time.Second))
+	}
+	fmt.Fprintf(w, "INSERT INTO sessions VALUES (%s, %s, %s, %s, %d, %s, %s, %d, %s, %s, %s)"+
+		" ON CONFLICT (host, tty, login, user) DO UPDATE SET"+
+		" logout = excluded.logout, duration = excluded.duration, end_type = excluded.end_type"+
+		" WHERE sessions.end_type = 'active';\n",
+		sqlStr(host), sqlStr(s.User), sqlStr(s.TTY), sqlStr(s.ID), s.PID,
+		sqlStr(s.Host), sqlIP(s.IP), s.Login.Unix(), logout, duration,
+		sqlStr(s.End.String()))
+}
+
+// Выполнить SQL запрос (только чтение) и получить строки результата
+// как JSON объекты "столбец: значение" (целые числа - json.Number).
+// Run read-only SQL query.
+func Query(dbname, query string) ([]map[string]any, error) {
+	var rows []map[string]any
+	err := queryJSON(dbname, query, &rows)
+	return rows, err
+}
+
+// Фильтр сеансов QuerySessions().
+// Filter of stored sessions.
+type SessionFilter struct {
+	Host   string    // Hostname ("" - all hosts)
+	User   string    // Username ("" - all users)
+	Since  time.Time // Sessions active after time (zero - no limit)
+	Until  time.Time // Sessions started before time (zero - no limit)
+	Active bool      // Active sessions only
+}
+
+// Сохранённый сеанс узла.
+// Stored session.
+type HostSession struct {
+	Host string // Hostname
+	utmp.Session
+}
+
+// Прочитать сеансы из базы данных (по времени входа).
+// Query stored sessions.
+func QuerySessions(dbname string, f SessionFilter) ([]HostSession, error) {
+	where := []string{"1"}
+	if f.Host != "" {
+		where = append(where, "host = "+sqlStr(f.Host))
+	}
+	if f.User != "" {
+		where = append(where, "user = "+sqlStr(f.User))
+	}
+	if !f.Since.IsZero() {
+		where = append(where, fmt.Sprintf("(logout IS NULL OR logout >= %d)", f.Since.Unix()))
+	}
+	if !f.Until.IsZero() {
+		where = append(where, fmt.Sprintf("login <= %d", f.Until.Unix()))
+	}
+	if f.Active {
+		where = append(where, "end_type = 'active'")
+	}
+
+	var rows []struct {
+		Host   string `json:"host"`
+		User   string `json:"user"`
+		TTY    string `json:"tty"`
+		ID     string `json:"id"`
+		PID    uint32 `json:"pid"`
+		Remote string `json:"remote_host"`
+		IP     string `json:"ip"`
+		Login  int64  `json:"login"`
+		Logout *int64 `json:"logout"`
+		End    string `json:"end_type"`
+	}
+	err := queryJSON(dbname, "SELECT * FROM sessions WHERE "+strings.Join(where, " AND ")+
+		" ORDER BY login;", &rows)
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]HostSession, 0, len(rows))
+	for _, r := range rows {
+		s := HostSession{Host: r.Host, Session: utmp.Session{
+			User:  r.User,
+			TTY:   r.TTY,
+			ID:    r.ID,
+			PID:   r.PID,
+			Host:  r.Remote,
+			IP:    net.ParseIP(r.IP),
+			Login: time.Unix(r.Login, 0)}}
+		if r.Logout != nil {
+			s.Logout = time.Unix(*r.Logout, 0)
+		}
+		for e, name := range utmp.SessionEndStr {
+			if name == r.End {
+				s.End = utmp.SessionEnd(e)
+			}
+		}
+		list = append(list, s)
+	}
+	return list, nil
+}
+
+// Выполнить запрос программой sqlite3 (только чтение) и разобрать
+// результат в формате JSON (пустой результат - нет строк).
+func queryJSON(dbname, query string, v any) error {
+	cmd := exec.Command(SQLITE3_CMD, "-bail", "-readonly", "-json", dbname, query)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%s: %w: %s", SQLITE3_CMD, err, msg)
+		}
+		return fmt.Errorf("%s: %w", SQLITE3_CMD, err)
+	}
+	if len(bytes.TrimSpace(out)) == 0 {
+		return nil // no rows
+	}
+	d := json.NewDecoder(bytes.NewReader(out))
+	d.UseNumber()
+	return d.Decode(v)
+}
+
+// EOF: "sync.go"
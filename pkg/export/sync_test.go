@@ -0,0 +1,135 @@
+// File: "sync_test.go"
+
+package export
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/azorg/gousers/v2/pkg/utmp"
+)
+
+// Сеансы узла из базы в виде "user end_type".
+func storedSessions(t *testing.T, db string) []string {
+	list, err := QuerySessions(db, SessionFilter{Host: "h1"})
+	require.NoError(t, err)
+	sessions := []string{}
+	for _, s := range list {
+		sessions = append(sessions, s.User+" "+s.End.String())
+	}
+	return sessions
+}
+
+// Число строк таблицы.
+func countRows(t *testing.T, db, table string) json.Number {
+	rows, err := Query(db, "SELECT count(*) AS n FROM "+table)
+	require.NoError(t, err)
+	return rows[0]["n"].(json.Number)
+}
+
+func TestSync(t *testing.T) {
+	needSQLite(t)
+	dir := t.TempDir()
+	db, wtmp := filepath.Join(dir, "logins.db"), filepath.Join(dir, "wtmp")
+	sync := func() SyncResult {
+		res, err := Sync(db, wtmp, SyncOpts{Host: "h1"})
+		require.NoError(t, err)
+		return res
+	}
+
+	// bob is still logged in
+	writeWtmp(t, wtmp, os.O_APPEND,
+		record(utmp.BOOT_TIME, 0, "~", "reboot", "6.1.0", 1000),
+		record(utmp.USER_PROCESS, 101, "pts/0", "alice", "10.0.0.5", 1010),
+		record(utmp.USER_PROCESS, 102, "pts/1", "bob", "10.0.0.6", 1020),
+		record(utmp.DEAD_PROCESS, 101, "pts/0", "", "", 1100))
+	require.Equal(t, SyncResult{Records: 4, Sessions: 2}, sync())
+	require.Equal(t, []string{"alice logout", "bob active"}, storedSessions(t, db))
+
+	// idempotent: nothing new
+	require.Equal(t, SyncResult{}, sync())
+	require.Equal(t, json.Number("4"), countRows(t, db, "records"))
+	require.Equal(t, json.Number("2"), countRows(t, db, "sessions"))
+
+	// appended: bob logged out (paired with stored session), dave logged in;
+	// incomplete record is read on next sync
+	writeWtmp(t, wtmp, os.O_APPEND,
+		record(utmp.DEAD_PROCESS, 102, "pts/1", "", "", 1400),
+		record(utmp.USER_PROCESS, 104, "tty1", "dave", "", 1430))
+	f, err := os.OpenFile(wtmp, os.O_WRONLY|os.O_APPEND, 0644)
+	require.NoError(t, err)
+	_, err = f.Write(make([]byte, utmp.RECORD_SIZE/2))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	require.Equal(t, SyncResult{Records: 2, Sessions: 2}, sync())
+	require.Equal(t, []string{"alice logout", "bob logout", "dave active"}, storedSessions(t, db))
+	require.NoError(t, os.Truncate(wtmp, 6*utmp.RECORD_SIZE))
+
+	// rotated: tail of rotated file is read, dave logged out in new file
+	writeWtmp(t, wtmp, os.O_APPEND,
+		record(utmp.USER_PROCESS, 105, "pts/2", "erin", "10.0.0.8", 1500))
+	require.NoError(t, os.Rename(wtmp, wtmp+".1"))
+	writeWtmp(t, wtmp, os.O_APPEND,
+		record(utmp.DEAD_PROCESS, 104, "tty1", "", "", 1600),
+		record(utmp.DEAD_PROCESS, 105, "pts/2", "", "", 1620))
+	res := sync()
+	require.True(t, res.Rotated)
+	require.Equal(t, 3, res.Records)
+	require.Equal(t, 2, res.Sessions)
+	require.Equal(t, []string{"alice logout", "bob logout", "dave logout", "erin logout"},
+		storedSessions(t, db))
+	require.Equal(t, SyncResult{}, sync())
+
+	// truncated: file is read from the start
+	require.NoError(t, os.Truncate(wtmp, utmp.RECORD_SIZE))
+	require.Equal(t, SyncResult{Records: 1, Truncated: true}, sync())
+	require.Equal(t, json.Number("9"), countRows(t, db, "records"))
+
+	// lost state: records and sessions are not duplicated
+	require.NoError(t, execSQLite(db, strings.NewReader("DELETE FROM sync_files;")))
+	writeWtmp(t, wtmp, os.O_TRUNC,
+		record(utmp.USER_PROCESS, 106, "pts/0", "frank", "10.0.0.9", 1810))
+	require.Equal(t, SyncResult{Records: 1, Sessions: 1}, sync())
+	require.NoError(t, execSQLite(db, strings.NewReader("DELETE FROM sync_files;")))
+	require.Equal(t, SyncResult{Records: 1}, sync())
+	require.Equal(t, json.Number("5"), countRows(t, db, "sessions"))
+	require.Equal(t, json.Number("10"), countRows(t, db, "records"))
+}
+
+func TestSyncExported(t *testing.T) {
+	needSQLite(t)
+	dir := t.TempDir()
+	db, wtmp := filepath.Join(dir, "logins.db"), filepath.Join(dir, "wtmp")
+	writeWtmp(t, wtmp, os.O_APPEND,
+		record(utmp.USER_PROCESS, 101, "pts/0", "alice", "10.0.0.5", 1010),
+		record(utmp.DEAD_PROCESS, 101, "pts/0", "", "", 1100),
+		record(utmp.USER_PROCESS, 102, "pts/1", "bob", "10.0.0.6", 1120))
+
+	// duplicates of incremental exports
+	d, err := Load(wtmp, "", utmp.GetUsersOpts{})
+	require.NoError(t, err)
+	d.Host = "h1"
+	require.NoError(t, SQLite(db, d))
+	d.Incremental = true
+	require.NoError(t, SQLite(db, d))
+	require.Equal(t, json.Number("4"), countRows(t, db, "sessions"))
+
+	// duplicates are removed, bob's session is continued
+	writeWtmp(t, wtmp, os.O_APPEND,
+		record(utmp.DEAD_PROCESS, 102, "pts/1", "", "", 1200))
+	res, err := Sync(db, wtmp, SyncOpts{Host: "h1"})
+	require.NoError(t, err)
+	require.Equal(t, SyncResult{Records: 4, Sessions: 2}, res)
+	require.Equal(t, []string{"alice logout", "bob logout"}, storedSessions(t, db))
+
+	// export to synced database replaces sessions
+	require.NoError(t, SQLite(db, d))
+	require.Equal(t, []string{"alice logout", "bob active"}, storedSessions(t, db))
+}
+
+// EOF: "sync_test.go"
@@ -0,0 +1,263 @@
+// File: "faillock.go"
+
+/*
+Пакет `faillock` - чтение счётчиков неудачных попыток входа pam_faillock
+(каталог /var/run/faillock, файл на пользователя) и устаревшего faillog
+(/var/log/faillog, pam_tally/shadow): последние неудачные попытки и
+признак блокировки учётной записи.
+
+	cfg, _ := faillock.ReadConfig(faillock.FAILLOCK_CONF)
+	st, err := cfg.Status("alice", time.Now())
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(st.Count, st.Locked, st.Until)
+
+Package faillock reads pam_faillock tally files and legacy faillog.
+*/
+package faillock
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Файлы pam_faillock.
+// Files of pam_faillock.
+const (
+	FAILLOCK_DIR  = "/var/run/faillock"           // Tally directory
+	FAILLOCK_CONF = "/etc/security/faillock.conf" // Configuration
+)
+
+// Параметры pam_faillock по умолчанию.
+// Default pam_faillock parameters.
+const (
+	DENY          = 3                // Failures to lock account
+	FAIL_INTERVAL = 15 * time.Minute // Interval of counted failures
+	UNLOCK_TIME   = 10 * time.Minute // Lock time (0 - until reset)
+)
+
+// Источники данных о неудачных попытках (Status.Source).
+// Sources of failure status.
+const (
+	SOURCE_FAILLOCK = "faillock"
+	SOURCE_FAILLOG  = "faillog"
+)
+
+// Запись tally файла pam_faillock (struct tally): источник (52 байта),
+// резерв, флаги состояния, время (uint64).
+const (
+	tallySourceLen = 52
+	tallySize      = tallySourceLen + 2 + 2 + 8
+)
+
+// Флаги состояния записи tally.
+const (
+	tallyValid   = 0x1 // counted for lockout
+	tallyRHost   = 0x2 // source is remote host
+	tallyTTY     = 0x4 // source is TTY
+	tallyService = 0x8 // source is PAM service
+)
+
+// Типы источника неудачной попытки (Failure.Type).
+// Failure source types.
+const (
+	TYPE_RHOST   = "rhost"
+	TYPE_TTY     = "tty"
+	TYPE_SERVICE = "svc"
+)
+
+// Неудачная попытка входа.
+// Failed login attempt.
+type Failure struct {
+	Time   time.Time // Time of failure
+	Source string    // Remote host, TTY or PAM service
+	Type   string    // Source type (see TYPE_*)
+	Valid  bool      // Counted for lockout
+}
+
+// Конфигурация pam_faillock (faillock.conf).
+// Configuration of pam_faillock.
+type Config struct {
+	Dir            string        // Tally directory
+	Deny           int           // Failures to lock account (0 - disabled)
+	FailInterval   time.Duration // Interval of counted failures
+	UnlockTime     time.Duration // Lock time (0 - until reset)
+	EvenDenyRoot   bool          // Lock root account too
+	RootUnlockTime time.Duration // Lock time of root (with EvenDenyRoot)
+}
+
+// Состояние неудачных попыток входа пользователя.
+// Failure status of user.
+type Status struct {
+	User     string    // Username
+	Source   string    // SOURCE_FAILLOCK or SOURCE_FAILLOG
+	Failures []Failure // Recorded failures (by time)
+	Count    int       // Failures counted for lockout (in FailInterval)
+	Locked   bool      // Account is locked
+	Until    time.Time // End of lock (zero - until reset)
+}
+
+// Конфигурация pam_faillock по умолчанию.
+// Default configuration.
+func DefaultConfig() Config {
+	return Config{
+		Dir:            FAILLOCK_DIR,
+		Deny:           DENY,
+		FailInterval:   FAIL_INTERVAL,
+		UnlockTime:     UNLOCK_TIME,
+		RootUnlockTime: UNLOCK_TIME,
+	}
+}
+
+// Прочитать faillock.conf ("ключ = значение", комментарии "#"); при
+// отсутствии файла возвращается конфигурация по умолчанию.
+// Read faillock.conf.
+func ReadConfig(fname string) (Config, error) {
+	cfg := DefaultConfig()
+	f, err := os.Open(fname)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	} else if err != nil {
+		return cfg, err
+	}
+	defer f.Close()
+	err = cfg.Parse(f)
+	return cfg, err
+}
+
+// Разобрать faillock.conf поверх текущих значений.
+// Parse faillock.conf.
+func (c *Config) Parse(r io.Reader) error {
+	rootUnlock := false
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line, _, _ := strings.Cut(s.Text(), "#")
+		key, val, _ := strings.Cut(line, "=")
+		key, val = strings.TrimSpace(key), strings.TrimSpace(val)
+		var err error
+		switch key {
+		case "dir":
+			c.Dir = val
+		case "deny":
+			c.Deny, err = strconv.Atoi(val)
+		case "fail_interval":
+			c.FailInterval, err = seconds(val)
+		case "unlock_time":
+			c.UnlockTime, err = seconds(val)
+		case "root_unlock_time":
+			c.RootUnlockTime, err = seconds(val)
+			rootUnlock = true
+		case "even_deny_root":
+			c.EvenDenyRoot = true
+		}
+		if err != nil {
+			return fmt.Errorf("faillock.conf:%d: bad %s: %w", n, key, err)
+		}
+	}
+	if !rootUnlock {
+		c.RootUnlockTime = c.UnlockTime
+	}
+	return s.Err()
+}
+
+// Время в секундах ("never" - 0, без ограничения).
+func seconds(s string) (time.Duration, error) {
+	if s == "never" {
+		return 0, nil
+	}
+	n, err := strconv.ParseUint(s, 10, 32)
+	return time.Duration(n) * time.Second, err
+}
+
+// Разобрать tally файл pam_faillock.
+// Parse pam_faillock tally file.
+func ReadTally(r io.Reader) ([]Failure, error) {
+	var list []Failure
+	buf := make([]byte, tallySize)
+	for {
+		_, err := io.ReadFull(r, buf)
+		if err == io.EOF {
+			return list, nil
+		} else if err != nil {
+			return list, err
+		}
+		status := binary.NativeEndian.Uint16(buf[tallySourceLen+2:])
+		sec := binary.NativeEndian.Uint64(buf[tallySourceLen+4:])
+		src, _, _ := bytes.Cut(buf[:tallySourceLen], []byte{0})
+		f := Failure{
+			Time:   time.Unix(int64(sec), 0),
+			Source: string(src),
+			Valid:  status&tallyValid != 0}
+		switch {
+		case status&tallyRHost != 0:
+			f.Type = TYPE_RHOST
+		case status&tallyTTY != 0:
+			f.Type = TYPE_TTY
+		case status&tallyService != 0:
+			f.Type = TYPE_SERVICE
+		}
+		list = append(list, f)
+	}
+}
+
+// Прочитать tally файл пользователя из каталога c.Dir и определить
+// блокировку как pam_faillock: учётная запись заблокирована, если число
+// действительных записей за FailInterval до последней неудачи не меньше
+// Deny и с последней неудачи не прошло UnlockTime (root - только при
+// EvenDenyRoot). Файла нет - неудач нет.
+// Get failure status of user.
+func (c Config) Status(user string, now time.Time) (Status, error) {
+	st := Status{User: user, Source: SOURCE_FAILLOCK}
+	if user == "" || strings.Contains(user, "/") || user == "." || user == ".." {
+		return st, fmt.Errorf("bad username '%s'", user)
+	}
+	f, err := os.Open(filepath.Join(c.Dir, user))
+	if errors.Is(err, os.ErrNotExist) {
+		return st, nil
+	} else if err != nil {
+		return st, err
+	}
+	defer f.Close()
+	if st.Failures, err = ReadTally(f); err != nil {
+		return st, err
+	}
+
+	var latest time.Time
+	for _, fl := range st.Failures {
+		if fl.Valid && fl.Time.After(latest) {
+			latest = fl.Time
+		}
+	}
+	for _, fl := range st.Failures {
+		if fl.Valid && latest.Sub(fl.Time) < c.FailInterval {
+			st.Count++
+		}
+	}
+	unlock := c.UnlockTime
+	if user == "root" {
+		if !c.EvenDenyRoot {
+			return st, nil
+		}
+		unlock = c.RootUnlockTime
+	}
+	if c.Deny > 0 && st.Count >= c.Deny {
+		if unlock == 0 {
+			st.Locked = true // until reset by `faillock --reset`
+		} else if until := latest.Add(unlock); until.After(now) {
+			st.Locked, st.Until = true, until
+		}
+	}
+	return st, nil
+}
+
+// EOF: "faillock.go"
@@ -0,0 +1,150 @@
+// File: "faillock_test.go"
+
+package faillock
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Запись tally файла.
+func tally(source string, status uint16, t time.Time) []byte {
+	buf := make([]byte, tallySize)
+	copy(buf, source)
+	binary.NativeEndian.PutUint16(buf[tallySourceLen+2:], status)
+	binary.NativeEndian.PutUint64(buf[tallySourceLen+4:], uint64(t.Unix()))
+	return buf
+}
+
+func TestConfig(t *testing.T) {
+	cfg := DefaultConfig()
+	require.NoError(t, cfg.Parse(strings.NewReader(`
+# comment
+dir = /run/faillock
+deny = 5 # comment
+unlock_time = never
+even_deny_root
+`)))
+	require.Equal(t, "/run/faillock", cfg.Dir)
+	require.Equal(t, 5, cfg.Deny)
+	require.Equal(t, time.Duration(0), cfg.UnlockTime)
+	require.Equal(t, time.Duration(0), cfg.RootUnlockTime)
+	require.True(t, cfg.EvenDenyRoot)
+
+	require.Error(t, cfg.Parse(strings.NewReader("deny = x")))
+
+	cfg, err := ReadConfig(filepath.Join(t.TempDir(), "none.conf"))
+	require.NoError(t, err)
+	require.Equal(t, DefaultConfig(), cfg)
+}
+
+func TestStatus(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Unix(1700000000, 0)
+	var data []byte
+	data = append(data, tally("10.0.0.5", tallyValid|tallyRHost, now.Add(-time.Hour))...)
+	data = append(data, tally("sshd", tallyService, now.Add(-50*time.Second))...) // not valid
+	data = append(data, tally("10.0.0.5", tallyValid|tallyRHost, now.Add(-3*time.Minute))...)
+	data = append(data, tally("pts/1", tallyValid|tallyTTY, now.Add(-2*time.Minute))...)
+	data = append(data, tally("10.0.0.6", tallyValid|tallyRHost, now.Add(-time.Minute))...)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "alice"), data, 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "root"), data, 0600))
+
+	failures, err := ReadTally(bytes.NewReader(data))
+	require.NoError(t, err)
+	require.Len(t, failures, 5)
+	require.Equal(t, Failure{Time: now.Add(-2 * time.Minute), Source: "pts/1", Type: TYPE_TTY, Valid: true}, failures[3])
+	require.Equal(t, TYPE_SERVICE, failures[1].Type)
+	require.False(t, failures[1].Valid)
+	_, err = ReadTally(bytes.NewReader(data[:tallySize+1]))
+	require.Error(t, err)
+
+	cfg := DefaultConfig()
+	cfg.Dir = dir
+	st, err := cfg.Status("alice", now)
+	require.NoError(t, err)
+	require.Equal(t, SOURCE_FAILLOCK, st.Source)
+	require.Len(t, st.Failures, 5)
+	require.Equal(t, 3, st.Count) // one hour old failure is out of interval
+	require.True(t, st.Locked)
+	require.Equal(t, now.Add(9*time.Minute), st.Until)
+
+	st, err = cfg.Status("alice", now.Add(10*time.Minute))
+	require.NoError(t, err)
+	require.False(t, st.Locked) // unlocked by time
+
+	st, err = cfg.Status("root", now)
+	require.NoError(t, err)
+	require.False(t, st.Locked) // no even_deny_root
+	cfg.EvenDenyRoot, cfg.RootUnlockTime = true, 0
+	st, err = cfg.Status("root", now.Add(time.Hour))
+	require.NoError(t, err)
+	require.True(t, st.Locked)
+	require.True(t, st.Until.IsZero()) // until reset
+
+	st, err = cfg.Status("bob", now)
+	require.NoError(t, err)
+	require.Empty(t, st.Failures)
+	require.False(t, st.Locked)
+
+	_, err = cfg.Status("../alice", now)
+	require.Error(t, err)
+}
+
+func TestFaillog(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	word := strconv.IntSize / 8
+	entry := func(cnt, max int16, line string, t time.Time, lock int64) []byte {
+		buf := make([]byte, faillogSize)
+		binary.NativeEndian.PutUint16(buf[0:], uint16(cnt))
+		binary.NativeEndian.PutUint16(buf[2:], uint16(max))
+		copy(buf[4:4+faillogLine], line)
+		sec := int64(0)
+		if !t.IsZero() {
+			sec = t.Unix()
+		}
+		if word == 8 {
+			binary.NativeEndian.PutUint64(buf[4+faillogLine:], uint64(sec))
+			binary.NativeEndian.PutUint64(buf[4+faillogLine+word:], uint64(lock))
+		} else {
+			binary.NativeEndian.PutUint32(buf[4+faillogLine:], uint32(sec))
+			binary.NativeEndian.PutUint32(buf[4+faillogLine+word:], uint32(lock))
+		}
+		return buf
+	}
+	var data []byte
+	data = append(data, entry(0, 0, "", time.Time{}, 0)...)
+	data = append(data, entry(4, 3, "pts/2", now.Add(-time.Minute), 300)...)
+	fname := filepath.Join(t.TempDir(), "faillog")
+	require.NoError(t, os.WriteFile(fname, data, 0600))
+
+	e, err := ReadFaillog(fname, 1)
+	require.NoError(t, err)
+	require.Equal(t, FaillogEntry{Count: 4, Max: 3, Line: "pts/2",
+		Time: now.Add(-time.Minute), LockTime: 5 * time.Minute}, e)
+	st := e.Status("alice", now)
+	require.Equal(t, SOURCE_FAILLOG, st.Source)
+	require.Equal(t, 4, st.Count)
+	require.True(t, st.Locked)
+	require.Equal(t, now.Add(4*time.Minute), st.Until)
+	require.False(t, e.Status("alice", now.Add(time.Hour)).Locked)
+
+	e, err = ReadFaillog(fname, 0)
+	require.NoError(t, err)
+	require.Equal(t, FaillogEntry{}, e)
+	require.Empty(t, e.Status("root", now).Failures)
+
+	e, err = ReadFaillog(fname, 1000) // beyond end of file
+	require.NoError(t, err)
+	require.Equal(t, FaillogEntry{}, e)
+}
+
+// EOF: "faillock_test.go"
@@ -0,0 +1,96 @@
+// File: "faillog.go"
+
+package faillock
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Устаревший файл неудачных попыток (faillog(5), индекс - UID).
+// Legacy faillog file.
+const FAILLOG = "/var/log/faillog"
+
+// Запись faillog (struct faillog): fail_cnt, fail_max (short),
+// fail_line[12], fail_time (time_t), fail_locktime (long).
+const faillogLine = 12
+
+// Размер записи faillog (time_t и long - размер слова платформы).
+var faillogSize = 2 + 2 + faillogLine + 2*strconv.IntSize/8
+
+// Запись faillog пользователя.
+// Faillog entry.
+type FaillogEntry struct {
+	Count    int           // Failures since last successful login
+	Max      int           // Failures to lock account (0 - no limit)
+	Line     string        // TTY or host of last failure
+	Time     time.Time     // Time of last failure (zero - none)
+	LockTime time.Duration // Lock time after last failure (0 - until reset)
+}
+
+// Прочитать запись faillog пользователя по UID (за концом файла -
+// пустая запись).
+// Read faillog entry by UID.
+func ReadFaillog(fname string, uid int) (FaillogEntry, error) {
+	var e FaillogEntry
+	f, err := os.Open(fname)
+	if err != nil {
+		return e, err
+	}
+	defer f.Close()
+
+	buf := make([]byte, faillogSize)
+	_, err = f.ReadAt(buf, int64(uid)*int64(faillogSize))
+	if errors.Is(err, io.EOF) {
+		return e, nil
+	} else if err != nil {
+		return e, err
+	}
+	return parseFaillog(buf), nil
+}
+
+// Разобрать запись faillog.
+func parseFaillog(buf []byte) FaillogEntry {
+	word := func(b []byte) int64 {
+		if len(b) == 8 {
+			return int64(binary.NativeEndian.Uint64(b))
+		}
+		return int64(int32(binary.NativeEndian.Uint32(b)))
+	}
+	n := (len(buf) - 4 - faillogLine) / 2
+	line, _, _ := bytes.Cut(buf[4:4+faillogLine], []byte{0})
+	e := FaillogEntry{
+		Count:    int(int16(binary.NativeEndian.Uint16(buf[0:]))),
+		Max:      int(int16(binary.NativeEndian.Uint16(buf[2:]))),
+		Line:     string(line),
+		LockTime: time.Duration(word(buf[4+faillogLine+n:])) * time.Second}
+	if sec := word(buf[4+faillogLine : 4+faillogLine+n]); sec != 0 {
+		e.Time = time.Unix(sec, 0)
+	}
+	return e
+}
+
+// Состояние неудачных попыток по записи faillog: блокировка, если
+// Count >= Max и не истекло LockTime после последней неудачи.
+// Failure status from faillog entry.
+func (e FaillogEntry) Status(user string, now time.Time) Status {
+	st := Status{User: user, Source: SOURCE_FAILLOG, Count: e.Count}
+	if e.Count != 0 && !e.Time.IsZero() {
+		st.Failures = []Failure{{Time: e.Time, Source: e.Line, Valid: true}}
+	}
+	if e.Max > 0 && e.Count >= e.Max {
+		if e.LockTime == 0 {
+			st.Locked = true
+		} else if until := e.Time.Add(e.LockTime); until.After(now) {
+			st.Locked, st.Until = true, until
+		}
+	}
+	return st
+}
+
+// EOF: "faillog.go"
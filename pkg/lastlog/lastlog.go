@@ -0,0 +1,112 @@
+// File: "lastlog.go"
+
+/*
+Пакет `lastlog` - время последнего входа пользователей: база lastlog2
+(SQLite, Fedora/openSUSE и др.; читается программой sqlite3, см.
+export.SQLITE3_CMD) или устаревший файл lastlog (индекс - UID).
+
+	e, err := lastlog.Get("", "alice", 1000)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(e.Source, e.Time, e.Line, e.Host)
+
+Package lastlog reads last login times from lastlog2 database or legacy
+lastlog file.
+*/
+package lastlog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Файлы последних входов.
+// Files of last logins.
+const (
+	LASTLOG  = "/var/log/lastlog"             // Legacy lastlog(8) file
+	LASTLOG2 = "/var/lib/lastlog/lastlog2.db" // lastlog2(8) database
+)
+
+// Источники данных о последнем входе (Entry.Source).
+// Sources of last login.
+const (
+	SOURCE_LASTLOG  = "lastlog"
+	SOURCE_LASTLOG2 = "lastlog2"
+)
+
+// Запись lastlog (struct lastlog): ll_time (int32), ll_line[32],
+// ll_host[256].
+const (
+	lastlogLine = 32
+	lastlogHost = 256
+	lastlogSize = 4 + lastlogLine + lastlogHost
+)
+
+// Последний вход пользователя.
+// Last login of user.
+type Entry struct {
+	User    string    // Username
+	Time    time.Time // Time of last login (zero - never logged in)
+	Line    string    // TTY
+	Host    string    // Remote host
+	Service string    // PAM service (lastlog2 only)
+	Source  string    // SOURCE_LASTLOG or SOURCE_LASTLOG2
+}
+
+// Прочитать последний вход пользователя: из базы lastlog2, если она
+// есть, иначе из устаревшего lastlog по UID (root - корневой каталог
+// файлов, "" - локальная система). Без базы lastlog2 для неизвестного
+// UID (uid < 0) возвращается os.ErrNotExist.
+// Get last login of user.
+func Get(root, user string, uid int) (Entry, error) {
+	db := filepath.Join(root, LASTLOG2)
+	if _, err := os.Stat(db); err == nil {
+		return ReadLastlog2(db, user)
+	}
+	if uid < 0 {
+		return Entry{User: user, Source: SOURCE_LASTLOG}, os.ErrNotExist
+	}
+	e, err := ReadLastlog(filepath.Join(root, LASTLOG), uid)
+	e.User = user
+	return e, err
+}
+
+// Прочитать запись устаревшего lastlog по UID (за концом файла -
+// пустая запись).
+// Read legacy lastlog entry by UID.
+func ReadLastlog(fname string, uid int) (Entry, error) {
+	e := Entry{Source: SOURCE_LASTLOG}
+	f, err := os.Open(fname)
+	if err != nil {
+		return e, err
+	}
+	defer f.Close()
+
+	buf := make([]byte, lastlogSize)
+	_, err = f.ReadAt(buf, int64(uid)*lastlogSize)
+	if errors.Is(err, io.EOF) {
+		return e, nil
+	} else if err != nil {
+		return e, err
+	}
+	return parseLastlog(buf), nil
+}
+
+// Разобрать запись lastlog.
+func parseLastlog(buf []byte) Entry {
+	line, _, _ := bytes.Cut(buf[4:4+lastlogLine], []byte{0})
+	host, _, _ := bytes.Cut(buf[4+lastlogLine:], []byte{0})
+	e := Entry{Line: string(line), Host: string(host), Source: SOURCE_LASTLOG}
+	if sec := int32(binary.NativeEndian.Uint32(buf)); sec != 0 {
+		e.Time = time.Unix(int64(sec), 0)
+	}
+	return e
+}
+
+// EOF: "lastlog.go"
@@ -0,0 +1,64 @@
+// File: "lastlog2.go"
+
+package lastlog
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/azorg/gousers/v2/pkg/export"
+)
+
+// Запрос записей таблицы Lastlog2 (Name, Time, TTY, RemoteHost, Service).
+const lastlog2Query = "SELECT Name, Time, TTY, RemoteHost, Service FROM Lastlog2"
+
+// Прочитать последний вход пользователя из базы lastlog2 (нет записи -
+// пользователь не входил в систему).
+// Read lastlog2 entry of user.
+func ReadLastlog2(dbname, user string) (Entry, error) {
+	list, err := readLastlog2(dbname,
+		lastlog2Query+" WHERE Name = '"+strings.ReplaceAll(user, "'", "''")+"';")
+	if err != nil || len(list) == 0 {
+		return Entry{User: user, Source: SOURCE_LASTLOG2}, err
+	}
+	return list[0], nil
+}
+
+// Прочитать последние входы всех пользователей из базы lastlog2
+// (по имени пользователя).
+// Read all lastlog2 entries.
+func ReadAllLastlog2(dbname string) ([]Entry, error) {
+	return readLastlog2(dbname, lastlog2Query+" ORDER BY Name;")
+}
+
+// Выполнить запрос к базе lastlog2 (NULL - пустая строка или нулевое
+// время).
+func readLastlog2(dbname, query string) ([]Entry, error) {
+	rows, err := export.Query(dbname, query)
+	if err != nil {
+		return nil, err
+	}
+	str := func(v any) string {
+		s, _ := v.(string)
+		return s
+	}
+	list := make([]Entry, 0, len(rows))
+	for _, row := range rows {
+		e := Entry{
+			User:    str(row["Name"]),
+			Line:    str(row["TTY"]),
+			Host:    str(row["RemoteHost"]),
+			Service: str(row["Service"]),
+			Source:  SOURCE_LASTLOG2}
+		if n, ok := row["Time"].(json.Number); ok {
+			if sec, err := n.Int64(); err == nil && sec != 0 {
+				e.Time = time.Unix(sec, 0)
+			}
+		}
+		list = append(list, e)
+	}
+	return list, nil
+}
+
+// EOF: "lastlog2.go"
@@ -0,0 +1,122 @@
+// File: "lastlog_test.go"
+
+package lastlog
+
+import (
+	"encoding/binary"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/azorg/gousers/v2/pkg/export"
+)
+
+// Запись lastlog.
+func record(t time.Time, line, host string) []byte {
+	buf := make([]byte, lastlogSize)
+	binary.NativeEndian.PutUint32(buf, uint32(t.Unix()))
+	copy(buf[4:], line)
+	copy(buf[4+lastlogLine:], host)
+	return buf
+}
+
+// Создать базу lastlog2 программой sqlite3.
+func lastlog2(t *testing.T, dbname, sql string) {
+	if _, err := exec.LookPath(export.SQLITE3_CMD); err != nil {
+		t.Skipf("%s is not found", export.SQLITE3_CMD)
+	}
+	require.NoError(t, os.MkdirAll(filepath.Dir(dbname), 0755))
+	cmd := exec.Command(export.SQLITE3_CMD, "-bail", dbname)
+	cmd.Stdin = strings.NewReader(`CREATE TABLE Lastlog2 (Name TEXT PRIMARY KEY,
+  Time INTEGER, TTY TEXT, RemoteHost TEXT, Service TEXT);` + sql)
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(out))
+}
+
+func TestReadLastlog(t *testing.T) {
+	fname := filepath.Join(t.TempDir(), "lastlog")
+	at := time.Unix(1700000000, 0)
+	var data []byte
+	data = append(data, record(time.Unix(0, 0), "", "")...) // root never logged in
+	data = append(data, record(at, "pts/0", "10.0.0.5")...)
+	require.NoError(t, os.WriteFile(fname, data, 0644))
+
+	e, err := ReadLastlog(fname, 1)
+	require.NoError(t, err)
+	require.Equal(t, Entry{Time: at, Line: "pts/0", Host: "10.0.0.5", Source: SOURCE_LASTLOG}, e)
+
+	e, err = ReadLastlog(fname, 0)
+	require.NoError(t, err)
+	require.True(t, e.Time.IsZero())
+
+	e, err = ReadLastlog(fname, 1000) // beyond end of file
+	require.NoError(t, err)
+	require.Equal(t, Entry{Source: SOURCE_LASTLOG}, e)
+
+	_, err = ReadLastlog(filepath.Join(t.TempDir(), "none"), 1)
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestReadLastlog2(t *testing.T) {
+	dbname := filepath.Join(t.TempDir(), "lastlog2.db")
+	lastlog2(t, dbname, `
+INSERT INTO Lastlog2 VALUES ('alice', 1700000000, 'pts/0', '10.0.0.5', 'sshd');
+INSERT INTO Lastlog2 VALUES ('o''hara', 1700000100, 'tty1', NULL, 'login');
+INSERT INTO Lastlog2 VALUES ('bob', NULL, NULL, NULL, NULL);`)
+
+	e, err := ReadLastlog2(dbname, "alice")
+	require.NoError(t, err)
+	require.Equal(t, Entry{User: "alice", Time: time.Unix(1700000000, 0), Line: "pts/0",
+		Host: "10.0.0.5", Service: "sshd", Source: SOURCE_LASTLOG2}, e)
+
+	e, err = ReadLastlog2(dbname, "o'hara")
+	require.NoError(t, err)
+	require.Equal(t, "tty1", e.Line)
+	require.Empty(t, e.Host)
+
+	e, err = ReadLastlog2(dbname, "carol") // never logged in
+	require.NoError(t, err)
+	require.Equal(t, Entry{User: "carol", Source: SOURCE_LASTLOG2}, e)
+
+	list, err := ReadAllLastlog2(dbname)
+	require.NoError(t, err)
+	require.Len(t, list, 3)
+	require.Equal(t, "bob", list[1].User)
+	require.True(t, list[1].Time.IsZero())
+
+	_, err = ReadLastlog2(filepath.Join(t.TempDir(), "none.db"), "alice")
+	require.Error(t, err)
+}
+
+func TestGet(t *testing.T) {
+	root := t.TempDir()
+	at := time.Unix(1700000000, 0)
+	require.NoError(t, os.MkdirAll(filepath.Join(root, filepath.Dir(LASTLOG)), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, LASTLOG),
+		append(record(time.Unix(0, 0), "", ""), record(at, "tty1", "")...), 0644))
+
+	// legacy lastlog
+	e, err := Get(root, "alice", 1)
+	require.NoError(t, err)
+	require.Equal(t, Entry{User: "alice", Time: at, Line: "tty1", Source: SOURCE_LASTLOG}, e)
+	_, err = Get(root, "alice", -1)
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	// lastlog2 database is preferred
+	lastlog2(t, filepath.Join(root, LASTLOG2), `
+INSERT INTO Lastlog2 VALUES ('alice', 1700000100, 'pts/1', '10.0.0.5', 'sshd');`)
+	e, err = Get(root, "alice", -1)
+	require.NoError(t, err)
+	require.Equal(t, SOURCE_LASTLOG2, e.Source)
+	require.Equal(t, "pts/1", e.Line)
+
+	_, err = Get(t.TempDir(), "alice", 1)
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+// EOF: "lastlog_test.go"
@@ -0,0 +1,215 @@
+// Пакет `merge` - объединение записей wtmp/btmp нескольких узлов в один
+// хронологический поток с учётом расхождения системных часов узлов.
+// File: "merge.go"
+package merge
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/azorg/gousers/v2/pkg/utmp"
+)
+
+// Допуск упорядочивания событий разных узлов по умолчанию: события,
+// разница скорректированного времени которых не превышает допуска,
+// помечаются как события с неоднозначным порядком.
+// Default ordering tolerance.
+const DEFAULT_TOLERANCE = 2 * time.Second
+
+// Максимальное расхождение времени загрузок узлов, считающихся одной
+// и той же (например, общее отключение питания) при оценке смещения часов.
+// Max distance between boot records of hosts to be paired.
+var BootWindow = 10 * time.Minute
+
+// Источник записей (wtmp/btmp файл или архив узла).
+// Source of records of one host.
+type Source struct {
+	Host   string        // Hostname
+	File   string        // wtmp/btmp file of host
+	Offset time.Duration // Correction added to record times of host
+	Fixed  bool          // Offset is set explicitly (don't estimate)
+}
+
+// Событие объединённого потока.
+// Event of merged stream.
+type Event struct {
+	utmp.Record               // Original record (Time - by clock of host)
+	Host        string        // Hostname
+	Corrected   time.Time     // Record time corrected by offset of host
+	Offset      time.Duration // Applied clock offset of host
+	Ambiguous   bool          // Order relative to other hosts is ambiguous
+	Estimated   bool          // Offset is estimated by boot records
+	source      int           // index of source
+}
+
+// Разобрать описание источника "host=file" (или "file", тогда имя узла -
+// имя файла без расширения).
+// Parse source as "host=file" or "file".
+func ParseSource(s string) (Source, error) {
+	if s == "" {
+		return Source{}, fmt.Errorf("empty source")
+	}
+	host, file, ok := strings.Cut(s, "=")
+	if !ok {
+		file = s
+		host = strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	}
+	if host == "" || file == "" {
+		return Source{}, fmt.Errorf("bad source '%s' (expected host=file)", s)
+	}
+	return Source{Host: host, File: file}, nil
+}
+
+// Прочитать записи источника (пустые записи пропускаются).
+// Load records of source.
+func Load(src Source, opts utmp.GetUsersOpts) ([]utmp.Record, error) {
+	f, err := opts.Open(src.File)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	recs := []utmp.Record{}
+	in := utmp.NewInterner(0)
+	s := opts.NewScanner(f)
+	s.SkipEmpty = true
+	for s.Scan() {
+		u := s.Record()
+		if opts.InRange(utmp.Time(u.TV)) {
+			recs = append(recs, u.DecodeWith(in))
+		}
+	}
+	if err = s.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", src.File, err)
+	}
+	return recs, nil
+}
+
+// Оценить смещение часов узла относительно опорного узла по записям
+// загрузки системы (BOOT_TIME): загрузки, отстоящие не более чем на
+// BootWindow, считаются одновременными, результат - медиана разностей.
+// Возвращает ok=false, если пар загрузок не найдено.
+// Estimate clock offset of recs relative to ref by boot records.
+func EstimateOffset(ref, recs []utmp.Record) (offset time.Duration, ok bool) {
+	refBoots := boots(ref)
+	if len(refBoots) == 0 {
+		return 0, false
+	}
+
+	diffs := []time.Duration{}
+	for _, t := range boots(recs) {
+		// nearest boot of reference host
+		i := sort.Search(len(refBoots), func(i int) bool {
+			return !refBoots[i].Before(t)
+		})
+		best, found := time.Duration(0), false
+		for _, j := range []int{i - 1, i} {
+			if j < 0 || j >= len(refBoots) {
+				continue
+			}
+			d := refBoots[j].Sub(t)
+			if abs(d) <= BootWindow && (!found || abs(d) < abs(best)) {
+				best, found = d, true
+			}
+		}
+		if found {
+			diffs = append(diffs, best)
+		}
+	}
+	if len(diffs) == 0 {
+		return 0, false
+	}
+
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i] < diffs[j] })
+	return diffs[len(diffs)/2], true
+}
+
+// Объединить записи нескольких узлов в хронологическом порядке.
+// Для узлов без явно заданного смещения (Source.Fixed) смещение часов
+// оценивается по записям загрузки относительно первого источника.
+// События разных узлов, скорректированное время которых отличается
+// не более чем на `tolerance`, помечаются флагом Ambiguous.
+// Merge records of several hosts with clock skew correction.
+func Merge(srcs []Source, opts utmp.GetUsersOpts, tolerance time.Duration) ([]Event, error) {
+	// время окна задано по опорным часам - читать записи с запасом
+	lopts := opts
+	lopts.Since, lopts.Until, lopts.SeekSince = time.Time{}, time.Time{}, false
+
+	all := make([][]utmp.Record, len(srcs))
+	for i, src := range srcs {
+		recs, err := Load(src, lopts)
+		if err != nil {
+			return nil, err
+		}
+		all[i] = recs
+	}
+
+	events := []Event{}
+	for i, src := range srcs {
+		estimated := false
+		if !src.Fixed && i > 0 {
+			if off, ok := EstimateOffset(all[0], all[i]); ok {
+				src.Offset, estimated = off, true
+			}
+		}
+		for _, r := range all[i] {
+			t := r.Time.Add(src.Offset)
+			if !opts.InRange(t) {
+				continue
+			}
+			events = append(events, Event{
+				Record:    r,
+				Host:      src.Host,
+				Corrected: t,
+				Offset:    src.Offset,
+				Estimated: estimated,
+				source:    i})
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Corrected.Before(events[j].Corrected)
+	})
+
+	markAmbiguous(events, tolerance)
+	return events, nil
+}
+
+// Пометить события разных узлов, порядок которых неоднозначен.
+func markAmbiguous(events []Event, tolerance time.Duration) {
+	for i := range events {
+		for j := i - 1; j >= 0; j-- {
+			if events[i].Corrected.Sub(events[j].Corrected) > tolerance {
+				break
+			}
+			if events[i].source != events[j].source {
+				events[i].Ambiguous = true
+				events[j].Ambiguous = true
+			}
+		}
+	}
+}
+
+// Времена загрузки системы (по возрастанию).
+func boots(recs []utmp.Record) []time.Time {
+	times := []time.Time{}
+	for _, r := range recs {
+		if r.Type == utmp.BOOT_TIME {
+			times = append(times, r.Time)
+		}
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	return times
+}
+
+func abs(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+// EOF: "merge.go"
@@ -0,0 +1,44 @@
+// File: "merge_test.go"
+
+package merge
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/azorg/gousers/v2/pkg/utmp"
+)
+
+func rec(typ int, sec int64) utmp.Record {
+	return utmp.Record{Type: typ, Time: time.Unix(sec, 0)}
+}
+
+func TestEstimateOffset(t *testing.T) {
+	ref := []utmp.Record{rec(utmp.BOOT_TIME, 1000), rec(utmp.USER_PROCESS, 1100), rec(utmp.BOOT_TIME, 50000)}
+	recs := []utmp.Record{rec(utmp.BOOT_TIME, 1090), rec(utmp.BOOT_TIME, 50095), rec(utmp.BOOT_TIME, 90000)}
+
+	off, ok := EstimateOffset(ref, recs)
+	require.True(t, ok)
+	require.Equal(t, -90*time.Second, off) // upper median of -95s, -90s
+
+	_, ok = EstimateOffset(ref, recs[2:]) // too far
+	require.False(t, ok)
+}
+
+func TestMarkAmbiguous(t *testing.T) {
+	at := func(sec int64, src int) Event {
+		return Event{Corrected: time.Unix(sec, 0), source: src}
+	}
+	events := []Event{at(10, 0), at(11, 0), at(20, 0), at(21, 1), at(30, 1)}
+	markAmbiguous(events, 2*time.Second)
+
+	flags := []bool{}
+	for _, e := range events {
+		flags = append(flags, e.Ambiguous)
+	}
+	require.Equal(t, []bool{false, false, true, true, false}, flags)
+}
+
+// EOF: "merge_test.go"
@@ -0,0 +1,229 @@
+// File: "metrics.go"
+
+/*
+Пакет `metrics` - метрики вошедших пользователей Prometheus по данным
+`utmp.Login`.
+
+`Collector` реализует prometheus.Collector (его можно зарегистрировать
+в реестре приложения prometheus.Register() рядом с собственными
+метриками) и обработчик net/http для пути /metrics (собственный реестр,
+текстовый формат 0.0.4 или согласованный с клиентом). Статические метки
+экземпляра службы (utmp.Labels()) добавляются к каждой серии.
+
+Метрики:
+
+	gousers_logged_users{type}               - вошедшие пользователи по типу
+	                                           входа (без root)
+	gousers_logged_users_all                 - всего вошедших (включая root)
+	gousers_root_logged{where}               - вошёл root (local, remote)
+	gousers_logins_total                     - число входов (счётчик)
+	gousers_logouts_total                    - число выходов (счётчик)
+	gousers_last_login_timestamp_seconds{user}
+	                                         - время последнего входа
+	gousers_events_dropped_total             - отброшенные события
+
+Package metrics exports logged user metrics to Prometheus.
+*/
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/common/expfmt"
+
+	"github.com/azorg/gousers/v2/pkg/utmp"
+)
+
+// Тип содержимого (Prometheus text exposition format, обработчик может
+// добавить параметры, например "escaping").
+// Content type of metrics.
+const CONTENT_TYPE = "text/plain; version=0.0.4; charset=utf-8"
+
+// Сборщик метрик: подписывается на события `Login` при создании
+// (подписка завершается при Close() службы). Метки экземпляра берутся
+// при создании сборщика.
+// Metrics collector.
+type Collector struct {
+	l       *utmp.Login
+	mx      sync.Mutex           // мьютекс для защиты счётчиков
+	logins  uint64               // число входов
+	logouts uint64               // число выходов
+	last    map[string]time.Time // время последнего входа по пользователям
+	reg     *prometheus.Registry // реестр для ServeHTTP() и WriteTo()
+	handler http.Handler         // обработчик GET /metrics
+
+	// Описания метрик
+	logged, loggedAll, root, loginsDesc, logoutsDesc, lastLogin, dropped *prometheus.Desc
+}
+
+// Создать сборщик метрик службы l.
+// Create collector.
+func NewCollector(l *utmp.Login) *Collector {
+	c := &Collector{l: l, last: make(map[string]time.Time)}
+	labels := instanceLabels()
+	desc := func(name, help string, variable ...string) *prometheus.Desc {
+		constLabels := prometheus.Labels{}
+		for k, v := range labels {
+			if !slices.Contains(variable, k) {
+				constLabels[k] = v
+			}
+		}
+		return prometheus.NewDesc(name, help, variable, constLabels)
+	}
+	c.logged = desc("gousers_logged_users",
+		"Number of logged users by logon type (excluding root).", "type")
+	c.loggedAll = desc("gousers_logged_users_all",
+		"Total number of logged users (including root).")
+	c.root = desc("gousers_root_logged",
+		"Privileged user is logged in (1) or not (0).", "where")
+	c.loginsDesc = desc("gousers_logins_total",
+		"Number of user logins since start.")
+	c.logoutsDesc = desc("gousers_logouts_total",
+		"Number of user logouts since start.")
+	c.lastLogin = desc("gousers_last_login_timestamp_seconds",
+		"Last login time of user (Unix time).", "user")
+	c.dropped = desc("gousers_events_dropped_total",
+		"Number of login events dropped on overflow.")
+
+	c.reg = prometheus.NewPedanticRegistry()
+	c.reg.MustRegister(c)
+	c.handler = promhttp.HandlerFor(c.reg, promhttp.HandlerOpts{})
+
+	for _, li := range l.GetUsers() {
+		c.last[li.Name] = li.Time
+	}
+	events := l.Subscribe(utmp.LOGIN_QUEUE, utmp.OVERFLOW_COALESCE)
+	go func() {
+		for evt := range events {
+			c.add(evt)
+		}
+	}()
+	return c
+}
+
+// Учесть событие входа/выхода.
+func (c *Collector) add(evt utmp.LoginEvent) {
+	if evt.Historical {
+		return // replayed from wtmp (see Login.Replay())
+	}
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.logins += uint64(len(evt.Login))
+	c.logouts += uint64(len(evt.Logout))
+	for _, li := range evt.Users {
+		if li.Time.After(c.last[li.Name]) {
+			c.last[li.Name] = li.Time
+		}
+	}
+}
+
+// Передать описания метрик (prometheus.Collector).
+// Describe metrics.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{c.logged, c.loggedAll, c.root,
+		c.loginsDesc, c.logoutsDesc, c.lastLogin, c.dropped} {
+		ch <- d
+	}
+}
+
+// Передать текущие значения метрик (prometheus.Collector).
+// Collect metrics.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	stat := c.l.GetStat()
+	c.mx.Lock()
+	logins, logouts := c.logins, c.logouts
+	last := make(map[string]time.Time, len(c.last))
+	for name, t := range c.last {
+		last[name] = t
+	}
+	c.mx.Unlock()
+
+	gauge := func(d *prometheus.Desc, v float64, labels ...string) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.GaugeValue, v, labels...)
+	}
+	counter := func(d *prometheus.Desc, v uint64) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.CounterValue, float64(v))
+	}
+
+	for _, v := range []struct {
+		typ string
+		n   int
+	}{
+		{"local_x", stat.LocalX},
+		{"local", stat.Local},
+		{"remote_x", stat.RemoteX},
+		{"remote", stat.Remote},
+		{"unknown", stat.Unknown},
+	} {
+		gauge(c.logged, float64(v.n), v.typ)
+	}
+	gauge(c.loggedAll, float64(stat.Total))
+	gauge(c.root, b2f(stat.LocalRoot), "local")
+	gauge(c.root, b2f(stat.RemoteRoot), "remote")
+	counter(c.loginsDesc, logins)
+	counter(c.logoutsDesc, logouts)
+	for name, t := range last {
+		gauge(c.lastLogin, float64(t.Unix()), name)
+	}
+	counter(c.dropped, c.l.Dropped())
+}
+
+// Отдать метрики (GET /metrics).
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.handler.ServeHTTP(w, r)
+}
+
+// Записать метрики в текстовом формате Prometheus.
+// Write metrics.
+func (c *Collector) WriteTo(w io.Writer) (int64, error) {
+	mfs, err := c.reg.Gather()
+	if err != nil {
+		return 0, err
+	}
+	var n int64
+	for _, mf := range mfs {
+		k, err := expfmt.MetricFamilyToText(w, mf)
+		n += int64(k)
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+func b2f(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// Символы, недопустимые в имени метки Prometheus.
+var badLabelChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// Метки экземпляра службы с допустимыми именами (недопустимые символы
+// заменяются "_", имена с префиксом "__" зарезервированы и пропускаются).
+func instanceLabels() map[string]string {
+	labels := make(map[string]string)
+	for k, v := range utmp.Labels() {
+		k = badLabelChars.ReplaceAllString(k, "_")
+		if k == "" || k[0] >= '0' && k[0] <= '9' {
+			k = "_" + k
+		}
+		if strings.HasPrefix(k, "__") {
+			continue
+		}
+		labels[k] = v
+	}
+	return labels
+}
+
+// EOF: "metrics.go"
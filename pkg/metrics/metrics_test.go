@@ -0,0 +1,122 @@
+// File: "metrics_test.go"
+
+package metrics
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+
+	"github.com/azorg/gousers/v2/pkg/utmp"
+)
+
+func TestCollector(t *testing.T) {
+	fname := filepath.Join(t.TempDir(), "utmp")
+	require.NoError(t, os.WriteFile(fname, nil, 0644))
+	login := func(user, line string, sec int32) {
+		u := utmp.Utmp{Type: utmp.USER_PROCESS}
+		for i := range line {
+			u.Line[i] = int8(line[i])
+		}
+		for i := range user {
+			u.User[i] = int8(user[i])
+		}
+		u.TV.Sec = sec
+		f, err := os.OpenFile(fname, os.O_WRONLY|os.O_APPEND, 0)
+		require.NoError(t, err)
+		require.NoError(t, binary.Write(f, binary.LittleEndian, &u))
+		require.NoError(t, f.Close())
+	}
+
+	l, err := utmp.NewLogin(fname, false)
+	require.NoError(t, err)
+	defer l.Close()
+	c := NewCollector(l)
+
+	scrape := func() string {
+		w := httptest.NewRecorder()
+		c.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+		require.True(t, strings.HasPrefix(w.Header().Get("Content-Type"), CONTENT_TYPE))
+		return w.Body.String()
+	}
+
+	out := scrape()
+	require.Contains(t, out, "# TYPE gousers_logged_users gauge\n")
+	require.Contains(t, out, "gousers_logged_users_all 0\n")
+	require.Contains(t, out, "gousers_logins_total 0\n")
+
+	sec := int32(time.Now().Unix())
+	login("ro\"ot", "tty1", sec)
+	require.Eventually(t, func() bool {
+		return strings.Contains(scrape(), "gousers_logins_total 1\n")
+	}, 5*time.Second, 10*time.Millisecond)
+
+	out = scrape()
+	require.Contains(t, out, "gousers_logged_users_all 1\n")
+	require.Contains(t, out, "gousers_logouts_total 0\n")
+	require.Contains(t, out, fmt.Sprintf("gousers_last_login_timestamp_seconds{user=\"ro\\\"ot\"} %s\n",
+		strconv.FormatFloat(float64(sec), 'g', -1, 64)))
+
+	var buf bytes.Buffer
+	n, err := c.WriteTo(&buf)
+	require.NoError(t, err)
+	require.Equal(t, int64(buf.Len()), n)
+	require.Contains(t, buf.String(), "gousers_logins_total 1\n")
+}
+
+func TestCollectorRegister(t *testing.T) {
+	require.NoError(t, utmp.SetConfig(utmp.Config{Labels: map[string]string{
+		"dc": "msk-1", "team-name": "ops", "user": "shadowed", "__name__": "bad"}}))
+	defer utmp.SetConfig(utmp.DefaultConfig())
+
+	fname := filepath.Join(t.TempDir(), "utmp")
+	require.NoError(t, os.WriteFile(fname, nil, 0644))
+	l, err := utmp.NewLogin(fname, false)
+	require.NoError(t, err)
+	defer l.Close()
+	c := NewCollector(l)
+
+	// usable in registry of application
+	reg := prometheus.NewPedanticRegistry()
+	require.NoError(t, reg.Register(c))
+	require.Error(t, reg.Register(c)) // already registered
+	n, err := testutil.GatherAndCount(reg)
+	require.NoError(t, err)
+	require.Equal(t, 5+1+2+1+1+1, n) // no last logins
+
+	// instance labels on every series
+	mfs, err := reg.Gather()
+	require.NoError(t, err)
+	require.Len(t, mfs, 6) // no last logins
+	for _, mf := range mfs {
+		for _, m := range mf.GetMetric() {
+			labels := map[string]string{}
+			for _, lp := range m.GetLabel() {
+				labels[lp.GetName()] = lp.GetValue()
+			}
+			require.Equal(t, "msk-1", labels["dc"], mf.GetName())
+			require.Equal(t, "ops", labels["team_name"], mf.GetName())
+			require.NotContains(t, labels, "__name__")
+		}
+	}
+
+	err = testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP gousers_logged_users_all Total number of logged users (including root).
+# TYPE gousers_logged_users_all gauge
+gousers_logged_users_all{dc="msk-1",team_name="ops",user="shadowed"} 0
+`), "gousers_logged_users_all")
+	require.NoError(t, err)
+}
+
+// EOF: "metrics_test.go"
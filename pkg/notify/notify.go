@@ -0,0 +1,125 @@
+// File: "notify.go"
+
+/*
+Пакет `notify` - стабильный программный интерфейс оповещения о входах
+и выходах пользователей по изменениям utmp файла.
+
+Входит в стабильное ядро модуля (вместе с пакетами `utmp` и `session`):
+в пределах основной версии модуля (v2) экспортируемые имена пакета
+не удаляются и не меняют сигнатуру.
+
+Типы пакета - псевдонимы типов пакета `utmp` (реализация остаётся там),
+поэтому код, использующий utmp.NewLogin() и т.п., продолжает работать
+без изменений.
+
+Пример:
+
+	l, err := notify.New("", false)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer l.Close()
+	for evt := range l.C() {
+		fmt.Println(evt.Login, evt.Logout, evt.Stat.Active)
+	}
+
+Package notify is the stable API for login/logout notifications.
+*/
+package notify
+
+import (
+	"context"
+
+	"github.com/azorg/gousers/v2/pkg/utmp"
+)
+
+// Рекомендуемый бюджет времени получения информации о пользователях
+// (включается явно, по умолчанию события ожидают полной информации).
+// Recommended enrichment time budget.
+const ENRICH_BUDGET = utmp.ENRICH_BUDGET
+
+// Служба оповещения о входах/выходах пользователей.
+// Login/logout notifier.
+type Login = utmp.Login
+
+// Интерфейс службы оповещения (для подмены в тестах).
+// Notifier interface.
+type Loginer = utmp.Loginer
+
+// Событие входа/выхода пользователей.
+// Login/logout event.
+type Event = utmp.LoginEvent
+
+// Опции создания службы (журнал, контекст, EUID).
+// Notifier options.
+type Options = utmp.LoginOpts
+
+// Поведение при переполнении канала событий (см. Options.Overflow).
+// Event channel overflow policy.
+type Overflow = utmp.Overflow
+
+const (
+	OVERFLOW_BLOCK       = utmp.OVERFLOW_BLOCK       // ждать получателя
+	OVERFLOW_DROP_OLDEST = utmp.OVERFLOW_DROP_OLDEST // отбросить самое старое
+	OVERFLOW_COALESCE    = utmp.OVERFLOW_COALESCE    // объединять события
+)
+
+// Способ отслеживания изменений файлов (см. Options.Backend).
+// File change notification backend.
+type Backend = utmp.Backend
+
+const (
+	BACKEND_FSNOTIFY = utmp.BACKEND_FSNOTIFY // inotify
+	BACKEND_POLL     = utmp.BACKEND_POLL     // периодический опрос
+)
+
+// Опции подписки с фильтром (см. Login.SubscribeWith()).
+// Subscription options.
+type SubscribeOpts = utmp.SubscribeOpts
+
+// Событие смены активного пользователя (см. Login.SubscribeActive()).
+// Active user changed event.
+type ActiveUserEvent = utmp.ActiveUserEvent
+
+// Ошибка службы (см. Login.Errors()).
+// Notifier error.
+type Error = utmp.LoginError
+
+// Служба завершена (см. Login.Close(), Login.Replay()).
+// Notifier is closed.
+var ErrClosed = utmp.ErrClosed
+
+// Пользователь и терминал.
+// User and TTY.
+type UserTTY = utmp.UserTTY
+
+// Полная информация о пользователе в системе.
+// Logged user info.
+type LoginInfo = utmp.LoginInfo
+
+// Статистика пользователей в системе.
+// Logged user statistics.
+type LoginStat = utmp.LoginStat
+
+// Создать службу оповещения (fname - путь к utmp файлу, "" - файл
+// по умолчанию; useEUID - использовать эффективный UID).
+// Первое событие (текущее состояние) уже прочитано при возврате.
+// Create login/logout notifier.
+func New(fname string, useEUID bool) (*Login, error) {
+	return utmp.NewLogin(fname, useEUID)
+}
+
+// Вариант New() с дополнительными опциями (например, журнал `*slog.Logger`:
+// по умолчанию служба ничего не пишет в stderr приложения).
+// Create login/logout notifier with options.
+func NewWith(fname string, opts Options) (*Login, error) {
+	return utmp.NewLoginWith(fname, opts)
+}
+
+// Вариант New(), завершающий работу службы при отмене контекста.
+// Create login/logout notifier stopped by context cancellation.
+func NewContext(ctx context.Context, fname string, useEUID bool) (*Login, error) {
+	return utmp.NewLoginContext(ctx, fname, useEUID)
+}
+
+// EOF: "notify.go"
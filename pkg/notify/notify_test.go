@@ -0,0 +1,105 @@
+// File: "notify_test.go"
+
+package notify
+
+import (
+	"context"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/azorg/gousers/v2/pkg/utmp"
+)
+
+// Append login record to utmp file
+func login(t *testing.T, fname, line, user, host string) {
+	u := utmp.Utmp{Type: utmp.USER_PROCESS}
+	for i := 0; i < len(line) && i < len(u.Line); i++ {
+		u.Line[i] = int8(line[i])
+	}
+	for i := 0; i < len(user) && i < len(u.User); i++ {
+		u.User[i] = int8(user[i])
+	}
+	for i := 0; i < len(host) && i < len(u.Host); i++ {
+		u.Host[i] = int8(host[i])
+	}
+	u.TV.Sec = int32(time.Now().Unix())
+	f, err := os.OpenFile(fname, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	require.NoError(t, err)
+	defer f.Close()
+	require.NoError(t, binary.Write(f, binary.LittleEndian, &u))
+}
+
+// Receive next event
+func next(t *testing.T, c <-chan Event) Event {
+	select {
+	case evt, ok := <-c:
+		require.True(t, ok, "channel is closed")
+		return evt
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event")
+	}
+	return Event{}
+}
+
+func TestNew(t *testing.T) {
+	fname := filepath.Join(t.TempDir(), "utmp")
+	login(t, fname, "tty1", "root", "")
+
+	l, err := New(fname, false)
+	require.NoError(t, err)
+	defer l.Close()
+	var _ Loginer = l
+	require.Equal(t, 1, l.GetStat().Total)
+
+	login(t, fname, "pts/0", "alice", "10.0.0.5")
+	evt := next(t, l.C())
+	require.Equal(t, []UserTTY{{User: "alice", TTY: "pts/0"}}, evt.Login)
+	require.Equal(t, utmp.REMOTE, evt.Types[UserTTY{User: "alice", TTY: "pts/0"}])
+
+	l.Close()
+	require.ErrorIs(t, l.Replay(time.Time{}), ErrClosed)
+}
+
+func TestNewWith(t *testing.T) {
+	fname := filepath.Join(t.TempDir(), "utmp")
+	login(t, fname, "tty1", "root", "")
+
+	l, err := NewWith(fname, Options{
+		Backend:      BACKEND_POLL,
+		PollInterval: 10 * time.Millisecond,
+		Overflow:     OVERFLOW_COALESCE})
+	require.NoError(t, err)
+	defer l.Close()
+
+	c := l.SubscribeWith(SubscribeOpts{Users: []string{"bob"}})
+	login(t, fname, "pts/0", "alice", "10.0.0.5")
+	login(t, fname, "pts/1", "bob", "10.0.0.6")
+	evt := next(t, c)
+	require.Equal(t, []UserTTY{{User: "bob", TTY: "pts/1"}}, evt.Login)
+
+	_, err = NewWith(filepath.Join(t.TempDir(), "utmp"), Options{})
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestNewContext(t *testing.T) {
+	fname := filepath.Join(t.TempDir(), "utmp")
+	login(t, fname, "tty1", "root", "")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	l, err := NewContext(ctx, fname, false)
+	require.NoError(t, err)
+	cancel()
+	select {
+	case _, ok := <-l.C():
+		require.False(t, ok)
+	case <-time.After(5 * time.Second):
+		t.Fatal("not closed by context")
+	}
+}
+
+// EOF: "notify_test.go"
@@ -0,0 +1,179 @@
+// File: "config.go"
+
+package otel
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Адрес коллектора по умолчанию (OTLP/HTTP).
+// Default OTLP/HTTP endpoint.
+const ENDPOINT = "http://localhost:4318"
+
+// Имя службы по умолчанию (service.name).
+// Default service name.
+const SERVICE_NAME = "gousers"
+
+// Период передачи метрик по умолчанию.
+// Default metric export interval.
+const INTERVAL = 60 * time.Second
+
+// Время ожидания запроса по умолчанию.
+// Default export timeout.
+const TIMEOUT = 10 * time.Second
+
+// Получить опции из стандартных переменных окружения OpenTelemetry:
+//
+//	OTEL_SDK_DISABLED                    - true: передача выключена (ok=false)
+//	OTEL_EXPORTER_OTLP_ENDPOINT          - адрес коллектора (+ /v1/logs, /v1/metrics)
+//	OTEL_EXPORTER_OTLP_LOGS_ENDPOINT     - URL приёма журналов
+//	OTEL_EXPORTER_OTLP_METRICS_ENDPOINT  - URL приёма метрик
+//	OTEL_EXPORTER_OTLP_HEADERS           - заголовки (key=value,...)
+//	OTEL_EXPORTER_OTLP_LOGS_HEADERS      - заголовки запросов журналов
+//	OTEL_EXPORTER_OTLP_METRICS_HEADERS   - заголовки запросов метрик
+//	OTEL_EXPORTER_OTLP_TIMEOUT           - время ожидания запроса (мс)
+//	OTEL_EXPORTER_OTLP_PROTOCOL          - только http/json
+//	OTEL_LOGS_EXPORTER                   - none: не передавать журналы
+//	OTEL_METRICS_EXPORTER                - none: не передавать метрики
+//	OTEL_METRIC_EXPORT_INTERVAL          - период передачи метрик (мс)
+//	OTEL_SERVICE_NAME                    - имя службы (service.name)
+//	OTEL_RESOURCE_ATTRIBUTES             - атрибуты ресурса (key=value,...)
+//
+// Configuration from OTEL_* environment variables.
+func ConfigFromEnv() (cfg Config, ok bool, err error) {
+	return configFrom(os.Getenv)
+}
+
+func configFrom(getenv func(string) string) (cfg Config, ok bool, err error) {
+	if strings.EqualFold(getenv("OTEL_SDK_DISABLED"), "true") {
+		return cfg, false, nil
+	}
+
+	for _, name := range []string{
+		"OTEL_EXPORTER_OTLP_PROTOCOL",
+		"OTEL_EXPORTER_OTLP_LOGS_PROTOCOL",
+		"OTEL_EXPORTER_OTLP_METRICS_PROTOCOL",
+	} {
+		if p := getenv(name); p != "" && p != "http/json" {
+			return cfg, false, fmt.Errorf("%s=%s: only http/json is supported", name, p)
+		}
+	}
+
+	base := strings.TrimRight(getenv("OTEL_EXPORTER_OTLP_ENDPOINT"), "/")
+	if base == "" {
+		base = ENDPOINT
+	}
+	endpoint := func(exporter, name, path string) string {
+		switch getenv(exporter) {
+		case "none":
+			return ""
+		case "", "otlp":
+		default:
+			err = fmt.Errorf("%s=%s: only otlp is supported", exporter, getenv(exporter))
+		}
+		if u := getenv(name); u != "" {
+			return u // signal specific URL is used as is
+		}
+		return base + path
+	}
+	cfg.LogsURL = endpoint("OTEL_LOGS_EXPORTER", "OTEL_EXPORTER_OTLP_LOGS_ENDPOINT", "/v1/logs")
+	cfg.MetricsURL = endpoint("OTEL_METRICS_EXPORTER", "OTEL_EXPORTER_OTLP_METRICS_ENDPOINT", "/v1/metrics")
+	if err != nil {
+		return cfg, false, err
+	}
+	if cfg.LogsURL == "" && cfg.MetricsURL == "" {
+		return cfg, false, nil
+	}
+
+	cfg.Headers, err = parseList("OTEL_EXPORTER_OTLP_HEADERS", getenv)
+	if err != nil {
+		return cfg, false, err
+	}
+	// Signal specific headers are sent to both endpoints (one set per Config)
+	for _, name := range []string{
+		"OTEL_EXPORTER_OTLP_LOGS_HEADERS",
+		"OTEL_EXPORTER_OTLP_METRICS_HEADERS",
+	} {
+		headers, err := parseList(name, getenv)
+		if err != nil {
+			return cfg, false, err
+		}
+		for k, v := range headers {
+			cfg.Headers[k] = v
+		}
+	}
+
+	cfg.Resource, err = parseList("OTEL_RESOURCE_ATTRIBUTES", getenv)
+	if err != nil {
+		return cfg, false, err
+	}
+	if name := getenv("OTEL_SERVICE_NAME"); name != "" {
+		cfg.Resource["service.name"] = name
+	} else if cfg.Resource["service.name"] == "" {
+		cfg.Resource["service.name"] = SERVICE_NAME
+	}
+	if _, ok := cfg.Resource["host.name"]; !ok {
+		if host, err := os.Hostname(); err == nil {
+			cfg.Resource["host.name"] = host
+		}
+	}
+
+	if cfg.Interval, err = parseMillis("OTEL_METRIC_EXPORT_INTERVAL", INTERVAL, getenv); err != nil {
+		return cfg, false, err
+	}
+	if cfg.Timeout, err = parseMillis("OTEL_EXPORTER_OTLP_TIMEOUT", TIMEOUT, getenv); err != nil {
+		return cfg, false, err
+	}
+	return cfg, true, nil
+}
+
+// Разобрать список key=value,... (значения в URL кодировке).
+func parseList(name string, getenv func(string) string) (map[string]string, error) {
+	kv := make(map[string]string)
+	for _, item := range strings.Split(getenv(name), ",") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(item, "=")
+		k = strings.TrimSpace(k)
+		if !ok || k == "" {
+			return nil, fmt.Errorf("%s: bad item %q", name, item)
+		}
+		v, err := url.QueryUnescape(strings.TrimSpace(v))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		kv[k] = v
+	}
+	return kv, nil
+}
+
+// Разобрать интервал в миллисекундах (def - если не задан).
+func parseMillis(name string, def time.Duration, getenv func(string) string) (time.Duration, error) {
+	s := getenv(name)
+	if s == "" {
+		return def, nil
+	}
+	ms, err := strconv.ParseUint(s, 10, 32)
+	if err != nil || ms == 0 {
+		return 0, fmt.Errorf("%s=%s: bad milliseconds", name, s)
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+func sortedKeys[V any](kv map[string]V) []string {
+	keys := make([]string, 0, len(kv))
+	for k := range kv {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// EOF: "config.go"
@@ -0,0 +1,331 @@
+// File: "otel.go"
+
+/*
+Пакет `otel` - передача событий входа/выхода и метрик вошедших
+пользователей в OpenTelemetry коллектор по протоколу OTLP/HTTP (JSON).
+
+Реализация не использует OTel SDK: API журналов Go SDK (otel/log,
+otlploghttp) не стабилизирован (v0.x), текущие выпуски требуют более
+новой версии Go, чем go.mod, и тянут gRPC/protobuf. Вместо этого
+формат OTLP/JSON (стабильный протокол) формируется напрямую. Входы
+и выходы передаются записями журнала (/v1/logs) сразу по событиям
+`utmp.Login`, метрики (/v1/metrics) - периодически. Метки экземпляра
+службы (utmp.Labels()) добавляются к атрибутам ресурса:
+
+	gousers.logged_users{type} - вошедшие пользователи по типу входа,
+	                             без root (gauge)
+	gousers.logged_users_all   - всего вошедших, включая root (gauge)
+	gousers.logins             - число входов (sum, cumulative)
+	gousers.logouts            - число выходов (sum, cumulative)
+
+Записи журнала входа/выхода привилегированных пользователей имеют
+уровень WARN, остальных - INFO.
+
+Настройка - стандартными переменными окружения (см. ConfigFromEnv()).
+
+Package otel exports login events and metrics to OpenTelemetry (OTLP/HTTP JSON).
+*/
+package otel
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/azorg/gousers/v2/dto"
+	"github.com/azorg/gousers/v2/pkg/utmp"
+)
+
+// Имя области инструментирования (scope).
+// Instrumentation scope name.
+const SCOPE = "github.com/azorg/gousers/v2/pkg/otel"
+
+// Опции передачи (см. ConfigFromEnv()).
+// Exporter configuration.
+type Config struct {
+	LogsURL    string            // URL приёма журналов ("" - не передавать)
+	MetricsURL string            // URL приёма метрик ("" - не передавать)
+	Headers    map[string]string // заголовки запросов (например, авторизация)
+	Resource   map[string]string // атрибуты ресурса (service.name и т.п.)
+	Interval   time.Duration     // период передачи метрик
+	Timeout    time.Duration     // время ожидания запроса
+	Logger     *slog.Logger      // журнал ошибок передачи (nil - не вести)
+}
+
+// Передача событий и метрик службы `Login` в коллектор.
+// OTLP exporter.
+type Exporter struct {
+	cfg     Config
+	l       *utmp.Login
+	events  <-chan utmp.LoginEvent // подписка на события
+	client  *http.Client
+	start   time.Time      // начало счёта входов/выходов
+	logins  int64          // число входов
+	logouts int64          // число выходов
+	wg      sync.WaitGroup // горутина передачи
+}
+
+// Начать передачу событий и метрик службы l (подписка на события
+// завершается при Close() службы или экспортёра).
+// Start exporter.
+func New(l *utmp.Login, cfg Config) *Exporter {
+	e := &Exporter{
+		cfg:    cfg,
+		l:      l,
+		client: &http.Client{Timeout: cfg.Timeout},
+		start:  time.Now()}
+	if e.cfg.Interval <= 0 {
+		e.cfg.Interval = INTERVAL
+	}
+	if e.client.Timeout <= 0 {
+		e.client.Timeout = TIMEOUT
+	}
+	e.events = l.Subscribe(utmp.LOGIN_QUEUE, utmp.OVERFLOW_DROP_OLDEST)
+	e.wg.Add(1)
+	go e.run()
+	return e
+}
+
+// Завершить передачу (с передачей последних метрик). Повторный вызов
+// безопасен, для nil ничего не делает (передача не включена).
+// Stop exporter.
+func (e *Exporter) Close() {
+	if e == nil {
+		return
+	}
+	e.l.Unsubscribe(e.events)
+	e.wg.Wait()
+}
+
+// Горутина передачи.
+func (e *Exporter) run() {
+	defer e.wg.Done()
+	ticker := time.NewTicker(e.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case evt, ok := <-e.events:
+			if !ok {
+				e.exportMetrics() // final metrics
+				return
+			}
+			e.exportEvent(evt)
+		case <-ticker.C:
+			e.exportMetrics()
+		}
+	}
+}
+
+// Передать входы/выходы события записями журнала.
+func (e *Exporter) exportEvent(evt utmp.LoginEvent) {
+	if evt.Historical {
+		return // replayed from wtmp (see Login.Replay())
+	}
+	e.logins += int64(len(evt.Login))
+	e.logouts += int64(len(evt.Logout))
+	if e.cfg.LogsURL == "" || len(evt.Login)+len(evt.Logout) == 0 {
+		return
+	}
+
+	ts := nanos(evt.Received)
+	var records []logRecord
+	add := func(name string, ut utmp.UserTTY) {
+		typ := evt.Types[ut]
+		sev, sevText := 9, "INFO"
+		if utmp.IsPrivileged(ut.User) {
+			sev, sevText = 13, "WARN" // privileged user
+		}
+		records = append(records, logRecord{
+			TimeUnixNano:         nanos(evt.Time),
+			ObservedTimeUnixNano: ts,
+			SeverityNumber:       sev,
+			SeverityText:         sevText,
+			Body:                 str(fmt.Sprintf("%s %s[%s]", name, ut.User, ut.TTY)),
+			Attributes: []keyValue{
+				{"event.name", str("gousers." + name)},
+				{"user.name", str(ut.User)},
+				{"gousers.tty", str(ut.TTY)},
+				{"gousers.logon_type", str(dto.LogonType[typ])},
+				{"gousers.seq", intValue(int64(evt.Seq))},
+			}})
+	}
+	for _, ut := range evt.Login {
+		add("login", ut)
+	}
+	for _, ut := range evt.Logout {
+		add("logout", ut)
+	}
+
+	e.post(e.cfg.LogsURL, map[string]any{
+		"resourceLogs": []any{map[string]any{
+			"resource": e.resource(),
+			"scopeLogs": []any{map[string]any{
+				"scope":      map[string]any{"name": SCOPE},
+				"logRecords": records}}}}})
+}
+
+// Передать метрики.
+func (e *Exporter) exportMetrics() {
+	if e.cfg.MetricsURL == "" {
+		return
+	}
+	stat := e.l.GetStat()
+	now := nanos(time.Now())
+	start := nanos(e.start)
+
+	var gauge []dataPoint
+	for _, v := range []struct {
+		typ string
+		n   int
+	}{
+		{"local_x", stat.LocalX},
+		{"local", stat.Local},
+		{"remote_x", stat.RemoteX},
+		{"remote", stat.Remote},
+		{"unknown", stat.Unknown},
+	} {
+		gauge = append(gauge, dataPoint{
+			Attributes:   []keyValue{{"type", str(v.typ)}},
+			TimeUnixNano: now,
+			AsInt:        fmt.Sprint(v.n)})
+	}
+	counter := func(name, desc string, n int64) map[string]any {
+		return map[string]any{
+			"name":        name,
+			"description": desc,
+			"unit":        "{login}",
+			"sum": map[string]any{
+				"aggregationTemporality": 2, // cumulative
+				"isMonotonic":            true,
+				"dataPoints": []dataPoint{{
+					StartTimeUnixNano: start,
+					TimeUnixNano:      now,
+					AsInt:             fmt.Sprint(n)}}}}
+	}
+
+	e.post(e.cfg.MetricsURL, map[string]any{
+		"resourceMetrics": []any{map[string]any{
+			"resource": e.resource(),
+			"scopeMetrics": []any{map[string]any{
+				"scope": map[string]any{"name": SCOPE},
+				"metrics": []any{
+					map[string]any{
+						"name":        "gousers.logged_users",
+						"description": "Number of logged users by logon type (excluding root)",
+						"unit":        "{user}",
+						"gauge":       map[string]any{"dataPoints": gauge}},
+					map[string]any{
+						"name":        "gousers.logged_users_all",
+						"description": "Total number of logged users (including root)",
+						"unit":        "{user}",
+						"gauge": map[string]any{"dataPoints": []dataPoint{{
+							TimeUnixNano: now,
+							AsInt:        fmt.Sprint(stat.Total)}}}},
+					counter("gousers.logins", "Number of user logins", e.logins),
+					counter("gousers.logouts", "Number of user logouts", e.logouts),
+				}}}}}})
+}
+
+// Атрибуты ресурса: метки экземпляра службы (utmp.Labels()) и
+// Config.Resource (имеют приоритет при совпадении имён).
+func (e *Exporter) resource() map[string]any {
+	res := utmp.Labels()
+	if res == nil {
+		res = make(map[string]string, len(e.cfg.Resource))
+	}
+	for k, v := range e.cfg.Resource {
+		res[k] = v
+	}
+	attrs := []keyValue{}
+	for _, k := range sortedKeys(res) {
+		attrs = append(attrs, keyValue{k, str(res[k])})
+	}
+	return map[string]any{"attributes": attrs}
+}
+
+// Отправить запрос OTLP/HTTP JSON.
+func (e *Exporter) post(url string, body any) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		e.error("json.Marshal()", err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), e.client.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		e.error("otlp request", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		e.error("otlp export", err)
+		return
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		e.error("otlp export", fmt.Errorf("%s: %s", url, resp.Status))
+	}
+}
+
+// Записать ошибку в журнал (если задан).
+func (e *Exporter) error(msg string, err error) {
+	if e.cfg.Logger != nil {
+		e.cfg.Logger.Error(msg, "err", err)
+	}
+}
+
+// OTLP JSON: атрибут (KeyValue).
+type keyValue struct {
+	Key   string `json:"key"`
+	Value any    `json:"value"`
+}
+
+// OTLP JSON: запись журнала (LogRecord).
+type logRecord struct {
+	TimeUnixNano         string     `json:"timeUnixNano"`
+	ObservedTimeUnixNano string     `json:"observedTimeUnixNano"`
+	SeverityNumber       int        `json:"severityNumber"`
+	SeverityText         string     `json:"severityText"`
+	Body                 any        `json:"body"`
+	Attributes           []keyValue `json:"attributes"`
+}
+
+// OTLP JSON: значение метрики (NumberDataPoint).
+type dataPoint struct {
+	Attributes        []keyValue `json:"attributes,omitempty"`
+	StartTimeUnixNano string     `json:"startTimeUnixNano,omitempty"`
+	TimeUnixNano      string     `json:"timeUnixNano"`
+	AsInt             string     `json:"asInt"`
+}
+
+// OTLP JSON: строковое значение (AnyValue).
+func str(s string) any {
+	return map[string]string{"stringValue": s}
+}
+
+// OTLP JSON: целое значение (AnyValue, int64 - строкой).
+func intValue(n int64) any {
+	return map[string]string{"intValue": fmt.Sprint(n)}
+}
+
+// OTLP JSON: время (fixed64 наносекунд - строкой, 0 - не задано).
+func nanos(t time.Time) string {
+	if t.IsZero() {
+		return "0"
+	}
+	return fmt.Sprint(t.UnixNano())
+}
+
+// EOF: "otel.go"
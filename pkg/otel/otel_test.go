@@ -0,0 +1,155 @@
+// File: "otel_test.go"
+
+package otel
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/azorg/gousers/v2/pkg/utmp"
+)
+
+func TestExporter(t *testing.T) {
+	var mx sync.Mutex
+	got := make(map[string][]map[string]any)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		require.Equal(t, "secret", r.Header.Get("Authorization"))
+		data, _ := io.ReadAll(r.Body)
+		var body map[string]any
+		require.NoError(t, json.Unmarshal(data, &body))
+		mx.Lock()
+		got[r.URL.Path] = append(got[r.URL.Path], body)
+		mx.Unlock()
+	}))
+	defer srv.Close()
+	count := func(path string) int {
+		mx.Lock()
+		defer mx.Unlock()
+		return len(got[path])
+	}
+
+	fname := filepath.Join(t.TempDir(), "utmp")
+	require.NoError(t, os.WriteFile(fname, nil, 0644))
+	l, err := utmp.NewLogin(fname, false)
+	require.NoError(t, err)
+	defer l.Close()
+
+	cfg, ok, err := configFrom(env(map[string]string{
+		"OTEL_EXPORTER_OTLP_ENDPOINT": srv.URL,
+		"OTEL_EXPORTER_OTLP_HEADERS":  "Authorization=secret",
+		"OTEL_METRIC_EXPORT_INTERVAL": "3600000",
+	}))
+	require.NoError(t, err)
+	require.True(t, ok)
+	e := New(l, cfg)
+
+	u := utmp.Utmp{Type: utmp.USER_PROCESS}
+	copy8(u.User[:], "root")
+	copy8(u.Line[:], "tty1")
+	u.TV.Sec = int32(time.Now().Unix())
+	f, err := os.OpenFile(fname, os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	require.NoError(t, binary.Write(f, binary.LittleEndian, &u))
+	require.NoError(t, f.Close())
+
+	require.Eventually(t, func() bool { return count("/v1/logs") == 1 },
+		5*time.Second, 10*time.Millisecond)
+	e.Close()
+	e.Close() // safe
+	require.Equal(t, 1, count("/v1/metrics"))
+
+	mx.Lock()
+	defer mx.Unlock()
+	rl := got["/v1/logs"][0]["resourceLogs"].([]any)[0].(map[string]any)
+	require.Contains(t, rl["resource"].(map[string]any)["attributes"],
+		map[string]any{"key": "service.name", "value": map[string]any{"stringValue": "gousers"}})
+	rec := rl["scopeLogs"].([]any)[0].(map[string]any)["logRecords"].([]any)[0].(map[string]any)
+	require.Equal(t, "WARN", rec["severityText"])
+	require.Equal(t, map[string]any{"stringValue": "login root[tty1]"}, rec["body"])
+	require.Contains(t, rec["attributes"],
+		map[string]any{"key": "user.name", "value": map[string]any{"stringValue": "root"}})
+
+	rm := got["/v1/metrics"][0]["resourceMetrics"].([]any)[0].(map[string]any)
+	metrics := rm["scopeMetrics"].([]any)[0].(map[string]any)["metrics"].([]any)
+	sums := make(map[string]string)
+	for _, m := range metrics {
+		m := m.(map[string]any)
+		if sum, ok := m["sum"].(map[string]any); ok {
+			sums[m["name"].(string)] = sum["dataPoints"].([]any)[0].(map[string]any)["asInt"].(string)
+		}
+	}
+	require.Equal(t, map[string]string{"gousers.logins": "1", "gousers.logouts": "0"}, sums)
+}
+
+func TestConfigFrom(t *testing.T) {
+	_, ok, err := configFrom(env(map[string]string{"OTEL_SDK_DISABLED": "true"}))
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	cfg, ok, err := configFrom(env(map[string]string{
+		"OTEL_EXPORTER_OTLP_ENDPOINT":         "http://collector:4318/",
+		"OTEL_EXPORTER_OTLP_METRICS_ENDPOINT": "http://metrics/custom",
+		"OTEL_LOGS_EXPORTER":                  "none",
+		"OTEL_SERVICE_NAME":                   "login-watch",
+		"OTEL_RESOURCE_ATTRIBUTES":            "service.name=x, deployment.environment=prod%20eu",
+		"OTEL_EXPORTER_OTLP_TIMEOUT":          "500",
+	}))
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, "", cfg.LogsURL)
+	require.Equal(t, "http://metrics/custom", cfg.MetricsURL)
+	require.Equal(t, "login-watch", cfg.Resource["service.name"])
+	require.Equal(t, "prod eu", cfg.Resource["deployment.environment"])
+	require.Equal(t, INTERVAL, cfg.Interval)
+	require.Equal(t, 500*time.Millisecond, cfg.Timeout)
+
+	cfg, ok, err = configFrom(env(nil))
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, ENDPOINT+"/v1/logs", cfg.LogsURL)
+
+	for _, kv := range []map[string]string{
+		{"OTEL_EXPORTER_OTLP_PROTOCOL": "grpc"},
+		{"OTEL_METRICS_EXPORTER": "prometheus"},
+		{"OTEL_EXPORTER_OTLP_HEADERS": "novalue"},
+		{"OTEL_METRIC_EXPORT_INTERVAL": "1s"},
+	} {
+		_, _, err = configFrom(env(kv))
+		require.Error(t, err, kv)
+	}
+}
+
+func TestResource(t *testing.T) {
+	require.NoError(t, utmp.SetConfig(utmp.Config{Labels: map[string]string{
+		"dc": "eu-1", "service.name": "label"}}))
+	defer utmp.SetConfig(utmp.DefaultConfig())
+
+	e := &Exporter{cfg: Config{Resource: map[string]string{"service.name": "gousers"}}}
+	require.Equal(t, []keyValue{
+		{"dc", str("eu-1")},
+		{"service.name", str("gousers")}, // OTEL_RESOURCE_ATTRIBUTES wins
+	}, e.resource()["attributes"])
+}
+
+func env(kv map[string]string) func(string) string {
+	return func(name string) string { return kv[name] }
+}
+
+func copy8(dst []int8, s string) {
+	for i := range s {
+		dst[i] = int8(s[i])
+	}
+}
+
+// EOF: "otel_test.go"
@@ -0,0 +1,215 @@
+// File: "pacct.go"
+
+/*
+Пакет `pacct` - чтение файла учёта процессов BSD (process accounting,
+формат acct_v3 ядра Linux, включается `accton`) и сопоставление
+завершённых процессов с сеансами wtmp по терминалу и времени запуска:
+процессорное время и число команд сеанса.
+
+	records, err := pacct.ReadFile(pacct.PACCT)
+	if err != nil {
+		log.Fatal(err)
+	}
+	sessions, _ := utmp.GetSessions("/var/log/wtmp", utmp.GetUsersOpts{})
+	usage := pacct.SessionUsage(sessions, records)
+	for i, s := range sessions {
+		fmt.Println(s.User, s.TTY, usage[i].CPU, usage[i].Commands)
+	}
+
+Package pacct reads BSD process accounting file.
+*/
+package pacct
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/azorg/gousers/v2/pkg/utmp"
+)
+
+// Файлы учёта процессов.
+// Process accounting files.
+const (
+	PACCT      = "/var/log/account/pacct" // Debian, Ubuntu
+	PACCT_RHEL = "/var/account/pacct"     // RHEL, CentOS, Fedora
+)
+
+// Частота тиков времени учёта (AHZ).
+// Accounting clock ticks per second.
+const AHZ = 100
+
+// Формат записи acct_v3.
+const (
+	acctVersion   = 3
+	acctBigEndian = 0x80 // ACCT_BYTEORDER in ac_version
+	acctComm      = 16
+	acctSize      = 64
+)
+
+// Флаги процесса (Record.Flag).
+// Process flags.
+const (
+	AFORK = 0x01 // Forked but not exec'ed
+	ASU   = 0x02 // Used super-user privileges
+	ACORE = 0x08 // Dumped core
+	AXSIG = 0x10 // Killed by signal
+)
+
+// Неподдерживаемый формат файла учёта (не acct_v3).
+// Unsupported accounting file format.
+var ErrVersion = errors.New("pacct: unsupported accounting format (acct_v3 expected)")
+
+// Запись о завершённом процессе.
+// Process accounting record.
+type Record struct {
+	Comm    string        // Command name (16 chars max)
+	TTY     string        // Control terminal ("pts/0", "tty1") or ""
+	Flag    uint8         // Flags (see AFORK...)
+	Exit    uint32        // Exit code
+	UID     uint32        // Real user ID
+	GID     uint32        // Real group ID
+	PID     uint32        // Process ID
+	PPID    uint32        // Parent process ID
+	Start   time.Time     // Process creation time
+	Elapsed time.Duration // Elapsed time
+	User    time.Duration // User CPU time
+	System  time.Duration // System CPU time
+	Mem     uint64        // Average memory usage (KiB)
+}
+
+// Процессорное время процесса (User + System).
+// CPU time of process.
+func (r *Record) CPU() time.Duration {
+	return r.User + r.System
+}
+
+// Прочитать файл учёта процессов.
+// Read process accounting file.
+func ReadFile(fname string) ([]Record, error) {
+	f, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(bufio.NewReader(f))
+}
+
+// Разобрать записи acct_v3.
+// Parse acct_v3 records.
+func Parse(r io.Reader) ([]Record, error) {
+	var list []Record
+	buf := make([]byte, acctSize)
+	for {
+		_, err := io.ReadFull(r, buf)
+		if err == io.EOF {
+			return list, nil
+		} else if err != nil {
+			return list, err
+		}
+		rec, err := parseRecord(buf)
+		if err != nil {
+			return list, err
+		}
+		list = append(list, rec)
+	}
+}
+
+// Разобрать запись acct_v3.
+func parseRecord(buf []byte) (Record, error) {
+	var order binary.ByteOrder = binary.LittleEndian
+	if buf[1]&acctBigEndian != 0 {
+		order = binary.BigEndian
+	}
+	if buf[1]&^acctBigEndian != acctVersion {
+		return Record{}, ErrVersion
+	}
+	comm, _, _ := bytes.Cut(buf[acctSize-acctComm:], []byte{0})
+	ticks := func(off int) time.Duration {
+		return time.Duration(compT(order.Uint16(buf[off:]))) * time.Second / AHZ
+	}
+	etime := math.Float32frombits(order.Uint32(buf[28:]))
+	return Record{
+		Comm:    string(comm),
+		TTY:     ttyName(order.Uint16(buf[2:])),
+		Flag:    buf[0],
+		Exit:    order.Uint32(buf[4:]),
+		UID:     order.Uint32(buf[8:]),
+		GID:     order.Uint32(buf[12:]),
+		PID:     order.Uint32(buf[16:]),
+		PPID:    order.Uint32(buf[20:]),
+		Start:   time.Unix(int64(order.Uint32(buf[24:])), 0),
+		Elapsed: time.Duration(float64(etime) * float64(time.Second) / AHZ),
+		User:    ticks(32),
+		System:  ticks(34),
+		Mem:     compT(order.Uint16(buf[36:])),
+	}, nil
+}
+
+// Значение comp_t: 13 бит мантиссы и 3 бита порядка по основанию 8.
+func compT(c uint16) uint64 {
+	return uint64(c&0x1fff) << (3 * (c >> 13))
+}
+
+// Имя терминала по номеру устройства (old_encode_dev: major<<8 | minor).
+func ttyName(dev uint16) string {
+	major, minor := int(dev>>8), int(dev&0xff)
+	switch {
+	case dev == 0:
+		return ""
+	case major >= 136 && major <= 143: // UNIX98 PTY slaves
+		return "pts/" + strconv.Itoa((major-136)<<8|minor)
+	case major == 4 && minor < 64:
+		return "tty" + strconv.Itoa(minor)
+	case major == 4:
+		return "ttyS" + strconv.Itoa(minor-64)
+	}
+	return fmt.Sprintf("%d:%d", major, minor)
+}
+
+// Использование ресурсов сеансом.
+// Resource usage of session.
+type Usage struct {
+	CPU      time.Duration // CPU time (user + system)
+	Commands int           // Number of finished processes
+}
+
+// Сопоставить процессы с сеансами: процесс относится к сеансу, если
+// его управляющий терминал совпадает с терминалом сеанса и он запущен
+// между входом и выходом. Результат соответствует sessions по индексу
+// (процессы без терминала, например X сеансов, не учитываются).
+// Correlate process accounting with sessions.
+func SessionUsage(sessions []utmp.Session, records []Record) []Usage {
+	byTTY := make(map[string][]int) // tty -> session indexes
+	for i := range sessions {
+		if tty := sessions[i].TTY; tty != "" {
+			byTTY[tty] = append(byTTY[tty], i)
+		}
+	}
+
+	usage := make([]Usage, len(sessions))
+	for i := range records {
+		r := &records[i]
+		for _, j := range byTTY[r.TTY] {
+			s := &sessions[j]
+			// Process accounting time has second precision
+			if r.Start.Before(s.Login.Truncate(time.Second)) ||
+				(s.End != utmp.SESSION_ACTIVE && r.Start.After(s.Logout)) {
+				continue
+			}
+			usage[j].CPU += r.CPU()
+			usage[j].Commands++
+			break
+		}
+	}
+	return usage
+}
+
+// EOF: "pacct.go"
@@ -0,0 +1,86 @@
+// File: "pacct_test.go"
+
+package pacct
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/azorg/gousers/v2/pkg/utmp"
+	"github.com/stretchr/testify/require"
+)
+
+// Запись acct_v3 (little endian).
+func record(comm string, tty uint16, uid uint32, start time.Time, utime, stime uint16) []byte {
+	buf := make([]byte, acctSize)
+	buf[0] = ASU
+	buf[1] = acctVersion
+	le := binary.LittleEndian
+	le.PutUint16(buf[2:], tty)
+	le.PutUint32(buf[8:], uid)
+	le.PutUint32(buf[16:], 4242)
+	le.PutUint32(buf[20:], 1)
+	le.PutUint32(buf[24:], uint32(start.Unix()))
+	le.PutUint32(buf[28:], math.Float32bits(250)) // 2.5s
+	le.PutUint16(buf[32:], utime)
+	le.PutUint16(buf[34:], stime)
+	le.PutUint16(buf[36:], 1<<13|512) // 4096 KiB
+	copy(buf[acctSize-acctComm:], comm)
+	return buf
+}
+
+func TestParse(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	var data []byte
+	data = append(data, record("bash", 136<<8|3, 1000, start, 150, 50)...)
+	data = append(data, record("agetty", 4<<8|1, 0, start, 0, 1)...)
+	data = append(data, record("cron", 0, 0, start, 1<<13|100, 0)...)
+
+	list, err := Parse(bytes.NewReader(data))
+	require.NoError(t, err)
+	require.Len(t, list, 3)
+	require.Equal(t, Record{Comm: "bash", TTY: "pts/3", Flag: ASU, UID: 1000, PID: 4242, PPID: 1,
+		Start: start, Elapsed: 2500 * time.Millisecond, User: 1500 * time.Millisecond,
+		System: 500 * time.Millisecond, Mem: 4096}, list[0])
+	require.Equal(t, 2*time.Second, list[0].CPU())
+	require.Equal(t, "tty1", list[1].TTY)
+	require.Equal(t, "", list[2].TTY)
+	require.Equal(t, 8*time.Second, list[2].User) // comp_t exponent
+
+	data[1] = 2 // acct_v2
+	_, err = Parse(bytes.NewReader(data))
+	require.ErrorIs(t, err, ErrVersion)
+	_, err = Parse(bytes.NewReader(data[acctSize : 2*acctSize+1]))
+	require.Error(t, err)
+
+	require.Equal(t, "pts/259", ttyName(137<<8|3))
+	require.Equal(t, "ttyS0", ttyName(4<<8|64))
+	require.Equal(t, "5:1", ttyName(5<<8|1))
+}
+
+func TestSessionUsage(t *testing.T) {
+	at := func(sec int64) time.Time { return time.Unix(sec, 0) }
+	sessions := []utmp.Session{
+		{User: "alice", TTY: "pts/0", Login: at(100), Logout: at(200), End: utmp.SESSION_LOGOUT},
+		{User: "bob", TTY: "pts/0", Login: at(300), End: utmp.SESSION_ACTIVE},
+		{User: "carol", TTY: "tty1", Login: at(100), Logout: at(500), End: utmp.SESSION_LOGOUT},
+	}
+	records := []Record{
+		{Comm: "vi", TTY: "pts/0", Start: at(150), User: time.Second},
+		{Comm: "ls", TTY: "pts/0", Start: at(150), System: time.Second},
+		{Comm: "ls", TTY: "pts/0", Start: at(250), User: time.Second}, // no session
+		{Comm: "make", TTY: "pts/0", Start: at(400), User: 3 * time.Second},
+		{Comm: "cron", Start: at(150), User: time.Second}, // no terminal
+	}
+
+	usage := SessionUsage(sessions, records)
+	require.Equal(t, []Usage{
+		{CPU: 2 * time.Second, Commands: 2},
+		{CPU: 3 * time.Second, Commands: 1},
+		{}}, usage)
+}
+
+// EOF: "pacct_test.go"
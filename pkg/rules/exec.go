@@ -0,0 +1,182 @@
+// File: "exec.go"
+
+package rules
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/azorg/gousers/v2/dto"
+	"github.com/azorg/gousers/v2/pkg/sink"
+	"github.com/azorg/gousers/v2/pkg/utmp"
+)
+
+// Время выполнения команды действия exec по умолчанию.
+// Default exec action timeout.
+const EXEC_TIMEOUT = 30 * time.Second
+
+// Опции исполнителя действий.
+// Executor options.
+type ExecOpts struct {
+	Logger  *slog.Logger      // журнал действий log (nil - slog.Default())
+	Webhook sink.WebhookOpts  // опции действий webhook (кроме Headers)
+	Timeout time.Duration     // время выполнения команды exec (0 - EXEC_TIMEOUT)
+	Env     map[string]string // дополнительные переменные окружения команд exec
+}
+
+// Исполнитель действий правил: получатель sink.Sink, выполняющий
+// сработавшие действия событий. Команды exec запускаются без оболочки,
+// с переменными окружения GOUSERS_RULE, GOUSERS_ACTION, GOUSERS_USER,
+// GOUSERS_TTY, GOUSERS_TYPE, GOUSERS_HOST, GOUSERS_IP. Действие exit
+// запоминает наибольший код завершения (см. ExitCode()).
+// Rule action executor.
+type Executor struct {
+	e        *Engine
+	opts     ExecOpts
+	mx       sync.Mutex
+	webhooks map[string]*sink.Webhook // по правилу и адресу
+	code     int
+	closed   bool
+}
+
+// Создать исполнитель действий правил e.
+// Create executor.
+func NewExecutor(e *Engine, opts ExecOpts) *Executor {
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = EXEC_TIMEOUT
+	}
+	return &Executor{e: e, opts: opts, webhooks: make(map[string]*sink.Webhook)}
+}
+
+// Выполнить сработавшие действия события.
+// Run triggered actions of event.
+func (x *Executor) Send(evt utmp.LoginEvent) error {
+	var errs []error
+	for _, a := range x.e.Evaluate(evt) {
+		if err := x.Run(a, evt); err != nil {
+			errs = append(errs, fmt.Errorf("rule %s: %s: %w", a.Rule, a.Type, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Выполнить действие (evt - событие действия для webhook).
+// Run action.
+func (x *Executor) Run(a Action, evt utmp.LoginEvent) error {
+	x.mx.Lock()
+	defer x.mx.Unlock()
+	if x.closed {
+		return errors.New("executor is closed")
+	}
+	switch a.Type {
+	case ACTION_LOG:
+		msg := a.Message
+		if msg == "" {
+			msg = MESSAGE
+		}
+		x.opts.Logger.Warn(a.Expand(msg),
+			"rule", a.Rule,
+			"action", a.Entry.Action,
+			"user", a.Entry.User,
+			"tty", a.Entry.TTY,
+			"type", dto.LogonType[a.Entry.Type])
+
+	case ACTION_WEBHOOK:
+		key := a.Rule + " " + a.URL
+		w := x.webhooks[key]
+		if w == nil {
+			opts := x.opts.Webhook
+			opts.Headers = a.Headers
+			var err error
+			if w, err = sink.NewWebhook(a.URL, opts); err != nil {
+				return err
+			}
+			x.webhooks[key] = w
+		}
+		return w.Send(only(evt, a.Entry))
+
+	case ACTION_EXEC:
+		ctx, cancel := context.WithTimeout(context.Background(), x.opts.Timeout)
+		defer cancel()
+		args := make([]string, len(a.Command))
+		for i, s := range a.Command {
+			args[i] = a.Expand(s)
+		}
+		cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+		cmd.Env = append(os.Environ(),
+			"GOUSERS_RULE="+a.Rule,
+			"GOUSERS_ACTION="+a.Entry.Action,
+			"GOUSERS_USER="+a.Entry.User,
+			"GOUSERS_TTY="+a.Entry.TTY,
+			"GOUSERS_TYPE="+dto.LogonType[a.Entry.Type],
+			"GOUSERS_HOST="+a.Entry.Host,
+			"GOUSERS_IP="+a.Expand("{ip}"))
+		for k, v := range x.opts.Env {
+			cmd.Env = append(cmd.Env, k+"="+v)
+		}
+		if out, err := cmd.CombinedOutput(); err != nil {
+			if len(out) > 256 {
+				out = out[:256]
+			}
+			return fmt.Errorf("%s: %w: %q", args[0], err, out)
+		}
+
+	case ACTION_EXIT:
+		x.code = max(x.code, a.Code)
+	}
+	return nil
+}
+
+// Наибольший код завершения действий exit (0 - не было).
+// Exit code set by actions.
+func (x *Executor) ExitCode() int {
+	x.mx.Lock()
+	defer x.mx.Unlock()
+	return x.code
+}
+
+// Отправить ожидающие webhook запросы и завершить работу.
+// Close executor.
+func (x *Executor) Close() error {
+	x.mx.Lock()
+	defer x.mx.Unlock()
+	if x.closed {
+		return nil
+	}
+	x.closed = true
+	var errs []error
+	for _, w := range x.webhooks {
+		errs = append(errs, w.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// Событие только с одним входом/выходом (для webhook).
+func only(evt utmp.LoginEvent, e sink.Entry) utmp.LoginEvent {
+	ut := utmp.UserTTY{User: e.User, TTY: e.TTY}
+	out := utmp.LoginEvent{
+		Time:    evt.Time,
+		Seq:     evt.Seq,
+		Types:   map[utmp.UserTTY]utmp.LoginType{ut: e.Type},
+		Records: evt.Records}
+	if e.Action == "login" {
+		out.Login = []utmp.UserTTY{ut}
+	} else {
+		out.Logout = []utmp.UserTTY{ut}
+	}
+	return out
+}
+
+// Проверка интерфейса получателя.
+var _ sink.Sink = (*Executor)(nil)
+
+// EOF: "exec.go"
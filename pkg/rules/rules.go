@@ -0,0 +1,389 @@
+// File: "rules.go"
+
+/*
+Пакет `rules` - правила оповещения о входах/выходах: условия (действие,
+пользователь, тип входа, сеть источника, время суток, день недели) и
+действия (запись в журнал, webhook, запуск команды, код завершения).
+
+Правила задаются конфигурацией (JSON/YAML), например "вход root извне
+вне рабочего времени":
+
+	[{"name": "root-after-hours",
+	  "match": {"events": ["login"], "users": ["^root$"],
+	            "types": ["remote", "remote_x"],
+	            "time": "18:00-09:00"},
+	  "actions": [{"type": "log"},
+	              {"type": "webhook", "url": "https://hooks.example.com/x"},
+	              {"type": "exit", "code": 2}]}]
+
+Engine.Evaluate() возвращает сработавшие действия события, Executor
+(получатель sink.Sink) выполняет их:
+
+	e, err := rules.New(list)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, a := range e.Evaluate(evt) {
+		fmt.Println(a.Rule, a.Type, a.Entry.User)
+	}
+
+	x := rules.NewExecutor(e, rules.ExecOpts{})
+	stop := sink.Forward(l, x, func(err error) { log.Print(err) })
+
+Package rules implements config-driven login alerting.
+*/
+package rules
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/azorg/gousers/v2/dto"
+	"github.com/azorg/gousers/v2/pkg/sink"
+	"github.com/azorg/gousers/v2/pkg/utmp"
+)
+
+// Типы действий.
+// Action types.
+const (
+	ACTION_LOG     = "log"     // запись в журнал
+	ACTION_WEBHOOK = "webhook" // POST JSON (см. sink.Webhook)
+	ACTION_EXEC    = "exec"    // запуск команды
+	ACTION_EXIT    = "exit"    // код завершения процесса
+)
+
+// Сообщение действия log по умолчанию.
+// Default log message template.
+const MESSAGE = "rule {rule}: {action} {user}[{tty}] ({type}) from {from}"
+
+// Правило: все заданные условия Match должны выполниться для входа/выхода.
+// Alert rule.
+type Rule struct {
+	Name    string       `json:"name"`
+	Match   Match        `json:"match"`
+	Actions []ActionSpec `json:"actions"`
+}
+
+// Условия правила (пустое условие выполняется всегда).
+// Rule conditions.
+type Match struct {
+	Events     []string `json:"events,omitempty"`     // "login", "logout"
+	Users      []string `json:"users,omitempty"`      // регулярные выражения имён
+	Privileged *bool    `json:"privileged,omitempty"` // привилегированный (см. utmp.IsPrivileged())
+	Types      []string `json:"types,omitempty"`      // remote, remote_x, local, local_x
+	Sources    []string `json:"sources,omitempty"`    // сети CIDR, IP или метки utmp.Config.Networks
+	Time       string   `json:"time,omitempty"`       // "HH:MM-HH:MM" местного времени ("18:00-09:00" - через полночь)
+	Weekdays   []string `json:"weekdays,omitempty"`   // "mon".."sun" или диапазоны "mon-fri"
+}
+
+// Действие правила.
+// Rule action.
+type ActionSpec struct {
+	Type    string            `json:"type"`              // log, webhook, exec, exit
+	Message string            `json:"message,omitempty"` // log: шаблон ("" - MESSAGE), см. Action.Expand()
+	URL     string            `json:"url,omitempty"`     // webhook: адрес
+	Headers map[string]string `json:"headers,omitempty"` // webhook: заголовки
+	Command []string          `json:"command,omitempty"` // exec: команда и аргументы (шаблоны)
+	Code    int               `json:"code,omitempty"`    // exit: код завершения
+}
+
+// Сработавшее действие: действие правила Rule для входа/выхода Entry.
+// Triggered action.
+type Action struct {
+	ActionSpec
+	Rule  string     // имя правила
+	Entry sink.Entry // вход или выход
+}
+
+// Скомпилированное правило.
+type rule struct {
+	Rule
+	events     map[string]bool
+	users      []*regexp.Regexp
+	types      map[utmp.LoginType]bool
+	nets       []*net.IPNet
+	labels     map[string]bool
+	from, to   int // минуты от полуночи, from == to - без ограничения
+	hasTime    bool
+	weekdays   [7]bool
+	hasWeekday bool
+}
+
+// Набор правил.
+// Rule set.
+type Engine struct {
+	rules []rule
+}
+
+// Скомпилировать правила.
+// Compile rules.
+func New(rules []Rule) (*Engine, error) {
+	e := &Engine{}
+	for i, r := range rules {
+		c, err := compile(r)
+		if err != nil {
+			name := r.Name
+			if name == "" {
+				name = "#" + strconv.Itoa(i+1)
+			}
+			return nil, fmt.Errorf("rule %s: %w", name, err)
+		}
+		e.rules = append(e.rules, c)
+	}
+	return e, nil
+}
+
+// Сработавшие действия события (по входам/выходам и правилам по порядку).
+// Evaluate event.
+func (e *Engine) Evaluate(evt utmp.LoginEvent) []Action {
+	if e == nil {
+		return nil
+	}
+	var list []Action
+	for _, ent := range sink.Entries(evt) {
+		for i := range e.rules {
+			r := &e.rules[i]
+			if !r.match(ent) {
+				continue
+			}
+			for _, spec := range r.Actions {
+				list = append(list, Action{ActionSpec: spec, Rule: r.Name, Entry: ent})
+			}
+		}
+	}
+	return list
+}
+
+// Подставить значения входа/выхода в шаблон: {rule}, {action}, {user},
+// {tty}, {type}, {host}, {ip}, {from} (узел или IP).
+// Expand template.
+func (a Action) Expand(tmpl string) string {
+	ip := ""
+	if len(a.Entry.IP) != 0 {
+		ip = a.Entry.IP.String()
+	}
+	from := a.Entry.Host
+	if from == "" {
+		from = ip
+	}
+	if from == "" {
+		from = "-"
+	}
+	return strings.NewReplacer(
+		"{rule}", a.Rule,
+		"{action}", a.Entry.Action,
+		"{user}", a.Entry.User,
+		"{tty}", a.Entry.TTY,
+		"{type}", dto.LogonType[a.Entry.Type],
+		"{host}", a.Entry.Host,
+		"{ip}", ip,
+		"{from}", from,
+	).Replace(tmpl)
+}
+
+// Скомпилировать правило.
+func compile(r Rule) (rule, error) {
+	c := rule{Rule: r}
+	m := r.Match
+	if len(r.Actions) == 0 {
+		return c, fmt.Errorf("no actions")
+	}
+	for _, a := range r.Actions {
+		if err := a.check(); err != nil {
+			return c, err
+		}
+	}
+	if len(m.Events) != 0 {
+		c.events = make(map[string]bool)
+		for _, ev := range m.Events {
+			if ev != "login" && ev != "logout" {
+				return c, fmt.Errorf("unknown event '%s'", ev)
+			}
+			c.events[ev] = true
+		}
+	}
+	for _, s := range m.Users {
+		re, err := regexp.Compile(s)
+		if err != nil {
+			return c, fmt.Errorf("users: %w", err)
+		}
+		c.users = append(c.users, re)
+	}
+	if len(m.Types) != 0 {
+		c.types = make(map[utmp.LoginType]bool)
+		for _, s := range m.Types {
+			t, err := utmp.ParseLoginType(s)
+			if err != nil {
+				return c, fmt.Errorf("types: %w", err)
+			}
+			c.types[t] = true
+		}
+	}
+	for _, s := range m.Sources {
+		if _, n, err := net.ParseCIDR(s); err == nil {
+			c.nets = append(c.nets, n)
+		} else if ip := net.ParseIP(s); ip != nil {
+			bits := 8 * len(ip.To4())
+			if bits == 0 {
+				bits = 128
+			} else {
+				ip = ip.To4()
+			}
+			c.nets = append(c.nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+		} else {
+			if c.labels == nil {
+				c.labels = make(map[string]bool)
+			}
+			c.labels[s] = true // network label
+		}
+	}
+	if m.Time != "" {
+		var err error
+		c.from, c.to, err = parseTimeRange(m.Time)
+		if err != nil {
+			return c, err
+		}
+		c.hasTime = true
+	}
+	for _, s := range m.Weekdays {
+		first, last, ok := strings.Cut(s, "-")
+		a, err := parseWeekday(first)
+		if err != nil {
+			return c, err
+		}
+		b := a
+		if ok {
+			if b, err = parseWeekday(last); err != nil {
+				return c, err
+			}
+		}
+		for d := a; ; d = (d + 1) % 7 {
+			c.weekdays[d] = true
+			if d == b {
+				break
+			}
+		}
+		c.hasWeekday = true
+	}
+	return c, nil
+}
+
+// Проверить действие.
+func (a ActionSpec) check() error {
+	switch a.Type {
+	case ACTION_LOG, ACTION_EXIT:
+	case ACTION_WEBHOOK:
+		if !strings.HasPrefix(a.URL, "http://") && !strings.HasPrefix(a.URL, "https://") {
+			return fmt.Errorf("webhook: bad URL %q", a.URL)
+		}
+	case ACTION_EXEC:
+		if len(a.Command) == 0 {
+			return fmt.Errorf("exec: no command")
+		}
+	default:
+		return fmt.Errorf("unknown action '%s'", a.Type)
+	}
+	return nil
+}
+
+// Проверить условия правила.
+func (r *rule) match(e sink.Entry) bool {
+	if r.events != nil && !r.events[e.Action] {
+		return false
+	}
+	if len(r.users) != 0 && !matchAny(r.users, e.User) {
+		return false
+	}
+	if r.Match.Privileged != nil && *r.Match.Privileged != e.Privileged {
+		return false
+	}
+	if r.types != nil && !r.types[e.Type] {
+		return false
+	}
+	if len(r.nets) != 0 || r.labels != nil {
+		if len(e.IP) == 0 {
+			return false // local or unknown source
+		}
+		ok := r.labels[utmp.NetworkLabel(e.IP)]
+		for _, n := range r.nets {
+			ok = ok || n.Contains(e.IP)
+		}
+		if !ok {
+			return false
+		}
+	}
+	t := e.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	t = t.Local()
+	if r.hasTime && r.from != r.to {
+		min := t.Hour()*60 + t.Minute()
+		if r.from <= r.to {
+			if min < r.from || min >= r.to {
+				return false
+			}
+		} else if min < r.from && min >= r.to { // through midnight
+			return false
+		}
+	}
+	if r.hasWeekday && !r.weekdays[t.Weekday()] {
+		return false
+	}
+	return true
+}
+
+// Совпадение с одним из регулярных выражений.
+func matchAny(list []*regexp.Regexp, s string) bool {
+	for _, re := range list {
+		if re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Разобрать интервал "HH:MM-HH:MM" (минуты от полуночи).
+func parseTimeRange(s string) (from, to int, err error) {
+	a, b, ok := strings.Cut(s, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("bad time range '%s'", s)
+	}
+	parse := func(s string) (int, error) {
+		t, err := time.Parse("15:04", strings.TrimSpace(s))
+		if err != nil {
+			if strings.TrimSpace(s) == "24:00" {
+				return 24 * 60, nil
+			}
+			return 0, fmt.Errorf("bad time range '%s'", s)
+		}
+		return t.Hour()*60 + t.Minute(), nil
+	}
+	if from, err = parse(a); err != nil {
+		return
+	}
+	to, err = parse(b)
+	return
+}
+
+// Названия дней недели (time.Weekday).
+var weekdays = [...]string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// Разобрать день недели ("mon", "Monday").
+func parseWeekday(s string) (time.Weekday, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if len(s) >= 3 {
+		for i, d := range weekdays {
+			if strings.HasPrefix(s, d) && strings.HasPrefix(strings.ToLower(time.Weekday(i).String()), s) {
+				return time.Weekday(i), nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("bad weekday '%s'", s)
+}
+
+// EOF: "rules.go"
@@ -0,0 +1,162 @@
+// File: "rules_test.go"
+
+package rules
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/azorg/gousers/v2/dto"
+	"github.com/azorg/gousers/v2/pkg/utmp"
+)
+
+// Вход root (pts/0 с 10.0.0.1) и выход alice (tty1) в момент t.
+func testEvent(t time.Time) utmp.LoginEvent {
+	root := utmp.UserTTY{User: "root", TTY: "pts/0"}
+	alice := utmp.UserTTY{User: "alice", TTY: "tty1"}
+	u := utmp.Utmp{Type: utmp.USER_PROCESS}
+	for i, c := range "root" {
+		u.User[i] = int8(c)
+	}
+	for i, c := range "pts/0" {
+		u.Line[i] = int8(c)
+	}
+	u.AddrV6[0] = 0x0100000a // 10.0.0.1
+	return utmp.LoginEvent{
+		Time:    t,
+		Seq:     3,
+		Login:   []utmp.UserTTY{root},
+		Logout:  []utmp.UserTTY{alice},
+		Types:   map[utmp.UserTTY]utmp.LoginType{root: utmp.REMOTE, alice: utmp.LOCAL},
+		Records: []utmp.Utmp{u}}
+}
+
+func TestEvaluate(t *testing.T) {
+	night := time.Date(2026, 10, 15, 23, 30, 0, 0, time.Local) // Thursday
+	day := time.Date(2026, 10, 17, 12, 0, 0, 0, time.Local)    // Saturday
+	log := ActionSpec{Type: ACTION_LOG}
+
+	e, err := New([]Rule{
+		{Name: "root-after-hours", Match: Match{
+			Events: []string{"login"}, Users: []string{"^root$"},
+			Types: []string{"remote", "remote_x"}, Time: "18:00-09:00"},
+			Actions: []ActionSpec{log, {Type: ACTION_EXIT, Code: 2}}},
+		{Name: "weekend", Match: Match{Weekdays: []string{"sat-sun"}},
+			Actions: []ActionSpec{log}},
+		{Name: "lan", Match: Match{Sources: []string{"10.0.0.0/8"}, Privileged: new(bool)},
+			Actions: []ActionSpec{log}},
+		{Name: "host", Match: Match{Sources: []string{"10.0.0.1"}, Events: []string{"login"}},
+			Actions: []ActionSpec{log}},
+	})
+	require.NoError(t, err)
+
+	names := func(list []Action) []string {
+		var s []string
+		for _, a := range list {
+			s = append(s, a.Rule+":"+a.Type+":"+a.Entry.User)
+		}
+		return s
+	}
+	require.Equal(t, []string{
+		"root-after-hours:log:root",
+		"root-after-hours:exit:root",
+		"host:log:root",
+	}, names(e.Evaluate(testEvent(night))))
+	require.Equal(t, []string{
+		"weekend:log:root",
+		"host:log:root",
+		"weekend:log:alice",
+	}, names(e.Evaluate(testEvent(day))))
+
+	a := e.Evaluate(testEvent(night))[0]
+	require.Equal(t, "rule root-after-hours: login root[pts/0] (remote) from 10.0.0.1", a.Expand(MESSAGE))
+	require.Equal(t, "10.0.0.1", a.Entry.IP.String())
+
+	var nilEngine *Engine
+	require.Empty(t, nilEngine.Evaluate(testEvent(day)))
+}
+
+func TestCompile(t *testing.T) {
+	bad := []Rule{
+		{Name: "no-actions"},
+		{Actions: []ActionSpec{{Type: "mail"}}},
+		{Actions: []ActionSpec{{Type: ACTION_WEBHOOK, URL: "ftp://x"}}},
+		{Actions: []ActionSpec{{Type: ACTION_EXEC}}},
+		{Match: Match{Events: []string{"reboot"}}, Actions: []ActionSpec{{Type: ACTION_LOG}}},
+		{Match: Match{Users: []string{"("}}, Actions: []ActionSpec{{Type: ACTION_LOG}}},
+		{Match: Match{Types: []string{"ssh"}}, Actions: []ActionSpec{{Type: ACTION_LOG}}},
+		{Match: Match{Time: "9-18"}, Actions: []ActionSpec{{Type: ACTION_LOG}}},
+		{Match: Match{Weekdays: []string{"mo"}}, Actions: []ActionSpec{{Type: ACTION_LOG}}},
+	}
+	for _, r := range bad {
+		_, err := New([]Rule{r})
+		require.Error(t, err, r)
+	}
+	_, err := New([]Rule{{Actions: []ActionSpec{{Type: "mail"}}}})
+	require.EqualError(t, err, "rule #1: unknown action 'mail'")
+
+	from, to, err := parseTimeRange("09:30-24:00")
+	require.NoError(t, err)
+	require.Equal(t, []int{570, 1440}, []int{from, to})
+	d, err := parseWeekday("Friday")
+	require.NoError(t, err)
+	require.Equal(t, time.Friday, d)
+}
+
+func TestExecutor(t *testing.T) {
+	var posted dto.Webhook
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &posted)
+		require.Equal(t, "secret", r.Header.Get("X-Token"))
+	}))
+	defer srv.Close()
+
+	out := filepath.Join(t.TempDir(), "out")
+	e, err := New([]Rule{{
+		Name:  "r1",
+		Match: Match{Events: []string{"login"}},
+		Actions: []ActionSpec{
+			{Type: ACTION_LOG, Message: "{user} from {from}"},
+			{Type: ACTION_EXEC, Command: []string{"sh", "-c",
+				"echo \"$GOUSERS_RULE $GOUSERS_USER $GOUSERS_IP $1\" > " + out, "sh", "{tty}"}},
+			{Type: ACTION_WEBHOOK, URL: srv.URL, Headers: map[string]string{"X-Token": "secret"}},
+			{Type: ACTION_EXIT, Code: 3},
+			{Type: ACTION_EXIT, Code: 1},
+		}}})
+	require.NoError(t, err)
+
+	var logs bytes.Buffer
+	x := NewExecutor(e, ExecOpts{Logger: slog.New(slog.NewTextHandler(&logs, nil))})
+	require.NoError(t, x.Send(testEvent(time.Now())))
+	require.Contains(t, logs.String(), `msg="root from 10.0.0.1" rule=r1 action=login user=root`)
+	data, err := os.ReadFile(out)
+	require.NoError(t, err)
+	require.Equal(t, "r1 root 10.0.0.1 pts/0\n", string(data))
+	require.Equal(t, 3, x.ExitCode())
+
+	require.NoError(t, x.Close()) // webhook is flushed
+	require.Len(t, posted.Events, 1)
+	require.Equal(t, "root", posted.Events[0].User)
+	require.Equal(t, "10.0.0.1", posted.Events[0].IP)
+	require.Error(t, x.Send(testEvent(time.Now())))
+
+	// Failed command
+	e, err = New([]Rule{{Actions: []ActionSpec{{Type: ACTION_EXEC, Command: []string{"false"}}}}})
+	require.NoError(t, err)
+	x = NewExecutor(e, ExecOpts{})
+	defer x.Close()
+	require.ErrorContains(t, x.Send(testEvent(time.Now())), "rule : exec: false")
+}
+
+// EOF: "rules_test.go"
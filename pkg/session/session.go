@@ -0,0 +1,70 @@
+// File: "session.go"
+
+/*
+Пакет `session` - стабильный программный интерфейс получения сеансов
+пользователей из wtmp (аналог команды `last`) и отчётов по группам.
+
+Входит в стабильное ядро модуля (вместе с пакетами `utmp` и `notify`):
+в пределах основной версии модуля (v2) экспортируемые имена пакета
+не удаляются и не меняют сигнатуру.
+
+Типы пакета - псевдонимы типов пакета `utmp` (реализация остаётся там),
+поэтому значения свободно передаются между пакетами, а код, использующий
+utmp.GetSessions() и т.п., продолжает работать без изменений.
+
+Package session is the stable API for user sessions from wtmp.
+*/
+package session
+
+import (
+	"context"
+	"time"
+
+	"github.com/azorg/gousers/v2/pkg/utmp"
+)
+
+// Сеанс пользователя (пара записей входа и выхода).
+// User session.
+type Session = utmp.Session
+
+// Причина завершения сеанса.
+// How session ended.
+type End = utmp.SessionEnd
+
+const (
+	ACTIVE = utmp.SESSION_ACTIVE // сеанс не завершен (пользователь в системе)
+	LOGOUT = utmp.SESSION_LOGOUT // штатный выход пользователя
+	GONE   = utmp.SESSION_GONE   // новый вход на тот же терминал без выхода
+	DOWN   = utmp.SESSION_DOWN   // останов системы
+	CRASH  = utmp.SESSION_CRASH  // перезагрузка без останова
+)
+
+// Параметры чтения файла (фильтры по времени, ротация, дедупликация...).
+// Read options.
+type Options = utmp.GetUsersOpts
+
+// Статистика группы пользователей.
+// Group statistics.
+type GroupStat = utmp.GroupStat
+
+// Прочитать wtmp файл и получить список сеансов, сортированный по времени
+// входа (fname = "" - файл по умолчанию).
+// Get user sessions from wtmp (sorted by login time).
+func Get(fname string, opts Options) ([]Session, error) {
+	return utmp.GetSessions(fname, opts)
+}
+
+// Вариант Get() с возможностью прервать чтение отменой контекста.
+// Get user sessions with cancellation.
+func GetContext(ctx context.Context, fname string, opts Options) ([]Session, error) {
+	return utmp.GetSessionsContext(ctx, fname, opts)
+}
+
+// Сгруппировать сеансы по группам пользователей (активные сеансы
+// учитываются до `now`).
+// Aggregate sessions by groups.
+func GroupReport(sessions []Session, now time.Time) []GroupStat {
+	return utmp.GroupReport(sessions, now)
+}
+
+// EOF: "session.go"
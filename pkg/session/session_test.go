@@ -0,0 +1,100 @@
+// File: "session_test.go"
+
+package session
+
+import (
+	"context"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/azorg/gousers/v2/pkg/utmp"
+)
+
+// Create test wtmp record
+func record(Type int16, pid uint32, line, user, host string, sec int32) utmp.Utmp {
+	u := utmp.Utmp{Type: Type}
+	binary.LittleEndian.PutUint32(u.PID[:], pid)
+	for i := 0; i < len(line) && i < len(u.Line); i++ {
+		u.Line[i] = int8(line[i])
+	}
+	for i := 0; i < len(user) && i < len(u.User); i++ {
+		u.User[i] = int8(user[i])
+	}
+	for i := 0; i < len(host) && i < len(u.Host); i++ {
+		u.Host[i] = int8(host[i])
+	}
+	u.TV.Sec = sec
+	return u
+}
+
+// Write test records to temporary wtmp file
+func wtmpFile(t *testing.T, recs ...utmp.Utmp) string {
+	fname := filepath.Join(t.TempDir(), "wtmp")
+	f, err := os.Create(fname)
+	require.NoError(t, err)
+	defer f.Close()
+	for i := range recs {
+		require.NoError(t, binary.Write(f, binary.LittleEndian, &recs[i]))
+	}
+	return fname
+}
+
+func TestGet(t *testing.T) {
+	fname := wtmpFile(t,
+		record(utmp.BOOT_TIME, 0, "~", "reboot", "6.1.0", 1000),
+		record(utmp.USER_PROCESS, 101, "tty1", "alice", "", 1010),
+		record(utmp.USER_PROCESS, 102, "pts/0", "bob", "10.0.0.5", 1020),
+		record(utmp.DEAD_PROCESS, 102, "pts/0", "", "", 1100),
+		record(utmp.RUN_LVL, 0, "~", "shutdown", "6.1.0", 1200),
+		record(utmp.BOOT_TIME, 0, "~", "reboot", "6.1.0", 2000),
+		record(utmp.USER_PROCESS, 103, "tty1", "alice", "", 2010),
+	)
+
+	sessions, err := Get(fname, Options{})
+	require.NoError(t, err)
+	require.Len(t, sessions, 3)
+	var s Session = sessions[0] // same type as utmp.Session
+	require.Equal(t, "alice", s.User)
+	require.Equal(t, DOWN, s.End)
+	require.Equal(t, LOGOUT, sessions[1].End)
+	require.Equal(t, 80*time.Second, sessions[1].Duration(time.Now()))
+	require.Equal(t, ACTIVE, sessions[2].End)
+	require.Equal(t, "active", End(ACTIVE).String())
+
+	sessions, err = Get(fname, Options{Since: time.Unix(1500, 0)})
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sessions, err = GetContext(ctx, fname, Options{})
+	require.NoError(t, err)
+	require.Len(t, sessions, 3)
+	cancel()
+	_, err = GetContext(ctx, fname, Options{})
+	require.ErrorIs(t, err, context.Canceled)
+
+	_, err = Get(filepath.Join(t.TempDir(), "wtmp"), Options{})
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestGroupReport(t *testing.T) {
+	require.NoError(t, utmp.SetConfig(utmp.Config{Groups: map[string][]string{
+		"ml": {"^alice$", "^bob$"}}}))
+	defer utmp.SetConfig(utmp.DefaultConfig())
+
+	at := func(sec int64) time.Time { return time.Unix(sec, 0) }
+	report := GroupReport([]Session{
+		{User: "alice", Login: at(0), Logout: at(100), End: LOGOUT},
+		{User: "bob", Login: at(200), End: ACTIVE},
+	}, at(300))
+	require.Equal(t, []GroupStat{
+		{Group: "ml", Users: 2, Sessions: 2, Time: 200 * time.Second},
+	}, report)
+}
+
+// EOF: "session_test.go"
@@ -0,0 +1,122 @@
+// File: "cef.go"
+
+package siem
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/azorg/gousers/v2/dto"
+	"github.com/azorg/gousers/v2/pkg/sink"
+	"github.com/azorg/gousers/v2/pkg/utmp"
+)
+
+// Кодировщик ArcSight CEF:
+//
+//	CEF:0|azorg|gousers|2|login|User login|8|rt=... duser=root src=10.0.0.1 ...
+//
+// Расширение: rt (время, мс), act, duser, shost, src, dvchost, cs1 (tty),
+// cs2 (тип входа), cn1 (номер события), cs4 (метки экземпляра службы
+// "имя=значение" через запятую); для сеансов также start, end, cn2
+// (длительность, с) и cs3 (завершение сеанса).
+type cef struct {
+	opts Opts
+}
+
+func (c *cef) Entry(e sink.Entry) []byte {
+	name := "User login"
+	if e.Action == "logout" {
+		name = "User logout"
+	}
+	ext := [][2]string{
+		{"rt", millis(e.Time)},
+		{"act", e.Action},
+		{"duser", e.User},
+		{"shost", e.Host},
+		{"src", ip(e)},
+		{"dvchost", c.opts.Hostname},
+		{"cs1", e.TTY}, {"cs1Label", "tty"},
+		{"cs2", dto.LogonType[e.Type]}, {"cs2Label", "logonType"},
+		{"cn1", fmt.Sprint(e.Seq)}, {"cn1Label", "seq"},
+		{"cs4", labels(e.Labels)}, {"cs4Label", "labels"},
+	}
+	return c.format(e.Action, name, severity(e.Privileged, e.Remote()), ext)
+}
+
+func (c *cef) Session(s utmp.Session, now time.Time) []byte {
+	ext := [][2]string{
+		{"rt", millis(s.Login)},
+		{"start", millis(s.Login)},
+		{"end", millis(s.Logout)},
+		{"duser", s.User},
+		{"shost", s.Host},
+		{"src", sessionIP(s)},
+		{"dvchost", c.opts.Hostname},
+		{"cs1", s.TTY}, {"cs1Label", "tty"},
+		{"cs3", s.End.String()}, {"cs3Label", "sessionEnd"},
+		{"cn2", fmt.Sprint(int64(s.Duration(now).Seconds()))}, {"cn2Label", "durationSeconds"},
+		{"cs4", labels(utmp.Labels())}, {"cs4Label", "labels"},
+	}
+	return c.format("session", "User session", severity(utmp.IsPrivileged(s.User), false), ext)
+}
+
+// Заголовок и расширение (пустые значения пропускаются).
+func (c *cef) format(id, name string, sev int, ext [][2]string) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "CEF:0|azorg|gousers|%s|%s|%s|%d|",
+		cefHeader.Replace(c.opts.Version), id, name, sev)
+	sep := ""
+	for i, kv := range ext {
+		if kv[1] == "" {
+			continue
+		}
+		if strings.HasSuffix(kv[0], "Label") && ext[i-1][1] == "" {
+			continue // label of skipped value
+		}
+		b.WriteString(sep + kv[0] + "=" + cefExt.Replace(kv[1]))
+		sep = " "
+	}
+	return []byte(b.String())
+}
+
+// Экранирование полей заголовка и значений расширения CEF.
+var (
+	cefHeader = strings.NewReplacer(`\`, `\\`, `|`, `\|`)
+	cefExt    = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`)
+)
+
+// Время в миллисекундах Unix ("" - не задано).
+func millis(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return fmt.Sprint(t.UnixMilli())
+}
+
+// Метки экземпляра службы: "имя=значение" через запятую по именам.
+func labels(m map[string]string) string {
+	list := make([]string, 0, len(m))
+	for _, k := range sink.LabelNames(m) {
+		list = append(list, k+"="+m[k])
+	}
+	return strings.Join(list, ",")
+}
+
+// IP адрес входа ("" - не известен).
+func ip(e sink.Entry) string {
+	if len(e.IP) == 0 {
+		return ""
+	}
+	return e.IP.String()
+}
+
+// IP адрес сеанса ("" - не известен).
+func sessionIP(s utmp.Session) string {
+	if len(s.IP) == 0 || s.IP.IsUnspecified() {
+		return ""
+	}
+	return s.IP.String()
+}
+
+// EOF: "cef.go"
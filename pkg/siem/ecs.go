@@ -0,0 +1,122 @@
+// File: "ecs.go"
+
+package siem
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/azorg/gousers/v2/dto"
+	"github.com/azorg/gousers/v2/pkg/sink"
+	"github.com/azorg/gousers/v2/pkg/utmp"
+)
+
+// Версия схемы ECS (поле ecs.version).
+// ECS version of documents.
+const ECS_VERSION = "8.11.0"
+
+// Кодировщик Elastic Common Schema: события входа/выхода - категории
+// authentication и session (type start/end), сеансы - session (type info
+// или end). Поля, которых нет в ECS (tty, тип входа, номер события), -
+// в пространстве имён gousers, метки экземпляра службы - в поле labels.
+type ecs struct {
+	opts Opts
+}
+
+type object = map[string]any
+
+func (c *ecs) Entry(e sink.Entry) []byte {
+	typ, action := "start", "user-login"
+	if e.Action == "logout" {
+		typ, action = "end", "user-logout"
+	}
+	doc := c.doc(e.Time, e.User, e.Host, ip(e), e.Labels)
+	doc["event"] = object{
+		"kind":     "event",
+		"category": []string{"authentication", "session"},
+		"type":     []string{typ},
+		"action":   action,
+		"outcome":  "success",
+		"module":   "gousers",
+		"dataset":  "gousers." + e.Action,
+		"severity": severity(e.Privileged, e.Remote()),
+		"sequence": e.Seq}
+	doc["gousers"] = object{
+		"tty":        e.TTY,
+		"logon_type": dto.LogonType[e.Type],
+		"privileged": e.Privileged}
+	return marshal(doc)
+}
+
+func (c *ecs) Session(s utmp.Session, now time.Time) []byte {
+	typ := "end"
+	event := object{
+		"kind":     "event",
+		"category": []string{"session"},
+		"action":   "user-session",
+		"module":   "gousers",
+		"dataset":  "gousers.session",
+		"start":    s.Login,
+		"duration": s.Duration(now).Nanoseconds()}
+	if s.End == utmp.SESSION_ACTIVE {
+		typ = "info"
+	} else {
+		event["end"] = s.Logout
+	}
+	event["type"] = []string{typ}
+
+	doc := c.doc(s.Login, s.User, s.Host, sessionIP(s), utmp.Labels())
+	doc["event"] = event
+	doc["gousers"] = object{
+		"tty":         s.TTY,
+		"session_end": s.End.String(),
+		"privileged":  utmp.IsPrivileged(s.User)}
+	if s.PID != 0 {
+		doc["process"] = object{"pid": s.PID}
+	}
+	return marshal(doc)
+}
+
+// Общие поля документа.
+func (c *ecs) doc(t time.Time, user, host, ip string, labels map[string]string) object {
+	doc := object{
+		"@timestamp": t,
+		"ecs":        object{"version": ECS_VERSION},
+		"user":       object{"name": user},
+		"host":       object{"hostname": c.opts.Hostname},
+		"observer": object{
+			"vendor":  "azorg",
+			"product": "gousers",
+			"version": c.opts.Version}}
+	related := object{"user": []string{user}}
+	source := object{}
+	if host != "" {
+		source["domain"] = host
+		related["hosts"] = []string{host}
+	}
+	if ip != "" {
+		source["ip"] = ip
+		related["ip"] = []string{ip}
+	}
+	if len(source) != 0 {
+		doc["source"] = source
+	}
+	doc["related"] = related
+	if len(labels) != 0 { // ECS labels: keys without dots
+		m := make(map[string]string, len(labels))
+		for k, v := range labels {
+			m[strings.ReplaceAll(k, ".", "_")] = v
+		}
+		doc["labels"] = m
+	}
+	return doc
+}
+
+// Документ JSON (ключи по возрастанию, @timestamp - первым).
+func marshal(doc object) []byte {
+	data, _ := json.Marshal(doc) // basic types only: never fails
+	return data
+}
+
+// EOF: "ecs.go"
@@ -0,0 +1,86 @@
+// File: "siem.go"
+
+/*
+Пакет `siem` - представление входов/выходов пользователей и сеансов
+в форматах систем SIEM без промежуточных преобразований:
+
+	cef - ArcSight Common Event Format (строка "CEF:0|...", QRadar, Splunk)
+	ecs - Elastic Common Schema (документ JSON, Elastic, OpenSearch)
+
+Входы/выходы кодируются по `sink.Entry` (см. sink.Entries()), сеансы -
+по `utmp.Session` (см. utmp.GetSessions()). Каждый результат - одна
+строка без завершающего перевода строки.
+
+Package siem encodes login events and sessions as CEF or ECS JSON.
+*/
+package siem
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/azorg/gousers/v2/pkg/sink"
+	"github.com/azorg/gousers/v2/pkg/utmp"
+)
+
+// Форматы.
+// Encoding formats.
+const (
+	FORMAT_CEF = "cef"
+	FORMAT_ECS = "ecs"
+)
+
+// Версия продукта в заголовке CEF и поле observer.version по умолчанию.
+// Default product version.
+const VERSION = "2"
+
+// Кодировщик входов/выходов и сеансов.
+// Event encoder.
+type Encoder interface {
+	// Закодировать вход/выход
+	Entry(e sink.Entry) []byte
+
+	// Закодировать сеанс (длительность активного сеанса - до now)
+	Session(s utmp.Session, now time.Time) []byte
+}
+
+// Опции кодирования.
+// Encoder options.
+type Opts struct {
+	Hostname string // имя узла ("" - os.Hostname())
+	Version  string // версия продукта ("" - VERSION)
+}
+
+// Создать кодировщик формата format (FORMAT_CEF, FORMAT_ECS).
+// Create encoder.
+func NewEncoder(format string, opts Opts) (Encoder, error) {
+	if opts.Hostname == "" {
+		opts.Hostname, _ = os.Hostname()
+	}
+	if opts.Version == "" {
+		opts.Version = VERSION
+	}
+	switch format {
+	case FORMAT_CEF:
+		return &cef{opts: opts}, nil
+	case FORMAT_ECS:
+		return &ecs{opts: opts}, nil
+	}
+	return nil, fmt.Errorf("unknown SIEM format %q (cef or ecs)", format)
+}
+
+// Важность входа/выхода по шкале 0..10 (CEF).
+func severity(privileged, remote bool) int {
+	switch {
+	case privileged && remote:
+		return 8
+	case privileged:
+		return 6
+	case remote:
+		return 4
+	}
+	return 3
+}
+
+// EOF: "siem.go"
@@ -0,0 +1,113 @@
+// File: "siem_test.go"
+
+package siem
+
+import (
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/azorg/gousers/v2/pkg/sink"
+	"github.com/azorg/gousers/v2/pkg/utmp"
+)
+
+var (
+	login = sink.Entry{
+		Action:     "login",
+		User:       "root",
+		TTY:        "pts/0",
+		Host:       "bastion|a=b",
+		IP:         net.IPv4(10, 0, 0, 1),
+		Type:       utmp.REMOTE,
+		Privileged: true,
+		Time:       time.Date(2026, 10, 15, 12, 30, 0, 0, time.UTC),
+		Seq:        7}
+	session = utmp.Session{
+		User:   "alice",
+		TTY:    "tty1",
+		PID:    42,
+		IP:     net.IP{},
+		Login:  time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC),
+		Logout: time.Date(2026, 10, 15, 13, 0, 0, 0, time.UTC),
+		End:    utmp.SESSION_LOGOUT}
+)
+
+func TestCEF(t *testing.T) {
+	enc, err := NewEncoder(FORMAT_CEF, Opts{Hostname: "h1"})
+	require.NoError(t, err)
+
+	require.Equal(t, "CEF:0|azorg|gousers|2|login|User login|8|"+
+		`rt=1792067400000 act=login duser=root shost=bastion|a\=b src=10.0.0.1 dvchost=h1 `+
+		"cs1=pts/0 cs1Label=tty cs2=remote cs2Label=logonType cn1=7 cn1Label=seq",
+		string(enc.Entry(login)))
+
+	out := string(enc.Session(session, time.Now()))
+	require.Equal(t, "CEF:0|azorg|gousers|2|session|User session|3|"+
+		"rt=1792065600000 start=1792065600000 end=1792069200000 duser=alice dvchost=h1 "+
+		"cs1=tty1 cs1Label=tty cs3=logout cs3Label=sessionEnd cn2=3600 cn2Label=durationSeconds", out)
+
+	labeled := login
+	labeled.Labels = map[string]string{"dc": "eu=1", "az": "b"}
+	require.True(t, strings.HasSuffix(string(enc.Entry(labeled)),
+		` cs4=az\=b,dc\=eu\=1 cs4Label=labels`))
+
+	require.NoError(t, utmp.SetConfig(utmp.Config{Labels: map[string]string{"dc": "eu-1"}}))
+	defer utmp.SetConfig(utmp.DefaultConfig())
+	require.True(t, strings.HasSuffix(string(enc.Session(session, time.Now())),
+		` cs4=dc\=eu-1 cs4Label=labels`))
+
+	enc, err = NewEncoder(FORMAT_CEF, Opts{Hostname: "h1", Version: "1|x"})
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(string(enc.Entry(login)), `CEF:0|azorg|gousers|1\|x|`))
+}
+
+func TestECS(t *testing.T) {
+	enc, err := NewEncoder(FORMAT_ECS, Opts{Hostname: "h1"})
+	require.NoError(t, err)
+
+	data := enc.Entry(login)
+	require.True(t, strings.HasPrefix(string(data), `{"@timestamp":"2026-10-15T12:30:00Z",`))
+	var doc map[string]any
+	require.NoError(t, json.Unmarshal(data, &doc))
+	require.Equal(t, map[string]any{"domain": "bastion|a=b", "ip": "10.0.0.1"}, doc["source"])
+	event := doc["event"].(map[string]any)
+	require.Equal(t, "user-login", event["action"])
+	require.Equal(t, []any{"start"}, event["type"])
+	require.Equal(t, "remote", doc["gousers"].(map[string]any)["logon_type"])
+	require.NotContains(t, doc, "labels")
+
+	labeled := login
+	labeled.Labels = map[string]string{"k8s.cluster": "prod"}
+	doc = nil
+	require.NoError(t, json.Unmarshal(enc.Entry(labeled), &doc))
+	require.Equal(t, map[string]any{"k8s_cluster": "prod"}, doc["labels"])
+
+	require.NoError(t, utmp.SetConfig(utmp.Config{Labels: map[string]string{"dc": "eu-1"}}))
+	defer utmp.SetConfig(utmp.DefaultConfig())
+	doc = nil
+	require.NoError(t, json.Unmarshal(enc.Session(session, time.Now()), &doc))
+	require.Equal(t, map[string]any{"dc": "eu-1"}, doc["labels"])
+
+	active := session
+	active.End = utmp.SESSION_ACTIVE
+	active.Logout = time.Time{}
+	doc = nil
+	require.NoError(t, json.Unmarshal(enc.Session(active, active.Login.Add(time.Second)), &doc))
+	event = doc["event"].(map[string]any)
+	require.Equal(t, []any{"info"}, event["type"])
+	require.Equal(t, float64(time.Second), event["duration"])
+	require.NotContains(t, event, "end")
+	require.NotContains(t, doc, "source")
+	require.Equal(t, float64(42), doc["process"].(map[string]any)["pid"])
+}
+
+func TestNewEncoder(t *testing.T) {
+	_, err := NewEncoder("leef", Opts{})
+	require.Error(t, err)
+}
+
+// EOF: "siem_test.go"
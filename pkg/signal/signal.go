@@ -3,76 +3,152 @@
 package signal
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 )
 
 var (
-	CtrlC  chan struct{} // Ctrl+C -> SIGINT or SIGTERM
-	CtrlZ  chan struct{} // Ctrl+Z -> SIGTSTP
-	CtrlBS chan struct{} // Ctrl+\ -> SIGQUIT
+	CtrlC   chan struct{} // Ctrl+C -> SIGINT or SIGTERM
+	CtrlZ   chan struct{} // Ctrl+Z -> SIGTSTP
+	CtrlBS  chan struct{} // Ctrl+\ -> SIGQUIT
+	SigHUP  chan struct{} // SIGHUP (reload config)
+	SigUSR1 chan struct{} // SIGUSR1 (dump current state)
+	SigUSR2 chan struct{} // SIGUSR2 (application defined)
 )
 
-// Setup Ctrl+C | Ctrl+Z | Ctrl+\ channels
-func init() {
-	CtrlC = make(chan struct{}, 1)
-	CtrlZ = make(chan struct{}, 1)
-	CtrlBS = make(chan struct{}, 1)
+// Subscribe to signals: incoming signals are delivered to the returned
+// channel (buffered, a signal is dropped if the channel is full), the
+// returned function stops delivery. Several subscribers of the same
+// signal receive it independently.
+func Subscribe(sigs ...os.Signal) (<-chan os.Signal, func()) {
 	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, sigs...)
+	return ch, func() { signal.Stop(ch) }
+}
 
-	sigList := []os.Signal{
-		//syscall.SIGTERM,
-		syscall.SIGINT,  // Ctrl-C
-		syscall.SIGTSTP, // Ctrl-Z
-		syscall.SIGQUIT, // Ctrl-\
-		//syscall.SIGHUP,
-	}
-
-	//signal.Ignore(sigList...)
-	signal.Notify(ch, sigList...)
+// Get context canceled on first of signals (or on parent cancellation),
+// as signal.NotifyContext() of standard library.
+func NotifyContext(parent context.Context, sigs ...os.Signal) (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(parent, sigs...)
+}
 
+// Call fn on every received signal of sigs (calls are serialized, made
+// from separate goroutine), the returned function stops calls.
+func Handle(fn func(os.Signal), sigs ...os.Signal) func() {
+	ch, stop := Subscribe(sigs...)
+	done := make(chan struct{})
 	go func() {
-		for sig := range ch {
-			fmt.Fprint(os.Stderr, "\r\n")
-			switch sig {
-			case syscall.SIGTERM:
-				log.Print(`SIGTERM received`)
-			case syscall.SIGINT:
-				log.Print(`SIGINT received (Ctrl+C pressed)`)
-				if len(CtrlC) == 0 {
-					CtrlC <- struct{}{}
-				}
-			case syscall.SIGTSTP:
-				log.Print(`SIGTSTP received (Ctrl+Z pressed)`)
-				if len(CtrlZ) == 0 {
-					CtrlZ <- struct{}{}
-				}
-			case syscall.SIGQUIT:
-				log.Print(`SIGQUIT received (Ctrl+\ pressed)`)
-				if len(CtrlBS) == 0 {
-					CtrlBS <- struct{}{}
-				}
-      case syscall.SIGHUP:
-				log.Print(`SIGHUP received`)
-				//...
-			default:
-				log.Printf("unknown signal=%v received", sig)
-			} // switch
-		} // for
+		for {
+			select {
+			case sig := <-ch:
+				fn(sig)
+			case <-done:
+				return
+			}
+		}
 	}()
+	return func() {
+		stop()
+		close(done)
+	}
+}
+
+// Call fn on SIGHUP (reload config)
+func OnReload(fn func()) func() {
+	return Handle(func(os.Signal) { fn() }, syscall.SIGHUP)
+}
+
+// Call fn on SIGUSR1 (dump current state)
+func OnDump(fn func()) func() {
+	return Handle(func(os.Signal) { fn() }, syscall.SIGUSR1)
+}
+
+// Call fn on SIGUSR2
+func OnUSR2(fn func()) func() {
+	return Handle(func(os.Signal) { fn() }, syscall.SIGUSR2)
+}
+
+// Options of Install()
+type Opts struct {
+	Logger *log.Logger // log received signals (nil - silent)
+}
+
+var (
+	mx   sync.Mutex // protect stop
+	stop func()     // stop legacy channels (nil - not installed)
+)
+
+// Legacy channels by signal
+var legacy = map[os.Signal]*chan struct{}{
+	syscall.SIGINT:  &CtrlC,   // Ctrl-C
+	syscall.SIGTERM: &CtrlC,   // kill, systemctl stop
+	syscall.SIGTSTP: &CtrlZ,   // Ctrl-Z
+	syscall.SIGQUIT: &CtrlBS,  // Ctrl-\
+	syscall.SIGHUP:  &SigHUP,  // reload config
+	syscall.SIGUSR1: &SigUSR1, // dump state
+	syscall.SIGUSR2: &SigUSR2,
+}
+
+func init() {
+	for _, ch := range legacy {
+		*ch = make(chan struct{}, 1)
+	}
+}
+
+// Install handlers of Ctrl+C (and SIGTERM) | Ctrl+Z | Ctrl+\ | SIGHUP |
+// SIGUSR1 | SIGUSR2 channels (wrappers of Subscribe). Nothing is installed on import: until
+// Install() (or after Reset()) these signals have default behavior.
+// Repeated call replaces options.
+func Install(opts Opts) {
+	mx.Lock()
+	defer mx.Unlock()
+	if stop != nil {
+		stop()
+	}
+
+	sigs := make([]os.Signal, 0, len(legacy))
+	for sig := range legacy {
+		sigs = append(sigs, sig)
+	}
+	stop = Handle(func(sig os.Signal) {
+		if opts.Logger != nil {
+			opts.Logger.Printf("%v received", sig)
+		}
+		notify(*legacy[sig])
+	}, sigs...)
+}
+
+// Remove handlers installed by Install() and restore default behavior of
+// the signals (subscriptions of Subscribe() and Handle() are not affected).
+func Reset() {
+	mx.Lock()
+	defer mx.Unlock()
+	if stop != nil {
+		stop()
+		stop = nil
+	}
+}
+
+// Non-blocking send to legacy channel
+func notify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default: // already pending
+	}
 }
 
-// Debug wait
+// Debug wait (requires Install())
 func WaitCtrl() {
 	fmt.Println(`press Ctrl+\ to resume or Ctrl+C to abort`)
 	select {
 	case <-CtrlBS:
-		log.Print(`resume application by Ctrl+\`)
 	case <-CtrlC:
-		log.Fatal("abort application by Ctrl+С")
+		os.Exit(1) // abort application by Ctrl+C
 	}
 } // func WaitCtr()
 
@@ -0,0 +1,140 @@
+// File: "signal_test.go"
+
+package signal
+
+import (
+	"os"
+	"os/exec"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Послать сигнал процессу теста.
+func raise(t *testing.T, sig syscall.Signal) {
+	require.NoError(t, syscall.Kill(os.Getpid(), sig))
+}
+
+// Дождаться значения из канала.
+func recv[T any](t *testing.T, c <-chan T) T {
+	select {
+	case v := <-c:
+		return v
+	case <-time.After(5 * time.Second):
+		t.Fatal("signal is not delivered")
+	}
+	var v T
+	return v
+}
+
+// Проверить, что канал пуст.
+func silent[T any](t *testing.T, c <-chan T) {
+	select {
+	case <-c:
+		t.Fatal("unexpected signal")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	c1, stop1 := Subscribe(syscall.SIGUSR1)
+	c2, stop2 := Subscribe(syscall.SIGUSR1, syscall.SIGUSR2)
+	defer stop2()
+
+	raise(t, syscall.SIGUSR1)
+	require.Equal(t, syscall.SIGUSR1, recv(t, c1))
+	require.Equal(t, syscall.SIGUSR1, recv(t, c2))
+
+	stop1() // unsubscribe: c2 still receives
+	raise(t, syscall.SIGUSR1)
+	require.Equal(t, syscall.SIGUSR1, recv(t, c2))
+	silent(t, c1)
+}
+
+func TestHandle(t *testing.T) {
+	guard, stopGuard := Subscribe(syscall.SIGUSR1, syscall.SIGHUP) // no default action
+	defer stopGuard()
+
+	var n atomic.Int32
+	called := make(chan struct{}, 1)
+	stop := OnDump(func() {
+		n.Add(1)
+		called <- struct{}{}
+	})
+	raise(t, syscall.SIGUSR1)
+	recv(t, called)
+	recv(t, guard)
+
+	stop()
+	raise(t, syscall.SIGUSR1)
+	recv(t, guard)
+	silent(t, called)
+	require.Equal(t, int32(1), n.Load())
+
+	reloaded := make(chan struct{}, 1)
+	stop = OnReload(func() { reloaded <- struct{}{} })
+	defer stop()
+	raise(t, syscall.SIGHUP)
+	recv(t, reloaded)
+}
+
+func TestInstall(t *testing.T) {
+	guard, stopGuard := Subscribe(syscall.SIGUSR2, syscall.SIGHUP)
+	defer stopGuard()
+
+	Install(Opts{})
+	raise(t, syscall.SIGHUP)
+	recv(t, SigHUP)
+	recv(t, guard)
+	raise(t, syscall.SIGUSR2)
+	recv(t, SigUSR2)
+	recv(t, guard)
+
+	// legacy channels are not fed after Reset()
+	Reset()
+	Reset() // safe
+	raise(t, syscall.SIGUSR2)
+	recv(t, guard)
+	silent(t, SigUSR2)
+}
+
+// Дочерний процесс TestResetDefault: SIGTERM после Install() и Reset().
+const CHILD_ENV = "GOUSERS_SIGNAL_TEST_CHILD"
+
+func TestResetDefault(t *testing.T) {
+	if os.Getenv(CHILD_ENV) != "" {
+		Install(Opts{})
+		if os.Getenv(CHILD_ENV) == "reset" {
+			Reset()
+		}
+		syscall.Kill(os.Getpid(), syscall.SIGTERM)
+		time.Sleep(time.Second)
+		os.Exit(0) // not terminated by SIGTERM
+	}
+
+	run := func(mode string) *os.ProcessState {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestResetDefault$")
+		cmd.Env = append(os.Environ(), CHILD_ENV+"="+mode)
+		err := cmd.Run()
+		if err != nil {
+			var exit *exec.ExitError
+			require.ErrorAs(t, err, &exit)
+		}
+		return cmd.ProcessState
+	}
+
+	// default handling is restored: process is terminated by SIGTERM
+	ws := run("reset").Sys().(syscall.WaitStatus)
+	require.True(t, ws.Signaled())
+	require.Equal(t, syscall.SIGTERM, ws.Signal())
+
+	// installed: SIGTERM is delivered to CtrlC channel only
+	ws = run("install").Sys().(syscall.WaitStatus)
+	require.False(t, ws.Signaled())
+	require.Equal(t, 0, ws.ExitStatus())
+}
+
+// EOF: "signal_test.go"
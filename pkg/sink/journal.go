@@ -0,0 +1,170 @@
+// File: "journal.go"
+
+package sink
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/azorg/gousers/v2/dto"
+	"github.com/azorg/gousers/v2/pkg/utmp"
+)
+
+// Сокет systemd-journald (native protocol).
+// journald socket.
+const JOURNAL_SOCKET = "/run/systemd/journal/socket"
+
+// Идентификатор (SYSLOG_IDENTIFIER) по умолчанию: journalctl -t gousers.
+// Default journal identifier.
+const JOURNAL_IDENTIFIER = "gousers"
+
+// Идентификаторы сообщений (MESSAGE_ID) входа и выхода
+// (journalctl MESSAGE_ID=...).
+// Message IDs of login and logout.
+const (
+	JOURNAL_LOGIN_ID  = "5f1c2a7e0b6d4c3f9a8e2d1b7c6f4e30"
+	JOURNAL_LOGOUT_ID = "a3d9e4b1c7f24e8d8b5a6c0f2e1d9b47"
+)
+
+// Уровни важности (PRIORITY).
+const (
+	JOURNAL_WARNING = 4
+	JOURNAL_NOTICE  = 5
+	JOURNAL_INFO    = 6
+)
+
+// Опции получателя journald.
+// journald sink options.
+type JournalOpts struct {
+	Identifier string // SYSLOG_IDENTIFIER ("" - JOURNAL_IDENTIFIER)
+	Socket     string // сокет journald ("" - JOURNAL_SOCKET)
+}
+
+// Получатель systemd-journald: входы/выходы записываются в журнал
+// со структурированными полями GOUSERS_ACTION, GOUSERS_USER, GOUSERS_TTY,
+// GOUSERS_TYPE, GOUSERS_HOST, GOUSERS_IP, GOUSERS_SEQ и метками экземпляра
+// службы GOUSERS_LABEL_<ИМЯ>. Уровень важности:
+// warning - удалённый вход привилегированного пользователя, notice -
+// привилегированный или удалённый, info - остальные.
+// journald sink.
+type Journal struct {
+	opts JournalOpts
+	mx   sync.Mutex
+	conn *net.UnixConn
+}
+
+// Создать получатель journald.
+// Create journald sink.
+func NewJournal(opts JournalOpts) (*Journal, error) {
+	if opts.Identifier == "" {
+		opts.Identifier = JOURNAL_IDENTIFIER
+	}
+	if opts.Socket == "" {
+		opts.Socket = JOURNAL_SOCKET
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: opts.Socket, Net: "unixgram"})
+	if err != nil {
+		return nil, fmt.Errorf("journal: %w", err)
+	}
+	return &Journal{opts: opts, conn: conn}, nil
+}
+
+// Передать входы/выходы события.
+func (j *Journal) Send(evt utmp.LoginEvent) error {
+	j.mx.Lock()
+	defer j.mx.Unlock()
+	if j.conn == nil {
+		return fmt.Errorf("journal: closed")
+	}
+	for _, e := range Entries(evt) {
+		if _, err := j.conn.Write(j.Format(e)); err != nil {
+			return fmt.Errorf("journal: %w", err)
+		}
+	}
+	return nil
+}
+
+// Закрыть соединение.
+func (j *Journal) Close() error {
+	j.mx.Lock()
+	defer j.mx.Unlock()
+	if j.conn == nil {
+		return nil
+	}
+	err := j.conn.Close()
+	j.conn = nil
+	return err
+}
+
+// Сформировать запись журнала (native protocol) входа/выхода.
+// Format journal entry.
+func (j *Journal) Format(e Entry) []byte {
+	priority := JOURNAL_INFO
+	if e.Privileged && e.Remote() {
+		priority = JOURNAL_WARNING
+	} else if e.Privileged || e.Remote() {
+		priority = JOURNAL_NOTICE
+	}
+	id := JOURNAL_LOGIN_ID
+	if e.Action == "logout" {
+		id = JOURNAL_LOGOUT_ID
+	}
+
+	msg := fmt.Sprintf("%s %s[%s]", e.Action, e.User, e.TTY)
+	if e.Host != "" {
+		msg += " from " + e.Host
+	}
+
+	var b bytes.Buffer
+	field := func(key, value string) {
+		if value == "" {
+			return
+		}
+		if !strings.Contains(value, "\n") {
+			fmt.Fprintf(&b, "%s=%s\n", key, value)
+			return
+		}
+		// Binary safe form: KEY\n<64-bit LE size><value>\n
+		b.WriteString(key + "\n")
+		binary.Write(&b, binary.LittleEndian, uint64(len(value)))
+		b.WriteString(value + "\n")
+	}
+	field("MESSAGE", msg)
+	field("MESSAGE_ID", id)
+	field("PRIORITY", fmt.Sprint(priority))
+	field("SYSLOG_IDENTIFIER", j.opts.Identifier)
+	field("SYSLOG_FACILITY", fmt.Sprint(SYSLOG_FACILITY))
+	field("GOUSERS_ACTION", e.Action)
+	field("GOUSERS_USER", e.User)
+	field("GOUSERS_TTY", e.TTY)
+	field("GOUSERS_TYPE", dto.LogonType[e.Type])
+	field("GOUSERS_HOST", e.Host)
+	if len(e.IP) != 0 {
+		field("GOUSERS_IP", e.IP.String())
+	}
+	field("GOUSERS_SEQ", fmt.Sprint(e.Seq))
+	for _, k := range LabelNames(e.Labels) {
+		field("GOUSERS_LABEL_"+journalName(k), e.Labels[k])
+	}
+	return b.Bytes()
+}
+
+// Имя поля журнала по имени метки: заглавные латинские буквы, цифры
+// и "_" (остальные символы заменяются "_").
+func journalName(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+			return r
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		}
+		return '_'
+	}, s)
+}
+
+// EOF: "journal.go"
@@ -0,0 +1,77 @@
+// File: "journal_test.go"
+
+package sink
+
+import (
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestJournal(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "socket")
+	pc, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: sock, Net: "unixgram"})
+	require.NoError(t, err)
+	defer pc.Close()
+
+	j, err := NewJournal(JournalOpts{Socket: sock})
+	require.NoError(t, err)
+	require.NoError(t, j.Send(testEvent()))
+	require.NoError(t, j.Close())
+	require.NoError(t, j.Close()) // safe
+	require.Error(t, j.Send(testEvent()))
+
+	read := func() map[string]string {
+		buf := make([]byte, 4096)
+		pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, err := pc.Read(buf)
+		require.NoError(t, err)
+		fields := make(map[string]string)
+		for _, line := range strings.Split(strings.TrimSuffix(string(buf[:n]), "\n"), "\n") {
+			k, v, ok := strings.Cut(line, "=")
+			require.True(t, ok, line)
+			fields[k] = v
+		}
+		return fields
+	}
+
+	require.Equal(t, map[string]string{
+		"MESSAGE":           "login root[pts/0] from bastion]\"1",
+		"MESSAGE_ID":        JOURNAL_LOGIN_ID,
+		"PRIORITY":          "4",
+		"SYSLOG_IDENTIFIER": "gousers",
+		"SYSLOG_FACILITY":   "10",
+		"GOUSERS_ACTION":    "login",
+		"GOUSERS_USER":      "root",
+		"GOUSERS_TTY":       "pts/0",
+		"GOUSERS_TYPE":      "remote",
+		"GOUSERS_HOST":      "bastion]\"1",
+		"GOUSERS_IP":        "10.0.0.1",
+		"GOUSERS_SEQ":       "7",
+	}, read())
+
+	f := read()
+	require.Equal(t, JOURNAL_LOGOUT_ID, f["MESSAGE_ID"])
+	require.Equal(t, "6", f["PRIORITY"])
+	require.Equal(t, "alice", f["GOUSERS_USER"])
+}
+
+func TestJournalBinaryField(t *testing.T) {
+	j := &Journal{opts: JournalOpts{Identifier: "x"}}
+	data := j.Format(Entry{Action: "login", User: "a\nb", TTY: "tty1"})
+	require.Contains(t, string(data), "GOUSERS_USER\n\x03\x00\x00\x00\x00\x00\x00\x00a\nb\n")
+}
+
+func TestJournalLabels(t *testing.T) {
+	j := &Journal{opts: JournalOpts{Identifier: "x"}}
+	data := j.Format(Entry{Action: "login", User: "root", TTY: "tty1",
+		Labels: map[string]string{"dc": "eu-1", "k8s.cluster": "prod"}})
+	require.True(t, strings.HasSuffix(string(data),
+		"GOUSERS_LABEL_DC=eu-1\nGOUSERS_LABEL_K8S_CLUSTER=prod\n"), string(data))
+}
+
+// EOF: "journal_test.go"
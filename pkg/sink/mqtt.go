@@ -0,0 +1,245 @@
+// File: "mqtt.go"
+
+package sink
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Типы пакетов MQTT 3.1.1.
+const (
+	mqttCONNECT = 1
+	mqttCONNACK = 2
+	mqttPUBLISH = 3
+	mqttPUBACK  = 4
+)
+
+// Отправитель MQTT 3.1.1 (без внешних зависимостей): сообщения
+// публикуются с QoS 1, приём подтверждается пакетом PUBACK.
+// MQTT publisher.
+type MQTT struct {
+	addr string
+	tls  bool
+	user *url.Userinfo
+	opts BrokerOpts
+	mx   sync.Mutex
+	conn net.Conn
+	r    *bufio.Reader
+	id   uint16 // последний идентификатор пакета
+}
+
+// Создать отправитель MQTT по адресу mqtt://[user:pass@]host[:1883]
+// (или mqtts://host[:8883]).
+// Create MQTT publisher.
+func NewMQTT(target string, opts BrokerOpts) (*MQTT, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("mqtt: %w", err)
+	}
+	m := &MQTT{opts: opts, user: u.User}
+	port := "1883"
+	switch u.Scheme {
+	case "mqtt", "tcp":
+	case "mqtts", "ssl":
+		m.tls, port = true, "8883"
+	default:
+		return nil, fmt.Errorf("mqtt: unsupported address %q", target)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("mqtt: no host in %q", target)
+	}
+	m.addr = u.Host
+	if u.Port() == "" {
+		m.addr = net.JoinHostPort(u.Hostname(), port)
+	}
+	if m.opts.Timeout <= 0 {
+		m.opts.Timeout = BROKER_TIMEOUT
+	}
+	if m.opts.ClientID == "" {
+		m.opts.ClientID = clientID()
+	}
+
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	if err := m.connect(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Установить соединение: CONNECT, CONNACK.
+func (m *MQTT) connect() error {
+	dialer := &net.Dialer{Timeout: m.opts.Timeout}
+	var conn net.Conn
+	var err error
+	if m.tls {
+		conn, err = tls.DialWithDialer(dialer, "tcp", m.addr, m.opts.TLSConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", m.addr)
+	}
+	if err != nil {
+		return fmt.Errorf("mqtt: %w", err)
+	}
+	m.conn, m.r = conn, bufio.NewReader(conn)
+	conn.SetDeadline(time.Now().Add(m.opts.Timeout))
+	defer conn.SetDeadline(time.Time{})
+
+	// Variable header: protocol "MQTT", level 4, flags, keep alive 0 (off)
+	flags := byte(0x02) // clean session
+	var payload []byte
+	payload = mqttString(payload, m.opts.ClientID)
+	if m.user != nil {
+		flags |= 0x80
+		payload = mqttString(payload, m.user.Username())
+		if pass, ok := m.user.Password(); ok {
+			flags |= 0x40
+			payload = mqttString(payload, pass)
+		}
+	}
+	body := append(mqttString(nil, "MQTT"), 4, flags, 0, 0)
+	if err := m.write(mqttCONNECT<<4, append(body, payload...)); err != nil {
+		m.drop()
+		return err
+	}
+
+	typ, data, err := m.read()
+	if err != nil {
+		m.drop()
+		return err
+	}
+	if typ != mqttCONNACK || len(data) != 2 {
+		m.drop()
+		return fmt.Errorf("mqtt: %s: unexpected packet %d", m.addr, typ)
+	}
+	if data[1] != 0 {
+		m.drop()
+		return fmt.Errorf("mqtt: %s: connection refused (code %d)", m.addr, data[1])
+	}
+	return nil
+}
+
+// Опубликовать сообщение (QoS 1).
+func (m *MQTT) Publish(topic string, payload []byte) error {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	if m.conn == nil {
+		if err := m.connect(); err != nil {
+			return err
+		}
+	}
+	m.id++
+	if m.id == 0 {
+		m.id = 1
+	}
+	body := mqttString(nil, topic)
+	body = binary.BigEndian.AppendUint16(body, m.id)
+	body = append(body, payload...)
+
+	m.conn.SetDeadline(time.Now().Add(m.opts.Timeout))
+	if err := m.write(mqttPUBLISH<<4|0x02, body); err != nil { // QoS 1
+		m.drop()
+		return err
+	}
+	for {
+		typ, data, err := m.read()
+		if err != nil {
+			m.drop()
+			return err
+		}
+		if typ == mqttPUBACK && len(data) == 2 && binary.BigEndian.Uint16(data) == m.id {
+			m.conn.SetDeadline(time.Time{})
+			return nil
+		}
+		// other packets: skip
+	}
+}
+
+// Закрыть соединение (пакет DISCONNECT).
+func (m *MQTT) Close() error {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	if m.conn != nil {
+		m.conn.SetDeadline(time.Now().Add(m.opts.Timeout))
+		m.conn.Write([]byte{0xE0, 0})
+	}
+	m.drop()
+	return nil
+}
+
+// Закрыть соединение после ошибки.
+func (m *MQTT) drop() {
+	if m.conn != nil {
+		m.conn.Close()
+		m.conn, m.r = nil, nil
+	}
+}
+
+// Отправить пакет (первый байт фиксированного заголовка и тело).
+func (m *MQTT) write(hdr byte, body []byte) error {
+	pkt := append([]byte{hdr}, mqttLength(len(body))...)
+	if _, err := m.conn.Write(append(pkt, body...)); err != nil {
+		return fmt.Errorf("mqtt: %w", err)
+	}
+	return nil
+}
+
+// Прочитать пакет: тип и тело.
+func (m *MQTT) read() (typ byte, body []byte, err error) {
+	hdr, err := m.r.ReadByte()
+	if err != nil {
+		return 0, nil, fmt.Errorf("mqtt: %w", err)
+	}
+	n, mul := 0, 1
+	for i := 0; ; i++ {
+		b, err := m.r.ReadByte()
+		if err != nil {
+			return 0, nil, fmt.Errorf("mqtt: %w", err)
+		}
+		n += int(b&0x7F) * mul
+		if b&0x80 == 0 {
+			break
+		}
+		if i == 3 {
+			return 0, nil, errors.New("mqtt: malformed packet length")
+		}
+		mul *= 128
+	}
+	body = make([]byte, n)
+	if _, err := io.ReadFull(m.r, body); err != nil {
+		return 0, nil, fmt.Errorf("mqtt: %w", err)
+	}
+	return hdr >> 4, body, nil
+}
+
+// Строка MQTT: длина (2 байта) и UTF-8.
+func mqttString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+// Длина пакета (Remaining Length).
+func mqttLength(n int) []byte {
+	var b []byte
+	for {
+		c := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			c |= 0x80
+		}
+		b = append(b, c)
+		if n == 0 {
+			return b
+		}
+	}
+}
+
+// EOF: "mqtt.go"
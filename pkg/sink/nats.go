@@ -0,0 +1,209 @@
+// File: "nats.go"
+
+package sink
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Время ожидания соединения/подтверждения брокера по умолчанию.
+// Default broker I/O timeout.
+const BROKER_TIMEOUT = 10 * time.Second
+
+// Опции соединения с брокером (NATS, MQTT).
+// Broker connection options.
+type BrokerOpts struct {
+	TLSConfig *tls.Config   // настройки TLS (nil - по умолчанию)
+	Timeout   time.Duration // время ожидания (0 - BROKER_TIMEOUT)
+	ClientID  string        // имя клиента ("" - "gousers-<host>")
+}
+
+// Отправитель NATS (текстовый протокол NATS core без внешних
+// зависимостей): приём сообщения подтверждается ответом PONG на PING,
+// отправленный следом за PUB.
+// NATS publisher.
+type NATS struct {
+	addr string
+	tls  bool
+	user *url.Userinfo
+	opts BrokerOpts
+	mx   sync.Mutex
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// Создать отправитель NATS по адресу nats://[user:pass@]host[:4222]
+// (или nats+tls://, токен - nats://token@host).
+// Create NATS publisher.
+func NewNATS(target string, opts BrokerOpts) (*NATS, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("nats: %w", err)
+	}
+	n := &NATS{opts: opts, user: u.User}
+	switch u.Scheme {
+	case "nats":
+	case "nats+tls", "tls":
+		n.tls = true
+	default:
+		return nil, fmt.Errorf("nats: unsupported address %q", target)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("nats: no host in %q", target)
+	}
+	n.addr = u.Host
+	if u.Port() == "" {
+		n.addr = net.JoinHostPort(u.Hostname(), "4222")
+	}
+	if n.opts.Timeout <= 0 {
+		n.opts.Timeout = BROKER_TIMEOUT
+	}
+	if n.opts.ClientID == "" {
+		n.opts.ClientID = clientID()
+	}
+
+	n.mx.Lock()
+	defer n.mx.Unlock()
+	if err := n.connect(); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// Установить соединение: INFO, CONNECT, PING/PONG.
+func (n *NATS) connect() error {
+	conn, err := net.DialTimeout("tcp", n.addr, n.opts.Timeout)
+	if err != nil {
+		return fmt.Errorf("nats: %w", err)
+	}
+	n.conn, n.r = conn, bufio.NewReader(conn)
+	conn.SetDeadline(time.Now().Add(n.opts.Timeout))
+
+	line, err := n.r.ReadString('\n')
+	if err != nil || !strings.HasPrefix(line, "INFO ") {
+		n.drop()
+		return fmt.Errorf("nats: %s: bad INFO: %v", n.addr, err)
+	}
+	var info struct {
+		TLSRequired bool `json:"tls_required"`
+	}
+	json.Unmarshal([]byte(line[5:]), &info)
+	if n.tls || info.TLSRequired {
+		cfg := n.opts.TLSConfig
+		if cfg == nil {
+			host, _, _ := net.SplitHostPort(n.addr)
+			cfg = &tls.Config{ServerName: host}
+		}
+		tc := tls.Client(conn, cfg)
+		if err := tc.Handshake(); err != nil {
+			n.drop()
+			return fmt.Errorf("nats: %w", err)
+		}
+		n.conn, n.r = tc, bufio.NewReader(tc)
+	}
+
+	connect := map[string]any{
+		"verbose": false, "pedantic": false, "lang": "go", "version": "2",
+		"protocol": 1, "name": n.opts.ClientID, "tls_required": n.tls}
+	if n.user != nil {
+		if pass, ok := n.user.Password(); ok {
+			connect["user"], connect["pass"] = n.user.Username(), pass
+		} else {
+			connect["auth_token"] = n.user.Username()
+		}
+	}
+	data, _ := json.Marshal(connect)
+	if _, err := fmt.Fprintf(n.conn, "CONNECT %s\r\nPING\r\n", data); err != nil {
+		n.drop()
+		return fmt.Errorf("nats: %w", err)
+	}
+	if err := n.pong(); err != nil {
+		n.drop()
+		return err
+	}
+	n.conn.SetDeadline(time.Time{})
+	return nil
+}
+
+// Ожидать PONG (ответ на PING от сервера, -ERR - ошибка).
+func (n *NATS) pong() error {
+	for {
+		line, err := n.r.ReadString('\n')
+		if err != nil {
+			return fmt.Errorf("nats: %w", err)
+		}
+		line = strings.TrimRight(line, "\r\n")
+		switch {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			if _, err := n.conn.Write([]byte("PONG\r\n")); err != nil {
+				return fmt.Errorf("nats: %w", err)
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("nats: %s", line)
+		}
+		// +OK, INFO: skip
+	}
+}
+
+// Закрыть соединение после ошибки.
+func (n *NATS) drop() {
+	if n.conn != nil {
+		n.conn.Close()
+		n.conn, n.r = nil, nil
+	}
+}
+
+// Опубликовать сообщение (subject - тема).
+func (n *NATS) Publish(subject string, payload []byte) error {
+	n.mx.Lock()
+	defer n.mx.Unlock()
+	if n.conn == nil {
+		if err := n.connect(); err != nil {
+			return err
+		}
+	}
+	n.conn.SetDeadline(time.Now().Add(n.opts.Timeout))
+	defer func() {
+		if n.conn != nil {
+			n.conn.SetDeadline(time.Time{})
+		}
+	}()
+	msg := fmt.Appendf(nil, "PUB %s %d\r\n", subject, len(payload))
+	msg = append(append(msg, payload...), "\r\nPING\r\n"...)
+	if _, err := n.conn.Write(msg); err != nil {
+		n.drop()
+		return fmt.Errorf("nats: %w", err)
+	}
+	if err := n.pong(); err != nil {
+		n.drop()
+		return err
+	}
+	return nil
+}
+
+// Закрыть соединение.
+func (n *NATS) Close() error {
+	n.mx.Lock()
+	defer n.mx.Unlock()
+	n.drop()
+	return nil
+}
+
+// Имя клиента по умолчанию.
+func clientID() string {
+	host, _ := os.Hostname()
+	return "gousers-" + host
+}
+
+// EOF: "nats.go"
@@ -0,0 +1,237 @@
+// File: "publish.go"
+
+package sink
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/azorg/gousers/v2/dto"
+	"github.com/azorg/gousers/v2/pkg/utmp"
+)
+
+// Форматы полезной нагрузки сообщений.
+// Message payload formats.
+const (
+	PAYLOAD_JSON     = "json"     // dto.Webhook
+	PAYLOAD_PROTOBUF = "protobuf" // Entries (pkg/utmpgrpc/gousers.proto)
+)
+
+// Число повторов публикации по умолчанию.
+// Default number of publish retries.
+const PUBLISH_RETRIES = 5
+
+// Отправитель сообщений брокеру (NATS, MQTT, Kafka). Publish() возвращает
+// управление после подтверждения приёма брокером и переподключается после
+// ошибки при следующем вызове.
+// Message broker publisher.
+type Publisher interface {
+	// Опубликовать сообщение в теме (subject, topic)
+	Publish(topic string, payload []byte) error
+
+	// Закрыть соединение
+	Close() error
+}
+
+// Создать отправитель по схеме адреса: nats://, nats+tls:// (NATS),
+// mqtt://, mqtts:// (MQTT), kafka+http://, kafka+https:// (Kafka REST Proxy).
+// Create publisher by address scheme.
+func NewPublisher(target string, opts BrokerOpts) (Publisher, error) {
+	scheme, _, _ := strings.Cut(target, "://")
+	switch scheme {
+	case "nats", "nats+tls":
+		return NewNATS(target, opts)
+	case "mqtt", "mqtts":
+		return NewMQTT(target, opts)
+	case "kafka+http", "kafka+https":
+		return NewKafka(target, nil, opts)
+	}
+	return nil, fmt.Errorf("publish: unsupported broker address %q", target)
+}
+
+// Шаблон темы по умолчанию для адреса брокера (разделитель NATS - ".",
+// MQTT - "/", темы Kafka - без подстановок).
+// Default topic template of broker.
+func DefaultTopic(target string) string {
+	switch {
+	case strings.HasPrefix(target, "nats"):
+		return "gousers.{host}.{action}"
+	case strings.HasPrefix(target, "mqtt"):
+		return "gousers/{host}/{action}"
+	}
+	return "gousers"
+}
+
+// Опции публикации.
+// Publish sink options.
+type PublishOpts struct {
+	// Шаблон темы: {host}, {action}, {user}, {type} заменяются значениями
+	// (символы . / + # * > и пробелы в значениях - на "_")
+	Topic string
+
+	Payload  string        // PAYLOAD_JSON ("") или PAYLOAD_PROTOBUF
+	Retries  int           // число повторов (0 - PUBLISH_RETRIES, <0 - без повторов)
+	Backoff  time.Duration // начальная задержка повтора (0 - WEBHOOK_BACKOFF)
+	Hostname string        // имя узла ("" - os.Hostname())
+}
+
+// Получатель-публикатор: каждый вход/выход - отдельное сообщение
+// в теме по шаблону, публикация повторяется до подтверждения брокером
+// (доставка "хотя бы один раз", возможны повторы сообщений).
+// Publish sink.
+type Publish struct {
+	p    Publisher
+	opts PublishOpts
+}
+
+// Создать получатель, публикующий входы/выходы через p.
+// Create publish sink.
+func NewPublish(p Publisher, opts PublishOpts) (*Publish, error) {
+	if opts.Topic == "" {
+		return nil, fmt.Errorf("publish: no topic")
+	}
+	switch opts.Payload {
+	case "":
+		opts.Payload = PAYLOAD_JSON
+	case PAYLOAD_JSON, PAYLOAD_PROTOBUF:
+	default:
+		return nil, fmt.Errorf("publish: unknown payload format %q (json or protobuf)", opts.Payload)
+	}
+	if opts.Retries == 0 {
+		opts.Retries = PUBLISH_RETRIES
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = WEBHOOK_BACKOFF
+	}
+	if opts.Hostname == "" {
+		opts.Hostname, _ = os.Hostname()
+	}
+	return &Publish{p: p, opts: opts}, nil
+}
+
+// Опубликовать входы/выходы события.
+func (s *Publish) Send(evt utmp.LoginEvent) error {
+	for _, e := range Entries(evt) {
+		topic := Topic(s.opts.Topic, s.opts.Hostname, e)
+		payload, err := s.payload(e)
+		if err != nil {
+			return err
+		}
+		backoff := s.opts.Backoff
+		for try := 0; ; try++ {
+			err = s.p.Publish(topic, payload)
+			if err == nil {
+				break
+			}
+			if try >= s.opts.Retries {
+				return fmt.Errorf("publish %s: %w", topic, err)
+			}
+			time.Sleep(backoff)
+			backoff = min(2*backoff, WEBHOOK_BACKOFF_MAX)
+		}
+	}
+	return nil
+}
+
+// Закрыть соединение с брокером.
+func (s *Publish) Close() error {
+	return s.p.Close()
+}
+
+// Полезная нагрузка сообщения.
+func (s *Publish) payload(e Entry) ([]byte, error) {
+	batch := []dto.Entry{e.DTO()}
+	msg := dto.Webhook{Host: s.opts.Hostname, Text: Summary(s.opts.Hostname, batch),
+		Events: batch, Labels: e.Labels}
+	if s.opts.Payload == PAYLOAD_PROTOBUF {
+		return encodeEntries(msg), nil
+	}
+	return json.Marshal(&msg)
+}
+
+// Подставить значения в шаблон темы.
+// Expand topic template.
+func Topic(tmpl, host string, e Entry) string {
+	return strings.NewReplacer(
+		"{host}", topicValue(host),
+		"{action}", e.Action,
+		"{user}", topicValue(e.User),
+		"{type}", topicValue(dto.LogonType[e.Type]),
+	).Replace(tmpl)
+}
+
+// Значение для подстановки: без разделителей и шаблонов NATS/MQTT.
+func topicValue(s string) string {
+	if s == "" {
+		return "_"
+	}
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune("./+#*>", r) || r <= ' ' {
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+// Закодировать сообщение Entries (gousers.proto, proto3: поля со
+// значением по умолчанию не записываются).
+func encodeEntries(m dto.Webhook) []byte {
+	var b []byte
+	bytes := func(field int, v []byte) {
+		b = binary.AppendUvarint(b, uint64(field)<<3|2)
+		b = binary.AppendUvarint(b, uint64(len(v)))
+		b = append(b, v...)
+	}
+	str := func(field int, v string) {
+		if v != "" {
+			bytes(field, []byte(v))
+		}
+	}
+	str(1, m.Host)
+	str(2, m.Text)
+	for _, e := range m.Events {
+		outer := b
+		b = nil
+		str(1, e.Action)
+		str(2, e.User)
+		str(3, e.TTY)
+		str(4, e.Host)
+		str(5, e.IP)
+		str(6, e.LogonType)
+		if e.Privileged {
+			b = append(b, 7<<3, 1)
+		}
+		if !e.Time.IsZero() { // google.protobuf.Timestamp
+			var ts []byte
+			if sec := e.Time.Unix(); sec != 0 {
+				ts = binary.AppendUvarint(append(ts, 1<<3), uint64(sec))
+			}
+			if ns := e.Time.Nanosecond(); ns != 0 {
+				ts = binary.AppendUvarint(append(ts, 2<<3), uint64(ns))
+			}
+			bytes(8, ts)
+		}
+		if e.Seq != 0 {
+			b = binary.AppendUvarint(append(b, 9<<3), e.Seq)
+		}
+		entry := b
+		b = outer
+		bytes(3, entry)
+	}
+	for _, k := range LabelNames(m.Labels) { // map<string, string>
+		outer := b
+		b = nil
+		str(1, k)
+		str(2, m.Labels[k])
+		entry := b
+		b = outer
+		bytes(4, entry)
+	}
+	return b
+}
+
+// EOF: "publish.go"
@@ -0,0 +1,200 @@
+// File: "publish_test.go"
+
+package sink
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/azorg/gousers/v2/dto"
+)
+
+// Опубликованное сообщение.
+type published struct {
+	topic   string
+	payload []byte
+}
+
+func TestTopic(t *testing.T) {
+	e := Entries(testEvent())[0]
+	require.Equal(t, "gousers.web_1.login.root.remote",
+		Topic("gousers.{host}.{action}.{user}.{type}", "web.1", e))
+	require.Equal(t, "gousers/h/_/x", Topic("gousers/h/{user}/x", "h", Entry{}))
+}
+
+func TestPublishPayload(t *testing.T) {
+	var got []published
+	p := publisherFunc(func(topic string, payload []byte) error {
+		got = append(got, published{topic, payload})
+		if len(got) == 1 {
+			return fmt.Errorf("broker down") // retried
+		}
+		return nil
+	})
+	s, err := NewPublish(p, PublishOpts{Topic: "t.{action}", Hostname: "h1", Backoff: 1})
+	require.NoError(t, err)
+	evt := testEvent()
+	evt.Labels = map[string]string{"dc": "eu-1"}
+	require.NoError(t, s.Send(evt))
+	require.Len(t, got, 3)
+	require.Equal(t, "t.login", got[1].topic)
+	require.Equal(t, "t.logout", got[2].topic)
+	var msg dto.Webhook
+	require.NoError(t, json.Unmarshal(got[1].payload, &msg))
+	require.Equal(t, "h1", msg.Host)
+	require.Equal(t, "root", msg.Events[0].User)
+	require.Equal(t, evt.Labels, msg.Labels)
+
+	got = nil
+	s, err = NewPublish(p, PublishOpts{Topic: "t", Payload: PAYLOAD_PROTOBUF, Hostname: "h1", Retries: -1})
+	require.NoError(t, err)
+	require.Error(t, s.Send(testEvent())) // no retries
+	require.NoError(t, s.Send(testEvent()))
+	// Entries.host = "h1" (field 1), then text (field 2)
+	require.Equal(t, []byte{1<<3 | 2, 2, 'h', '1', 2<<3 | 2}, got[1].payload[:5])
+	require.NoError(t, s.Send(evt))
+	// Entries.labels (field 4): map entry {key = "dc", value = "eu-1"}
+	require.True(t, bytes.HasSuffix(got[3].payload, []byte{4<<3 | 2, 10,
+		1<<3 | 2, 2, 'd', 'c', 2<<3 | 2, 4, 'e', 'u', '-', '1'}))
+
+	_, err = NewPublish(p, PublishOpts{Topic: "t", Payload: "avro"})
+	require.Error(t, err)
+	_, err = NewPublish(p, PublishOpts{})
+	require.Error(t, err)
+}
+
+type publisherFunc func(topic string, payload []byte) error
+
+func (f publisherFunc) Publish(topic string, payload []byte) error { return f(topic, payload) }
+func (f publisherFunc) Close() error                               { return nil }
+
+func TestNATS(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	got := make(chan published, 1)
+	go func() { // minimal NATS server
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		fmt.Fprint(conn, "INFO {\"server_id\":\"test\"}\r\n")
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			f := strings.Fields(line)
+			switch f[0] {
+			case "CONNECT":
+				if !strings.Contains(line, `"pass":"p"`) || !strings.Contains(line, `"user":"u"`) {
+					fmt.Fprint(conn, "-ERR 'Authorization Violation'\r\n")
+					return
+				}
+			case "PING":
+				fmt.Fprint(conn, "PONG\r\n")
+			case "PUB":
+				var n int
+				fmt.Sscan(f[2], &n)
+				payload := make([]byte, n+2)
+				io.ReadFull(r, payload)
+				got <- published{f[1], payload[:n]}
+			}
+		}
+	}()
+
+	n, err := NewNATS("nats://u:p@"+ln.Addr().String(), BrokerOpts{})
+	require.NoError(t, err)
+	require.NoError(t, n.Publish("gousers.h1.login", []byte("hello")))
+	require.Equal(t, published{"gousers.h1.login", []byte("hello")}, <-got)
+	require.NoError(t, n.Close())
+
+	_, err = NewNATS("http://x", BrokerOpts{})
+	require.Error(t, err)
+}
+
+func TestMQTT(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	got := make(chan published, 1)
+	go func() { // minimal MQTT broker
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		m := &MQTT{conn: conn, r: bufio.NewReader(conn)}
+		for {
+			typ, body, err := m.read()
+			if err != nil {
+				return
+			}
+			switch typ {
+			case mqttCONNECT:
+				m.write(mqttCONNACK<<4, []byte{0, 0})
+			case mqttPUBLISH:
+				n := int(binary.BigEndian.Uint16(body))
+				topic, id := string(body[2:2+n]), body[2+n:4+n]
+				got <- published{topic, body[4+n:]}
+				m.write(mqttPUBACK<<4, id)
+			}
+		}
+	}()
+
+	m, err := NewMQTT("mqtt://"+ln.Addr().String(), BrokerOpts{ClientID: "c1"})
+	require.NoError(t, err)
+	payload := []byte(strings.Repeat("x", 200)) // 2 byte remaining length
+	require.NoError(t, m.Publish("gousers/h1/login", payload))
+	require.Equal(t, published{"gousers/h1/login", payload}, <-got)
+	require.NoError(t, m.Close())
+
+	_, err = NewMQTT("mqtt://", BrokerOpts{})
+	require.Error(t, err)
+}
+
+func TestKafka(t *testing.T) {
+	got := make(chan published, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, KAFKA_CONTENT_TYPE, r.Header.Get("Content-Type"))
+		var req struct {
+			Records []struct{ Value string } `json:"records"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		value, err := base64.StdEncoding.DecodeString(req.Records[0].Value)
+		require.NoError(t, err)
+		if strings.HasSuffix(r.URL.Path, "/bad") {
+			fmt.Fprint(w, `{"offsets":[{"partition":null,"offset":null,"error_code":40403,"error":"unknown topic"}]}`)
+			return
+		}
+		got <- published{r.URL.Path, value}
+		fmt.Fprint(w, `{"offsets":[{"partition":0,"offset":12,"error_code":null,"error":null}]}`)
+	}))
+	defer srv.Close()
+
+	k, err := NewKafka("kafka+"+srv.URL+"/proxy/", nil, BrokerOpts{})
+	require.NoError(t, err)
+	require.NoError(t, k.Publish("gousers", []byte("hello")))
+	require.Equal(t, published{"/proxy/topics/gousers", []byte("hello")}, <-got)
+	require.ErrorContains(t, k.Publish("bad", []byte("x")), "unknown topic")
+	require.NoError(t, k.Close())
+
+	_, err = NewKafka("kafka://broker:9092", nil, BrokerOpts{})
+	require.Error(t, err)
+}
+
+// EOF: "publish_test.go"
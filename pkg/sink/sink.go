@@ -0,0 +1,166 @@
+// File: "sink.go"
+
+/*
+Пакет `sink` - передача событий входа/выхода `utmp.Login` внешним
+получателям (syslog, systemd-journald и т.п.).
+
+Получатель реализует интерфейс `Sink`, функция Forward() подписывается
+на события службы и передаёт их получателю в отдельной горутине:
+
+	s, err := sink.NewSyslog("udp://loghost:514", sink.SyslogOpts{})
+	if err != nil {
+		log.Fatal(err)
+	}
+	stop := sink.Forward(l, s, func(err error) { log.Print(err) })
+	defer stop()
+
+Получатели: Syslog (RFC 5424), Journal (systemd-journald), Webhook
+(HTTP POST) и Publish (брокеры сообщений NATS, MQTT, Kafka через
+Publisher, протоколы реализованы без внешних зависимостей).
+
+Адрес удалённого узла (host, ip) входа определяется по исходным записям
+utmp, поэтому служба создаётся с опцией LoginOpts.RawRecords.
+
+Package sink forwards login/logout events to external receivers.
+*/
+package sink
+
+import (
+	"net"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/azorg/gousers/v2/pkg/utmp"
+)
+
+// Получатель событий входа/выхода.
+// Event receiver.
+type Sink interface {
+	// Передать событие (вызывается из одной горутины)
+	Send(evt utmp.LoginEvent) error
+
+	// Завершить работу (закрыть соединение)
+	Close() error
+}
+
+// Вход или выход одного пользователя (плоское представление события
+// для получателей).
+// Single login or logout.
+type Entry struct {
+	Action     string         // "login" or "logout"
+	User       string         // User name
+	TTY        string         // TTY device
+	Host       string         // Remote host (from utmp record, "" - unknown)
+	IP         net.IP         // Remote IP address (nil - unknown)
+	Type       utmp.LoginType // Logon type
+	Privileged bool           // Privileged user (see utmp.IsPrivileged())
+	Time       time.Time      // Event time
+	Seq        uint64         // Event sequence number
+
+	Labels map[string]string // Static instance labels (see utmp.Labels())
+}
+
+// Удалённый ли вход (по типу входа).
+// Remote login.
+func (e Entry) Remote() bool {
+	return e.Type == utmp.REMOTE || e.Type == utmp.REMOTE_X
+}
+
+// Разбить событие на входы и выходы пользователей (сначала входы).
+// Host и IP заполняются по исходным записям события (LoginEvent.Records).
+// Split event into entries.
+func Entries(evt utmp.LoginEvent) []Entry {
+	var list []Entry
+	add := func(action string, ut utmp.UserTTY) {
+		e := Entry{
+			Action:     action,
+			User:       ut.User,
+			TTY:        ut.TTY,
+			Type:       evt.Types[ut],
+			Privileged: utmp.IsPrivileged(ut.User),
+			Time:       evt.Time,
+			Seq:        evt.Seq,
+			Labels:     evt.Labels}
+		for i := range evt.Records {
+			r := evt.Records[i].Decode()
+			if r.Line != ut.TTY || r.User != ut.User && r.User != "" {
+				continue // DEAD_PROCESS records may have no user
+			}
+			if r.Host != "" {
+				e.Host = r.Host
+			}
+			if len(r.IP) != 0 {
+				e.IP = r.IP
+			}
+		}
+		list = append(list, e)
+	}
+	for _, ut := range evt.Login {
+		add("login", ut)
+	}
+	for _, ut := range evt.Logout {
+		add("logout", ut)
+	}
+	return list
+}
+
+// Имена меток по возрастанию.
+// Sorted label names.
+func LabelNames(labels map[string]string) []string {
+	names := make([]string, 0, len(labels))
+	for k := range labels {
+		names = append(names, k)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Передавать события службы l получателю s до завершения службы или
+// вызова stop() (stop() закрывает получатель). Ошибки передачи
+// передаются функции errFn (nil - не сообщать). Исторические события
+// (Login.Replay()) не передаются.
+// Forward events to sink.
+func Forward(l *utmp.Login, s Sink, errFn func(error)) (stop func()) {
+	return ForwardWith(l, s, utmp.SubscribeOpts{}, errFn)
+}
+
+// Вариант Forward() с фильтром событий (пользователи, типы входа, см.
+// utmp.SubscribeOpts). Буфер 0 заменяется на LOGIN_QUEUE, OVERFLOW_BLOCK -
+// на OVERFLOW_DROP_OLDEST: медленный получатель не задерживает службу.
+// Forward filtered events to sink.
+func ForwardWith(l *utmp.Login, s Sink, opts utmp.SubscribeOpts, errFn func(error)) (stop func()) {
+	if opts.Buffer == 0 {
+		opts.Buffer = utmp.LOGIN_QUEUE
+	}
+	if opts.Overflow == utmp.OVERFLOW_BLOCK {
+		opts.Overflow = utmp.OVERFLOW_DROP_OLDEST
+	}
+	events := l.SubscribeWith(opts)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for evt := range events {
+			if evt.Historical || len(evt.Login)+len(evt.Logout) == 0 {
+				continue
+			}
+			if err := s.Send(evt); err != nil && errFn != nil {
+				errFn(err)
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.Unsubscribe(events)
+			wg.Wait()
+			if err := s.Close(); err != nil && errFn != nil {
+				errFn(err)
+			}
+		})
+	}
+}
+
+// EOF: "sink.go"
@@ -0,0 +1,287 @@
+// File: "syslog.go"
+
+package sink
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/azorg/gousers/v2/dto"
+	"github.com/azorg/gousers/v2/pkg/utmp"
+)
+
+// Имя приложения (APP-NAME) по умолчанию.
+// Default syslog application name.
+const SYSLOG_APP = "gousers"
+
+// Идентификатор структурированных данных (SD-ID) по умолчанию
+// (32473 - номер предприятия IANA для примеров и документации).
+// Default structured data ID.
+const SYSLOG_SD_ID = "gousers@32473"
+
+// Идентификатор структурированных данных меток экземпляра службы.
+// Structured data ID of instance labels.
+const SYSLOG_LABELS_SD_ID = "labels@32473"
+
+// Категория (facility) по умолчанию: authpriv.
+// Default syslog facility.
+const SYSLOG_FACILITY = 10
+
+// Время ожидания соединения/записи по умолчанию.
+// Default syslog I/O timeout.
+const SYSLOG_TIMEOUT = 5 * time.Second
+
+// Уровни важности (severity).
+const (
+	SYSLOG_NOTICE = 5
+	SYSLOG_INFO   = 6
+)
+
+// Пути локального syslog.
+var syslogPaths = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+// Опции получателя syslog.
+// Syslog sink options.
+type SyslogOpts struct {
+	Facility  int           // категория (0 - SYSLOG_FACILITY)
+	AppName   string        // APP-NAME ("" - SYSLOG_APP)
+	SDID      string        // SD-ID ("" - SYSLOG_SD_ID)
+	Hostname  string        // HOSTNAME ("" - os.Hostname())
+	TLSConfig *tls.Config   // настройки TLS (tls://, nil - по умолчанию)
+	Timeout   time.Duration // время ожидания (0 - SYSLOG_TIMEOUT)
+}
+
+// Получатель syslog: входы/выходы передаются сообщениями RFC 5424
+// со структурированными данными [gousers@32473 user tty host ip type]
+// и метками экземпляра службы [labels@32473 ...] (удалённый syslog) или
+// сообщениями в формате syslog(3) (локальный, метки - в конце текста).
+// Входы/выходы привилегированных пользователей имеют уровень notice,
+// остальных - info.
+// Syslog sink.
+type Syslog struct {
+	network string // udp, tcp, tls, unix ("" - local syslog)
+	addr    string
+	opts    SyslogOpts
+	pid     int
+	mx      sync.Mutex
+	conn    net.Conn
+}
+
+// Создать получатель syslog по адресу target:
+//
+//	"" или "local"        - локальный syslog (/dev/log)
+//	unix:///path          - локальный сокет
+//	udp://host[:514]      - RFC 5424 по UDP (RFC 5426)
+//	tcp://host[:514]      - RFC 5424 по TCP (RFC 6587, подсчёт октетов)
+//	tls://host[:6514]     - RFC 5424 по TLS (RFC 5425)
+//
+// Create syslog sink.
+func NewSyslog(target string, opts SyslogOpts) (*Syslog, error) {
+	s := &Syslog{opts: opts, pid: os.Getpid()}
+	if s.opts.Facility == 0 {
+		s.opts.Facility = SYSLOG_FACILITY
+	}
+	if s.opts.AppName == "" {
+		s.opts.AppName = SYSLOG_APP
+	}
+	if s.opts.SDID == "" {
+		s.opts.SDID = SYSLOG_SD_ID
+	}
+	if s.opts.Hostname == "" {
+		s.opts.Hostname, _ = os.Hostname()
+	}
+	if s.opts.Timeout <= 0 {
+		s.opts.Timeout = SYSLOG_TIMEOUT
+	}
+
+	if target != "" && target != "local" {
+		u, err := url.Parse(target)
+		if err != nil {
+			return nil, fmt.Errorf("syslog: %w", err)
+		}
+		port := ""
+		switch u.Scheme {
+		case "unix":
+			s.network, s.addr = "unix", u.Path
+		case "udp", "tcp":
+			s.network, s.addr, port = u.Scheme, u.Host, "514"
+		case "tls":
+			s.network, s.addr, port = u.Scheme, u.Host, "6514"
+		default:
+			return nil, fmt.Errorf("syslog: unsupported address %q", target)
+		}
+		if s.addr == "" {
+			return nil, fmt.Errorf("syslog: no address in %q", target)
+		}
+		if port != "" && u.Port() == "" {
+			s.addr = net.JoinHostPort(u.Hostname(), port)
+		}
+	}
+
+	if err := s.connect(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Установить соединение.
+func (s *Syslog) connect() error {
+	var err error
+	dialer := &net.Dialer{Timeout: s.opts.Timeout}
+	switch s.network {
+	case "", "unix":
+		paths := syslogPaths
+		if s.network == "unix" {
+			paths = []string{s.addr}
+		}
+		for _, path := range paths {
+			for _, network := range []string{"unixgram", "unix"} {
+				s.conn, err = dialer.Dial(network, path)
+				if err == nil {
+					return nil
+				}
+			}
+		}
+	case "tls":
+		s.conn, err = tls.DialWithDialer(dialer, "tcp", s.addr, s.opts.TLSConfig)
+	default:
+		s.conn, err = dialer.Dial(s.network, s.addr)
+	}
+	if err != nil {
+		return fmt.Errorf("syslog: %w", err)
+	}
+	return nil
+}
+
+// Передать входы/выходы события.
+func (s *Syslog) Send(evt utmp.LoginEvent) error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	for _, e := range Entries(evt) {
+		if err := s.write(s.Format(e)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Записать сообщение (с одной попыткой переподключения).
+func (s *Syslog) write(msg string) error {
+	if s.network == "tcp" || s.network == "tls" {
+		msg = fmt.Sprintf("%d %s", len(msg), msg) // octet counting
+	}
+	var err error
+	for try := 0; try < 2; try++ {
+		if s.conn == nil {
+			if err = s.connect(); err != nil {
+				continue
+			}
+		}
+		s.conn.SetWriteDeadline(time.Now().Add(s.opts.Timeout))
+		if _, err = s.conn.Write([]byte(msg)); err == nil {
+			return nil
+		}
+		s.conn.Close()
+		s.conn = nil
+	}
+	return fmt.Errorf("syslog: %w", err)
+}
+
+// Закрыть соединение.
+func (s *Syslog) Close() error {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// Сформировать сообщение syslog входа/выхода.
+// Format syslog message.
+func (s *Syslog) Format(e Entry) string {
+	severity := SYSLOG_INFO
+	if e.Privileged {
+		severity = SYSLOG_NOTICE
+	}
+	pri := s.opts.Facility*8 + severity
+
+	params := [][2]string{{"user", e.User}, {"tty", e.TTY}}
+	if e.Host != "" {
+		params = append(params, [2]string{"host", e.Host})
+	}
+	if len(e.IP) != 0 {
+		params = append(params, [2]string{"ip", e.IP.String()})
+	}
+	params = append(params, [2]string{"type", dto.LogonType[e.Type]})
+
+	var text strings.Builder
+	text.WriteString(e.Action)
+	for _, p := range params {
+		if p[1] != "" {
+			fmt.Fprintf(&text, " %s=%s", p[0], p[1])
+		}
+	}
+
+	if s.network == "" || s.network == "unix" { // syslog(3) format
+		for _, k := range LabelNames(e.Labels) {
+			fmt.Fprintf(&text, " %s=%s", k, e.Labels[k])
+		}
+		return fmt.Sprintf("<%d>%s %s[%d]: %s", pri,
+			e.Time.Format(time.Stamp), s.opts.AppName, s.pid, text.String())
+	}
+
+	var sd strings.Builder
+	sd.WriteString("[" + s.opts.SDID)
+	for _, p := range params {
+		fmt.Fprintf(&sd, " %s=\"%s\"", p[0], sdEscaper.Replace(p[1]))
+	}
+	sd.WriteString("]")
+	if len(e.Labels) != 0 {
+		sd.WriteString("[" + SYSLOG_LABELS_SD_ID)
+		for _, k := range LabelNames(e.Labels) {
+			fmt.Fprintf(&sd, " %s=\"%s\"", sdName(k), sdEscaper.Replace(e.Labels[k]))
+		}
+		sd.WriteString("]")
+	}
+	return fmt.Sprintf("<%d>1 %s %s %s %d %s %s %s", pri,
+		e.Time.Format("2006-01-02T15:04:05.000000Z07:00"),
+		nilValue(s.opts.Hostname), nilValue(s.opts.AppName), s.pid,
+		e.Action, sd.String(), text.String())
+}
+
+// Экранирование значения SD-PARAM (RFC 5424, 6.3.3).
+var sdEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+// Имя SD-PARAM (RFC 5424, 6.3.3): печатные символы ASCII, кроме '=',
+// ' ', ']', '"', не длиннее 32 символов (недопустимые заменяются "_").
+func sdName(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if c <= ' ' || c >= 127 || c == '=' || c == ']' || c == '"' {
+			b[i] = '_'
+		}
+	}
+	if len(b) > 32 {
+		b = b[:32]
+	}
+	return string(b)
+}
+
+// Пустое поле заголовка RFC 5424 - NILVALUE ("-").
+func nilValue(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// EOF: "syslog.go"
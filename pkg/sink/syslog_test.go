@@ -0,0 +1,135 @@
+// File: "syslog_test.go"
+
+package sink
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/azorg/gousers/v2/pkg/utmp"
+)
+
+// Событие входа root с удалённого узла и выхода alice.
+func testEvent() utmp.LoginEvent {
+	root := utmp.UserTTY{User: "root", TTY: "pts/0"}
+	alice := utmp.UserTTY{User: "alice", TTY: "tty1"}
+	u := utmp.Utmp{Type: utmp.USER_PROCESS}
+	copy8(u.User[:], "root")
+	copy8(u.Line[:], "pts/0")
+	copy8(u.Host[:], "bastion]\"1")
+	u.AddrV6[0] = 0x0100000a // 10.0.0.1
+	return utmp.LoginEvent{
+		Time:    time.Date(2026, 10, 15, 12, 30, 0, 0, time.UTC),
+		Seq:     7,
+		Login:   []utmp.UserTTY{root},
+		Logout:  []utmp.UserTTY{alice},
+		Types:   map[utmp.UserTTY]utmp.LoginType{root: utmp.REMOTE, alice: utmp.LOCAL},
+		Records: []utmp.Utmp{u}}
+}
+
+func TestEntries(t *testing.T) {
+	list := Entries(testEvent())
+	require.Len(t, list, 2)
+	require.Equal(t, "login", list[0].Action)
+	require.Equal(t, "bastion]\"1", list[0].Host)
+	require.Equal(t, "10.0.0.1", list[0].IP.String())
+	require.True(t, list[0].Privileged)
+	require.True(t, list[0].Remote())
+	require.Equal(t, "logout", list[1].Action)
+	require.Equal(t, "", list[1].Host)
+	require.Nil(t, list[1].IP)
+	require.False(t, list[1].Remote())
+	require.Nil(t, list[0].Labels)
+
+	evt := testEvent()
+	evt.Labels = map[string]string{"dc": "eu-1"}
+	list = Entries(evt)
+	require.Equal(t, evt.Labels, list[0].Labels)
+	require.Equal(t, evt.Labels, list[1].Labels)
+	require.Equal(t, []string{"a", "b", "c"}, LabelNames(map[string]string{"c": "", "a": "", "b": ""}))
+}
+
+func TestSyslogUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer pc.Close()
+
+	s, err := NewSyslog("udp://"+pc.LocalAddr().String(), SyslogOpts{Hostname: "h1"})
+	require.NoError(t, err)
+	defer s.Close()
+	evt := testEvent()
+	evt.Labels = map[string]string{"dc": "eu\"1", "rack id": "r7"}
+	require.NoError(t, s.Send(evt))
+
+	buf := make([]byte, 2048)
+	pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := pc.ReadFrom(buf)
+	require.NoError(t, err)
+	msg := string(buf[:n])
+	require.True(t, strings.HasPrefix(msg, "<85>1 2026-10-15T12:30:00.000000Z h1 gousers "), msg)
+	require.Contains(t, msg, ` login [gousers@32473 user="root" tty="pts/0" `+
+		`host="bastion\]\"1" ip="10.0.0.1" type="remote"]`+
+		`[labels@32473 dc="eu\"1" rack_id="r7"] login user=root`)
+
+	n, _, err = pc.ReadFrom(buf)
+	require.NoError(t, err)
+	msg = string(buf[:n])
+	require.True(t, strings.HasPrefix(msg, "<86>1 "), msg)
+	require.Contains(t, msg, ` logout [gousers@32473 user="alice" tty="tty1" type="local"]`+
+		`[labels@32473 dc="eu\"1" rack_id="r7"] logout`)
+}
+
+func TestSyslogTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	lines := make(chan string, 2)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for {
+			var n int
+			if _, err := fmt.Fscanf(r, "%d ", &n); err != nil {
+				return
+			}
+			msg := make([]byte, n)
+			if _, err := io.ReadFull(r, msg); err != nil {
+				return
+			}
+			lines <- string(msg)
+		}
+	}()
+
+	s, err := NewSyslog("tcp://"+ln.Addr().String(), SyslogOpts{Facility: 4})
+	require.NoError(t, err)
+	require.NoError(t, s.Send(testEvent()))
+	require.NoError(t, s.Close())
+	require.True(t, strings.HasPrefix(<-lines, "<37>1 "))
+	require.True(t, strings.HasPrefix(<-lines, "<38>1 "))
+}
+
+func TestSyslogTarget(t *testing.T) {
+	for _, target := range []string{"http://x", "udp://", "::"} {
+		_, err := NewSyslog(target, SyslogOpts{})
+		require.Error(t, err, target)
+	}
+}
+
+func copy8(dst []int8, s string) {
+	for i := range s {
+		dst[i] = int8(s[i])
+	}
+}
+
+// EOF: "syslog_test.go"
@@ -0,0 +1,316 @@
+// File: "webhook.go"
+
+package sink
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/azorg/gousers/v2/dto"
+	"github.com/azorg/gousers/v2/pkg/utmp"
+)
+
+// Заголовок подписи запроса: "sha256=<hex HMAC-SHA256 тела запроса>".
+// Signature header.
+const WEBHOOK_SIGNATURE = "X-Gousers-Signature"
+
+// Число повторов отправки по умолчанию.
+// Default number of retries.
+const WEBHOOK_RETRIES = 5
+
+// Начальная и наибольшая задержка повтора по умолчанию (задержка
+// удваивается с каждым повтором).
+// Default retry backoff.
+const (
+	WEBHOOK_BACKOFF     = time.Second
+	WEBHOOK_BACKOFF_MAX = 30 * time.Second
+)
+
+// Период отправки неполного пакета по умолчанию.
+// Default flush interval of incomplete batch.
+const WEBHOOK_FLUSH = time.Second
+
+// Время ожидания запроса по умолчанию.
+// Default request timeout.
+const WEBHOOK_TIMEOUT = 10 * time.Second
+
+// Размер очереди входов/выходов, ожидающих отправки.
+const webhookQueue = 1024
+
+// Опции получателя webhook.
+// Webhook sink options.
+type WebhookOpts struct {
+	Headers    map[string]string // заголовки запросов (например, авторизация)
+	Secret     string            // ключ подписи WEBHOOK_SIGNATURE ("" - без подписи)
+	Retries    int               // число повторов (0 - WEBHOOK_RETRIES, <0 - без повторов)
+	Backoff    time.Duration     // начальная задержка повтора (0 - WEBHOOK_BACKOFF)
+	BackoffMax time.Duration     // наибольшая задержка (0 - WEBHOOK_BACKOFF_MAX)
+	BatchSize  int               // входов/выходов в запросе (0 - 1)
+	Flush      time.Duration     // отправка неполного пакета (0 - WEBHOOK_FLUSH)
+	Timeout    time.Duration     // время ожидания запроса (0 - WEBHOOK_TIMEOUT)
+	Hostname   string            // имя узла ("" - os.Hostname())
+}
+
+// Получатель webhook: входы/выходы отправляются пакетами запросами POST
+// с телом JSON (dto.Webhook). Отправка выполняется в отдельной горутине,
+// при сетевой ошибке, ответе 5xx или 429 запрос повторяется с задержкой
+// (Retry-After или экспоненциальной). Ошибки отправки возвращаются
+// следующим вызовом Send() или Close().
+// Webhook sink.
+type Webhook struct {
+	url    string
+	opts   WebhookOpts
+	client *http.Client
+	queue  chan dto.Entry
+	done   chan struct{} // отмена ожидания повтора при Close()
+	wg     sync.WaitGroup
+	mx     sync.Mutex
+	err    error // последняя ошибка отправки
+	closed bool
+}
+
+// Создать получатель webhook с адресом url.
+// Create webhook sink.
+func NewWebhook(url string, opts WebhookOpts) (*Webhook, error) {
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return nil, fmt.Errorf("webhook: bad URL %q", url)
+	}
+	if opts.Retries == 0 {
+		opts.Retries = WEBHOOK_RETRIES
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = WEBHOOK_BACKOFF
+	}
+	if opts.BackoffMax <= 0 {
+		opts.BackoffMax = WEBHOOK_BACKOFF_MAX
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 1
+	}
+	if opts.Flush <= 0 {
+		opts.Flush = WEBHOOK_FLUSH
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = WEBHOOK_TIMEOUT
+	}
+	if opts.Hostname == "" {
+		opts.Hostname, _ = os.Hostname()
+	}
+	w := &Webhook{
+		url:    url,
+		opts:   opts,
+		client: &http.Client{Timeout: opts.Timeout},
+		queue:  make(chan dto.Entry, webhookQueue),
+		done:   make(chan struct{})}
+	w.wg.Add(1)
+	go w.run()
+	return w, nil
+}
+
+// Поставить входы/выходы события в очередь отправки.
+func (w *Webhook) Send(evt utmp.LoginEvent) error {
+	w.mx.Lock()
+	defer w.mx.Unlock()
+	if w.closed {
+		return errors.New("webhook: closed")
+	}
+	for _, e := range Entries(evt) {
+		select {
+		case w.queue <- e.DTO():
+		default:
+			return errors.New("webhook: queue overflow, event dropped")
+		}
+	}
+	err := w.err
+	w.err = nil
+	return err
+}
+
+// Отправить оставшиеся входы/выходы и завершить работу (повторы при
+// ошибках прекращаются).
+func (w *Webhook) Close() error {
+	w.mx.Lock()
+	if w.closed {
+		w.mx.Unlock()
+		return nil
+	}
+	w.closed = true
+	close(w.queue)
+	w.mx.Unlock()
+
+	close(w.done)
+	w.wg.Wait()
+	return w.err
+}
+
+// Горутина отправки.
+func (w *Webhook) run() {
+	defer w.wg.Done()
+	var batch []dto.Entry
+	timer := time.NewTimer(w.opts.Flush)
+	timer.Stop()
+	flush := func() {
+		if len(batch) != 0 {
+			w.setError(w.post(batch))
+			batch = nil
+		}
+	}
+	for {
+		select {
+		case e, ok := <-w.queue:
+			if !ok {
+				flush()
+				return
+			}
+			if len(batch) == 0 {
+				timer.Reset(w.opts.Flush)
+			}
+			batch = append(batch, e)
+			if len(batch) >= w.opts.BatchSize {
+				timer.Stop()
+				flush()
+			}
+		case <-timer.C:
+			flush()
+		}
+	}
+}
+
+// Запомнить ошибку отправки.
+func (w *Webhook) setError(err error) {
+	if err != nil {
+		w.mx.Lock()
+		w.err = err
+		w.mx.Unlock()
+	}
+}
+
+// Отправить пакет (с повторами).
+func (w *Webhook) post(batch []dto.Entry) error {
+	body, err := json.Marshal(dto.Webhook{
+		Host:   w.opts.Hostname,
+		Text:   Summary(w.opts.Hostname, batch),
+		Events: batch,
+		Labels: utmp.Labels()})
+	if err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+
+	backoff := w.opts.Backoff
+	for try := 0; ; try++ {
+		retry, delay, err := w.do(body)
+		if err == nil {
+			return nil
+		}
+		if !retry || try >= w.opts.Retries {
+			return fmt.Errorf("webhook: %w", err)
+		}
+		if delay <= 0 {
+			delay = backoff
+			backoff = min(2*backoff, w.opts.BackoffMax)
+		}
+		select {
+		case <-time.After(delay):
+		case <-w.done:
+			return fmt.Errorf("webhook: %w (closed while retrying)", err)
+		}
+	}
+}
+
+// Выполнить запрос: признак повтора и задержка по Retry-After.
+func (w *Webhook) do(body []byte) (retry bool, delay time.Duration, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), w.opts.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return false, 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "gousers")
+	for k, v := range w.opts.Headers {
+		req.Header.Set(k, v)
+	}
+	if w.opts.Secret != "" {
+		req.Header.Set(WEBHOOK_SIGNATURE, Sign(w.opts.Secret, body))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return true, 0, err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		return false, 0, nil
+	}
+	err = fmt.Errorf("%s: %s", w.url, resp.Status)
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		if sec, e := strconv.Atoi(resp.Header.Get("Retry-After")); e == nil && sec > 0 {
+			delay = min(time.Duration(sec)*time.Second, w.opts.BackoffMax)
+		}
+		return true, delay, err
+	}
+	return false, 0, err
+}
+
+// Подпись тела запроса: "sha256=<hex HMAC-SHA256>" (для проверки
+// получателем).
+// Sign request body.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Преобразовать в dto.Entry.
+// Repack to dto.Entry.
+func (e Entry) DTO() dto.Entry {
+	d := dto.Entry{
+		Action:     e.Action,
+		User:       e.User,
+		TTY:        e.TTY,
+		Host:       e.Host,
+		LogonType:  dto.LogonType[e.Type],
+		Privileged: e.Privileged,
+		Time:       e.Time,
+		Seq:        e.Seq}
+	if len(e.IP) != 0 {
+		d.IP = e.IP.String()
+	}
+	return d
+}
+
+// Краткое описание входов/выходов, например
+// "host1: login root[pts/0] from 10.0.0.1, logout alice[tty1]".
+// Human readable summary.
+func Summary(host string, list []dto.Entry) string {
+	var b strings.Builder
+	b.WriteString(host + ":")
+	for i, e := range list {
+		if i != 0 {
+			b.WriteString(",")
+		}
+		fmt.Fprintf(&b, " %s %s[%s]", e.Action, e.User, e.TTY)
+		if e.Host != "" {
+			b.WriteString(" from " + e.Host)
+		} else if e.IP != "" {
+			b.WriteString(" from " + e.IP)
+		}
+	}
+	return b.String()
+}
+
+// EOF: "webhook.go"
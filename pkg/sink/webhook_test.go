@@ -0,0 +1,91 @@
+// File: "webhook_test.go"
+
+package sink
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/azorg/gousers/v2/dto"
+	"github.com/azorg/gousers/v2/pkg/utmp"
+)
+
+func TestWebhook(t *testing.T) {
+	var mx sync.Mutex
+	var got []dto.Webhook
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		require.Equal(t, Sign("key", body), r.Header.Get(WEBHOOK_SIGNATURE))
+		require.Equal(t, "Bearer x", r.Header.Get("Authorization"))
+		mx.Lock()
+		defer mx.Unlock()
+		calls++
+		if calls == 1 { // first attempt fails
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var req dto.Webhook
+		require.NoError(t, json.Unmarshal(body, &req))
+		got = append(got, req)
+	}))
+	defer srv.Close()
+
+	require.NoError(t, utmp.SetConfig(utmp.Config{Labels: map[string]string{"dc": "eu-1"}}))
+	defer utmp.SetConfig(utmp.DefaultConfig())
+
+	w, err := NewWebhook(srv.URL, WebhookOpts{
+		Headers:   map[string]string{"Authorization": "Bearer x"},
+		Secret:    "key",
+		Backoff:   10 * time.Millisecond,
+		BatchSize: 2,
+		Hostname:  "h1"})
+	require.NoError(t, err)
+	require.NoError(t, w.Send(testEvent()))
+	require.Eventually(t, func() bool {
+		mx.Lock()
+		defer mx.Unlock()
+		return len(got) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	require.NoError(t, w.Close())
+	require.NoError(t, w.Close()) // safe
+	require.Error(t, w.Send(testEvent()))
+
+	require.Equal(t, 2, calls)
+	require.Equal(t, "h1", got[0].Host)
+	require.Equal(t, `h1: login root[pts/0] from bastion]"1, logout alice[tty1]`, got[0].Text)
+	require.Len(t, got[0].Events, 2)
+	require.Equal(t, "10.0.0.1", got[0].Events[0].IP)
+	require.True(t, got[0].Events[0].Privileged)
+	require.Equal(t, "logout", got[0].Events[1].Action)
+	require.Equal(t, map[string]string{"dc": "eu-1"}, got[0].Labels)
+}
+
+func TestWebhookErrors(t *testing.T) {
+	_, err := NewWebhook("ftp://x", WebhookOpts{})
+	require.Error(t, err)
+
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusBadRequest) // not retried
+	}))
+	defer srv.Close()
+
+	w, err := NewWebhook(srv.URL, WebhookOpts{Flush: 10 * time.Millisecond, BatchSize: 10})
+	require.NoError(t, err)
+	require.NoError(t, w.Send(testEvent())) // incomplete batch: sent by timer
+	time.Sleep(100 * time.Millisecond)
+	require.ErrorContains(t, w.Send(testEvent()), "400 Bad Request")
+	require.ErrorContains(t, w.Close(), "400 Bad Request")
+	require.Equal(t, 2, calls)
+}
+
+// EOF: "webhook_test.go"
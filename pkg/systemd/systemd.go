@@ -0,0 +1,173 @@
+// File: "systemd.go"
+
+/*
+Пакет `systemd` - интеграция службы с systemd без внешних зависимостей:
+уведомления sd_notify (Type=notify: готовность, перезагрузка
+конфигурации, остановка, сторожевой таймер WatchdogSec=) и приём
+заранее открытых сокетов (socket activation, LISTEN_FDS).
+
+Вне systemd (нет NOTIFY_SOCKET, LISTEN_FDS) функции ничего не делают.
+
+	files := systemd.Files() // gousers.socket: FileDescriptorName=http
+	ln, err := systemd.Listener(files, "http")
+	if ln == nil && err == nil {
+		ln, err = net.Listen("tcp", addr)
+	}
+	...
+	systemd.Notify(systemd.READY)
+	wd := systemd.NewWatchdog()
+	defer wd.Stop()
+	for {
+		select {
+		case <-wd.C:
+			systemd.Notify(systemd.WATCHDOG)
+		...
+		}
+	}
+
+Package systemd implements sd_notify and socket activation.
+*/
+package systemd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Состояния для Notify().
+// Notify states.
+const (
+	READY     = "READY=1"     // служба готова
+	RELOADING = "RELOADING=1" // перезагрузка конфигурации (затем READY)
+	STOPPING  = "STOPPING=1"  // остановка
+	WATCHDOG  = "WATCHDOG=1"  // сторожевой таймер
+)
+
+// Первый переданный дескриптор (SD_LISTEN_FDS_START).
+// First passed file descriptor.
+const LISTEN_FDS_START = 3
+
+// Строка состояния службы (systemctl status).
+// Status state.
+func Status(s string) string {
+	return "STATUS=" + s
+}
+
+// Отправить состояния менеджеру служб (NOTIFY_SOCKET). Возвращает false
+// без ошибки, если служба запущена не systemd.
+// Send states to service manager.
+func Notify(states ...string) (bool, error) {
+	addr := os.Getenv("NOTIFY_SOCKET")
+	if addr == "" {
+		return false, nil
+	}
+	if addr[0] != '/' && addr[0] != '@' {
+		return false, fmt.Errorf("systemd: unsupported NOTIFY_SOCKET %q", addr)
+	}
+	c, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: addr, Net: "unixgram"})
+	if err != nil {
+		return false, fmt.Errorf("systemd: %w", err)
+	}
+	defer c.Close()
+	if _, err := c.Write([]byte(strings.Join(states, "\n"))); err != nil {
+		return false, fmt.Errorf("systemd: %w", err)
+	}
+	return true, nil
+}
+
+// Период сторожевого таймера (WATCHDOG_USEC для этого процесса), 0 - выключен.
+// Watchdog timeout.
+func WatchdogTimeout() time.Duration {
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+// Тикер сторожевого таймера: C срабатывает с половиной периода
+// WatchdogTimeout(), при выключенном таймере C - nil (никогда не срабатывает).
+// Watchdog ticker.
+type Watchdog struct {
+	C <-chan time.Time
+	t *time.Ticker
+}
+
+// Создать тикер сторожевого таймера.
+// Create watchdog ticker.
+func NewWatchdog() *Watchdog {
+	d := WatchdogTimeout()
+	if d == 0 {
+		return &Watchdog{}
+	}
+	t := time.NewTicker(d / 2)
+	return &Watchdog{C: t.C, t: t}
+}
+
+// Остановить тикер.
+// Stop ticker.
+func (w *Watchdog) Stop() {
+	if w.t != nil {
+		w.t.Stop()
+	}
+}
+
+// Дескрипторы, переданные при socket activation (LISTEN_PID, LISTEN_FDS),
+// имя файла - имя из LISTEN_FDNAMES (FileDescriptorName= юнита сокета).
+// Переменные окружения удаляются (не наследуются дочерними процессами),
+// повторный вызов возвращает nil.
+// Files passed by socket activation.
+func Files() []*os.File {
+	defer func() {
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	}()
+	if os.Getenv("LISTEN_PID") != strconv.Itoa(os.Getpid()) {
+		return nil
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n <= 0 {
+		return nil
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+	files := make([]*os.File, 0, n)
+	for i := 0; i < n; i++ {
+		fd := LISTEN_FDS_START + i
+		syscall.CloseOnExec(fd)
+		name := "unknown"
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+		files = append(files, os.NewFile(uintptr(fd), name))
+	}
+	return files
+}
+
+// Слушающий сокет из files по имени (nil - нет такого). Дескриптор
+// дублируется: закрытие сокета не закрывает файл, и по нему можно снова
+// получить сокет (например, после перезапуска сервера).
+// Listener by name.
+func Listener(files []*os.File, name string) (net.Listener, error) {
+	for _, f := range files {
+		if f.Name() != name {
+			continue
+		}
+		ln, err := net.FileListener(f)
+		if err != nil {
+			return nil, fmt.Errorf("systemd: socket %q: %w", name, err)
+		}
+		return ln, nil
+	}
+	return nil, nil
+}
+
+// EOF: "systemd.go"
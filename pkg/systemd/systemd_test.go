@@ -0,0 +1,105 @@
+// File: "systemd_test.go"
+
+package systemd
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotify(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+	ok, err := Notify(READY)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	sock := filepath.Join(t.TempDir(), "notify")
+	pc, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: sock, Net: "unixgram"})
+	require.NoError(t, err)
+	defer pc.Close()
+	t.Setenv("NOTIFY_SOCKET", sock)
+
+	ok, err = Notify(READY, Status("watching /var/run/utmp"))
+	require.NoError(t, err)
+	require.True(t, ok)
+	buf := make([]byte, 1024)
+	pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, err := pc.Read(buf)
+	require.NoError(t, err)
+	require.Equal(t, "READY=1\nSTATUS=watching /var/run/utmp", string(buf[:n]))
+
+	t.Setenv("NOTIFY_SOCKET", "vsock:2:1")
+	_, err = Notify(READY)
+	require.Error(t, err)
+}
+
+func TestWatchdog(t *testing.T) {
+	t.Setenv("WATCHDOG_USEC", "")
+	t.Setenv("WATCHDOG_PID", "")
+	require.Zero(t, WatchdogTimeout())
+	wd := NewWatchdog()
+	require.Nil(t, wd.C)
+	wd.Stop()
+
+	t.Setenv("WATCHDOG_USEC", "20000")
+	require.Equal(t, 20*time.Millisecond, WatchdogTimeout())
+	t.Setenv("WATCHDOG_PID", "1")
+	require.Zero(t, WatchdogTimeout()) // not for this process
+
+	t.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()))
+	wd = NewWatchdog()
+	defer wd.Stop()
+	select {
+	case <-wd.C:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no watchdog tick")
+	}
+}
+
+func TestListener(t *testing.T) {
+	t.Setenv("LISTEN_PID", "1")
+	t.Setenv("LISTEN_FDS", "2")
+	require.Nil(t, Files()) // not for this process
+	_, ok := os.LookupEnv("LISTEN_FDS")
+	require.False(t, ok)
+
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	f, err := tcp.(*net.TCPListener).File()
+	require.NoError(t, err)
+	tcp.Close()
+	fd, err := syscall.Dup(int(f.Fd()))
+	require.NoError(t, err)
+	f.Close()
+	files := []*os.File{os.NewFile(uintptr(fd), "http")}
+	defer files[0].Close()
+
+	ln, err := Listener(files, "ipc")
+	require.NoError(t, err)
+	require.Nil(t, ln)
+
+	// Listener can be taken again after close
+	for i := 0; i < 2; i++ {
+		ln, err = Listener(files, "http")
+		require.NoError(t, err)
+		go func(addr string) {
+			c, err := net.Dial("tcp", addr)
+			if err == nil {
+				c.Close()
+			}
+		}(ln.Addr().String())
+		c, err := ln.Accept()
+		require.NoError(t, err)
+		c.Close()
+		require.NoError(t, ln.Close())
+	}
+}
+
+// EOF: "systemd_test.go"
@@ -0,0 +1,108 @@
+// File: "ac.go"
+
+package utmp
+
+import (
+	"sort"
+	"time"
+)
+
+// Опции учёта времени подключения.
+// Connect time accounting options.
+type ConnectOpts struct {
+	Since  time.Time // начало периода (нулевое - вход первого сеанса)
+	Until  time.Time // конец периода (нулевое - now)
+	ByUser bool      // по пользователям (как `ac -p`)
+	ByDay  bool      // по дням местного времени (как `ac -d`)
+}
+
+// Время подключения за период (пользователя и/или дня).
+// Connect time of user and/or day.
+type ConnectTime struct {
+	User string        // Username ("" - all users)
+	Day  time.Time     // Local midnight of day (zero - whole period)
+	Time time.Duration // Total connect time
+}
+
+// Подсчитать суммарное время подключения сеансов (аналог утилиты `ac`):
+// сеансы обрезаются по периоду [Since, Until], активные сеансы считаются
+// до Until (или now), при ByDay сеансы делятся по местной полуночи.
+// Результат сортирован по дню, затем по пользователю.
+// Connect time accounting like `ac`.
+func ConnectReport(sessions []Session, opts ConnectOpts, now time.Time) []ConnectTime {
+	until := opts.Until
+	if until.IsZero() || until.After(now) {
+		until = now
+	}
+	type key struct {
+		user string
+		day  int64 // Unix time of local midnight
+	}
+	stats := make(map[key]*ConnectTime)
+	add := func(user string, day time.Time, d time.Duration) {
+		k := key{}
+		ct := ConnectTime{}
+		if opts.ByUser {
+			k.user, ct.User = user, user
+		}
+		if opts.ByDay {
+			k.day, ct.Day = day.Unix(), day
+		}
+		st := stats[k]
+		if st == nil {
+			st = &ct
+			stats[k] = st
+		}
+		st.Time += d
+	}
+
+	for i := range sessions {
+		s := &sessions[i]
+		from, to, ok := s.clip(opts.Since, until)
+		if !ok {
+			continue // out of period
+		}
+		if !opts.ByDay {
+			add(s.User, time.Time{}, to.Sub(from))
+			continue
+		}
+		for from.Before(to) {
+			y, m, d := from.Local().Date()
+			day := time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+			next := time.Date(y, m, d+1, 0, 0, 0, 0, time.Local) // DST safe
+			end := to
+			if next.Before(end) {
+				end = next
+			}
+			add(s.User, day, end.Sub(from))
+			from = end
+		}
+	}
+
+	report := make([]ConnectTime, 0, len(stats))
+	for _, st := range stats {
+		report = append(report, *st)
+	}
+	sort.Slice(report, func(i, j int) bool {
+		if !report[i].Day.Equal(report[j].Day) {
+			return report[i].Day.Before(report[j].Day)
+		}
+		return report[i].User < report[j].User
+	})
+	return report
+}
+
+// Обрезать сеанс по периоду [since, until] (нулевое since - без
+// ограничения), активный сеанс длится до until.
+func (s *Session) clip(since, until time.Time) (from, to time.Time, ok bool) {
+	from, to = s.Login, s.Logout
+	if s.End == SESSION_ACTIVE || to.After(until) {
+		to = until
+	}
+	if !since.IsZero() && from.Before(since) {
+		from = since
+	}
+	return from, to, to.After(from)
+}
+
+// EOF: "ac.go"
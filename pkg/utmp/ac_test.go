@@ -0,0 +1,45 @@
+// File: "ac_test.go"
+
+package utmp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnectReport(t *testing.T) {
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local)
+	at := func(h, m int) time.Time { return day.Add(time.Duration(h)*time.Hour + time.Duration(m)*time.Minute) }
+	sessions := []Session{
+		{User: "alice", Login: at(9, 0), Logout: at(11, 30), End: SESSION_LOGOUT},
+		{User: "bob", Login: at(23, 0), Logout: at(25, 0), End: SESSION_CRASH}, // over midnight
+		{User: "alice", Login: at(47, 0), End: SESSION_ACTIVE},
+		{User: "carol", Login: at(-5, 0), Logout: at(-4, 0), End: SESSION_LOGOUT}, // before period
+	}
+	now := at(48, 0)
+
+	total := ConnectReport(sessions, ConnectOpts{Since: day}, now)
+	require.Equal(t, []ConnectTime{{Time: 5*time.Hour + 30*time.Minute}}, total)
+
+	users := ConnectReport(sessions, ConnectOpts{Since: day, ByUser: true}, now)
+	require.Len(t, users, 2)
+	require.Equal(t, ConnectTime{User: "alice", Time: 3*time.Hour + 30*time.Minute}, users[0])
+	require.Equal(t, ConnectTime{User: "bob", Time: 2 * time.Hour}, users[1])
+
+	daily := ConnectReport(sessions, ConnectOpts{Since: day, Until: at(47, 30), ByUser: true, ByDay: true}, now)
+	require.Len(t, daily, 4)
+	require.Equal(t, "alice", daily[0].User)
+	require.Equal(t, 150*time.Minute, daily[0].Time)
+	require.Equal(t, "bob", daily[1].User)
+	require.Equal(t, time.Hour, daily[1].Time)
+	require.True(t, daily[2].Day.Equal(day.AddDate(0, 0, 1)))
+	require.Equal(t, "alice", daily[2].User)
+	require.Equal(t, 30*time.Minute, daily[2].Time) // clipped by Until
+	require.True(t, daily[3].Day.Equal(day.AddDate(0, 0, 1)))
+	require.Equal(t, "bob", daily[3].User)
+	require.Equal(t, time.Hour, daily[3].Time)
+}
+
+// EOF: "ac_test.go"
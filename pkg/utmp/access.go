@@ -0,0 +1,129 @@
+// File: "access.go"
+
+package utmp
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Отчёт о возможности чтения файла с текущими правами процесса
+// (вместо "permission denied" при первом чтении - что нужно сделать,
+// чтобы читать файл без прав root).
+// File read capability report.
+type AccessReport struct {
+	File        string      // Checked file
+	Readable    bool        // File can be read with current credentials
+	Err         error       // Open error (nil if readable)
+	Owner       string      // File owner (name or UID)
+	Group       string      // File group (name or GID), required group
+	Mode        fs.FileMode // File permissions
+	InGroup     bool        // Process is member of file group
+	Suggestions []string    // How to get read access
+}
+
+// Проверить возможность чтения utmp/wtmp/btmp файла (в т.ч. членство
+// процесса в группе файла, обычно "utmp") и сформировать рекомендации:
+// группа, setcap, настройки systemd unit.
+// Check file can be read with current credentials.
+func CheckAccess(fname string) AccessReport {
+	r := AccessReport{File: fname}
+
+	f, err := os.Open(fname)
+	if err == nil {
+		f.Close()
+		r.Readable = true
+	} else {
+		r.Err = err
+	}
+
+	fi, serr := os.Stat(fname)
+	if serr != nil {
+		if errors.Is(serr, fs.ErrNotExist) {
+			r.Suggestions = append(r.Suggestions,
+				"file does not exist, check path (option -file)")
+		}
+		return r
+	}
+	r.Mode = fi.Mode().Perm()
+
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return r
+	}
+	uid, gid := strconv.Itoa(int(st.Uid)), strconv.Itoa(int(st.Gid))
+	r.Owner, r.Group = uid, gid
+	if u, err := user.LookupId(uid); err == nil {
+		r.Owner = u.Username
+	}
+	if g, err := user.LookupGroupId(gid); err == nil {
+		r.Group = g.Name
+	}
+	r.InGroup = inGroup(int(st.Gid))
+
+	if r.Readable || !errors.Is(r.Err, fs.ErrPermission) {
+		return r
+	}
+
+	exe, _ := os.Executable()
+	if exe == "" {
+		exe = "gousers"
+	}
+	if r.Mode&0040 != 0 && !r.InGroup { // group readable
+		name := "$USER"
+		if u, err := user.Current(); err == nil {
+			name = u.Username
+		}
+		r.Suggestions = append(r.Suggestions,
+			fmt.Sprintf("add user to group '%s': usermod -aG %s %s (re-login required)",
+				r.Group, r.Group, name),
+			fmt.Sprintf("systemd unit: SupplementaryGroups=%s", r.Group))
+	}
+	r.Suggestions = append(r.Suggestions,
+		fmt.Sprintf("grant capability: setcap cap_dac_read_search+ep %s", exe),
+		"systemd unit: AmbientCapabilities=CAP_DAC_READ_SEARCH",
+		"run as root")
+	return r
+}
+
+// Проверить членство процесса в группе (эффективный GID или
+// дополнительные группы).
+func inGroup(gid int) bool {
+	if os.Getegid() == gid {
+		return true
+	}
+	groups, _ := os.Getgroups()
+	for _, g := range groups {
+		if g == gid {
+			return true
+		}
+	}
+	return false
+}
+
+// Текстовый отчёт (многострочный).
+// Human readable report.
+func (r AccessReport) String() string {
+	var b strings.Builder
+	if r.Readable {
+		fmt.Fprintf(&b, "%s: readable\n", r.File)
+		return b.String()
+	}
+	fmt.Fprintf(&b, "%s: can't read: %v\n", r.File, r.Err)
+	if r.Owner != "" {
+		fmt.Fprintf(&b, "  owner=%s group=%s mode=%v in_group=%v\n",
+			r.Owner, r.Group, r.Mode, r.InGroup)
+	}
+	for _, s := range r.Suggestions {
+		fmt.Fprintf(&b, "  - %s\n", s)
+	}
+	return b.String()
+}
+
+// EOF: "access.go"
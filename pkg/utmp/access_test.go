@@ -0,0 +1,35 @@
+// File: "access_test.go"
+
+package utmp
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckAccess(t *testing.T) {
+	fname := testFile(t, testRecord(BOOT_TIME, 0, "~", "~~", "reboot", "", 1000))
+	r := CheckAccess(fname)
+	require.True(t, r.Readable)
+	require.NoError(t, r.Err)
+	require.NotEmpty(t, r.Owner)
+
+	r = CheckAccess(filepath.Join(t.TempDir(), "nosuchfile"))
+	require.False(t, r.Readable)
+	require.ErrorIs(t, r.Err, os.ErrNotExist)
+	require.Len(t, r.Suggestions, 1)
+
+	if os.Geteuid() == 0 {
+		t.Skip("root can read any file")
+	}
+	require.NoError(t, os.Chmod(fname, 0))
+	r = CheckAccess(fname)
+	require.False(t, r.Readable)
+	require.ErrorIs(t, r.Err, os.ErrPermission)
+	require.Contains(t, r.String(), "setcap")
+}
+
+// EOF: "access_test.go"
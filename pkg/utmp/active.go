@@ -0,0 +1,85 @@
+// File: "active.go"
+
+package utmp
+
+import "time"
+
+// Событие смены активного (основного) пользователя сеанса: вход
+// первого пользователя, смена имени или типа входа, выход (Active = nil).
+// Active user changed event.
+type ActiveUserEvent struct {
+	Time    time.Time  // Время обновления utmp файла
+	Prev    *LoginInfo // Прежний активный пользователь (nil - не было)
+	Active  *LoginInfo // Новый активный пользователь (nil - вышел)
+	Partial bool       // Информация о пользователе неполна (см. LoginEvent.Partial)
+}
+
+// Подписки на смену активного пользователя (канал -> канал подписки).
+type activeMap map[<-chan ActiveUserEvent]<-chan LoginEvent
+
+// Подписаться только на смену активного пользователя (канал с буфером
+// `buffer` событий, при переполнении получатель задерживает рассылку).
+// Канал закрывается в UnsubscribeActive() или Close().
+// Subscribe to active user changes.
+func (l *Login) SubscribeActive(buffer int) <-chan ActiveUserEvent {
+	out := make(chan ActiveUserEvent)
+	in := l.SubscribeWith(SubscribeOpts{Buffer: buffer, ActiveOnly: true})
+
+	l.subMx.Lock()
+	defer l.subMx.Unlock()
+	sub, ok := l.subs[in]
+	if !ok { // closed
+		close(out)
+		return out
+	}
+	if l.active == nil {
+		l.active = make(activeMap)
+	}
+	l.active[out] = in
+
+	prev := cloneInfo(l.GetStat().Active)
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+		defer close(out)
+		for evt := range in {
+			e := ActiveUserEvent{
+				Time:    evt.Time,
+				Prev:    prev,
+				Active:  cloneInfo(evt.Stat.Active),
+				Partial: evt.Partial}
+			select {
+			case out <- e:
+			case <-sub.quit:
+				return
+			case <-l.done:
+				return
+			}
+			prev = e.Active
+		}
+	}()
+	return out
+}
+
+// Отписаться от смены активного пользователя (см. SubscribeActive()).
+// Unsubscribe from active user changes.
+func (l *Login) UnsubscribeActive(c <-chan ActiveUserEvent) {
+	l.subMx.Lock()
+	in, ok := l.active[c]
+	delete(l.active, c)
+	l.subMx.Unlock()
+	if ok {
+		l.Unsubscribe(in)
+	}
+}
+
+// Копия информации о пользователе (nil - nil).
+func cloneInfo(li *LoginInfo) *LoginInfo {
+	if li == nil {
+		return nil
+	}
+	c := *li
+	return &c
+}
+
+// EOF: "active.go"
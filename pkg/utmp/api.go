@@ -3,7 +3,14 @@
 package utmp
 
 import (
+	"context"
+	"errors"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
@@ -13,6 +20,42 @@ import (
 // Default file to read.
 var DefaultFile = DEFAULT_FILE
 
+// Рекомендуемый бюджет времени обогащения (получения информации о
+// пользователях из базы пользователей, LDAP и т.п.): при превышении
+// событие входа/выхода отправляется без ожидания (см. LoginEvent.Partial).
+// Бюджет включается явно (см. SetEnrichBudget()), по умолчанию события
+// ожидают полного обогащения.
+// Recommended enrichment time budget (opt-in).
+const ENRICH_BUDGET = 100 * time.Millisecond
+
+// Время жизни записи кэша информации о пользователях по умолчанию
+// (см. SetUserInfoTTL()).
+// Default user info cache TTL.
+const USER_INFO_TTL = time.Minute
+
+// Размер очередей между стадиями Login (разбор, обогащение, отправка).
+// Size of queues between Login stages.
+const LOGIN_QUEUE = 16
+
+// Рекомендуемое окно объединения быстро следующих записей utmp файла
+// (менеджеры дисплеев пишут несколько записей подряд).
+// Suggested debounce window of utmp updates (see LoginOpts.Debounce).
+const DEBOUNCE = 100 * time.Millisecond
+
+// Время ожидания повторного создания удалённого (переименованного) utmp
+// файла, после которого Login сообщает о фатальной ошибке.
+// Time to wait for removed utmp file to be recreated.
+var RecreateWait = 5 * time.Second
+
+// Начальная и наибольшая задержки повторных проверок отсутствующего utmp
+// файла (задержка удваивается, пока не истечёт RecreateWait): файл,
+// появившийся снова без события fsnotify, разбирается полностью.
+// Retry backoff of missing utmp file.
+const (
+	RETRY_MIN = 100 * time.Millisecond
+	RETRY_MAX = 2 * time.Second
+)
+
 // Типы пользователей.
 // Type of logged user (5 types: 0-4).
 var LoginTypeStr = [...]string{"", "remote", "remote_x", "local", "local_x"}
@@ -23,7 +66,7 @@ const (
 	UNKNOWN LoginType = iota // тип пользователя не определен (авария)
 
 	REMOTE   // удаленный пользователь (ssh)
-	REMOTE_X // удаленный пользователь графического сеанса (FIXME: НЕ реализовано)
+	REMOTE_X // удаленный пользователь графического сеанса (xrdp, VNC, x2go, NoMachine)
 	LOCAL    // локальный пользователь (вход через login/sudo)
 	LOCAL_X  // локальный пользователь графического сеанса (вход через Desktop manager)
 )
@@ -52,9 +95,24 @@ type UserLogin struct {
 type LoginInfo struct {
 	UserInfo
 	UserLogin
+	Auth []AuthInfo    // Аутентификация сеансов пользователя (см. pkg/authlog)
+	Idle time.Duration // Простой наименее простаивающего сеанса (-1 - неизвестно, см. GetUsersOpts.Idle)
+}
+
+// Сведения об аутентификации сеанса SSH по журналу sshd (см. pkg/authlog).
+// SSH authentication of session.
+type AuthInfo struct {
+	Method      string    // publickey, password, keyboard-interactive/pam, gssapi-with-mic, hostbased
+	KeyType     string    // Key type: ED25519, RSA, ECDSA, ED25519-CERT... (publickey, hostbased)
+	Fingerprint string    // Key fingerprint "SHA256:..." (publickey, hostbased)
+	CertID      string    // Certificate key ID (*-CERT keys)
+	PID         int       // PID of sshd process
+	Port        int       // Client port
+	Time        time.Time // Authentication time
 }
 
-// Статистика входов пользователей.
+// Статистика входов пользователей. Под "root" понимаются все
+// привилегированные пользователи (см. IsPrivileged(), Config.Privileged).
 // Logged user statistics.
 type LoginStat struct {
 	Total      int        // Total logged users "Local + Remote + root"
@@ -63,9 +121,31 @@ type LoginStat struct {
 	RemoteX    int        // Number of remote users logged in X/xrdp/vnc (excluding root)
 	Remote     int        // Number of remote users (excluding root)
 	Unknown    int        // Total number of unknown logged users (must be 0)
-	LocalRoot  bool       // Local root logged
-	RemoteRoot bool       // Remote root logged
+	LocalRoot  bool       // Local root (privileged user) logged
+	RemoteRoot bool       // Remote root (privileged user) logged
 	Active     *LoginInfo // Information about active user or nil
+
+	// Имена пользователей по категориям (отсортированы, без root)
+	LocalXUsers  []string // Users logged in X session
+	LocalUsers   []string // Local users
+	RemoteXUsers []string // Remote users logged in X/xrdp/vnc
+	RemoteUsers  []string // Remote users
+	UnknownUsers []string // Unknown logged users (incl. root)
+
+	// Число входов (сеансов) по пользователям (включая root)
+	Logons map[string]int // Number of logons by user
+
+	// Число пользователей по группам (см. GroupOf())
+	Groups map[string]int // Number of logged users by group
+
+	// Активные пользователи по рабочим местам (seat) для систем с
+	// несколькими рабочими местами (см. User.Seat()), nil - нет локальных
+	// пользователей
+	Seats map[string]*LoginInfo // Active user by seat
+
+	// Статистика получена в режиме автономного анализа (см. SetOffline()),
+	// типы входа определены без /proc и менее точны
+	Offline bool // Offline analysis (no /proc lookups)
 }
 
 // Вспомагательная структура для сохрнения имени пользователя и терминала.
@@ -100,13 +180,71 @@ type LoginEvent struct {
 	// Имена пользователей только что вышедших (с указанием терминала)
 	Logout []UserTTY
 
+	// Типы входа вошедших и вышедших пользователей (по терминалам)
+	Types map[UserTTY]LoginType
+
 	// Полное описание пользователей в системе на данный момент
 	Users []LoginInfo
 
 	// Статистика пользователей, в т.ч. информация об активном пользователе сеанса
 	Stat LoginStat
+
+	// Статические метки экземпляра службы (см. `Config.Labels`)
+	Labels map[string]string
+
+	// Обогащение не уложилось в бюджет времени: информация о пользователях
+	// (Users, Stat.Active) взята из кэша или неполна (только имя и метрики).
+	// Только при заданном бюджете (см. SetEnrichBudget()), иначе false
+	Partial bool
+
+	// Число более ранних событий, объединённых с этим (OVERFLOW_COALESCE)
+	Coalesced int
+
+	// Полная повторная синхронизация: utmp файл был усечён, заменён или
+	// создан заново (вход/выход определены сравнением с прежним состоянием)
+	Resync bool
+
+	// Изменение utmp файла с момента предыдущего чтения (размер, время
+	// изменения, inode, вид): позволяет получателю выбрать инкрементальное
+	// или полное повторное чтение (см. FileChange.Full())
+	Change FileChange
+
+	// Историческое событие, восстановленное из wtmp (см. Login.Replay()):
+	// Users и Stat - состояние на момент события, информация о
+	// пользователях - только имя и метрики
+	Historical bool
+
+	// Порядковый номер события службы (1, 2, ...): пропуски означают
+	// объединённые (Coalesced), отброшенные (Dropped()) или
+	// отфильтрованные (SubscribeWith()) события
+	Seq uint64
+
+	// Время обнаружения изменения utmp файла службой (содержит показания
+	// монотонных часов, пригодно для упорядочивания событий)
+	Received time.Time
+
+	// Исходные записи utmp/wtmp, вызвавшие событие: записи входа и выхода
+	// (только с опцией LoginOpts.RawRecords)
+	Records []Utmp
 }
 
+// Ошибка службы `Login` (см. Login.Errors()).
+// Login service error.
+type LoginError struct {
+	Op    string // операция: "stat", "read", "config", "watch"
+	Err   error  // исходная ошибка
+	Fatal bool   // отслеживание прекращено: события больше не поступают
+}
+
+func (e *LoginError) Error() string {
+	if e.Fatal {
+		return "login " + e.Op + " (fatal): " + e.Err.Error()
+	}
+	return "login " + e.Op + ": " + e.Err.Error()
+}
+
+func (e *LoginError) Unwrap() error { return e.Err }
+
 // Интерфейс класса Login
 type Loginer interface {
 	Close()                // Terminate
@@ -118,69 +256,342 @@ type Loginer interface {
 // Класс для отслеживания событий входа/выхода пользователей
 // и оперативного извлечения из памяти данных о текущем активном (основном)
 // пользователе сеанса для работы службы контроля съёмных носителей.
+// Работает в три стадии (горутины), связанные ограниченными очередями:
+// разбор utmp и определение входа/выхода, обогащение информацией
+// о пользователях (не дольше бюджета времени, см. SetEnrichBudget())
+// и отправка событий в канал C().
 type Login struct {
 	// Все поля структуры "приватные".
 	// Has unexported fields.
-	fname    string               // полный путь к файлу utmp
-	useEUID  bool                 // признак использования эффективного UID
-	evtChan  chan LoginEvent      // канал для передачи событий изменения utmp
-	watcher  *fsnotify.Watcher    // компонент fsnotify
-	users    Users                // списко пользователей полученный из utmp
-	logged   map[UserTTY]struct{} // перечень пользователей в системе с терминалами
-	logins   []LoginInfo          // подробная информация о всех пользователях системы
-	loginsMx sync.RWMutex         // мьютекс для защиты `logins`
-	stat     LoginStat            // статистика пользователей
-	statMx   sync.RWMutex         // мьютекс для защиты `stat`
-	wg       sync.WaitGroup       // группа ожидания при завершении работы
+	fname    string                 // полный путь к файлу utmp
+	useEUID  bool                   // признак использования эффективного UID
+	stale    bool                   // проверять повторное использование PID
+	evtChan  <-chan LoginEvent      // канал для передачи событий изменения utmp
+	first    chan error             // результат первого чтения utmp
+	errChan  chan error             // канал для передачи ошибок (*LoginError)
+	watcher  *fsnotify.Watcher      // компонент fsnotify
+	users    Users                  // списко пользователей полученный из utmp
+	logged   map[UserTTY]struct{}   // перечень пользователей в системе с терминалами
+	types    ttyTypes               // типы входа пользователей в системе
+	logins   []LoginInfo            // подробная информация о всех пользователях системы
+	loginsMx sync.RWMutex           // мьютекс для защиты `logins`
+	stat     LoginStat              // статистика пользователей
+	statMx   sync.RWMutex           // мьютекс для защиты `stat`
+	wg       sync.WaitGroup         // группа ожидания при завершении работы
+	reload   chan struct{}          // канал запроса на повторное чтение utmp
+	confName string                 // путь к отслеживаемому файлу конфигурации
+	confInfo os.FileInfo            // состояние файла конфигурации при загрузке
+	confMx   sync.Mutex             // мьютекс для защиты `confName`, `confInfo`
+	parsed   chan parsedUtmp        // очередь стадии обогащения
+	ready    chan LoginEvent        // очередь стадии отправки
+	done     chan struct{}          // канал завершения работы
+	closeOne sync.Once              // однократное завершение
+	budget   atomic.Int64           // бюджет времени обогащения (time.Duration)
+	busy     atomic.Bool            // выполняется обогащение (не более одного)
+	gen      atomic.Uint64          // номер последнего разобранного utmp
+	cache    map[string]UserInfo    // кэш информации о пользователях
+	cacheMx  sync.Mutex             // мьютекс для защиты `cache`
+	log      *slog.Logger           // журнал ошибок (см. LoginOpts.Logger)
+	dropped  atomic.Uint64          // число отброшенных событий
+	subs     subMap                 // подписчики (см. Subscribe())
+	active   activeMap              // подписчики SubscribeActive()
+	subMx    sync.RWMutex           // мьютекс для защиты `subs`, `active`, `closed`
+	closed   bool                   // вызван Close()
+	reader   *usersReader           // инкрементальное чтение (или nil)
+	fi       os.FileInfo            // utmp файл при последнем чтении
+	resync   bool                   // файл создан заново (полный разбор)
+	debounce time.Duration          // окно объединения записей
+	wtmp     string                 // журнал для Replay()
+	replay   chan replayReq         // запросы Replay()
+	seq      uint64                 // номер последнего события (см. enricherFn)
+	raw      bool                   // см. LoginOpts.RawRecords
+	backend  Backend                // фактический способ отслеживания изменений
+	watch    map[string]os.FileInfo // дополнительные файлы (см. LoginOpts.Watch)
+	files    chan FileEvent         // канал изменений дополнительных файлов
+}
+
+// Опции создания `Login` (см. NewLoginWith()).
+// Options for NewLoginWith().
+type LoginOpts struct {
+	UseEUID bool // use EUID(PID) to get real username of local users
+
+	// Проверять повторное использование PID устаревших записей utmp
+	// (см. GetUsersOpts.Stale)
+	Stale bool
+
+	// Журнал ошибок и предупреждений службы (nil - не вести: библиотека
+	// ничего не пишет в stderr приложения)
+	Logger *slog.Logger
+
+	// Завершить работу (как Close()) при отмене контекста (nil - только
+	// вызовом Close())
+	Context context.Context
+
+	// Размер буфера канала событий C() (0 - не буферизирован, для
+	// OVERFLOW_DROP_OLDEST - буфер на одно событие)
+	Buffer int
+
+	// Поведение при переполнении канала событий (медленный получатель)
+	Overflow Overflow
+
+	// Окно объединения записей utmp файла: изменения, сделанные в течение
+	// Debounce после первого, разбираются однократно и дают одно событие
+	// с общими списками входов/выходов (0 - без задержки, см. DEBOUNCE)
+	Debounce time.Duration
+
+	// Способ отслеживания изменений файлов (BACKEND_POLL - для файловых
+	// систем без inotify) и период опроса (0 - POLL_INTERVAL).
+	// Если inotify недоступен (ядро без inotify, исчерпаны лимиты
+	// экземпляров/наблюдений), BACKEND_FSNOTIFY переходит на опрос
+	// (см. Login.Backend())
+	Backend      Backend
+	PollInterval time.Duration
+
+	// Добавлять в события исходные записи входа/выхода (LoginEvent.Records)
+	// для архивирования первичных данных
+	RawRecords bool
+
+	// Журнал входов для Replay() ("" - DEFAULT_FILE)
+	Wtmp string
+
+	// Дополнительные отслеживаемые файлы (например, wtmp и btmp): их
+	// изменения передаются в канал Files() с путём файла; файл может
+	// появиться позже (каталог файла должен существовать)
+	Watch []string
+
+	// Разбирать только дописанные записи (для журналов wtmp/btmp, в которые
+	// записи только дописываются; при усечении, замене или перезаписи файла
+	// выполняется полный разбор)
+	Incremental bool
 }
 
 // Фабричная функция для создания экземпляра класса (конструктор).
 // (fname - полный путь к файлу utmp, например "/var/run/utmp" или ""
 // - для использования файла по умолчанию).
 func NewLogin(fname string, useEUID bool) (*Login, error) {
+	return NewLoginWith(fname, LoginOpts{UseEUID: useEUID})
+}
+
+// Вариант NewLogin() с дополнительными опциями (см. `LoginOpts`).
+// Возвращается после первого чтения utmp файла или с его ошибкой.
+// Create Login with options.
+func NewLoginWith(fname string, opts LoginOpts) (*Login, error) {
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	if fname == "" {
 		fname = DefaultFile
 	}
-	l := &Login{fname: fname, useEUID: useEUID}
-	l.evtChan = make(chan LoginEvent)
-
-	// Создать объект fsnotify.Watcher
-	var err error
-	l.watcher, err = fsnotify.NewWatcher()
+	fname = filepath.Clean(fname) // as in fsnotify events
+	// Проверить доступ к файлу и его формат
+	f, err := Open(fname)
 	if err != nil {
 		return nil, err
 	}
-	err = l.watcher.Add(fname)
-	if err != nil {
-		return nil, err
+	f.Close()
+
+	l := &Login{fname: fname, useEUID: opts.UseEUID, log: opts.Logger}
+	l.stale = opts.Stale
+	l.debounce = opts.Debounce
+	l.raw = opts.RawRecords
+	l.wtmp = opts.Wtmp
+	if l.wtmp == "" {
+		l.wtmp = DEFAULT_FILE
+	}
+	l.replay = make(chan replayReq)
+	if l.log == nil {
+		l.log = slog.New(discardHandler{})
+	}
+	if opts.Incremental {
+		l.reader = newUsersReader(fname, l.usersOpts())
+		l.reader.raw = opts.RawRecords
+	}
+	l.subs = make(subMap)
+	l.errChan = make(chan error, LOGIN_QUEUE)
+	l.reload = make(chan struct{}, 1)
+	l.parsed = make(chan parsedUtmp, LOGIN_QUEUE)
+	l.ready = make(chan LoginEvent, LOGIN_QUEUE)
+	l.done = make(chan struct{})
+	l.first = make(chan error, 1)
+	l.files = make(chan FileEvent, LOGIN_QUEUE)
+	l.watch = make(map[string]os.FileInfo)
+	for _, name := range opts.Watch {
+		name = filepath.Clean(name)
+		l.watch[name], _ = os.Stat(name) // nil - file does not exist yet
+	}
+	l.cache = make(map[string]UserInfo)
+
+	var events <-chan fsnotify.Event
+	var errs <-chan error
+	var poll chan fsnotify.Event
+	l.backend = opts.Backend
+	if l.backend != BACKEND_POLL {
+		err = l.newWatcher()
+		if errors.Is(err, errNoInotify) {
+			l.log.Warn("inotify is unavailable, polling files", "err", err)
+			l.backend = BACKEND_POLL
+		} else if err != nil {
+			return nil, err
+		} else {
+			events, errs = l.watcher.Events, l.watcher.Errors
+		}
+	}
+	if l.backend == BACKEND_POLL {
+		poll = make(chan fsnotify.Event)
+		events = poll
 	}
 
 	// Инициировать пустое множество пользователей в системе
 	l.logged = make(map[UserTTY]struct{})
+	l.types = make(ttyTypes)
 
 	// Запустить горутину ожидания событий от объекта fsnotify.Watcher
-	l.wg.Add(1)
-	go watcherFn(l)
-
-	// Дождаться завершения первого чтения utmp файла
+	// и горутины обогащения и отправки событий
+	l.evtChan = l.Subscribe(opts.Buffer, opts.Overflow)
+	l.wg.Add(3)
+	if poll != nil {
+		interval := opts.PollInterval
+		if interval <= 0 {
+			interval = POLL_INTERVAL
+		}
+		l.wg.Add(1)
+		go pollerFn(l, poll, interval, maps.Clone(l.watch))
+	}
+	go watcherFn(l, events, errs)
+	go enricherFn(l)
+	go dispatcherFn(l)
+
+	// Дождаться завершения первого чтения utmp файла (при ошибке
+	// событие не формируется)
+	if err := <-l.first; err != nil {
+		l.Close()
+		return nil, err
+	}
 	<-l.evtChan
 
+	if ctx.Done() != nil {
+		go func() {
+			select {
+			case <-ctx.Done():
+				l.Close()
+			case <-l.done: // closed by Close()
+			}
+		}()
+	}
 	return l, nil
 }
 
+// Вариант NewLogin(), завершающий работу (как Close()) при отмене
+// контекста; вызов Close() по-прежнему допустим.
+// Create Login stopped by context cancellation.
+func NewLoginContext(ctx context.Context, fname string, useEUID bool) (*Login, error) {
+	return NewLoginWith(fname, LoginOpts{UseEUID: useEUID, Context: ctx})
+}
+
 // Функция деинициализации (деструктор, освобождение ресурсов,
-// закрытие канала событий, останов горутин).
+// останов горутин). Каналы C(), Errors() и Files() закрываются
+// отправляющими горутинами при их завершении (до возврата из Close()), повторный
+// и конкурентный вызов Close() безопасен.
 func (l *Login) Close() {
-	close(l.evtChan)
-	l.watcher.Close()
-	l.wg.Wait()
+	l.closeOne.Do(func() {
+		l.subMx.Lock()
+		l.closed = true
+		l.subMx.Unlock()
+		close(l.done)
+		if l.watcher != nil {
+			l.watcher.Close()
+		}
+		l.wg.Wait()
+	})
+}
+
+// Число событий, отброшенных при переполнении каналов (OVERFLOW_DROP_OLDEST).
+// Number of dropped events.
+func (l *Login) Dropped() uint64 {
+	return l.dropped.Load()
+}
+
+// Задать бюджет времени обогащения события информацией о пользователях
+// (0 - ожидать без ограничения, по умолчанию; рекомендуется ENRICH_BUDGET).
+// Set enrichment time budget.
+func (l *Login) SetEnrichBudget(budget time.Duration) {
+	l.budget.Store(int64(budget))
+}
+
+// Запросить повторное чтение и классификацию пользователей utmp файла
+// (например, после изменения конфигурации с помощью SetConfig()).
+// Состояние (список вошедших пользователей) при этом сохраняется.
+// Request re-read of utmp file with current config.
+func (l *Login) Reload() {
+	select {
+	case l.reload <- struct{}{}:
+	default: // reload already requested
+	}
+}
+
+// Загрузить конфигурацию из JSON файла и отслеживать его изменения:
+// при каждом изменении файла конфигурация применяется "на лету".
+// Load detection config from file and reload it on every change.
+func (l *Login) WatchConfig(fname string) error {
+	fname, err := filepath.Abs(fname)
+	if err != nil {
+		return err
+	}
+
+	fi, err := os.Stat(fname) // before load: later change is not missed
+	if err != nil {
+		return err
+	}
+	err = LoadConfig(fname)
+	if err != nil {
+		return err
+	}
+
+	// Отслеживать каталог, чтобы не потерять файл при атомарной замене
+	// (BACKEND_POLL - файл опрашивается, см. pollerFn)
+	if l.watcher != nil {
+		err = l.watcher.Add(filepath.Dir(fname))
+		if err != nil {
+			return err
+		}
+	}
+
+	l.confMx.Lock()
+	l.confName, l.confInfo = fname, fi
+	l.confMx.Unlock()
+
+	l.Reload()
+	return nil
 }
 
-// Функция/метод получения (не буферизированного) канала для получения событий.
+// Функция/метод получения канала для получения событий (не буферизирован,
+// если не задан LoginOpts.Buffer).
 func (l *Login) C() <-chan LoginEvent {
 	return l.evtChan
 }
 
+// Канал ошибок службы (*LoginError): временные (чтение utmp, перезагрузка
+// конфигурации, ошибки fsnotify) и фатальные (utmp файл удалён или
+// переименован - события больше не поступают, следует пересоздать
+// службу). Буферизирован (LOGIN_QUEUE), при переполнении ошибки
+// только журналируются. Закрывается в Close().
+// Get channel of service errors.
+func (l *Login) Errors() <-chan error {
+	return l.errChan
+}
+
+// Канал изменений дополнительных файлов (см. LoginOpts.Watch).
+// Буферизирован (LOGIN_QUEUE), при переполнении изменения только
+// журналируются. Закрывается в Close().
+// Get channel of watched file changes.
+func (l *Login) Files() <-chan FileEvent {
+	return l.files
+}
+
 // Функция/метод получения (из памяти) полной информация
 // обо всех пользователях в системе
 func (l *Login) GetUsers() []LoginInfo {
@@ -0,0 +1,166 @@
+// File: "backend.go"
+
+package utmp
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Способ отслеживания изменений utmp файла и файла конфигурации.
+// File change notification backend.
+type Backend int
+
+const (
+	BACKEND_FSNOTIFY Backend = iota // inotify (default)
+	BACKEND_POLL                    // periodic stat (NFS, no inotify)
+)
+
+// Названия способов (для опций командной строки).
+var BackendStr = [...]string{"fsnotify", "poll"}
+
+// Получить способ отслеживания по названию ("fsnotify", "poll").
+// Parse backend.
+func ParseBackend(s string) (Backend, error) {
+	for i, name := range BackendStr {
+		if name == s {
+			return Backend(i), nil
+		}
+	}
+	return BACKEND_FSNOTIFY, fmt.Errorf("unknown backend '%s'", s)
+}
+
+// inotify недоступен (BACKEND_FSNOTIFY заменяется на BACKEND_POLL).
+var errNoInotify = errors.New("inotify is unavailable")
+
+// Создание fsnotify.Watcher (подменяется в тестах).
+var newFsnotify = fsnotify.NewWatcher
+
+// Создать fsnotify.Watcher и отслеживать каталоги utmp файла и
+// дополнительных файлов (каталоги, чтобы не потерять файл при атомарной
+// замене и получить событие появления файла). Ошибки, при которых
+// возможен только опрос, оборачивают errNoInotify.
+func (l *Login) newWatcher() error {
+	w, err := newFsnotify()
+	if err != nil {
+		return fmt.Errorf("%w: %w", errNoInotify, err)
+	}
+	dirs := map[string]struct{}{filepath.Dir(l.fname): {}}
+	for name := range l.watch {
+		dirs[filepath.Dir(name)] = struct{}{}
+	}
+	for dir := range dirs {
+		if err = w.Add(dir); err != nil {
+			w.Close()
+			if errors.Is(err, syscall.ENOSPC) { // max_user_watches
+				return fmt.Errorf("%w: %w", errNoInotify, err)
+			}
+			return err
+		}
+	}
+	l.watcher = w
+	return nil
+}
+
+// Получить фактический способ отслеживания изменений файлов.
+// Get effective backend.
+func (l *Login) Backend() Backend {
+	return l.backend
+}
+
+// Период опроса файлов по умолчанию (BACKEND_POLL).
+// Default poll interval.
+const POLL_INTERVAL = time.Second
+
+// Состояние опрашиваемого файла.
+type polled struct {
+	fi os.FileInfo // nil - file does not exist
+}
+
+// Опросить файл и сформировать событие, аналогичное fsnotify.
+func (p *polled) poll(name string) (evt fsnotify.Event, ok bool) {
+	fi, err := os.Stat(name)
+	if err != nil {
+		fi = nil
+	}
+	prev := p.fi
+	p.fi = fi
+	evt.Name = name
+	switch StatChange(prev, fi).Kind {
+	case CHANGE_DELETED:
+		evt.Op = fsnotify.Remove
+	case CHANGE_CREATED, CHANGE_REPLACED:
+		evt.Op = fsnotify.Create
+	case CHANGE_APPENDED, CHANGE_TRUNCATED, CHANGE_MODIFIED:
+		evt.Op = fsnotify.Write
+	default:
+		if fi == nil || fi.Mode() == prev.Mode() {
+			return evt, false
+		}
+		evt.Op = fsnotify.Chmod
+	}
+	return evt, true
+}
+
+// Горутина опроса utmp файла, файла конфигурации и дополнительных
+// файлов (BACKEND_POLL, watch - их начальное состояние): изменения
+// передаются в канал событий так же, как от fsnotify.
+// Poll goroutine.
+func pollerFn(l *Login, events chan<- fsnotify.Event, interval time.Duration, watch map[string]os.FileInfo) {
+	defer l.wg.Done()
+	defer close(events)
+
+	var utmp, conf polled
+	utmp.fi, _ = os.Stat(l.fname) // first read does not wait for event
+	confName := ""
+	type file struct {
+		name string
+		p    *polled
+	}
+	files := []file{{l.fname, &utmp}, {"", &conf}}
+	for name, fi := range watch {
+		files = append(files, file{name, &polled{fi: fi}})
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-l.done:
+			return
+		}
+
+		l.confMx.Lock()
+		name, fi := l.confName, l.confInfo
+		l.confMx.Unlock()
+		if name != confName { // WatchConfig() loads config itself
+			confName = name
+			conf.fi = fi
+		}
+
+		files[1].name = confName
+		for _, f := range files {
+			if f.name == "" {
+				continue
+			}
+			evt, ok := f.p.poll(f.name)
+			if !ok {
+				continue
+			}
+			select {
+			case events <- evt:
+			case <-l.done:
+				return
+			}
+		}
+	}
+}
+
+// EOF: "backend.go"
@@ -0,0 +1,118 @@
+// File: "change.go"
+
+package utmp
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// Вид изменения файла между двумя os.Stat().
+// File change kind.
+type ChangeKind int
+
+const (
+	CHANGE_NONE      ChangeKind = iota // no change
+	CHANGE_CREATED                     // file appeared
+	CHANGE_APPENDED                    // grew (same inode)
+	CHANGE_MODIFIED                    // rewritten in place (size not grown)
+	CHANGE_TRUNCATED                   // shrunk
+	CHANGE_REPLACED                    // other inode (atomic replace, rotation)
+	CHANGE_DELETED                     // file disappeared
+)
+
+// Названия видов изменения.
+var ChangeKindStr = [...]string{
+	"none", "created", "appended", "modified", "truncated", "replaced", "deleted"}
+
+// Получить название вида изменения.
+// Change kind name.
+func (k ChangeKind) String() string {
+	if k >= 0 && int(k) < len(ChangeKindStr) {
+		return ChangeKindStr[k]
+	}
+	return ""
+}
+
+// Изменение файла: по нему выбирается инкрементальное или полное
+// чтение.
+// File change.
+type FileChange struct {
+	Kind             ChangeKind
+	OldSize, NewSize int64     // размер
+	OldMod, NewMod   time.Time // время изменения
+	OldIno, NewIno   uint64    // inode
+}
+
+// Изменение дополнительного отслеживаемого файла (см. LoginOpts.Watch).
+// Watched file change.
+type FileEvent struct {
+	Path   string     // путь файла (как в LoginOpts.Watch, после filepath.Clean)
+	Change FileChange // изменение (CHANGE_CREATED - файл появился)
+	Time   time.Time  // время обнаружения изменения
+}
+
+// Определить изменение файла (prev - прежнее состояние, cur - текущее,
+// nil - файл отсутствует).
+// Classify file change.
+func StatChange(prev, cur os.FileInfo) (c FileChange) {
+	if prev != nil {
+		c.OldSize, c.OldMod, c.OldIno = prev.Size(), prev.ModTime(), inode(prev)
+	}
+	if cur != nil {
+		c.NewSize, c.NewMod, c.NewIno = cur.Size(), cur.ModTime(), inode(cur)
+	}
+	switch {
+	case prev == nil && cur == nil:
+		c.Kind = CHANGE_NONE
+	case prev == nil:
+		c.Kind = CHANGE_CREATED
+	case cur == nil:
+		c.Kind = CHANGE_DELETED
+	case !os.SameFile(prev, cur):
+		c.Kind = CHANGE_REPLACED
+	case c.NewSize < c.OldSize:
+		c.Kind = CHANGE_TRUNCATED
+	case c.NewSize > c.OldSize:
+		c.Kind = CHANGE_APPENDED
+	case !c.NewMod.Equal(c.OldMod):
+		c.Kind = CHANGE_MODIFIED
+	default:
+		c.Kind = CHANGE_NONE
+	}
+	return c
+}
+
+// Файл нужно прочитать полностью (прежнее состояние не применимо).
+// Full re-read required.
+func (c FileChange) Full() bool {
+	switch c.Kind {
+	case CHANGE_CREATED, CHANGE_TRUNCATED, CHANGE_REPLACED, CHANGE_DELETED:
+		return true
+	}
+	return false
+}
+
+// Объединить последовательные изменения (c - более раннее): прежнее
+// состояние берётся из c, текущее - из next, вид - из next, если c не
+// требует полного чтения.
+// Merge consecutive changes.
+func (c FileChange) Merge(next FileChange) FileChange {
+	m := next
+	m.OldSize, m.OldMod, m.OldIno = c.OldSize, c.OldMod, c.OldIno
+	if c.Full() && !next.Full() {
+		m.Kind = c.Kind
+	}
+	return m
+}
+
+// Получить номер inode (0 - неизвестен).
+func inode(fi os.FileInfo) uint64 {
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		return uint64(st.Ino)
+	}
+	return 0
+}
+
+// EOF: "change.go"
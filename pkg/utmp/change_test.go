@@ -0,0 +1,100 @@
+// File: "change_test.go"
+
+package utmp
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatChange(t *testing.T) {
+	dir := t.TempDir()
+	fname := filepath.Join(dir, "utmp")
+	stat := func() os.FileInfo {
+		fi, err := os.Stat(fname)
+		if err != nil {
+			return nil
+		}
+		return fi
+	}
+	touch := func(ts time.Time) {
+		require.NoError(t, os.Chtimes(fname, ts, ts))
+	}
+	ts := time.Unix(1000, 0)
+
+	require.Equal(t, CHANGE_NONE, StatChange(nil, nil).Kind)
+
+	require.NoError(t, os.WriteFile(fname, make([]byte, RECORD_SIZE), 0o644))
+	touch(ts)
+	fi := stat()
+	c := StatChange(nil, fi)
+	require.Equal(t, CHANGE_CREATED, c.Kind)
+	require.Equal(t, int64(RECORD_SIZE), c.NewSize)
+	require.NotZero(t, c.NewIno)
+	require.True(t, c.Full())
+	require.Equal(t, CHANGE_NONE, StatChange(fi, stat()).Kind)
+
+	// appended
+	require.NoError(t, os.WriteFile(fname, make([]byte, 2*RECORD_SIZE), 0o644))
+	touch(ts.Add(time.Second))
+	c = StatChange(fi, stat())
+	require.Equal(t, CHANGE_APPENDED, c.Kind)
+	require.Equal(t, int64(RECORD_SIZE), c.OldSize)
+	require.Equal(t, int64(2*RECORD_SIZE), c.NewSize)
+	require.Equal(t, c.OldIno, c.NewIno)
+	require.False(t, c.Full())
+
+	// rewritten in place
+	fi = stat()
+	touch(ts.Add(2 * time.Second))
+	c = StatChange(fi, stat())
+	require.Equal(t, CHANGE_MODIFIED, c.Kind)
+	require.False(t, c.Full())
+
+	// truncated
+	fi = stat()
+	require.NoError(t, os.Truncate(fname, 0))
+	c = StatChange(fi, stat())
+	require.Equal(t, CHANGE_TRUNCATED, c.Kind)
+	require.True(t, c.Full())
+
+	// atomic replace
+	fi = stat()
+	tmp := filepath.Join(dir, "utmp.new")
+	require.NoError(t, os.WriteFile(tmp, nil, 0o644))
+	require.NoError(t, os.Rename(tmp, fname))
+	c = StatChange(fi, stat())
+	require.Equal(t, CHANGE_REPLACED, c.Kind)
+	require.NotEqual(t, c.OldIno, c.NewIno)
+	require.True(t, c.Full())
+
+	// deleted
+	fi = stat()
+	require.NoError(t, os.Remove(fname))
+	c = StatChange(fi, stat())
+	require.Equal(t, CHANGE_DELETED, c.Kind)
+	require.Equal(t, "deleted", c.Kind.String())
+	require.Equal(t, "", ChangeKind(-1).String())
+	require.Equal(t, "", ChangeKind(len(ChangeKindStr)).String())
+	require.True(t, c.Full())
+}
+
+func TestFileChangeMerge(t *testing.T) {
+	a := FileChange{Kind: CHANGE_TRUNCATED, OldSize: 768, NewSize: 0}
+	b := FileChange{Kind: CHANGE_APPENDED, OldSize: 0, NewSize: 384}
+	m := a.Merge(b)
+	require.Equal(t, CHANGE_TRUNCATED, m.Kind) // full re-read still required
+	require.Equal(t, int64(768), m.OldSize)
+	require.Equal(t, int64(384), m.NewSize)
+
+	m = b.Merge(FileChange{Kind: CHANGE_APPENDED, OldSize: 384, NewSize: 768})
+	require.Equal(t, CHANGE_APPENDED, m.Kind)
+	require.Equal(t, int64(0), m.OldSize)
+	require.Equal(t, int64(768), m.NewSize)
+}
+
+// EOF: "change_test.go"
@@ -0,0 +1,250 @@
+// File: "classify.go"
+
+package utmp
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// Классификатор типа входа пользователя. Классификаторы опрашиваются
+// по очереди (см. RegisterClassifier()), первый вернувший ok=true
+// определяет тип входа; последним опрашивается DefaultClassifier.
+// Login type classifier (e.g. for VNC, x2go, mosh, site specific DM).
+type Classifier interface {
+	Classify(u *User) (t LoginType, ok bool)
+}
+
+// Функция-классификатор.
+// Classifier function adapter.
+type ClassifierFunc func(u *User) (LoginType, bool)
+
+func (f ClassifierFunc) Classify(u *User) (LoginType, bool) {
+	return f(u)
+}
+
+// Правило классификации из конфигурации: все заданные регулярные
+// выражения должны совпасть с соответствующими полями пользователя.
+// Login type rule from config (all given patterns must match).
+type Rule struct {
+	Type string `json:"type"`           // "remote", "remote_x", "local", "local_x"
+	Host string `json:"host,omitempty"` // Host field regexp
+	TTY  string `json:"tty,omitempty"`  // TTY field regexp
+	ID   string `json:"id,omitempty"`   // ID field regexp
+	Cmd  string `json:"cmd,omitempty"`  // command line of session leader regexp
+}
+
+// Скомпилированное правило.
+type rule struct {
+	t                  LoginType
+	host, tty, id, cmd *regexp.Regexp
+}
+
+// Классификатор по умолчанию (X дисплей, XRDP, Wayland, IP адрес/узел).
+// Default classifier.
+var DefaultClassifier Classifier = ClassifierFunc(defaultClassify)
+
+// Зарегистрированные классификаторы.
+var classifiers atomic.Pointer[[]Classifier]
+
+// Добавить классификатор в конец очереди (перед правилами из
+// конфигурации и DefaultClassifier).
+// Register login type classifier (thread safe).
+func RegisterClassifier(c Classifier) {
+	for {
+		old := classifiers.Load()
+		cs := []Classifier{}
+		if old != nil {
+			cs = append(cs, *old...)
+		}
+		cs = append(cs, c)
+		if classifiers.CompareAndSwap(old, &cs) {
+			return
+		}
+	}
+}
+
+// Заменить все зарегистрированные классификаторы (без аргументов -
+// удалить все).
+// Replace registered classifiers.
+func SetClassifiers(cs ...Classifier) {
+	cs = append([]Classifier{}, cs...)
+	classifiers.Store(&cs)
+}
+
+// Определить тип входа пользователя.
+// Classify user login type.
+func Classify(u *User) LoginType {
+	if cs := classifiers.Load(); cs != nil {
+		for _, c := range *cs {
+			if t, ok := c.Classify(u); ok {
+				return t
+			}
+		}
+	}
+
+	for _, r := range curDetector.Load().rules {
+		if r.match(u) {
+			return r.t
+		}
+	}
+
+	t, _ := DefaultClassifier.Classify(u)
+	return t
+}
+
+// Получить тип входа по имени ("remote", "local_x", ...).
+// Parse login type name.
+func ParseLoginType(s string) (LoginType, error) {
+	for i, name := range LoginTypeStr {
+		if i != int(UNKNOWN) && name == s {
+			return LoginType(i), nil
+		}
+	}
+	return UNKNOWN, fmt.Errorf("unknown login type '%s'", s)
+}
+
+// Скомпилировать правило.
+func compileRule(r Rule) (rule, error) {
+	var c rule
+	var err error
+
+	c.t, err = ParseLoginType(r.Type)
+	if err != nil {
+		return c, err
+	}
+
+	for _, p := range []struct {
+		re  **regexp.Regexp
+		src string
+	}{{&c.host, r.Host}, {&c.tty, r.TTY}, {&c.id, r.ID}, {&c.cmd, r.Cmd}} {
+		if p.src == "" {
+			continue
+		}
+		*p.re, err = regexp.Compile(p.src)
+		if err != nil {
+			return c, err
+		}
+	}
+	return c, nil
+}
+
+// Проверить совпадение правила.
+func (r *rule) match(u *User) bool {
+	if r.host != nil && !r.host.MatchString(u.Host) {
+		return false
+	}
+	if r.tty != nil && !r.tty.MatchString(u.TTY) {
+		return false
+	}
+	if r.id != nil && !r.id.MatchString(u.ID) {
+		return false
+	}
+	if r.cmd != nil {
+		pid := u.procPID()
+		if pid == 0 {
+			return false // no process or offline analysis
+		}
+		cmd, err := GetCmdline(pid)
+		if err != nil || !r.cmd.MatchString(cmd) {
+			return false
+		}
+	}
+	return true
+}
+
+// Классификация по умолчанию.
+func defaultClassify(u *User) (LoginType, bool) {
+	d := curDetector.Load()
+	msX := d.xDisplay.MatchString    // user logged to X
+	msRDP := func(cmd string) bool { // user logged by XRDP/VNC/x2go/NX
+		for _, re := range d.remoteX {
+			if re.MatchString(cmd) {
+				return true
+			}
+		}
+		return false
+	}
+
+	t := UNKNOWN
+	if msX(u.Host) || msX(u.ID) || msX(u.TTY) { // e.g. ":1"
+		if u.IP.Equal(net.IP{}) { // IP is empty
+			t = LOCAL_X
+			if pid := u.procPID(); pid != 0 && msRDP(cmdline(pid)) {
+				t = REMOTE_X // XRDP, VNC, x2go, NoMachine
+			} else if d.isVNCDisplay(u) {
+				t = REMOTE_X // VNC server listens for the display
+			}
+		}
+	} else {
+		if u.IP.Equal(net.IP{}) && u.Host == "" { // IP and Host is empty
+			t = LOCAL
+			if d.isWayland(u.procPID()) {
+				t = LOCAL_X // Wayland session (no X display)
+			}
+		} else {
+			t = REMOTE
+		}
+	}
+	return t, true
+}
+
+// Командная строка процесса ("" при ошибке).
+func cmdline(pid uint32) string {
+	cmd, _ := GetCmdline(pid)
+	return cmd
+}
+
+// Проверить, что для X дисплея пользователя (":N") прослушивается
+// порт VNC сервера (VNCPortBase+N).
+func (d *detector) isVNCDisplay(u *User) bool {
+	if d.conf.VNCPortBase < 0 || u.offline {
+		return false
+	}
+	for _, s := range []string{u.Host, u.ID, u.TTY} {
+		if n, ok := displayNumber(s); ok {
+			ports, err := GetListenPorts()
+			return err == nil && ports[d.conf.VNCPortBase+n]
+		}
+	}
+	return false
+}
+
+// Получить номер X дисплея из строки вида ":1" или ":1.0".
+func displayNumber(s string) (int, bool) {
+	s, ok := strings.CutPrefix(s, ":")
+	if !ok {
+		return 0, false
+	}
+	s, _, _ = strings.Cut(s, ".")
+	n, err := strconv.Atoi(s)
+	return n, err == nil && n >= 0
+}
+
+// Проверить, что процесс - лидер сеанса Wayland: переменная окружения
+// XDG_SESSION_TYPE=wayland или командная строка лидера сеанса
+// (композитора) соответствует Config.Wayland.
+func (d *detector) isWayland(pid uint32) bool {
+	if pid == 0 {
+		return false
+	}
+	if v, ok, _ := GetEnv(pid, "XDG_SESSION_TYPE"); ok {
+		return v == "wayland"
+	}
+	cmd, err := GetCmdline(pid)
+	if err != nil {
+		return false
+	}
+	for _, re := range d.wayland {
+		if re.MatchString(cmd) {
+			return true
+		}
+	}
+	return false
+}
+
+// EOF: "classify.go"
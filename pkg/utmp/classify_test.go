@@ -0,0 +1,197 @@
+// File: "classify_test.go"
+
+package utmp
+
+import (
+	"net"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassify(t *testing.T) {
+	defer SetClassifiers()
+	defer SetConfig(DefaultConfig())
+
+	local := &User{TTY: "tty1"}
+	ssh := &User{TTY: "pts/0", Host: "10.0.0.5", IP: net.IPv4(10, 0, 0, 5)}
+	mosh := &User{TTY: "pts/1", Host: "10.0.0.6 via mosh [1234]"}
+	require.Equal(t, LOCAL, local.LoginType())
+	require.Equal(t, REMOTE, ssh.LoginType())
+
+	// rule from config
+	require.NoError(t, SetConfig(Config{Rules: []Rule{{Type: "remote_x", Host: "via mosh"}}}))
+	require.Equal(t, REMOTE_X, mosh.LoginType())
+	require.Equal(t, REMOTE, ssh.LoginType())
+	require.Error(t, SetConfig(Config{Rules: []Rule{{Type: "vnc"}}}))
+
+	// registered classifier is asked first
+	RegisterClassifier(ClassifierFunc(func(u *User) (LoginType, bool) {
+		return LOCAL_X, u.TTY == "pts/1"
+	}))
+	require.Equal(t, LOCAL_X, mosh.LoginType())
+	require.Equal(t, LOCAL, local.LoginType())
+}
+
+func TestClassifyWayland(t *testing.T) {
+	cmd := exec.Command("sleep", "10")
+	cmd.Env = append(os.Environ(), "XDG_SESSION_TYPE=wayland")
+	require.NoError(t, cmd.Start())
+	defer cmd.Process.Kill()
+
+	u := &User{TTY: "tty2", PID: uint32(cmd.Process.Pid)}
+	require.Equal(t, LOCAL_X, u.LoginType())
+
+	console := exec.Command("sleep", "10")
+	console.Env = []string{"XDG_SESSION_TYPE=tty"}
+	require.NoError(t, console.Start())
+	defer console.Process.Kill()
+
+	u.PID = uint32(console.Process.Pid)
+	require.Equal(t, LOCAL, u.LoginType())
+}
+
+func TestClassifyVNC(t *testing.T) {
+	defer SetConfig(DefaultConfig())
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	u := &User{TTY: ":7", Host: ":7"}
+	require.NoError(t, SetConfig(Config{VNCPortBase: port - 7}))
+	require.Equal(t, REMOTE_X, u.LoginType())
+
+	require.NoError(t, SetConfig(Config{VNCPortBase: -1}))
+	require.Equal(t, LOCAL_X, u.LoginType())
+
+	n, ok := displayNumber(":1.0")
+	require.True(t, ok)
+	require.Equal(t, 1, n)
+}
+
+func TestSetRemoteXPatterns(t *testing.T) {
+	defer SetConfig(DefaultConfig())
+
+	cmd := exec.Command("sleep", "10")
+	require.NoError(t, cmd.Start())
+	defer cmd.Process.Kill()
+	u := &User{TTY: ":5", Host: ":5", PID: uint32(cmd.Process.Pid)}
+
+	require.NoError(t, SetConfig(Config{VNCPortBase: -1, Labels: map[string]string{"dc": "a"}}))
+	require.Equal(t, LOCAL_X, u.LoginType())
+
+	require.NoError(t, SetRemoteXPatterns([]string{`^sleep `}))
+	require.Equal(t, REMOTE_X, u.LoginType())
+	require.Equal(t, []string{`^sleep `}, RemoteXPatterns())
+	require.Equal(t, "a", Labels()["dc"]) // other settings kept
+
+	require.Error(t, SetRemoteXPatterns([]string{"("}))
+	require.NoError(t, SetRemoteXPatterns(nil))
+	require.Equal(t, DefaultRemoteX, RemoteXPatterns())
+}
+
+func TestOffline(t *testing.T) {
+	defer SetConfig(DefaultConfig())
+	defer SetOffline(false)
+
+	cmd := exec.Command("sleep", "10")
+	require.NoError(t, cmd.Start())
+	defer cmd.Process.Kill()
+	u := &User{TTY: ":5", Host: ":5", PID: uint32(cmd.Process.Pid)}
+
+	require.NoError(t, SetRemoteXPatterns([]string{`^sleep `}))
+	require.Equal(t, REMOTE_X, u.LoginType())
+
+	SetOffline(true)
+	require.Equal(t, LOCAL_X, u.LoginType()) // no cmdline
+	_, err := GetCmdline(u.PID)
+	require.ErrorIs(t, err, ErrOffline)
+	require.True(t, Users{u}.GetLoginStat().Offline)
+	_, err = GetUserInfo("root")
+	require.ErrorIs(t, err, ErrOffline)
+}
+
+func TestOfflineOption(t *testing.T) {
+	defer SetConfig(DefaultConfig())
+
+	cmd := exec.Command("sleep", "10")
+	require.NoError(t, cmd.Start())
+	defer cmd.Process.Kill()
+	pid := uint32(cmd.Process.Pid)
+	now := int32(time.Now().Unix())
+	fname := testFile(t,
+		testRecord(USER_PROCESS, pid, ":5", ":5", "root", ":5", now))
+
+	require.NoError(t, SetRemoteXPatterns([]string{`^sleep `}))
+	users, err := GetUsersWith(fname, GetUsersOpts{})
+	require.NoError(t, err)
+	require.Equal(t, REMOTE_X, users[0].LoginType())
+	info, err := users.GetLoginInfo("root")
+	require.NoError(t, err)
+	require.Equal(t, "0", info.UID)
+
+	users, err = GetUsersWith(fname, GetUsersOpts{Offline: true})
+	require.NoError(t, err)
+	require.Equal(t, LOCAL_X, users[0].LoginType()) // no cmdline
+	info, err = users.GetLoginInfo("root")
+	require.NoError(t, err)
+	require.Equal(t, "", info.UID) // no user database
+	require.True(t, users.GetLoginStat().Offline)
+	require.False(t, Offline())
+}
+
+func TestCheckStale(t *testing.T) {
+	defer SetConfig(DefaultConfig())
+
+	cmd := exec.Command("sleep", "10")
+	require.NoError(t, cmd.Start())
+	defer cmd.Process.Kill()
+	pid := uint32(cmd.Process.Pid)
+
+	start, err := GetProcStart(pid)
+	require.NoError(t, err)
+	require.WithinDuration(t, time.Now(), start, 5*time.Second)
+
+	require.NoError(t, SetRemoteXPatterns([]string{`^sleep `}))
+	u := &User{TTY: ":5", Host: ":5", PID: pid, Time: time.Now()}
+	require.False(t, u.CheckStale())
+	require.Equal(t, REMOTE_X, u.LoginType())
+
+	u.Time = time.Now().Add(-time.Hour) // login before process start
+	require.True(t, u.CheckStale())
+	require.Equal(t, LOCAL_X, u.LoginType()) // cmdline is not used
+}
+
+func TestGetUsersStale(t *testing.T) {
+	cmd := exec.Command("sleep", "10")
+	require.NoError(t, cmd.Start())
+	defer cmd.Process.Kill()
+	pid := uint32(cmd.Process.Pid)
+
+	// login record before process start: PID is reused
+	fname := testFile(t, testRecord(USER_PROCESS, pid, "pts/0", "ts/0", "alice",
+		"10.0.0.1", int32(time.Now().Add(-time.Hour).Unix())))
+
+	for _, tc := range []struct {
+		opts  GetUsersOpts
+		stale bool
+	}{
+		{GetUsersOpts{}, false}, // no /proc lookups by default
+		{GetUsersOpts{Stale: true}, true},
+		{GetUsersOpts{Elevated: true}, true},
+		{GetUsersOpts{Mux: MUX_TAG}, true},
+		{GetUsersOpts{Stale: true, Offline: true}, false},
+	} {
+		users, err := GetUsersWith(fname, tc.opts)
+		require.NoError(t, err)
+		require.Len(t, users, 1)
+		require.Equal(t, tc.stale, users[0].Stale, "%+v", tc.opts)
+	}
+}
+
+// EOF: "classify_test.go"
@@ -0,0 +1,185 @@
+// File: "compress.go"
+
+package utmp
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// Сигнатуры (magic bytes) сжатых файлов.
+// Magic bytes of compressed files.
+var (
+	MAGIC_GZIP = []byte{0x1F, 0x8B}
+	MAGIC_XZ   = []byte{0xFD, '7', 'z', 'X', 'Z', 0x00}
+	MAGIC_ZSTD = []byte{0x28, 0xB5, 0x2F, 0xFD}
+
+	MAGIC_BZIP2 = []byte{'B', 'Z', 'h'} // not supported
+)
+
+// Внешние программы распаковки для форматов, не поддерживаемых
+// стандартной библиотекой Go.
+// External decompressors (xz, zstd).
+var (
+	XZ_CMD   = []string{"xz", "-dc"}
+	ZSTD_CMD = []string{"zstd", "-dc"}
+)
+
+// Открыть utmp/wtmp/btmp файл для чтения записей; сжатые файлы
+// (gzip/xz/zstd) распаковываются "на лету" (формат определяется
+// по сигнатуре).
+// Для несжатого файла проверяется тип первой записи (ErrUnsupportedFormat).
+// Open utmp/wtmp/btmp file (gzip/xz/zstd are decompressed transparently).
+func Open(fname string) (io.ReadCloser, error) {
+	f, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+
+	// Несжатый файл возвращается как есть (с возможностью Seek)
+	magic := make([]byte, len(MAGIC_XZ))
+	n, _ := f.ReadAt(magic, 0)
+	if !isCompressed(magic[:n]) {
+		if err = checkFormat(f, fname); err != nil {
+			f.Close()
+			return nil, err
+		}
+		return f, nil
+	}
+
+	r, err := Decompress(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", fname, err)
+	}
+	return r, nil
+}
+
+// Распаковать поток, если он сжат (gzip/xz/zstd), иначе вернуть его как есть.
+// Закрытие результата закрывает исходный поток (если это io.Closer).
+// Decompress stream if it's compressed (detect by magic bytes).
+func Decompress(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReaderSize(r, RECORD_SIZE)
+	magic, _ := br.Peek(len(MAGIC_XZ)) // short file is not an error here
+
+	closer, ok := r.(io.Closer)
+	if !ok {
+		closer = io.NopCloser(nil)
+	}
+
+	switch {
+	case bytes.HasPrefix(magic, MAGIC_GZIP):
+		z, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		return &readCloser{z, func() error {
+			return errors.Join(z.Close(), closer.Close())
+		}}, nil
+
+	case bytes.HasPrefix(magic, MAGIC_XZ):
+		return execDecompress(XZ_CMD, br, closer)
+
+	case bytes.HasPrefix(magic, MAGIC_ZSTD):
+		return execDecompress(ZSTD_CMD, br, closer)
+	}
+
+	return &readCloser{br, closer.Close}, nil
+}
+
+// Проверить сигнатуру сжатого файла.
+func isCompressed(magic []byte) bool {
+	return bytes.HasPrefix(magic, MAGIC_GZIP) ||
+		bytes.HasPrefix(magic, MAGIC_XZ) ||
+		bytes.HasPrefix(magic, MAGIC_ZSTD)
+}
+
+// Распаковать поток внешней программой: ошибка её завершения (с текстом
+// stderr) возвращается из Read() при достижении конца потока и из Close().
+func execDecompress(args []string, r io.Reader, closer io.Closer) (io.ReadCloser, error) {
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = r
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	er := &execReader{out: out, cmd: cmd, closer: closer}
+	cmd.Stderr = &er.stderr
+
+	err = cmd.Start()
+	if err != nil {
+		return nil, fmt.Errorf("can't run %s: %w", args[0], err)
+	}
+	return er, nil
+}
+
+// Поток распаковки внешней программой.
+type execReader struct {
+	out    io.ReadCloser
+	cmd    *exec.Cmd
+	stderr bytes.Buffer
+	closer io.Closer // исходный поток
+	done   bool      // программа завершена (см. wait())
+	err    error     // ошибка завершения программы
+}
+
+func (r *execReader) Read(p []byte) (int, error) {
+	n, err := r.out.Read(p)
+	if err == io.EOF {
+		if werr := r.wait(false); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+// Закрыть поток: программа, не дочитанная до конца, завершается по SIGPIPE
+// (это не ошибка).
+func (r *execReader) Close() error {
+	r.out.Close()
+	return errors.Join(r.wait(true), r.closer.Close())
+}
+
+// Дождаться завершения программы (однократно).
+func (r *execReader) wait(early bool) error {
+	if r.done {
+		return r.err
+	}
+	r.done = true
+	err := r.cmd.Wait()
+	var exit *exec.ExitError
+	if early && errors.As(err, &exit) {
+		if ws, ok := exit.Sys().(syscall.WaitStatus); ok &&
+			ws.Signaled() && ws.Signal() == syscall.SIGPIPE {
+			return nil // closed before EOF
+		}
+	}
+	if err != nil {
+		msg := strings.TrimSpace(r.stderr.String())
+		if msg != "" {
+			err = fmt.Errorf("%w: %s", err, msg)
+		}
+		r.err = fmt.Errorf("%s: %w", r.cmd.Args[0], err)
+	}
+	return r.err
+}
+
+// Поток с произвольной функцией закрытия.
+type readCloser struct {
+	io.Reader
+	close func() error
+}
+
+func (rc *readCloser) Close() error {
+	return rc.close()
+}
+
+// EOF: "compress.go"
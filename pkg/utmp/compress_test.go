@@ -0,0 +1,136 @@
+// File: "compress_test.go"
+
+package utmp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Файл wtmp из n записей входа и его содержимое.
+func compressFixture(t *testing.T, n int) (string, []byte) {
+	recs := make([]Utmp, n)
+	for i := range recs {
+		recs[i] = testRecord(USER_PROCESS, uint32(100+i), "pts/0", "ts/0", "alice", "10.0.0.5", int32(1000+i))
+	}
+	fname := testFile(t, recs...)
+	data, err := os.ReadFile(fname)
+	require.NoError(t, err)
+	return fname, data
+}
+
+// Сжать файл внешней программой (тест пропускается, если её нет).
+func compressWith(t *testing.T, fname string, args ...string) string {
+	if _, err := exec.LookPath(args[0]); err != nil {
+		t.Skipf("%s is not found", args[0])
+	}
+	out, err := exec.Command(args[0], append(args[1:], "-c", fname)...).Output()
+	require.NoError(t, err)
+	zname := fname + "." + args[0]
+	require.NoError(t, os.WriteFile(zname, out, 0644))
+	return zname
+}
+
+func TestIsCompressed(t *testing.T) {
+	for _, tc := range []struct {
+		magic []byte
+		ok    bool
+	}{
+		{MAGIC_GZIP, true},
+		{MAGIC_XZ, true},
+		{MAGIC_ZSTD, true},
+		{MAGIC_BZIP2, false},
+		{MAGIC_XZ[:3], false},
+		{[]byte{0x07, 0x00}, false}, // USER_PROCESS record
+		{nil, false},
+	} {
+		require.Equal(t, tc.ok, isCompressed(tc.magic), "%x", tc.magic)
+	}
+}
+
+func TestOpenGzip(t *testing.T) {
+	fname, data := compressFixture(t, 10)
+
+	// plain file is returned as is
+	r, err := Open(fname)
+	require.NoError(t, err)
+	require.IsType(t, &os.File{}, r)
+	require.NoError(t, r.Close())
+
+	var buf bytes.Buffer
+	z := gzip.NewWriter(&buf)
+	_, err = z.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, z.Close())
+	zname := fname + ".gz"
+	require.NoError(t, os.WriteFile(zname, buf.Bytes(), 0644))
+
+	r, err = Open(zname)
+	require.NoError(t, err)
+	got, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	require.Equal(t, data, got)
+
+	// truncated file
+	require.NoError(t, os.WriteFile(zname, buf.Bytes()[:buf.Len()/2], 0644))
+	r, err = Open(zname)
+	require.NoError(t, err)
+	_, err = io.ReadAll(r)
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	r.Close()
+}
+
+func TestOpenXZ(t *testing.T) { testOpenExec(t, "xz", &XZ_CMD) }
+
+func TestOpenZstd(t *testing.T) { testOpenExec(t, "zstd", &ZSTD_CMD) }
+
+func testOpenExec(t *testing.T, name string, cmd *[]string) {
+	fname, data := compressFixture(t, 1000) // larger than pipe buffer
+	zname := compressWith(t, fname, name, "-q")
+
+	r, err := Open(zname)
+	require.NoError(t, err)
+	got, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	require.Equal(t, data, got)
+
+	// closed before EOF: SIGPIPE is not an error
+	r, err = Open(zname)
+	require.NoError(t, err)
+	_, err = io.ReadFull(r, make([]byte, RECORD_SIZE))
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+
+	// truncated file: error of decompressor with its stderr
+	z, err := os.ReadFile(zname)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(zname, z[:len(z)/2], 0644))
+	r, err = Open(zname)
+	require.NoError(t, err)
+	_, err = io.ReadAll(r)
+	require.ErrorContains(t, err, name+": exit status")
+	require.Error(t, r.Close())
+
+	// missing and failing decompressor
+	saved := *cmd
+	defer func() { *cmd = saved }()
+	*cmd = []string{"gousers-no-such-" + name}
+	_, err = Open(zname)
+	require.ErrorContains(t, err, "can't run")
+	*cmd = []string{"false"}
+	r, err = Open(zname)
+	require.NoError(t, err)
+	_, err = io.ReadAll(r)
+	require.ErrorContains(t, err, "false: exit status 1")
+	r.Close()
+}
+
+// EOF: "compress_test.go"
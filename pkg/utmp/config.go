@@ -0,0 +1,365 @@
+// File: "config.go"
+
+package utmp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// Конфигурация определения типа входа пользователей.
+// Может быть загружена из JSON файла и заменена "на лету" (без перезапуска
+// службы) с помощью SetConfig().
+// Detection config (may be reloaded at runtime).
+type Config struct {
+	// Регулярное выражение X дисплея (по умолчанию "^:[0-9]+$")
+	XDisplay string `json:"x_display,omitempty"`
+
+	// Регулярные выражения командной строки лидера сеанса удаленного
+	// X пользователя (по умолчанию DefaultRemoteX)
+	RemoteX []string `json:"remote_x,omitempty"`
+
+	// Базовый TCP порт VNC: X дисплей ":N", для которого прослушивается
+	// порт VNCPortBase+N, считается удалённым (0 - VNC_PORT_BASE,
+	// -1 - не проверять)
+	VNCPortBase int `json:"vnc_port_base,omitempty"`
+
+	// Регулярные выражения командной строки лидера сеанса (или композитора)
+	// локального пользователя Wayland (по умолчанию WAYLAND_CMD)
+	Wayland []string `json:"wayland,omitempty"`
+
+	// Регулярные выражения командной строки терминального мультиплексора
+	// (по умолчанию MUX_CMD)
+	Multiplexer []string `json:"multiplexer,omitempty"`
+
+	// Метки сетей: имя метки -> список сетей в формате CIDR
+	Networks map[string][]string `json:"networks,omitempty"`
+
+	// Регулярные выражения имён игнорируемых пользователей
+	IgnoreUsers []string `json:"ignore_users,omitempty"`
+
+	// Статические метки экземпляра службы (datacenter, role, tenant, ...),
+	// добавляемые к каждому событию, метрике и ответу API
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Правила определения типа входа (VNC, x2go, ...), проверяются
+	// по порядку перед правилами по умолчанию
+	Rules []Rule `json:"rules,omitempty"`
+
+	// Группы (команды) для статистики: имя группы -> регулярные выражения
+	// имён пользователей (остальные учитываются по основной группе)
+	Groups map[string][]string `json:"groups,omitempty"`
+
+	// Правила нормализации имён пользователей ("DOMAIN\alice",
+	// "alice@corp" -> "alice"), проверяются по порядку
+	UserMap []UserMap `json:"user_map,omitempty"`
+
+	// Правило выбора активного пользователя: "default", "seat0" или
+	// "recent" (по умолчанию "default", см. ActivePolicyStr)
+	ActivePolicy string `json:"active_policy,omitempty"`
+
+	// Предпочитаемые активные пользователи (киоски, операторы): первый
+	// вошедший пользователь из списка, иначе - правило ActivePolicy
+	ActiveUsers []string `json:"active_users,omitempty"`
+
+	// Регулярные выражения имён привилегированных пользователей
+	// (по умолчанию PRIVILEGED_USER - только root)
+	Privileged []string `json:"privileged,omitempty"`
+
+	// Группы привилегированных пользователей (wheel, sudo, ...)
+	PrivilegedGroups []string `json:"privileged_groups,omitempty"`
+}
+
+// Скомпилированная конфигурация.
+type detector struct {
+	conf     Config
+	xDisplay *regexp.Regexp
+	remoteX  []*regexp.Regexp
+	wayland  []*regexp.Regexp
+	mux      []*regexp.Regexp
+	networks []network
+	ignore   []*regexp.Regexp
+	rules    []rule
+	groups   []group
+	userMap  []userMap
+	active   ActivePolicy
+	priv     []*regexp.Regexp
+}
+
+// Метка сети.
+type network struct {
+	label string
+	ipNet *net.IPNet
+}
+
+// Текущая конфигурация (атомарно заменяемая).
+var curDetector atomic.Pointer[detector]
+
+// Мьютекс изменения конфигурации (чтение без блокировки).
+var confMx sync.Mutex
+
+func init() {
+	d, err := compileConfig(DefaultConfig())
+	if err != nil {
+		panic(err) // default config must be valid
+	}
+	curDetector.Store(d)
+}
+
+// Конфигурация по умолчанию.
+// Default detection config.
+func DefaultConfig() Config {
+	return Config{
+		XDisplay:    "^:[0-9]+$",
+		RemoteX:     append([]string{}, DefaultRemoteX...),
+		Wayland:     []string{WAYLAND_CMD},
+		Multiplexer: []string{MUX_CMD},
+		Privileged:  []string{PRIVILEGED_USER}}
+}
+
+// Скомпилировать конфигурацию (пустые поля заменяются значениями
+// по умолчанию).
+func compileConfig(c Config) (*detector, error) {
+	def := DefaultConfig()
+	if c.XDisplay == "" {
+		c.XDisplay = def.XDisplay
+	}
+	if len(c.RemoteX) == 0 {
+		c.RemoteX = def.RemoteX
+	}
+	if len(c.Wayland) == 0 {
+		c.Wayland = def.Wayland
+	}
+	if len(c.Multiplexer) == 0 {
+		c.Multiplexer = def.Multiplexer
+	}
+	if len(c.Privileged) == 0 {
+		c.Privileged = def.Privileged
+	}
+	if c.VNCPortBase == 0 {
+		c.VNCPortBase = VNC_PORT_BASE
+	}
+
+	d := &detector{conf: c}
+
+	var err error
+	d.xDisplay, err = regexp.Compile(c.XDisplay)
+	if err != nil {
+		return nil, fmt.Errorf("x_display: %w", err)
+	}
+
+	for _, s := range c.RemoteX {
+		re, err := regexp.Compile(s)
+		if err != nil {
+			return nil, fmt.Errorf("remote_x: %w", err)
+		}
+		d.remoteX = append(d.remoteX, re)
+	}
+
+	for _, s := range c.Wayland {
+		re, err := regexp.Compile(s)
+		if err != nil {
+			return nil, fmt.Errorf("wayland: %w", err)
+		}
+		d.wayland = append(d.wayland, re)
+	}
+
+	for _, s := range c.Multiplexer {
+		re, err := regexp.Compile(s)
+		if err != nil {
+			return nil, fmt.Errorf("multiplexer: %w", err)
+		}
+		d.mux = append(d.mux, re)
+	}
+
+	for label, cidrs := range c.Networks {
+		for _, cidr := range cidrs {
+			_, ipNet, err := net.ParseCIDR(cidr)
+			if err != nil {
+				return nil, fmt.Errorf("networks[%s]: %w", label, err)
+			}
+			d.networks = append(d.networks, network{label, ipNet})
+		}
+	}
+
+	// most specific network first
+	sort.SliceStable(d.networks, func(i, j int) bool {
+		bi, _ := d.networks[i].ipNet.Mask.Size()
+		bj, _ := d.networks[j].ipNet.Mask.Size()
+		if bi != bj {
+			return bi > bj
+		}
+		return d.networks[i].label < d.networks[j].label
+	})
+
+	for _, s := range c.IgnoreUsers {
+		re, err := regexp.Compile(s)
+		if err != nil {
+			return nil, fmt.Errorf("ignore_users: %w", err)
+		}
+		d.ignore = append(d.ignore, re)
+	}
+
+	for i, r := range c.Rules {
+		cr, err := compileRule(r)
+		if err != nil {
+			return nil, fmt.Errorf("rules[%d]: %w", i, err)
+		}
+		d.rules = append(d.rules, cr)
+	}
+
+	for name, users := range c.Groups {
+		for _, s := range users {
+			re, err := regexp.Compile(s)
+			if err != nil {
+				return nil, fmt.Errorf("groups[%s]: %w", name, err)
+			}
+			d.groups = append(d.groups, group{name, re})
+		}
+	}
+	sort.SliceStable(d.groups, func(i, j int) bool {
+		return d.groups[i].name < d.groups[j].name
+	})
+
+	for i, m := range c.UserMap {
+		re, err := regexp.Compile(m.Match)
+		if err != nil {
+			return nil, fmt.Errorf("user_map[%d]: %w", i, err)
+		}
+		d.userMap = append(d.userMap, userMap{re, m.Replace})
+	}
+
+	for _, s := range c.Privileged {
+		re, err := regexp.Compile(s)
+		if err != nil {
+			return nil, fmt.Errorf("privileged: %w", err)
+		}
+		d.priv = append(d.priv, re)
+	}
+
+	d.active, err = compileActivePolicy(c)
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Установить новую конфигурацию (потокобезопасно).
+// Set detection config (thread safe).
+func SetConfig(c Config) error {
+	d, err := compileConfig(c)
+	if err != nil {
+		return err
+	}
+	confMx.Lock()
+	curDetector.Store(d)
+	confMx.Unlock()
+	return nil
+}
+
+// Заменить регулярные выражения командной строки лидера сеанса
+// удалённых X пользователей (xrdp, VNC, ...), не изменяя остальную
+// конфигурацию (пустой список - DefaultRemoteX).
+// Set remote X session leader patterns (thread safe).
+func SetRemoteXPatterns(patterns []string) error {
+	confMx.Lock()
+	defer confMx.Unlock()
+
+	c := curDetector.Load().conf
+	c.RemoteX = append([]string{}, patterns...)
+	d, err := compileConfig(c)
+	if err != nil {
+		return err
+	}
+	curDetector.Store(d)
+	return nil
+}
+
+// Получить текущие регулярные выражения удалённых X пользователей.
+// Get remote X session leader patterns.
+func RemoteXPatterns() []string {
+	return append([]string{}, curDetector.Load().conf.RemoteX...)
+}
+
+// Получить текущую конфигурацию.
+// Get current detection config.
+func GetConfig() Config {
+	return curDetector.Load().conf
+}
+
+// Прочитать конфигурацию из JSON файла (без применения).
+// Read detection config from JSON file.
+func ReadConfig(fname string) (Config, error) {
+	data, err := os.ReadFile(fname)
+	if err != nil {
+		return Config{}, err
+	}
+
+	var c Config
+	err = json.Unmarshal(data, &c)
+	if err != nil {
+		return Config{}, fmt.Errorf("%s: %w", fname, err)
+	}
+
+	if _, err = compileConfig(c); err != nil {
+		return Config{}, fmt.Errorf("%s: %w", fname, err)
+	}
+	return c, nil
+}
+
+// Прочитать конфигурацию из JSON файла и применить её.
+// Read detection config from JSON file and set it.
+func LoadConfig(fname string) error {
+	c, err := ReadConfig(fname)
+	if err != nil {
+		return err
+	}
+	return SetConfig(c)
+}
+
+// Получить копию статических меток экземпляра службы (или nil).
+// Get static instance labels (copy).
+func Labels() map[string]string {
+	labels := curDetector.Load().conf.Labels
+	if len(labels) == 0 {
+		return nil
+	}
+	m := make(map[string]string, len(labels))
+	for k, v := range labels {
+		m[k] = v
+	}
+	return m
+}
+
+// Получить метку сети по IP адресу (или "" если сеть не описана).
+// Get network label by IP address.
+func NetworkLabel(ip net.IP) string {
+	if len(ip) == 0 {
+		return ""
+	}
+	for _, n := range curDetector.Load().networks {
+		if n.ipNet.Contains(ip) {
+			return n.label
+		}
+	}
+	return ""
+}
+
+// Проверить, что пользователь должен игнорироваться.
+// Check username matches ignore rules.
+func IsIgnored(name string) bool {
+	for _, re := range curDetector.Load().ignore {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// EOF: "config.go"
@@ -0,0 +1,77 @@
+// File: "config_test.go"
+
+package utmp
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfig(t *testing.T) {
+	defer SetConfig(DefaultConfig())
+	fname := filepath.Join(t.TempDir(), "config.json")
+
+	require.NoError(t, os.WriteFile(fname, []byte(`{"labels": {"v": "1"}}`), 0644))
+	require.NoError(t, LoadConfig(fname))
+	require.Equal(t, map[string]string{"v": "1"}, GetConfig().Labels)
+
+	// bad file keeps previous config
+	require.NoError(t, os.WriteFile(fname, []byte(`{"labels": `), 0644))
+	require.ErrorContains(t, LoadConfig(fname), fname)
+	require.NoError(t, os.WriteFile(fname, []byte(`{"networks": {"x": "bad"}}`), 0644))
+	require.Error(t, LoadConfig(fname))
+	require.ErrorIs(t, LoadConfig(filepath.Join(t.TempDir(), "none.json")), os.ErrNotExist)
+	require.Equal(t, map[string]string{"v": "1"}, GetConfig().Labels)
+}
+
+func TestLoginWatchConfig(t *testing.T) { forBackends(t, testLoginWatchConfig) }
+
+func testLoginWatchConfig(t *testing.T, opts LoginOpts) {
+	defer SetConfig(DefaultConfig())
+	now := int32(time.Now().Unix())
+	l, err := NewLoginWith(testFile(t, testRecord(USER_PROCESS, 0, "tty1", "tty1", "root", "", now)), opts)
+	require.NoError(t, err)
+	defer l.Close()
+
+	dir := t.TempDir()
+	fname := filepath.Join(dir, "config.json")
+	labels := func() string { return GetConfig().Labels["v"] }
+
+	require.NoError(t, os.WriteFile(fname, []byte(`{"labels": {"v": "1"}}`), 0644))
+	require.NoError(t, l.WatchConfig(fname))
+	require.Equal(t, "1", labels())
+
+	// rewritten file is applied
+	require.NoError(t, os.WriteFile(fname, []byte(`{"labels": {"v": "22"}}`), 0644))
+	require.Eventually(t, func() bool { return labels() == "22" }, 5*time.Second, 10*time.Millisecond)
+
+	// bad file: error is reported, previous config is kept
+	require.NoError(t, os.WriteFile(fname, []byte(`{"labels": {`), 0644))
+	select {
+	case err := <-l.Errors():
+		var e *LoginError
+		require.True(t, errors.As(err, &e), err)
+		require.Equal(t, "config", e.Op)
+		require.False(t, e.Fatal)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no config error")
+	}
+	require.Equal(t, "22", labels())
+
+	// atomic replace (rename) is applied
+	tmp := filepath.Join(dir, "config.json.tmp")
+	require.NoError(t, os.WriteFile(tmp, []byte(`{"labels": {"v": "333"}}`), 0644))
+	require.NoError(t, os.Rename(tmp, fname))
+	require.Eventually(t, func() bool { return labels() == "333" }, 5*time.Second, 10*time.Millisecond)
+
+	// missing file can't be watched
+	require.Error(t, l.WatchConfig(filepath.Join(dir, "none.json")))
+	require.Equal(t, "333", labels())
+}
+
+// EOF: "config_test.go"
@@ -11,4 +11,33 @@ const DEFAULT_FILE = "/var/log/wtmp"
 // XRDP programm for detect remote X users.
 const XRDP_CMD = "xrdp-sesman"
 
+// Регулярные выражения командной строки лидера сеанса удалённых
+// X пользователей VNC (TigerVNC, TightVNC, x11vnc), x2go и NoMachine.
+// VNC, x2go and NoMachine session leader command line regexps.
+const (
+	VNC_CMD  = `(^|/)(Xvnc|Xtigervnc|Xtightvnc|vncsession|x0vncserver|x11vnc)( |$)`
+	X2GO_CMD = `(^|/)(x2goruncommand|x2goagent)( |$)`
+	NX_CMD   = `(^|/)(nxagent|nxnode|nxexec)( |$)`
+)
+
+// Регулярные выражения командной строки лидера сеанса удалённых
+// X пользователей по умолчанию (см. SetRemoteXPatterns()).
+// Default remote X session leader patterns.
+var DefaultRemoteX = []string{XRDP_CMD, VNC_CMD, X2GO_CMD, NX_CMD}
+
+// Базовый TCP порт VNC сервера: дисплей ":N" обслуживается портом
+// VNC_PORT_BASE+N.
+// VNC server base TCP port (display :N listens on VNC_PORT_BASE+N).
+const VNC_PORT_BASE = 5900
+
+// Регулярное выражение командной строки лидера сеанса или композитора
+// Wayland для определения локальных графических пользователей Wayland.
+// Wayland session leader/compositor command line regexp.
+const WAYLAND_CMD = `(^|/)(gdm-wayland-session|gnome-shell|kwin_wayland|startplasma-wayland|sway|weston|Hyprland|wayfire|labwc|cage)( |$)`
+
+// Регулярное выражение командной строки терминального мультиплексора
+// (tmux, screen, byobu, zellij), создающего записи utmp для своих окон.
+// Terminal multiplexer command line regexp.
+const MUX_CMD = `(^|/)(tmux|screen|SCREEN|byobu|zellij)(: | |$)`
+
 // EOF: "const.go"
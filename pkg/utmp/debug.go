@@ -6,9 +6,9 @@ package utmp
 import (
 	"encoding/json"
 	"fmt"
-	"log"
 	"net"
 	"os"
+	"time"
 )
 
 // Отладочная печать структуры `LoginInfo` в виде JSON.
@@ -16,7 +16,8 @@ import (
 func (u *LoginInfo) Print(f *os.File) {
 	data, err := json.MarshalIndent(u, "", "  ") // human indent
 	if err != nil {
-		log.Printf("error: suddenly json.Marshal(): %v", err)
+		fmt.Fprintf(f, "error: suddenly json.Marshal(): %v\n", err)
+		return
 	}
 	fmt.Fprintf(f, "%s\n", string(data))
 }
@@ -37,9 +38,12 @@ func (u *User) Print(f *os.File) {
 
 	fmt.Fprint(f, " PID=", u.PID)
 
-	cmd, err := GetCmdline(u.PID)
-	if err == nil {
-		fmt.Fprint(f, " Cmd='", cmd, "'")
+	if u.Stale {
+		fmt.Fprint(f, " Stale")
+	} else if !u.offline {
+		if cmd, err := GetCmdline(u.PID); err == nil {
+			fmt.Fprint(f, " Cmd='", cmd, "'")
+		}
 	}
 
 	if u.Host != "" {
@@ -51,17 +55,45 @@ func (u *User) Print(f *os.File) {
 	if u.SID != 0 {
 		fmt.Fprint(f, " SID=", u.SID)
 	}
+	if u.Elevated {
+		fmt.Fprint(f, " Elevated EUser='", u.EUser, "'")
+	}
+	if u.Multiplexed {
+		fmt.Fprint(f, " Multiplexed")
+	}
+	if u.What != "" {
+		fmt.Fprint(f, " What='", u.What, "'")
+	}
+	if u.Idle > 0 {
+		fmt.Fprint(f, " Idle=", u.Idle.Truncate(time.Second))
+	}
+	if u.SSH != nil {
+		fmt.Fprint(f, " SSH=", u.SSH)
+	}
+	if u.Auth != nil {
+		fmt.Fprint(f, " Auth=", u.Auth.Method)
+		if u.Auth.Fingerprint != "" {
+			fmt.Fprint(f, " Key='", u.Auth.KeyType, " ", u.Auth.Fingerprint, "'")
+		}
+	}
 	fmt.Fprintln(f)
 }
 
 // Отладочная печать структуры `Utmp`.
 // Debug print `Utmp`.
 func (u *Utmp) Print(f *os.File) {
+	u.PrintWith(f, Offline())
+}
+
+// Отладочная печать структуры `Utmp` (offline - без EUID и командной
+// строки процесса из /proc, для файлов с другого узла).
+// Debug print `Utmp` with optional offline analysis.
+func (u *Utmp) PrintWith(f *os.File, offline bool) {
 	t := Time(u.TV)
 	fmt.Fprint(f, t.Format("2006-01-02 15:04:05"))
 
 	Type := int(u.Type)
-	fmt.Fprintf(f, " #%d %10s", Type, TypeString[Type])
+	fmt.Fprintf(f, " #%d %10s", Type, TypeName(Type))
 
 	if u.Type == BOOT_TIME { // reboot
 		if user := Str(u.User[:]); user != "" {
@@ -72,7 +104,25 @@ func (u *Utmp) Print(f *os.File) {
 			fmt.Fprint(f, " Kernel='", host, "'")
 		}
 	} else if u.Type == RUN_LVL { // run level
-		fmt.Fprint(f, " RL=", RunLvl(u.PID))
+		rl, _ := u.RunLevel()
+		fmt.Fprint(f, " RL=", RunLevelStr(rl))
+	} else if u.Type == ACCOUNTING { // accounting (Solaris)
+		r := u.Decode()
+		if r.User != "" {
+			fmt.Fprint(f, " User='", r.User, "'")
+		}
+		if r.Line != "" {
+			fmt.Fprint(f, " TTY='", r.Line, "'")
+		}
+		if r.ID != "" {
+			fmt.Fprint(f, " ID='", r.ID, "'")
+		}
+		if r.PID != 0 {
+			fmt.Fprint(f, " PID=", r.PID)
+		}
+		if r.Host != "" {
+			fmt.Fprint(f, " Host='", r.Host, "'")
+		}
 	} else {
 		user := Str(u.User[:])
 
@@ -88,14 +138,15 @@ func (u *Utmp) Print(f *os.File) {
 			fmt.Fprint(f, " ID='", id, "'")
 		}
 
-		pid := PID(u.PID)
+		pid := u.ProcessID()
 		if pid != 0 {
 			fmt.Fprint(f, " PID=", pid)
 		}
 
-		euid, err := GetEUID(pid)
-		if err == nil {
-			fmt.Fprint(f, " EUID=", euid)
+		if !offline {
+			if euid, err := GetEUID(pid); err == nil {
+				fmt.Fprint(f, " EUID=", euid)
+			}
 		}
 
 		if host := Str(u.Host[:]); host != "" {
@@ -106,9 +157,10 @@ func (u *Utmp) Print(f *os.File) {
 			fmt.Fprint(f, " IP=", ip)
 		}
 
-		cmd, err := GetCmdline(pid)
-		if err == nil {
-			fmt.Fprint(f, " Cmd='", cmd, "'")
+		if !offline {
+			if cmd, err := GetCmdline(pid); err == nil {
+				fmt.Fprint(f, " Cmd='", cmd, "'")
+			}
 		}
 	}
 
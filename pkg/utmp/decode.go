@@ -0,0 +1,95 @@
+// File: "decode.go"
+
+package utmp
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Размер буфера чтения записей (bufio) по умолчанию.
+// Default read buffer size (bufio) for record readers.
+const READ_BUF_SIZE = 256 * RECORD_SIZE
+
+// Ошибка декодирования записи из буфера недостаточного размера.
+// Является ErrCorruptRecord.
+// Buffer is too short to decode Utmp record (is ErrCorruptRecord).
+var ErrShortRecord = fmt.Errorf("%w: short record", ErrCorruptRecord)
+
+// Смещения полей в двоичной записи `utmp` (x86_64, little endian).
+const (
+	offType    = 0
+	offPID     = 4
+	offLine    = 8
+	offID      = offLine + LINESIZE
+	offUser    = offID + 4
+	offHost    = offUser + NAMESIZE
+	offExit    = offHost + HOSTSIZE
+	offSession = offExit + 4
+	offTV      = offSession + 4
+	offAddrV6  = offTV + 8
+	offPad1    = offAddrV6 + 16
+)
+
+// Декодировать запись `utmp` из буфера (не менее RECORD_SIZE байт) без
+// использования рефлексии (в отличие от binary.Read), буфер можно
+// использовать повторно.
+// Decode Utmp record from buf without reflection.
+func DecodeUtmp(buf []byte, u *Utmp) error {
+	if len(buf) < RECORD_SIZE {
+		return ErrShortRecord
+	}
+	buf = buf[:RECORD_SIZE] // bounds check hint
+	le := binary.LittleEndian
+
+	u.Type = int16(le.Uint16(buf[offType:]))
+	copy(u.Pad0_unused[:], buf[offType+2:offPID])
+	copy(u.PID[:], buf[offPID:offLine])
+	copyInt8(u.Line[:], buf[offLine:])
+	copyInt8(u.ID[:], buf[offID:])
+	copyInt8(u.User[:], buf[offUser:])
+	copyInt8(u.Host[:], buf[offHost:])
+	u.Exit.Termination = int16(le.Uint16(buf[offExit:]))
+	u.Exit.Exit = int16(le.Uint16(buf[offExit+2:]))
+	u.Session = int32(le.Uint32(buf[offSession:]))
+	u.TV.Sec = int32(le.Uint32(buf[offTV:]))
+	u.TV.Usec = int32(le.Uint32(buf[offTV+4:]))
+	for i := range u.AddrV6 {
+		u.AddrV6[i] = int32(le.Uint32(buf[offAddrV6+4*i:]))
+	}
+	copyInt8(u.Pad1_unused[:], buf[offPad1:])
+	return nil
+}
+
+// Скопировать байты в массив символов записи.
+func copyInt8(dst []int8, src []byte) {
+	src = src[:len(dst)]
+	for i, b := range src {
+		dst[i] = int8(b)
+	}
+}
+
+// Буферизованное чтение записей `Utmp` (bufio + DecodeUtmp).
+// Buffered Utmp record reader.
+type Reader struct {
+	r   *bufio.Reader
+	buf [RECORD_SIZE]byte
+}
+
+// Создать буферизованный Reader записей.
+// Create buffered record reader.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReaderSize(r, READ_BUF_SIZE)}
+}
+
+// Прочитать одну запись. В конце файла возвращает io.EOF, при неполной
+// записи в конце файла или недопустимом типе записи - ErrCorruptRecord
+// (для неполной записи - также io.ErrUnexpectedEOF).
+// Read one record.
+func (r *Reader) Read(u *Utmp) error {
+	return readRecord(r.r, r.buf[:], u)
+}
+
+// EOF: "decode.go"
@@ -0,0 +1,88 @@
+// File: "decode_test.go"
+
+package utmp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeUtmp(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	buf := make([]byte, RECORD_SIZE)
+	for i := 0; i < 100; i++ {
+		rnd.Read(buf)
+
+		var want, got Utmp
+		require.NoError(t, binary.Read(bytes.NewReader(buf), binary.LittleEndian, &want))
+		require.NoError(t, DecodeUtmp(buf, &got))
+		require.Equal(t, want, got)
+	}
+
+	require.ErrorIs(t, DecodeUtmp(buf[:RECORD_SIZE-1], &Utmp{}), ErrShortRecord)
+}
+
+func TestReader(t *testing.T) {
+	recs := []Utmp{
+		testRecord(BOOT_TIME, 0, "~", "~~", "reboot", "6.1.0", 1000),
+		testRecord(USER_PROCESS, 101, "pts/0", "ts/0", "bob", "10.0.0.5", 1010),
+	}
+	var data bytes.Buffer
+	for i := range recs {
+		require.NoError(t, binary.Write(&data, binary.LittleEndian, &recs[i]))
+	}
+	data.WriteString("tail")
+
+	r := NewReader(&data)
+	var u Utmp
+	for i := range recs {
+		require.NoError(t, r.Read(&u))
+		require.Equal(t, recs[i], u)
+	}
+	require.ErrorIs(t, r.Read(&u), io.ErrUnexpectedEOF)
+}
+
+// Test wtmp image with n records
+func benchData(n int) []byte {
+	var data bytes.Buffer
+	for i := 0; i < n; i++ {
+		u := testRecord(USER_PROCESS, uint32(i), "pts/0", "ts/0", "bob", "10.0.0.5", int32(i))
+		binary.Write(&data, binary.LittleEndian, &u)
+	}
+	return data.Bytes()
+}
+
+func BenchmarkBinaryRead(b *testing.B) {
+	data := benchData(1)
+	b.SetBytes(RECORD_SIZE)
+	var u Utmp
+	for i := 0; i < b.N; i++ {
+		binary.Read(bytes.NewReader(data), binary.LittleEndian, &u)
+	}
+}
+
+func BenchmarkDecodeUtmp(b *testing.B) {
+	data := benchData(1)
+	b.SetBytes(RECORD_SIZE)
+	var u Utmp
+	for i := 0; i < b.N; i++ {
+		DecodeUtmp(data, &u)
+	}
+}
+
+func BenchmarkScanner(b *testing.B) {
+	data := benchData(10000)
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		s := NewScanner(bytes.NewReader(data))
+		for s.Scan() {
+		}
+	}
+}
+
+// EOF: "decode_test.go"
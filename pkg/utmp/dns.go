@@ -0,0 +1,242 @@
+// File: "dns.go"
+
+package utmp
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Параметры определения имён узлов по умолчанию.
+// Host resolver defaults.
+const (
+	DNS_WORKERS = 4                // одновременных запросов DNS
+	DNS_TIMEOUT = 2 * time.Second  // время ожидания запроса
+	DNS_TTL     = 10 * time.Minute // время жизни записи кэша (и неудачи)
+	DNS_QUEUE   = 1024             // запросов в очереди (лишние отбрасываются)
+)
+
+// Опции определения имён узлов.
+// Host resolver options.
+type HostResolverOpts struct {
+	Workers  int           // одновременных запросов (0 - DNS_WORKERS)
+	Timeout  time.Duration // время ожидания запроса (0 - DNS_TIMEOUT)
+	TTL      time.Duration // время жизни кэша (0 - DNS_TTL)
+	Queue    int           // размер очереди (0 - DNS_QUEUE)
+	Resolver *net.Resolver // nil - net.DefaultResolver
+}
+
+// Асинхронное определение имён узлов по IP адресу (обратный DNS) и
+// адресов по имени узла с кэшем. Запросы не блокируют вызывающего:
+// Host()/Addr() возвращают значение из кэша или ставят запрос в очередь,
+// которую обслуживает ограниченное число горутин.
+// Asynchronous reverse DNS resolver with cache.
+type HostResolver struct {
+	opts    HostResolverOpts
+	ctx     context.Context // cancelled by Close()
+	cancel  context.CancelFunc
+	jobs    chan string
+	wg      sync.WaitGroup
+	mx      sync.Mutex
+	cache   map[string]dnsEntry // "ptr:<IP>" or "ip:<host>" -> value
+	pending map[string]bool     // queued or running lookups
+	changed chan struct{}       // closed when lookup is done
+	closed  bool
+
+	// Функции запросов (подменяются в тестах)
+	lookupAddr func(ctx context.Context, addr string) ([]string, error)
+	lookupIP   func(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// Запись кэша.
+type dnsEntry struct {
+	value   string // "" - not resolved
+	expires time.Time
+}
+
+// Создать и запустить определение имён узлов.
+// Create host resolver.
+func NewHostResolver(opts HostResolverOpts) *HostResolver {
+	if opts.Workers <= 0 {
+		opts.Workers = DNS_WORKERS
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DNS_TIMEOUT
+	}
+	if opts.TTL <= 0 {
+		opts.TTL = DNS_TTL
+	}
+	if opts.Queue <= 0 {
+		opts.Queue = DNS_QUEUE
+	}
+	if opts.Resolver == nil {
+		opts.Resolver = net.DefaultResolver
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &HostResolver{
+		opts:       opts,
+		ctx:        ctx,
+		cancel:     cancel,
+		jobs:       make(chan string, opts.Queue),
+		cache:      make(map[string]dnsEntry),
+		pending:    make(map[string]bool),
+		changed:    make(chan struct{}),
+		lookupAddr: opts.Resolver.LookupAddr,
+		lookupIP:   opts.Resolver.LookupIPAddr}
+	r.wg.Add(opts.Workers)
+	for i := 0; i < opts.Workers; i++ {
+		go r.worker()
+	}
+	return r
+}
+
+// Имя узла по IP адресу: значение из кэша (ok=true, "" - имя не
+// найдено) или запрос ставится в очередь (ok=false).
+// Get host name of IP address (non-blocking).
+func (r *HostResolver) Host(ip net.IP) (name string, ok bool) {
+	if len(ip) == 0 || ip.IsUnspecified() {
+		return "", true
+	}
+	return r.get("ptr:" + ip.String())
+}
+
+// IP адрес по имени узла: значение из кэша (ok=true, nil - адрес не
+// найден) или запрос ставится в очередь (ok=false).
+// Get IP address of host name (non-blocking).
+func (r *HostResolver) Addr(host string) (ip net.IP, ok bool) {
+	if host == "" || strings.Contains(host, ":") || net.ParseIP(host) != nil {
+		return nil, true // no name (IP address or X display)
+	}
+	v, ok := r.get("ip:" + host)
+	return net.ParseIP(v), ok
+}
+
+// Значение из кэша или постановка запроса в очередь.
+func (r *HostResolver) get(key string) (string, bool) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	if e, ok := r.cache[key]; ok && time.Now().Before(e.expires) {
+		return e.value, true
+	}
+	if r.closed || r.pending[key] {
+		return "", false
+	}
+	select {
+	case r.jobs <- key:
+		r.pending[key] = true
+	default: // queue is full, retry on next call
+	}
+	return "", false
+}
+
+// Дождаться выполнения запросов в очереди (или отмены ctx).
+// Wait for pending lookups.
+func (r *HostResolver) Wait(ctx context.Context) error {
+	for {
+		r.mx.Lock()
+		if len(r.pending) == 0 {
+			r.mx.Unlock()
+			return nil
+		}
+		ch := r.changed
+		r.mx.Unlock()
+		select {
+		case <-ch:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Горутина запросов.
+func (r *HostResolver) worker() {
+	defer r.wg.Done()
+	for key := range r.jobs {
+		ctx, cancel := context.WithTimeout(r.ctx, r.opts.Timeout)
+		var value string
+		if addr, ok := strings.CutPrefix(key, "ptr:"); ok {
+			if names, err := r.lookupAddr(ctx, addr); err == nil && len(names) != 0 {
+				value = strings.TrimSuffix(names[0], ".")
+			}
+		} else if host, ok := strings.CutPrefix(key, "ip:"); ok {
+			if addrs, err := r.lookupIP(ctx, host); err == nil && len(addrs) != 0 {
+				ip := addrs[0].IP
+				for _, a := range addrs {
+					if a.IP.To4() != nil { // IPv4 is preferred (see User.IP)
+						ip = a.IP
+						break
+					}
+				}
+				value = ip.String()
+			}
+		}
+		cancel()
+
+		r.mx.Lock()
+		if r.ctx.Err() == nil { // not interrupted by Close()
+			r.cache[key] = dnsEntry{value, time.Now().Add(r.opts.TTL)}
+		}
+		delete(r.pending, key)
+		close(r.changed)
+		r.changed = make(chan struct{})
+		r.mx.Unlock()
+	}
+}
+
+// Заполнить недостающие имена узлов (и IP адреса) пользователей по кэшу,
+// отсутствующие в кэше запрашиваются (см. Wait()). Имя узла считается
+// недостающим, если оно пусто или является IP адресом.
+// Fill missing hosts of users (non-blocking).
+func (r *HostResolver) FillUsers(users Users) {
+	for _, u := range users {
+		r.fill(&u.Host, &u.IP)
+	}
+}
+
+// Заполнить недостающие имена узлов (и IP адреса) сеансов (см. FillUsers()).
+// Fill missing hosts of sessions (non-blocking).
+func (r *HostResolver) FillSessions(sessions []Session) {
+	for i := range sessions {
+		r.fill(&sessions[i].Host, &sessions[i].IP)
+	}
+}
+
+// Заполнить имя узла или IP адрес.
+func (r *HostResolver) fill(host *string, ip *net.IP) {
+	if len(*ip) == 0 || ip.IsUnspecified() {
+		if a, ok := r.Addr(*host); ok && a != nil {
+			*ip = a
+		}
+		return
+	}
+	if *host == "" || net.ParseIP(*host) != nil {
+		if name, ok := r.Host(*ip); ok && name != "" {
+			*host = name
+		}
+	}
+}
+
+// Остановить запросы (выполняемые прерываются, ожидающие в очереди
+// отбрасываются).
+// Stop resolver.
+func (r *HostResolver) Close() {
+	r.mx.Lock()
+	if r.closed {
+		r.mx.Unlock()
+		return
+	}
+	r.closed = true
+	r.cancel()
+	close(r.jobs)
+	for len(r.jobs) != 0 { // drop queued lookups
+		key := <-r.jobs
+		delete(r.pending, key)
+	}
+	r.mx.Unlock()
+	r.wg.Wait()
+}
+
+// EOF: "dns.go"
@@ -0,0 +1,96 @@
+// File: "dns_test.go"
+
+package utmp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHostResolver(t *testing.T) {
+	r := NewHostResolver(HostResolverOpts{Workers: 2})
+	defer r.Close()
+
+	var lookups atomic.Int32
+	r.lookupAddr = func(ctx context.Context, addr string) ([]string, error) {
+		lookups.Add(1)
+		if addr == "10.0.0.5" {
+			return []string{"ws5.example.com."}, nil
+		}
+		return nil, errors.New("no such host")
+	}
+	r.lookupIP = func(ctx context.Context, host string) ([]net.IPAddr, error) {
+		lookups.Add(1)
+		if host == "vpn.example.com" {
+			return []net.IPAddr{{IP: net.ParseIP("2001:db8::1")}, {IP: net.ParseIP("192.0.2.7")}}, nil
+		}
+		return nil, errors.New("no such host")
+	}
+
+	sessions := []Session{
+		{User: "alice", Host: "10.0.0.5", IP: net.ParseIP("10.0.0.5")},
+		{User: "bob", IP: net.ParseIP("10.0.0.5")},
+		{User: "carol", Host: "vpn.example.com"},
+		{User: "dave", Host: ":0"},                                   // local X
+		{User: "eve", Host: "10.9.9.9", IP: net.ParseIP("10.9.9.9")}, // no PTR
+	}
+
+	// Not blocked: nothing is cached yet
+	r.FillSessions(sessions)
+	require.Equal(t, "10.0.0.5", sessions[0].Host)
+	require.Empty(t, sessions[2].IP)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, r.Wait(ctx))
+	require.Equal(t, int32(3), lookups.Load()) // one lookup per address/name
+
+	r.FillSessions(sessions)
+	require.Equal(t, "ws5.example.com", sessions[0].Host)
+	require.Equal(t, "ws5.example.com", sessions[1].Host)
+	require.Equal(t, "192.0.2.7", sessions[2].IP.String())
+	require.Equal(t, ":0", sessions[3].Host)
+	require.Empty(t, sessions[3].IP)
+	require.Equal(t, "10.9.9.9", sessions[4].Host)
+
+	// Failures are cached too
+	name, ok := r.Host(net.ParseIP("10.9.9.9"))
+	require.True(t, ok)
+	require.Empty(t, name)
+
+	users := Users{{Name: "alice", IP: net.ParseIP("10.0.0.5")}}
+	r.FillUsers(users)
+	require.Equal(t, "ws5.example.com", users[0].Host)
+	require.Equal(t, int32(3), lookups.Load())
+}
+
+func TestHostResolverClose(t *testing.T) {
+	r := NewHostResolver(HostResolverOpts{Workers: 1, Timeout: time.Minute})
+	r.lookupAddr = func(ctx context.Context, addr string) ([]string, error) {
+		<-ctx.Done() // hanging DNS server
+		return nil, ctx.Err()
+	}
+	for i := 1; i <= 10; i++ {
+		_, ok := r.Host(net.IPv4(10, 0, 0, byte(i)))
+		require.False(t, ok)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, r.Wait(ctx), context.DeadlineExceeded)
+
+	start := time.Now()
+	r.Close() // interrupts lookups
+	require.Less(t, time.Since(start), 5*time.Second)
+	_, ok := r.Host(net.IPv4(10, 0, 0, 1))
+	require.False(t, ok)
+	r.Close()
+}
+
+// EOF: "dns_test.go"
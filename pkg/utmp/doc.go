@@ -26,6 +26,15 @@
 Безопасно можно так же получить информацию о текущих пользователях системы
 с помощью метода GetUsers(), а статистику пользователей и данные о "главном"
 пользователе с помощью метода GetStat().
+
+Стабильность API: модуль github.com/azorg/gousers/v2. Стабильное ядро -
+пакеты `utmp` (структура `Utmp`, чтение и разбор записей, GetUsers()),
+`session` (сеансы wtmp) и `notify` (оповещение о входах/выходах, тип
+`Login`): в пределах v2 их экспортируемые имена не удаляются и не меняют
+сигнатуру. Пакеты `session` и `notify` - псевдонимы типов и функций
+этого пакета, прежние вызовы utmp.GetSessions(), utmp.NewLogin() и т.п.
+сохранены. Остальные пакеты (export, merge, signal, dto) и команда
+gousers могут меняться.
 */
 package utmp
 
@@ -0,0 +1,80 @@
+// File: "elevated.go"
+
+package utmp
+
+import "strconv"
+
+// Определить сеансы, в которых пользователь повысил привилегии через
+// `su -`/`sudo -i` и т.п.: в utmp такие сеансы записаны под исходным
+// именем, поэтому просматриваются процессы-потомки лидера сеанса (/proc),
+// эффективный UID которых отличается от UID вошедшего пользователя
+// (login UID лидера сеанса, при его отсутствии - UID по имени из utmp).
+// Заполняет поля User.Elevated и User.EUser (имя эффективного
+// пользователя, предпочтительно root). Имеет смысл только для utmp
+// текущего узла.
+// Detect su/sudo sessions by EUID and loginuid of processes.
+func (users Users) DetectElevated() {
+	if len(users) == 0 {
+		return
+	}
+	procs, err := GetProcs()
+	if err != nil {
+		return // no /proc
+	}
+
+	for _, u := range users {
+		if u.Stale {
+			continue // PID is reused
+		}
+		uid := -1
+		if p, err := GetProc(u.PID); err == nil && p.LoginUID >= 0 {
+			uid = p.LoginUID
+		} else if lu, err := GetUserInfo(u.Name); err == nil {
+			uid, _ = strconv.Atoi(lu.UID)
+		}
+		if uid < 0 {
+			continue // unknown original user
+		}
+
+		if euid, ok := elevatedUID(procs, u.PID, uid); ok {
+			u.Elevated = true
+			u.EUser = strconv.Itoa(euid)
+			if db := currentUserDB(Offline()); db != nil {
+				if eu, err := db.LookupUID(u.EUser); err == nil {
+					u.EUser = eu.Name
+				}
+			}
+		}
+	}
+}
+
+// Найти среди потомков процесса `pid` процесс с эффективным UID,
+// отличным от `uid` (root предпочтительнее).
+func elevatedUID(procs []Proc, pid uint32, uid int) (euid int, ok bool) {
+	if pid == 0 {
+		return 0, false
+	}
+
+	children := make(map[uint32][]int, len(procs)) // PPID -> indexes
+	for i, p := range procs {
+		children[p.PPID] = append(children[p.PPID], i)
+	}
+
+	queue := []uint32{pid}
+	for len(queue) != 0 {
+		for _, i := range children[queue[0]] {
+			p := &procs[i]
+			queue = append(queue, p.PID)
+			if p.LoginUID >= 0 && p.LoginUID != uid {
+				continue // other login (e.g. nested sshd)
+			}
+			if p.EUID != uid && (!ok || p.EUID == 0) {
+				euid, ok = p.EUID, true
+			}
+		}
+		queue = queue[1:]
+	}
+	return euid, ok
+}
+
+// EOF: "elevated.go"
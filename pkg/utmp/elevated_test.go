@@ -0,0 +1,36 @@
+// File: "elevated_test.go"
+
+package utmp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestElevatedUID(t *testing.T) {
+	procs := []Proc{
+		{PID: 100, PPID: 1, EUID: 0, LoginUID: 1000},      // sshd: alice [priv]
+		{PID: 101, PPID: 100, EUID: 1000, LoginUID: 1000}, // sshd: alice@pts/0
+		{PID: 102, PPID: 101, EUID: 1000, LoginUID: 1000}, // -bash
+		{PID: 103, PPID: 102, EUID: 0, LoginUID: 1000},    // sudo -i
+		{PID: 104, PPID: 103, EUID: 0, LoginUID: 1000},    // -bash (root)
+		{PID: 200, PPID: 1, EUID: 1001, LoginUID: 1001},   // bob login shell
+		{PID: 201, PPID: 200, EUID: 1001, LoginUID: 1001}, // vim
+	}
+
+	euid, ok := elevatedUID(procs, 101, 1000)
+	require.True(t, ok)
+	require.Equal(t, 0, euid)
+
+	_, ok = elevatedUID(procs, 104, 1000) // no children
+	require.False(t, ok)
+
+	_, ok = elevatedUID(procs, 200, 1001)
+	require.False(t, ok)
+
+	_, ok = elevatedUID(procs, 0, 1001)
+	require.False(t, ok)
+}
+
+// EOF: "elevated_test.go"
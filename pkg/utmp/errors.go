@@ -0,0 +1,113 @@
+// File: "errors.go"
+
+package utmp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os/user"
+)
+
+// Ошибки пакета для проверки с помощью errors.Is() (исходная ошибка
+// сохраняется в цепочке и доступна через errors.As()).
+// Error values to check by errors.Is().
+var (
+	// Повреждённая запись: неполная запись в конце файла или
+	// недопустимый тип записи (см. Read()).
+	// Truncated record or record with invalid type.
+	ErrCorruptRecord = errors.New("utmp: corrupt record")
+
+	// Файл усечён во время чтения отображения в память (см. OpenMapped()).
+	// Memory mapped file is truncated while reading.
+	ErrTruncated = errors.New("utmp: file is truncated while mapped")
+
+	// Файл не является utmp/wtmp/btmp файлом этой платформы или сжат
+	// неподдерживаемым способом (см. Open()).
+	// Not a utmp/wtmp/btmp file of this platform.
+	ErrUnsupportedFormat = errors.New("utmp: unsupported file format")
+
+	// Пользователь не найден в базе пользователей (см. GetUserInfo()).
+	// User not found in user database.
+	ErrNoSuchUser = errors.New("utmp: no such user")
+
+	// Нет прав доступа к файлу (то же, что fs.ErrPermission; подробности
+	// и способы получить доступ - см. CheckAccess()).
+	// Permission denied (same as fs.ErrPermission).
+	ErrPermission = fs.ErrPermission
+
+	// Служба Login завершена (см. Login.Close()).
+	// Login is closed.
+	ErrClosed = errors.New("utmp: login monitor is closed")
+
+	// Сеанс не активен (см. Session.Kill()).
+	// Session is not active.
+	ErrNotActive = errors.New("utmp: session is not active")
+
+	// Процесс лидера сеанса завершён или его PID использован повторно
+	// (см. Session.Kill()).
+	// Session leader is gone or its PID is reused.
+	ErrNoLeader = errors.New("utmp: session leader is gone or PID is reused")
+
+	// Пользователь запретил сообщения на терминал (`mesg n`, см.
+	// Users.Message()).
+	// Messages to terminal are disabled.
+	ErrMesgOff = errors.New("utmp: messages are disabled (mesg n)")
+)
+
+// Проверить тип записи.
+func validType(t int16) bool {
+	return t >= EMPTY && t <= ACCOUNTING
+}
+
+// Прочитать и проверить одну запись (buf - буфер размером RECORD_SIZE).
+func readRecord(r io.Reader, buf []byte, u *Utmp) error {
+	_, err := io.ReadFull(r, buf)
+	if err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return fmt.Errorf("%w: %w", ErrCorruptRecord, err)
+		}
+		return err // io.EOF
+	}
+	err = DecodeUtmp(buf, u)
+	if err != nil {
+		return err
+	}
+	if !validType(u.Type) {
+		return fmt.Errorf("%w: bad type %d", ErrCorruptRecord, u.Type)
+	}
+	return nil
+}
+
+// Проверить формат несжатого файла по первой записи.
+func checkFormat(r io.ReaderAt, fname string) error {
+	var buf [RECORD_SIZE]byte
+	n, _ := r.ReadAt(buf[:], 0)
+	if bytes.HasPrefix(buf[:n], MAGIC_BZIP2) {
+		return fmt.Errorf("%w: %s: bzip2 compressed", ErrUnsupportedFormat, fname)
+	}
+	if n < RECORD_SIZE {
+		return nil // empty file or truncated record (see ScanStat.Broken)
+	}
+	var u Utmp
+	if DecodeUtmp(buf[:], &u); !validType(u.Type) {
+		return fmt.Errorf("%w: %s: bad type %d of first record",
+			ErrUnsupportedFormat, fname, u.Type)
+	}
+	return nil
+}
+
+// Добавить ErrNoSuchUser к ошибке "пользователь не найден" базы
+// пользователей.
+func noSuchUser(err error) error {
+	var e1 user.UnknownUserError
+	var e2 user.UnknownUserIdError
+	if errors.As(err, &e1) || errors.As(err, &e2) {
+		return fmt.Errorf("%w: %w", ErrNoSuchUser, err)
+	}
+	return err
+}
+
+// EOF: "errors.go"
@@ -0,0 +1,55 @@
+// File: "errors_test.go"
+
+package utmp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrors(t *testing.T) {
+	var u Utmp
+	rec := testRecord(USER_PROCESS, 1, "pts/0", "ts/0", "alice", "", 1000)
+	buf := &bytes.Buffer{}
+	require.NoError(t, binary.Write(buf, binary.LittleEndian, &rec))
+	data := buf.Bytes()
+
+	// truncated record
+	err := Read(bytes.NewReader(data[:100]), &u)
+	require.ErrorIs(t, err, ErrCorruptRecord)
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	require.ErrorIs(t, NewReader(bytes.NewReader(nil)).Read(&u), io.EOF)
+	require.ErrorIs(t, DecodeUtmp(data[:10], &u), ErrCorruptRecord)
+
+	// invalid type
+	bad := bytes.Clone(data)
+	bad[0] = 0x7F
+	require.ErrorIs(t, Read(bytes.NewReader(bad), &u), ErrCorruptRecord)
+
+	// not a utmp file
+	dir := t.TempDir()
+	fname := filepath.Join(dir, "junk")
+	require.NoError(t, os.WriteFile(fname, bad, 0644))
+	_, err = GetUsers(fname, false)
+	require.ErrorIs(t, err, ErrUnsupportedFormat)
+	_, err = NewLogin(fname, false)
+	require.ErrorIs(t, err, ErrUnsupportedFormat)
+
+	require.NoError(t, os.WriteFile(fname, []byte("BZh91AY&SY"), 0644))
+	_, err = GetSessions(fname, GetUsersOpts{})
+	require.ErrorIs(t, err, ErrUnsupportedFormat)
+
+	// unknown user
+	SetUserDB(&FileUserDB{})
+	defer SetUserDB(nil)
+	_, err = Users{{Name: "alice"}}.GetLoginInfo("alice")
+	require.ErrorIs(t, err, ErrNoSuchUser)
+}
+
+// EOF: "errors_test.go"
@@ -0,0 +1,126 @@
+// File: "fuzz_test.go"
+
+package utmp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Seed corpus: wtmp/utmp files in testdata/wtmp with records as written
+// by Debian 12, RHEL 9 (Wayland, IPv6), Astra Linux (X displays, xrdp),
+// Alpine/busybox (INIT/LOGIN, reused and partial slots, truncated tail)
+// and Ubuntu (clock change, failed login, long host name).
+// Run long fuzzing by e.g. "go test -fuzz=FuzzGetUsers -fuzztime=1h".
+func seedFiles(f *testing.F) [][]byte {
+	fnames, err := filepath.Glob(filepath.Join("testdata", "wtmp", "*"))
+	require.NoError(f, err)
+	require.NotEmpty(f, fnames)
+
+	files := [][]byte{}
+	for _, fname := range fnames {
+		data, err := os.ReadFile(fname)
+		require.NoError(f, err)
+		files = append(files, data)
+	}
+	return files
+}
+
+func FuzzRead(f *testing.F) {
+	for _, data := range seedFiles(f) {
+		for len(data) >= RECORD_SIZE {
+			f.Add(data[:RECORD_SIZE])
+			data = data[RECORD_SIZE:]
+		}
+		f.Add(data) // tail
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var u, ref Utmp
+		err := Read(bytes.NewReader(data), &u)
+		if len(data) < RECORD_SIZE {
+			require.Error(t, err)
+			return
+		}
+
+		// must be equal to reflection based decoder
+		require.NoError(t, binary.Read(bytes.NewReader(data), binary.LittleEndian, &ref))
+		require.Equal(t, ref, u)
+		if ref.Type < EMPTY || ref.Type > ACCOUNTING {
+			require.ErrorIs(t, err, ErrCorruptRecord)
+			return
+		}
+		require.NoError(t, err)
+
+		r := u.Decode()
+		require.LessOrEqual(t, len(r.User), NAMESIZE)
+		require.LessOrEqual(t, len(r.Host), HOSTSIZE)
+	})
+}
+
+func FuzzStr(f *testing.F) {
+	f.Add([]byte("alice"))
+	f.Add([]byte("pts/0\x00garbage"))
+	f.Add([]byte{0xFF, 0x80, 0x00})
+	f.Add(bytes.Repeat([]byte{'x'}, HOSTSIZE+10))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		src := unsafe.Slice((*int8)(unsafe.Pointer(unsafe.SliceData(data))), len(data))
+		s := Str(src)
+		require.Equal(t, StrLen(src), len(s))
+		require.NotContains(t, s, "\x00")
+		require.Equal(t, s, string(StrAppend(nil, src)))
+		require.Equal(t, s, NewInterner(1).Str(src))
+	})
+}
+
+func FuzzIPv4(f *testing.F) {
+	f.Add(int32(0), int32(0), int32(0), int32(0))
+	f.Add(int32(0x0A01A8C0), int32(0), int32(0), int32(0))           // 192.168.1.10
+	f.Add(int32(-0x47F2FEE0), int32(0), int32(0), int32(0x42000000)) // 2001:db8::42
+
+	f.Fuzz(func(t *testing.T, a0, a1, a2, a3 int32) {
+		ip := IPv4([4]int32{a0, a1, a2, a3})
+		if a0 == 0 {
+			require.Empty(t, ip)
+		} else {
+			require.NotNil(t, ip.To4())
+		}
+	})
+}
+
+func FuzzGetUsers(f *testing.F) {
+	for _, data := range seedFiles(f) {
+		f.Add(data)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		fname := filepath.Join(t.TempDir(), "wtmp")
+		require.NoError(t, os.WriteFile(fname, data, 0644))
+		records := len(data) / RECORD_SIZE
+
+		users, err := GetUsersWith(fname, GetUsersOpts{Offline: true})
+		if errors.Is(err, ErrUnsupportedFormat) {
+			return // not a utmp file
+		}
+		require.NoError(t, err)
+		require.LessOrEqual(t, len(users), records)
+
+		sessions, err := GetSessions(fname, GetUsersOpts{Offline: true})
+		require.NoError(t, err)
+		require.LessOrEqual(t, len(sessions), records)
+
+		st, err := GetScanStat(fname)
+		require.NoError(t, err)
+		require.Equal(t, records, st.Records)
+	})
+}
+
+// EOF: "fuzz_test.go"
@@ -0,0 +1,102 @@
+// File: "groups.go"
+
+package utmp
+
+import (
+	"regexp"
+	"sort"
+	"time"
+)
+
+// Группа пользователей из конфигурации.
+type group struct {
+	name string
+	user *regexp.Regexp
+}
+
+// Статистика сеансов группы пользователей.
+// Sessions and connect time of group.
+type GroupStat struct {
+	Group    string        // Group name
+	Users    int           // Number of distinct users
+	Sessions int           // Number of sessions
+	Time     time.Duration // Total connect time
+}
+
+// Получить группу пользователя для статистики: группа из конфигурации
+// (см. Config.Groups) или имя основной группы пользователя ("" если
+// пользователь не найден).
+// Get group of user (configured or primary).
+func GroupOf(name string) string {
+	return groupOf(name, Offline())
+}
+
+// Получить группу пользователя (offline - только группы из конфигурации).
+func groupOf(name string, offline bool) string {
+	for _, g := range curDetector.Load().groups {
+		if g.user.MatchString(name) {
+			return g.name
+		}
+	}
+	db := currentUserDB(offline)
+	if db == nil {
+		return ""
+	}
+
+	u, err := cachedUserInfo(name)
+	if err != nil {
+		return ""
+	}
+	defer perfUser(time.Now())
+	g, err := db.LookupGID(u.GID)
+	if err != nil {
+		return u.GID
+	}
+	return g
+}
+
+// Сгруппировать сеансы по группам пользователей (число пользователей,
+// сеансов и суммарное время подключения; активные сеансы - до `now`).
+// Результат сортирован по убыванию времени подключения.
+// Aggregate sessions by groups.
+func GroupReport(sessions []Session, now time.Time) []GroupStat {
+	groupOf := make(map[string]string) // user -> group cache
+	stats := make(map[string]*GroupStat)
+	type groupUser struct{ group, user string }
+	users := make(map[groupUser]struct{})
+
+	for i := range sessions {
+		s := &sessions[i]
+		g, ok := groupOf[s.User]
+		if !ok {
+			g = GroupOf(s.User)
+			groupOf[s.User] = g
+		}
+
+		st := stats[g]
+		if st == nil {
+			st = &GroupStat{Group: g}
+			stats[g] = st
+		}
+		st.Sessions++
+		st.Time += s.Duration(now)
+		if _, ok := users[groupUser{g, s.User}]; !ok {
+			users[groupUser{g, s.User}] = struct{}{}
+			st.Users++
+		}
+	}
+
+	report := make([]GroupStat, 0, len(stats))
+	for _, st := range stats {
+		report = append(report, *st)
+	}
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].Time != report[j].Time {
+			return report[i].Time > report[j].Time
+		}
+		return report[i].Group < report[j].Group
+	})
+	return report
+}
+
+// EOF: "groups.go"
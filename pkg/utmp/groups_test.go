@@ -0,0 +1,35 @@
+// File: "groups_test.go"
+
+package utmp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupReport(t *testing.T) {
+	require.NoError(t, SetConfig(Config{Groups: map[string][]string{
+		"ml":  {"^alice$", "^bob$"},
+		"web": {"^carol$"}}}))
+	defer SetConfig(DefaultConfig())
+
+	require.Equal(t, "ml", GroupOf("bob"))
+	require.Equal(t, "", GroupOf("no-such-user-xyz"))
+
+	at := func(sec int64) time.Time { return time.Unix(sec, 0) }
+	sessions := []Session{
+		{User: "alice", Login: at(0), Logout: at(100), End: SESSION_LOGOUT},
+		{User: "bob", Login: at(50), Logout: at(80), End: SESSION_LOGOUT},
+		{User: "alice", Login: at(200), End: SESSION_ACTIVE},
+		{User: "carol", Login: at(0), Logout: at(1000), End: SESSION_CRASH},
+	}
+	report := GroupReport(sessions, at(300))
+	require.Equal(t, []GroupStat{
+		{Group: "web", Users: 1, Sessions: 1, Time: 1000 * time.Second},
+		{Group: "ml", Users: 2, Sessions: 3, Time: 230 * time.Second},
+	}, report)
+}
+
+// EOF: "groups_test.go"
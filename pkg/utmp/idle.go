@@ -0,0 +1,93 @@
+// File: "idle.go"
+
+package utmp
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Определить время простоя сеансов по времени последнего доступа
+// к устройствам терминалов (см. GetTTYIdle()). Заполняет User.Idle
+// (-1 - неизвестно: нет устройства терминала, например X сеанс ":0",
+// или запись устарела). Имеет смысл только для utmp текущего узла.
+// Detect idle time of sessions by TTY access time.
+func (users Users) DetectIdle() {
+	if Offline() {
+		return
+	}
+	for _, u := range users {
+		u.Idle = -1
+		if u.Stale || u.offline || u.TTY == "" || strings.HasPrefix(u.TTY, ":") {
+			continue
+		}
+		if d, err := GetTTYIdle(u.TTY); err == nil {
+			u.Idle = d
+		}
+	}
+}
+
+// Получить процесс переднего плана терминала (например "pts/3"): лидер
+// группы процессов переднего плана (или последний запущенный процесс
+// группы), как в колонке WHAT утилиты `w`. Возвращает PID и командную
+// строку процесса.
+// Get foreground process of TTY.
+func GetTTYForeground(tty string) (pid uint32, cmd string, err error) {
+	if Offline() {
+		return 0, "", ErrOffline
+	}
+	dev := filepath.Join("/dev", tty)
+	var st syscall.Stat_t
+	if err = syscall.Stat(dev, &st); err != nil {
+		return 0, "", &os.PathError{Op: "stat", Path: dev, Err: err}
+	}
+	procs, err := procStats()
+	if err != nil {
+		return 0, "", err
+	}
+
+	var fg *procStat
+	for i := range procs {
+		p := &procs[i]
+		if p.tty != st.Rdev || p.pgrp != p.tpgid {
+			continue // other terminal or background
+		}
+		if p.pid == p.tpgid {
+			fg = p // group leader
+			break
+		}
+		if fg == nil || p.start > fg.start {
+			fg = p
+		}
+	}
+	if fg == nil {
+		return 0, "", fmt.Errorf("no foreground process on %s", dev)
+	}
+	pid = uint32(fg.pid)
+	cmd, err = GetCmdline(pid)
+	return pid, cmd, err
+}
+
+// Время простоя пользователя в виде, как в утилите `w`: "12.00s",
+// "5:03" (минуты:секунды), "2:05m" (часы:минуты), "3days"
+// (отрицательное время - "?").
+// Format idle time like `w`.
+func FormatIdle(d time.Duration) string {
+	switch {
+	case d < 0:
+		return "?"
+	case d < time.Minute:
+		return fmt.Sprintf("%.2fs", d.Seconds())
+	case d < time.Hour:
+		return fmt.Sprintf("%d:%02d", int(d.Minutes()), int(d.Seconds())%60)
+	case d < 48*time.Hour:
+		return fmt.Sprintf("%d:%02dm", int(d.Hours()), int(d.Minutes())%60)
+	}
+	return fmt.Sprintf("%ddays", int(d.Hours())/24)
+}
+
+// EOF: "idle.go"
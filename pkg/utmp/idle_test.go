@@ -0,0 +1,42 @@
+// File: "idle_test.go"
+
+package utmp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatIdle(t *testing.T) {
+	require.Equal(t, "?", FormatIdle(-1))
+	require.Equal(t, "0.00s", FormatIdle(0))
+	require.Equal(t, "12.50s", FormatIdle(12500*time.Millisecond))
+	require.Equal(t, "5:03", FormatIdle(5*time.Minute+3*time.Second))
+	require.Equal(t, "2:05m", FormatIdle(2*time.Hour+5*time.Minute+59*time.Second))
+	require.Equal(t, "3days", FormatIdle(80*time.Hour))
+}
+
+func TestDetectIdle(t *testing.T) {
+	users := Users{
+		{Name: "alice", TTY: ":0"},
+		{Name: "bob", TTY: "pts/4242"}, // no such device
+		{Name: "carol", TTY: "null"},   // /dev/null
+	}
+	users.DetectIdle()
+	require.Equal(t, time.Duration(-1), users[0].Idle)
+	require.Equal(t, time.Duration(-1), users[1].Idle)
+	require.GreaterOrEqual(t, users[2].Idle, time.Duration(0))
+
+	_, _, err := GetTTYForeground("pts/4242")
+	require.Error(t, err)
+
+	SetOffline(true)
+	defer SetOffline(false)
+	users[1].Idle = 0
+	users.DetectIdle()
+	require.Equal(t, time.Duration(0), users[1].Idle) // not touched
+}
+
+// EOF: "idle_test.go"
@@ -0,0 +1,128 @@
+// File: "incremental.go"
+
+package utmp
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+// Инкрементальное чтение журнала входов (wtmp/btmp): файл остаётся
+// открытым, при очередном чтении разбираются только дописанные записи.
+// Полный разбор выполняется заново, если файл заменён (другой inode),
+// усечён, изменён без изменения размера (перезапись слотов utmp) или
+// изменена последняя прочитанная запись.
+// Incremental reader of append-only login log.
+type usersReader struct {
+	fname string
+	opts  GetUsersOpts
+	f     *os.File    // opened file (nil - full parse on next read)
+	fi    os.FileInfo // file info at open
+	s     *Scanner    // scanner of opened file
+	b     *userBase   // logged users
+	off   int64       // offset after last complete record
+	size  int64       // file size at last read
+	mod   time.Time   // modification time at last read
+	tail  Utmp        // last complete record
+	full  int         // number of full parses
+	raw   bool        // keep raw records (see userBase.raw)
+}
+
+// Создать инкрементальное чтение файла fname.
+func newUsersReader(fname string, opts GetUsersOpts) *usersReader {
+	return &usersReader{fname: fname, opts: opts}
+}
+
+// Прочитать дописанные записи и получить список вошедших пользователей.
+func (r *usersReader) read(ctx context.Context) (Users, error) {
+	fi, err := os.Stat(r.fname)
+	if err != nil {
+		return Users{}, err
+	}
+	if !r.appended(fi) {
+		if err = r.open(); err != nil {
+			return Users{}, err
+		}
+	}
+
+	r.s.SetContext(ctx)
+	for r.s.Scan() {
+		u := r.s.Record()
+		r.tail = *u
+		r.off += RECORD_SIZE
+		if u.IsEmpty() || u.IsPartial() {
+			continue // skip EMPTY and partially zeroed slots
+		}
+		r.b.add(u)
+	}
+	if err = r.s.Err(); err != nil {
+		r.Close() // full parse on next read
+		return Users{}, err
+	}
+	r.size, r.mod = fi.Size(), fi.ModTime()
+	return r.b.users(), nil
+}
+
+// Проверить, что с момента последнего чтения в файл только дописывались
+// записи.
+func (r *usersReader) appended(fi os.FileInfo) bool {
+	if r.f == nil || !os.SameFile(r.fi, fi) {
+		return false // not opened yet or replaced
+	}
+	if fi.Size() < r.off {
+		return false // truncated
+	}
+	if fi.Size() == r.size && !fi.ModTime().Equal(r.mod) {
+		return false // rewritten in place
+	}
+	if r.off == 0 {
+		return true
+	}
+
+	var buf [RECORD_SIZE]byte
+	var u Utmp
+	_, err := r.f.ReadAt(buf[:], r.off-RECORD_SIZE)
+	if err != nil || DecodeUtmp(buf[:], &u) != nil {
+		return false
+	}
+	return u == r.tail // last record unchanged
+}
+
+// Открыть файл заново для полного разбора.
+func (r *usersReader) open() error {
+	r.Close()
+	f, err := os.Open(r.fname)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	var boot time.Time
+	if r.opts.CurrentBoot {
+		boot, _ = GetBootTime() // zero if unknown
+	}
+	r.f, r.fi = f, fi
+	r.s = r.opts.NewScanner(f)
+	r.b = newUserBase(r.opts, boot)
+	r.b.raw = r.raw
+	r.off = 0
+	r.full++
+	return nil
+}
+
+// Закрыть файл (следующее чтение - полный разбор).
+func (r *usersReader) Close() error {
+	if r.f == nil {
+		return nil
+	}
+	err := r.f.Close()
+	r.f, r.s = nil, nil
+	return err
+}
+
+// EOF: "incremental.go"
@@ -0,0 +1,105 @@
+// File: "incremental_test.go"
+
+package utmp
+
+import (
+	"context"
+	"encoding/binary"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsersReader(t *testing.T) {
+	ctx := context.Background()
+	fname := testFile(t,
+		testRecord(BOOT_TIME, 0, "~", "~~", "reboot", "6.1.0", 1000),
+		testRecord(USER_PROCESS, 101, "tty1", "tty1", "alice", "", 1010),
+		testRecord(USER_PROCESS, 102, "pts/0", "ts/0", "bob", "10.0.0.5", 1020))
+	appendRecs := func(recs ...Utmp) {
+		f, err := os.OpenFile(fname, os.O_WRONLY|os.O_APPEND, 0)
+		require.NoError(t, err)
+		defer f.Close()
+		for i := range recs {
+			require.NoError(t, binary.Write(f, binary.LittleEndian, &recs[i]))
+		}
+	}
+
+	r := newUsersReader(fname, GetUsersOpts{Offline: true})
+	defer r.Close()
+	users, err := r.read(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []string{"alice", "bob"}, users.Names())
+	require.Equal(t, 1, r.full)
+
+	// appended records only
+	appendRecs(
+		testRecord(DEAD_PROCESS, 102, "pts/0", "ts/0", "", "", 1100),
+		testRecord(USER_PROCESS, 103, "pts/1", "ts/1", "carol", "10.0.0.6", 1200))
+	users, err = r.read(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []string{"alice", "carol"}, users.Names())
+	require.Equal(t, 1, r.full)
+
+	// boot marker
+	appendRecs(testRecord(BOOT_TIME, 0, "~", "~~", "reboot", "6.1.0", 2000))
+	users, err = r.read(ctx)
+	require.NoError(t, err)
+	require.Empty(t, users)
+	require.Equal(t, 1, r.full)
+
+	// truncation => full parse
+	require.NoError(t, os.Truncate(fname, 2*RECORD_SIZE))
+	users, err = r.read(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []string{"alice"}, users.Names())
+	require.Equal(t, 2, r.full)
+
+	// rewritten last record => full parse
+	f, err := os.OpenFile(fname, os.O_WRONLY, 0)
+	require.NoError(t, err)
+	rec := testRecord(USER_PROCESS, 105, "tty2", "tty2", "dave", "", 1030)
+	_, err = f.Seek(RECORD_SIZE, 0)
+	require.NoError(t, err)
+	require.NoError(t, binary.Write(f, binary.LittleEndian, &rec))
+	require.NoError(t, f.Close())
+	appendRecs(testRecord(USER_PROCESS, 106, "pts/2", "ts/2", "erin", "10.0.0.7", 1300))
+	users, err = r.read(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []string{"dave", "erin"}, users.Names())
+	require.Equal(t, 3, r.full)
+
+	// same result as full parse
+	want, err := GetUsersWith(fname, GetUsersOpts{Offline: true})
+	require.NoError(t, err)
+	require.Equal(t, want, users)
+}
+
+func TestLoginIncremental(t *testing.T) {
+	now := int32(time.Now().Unix())
+	fname := testFile(t,
+		testRecord(USER_PROCESS, 0, "tty1", "tty1", "root", "", now))
+	l, err := NewLoginWith(fname, LoginOpts{Incremental: true})
+	require.NoError(t, err)
+	defer l.Close()
+	require.Equal(t, 1, l.GetStat().Total)
+
+	f, err := os.OpenFile(fname, os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	u := testRecord(DEAD_PROCESS, 0, "tty1", "tty1", "", "", now+1)
+	require.NoError(t, binary.Write(f, binary.LittleEndian, &u))
+	require.NoError(t, f.Close())
+
+	select {
+	case evt := <-l.C():
+		require.Equal(t, []UserTTY{{"root", "tty1"}}, evt.Logout)
+		require.Empty(t, evt.Users)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no logout event")
+	}
+	require.Equal(t, 1, l.reader.full)
+}
+
+// EOF: "incremental_test.go"
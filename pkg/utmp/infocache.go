@@ -0,0 +1,92 @@
+// File: "infocache.go"
+
+package utmp
+
+import (
+	"sync"
+	"time"
+)
+
+// Кэш информации о пользователях (результатов запросов к базе
+// пользователей, т.е. NSS/SSSD/LDAP), общий для Users.GetLoginInfo()
+// и Login: каждое изменение utmp не приводит к повторным запросам.
+// User info cache with TTL.
+type userInfoCache struct {
+	mx  sync.Mutex
+	ttl time.Duration // время жизни записи (0 - кэш отключен)
+	m   map[string]infoEntry
+}
+
+// Запись кэша.
+type infoEntry struct {
+	info   UserInfo
+	expire time.Time
+}
+
+// Размер кэша, при превышении которого удаляются устаревшие записи.
+const infoCachePrune = 1024
+
+var infoCache = &userInfoCache{
+	ttl: USER_INFO_TTL,
+	m:   make(map[string]infoEntry)}
+
+// Задать время жизни записи кэша информации о пользователях
+// (0 - не кэшировать, по умолчанию USER_INFO_TTL). Кэш очищается.
+// Set user info cache TTL (0 - disable cache).
+func SetUserInfoTTL(ttl time.Duration) {
+	c := infoCache
+	c.mx.Lock()
+	c.ttl = ttl
+	clear(c.m)
+	c.mx.Unlock()
+}
+
+// Сбросить кэш информации о пользователях (всех или заданных),
+// например после изменения /etc/passwd или членства в группах.
+// Invalidate cached user info (all users if no names given).
+func InvalidateUserInfo(names ...string) {
+	c := infoCache
+	c.mx.Lock()
+	if len(names) == 0 {
+		clear(c.m)
+	}
+	for _, name := range names {
+		delete(c.m, name)
+	}
+	c.mx.Unlock()
+}
+
+// Получить информацию о пользователе через кэш (ошибки не кэшируются).
+func cachedUserInfo(name string) (*UserInfo, error) {
+	c := infoCache
+	now := time.Now()
+	c.mx.Lock()
+	e, ok := c.m[name]
+	ttl := c.ttl
+	c.mx.Unlock()
+	if ok && now.Before(e.expire) {
+		perfUserCache(true)
+		info := e.info
+		return &info, nil
+	}
+	perfUserCache(false)
+
+	info, err := GetUserInfo(name)
+	if err != nil || ttl <= 0 {
+		return info, err
+	}
+
+	c.mx.Lock()
+	if len(c.m) >= infoCachePrune {
+		for k, e := range c.m {
+			if !now.Before(e.expire) {
+				delete(c.m, k)
+			}
+		}
+	}
+	c.m[name] = infoEntry{info: *info, expire: now.Add(ttl)}
+	c.mx.Unlock()
+	return info, nil
+}
+
+// EOF: "infocache.go"
@@ -0,0 +1,68 @@
+// File: "kill.go"
+
+package utmp
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// Опции завершения сеанса.
+// Session termination options.
+type KillOpts struct {
+	Group  bool // послать сигнал и группе процессов лидера сеанса
+	DryRun bool // только проверить сеанс и вернуть цели сигнала
+}
+
+// Послать сигнал лидеру активного сеанса (например syscall.SIGHUP или
+// syscall.SIGTERM).
+// Signal session leader.
+func (s *Session) Kill(sig syscall.Signal) error {
+	_, err := s.KillWith(sig, KillOpts{})
+	return err
+}
+
+// Послать сигнал лидеру активного сеанса и, если opts.Group, группе его
+// процессов. Перед отправкой проверяется, что процесс лидера существует
+// и запущен не позже записи о входе (PID не использован повторно, см.
+// User.CheckStale()). Возвращает цели сигнала в виде аргументов kill(2):
+// PID лидера и -PGID группы (при opts.DryRun сигнал не посылается).
+// Signal session leader with options.
+func (s *Session) KillWith(sig syscall.Signal, opts KillOpts) (targets []int, err error) {
+	if Offline() {
+		return nil, ErrOffline
+	}
+	if s.End != SESSION_ACTIVE {
+		return nil, ErrNotActive
+	}
+	if s.PID <= 1 {
+		return nil, fmt.Errorf("bad session leader PID %d", s.PID)
+	}
+	u := User{Name: s.User, PID: s.PID, Time: s.Login}
+	if _, err := GetProcStart(s.PID); err != nil || u.CheckStale() {
+		return nil, ErrNoLeader
+	}
+
+	pid := int(s.PID)
+	targets = []int{pid}
+	if opts.Group {
+		pgid, err := syscall.Getpgid(pid)
+		if err != nil {
+			return nil, fmt.Errorf("getpgid(%d): %w", pid, err)
+		}
+		if pgid > 1 && pgid != syscall.Getpgrp() { // not own group
+			targets = append(targets, -pgid)
+		}
+	}
+	if opts.DryRun {
+		return targets, nil
+	}
+	for _, t := range targets {
+		if err := syscall.Kill(t, sig); err != nil && err != syscall.ESRCH {
+			return targets, fmt.Errorf("kill(%d, %s): %w", t, sig, err)
+		}
+	}
+	return targets, nil
+}
+
+// EOF: "kill.go"
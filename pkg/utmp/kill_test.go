@@ -0,0 +1,41 @@
+// File: "kill_test.go"
+
+package utmp
+
+import (
+	"os/exec"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionKill(t *testing.T) {
+	login := time.Now()
+	cmd := exec.Command("sleep", "30")
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	require.NoError(t, cmd.Start())
+	defer cmd.Process.Kill()
+	pid := cmd.Process.Pid
+
+	s := Session{User: "alice", TTY: "pts/3", PID: uint32(pid), Login: login}
+	targets, err := s.KillWith(syscall.SIGTERM, KillOpts{Group: true, DryRun: true})
+	require.NoError(t, err)
+	require.Equal(t, []int{pid, -pid}, targets)
+	require.NoError(t, syscall.Kill(pid, 0)) // still running
+
+	old := s
+	old.Login = login.Add(-time.Hour) // PID is reused
+	require.ErrorIs(t, old.Kill(syscall.SIGTERM), ErrNoLeader)
+	old.End = SESSION_LOGOUT
+	require.ErrorIs(t, old.Kill(syscall.SIGTERM), ErrNotActive)
+
+	require.NoError(t, s.Kill(syscall.SIGTERM))
+	err = cmd.Wait()
+	require.Error(t, err)
+	require.Equal(t, syscall.SIGTERM, err.(*exec.ExitError).Sys().(syscall.WaitStatus).Signal())
+	require.ErrorIs(t, s.Kill(syscall.SIGTERM), ErrNoLeader)
+}
+
+// EOF: "kill_test.go"
@@ -3,23 +3,34 @@
 package utmp
 
 import (
-	"log"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
 	"os"
+	"time"
 
 	"github.com/fsnotify/fsnotify"
 )
 
-// Поиск вновь вошедших, только что вышедших пользовтаелей.
+// Типы входа по терминалам пользователей.
+type ttyTypes map[UserTTY]LoginType
+
+// Поиск вновь вошедших, только что вышедших пользовтаелей
+// (types - типы входа вошедших и вышедших).
 // Find login/logout users
-func (l *Login) findLoginLogout() (login, logout []UserTTY) {
+func (l *Login) findLoginLogout() (login, logout []UserTTY, types ttyTypes) {
 	m := make(map[UserTTY]struct{})
+	types = make(ttyTypes)
 
 	// Найти вновь вошедших (find login)
 	for _, u := range l.users {
 		ut := UserTTY{u.Name, u.TTY}
 		if _, ok := l.logged[ut]; !ok {
 			l.logged[ut] = struct{}{}
+			l.types[ut] = u.LoginType()
 			login = append(login, ut)
+			types[ut] = l.types[ut]
 		}
 		m[ut] = struct{}{}
 	}
@@ -29,51 +40,281 @@ func (l *Login) findLoginLogout() (login, logout []UserTTY) {
 		if _, ok := m[ut]; !ok {
 			delete(l.logged, ut)
 			logout = append(logout, ut)
+			types[ut] = l.types[ut]
+			delete(l.types, ut)
+		}
+	}
+	return login, logout, types
+}
+
+// Разобранный utmp файл (передаётся на стадию обогащения).
+type parsedUtmp struct {
+	gen     uint64     // номер разбора
+	modTime time.Time  // время обновления utmp файла
+	login   []UserTTY  // вновь вошедшие
+	logout  []UserTTY  // только что вышедшие
+	types   ttyTypes   // типы входа вошедших и вышедших
+	users   Users      // пользователи в системе
+	resync  bool       // полная повторная синхронизация
+	change  FileChange // изменение utmp файла
+	recv    time.Time  // время обнаружения изменения
+	records []Utmp     // исходные записи входов и выходов
+
+	hist *LoginEvent // историческое событие (см. Replay()) или nil
+}
+
+// Исходные записи входов (USER_PROCESS) и выходов (DEAD_PROCESS),
+// вызвавших событие: запись выхода учитывается, только если она не
+// раньше записи входа вышедшего пользователя (слот utmp мог быть занят
+// другим сеансом без записи выхода).
+func rawRecords(login, logout []UserTTY, users, prev Users, dead map[UserTTY]Utmp) []Utmp {
+	var records []Utmp
+	for _, ut := range login {
+		for _, u := range users {
+			if u.raw != nil && u.Name == ut.User && u.TTY == ut.TTY {
+				records = append(records, *u.raw)
+				break
+			}
 		}
 	}
-	return login, logout
+	for _, ut := range logout {
+		rec, ok := dead[ut]
+		if !ok {
+			continue
+		}
+		for _, u := range prev {
+			if u.Name == ut.User && u.TTY == ut.TTY && !Time(rec.TV).Before(u.Time) {
+				records = append(records, rec)
+				break
+			}
+		}
+	}
+	return records
 }
 
-// Прочитать utmp файл, сохранить/распаковать данные, послать событие.
-// Read utmp file, save/parse data, send event.
-func (l *Login) readUtmp() {
+// Опции чтения utmp файла (см. LoginOpts).
+func (l *Login) usersOpts() GetUsersOpts {
+	return GetUsersOpts{UseEUID: l.useEUID, Stale: l.stale}
+}
+
+// Прочитать utmp файл, определить вошедших/вышедших пользователей
+// и передать на стадию обогащения (стадия разбора).
+// Возвращает ошибку получения состояния файла (например, файл временно
+// отсутствует при ротации).
+// Read utmp file, find login/logout users and queue for enrichment.
+func (l *Login) readUtmp() error {
+	recv := time.Now()
+
 	// Получить время обновления utmp файла
 	Stat, err := os.Stat(l.fname)
 	if err != nil {
-		log.Printf("error: %v", err)
-		return
+		l.report("stat", err, false)
+		return err
 	}
 	modTime := Stat.ModTime()
 
+	// Файл создан заново, заменён (атомарная замена, ротация) или усечён
+	change := StatChange(l.fi, Stat)
+	resync := l.resync || l.fi != nil && change.Full()
+	if resync && l.reader != nil {
+		l.reader.Close() // full parse
+	}
+	l.resync, l.fi = false, Stat
+
 	// Прочитать (обновленный) utmp файл
-	l.users, err = GetUsers(l.fname, l.useEUID)
+	prev := l.users
+	var dead map[UserTTY]Utmp // logout records (see LoginOpts.RawRecords)
+	if l.reader != nil {
+		l.users, err = l.reader.read(context.Background())
+		if err == nil {
+			dead = l.reader.b.dead
+		}
+	} else if l.raw {
+		var b *userBase
+		b, err = readUserBase(context.Background(), l.fname,
+			l.usersOpts(), true)
+		if err == nil {
+			l.users, dead = b.users(), b.dead
+		}
+	} else {
+		l.users, err = GetUsersWith(l.fname, l.usersOpts())
+	}
 	if err != nil {
-		log.Printf("error: %v", err)
-		return
+		l.report("read", err, false)
+		return err
 	}
 
 	// Определить кто вошел/кто вышел (find login/logout users)
-	login, logout := l.findLoginLogout()
+	login, logout, types := l.findLoginLogout()
+	var records []Utmp
+	if l.raw {
+		records = rawRecords(login, logout, l.users, prev, dead)
+	}
+
+	p := parsedUtmp{
+		gen:     l.gen.Add(1),
+		modTime: modTime,
+		login:   login,
+		logout:  logout,
+		types:   types,
+		users:   l.users,
+		resync:  resync,
+		change:  change,
+		recv:    recv,
+
+		records: records}
+	select {
+	case l.parsed <- p:
+	case <-l.done:
+	}
+	return nil
+}
+
+// Горутина обогащения событий информацией о пользователях.
+// Enrichment goroutine.
+func enricherFn(l *Login) {
+	defer l.wg.Done()
+	defer close(l.ready)
+
+	for p := range l.parsed {
+		var evt LoginEvent
+		if p.hist != nil {
+			evt = *p.hist // already complete
+		} else {
+			evt = l.enrich(p)
+		}
+		l.seq++
+		evt.Seq = l.seq
+		select {
+		case l.ready <- evt:
+		case <-l.done:
+			return
+		}
+	}
+}
+
+// Горутина рассылки событий подписчикам (медленный подписчик с
+// OVERFLOW_BLOCK задерживает рассылку остальным после заполнения его
+// очереди, см. Subscribe()).
+// Dispatch goroutine.
+func dispatcherFn(l *Login) {
+	defer l.wg.Done()
+
+	for evt := range l.ready {
+		for _, sub := range l.subscribers() {
+			evt, ok := sub.apply(evt)
+			if !ok {
+				continue // filtered out
+			}
+			select {
+			case sub.in <- evt:
+			case <-sub.quit: // unsubscribed
+			case <-l.done:
+				return
+			}
+		}
+	}
+}
+
+// Получить полную информацию о пользователях (не дольше бюджета
+// времени), сохранить в памяти, сформировать событие.
+// Enrich parsed utmp with user information.
+func (l *Login) enrich(p parsedUtmp) LoginEvent {
+	partial := true
+	if l.busy.CompareAndSwap(false, true) { // no slow lookup in progress
+		done := make(chan struct{})
+		go func() {
+			defer l.busy.Store(false)
+			l.lookup(p.users)
+			close(done)
+			if l.gen.Load() == p.gen {
+				l.store(p.users) // late result of last utmp
+			}
+		}()
+
+		var timeout <-chan time.Time
+		if budget := time.Duration(l.budget.Load()); budget > 0 {
+			timer := time.NewTimer(budget)
+			defer timer.Stop()
+			timeout = timer.C
+		}
+		select {
+		case <-done:
+			partial = false
+		case <-timeout:
+			l.log.Warn("user lookup exceeds budget, event is partial",
+				"budget", time.Duration(l.budget.Load()))
+		}
+	}
+
+	logins, stat := l.store(p.users)
+	return LoginEvent{
+		Time:    p.modTime,
+		Login:   p.login,
+		Logout:  p.logout,
+		Types:   p.types,
+		Users:   logins,
+		Stat:    stat,
+		Labels:  Labels(),
+		Partial: partial,
+		Resync:  p.resync,
+		Change:  p.change,
+
+		Received: p.recv,
+		Records:  p.records}
+}
+
+// Получить информацию о пользователях из базы пользователей (через
+// общий кэш с TTL) в кэш последних известных значений. Повторяющиеся
+// имена запрашиваются однократно, запросы выполняются параллельно.
+func (l *Login) lookup(users Users) {
+	names := make([]string, len(users))
+	for i, u := range users {
+		names[i] = u.Name
+	}
+	for name, r := range resolveUserInfo(names, RESOLVE_WORKERS) {
+		if r.err != nil {
+			l.log.Error("can't get user info", "user", name, "err", r.err)
+			continue // keep cached info
+		}
+		l.cacheMx.Lock()
+		l.cache[name] = *r.info
+		l.cacheMx.Unlock()
+	}
+}
+
+// Полная информация о пользователе по кэшу (если пользователя в кэше
+// нет - только имя и метрики).
+func (l *Login) cachedInfo(users Users, name string) (*LoginInfo, error) {
+	l.cacheMx.Lock()
+	info, ok := l.cache[name]
+	l.cacheMx.Unlock()
+	if !ok {
+		info = UserInfo{Name: name}
+	}
+	return &LoginInfo{
+		UserInfo:  info,
+		UserLogin: users.GetUserLogin(name)}, nil
+}
+
+// Сохранить в памяти информацию о пользователях и статистику по кэшу.
+func (l *Login) store(users Users) ([]LoginInfo, LoginStat) {
+	info := func(name string) (*LoginInfo, error) {
+		return l.cachedInfo(users, name)
+	}
 
 	// Получить полную информацию о всех пользователях в системе (logins).
 	// Результирующий список сортирован по времени.
 	// Get full info about logged users (sorted by time)
 	logins := []LoginInfo{}
 	umap := make(map[string]int) // индекс пользователя в списке по имени
-	cnt := 0
-	for _, u := range l.users {
-		info, err := l.users.GetLoginInfo(u.Name)
-		if err != nil {
-			log.Printf("error: %v", err)
-			return
-		}
-		ix, ok := umap[info.Name]
-		if ok {
-			logins[ix] = *info // update (users sorted by time)
+	for _, u := range users {
+		li, _ := info(u.Name)
+		if ix, ok := umap[u.Name]; ok {
+			logins[ix] = *li // update (users sorted by time)
 		} else {
-			logins = append(logins, *info)
-			umap[info.Name] = cnt
-			cnt++
+			umap[u.Name] = len(logins)
+			logins = append(logins, *li)
 		}
 	}
 
@@ -84,44 +325,189 @@ func (l *Login) readUtmp() {
 	l.loginsMx.Unlock()
 
 	// Получить статистику и сохранить в памяти
-	stat := l.users.GetLoginStat()
+	stat := users.loginStat(info)
 	l.statMx.Lock()
 	l.stat = stat
 	l.statMx.Unlock()
 
-	// Write event to channel
-	l.evtChan <- LoginEvent{
-		Time:   modTime,
-		Login:  login,
-		Logout: logout,
-		Users:  logins,
-		Stat:   stat}
+	return logins, stat
+}
+
+// Проверить, что событие fsnotify относится к файлу конфигурации.
+func (l *Login) isConfig(name string) bool {
+	l.confMx.Lock()
+	defer l.confMx.Unlock()
+	return l.confName != "" && name == l.confName
+}
+
+// Перечитать файл конфигурации и повторно классифицировать пользователей.
+// Reload config file and re-read utmp.
+func (l *Login) reloadConfig() {
+	l.confMx.Lock()
+	fname := l.confName
+	l.confMx.Unlock()
+
+	err := LoadConfig(fname)
+	if err != nil {
+		l.report("config", err, false) // keep previous config
+		return
+	}
+	l.rereadUtmp()
+}
+
+// Полностью перечитать utmp файл (без инкрементального чтения, например
+// после изменения конфигурации).
+// Full re-read of utmp file.
+func (l *Login) rereadUtmp() error {
+	if l.reader != nil {
+		l.reader.Close()
+	}
+	return l.readUtmp()
+}
+
+// Повторные проверки отсутствующего utmp файла с экспоненциальной
+// задержкой (от RETRY_MIN до RETRY_MAX) в течение RecreateWait.
+type backoff struct {
+	deadline time.Time     // время ожидания истекает
+	delay    time.Duration // задержка очередной проверки
 }
 
-// Горутина ожидания событий fsnotify,
+// Начать ожидание: канал первой проверки.
+func (b *backoff) start() <-chan time.Time {
+	b.deadline = time.Now().Add(RecreateWait)
+	b.delay = RETRY_MIN
+	return time.After(min(b.delay, RecreateWait))
+}
+
+// Канал следующей проверки (nil - время ожидания истекло).
+func (b *backoff) next() <-chan time.Time {
+	left := time.Until(b.deadline)
+	if left <= 0 {
+		return nil
+	}
+	b.delay = min(2*b.delay, RETRY_MAX)
+	return time.After(min(b.delay, left))
+}
+
+// Горутина ожидания событий fsnotify (или опроса, см. pollerFn),
 // fsnotify goroutine.
-func watcherFn(l *Login) {
-	l.readUtmp() // первый раз прочитать utmp не ожидая события
+func watcherFn(l *Login, events <-chan fsnotify.Event, errs <-chan error) {
+	defer l.wg.Done()
+	defer close(l.parsed)
+	defer close(l.errChan) // единственный отправитель ошибок, см. report()
+	defer close(l.files)   // см. fileChanged()
+	if l.reader != nil {
+		defer l.reader.Close()
+	}
+
+	var gone <-chan time.Time    // проверка повторного создания utmp файла
+	var pending <-chan time.Time // окно объединения записей (см. Debounce)
+	var retry backoff
+
+	// Файл временно отсутствует (ротация) - проверять его появление
+	missing := func(err error) {
+		if errors.Is(err, os.ErrNotExist) && gone == nil {
+			gone = retry.start()
+		}
+	}
+
+	// Первый раз прочитать utmp не ожидая события (ошибка - в NewLoginWith())
+	err := l.readUtmp()
+	l.first <- err
+	if err != nil {
+		return
+	}
 
 For:
 	for {
 		select {
-		case evt, ok := <-l.watcher.Events:
+		case evt, ok := <-events:
 			if !ok {
 				break For
 			}
 			//log.Print("fsnotify: ", evt)
-			if evt.Has(fsnotify.Write) {
-				l.readUtmp() // нас интересует только события обновления файла
+			if l.isConfig(evt.Name) {
+				if evt.Has(fsnotify.Write) || evt.Has(fsnotify.Create) {
+					l.reloadConfig() // файл конфигурации изменен
+				}
+			} else if _, ok := l.watch[evt.Name]; ok {
+				l.fileChanged(evt.Name) // дополнительный файл
+			} else if evt.Name != l.fname {
+				// другие файлы каталога
+			} else if evt.Has(fsnotify.Create) {
+				gone, pending = nil, nil
+				l.resync = true
+				missing(l.readUtmp()) // файл создан заново (атомарная замена, ротация)
+			} else if evt.Has(fsnotify.Write) || evt.Has(fsnotify.Chmod) {
+				if l.debounce <= 0 {
+					missing(l.readUtmp()) // обновление (или усечение) файла
+				} else if pending == nil {
+					pending = time.After(l.debounce) // дождаться остальных записей
+				}
+			} else if (evt.Has(fsnotify.Remove) || evt.Has(fsnotify.Rename)) && gone == nil {
+				l.report("watch", fmt.Errorf("%s: %s", l.fname, evt.Op), false)
+				gone = retry.start()
 			}
-		case err, ok := <-l.watcher.Errors:
+		case <-pending:
+			pending = nil
+			missing(l.readUtmp()) // все записи окна одним событием
+		case <-gone:
+			if _, err := os.Stat(l.fname); err == nil {
+				gone, pending = nil, nil
+				l.resync = true
+				missing(l.readUtmp()) // файл появился снова (событие не получено)
+			} else if gone = retry.next(); gone == nil {
+				l.report("watch", err, true) // файл не создан заново
+			}
+		case r := <-l.replay:
+			r.err <- l.replayWtmp(r.since) // восстановить события из wtmp
+		case <-l.reload:
+			missing(l.rereadUtmp()) // повторное чтение по запросу
+		case err, ok := <-errs:
 			if !ok {
 				break For
 			}
-			log.Print("error:", err)
+			l.report("watch", err, false)
 		} // select
 	} // for
-	l.wg.Done()
 }
 
+// Передать изменение дополнительного файла в канал Files() (без
+// блокировки).
+func (l *Login) fileChanged(name string) {
+	fi, err := os.Stat(name)
+	if err != nil {
+		fi = nil
+	}
+	change := StatChange(l.watch[name], fi)
+	l.watch[name] = fi
+	if change.Kind == CHANGE_NONE {
+		return
+	}
+	select {
+	case l.files <- FileEvent{Path: name, Change: change, Time: time.Now()}:
+	default:
+		l.log.Warn("file channel is full, change dropped", "path", name)
+	}
+}
+
+// Сообщить об ошибке: в журнал и в канал Errors() (без блокировки).
+func (l *Login) report(op string, err error, fatal bool) {
+	e := &LoginError{Op: op, Err: err, Fatal: fatal}
+	l.log.Error(e.Error())
+	select {
+	case l.errChan <- e:
+	default:
+		l.log.Warn("error channel is full, error dropped", "op", op)
+	}
+}
+
+// Обработчик slog, отбрасывающий все записи (журнал по умолчанию).
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
 // EOF: "login.go"
@@ -0,0 +1,717 @@
+// File: "login_test.go"
+
+package utmp
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/stretchr/testify/require"
+)
+
+// Выполнить тест для каждого способа отслеживания изменений.
+func forBackends(t *testing.T, fn func(t *testing.T, opts LoginOpts)) {
+	for b, name := range BackendStr {
+		t.Run(name, func(t *testing.T) {
+			fn(t, LoginOpts{Backend: Backend(b), PollInterval: 10 * time.Millisecond})
+		})
+	}
+}
+
+func TestLogin(t *testing.T) { forBackends(t, testLogin) }
+
+func testLogin(t *testing.T, opts LoginOpts) {
+	now := int32(time.Now().Unix())
+	fname := testFile(t,
+		testRecord(USER_PROCESS, 0, "tty1", "tty1", "root", "", now))
+
+	logs := &syncBuffer{}
+	opts.Logger = slog.New(slog.NewTextHandler(logs, nil))
+	l, err := NewLoginWith(fname, opts)
+	require.NoError(t, err)
+	defer l.Close()
+
+	require.Len(t, l.GetUsers(), 1)
+	require.Equal(t, "0", l.GetUsers()[0].UID)
+	require.Equal(t, 1, l.GetStat().Total)
+
+	// unknown user must not block events
+	f, err := os.OpenFile(fname, os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	u := testRecord(USER_PROCESS, 0, "pts/0", "ts/0", "nosuchuser0", "10.0.0.5", now+1)
+	require.NoError(t, binary.Write(f, binary.LittleEndian, &u))
+	require.NoError(t, f.Close())
+
+	select {
+	case evt := <-l.C():
+		require.Equal(t, []UserTTY{{"nosuchuser0", "pts/0"}}, evt.Login)
+		require.False(t, evt.Partial)
+		require.Len(t, evt.Users, 2)
+		require.Equal(t, "nosuchuser0", evt.Users[1].Name)
+		require.Equal(t, 1, evt.Users[1].Logons)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no login event")
+	}
+	require.Contains(t, logs.String(), "user=nosuchuser0")
+}
+
+func TestLoginErrors(t *testing.T) {
+	defer func(wait time.Duration) { RecreateWait = wait }(RecreateWait)
+	RecreateWait = 100 * time.Millisecond
+	forBackends(t, testLoginErrors)
+}
+
+func testLoginErrors(t *testing.T, opts LoginOpts) {
+	fname := testFile(t,
+		testRecord(USER_PROCESS, 0, "tty1", "tty1", "root", "", int32(time.Now().Unix())))
+	l, err := NewLoginWith(fname, opts)
+	require.NoError(t, err)
+	defer l.Close()
+
+	next := func() *LoginError {
+		select {
+		case err := <-l.Errors():
+			var e *LoginError
+			require.ErrorAs(t, err, &e)
+			return e
+		case <-time.After(5 * time.Second):
+			t.Fatal("no error event")
+		}
+		return nil
+	}
+
+	// transient: utmp is overwritten by garbage
+	require.NoError(t, os.WriteFile(fname, bytes.Repeat([]byte{0x7F}, RECORD_SIZE), 0644))
+	e := next()
+	require.Equal(t, "read", e.Op)
+	require.False(t, e.Fatal)
+	require.ErrorIs(t, e, ErrUnsupportedFormat)
+
+	// fatal: utmp is removed and not recreated
+	require.NoError(t, os.Remove(fname))
+	for e = next(); e.Op != "watch"; e = next() {
+	}
+	require.False(t, e.Fatal)
+	for e = next(); e.Op != "watch"; e = next() {
+	}
+	require.True(t, e.Fatal)
+	require.ErrorIs(t, e, os.ErrNotExist)
+}
+
+func TestNewLoginError(t *testing.T) { forBackends(t, testNewLoginError) }
+
+func testNewLoginError(t *testing.T, opts LoginOpts) {
+	newLogin := func(fname string) error {
+		res := make(chan error, 1)
+		go func() {
+			l, err := NewLoginWith(fname, opts)
+			if err == nil {
+				l.Close()
+			}
+			res <- err
+		}()
+		select {
+		case err := <-res:
+			return err
+		case <-time.After(5 * time.Second):
+			t.Fatal("NewLoginWith() is blocked")
+		}
+		return nil
+	}
+
+	// missing utmp
+	err := newLogin(filepath.Join(t.TempDir(), "utmp"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	// first read fails: directory is not rejected by Open()
+	require.ErrorIs(t, newLogin(t.TempDir()), syscall.EISDIR)
+}
+
+func TestLoginRecreate(t *testing.T) { forBackends(t, testLoginRecreate) }
+
+func testLoginRecreate(t *testing.T, opts LoginOpts) {
+	now := int32(time.Now().Unix())
+	root := testRecord(USER_PROCESS, 0, "tty1", "tty1", "root", "", now)
+	fname := testFile(t, root)
+	l, err := NewLoginWith(fname, opts)
+	require.NoError(t, err)
+	defer l.Close()
+
+	// utmp is briefly missing (rotation) and written again
+	require.NoError(t, os.Remove(fname))
+	time.Sleep(3 * RETRY_MIN)
+	bob := testRecord(USER_PROCESS, 0, "pts/0", "ts/0", "bob", "10.0.0.5", now+1)
+	tmp := testFile(t, root, bob)
+	require.NoError(t, os.Rename(tmp, fname))
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case evt := <-l.C():
+			if !evt.Resync {
+				continue
+			}
+			require.Equal(t, []UserTTY{{"bob", "pts/0"}}, evt.Login)
+			require.Empty(t, evt.Logout)
+			return
+		case err := <-l.Errors():
+			var e *LoginError
+			require.ErrorAs(t, err, &e)
+			require.False(t, e.Fatal)
+		case <-timeout:
+			t.Fatal("no resync event")
+		}
+	}
+}
+
+func TestLoginWatch(t *testing.T) { forBackends(t, testLoginWatch) }
+
+func testLoginWatch(t *testing.T, opts LoginOpts) {
+	now := int32(time.Now().Unix())
+	root := testRecord(USER_PROCESS, 0, "tty1", "tty1", "root", "", now)
+	fname := testFile(t, root)
+	wtmp := testFile(t, root)
+	btmp := filepath.Join(filepath.Dir(wtmp), "btmp") // does not exist yet
+	opts.Watch = []string{wtmp, btmp + "/"}
+	l, err := NewLoginWith(fname, opts)
+	require.NoError(t, err)
+	defer l.Close()
+
+	// next change of file (created file may be reported as appended too)
+	next := func(path string, kind ChangeKind) FileChange {
+		timeout := time.After(5 * time.Second)
+		for {
+			select {
+			case evt := <-l.Files():
+				require.False(t, evt.Time.IsZero())
+				if evt.Path == path && evt.Change.Kind == kind {
+					return evt.Change
+				}
+				require.Equal(t, CHANGE_APPENDED, evt.Change.Kind, evt.Path)
+			case <-timeout:
+				t.Fatalf("no %s event", kind)
+			}
+		}
+	}
+	appendTo := func(name string, u Utmp) {
+		f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		require.NoError(t, err)
+		require.NoError(t, binary.Write(f, binary.LittleEndian, &u))
+		require.NoError(t, f.Close())
+	}
+
+	// utmp changes are not reported as file changes
+	bob := testRecord(USER_PROCESS, 0, "pts/0", "ts/0", "bob", "10.0.0.5", now+1)
+	appendTo(fname, bob)
+	select {
+	case <-l.C():
+	case <-time.After(5 * time.Second):
+		t.Fatal("no login event")
+	}
+
+	appendTo(wtmp, bob)
+	c := next(wtmp, CHANGE_APPENDED)
+	require.Equal(t, int64(RECORD_SIZE), c.OldSize)
+	require.Equal(t, int64(2*RECORD_SIZE), c.NewSize)
+
+	appendTo(btmp, bob)
+	next(btmp, CHANGE_CREATED)
+
+	require.NoError(t, os.Truncate(wtmp, 0))
+	next(wtmp, CHANGE_TRUNCATED)
+
+	require.NoError(t, os.Remove(btmp))
+	next(btmp, CHANGE_DELETED)
+
+	l.Close()
+	_, ok := <-l.Files()
+	require.False(t, ok)
+}
+
+func TestBackoff(t *testing.T) {
+	defer func(wait time.Duration) { RecreateWait = wait }(RecreateWait)
+	RecreateWait = 4 * RETRY_MIN
+
+	var b backoff
+	start := time.Now()
+	n := 0
+	for c := b.start(); c != nil; c = b.next() {
+		<-c
+		n++
+	}
+	require.Equal(t, 3, n) // RETRY_MIN, 2*RETRY_MIN, rest of RecreateWait
+	require.GreaterOrEqual(t, time.Since(start), RecreateWait)
+	require.Equal(t, 4*RETRY_MIN, b.delay)
+}
+
+func TestLoginResync(t *testing.T) { forBackends(t, testLoginResync) }
+
+func testLoginResync(t *testing.T, opts LoginOpts) {
+	now := int32(time.Now().Unix())
+	root := testRecord(USER_PROCESS, 0, "tty1", "tty1", "root", "", now)
+	fname := testFile(t, root)
+	l, err := NewLoginWith(fname, opts)
+	require.NoError(t, err)
+	defer l.Close()
+
+	next := func() LoginEvent {
+		select {
+		case evt := <-l.C():
+			return evt
+		case <-time.After(5 * time.Second):
+			t.Fatal("no event")
+		}
+		return LoginEvent{}
+	}
+
+	// atomic replace
+	bob := testRecord(USER_PROCESS, 0, "pts/0", "ts/0", "bob", "10.0.0.5", now+1)
+	tmp := testFile(t, bob)
+	require.NoError(t, os.Rename(tmp, fname))
+	evt := next()
+	for !evt.Resync {
+		evt = next()
+	}
+	require.Equal(t, []UserTTY{{"bob", "pts/0"}}, evt.Login)
+	require.Equal(t, []UserTTY{{"root", "tty1"}}, evt.Logout)
+	require.Equal(t, CHANGE_REPLACED, evt.Change.Kind)
+
+	// truncation (e.g. at boot)
+	require.NoError(t, os.Truncate(fname, 0))
+	for evt = next(); !evt.Resync; evt = next() {
+	}
+	require.Equal(t, []UserTTY{{"bob", "pts/0"}}, evt.Logout)
+	require.Empty(t, evt.Users)
+	require.Equal(t, CHANGE_TRUNCATED, evt.Change.Kind)
+
+	// plain update
+	f, err := os.OpenFile(fname, os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	require.NoError(t, binary.Write(f, binary.LittleEndian, &root))
+	require.NoError(t, f.Close())
+	evt = next()
+	require.False(t, evt.Resync)
+	require.Equal(t, []UserTTY{{"root", "tty1"}}, evt.Login)
+	require.Equal(t, CHANGE_APPENDED, evt.Change.Kind)
+	require.Equal(t, int64(0), evt.Change.OldSize)
+	require.Equal(t, int64(RECORD_SIZE), evt.Change.NewSize)
+}
+
+func TestLoginDebounce(t *testing.T) {
+	now := int32(time.Now().Unix())
+	fname := testFile(t,
+		testRecord(USER_PROCESS, 0, "tty1", "tty1", "root", "", now))
+	l, err := NewLoginWith(fname, LoginOpts{Debounce: 200 * time.Millisecond})
+	require.NoError(t, err)
+	defer l.Close()
+
+	// display manager burst: several records in quick succession
+	recs := []Utmp{
+		testRecord(USER_PROCESS, 0, ":0", ":0", "alice", ":0", now+1),
+		testRecord(USER_PROCESS, 0, "pts/0", "ts/0", "alice", ":0", now+1),
+		testRecord(DEAD_PROCESS, 0, "tty1", "tty1", "", "", now+1)}
+	for i := range recs {
+		f, err := os.OpenFile(fname, os.O_WRONLY|os.O_APPEND, 0)
+		require.NoError(t, err)
+		require.NoError(t, binary.Write(f, binary.LittleEndian, &recs[i]))
+		require.NoError(t, f.Close())
+	}
+
+	select {
+	case evt := <-l.C():
+		require.ElementsMatch(t, []UserTTY{{"alice", ":0"}, {"alice", "pts/0"}}, evt.Login)
+		require.Equal(t, []UserTTY{{"root", "tty1"}}, evt.Logout)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event")
+	}
+	select {
+	case evt := <-l.C():
+		t.Fatalf("unexpected event: %+v", evt)
+	case <-time.After(300 * time.Millisecond):
+	}
+}
+
+func TestLoginReplay(t *testing.T) {
+	now := int32(time.Now().Unix())
+	wtmp := testFile(t,
+		testRecord(BOOT_TIME, 0, "~", "~~", "reboot", "6.1.0", now-100),
+		testRecord(USER_PROCESS, 101, "tty1", "tty1", "alice", "", now-90),
+		testRecord(USER_PROCESS, 102, "pts/0", "ts/0", "bob", "10.0.0.5", now-50),
+		testRecord(DEAD_PROCESS, 102, "pts/0", "ts/0", "", "", now-40),
+		testRecord(USER_PROCESS, 0, "tty1", "tty1", "root", "", now-30))
+	fname := testFile(t,
+		testRecord(USER_PROCESS, 0, "tty1", "tty1", "root", "", now))
+	l, err := NewLoginWith(fname, LoginOpts{Wtmp: wtmp, Buffer: 8})
+	require.NoError(t, err)
+	defer l.Close()
+
+	require.NoError(t, l.Replay(time.Unix(int64(now-60), 0)))
+	var events []LoginEvent
+	for len(events) < 3 {
+		select {
+		case evt := <-l.C():
+			require.True(t, evt.Historical)
+			events = append(events, evt)
+		case <-time.After(5 * time.Second):
+			t.Fatal("no event")
+		}
+	}
+	require.Equal(t, []UserTTY{{"bob", "pts/0"}}, events[0].Login)
+	require.Equal(t, 2, events[0].Stat.Total)
+	require.Equal(t, time.Unix(int64(now-50), 0), events[0].Time)
+	require.Equal(t, []UserTTY{{"bob", "pts/0"}}, events[1].Logout)
+	require.Equal(t, []UserTTY{{"root", "tty1"}}, events[2].Login)
+	require.Equal(t, 2, events[2].Stat.Total) // alice logged in before since
+	for i, evt := range events {
+		require.Equal(t, uint64(i+2), evt.Seq) // 1 - initial read
+		if i > 0 {
+			require.False(t, evt.Received.Before(events[i-1].Received))
+		}
+	}
+
+	l.Close()
+	require.ErrorIs(t, l.Replay(time.Time{}), ErrClosed)
+}
+
+func TestLoginRawRecords(t *testing.T) {
+	now := int32(time.Now().Unix())
+	root := testRecord(USER_PROCESS, 0, "tty1", "tty1", "root", "", now)
+	dead := testRecord(DEAD_PROCESS, 0, "tty1", "tty1", "", "", now+1)
+	fname := testFile(t, root)
+	for _, incremental := range []bool{false, true} {
+		l, err := NewLoginWith(fname, LoginOpts{RawRecords: true, Incremental: incremental})
+		require.NoError(t, err)
+
+		for _, rec := range []Utmp{dead, root} {
+			f, err := os.OpenFile(fname, os.O_WRONLY|os.O_APPEND, 0)
+			require.NoError(t, err)
+			require.NoError(t, binary.Write(f, binary.LittleEndian, &rec))
+			require.NoError(t, f.Close())
+
+			select {
+			case evt := <-l.C():
+				require.Equal(t, []Utmp{rec}, evt.Records)
+			case <-time.After(5 * time.Second):
+				t.Fatal("no event")
+			}
+		}
+		l.Close()
+	}
+}
+
+func TestCoalesce(t *testing.T) {
+	a := LoginEvent{Login: []UserTTY{{"alice", "pts/0"}}}
+	coalesce(&a, LoginEvent{Login: []UserTTY{{"bob", "pts/1"}}})
+	coalesce(&a, LoginEvent{Logout: []UserTTY{{"alice", "pts/0"}, {"carol", "tty1"}}})
+	coalesce(&a, LoginEvent{Login: []UserTTY{{"carol", "tty1"}}, Partial: true})
+	require.Equal(t, LoginEvent{
+		Login:     []UserTTY{{"bob", "pts/1"}},
+		Logout:    []UserTTY{},
+		Partial:   true,
+		Coalesced: 3}, a)
+
+	// login types of earlier events are kept, shared maps are not changed
+	alice, bob := UserTTY{"alice", "pts/0"}, UserTTY{"bob", "tty1"}
+	first := map[UserTTY]LoginType{alice: REMOTE}
+	second := map[UserTTY]LoginType{bob: LOCAL}
+	a = LoginEvent{Login: []UserTTY{alice}, Types: first}
+	coalesce(&a, LoginEvent{Login: []UserTTY{bob}, Types: second})
+	require.Equal(t, []UserTTY{alice, bob}, a.Login)
+	require.Equal(t, map[UserTTY]LoginType{alice: REMOTE, bob: LOCAL}, a.Types)
+	require.Len(t, first, 1)
+	require.Len(t, second, 1)
+}
+
+func TestLoginOverflow(t *testing.T) {
+	now := int32(time.Now().Unix())
+	fname := testFile(t, testRecord(USER_PROCESS, 0, "tty1", "tty1", "root", "", now))
+	l, err := NewLoginWith(fname, LoginOpts{Overflow: OVERFLOW_COALESCE})
+	require.NoError(t, err)
+
+	// slow receiver: logins are merged into one event
+	for i, tty := range []string{"pts/0", "pts/1", "pts/2"} {
+		f, err := os.OpenFile(fname, os.O_WRONLY|os.O_APPEND, 0)
+		require.NoError(t, err)
+		u := testRecord(USER_PROCESS, 0, tty, tty[1:], "root", "", now+int32(i)+1)
+		require.NoError(t, binary.Write(f, binary.LittleEndian, &u))
+		require.NoError(t, f.Close())
+		time.Sleep(50 * time.Millisecond)
+	}
+	time.Sleep(300 * time.Millisecond)
+
+	evt := <-l.C()
+	require.Len(t, evt.Login, 3)
+	require.Len(t, evt.Users, 1)
+	require.Len(t, evt.Types, 3) // types of all merged logins
+	for _, ut := range evt.Login {
+		require.Equal(t, LOCAL, evt.Types[ut], ut.TTY)
+	}
+	select {
+	case evt = <-l.C():
+		t.Fatalf("unexpected event %v", evt)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	// close with pending events is safe, channels are closed
+	go l.Close()
+	l.Close()
+	_, ok := <-l.C()
+	require.False(t, ok)
+	_, ok = <-l.Errors()
+	require.False(t, ok)
+}
+
+// База пользователей с задержкой ответа.
+type slowDB struct {
+	UserDB
+	delay time.Duration
+}
+
+func (db *slowDB) LookupUser(name string) (*UserInfo, error) {
+	time.Sleep(db.delay)
+	return db.UserDB.LookupUser(name)
+}
+
+func TestLoginEnrichBudget(t *testing.T) {
+	SetUserDB(&slowDB{UserDB: &FileUserDB{users: map[string]*passwdEntry{
+		"alice": {name: "alice", uid: "1000"},
+		"bob":   {name: "bob", uid: "1001"}}}, delay: 300 * time.Millisecond})
+	defer SetUserDB(nil)
+
+	now := int32(time.Now().Unix())
+	fname := testFile(t)
+	l, err := NewLoginWith(fname, LoginOpts{})
+	require.NoError(t, err)
+	defer l.Close()
+
+	login := func(i int32, user string) LoginEvent {
+		f, err := os.OpenFile(fname, os.O_WRONLY|os.O_APPEND, 0)
+		require.NoError(t, err)
+		tty := []string{"pts/0", "pts/1"}[i]
+		u := testRecord(USER_PROCESS, 0, tty, tty[1:], user, "", now+i)
+		require.NoError(t, binary.Write(f, binary.LittleEndian, &u))
+		require.NoError(t, f.Close())
+		select {
+		case evt := <-l.C():
+			return evt
+		case <-time.After(5 * time.Second):
+			t.Fatal("no login event")
+		}
+		return LoginEvent{}
+	}
+
+	// no budget by default: wait for full enrichment
+	evt := login(0, "alice")
+	require.False(t, evt.Partial)
+	require.Len(t, evt.Users, 1)
+	require.Equal(t, "1000", evt.Users[0].UID)
+
+	// opt-in budget: event is sent before slow lookup completes
+	l.SetEnrichBudget(10 * time.Millisecond)
+	evt = login(1, "bob")
+	require.True(t, evt.Partial)
+	require.Equal(t, []UserTTY{{"bob", "pts/1"}}, evt.Login)
+}
+
+func TestSubscribe(t *testing.T) {
+	now := int32(time.Now().Unix())
+	fname := testFile(t, testRecord(USER_PROCESS, 0, "tty1", "tty1", "root", "", now))
+	l, err := NewLogin(fname, false)
+	require.NoError(t, err)
+	defer l.Close()
+
+	c1 := l.Subscribe(4, OVERFLOW_BLOCK)
+	c2 := l.Subscribe(0, OVERFLOW_COALESCE)
+
+	f, err := os.OpenFile(fname, os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	u := testRecord(USER_PROCESS, 0, "pts/0", "ts/0", "root", "", now+1)
+	require.NoError(t, binary.Write(f, binary.LittleEndian, &u))
+	require.NoError(t, f.Close())
+
+	for _, c := range []<-chan LoginEvent{l.C(), c1, c2} {
+		select {
+		case evt := <-c:
+			require.Equal(t, []UserTTY{{"root", "pts/0"}}, evt.Login)
+		case <-time.After(5 * time.Second):
+			t.Fatal("no login event")
+		}
+	}
+
+	l.Unsubscribe(c2)
+	l.Unsubscribe(c2)
+	_, ok := <-c2
+	require.False(t, ok)
+
+	l.Close()
+	_, ok = <-c1
+	require.False(t, ok)
+	_, ok = <-l.Subscribe(1, OVERFLOW_BLOCK)
+	require.False(t, ok)
+}
+
+func TestSubscribeDropOldest(t *testing.T) {
+	now := int32(time.Now().Unix())
+	fname := testFile(t, testRecord(USER_PROCESS, 0, "tty1", "tty1", "root", "", now))
+	l, err := NewLogin(fname, false)
+	require.NoError(t, err)
+	defer l.Close()
+
+	c := l.Subscribe(0, OVERFLOW_DROP_OLDEST)
+	require.Equal(t, 1, cap(c))
+
+	// stalled receiver: only the last event is kept, no busy loop
+	for i, tty := range []string{"pts/0", "pts/1", "pts/2"} {
+		f, err := os.OpenFile(fname, os.O_WRONLY|os.O_APPEND, 0)
+		require.NoError(t, err)
+		u := testRecord(USER_PROCESS, 0, tty, tty[1:], "root", "", now+int32(i)+1)
+		require.NoError(t, binary.Write(f, binary.LittleEndian, &u))
+		require.NoError(t, f.Close())
+		time.Sleep(50 * time.Millisecond)
+	}
+	require.Eventually(t, func() bool { return l.Dropped() >= 2 },
+		5*time.Second, 10*time.Millisecond)
+
+	cpu := func() time.Duration {
+		var ru syscall.Rusage
+		require.NoError(t, syscall.Getrusage(syscall.RUSAGE_SELF, &ru))
+		return time.Duration(ru.Utime.Nano() + ru.Stime.Nano())
+	}
+	start := cpu()
+	time.Sleep(300 * time.Millisecond)
+	require.Less(t, cpu()-start, 150*time.Millisecond, "subscriber is spinning")
+
+	evt := <-c
+	require.Equal(t, []UserTTY{{"root", "pts/2"}}, evt.Login)
+}
+
+func TestSubscribeFilter(t *testing.T) {
+	root := UserTTY{"root", "pts/0"}
+	alice := UserTTY{"alice", "tty1"}
+	evt := LoginEvent{
+		Login: []UserTTY{root, alice},
+		Types: map[UserTTY]LoginType{root: REMOTE, alice: LOCAL},
+		Users: []LoginInfo{
+			{UserInfo: UserInfo{Name: "root"}, UserLogin: UserLogin{Type: REMOTE}},
+			{UserInfo: UserInfo{Name: "alice"}, UserLogin: UserLogin{Type: LOCAL}}}}
+	evt.Stat.Active = &evt.Users[1]
+
+	// remote root logins only
+	sub := &subscriber{filter: SubscribeOpts{Users: []string{"root"}, Types: []LoginType{REMOTE}}}
+	got, ok := sub.apply(evt)
+	require.True(t, ok)
+	require.Equal(t, []UserTTY{root}, got.Login)
+	require.Len(t, got.Users, 1)
+	require.Len(t, evt.Login, 2) // source event is not changed
+
+	_, ok = sub.apply(LoginEvent{Logout: []UserTTY{alice}})
+	require.False(t, ok)
+
+	// active user changes only
+	sub = &subscriber{filter: SubscribeOpts{ActiveOnly: true}}
+	_, ok = sub.apply(evt)
+	require.True(t, ok) // nobody -> alice
+	_, ok = sub.apply(evt)
+	require.False(t, ok)
+	_, ok = sub.apply(LoginEvent{Logout: []UserTTY{alice}})
+	require.True(t, ok) // alice -> nobody
+}
+
+func TestSubscribeActive(t *testing.T) {
+	now := int32(time.Now().Unix())
+	fname := testFile(t, testRecord(USER_PROCESS, 0, "tty1", "tty1", "root", "", now))
+	l, err := NewLoginWith(fname, LoginOpts{Overflow: OVERFLOW_COALESCE})
+	require.NoError(t, err)
+	defer l.Close()
+
+	c := l.SubscribeActive(1)
+	require.NoError(t, os.Truncate(fname, 0)) // root logout
+
+	select {
+	case evt := <-c:
+		require.Equal(t, "root", evt.Prev.Name)
+		require.Nil(t, evt.Active)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no active user event")
+	}
+
+	l.UnsubscribeActive(c)
+	_, ok := <-c
+	require.False(t, ok)
+}
+
+// Буфер журнала для нескольких горутин.
+type syncBuffer struct {
+	mx  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	return b.buf.String()
+}
+
+func TestLoginContext(t *testing.T) {
+	fname := testFile(t,
+		testRecord(USER_PROCESS, 0, "tty1", "tty1", "root", "", int32(time.Now().Unix())))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	l, err := NewLoginContext(ctx, fname, false)
+	require.NoError(t, err)
+	cancel()
+
+	select {
+	case _, ok := <-l.C():
+		require.False(t, ok) // channel closed
+	case <-time.After(5 * time.Second):
+		t.Fatal("login is not stopped by context")
+	}
+	l.Close() // safe after cancel
+
+	_, err = NewLoginContext(ctx, fname, false)
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestLoginFallback(t *testing.T) {
+	defer func(fn func() (*fsnotify.Watcher, error)) { newFsnotify = fn }(newFsnotify)
+	newFsnotify = func() (*fsnotify.Watcher, error) { return nil, syscall.EMFILE }
+
+	now := int32(time.Now().Unix())
+	fname := testFile(t,
+		testRecord(USER_PROCESS, 0, "tty1", "tty1", "root", "", now))
+	l, err := NewLoginWith(fname, LoginOpts{PollInterval: 10 * time.Millisecond})
+	require.NoError(t, err)
+	defer l.Close()
+	require.Equal(t, BACKEND_POLL, l.Backend())
+
+	f, err := os.OpenFile(fname, os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	u := testRecord(DEAD_PROCESS, 0, "tty1", "tty1", "", "", now+1)
+	require.NoError(t, binary.Write(f, binary.LittleEndian, &u))
+	require.NoError(t, f.Close())
+
+	select {
+	case evt := <-l.C():
+		require.Equal(t, []UserTTY{{"root", "tty1"}}, evt.Logout)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no logout event")
+	}
+}
+
+// EOF: "login_test.go"
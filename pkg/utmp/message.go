@@ -0,0 +1,132 @@
+// File: "message.go"
+
+package utmp
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Время ожидания записи сообщения в терминал по умолчанию (терминал может
+// быть остановлен Ctrl+S).
+// Default terminal write timeout.
+const MESSAGE_TIMEOUT = 3 * time.Second
+
+// Опции отправки сообщений на терминалы.
+// Terminal message options.
+type MessageOpts struct {
+	From    string        // отправитель в заголовке ("" - текущий пользователь@узел)
+	Force   bool          // писать и на терминалы с запретом сообщений (mesg n)
+	Timeout time.Duration // время ожидания записи (0 - MESSAGE_TIMEOUT)
+}
+
+// Результат отправки сообщения на терминал.
+// Terminal message result.
+type MessageResult struct {
+	User string // Username
+	TTY  string // TTY device
+	Err  error  // nil - message is written
+}
+
+// Написать сообщение на терминалы вошедших пользователей (как `write` и
+// `wall`): с заголовком "Message from root@host at 10:00 ...", управляющие
+// символы текста заменяются на ^X. Терминалы с запретом сообщений
+// (`mesg n`, нет права записи для группы) пропускаются с ошибкой
+// ErrMesgOff, если не задано opts.Force. X сеансы (":0") пропускаются,
+// на каждый терминал сообщение пишется один раз.
+// Write message to terminals of users.
+func (users Users) Message(text string, opts MessageOpts) []MessageResult {
+	if opts.Timeout <= 0 {
+		opts.Timeout = MESSAGE_TIMEOUT
+	}
+	if opts.From == "" {
+		opts.From = sender()
+	}
+	msg := formatMessage(text, opts.From, time.Now())
+
+	var list []MessageResult
+	done := make(map[string]bool)
+	for _, u := range users {
+		if u.TTY == "" || strings.Contains(u.TTY, ":") || done[u.TTY] {
+			continue // no terminal (X session)
+		}
+		done[u.TTY] = true
+		err := writeTTY(u.TTY, msg, opts)
+		list = append(list, MessageResult{User: u.Name, TTY: u.TTY, Err: err})
+	}
+	return list
+}
+
+// Записать сообщение в терминал /dev/<tty>.
+func writeTTY(tty, msg string, opts MessageOpts) error {
+	if Offline() {
+		return ErrOffline
+	}
+	dev := filepath.Join("/dev", tty)
+	if !strings.HasPrefix(dev, "/dev/") || strings.Contains(tty, "..") {
+		return fmt.Errorf("bad tty '%s'", tty)
+	}
+	var st syscall.Stat_t
+	if err := syscall.Stat(dev, &st); err != nil {
+		return &os.PathError{Op: "stat", Path: dev, Err: err}
+	}
+	if st.Mode&syscall.S_IFMT != syscall.S_IFCHR {
+		return fmt.Errorf("%s is not a terminal", dev)
+	}
+	if st.Mode&syscall.S_IWGRP == 0 && !opts.Force {
+		return ErrMesgOff
+	}
+
+	// Non-blocking terminal is pollable, so write deadline works
+	f, err := os.OpenFile(dev, os.O_WRONLY|syscall.O_NOCTTY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	f.SetWriteDeadline(time.Now().Add(opts.Timeout))
+	_, err = f.WriteString(msg)
+	return err
+}
+
+// Отправитель по умолчанию: пользователь@узел.
+func sender() string {
+	name := "?"
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	if host, err := os.Hostname(); err == nil {
+		name += "@" + host
+	}
+	return name
+}
+
+// Сформировать сообщение: заголовок, текст с заменой управляющих символов
+// на ^X, строки через "\r\n".
+func formatMessage(text, from string, t time.Time) string {
+	var b strings.Builder
+	b.WriteString("\r\n\aMessage from " + from + " at " + t.Format("15:04 Mon Jan 2") + " ...\r\n")
+	for _, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		for _, r := range line {
+			switch {
+			case r == '\t':
+				b.WriteRune(r)
+			case r < ' ' || r == 0x7f:
+				b.WriteString("^" + string(rune(r^0x40)))
+			case r >= 0x80 && r < 0xa0: // C1 controls
+				b.WriteString("^?")
+			default:
+				b.WriteRune(r)
+			}
+		}
+		b.WriteString("\r\n")
+	}
+	b.WriteString("EOF\r\n")
+	return b.String()
+}
+
+// EOF: "message.go"
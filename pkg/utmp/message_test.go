@@ -0,0 +1,63 @@
+// File: "message_test.go"
+
+package utmp
+
+import (
+	"os"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Открыть псевдотерминал: мастер и имя подчинённого ("pts/N").
+func openPTY(t *testing.T) (*os.File, string) {
+	m, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		t.Skip("no /dev/ptmx:", err)
+	}
+	t.Cleanup(func() { m.Close() })
+	var n, unlock uint32
+	_, _, e := syscall.Syscall(syscall.SYS_IOCTL, m.Fd(), syscall.TIOCSPTLCK, uintptr(unsafe.Pointer(&unlock)))
+	require.Zero(t, e)
+	_, _, e = syscall.Syscall(syscall.SYS_IOCTL, m.Fd(), syscall.TIOCGPTN, uintptr(unsafe.Pointer(&n)))
+	require.Zero(t, e)
+	return m, "pts/" + strconv.Itoa(int(n))
+}
+
+func TestMessage(t *testing.T) {
+	m, tty := openPTY(t)
+	require.NoError(t, os.Chmod("/dev/"+tty, 0620)) // mesg y
+
+	users := Users{
+		{Name: "alice", TTY: tty},
+		{Name: "alice", TTY: tty}, // same terminal
+		{Name: "bob", TTY: ":0"},  // X session
+		{Name: "carol", TTY: "pts/4242"},
+	}
+	res := users.Message("reboot in 5 min\n\x1b[2Jbye", MessageOpts{From: "root@web1"})
+	require.Len(t, res, 2)
+	require.NoError(t, res[0].Err)
+	require.Error(t, res[1].Err)
+
+	buf := make([]byte, 1024)
+	m.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, err := m.Read(buf)
+	require.NoError(t, err)
+	out := string(buf[:n])
+	require.Contains(t, out, "Message from root@web1 at ")
+	require.Contains(t, out, "reboot in 5 min")
+	require.Contains(t, out, "^[[2Jbye") // escape sequence is disarmed
+	require.NotContains(t, out, "\x1b")
+
+	require.NoError(t, os.Chmod("/dev/"+tty, 0600)) // mesg n
+	res = users[:1].Message("x", MessageOpts{})
+	require.ErrorIs(t, res[0].Err, ErrMesgOff)
+	res = users[:1].Message("x", MessageOpts{Force: true})
+	require.NoError(t, res[0].Err)
+}
+
+// EOF: "message_test.go"
@@ -0,0 +1,80 @@
+// File: "mmap.go"
+
+package utmp
+
+import (
+	"os"
+	"syscall"
+)
+
+// Открыть utmp/wtmp/btmp файл, отобразив его в память (только для чтения),
+// и вернуть Scanner для последовательного чтения записей. Записи
+// декодируются непосредственно из отображения без системных вызовов
+// read() и промежуточных буферов - режим для анализа больших wtmp файлов.
+// Сжатые файлы читаются обычным образом (см. Open()).
+// После чтения необходимо вызвать Scanner.Close().
+// Записи, добавленные в файл после открытия, не видны (нет режима "tail -f").
+// Как и в Open(), проверяется тип первой записи (ErrUnsupportedFormat).
+// Если файл усекается во время чтения (logrotate), обращение к
+// отображению вместо SIGBUS прерывает чтение: Scan() возвращает false,
+// Err() - ErrTruncated.
+// Open file as memory mapped record stream.
+func OpenMapped(fname string) (*Scanner, error) {
+	f, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() // mapping stays valid after close
+
+	magic := make([]byte, len(MAGIC_XZ))
+	n, _ := f.ReadAt(magic, 0)
+	if isCompressed(magic[:n]) {
+		r, err := Open(fname)
+		if err != nil {
+			return nil, err
+		}
+		s := NewScanner(r)
+		s.cl = r
+		return s, nil
+	}
+
+	if err = checkFormat(f, fname); err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Scanner{data: []byte{}, keys: make(map[TTYID]struct{})}
+	if size := fi.Size(); size > 0 {
+		s.data, err = syscall.Mmap(int(f.Fd()), 0, int(size),
+			syscall.PROT_READ, syscall.MAP_SHARED)
+		if err != nil {
+			return nil, &os.PathError{Op: "mmap", Path: fname, Err: err}
+		}
+		s.cl = mapping(s.data)
+	}
+	return s, nil
+}
+
+// Прервать чтение отображения при его усечении: ошибка обращения к
+// памяти (SIGBUS) превращается в панику (см. debug.SetPanicOnFault()),
+// перехватываемую здесь. Вызывается через defer в scanMapped().
+func (s *Scanner) recoverFault(ok *bool) {
+	if r := recover(); r != nil {
+		if _, fault := r.(interface{ Addr() uintptr }); !fault {
+			panic(r)
+		}
+		s.err, *ok = ErrTruncated, false
+	}
+}
+
+// Отображение файла в память.
+type mapping []byte
+
+func (m mapping) Close() error {
+	return syscall.Munmap(m)
+}
+
+// EOF: "mmap.go"
@@ -0,0 +1,82 @@
+// File: "mmap_test.go"
+
+package utmp
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenMapped(t *testing.T) {
+	recs := []Utmp{
+		testRecord(BOOT_TIME, 0, "~", "~~", "reboot", "6.1.0", 1000),
+		testRecord(EMPTY, 0, "", "", "", "", 0),
+		testRecord(USER_PROCESS, 101, "pts/0", "ts/0", "bob", "10.0.0.5", 1010),
+	}
+	fname := testFile(t, recs...)
+
+	s, err := OpenMapped(fname)
+	require.NoError(t, err)
+	got := []Utmp{}
+	for s.Scan() {
+		got = append(got, *s.Record())
+	}
+	require.NoError(t, s.Err())
+	require.NoError(t, s.Close())
+	require.Equal(t, recs, got)
+	require.Equal(t, ScanStat{Records: 3, Empty: 1}, s.Stat())
+
+	// empty file
+	empty := filepath.Join(t.TempDir(), "empty")
+	require.NoError(t, os.WriteFile(empty, nil, 0644))
+	s, err = OpenMapped(empty)
+	require.NoError(t, err)
+	require.False(t, s.Scan())
+	require.NoError(t, s.Close())
+}
+
+func TestOpenMappedFormat(t *testing.T) {
+	fname := filepath.Join(t.TempDir(), "wtmp")
+	data := make([]byte, RECORD_SIZE)
+	data[0] = 0x7F // bad type of first record
+	require.NoError(t, os.WriteFile(fname, data, 0644))
+	_, err := OpenMapped(fname)
+	require.ErrorIs(t, err, ErrUnsupportedFormat)
+}
+
+func TestOpenMappedTruncated(t *testing.T) {
+	recs := make([]Utmp, 100) // several pages
+	for i := range recs {
+		recs[i] = testRecord(USER_PROCESS, uint32(100+i), "pts/0", "ts/0", "bob", "", int32(1000+i))
+	}
+	fname := testFile(t, recs...)
+	s, err := OpenMapped(fname)
+	require.NoError(t, err)
+	defer s.Close()
+	require.True(t, s.Scan())
+
+	// logrotate: no SIGBUS, scan is stopped with error
+	require.NoError(t, os.Truncate(fname, 0))
+	for s.Scan() {
+	}
+	require.ErrorIs(t, s.Err(), ErrTruncated)
+}
+
+func BenchmarkOpenMapped(b *testing.B) {
+	fname := filepath.Join(b.TempDir(), "wtmp")
+	data := benchData(10000)
+	require.NoError(b, os.WriteFile(fname, data, 0644))
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s, _ := OpenMapped(fname)
+		for s.Scan() {
+		}
+		s.Close()
+	}
+}
+
+// EOF: "mmap_test.go"
@@ -0,0 +1,102 @@
+// File: "mux.go"
+
+package utmp
+
+import "fmt"
+
+// Обработка записей utmp, созданных терминальным мультиплексором
+// (каждое окно tmux/screen может создать свою запись pts).
+// Terminal multiplexer entries handling mode.
+type MuxMode int
+
+const (
+	MUX_KEEP     MuxMode = iota // keep entries as is (default)
+	MUX_TAG                     // set User.Multiplexed flag
+	MUX_COLLAPSE                // collapse entries into other session of user
+)
+
+// Названия режимов (для опций командной строки).
+var MuxModeStr = [...]string{"keep", "tag", "collapse"}
+
+// Получить режим по названию ("keep", "tag", "collapse").
+// Parse multiplexer entries handling mode.
+func ParseMuxMode(s string) (MuxMode, error) {
+	for i, name := range MuxModeStr {
+		if name == s {
+			return MuxMode(i), nil
+		}
+	}
+	return MUX_KEEP, fmt.Errorf("unknown multiplexer mode '%s'", s)
+}
+
+// Проверить, что запись создана терминальным мультиплексором:
+// командная строка процесса записи (utempter записывает PID самого
+// мультиплексора) или его родителя соответствует Config.Multiplexer.
+// Check user entry is created by terminal multiplexer.
+func (u *User) IsMultiplexed() bool {
+	if u.PID == 0 || u.Stale {
+		return false
+	}
+	d := curDetector.Load()
+	if d.isMux(u.PID) {
+		return true
+	}
+	p, err := GetProc(u.PID)
+	return err == nil && p.PPID > 1 && d.isMux(p.PPID)
+}
+
+// Проверить командную строку процесса на соответствие мультиплексору.
+func (d *detector) isMux(pid uint32) bool {
+	cmd, err := GetCmdline(pid)
+	if err != nil {
+		return false
+	}
+	for _, re := range d.mux {
+		if re.MatchString(cmd) {
+			return true
+		}
+	}
+	return false
+}
+
+// Пометить записи мультиплексоров (User.Multiplexed) и, в режиме
+// MUX_COLLAPSE, исключить их из списка, если у пользователя есть другой
+// сеанс (иначе остаётся самая ранняя запись мультиплексора - например,
+// сеанс screen, отсоединённый после выхода пользователя).
+// Tag or collapse terminal multiplexer entries.
+func (users Users) DetectMultiplexed(mode MuxMode) Users {
+	if mode == MUX_KEEP {
+		return users
+	}
+	for _, u := range users {
+		u.Multiplexed = u.IsMultiplexed()
+	}
+	if mode == MUX_COLLAPSE {
+		users = users.collapseMultiplexed()
+	}
+	return users
+}
+
+// Исключить помеченные записи мультиплексоров (порядок сохраняется).
+func (users Users) collapseMultiplexed() Users {
+	own := make(map[string]bool) // user has not multiplexed session
+	for _, u := range users {
+		if !u.Multiplexed {
+			own[u.Name] = true
+		}
+	}
+
+	res := make(Users, 0, len(users))
+	for _, u := range users {
+		if u.Multiplexed {
+			if own[u.Name] {
+				continue // collapse into other session
+			}
+			own[u.Name] = true // keep first one
+		}
+		res = append(res, u)
+	}
+	return res
+}
+
+// EOF: "mux.go"
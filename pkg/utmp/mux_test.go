@@ -0,0 +1,45 @@
+// File: "mux_test.go"
+
+package utmp
+
+import (
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMultiplexed(t *testing.T) {
+	defer SetConfig(DefaultConfig())
+
+	cmd := exec.Command("sleep", "10")
+	require.NoError(t, cmd.Start())
+	defer cmd.Process.Kill()
+	pid := uint32(cmd.Process.Pid)
+
+	users := Users{
+		{Name: "alice", TTY: "pts/0", PID: 1},
+		{Name: "alice", TTY: "pts/1", PID: pid},
+		{Name: "bob", TTY: "pts/2", PID: pid},
+		{Name: "bob", TTY: "pts/3", PID: pid},
+	}
+	require.False(t, users[1].IsMultiplexed())
+
+	require.NoError(t, SetConfig(Config{Multiplexer: []string{`^sleep `}}))
+	require.True(t, users[1].IsMultiplexed())
+
+	tagged := users.DetectMultiplexed(MUX_TAG)
+	require.Len(t, tagged, 4)
+	require.True(t, tagged[3].Multiplexed)
+
+	collapsed := users.DetectMultiplexed(MUX_COLLAPSE)
+	require.Len(t, collapsed, 2)
+	require.Equal(t, "pts/0", collapsed[0].TTY)
+	require.Equal(t, "pts/2", collapsed[1].TTY) // first one kept
+	require.Equal(t, 1, collapsed.GetUserLogin("bob").Logons)
+
+	_, err := ParseMuxMode("merge")
+	require.Error(t, err)
+}
+
+// EOF: "mux_test.go"
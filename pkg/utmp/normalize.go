@@ -0,0 +1,81 @@
+// File: "normalize.go"
+
+package utmp
+
+import (
+	"regexp"
+	"sync/atomic"
+)
+
+// Правило нормализации имени пользователя из конфигурации: имя,
+// соответствующее регулярному выражению Match, заменяется на Replace
+// (допустимы ссылки на подвыражения "$1", "${name}").
+// Username normalization rule from config.
+type UserMap struct {
+	Match   string `json:"match"`   // Username regexp
+	Replace string `json:"replace"` // Replacement (e.g. "$1")
+}
+
+// Скомпилированное правило нормализации.
+type userMap struct {
+	re      *regexp.Regexp
+	replace string
+}
+
+// Функция нормализации имени пользователя.
+// Username normalization callback.
+type UserNormalizer func(name string) string
+
+// Зарегистрированная функция нормализации.
+var normalizer atomic.Pointer[UserNormalizer]
+
+// Задать функцию нормализации имён пользователей (nil - использовать
+// правила из конфигурации, см. Config.UserMap). Функция вызывается до
+// агрегации (списки пользователей, статистика, сеансы), поэтому,
+// например, "alice", "DOMAIN\alice" и "alice@corp" могут считаться
+// одним пользователем.
+// Set username normalization callback (thread safe).
+func SetUserNormalizer(f UserNormalizer) {
+	if f == nil {
+		normalizer.Store(nil)
+	} else {
+		normalizer.Store(&f)
+	}
+}
+
+// Нормализовать имя пользователя: функция нормализации (если задана)
+// или первое совпавшее правило из конфигурации.
+// Normalize username.
+func NormalizeUser(name string) string {
+	if f := normalizer.Load(); f != nil {
+		return (*f)(name)
+	}
+	for _, m := range curDetector.Load().userMap {
+		if m.re.MatchString(name) {
+			return m.re.ReplaceAllString(name, m.replace)
+		}
+	}
+	return name
+}
+
+// Создать функцию нормализации с кэшем результатов (для чтения больших
+// wtmp файлов, не потокобезопасна).
+func newNormalizer() func(name string) string {
+	if normalizer.Load() == nil && len(curDetector.Load().userMap) == 0 {
+		return func(name string) string { return name } // nothing to do
+	}
+	cache := make(map[string]string)
+	return func(name string) string {
+		if n, ok := cache[name]; ok {
+			return n
+		}
+		if len(cache) >= INTERN_MAX {
+			clear(cache)
+		}
+		n := NormalizeUser(name)
+		cache[name] = n
+		return n
+	}
+}
+
+// EOF: "normalize.go"
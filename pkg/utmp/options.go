@@ -0,0 +1,132 @@
+// File: "options.go"
+
+package utmp
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// Опции чтения utmp/wtmp/btmp файла для GetUsersWith() и GetSessions().
+// Нулевое значение структуры соответствует поведению GetUsers() по умолчанию.
+// Options for GetUsersWith() and GetSessions().
+type GetUsersOpts struct {
+	UseEUID bool      // use EUID(PID) to get real username of local users
+	Since   time.Time // begin of time window (if not zero), see InWindow()
+	Until   time.Time // ignore records after this time (if not zero)
+	Rotated bool      // also read rotated files ("wtmp.1", "wtmp-YYYYMM.gz", ...)
+
+	// Использовать двоичный поиск первой записи >= Since (только для
+	// упорядоченных по времени wtmp/btmp, но не utmp); более ранние
+	// записи не читаются, поэтому сеансы, начатые до Since, теряются
+	SeekSince bool
+
+	// Пропускать дубликаты записей входа (см. Scanner.Dedup)
+	Dedup time.Duration
+
+	// Определять сеансы su/sudo по /proc (см. Users.DetectElevated())
+	Elevated bool
+
+	// Определять TCP соединения сеансов SSH по /proc (см. Users.DetectSSH())
+	SSH bool
+
+	// Определять время простоя сеансов по терминалам (см. Users.DetectIdle())
+	Idle bool
+
+	// Определять текущие команды сеансов (и деревья процессов) по /proc
+	// (см. Users.DetectWhat())
+	What bool
+	Tree bool
+
+	// Обработка записей tmux/screen (см. Users.DetectMultiplexed())
+	Mux MuxMode
+
+	// Проверять повторное использование PID устаревших записей по /proc
+	// (см. User.CheckStale()); выполняется всегда для UseEUID и опций,
+	// обращающихся к процессам сеансов (Elevated, SSH, What, Tree, Mux)
+	Stale bool
+
+	// Пропускать записи входа, сделанные до текущей загрузки системы
+	// (не очищенный при перезагрузке utmp), нет эффекта без /proc
+	CurrentBoot bool
+
+	// Автономный анализ файла с другого узла: не обращаться к /proc
+	// и к базе пользователей для этого вызова (см. SetOffline())
+	Offline bool
+}
+
+// Проверить попадание времени сеанса [login, logout] во временное окно
+// [Since, Until]: записи до Since разбираются только для восстановления
+// состояния (вошедшие к Since пользователи), записи после Until
+// пропускаются (нулевой logout - сеанс не завершён).
+// Check session overlaps time window.
+func (opts *GetUsersOpts) InWindow(login, logout time.Time) bool {
+	if !opts.Since.IsZero() && !logout.IsZero() && logout.Before(opts.Since) {
+		return false
+	}
+	if !opts.Until.IsZero() && login.After(opts.Until) {
+		return false
+	}
+	return true
+}
+
+// Проверить попадание времени записи во временное окно [Since, Until].
+// Check record time is inside of time window.
+func (opts *GetUsersOpts) InRange(t time.Time) bool {
+	if !opts.Since.IsZero() && t.Before(opts.Since) {
+		return false
+	}
+	if !opts.Until.IsZero() && t.After(opts.Until) {
+		return false
+	}
+	return true
+}
+
+// Проверять ли повторное использование PID записей (см. Stale).
+func (opts *GetUsersOpts) checkStale() bool {
+	return opts.Stale || opts.UseEUID || opts.Elevated || opts.SSH ||
+		opts.What || opts.Tree || opts.Mux != MUX_KEEP
+}
+
+// Открыть файл записей с учётом опций.
+// Open file of records according to options.
+func (opts *GetUsersOpts) Open(fname string) (io.ReadCloser, error) {
+	if opts.Rotated {
+		return OpenRotated(fname)
+	}
+
+	f, err := Open(fname)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.SeekSince && !opts.Since.IsZero() {
+		if rs, ok := f.(io.ReadSeeker); ok { // not compressed file
+			_, err = SeekToTime(rs, opts.Since)
+			if err != nil {
+				f.Close()
+				return nil, err
+			}
+		}
+	}
+	return f, nil
+}
+
+// Создать Scanner с учётом опций.
+// Create Scanner according to options.
+func (opts *GetUsersOpts) NewScanner(r io.Reader) *Scanner {
+	s := NewScanner(r)
+	s.Dedup = opts.Dedup
+	return s
+}
+
+// Создать Scanner с учётом опций, прерываемый отменой контекста.
+// Create Scanner according to options with cancellation.
+func (opts *GetUsersOpts) NewScannerContext(ctx context.Context, r io.Reader) *Scanner {
+	s := opts.NewScanner(r)
+	s.SetContext(ctx)
+	return s
+}
+
+// EOF: "options.go"
@@ -0,0 +1,72 @@
+// File: "overflow.go"
+
+package utmp
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// Поведение при переполнении канала событий C() (медленный получатель).
+// Event channel overflow policy.
+type Overflow int
+
+const (
+	OVERFLOW_BLOCK       Overflow = iota // wait for receiver (default)
+	OVERFLOW_DROP_OLDEST                 // drop oldest queued event
+	OVERFLOW_COALESCE                    // merge events while receiver is busy
+)
+
+// Названия режимов (для опций командной строки).
+var OverflowStr = [...]string{"block", "drop_oldest", "coalesce"}
+
+// Получить режим по названию ("block", "drop_oldest", "coalesce").
+// Parse overflow policy.
+func ParseOverflow(s string) (Overflow, error) {
+	for i, name := range OverflowStr {
+		if name == s {
+			return Overflow(i), nil
+		}
+	}
+	return OVERFLOW_BLOCK, fmt.Errorf("unknown overflow policy '%s'", s)
+}
+
+// Объединить событие `b` с более ранним неотправленным событием `a`:
+// входы/выходы суммируются (вход и последующий выход на том же
+// терминале взаимно исключаются и наоборот), типы входа объединяются,
+// состояние (Users, Stat, ...) берётся из последнего события. Списки и
+// карты событий общие для всех подписчиков и не изменяются.
+// Coalesce event b into earlier pending event a.
+func coalesce(a *LoginEvent, b LoginEvent) {
+	login, logout := slices.Clone(a.Login), slices.Clone(a.Logout)
+	for _, ut := range b.Login {
+		if i := slices.Index(logout, ut); i >= 0 {
+			logout = slices.Delete(logout, i, i+1) // logout + login
+		} else {
+			login = append(login, ut)
+		}
+	}
+	for _, ut := range b.Logout {
+		if i := slices.Index(login, ut); i >= 0 {
+			login = slices.Delete(login, i, i+1) // login + logout
+		} else {
+			logout = append(logout, ut)
+		}
+	}
+	coalesced := a.Coalesced + b.Coalesced + 1
+	resync := a.Resync || b.Resync
+	records := append(slices.Clip(a.Records), b.Records...)
+	change := a.Change.Merge(b.Change)
+	types := b.Types
+	if a.Types != nil {
+		types = make(map[UserTTY]LoginType, len(a.Types)+len(b.Types))
+		maps.Copy(types, a.Types)
+		maps.Copy(types, b.Types)
+	}
+	*a = b
+	a.Login, a.Logout, a.Coalesced, a.Resync = login, logout, coalesced, resync
+	a.Records, a.Change, a.Types = records, change, types
+}
+
+// EOF: "overflow.go"
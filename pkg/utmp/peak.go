@@ -0,0 +1,111 @@
+// File: "peak.go"
+
+package utmp
+
+import (
+	"sort"
+	"time"
+)
+
+// Пиковое число одновременно вошедших пользователей.
+// Peak of concurrent users.
+type Peak struct {
+	Users int       // Peak concurrent (distinct) users
+	Time  time.Time // First time of peak (zero if no sessions)
+}
+
+// Пиковая одновременность входов: общая и по типам входа.
+// Peak concurrency overall and by login type.
+type Concurrency struct {
+	Peak  Peak               // All sessions
+	Types map[LoginType]Peak // By login type (see Session.LoginType())
+}
+
+// Определить тип входа сеанса (см. Classify()), для завершённых сеансов
+// процессы не анализируются.
+// Classify session login type.
+func (s *Session) LoginType() LoginType {
+	u := User{
+		Name:    s.User,
+		PID:     s.PID,
+		TTY:     s.TTY,
+		Host:    s.Host,
+		IP:      s.IP,
+		SID:     s.SID,
+		ID:      s.ID,
+		Time:    s.Login,
+		offline: s.End != SESSION_ACTIVE || Offline()}
+	return Classify(&u)
+}
+
+// Вычислить максимальное число одновременно вошедших пользователей
+// (несколько сеансов одного пользователя считаются за одного) в периоде
+// [since, until] - общее и по типам входа (например для контроля лицензий
+// терминальных серверов). Сеансы обрезаются по периоду (нулевое since -
+// без ограничения, нулевое until - now), выход и вход в один момент
+// времени не пересекаются.
+// Compute peak concurrent users in period.
+func PeakConcurrency(sessions []Session, since, until time.Time) Concurrency {
+	if until.IsZero() {
+		until = time.Now()
+	}
+	type edge struct {
+		t    time.Time
+		user string
+		lt   LoginType
+		d    int // +1 login, -1 logout
+	}
+	edges := make([]edge, 0, 2*len(sessions))
+	for i := range sessions {
+		s := &sessions[i]
+		if from, to, ok := s.clip(since, until); ok {
+			lt := s.LoginType()
+			edges = append(edges, edge{from, s.User, lt, 1}, edge{to, s.User, lt, -1})
+		}
+	}
+	sort.SliceStable(edges, func(i, j int) bool {
+		if !edges[i].t.Equal(edges[j].t) {
+			return edges[i].t.Before(edges[j].t)
+		}
+		return edges[i].d < edges[j].d // logout before login at same time
+	})
+
+	c := Concurrency{Types: make(map[LoginType]Peak)}
+	type typeUser struct {
+		lt   LoginType
+		user string
+	}
+	logged := make(map[string]int)  // user -> sessions
+	typed := make(map[typeUser]int) // type, user -> sessions
+	cur := make(map[LoginType]int)  // type -> users
+	total := 0                      // users
+	for _, e := range edges {
+		tu := typeUser{e.lt, e.user}
+		logged[e.user] += e.d
+		typed[tu] += e.d
+		if e.d > 0 {
+			if logged[e.user] == 1 {
+				total++
+				if total > c.Peak.Users {
+					c.Peak = Peak{Users: total, Time: e.t}
+				}
+			}
+			if typed[tu] == 1 {
+				cur[e.lt]++
+				if cur[e.lt] > c.Types[e.lt].Users {
+					c.Types[e.lt] = Peak{Users: cur[e.lt], Time: e.t}
+				}
+			}
+		} else {
+			if logged[e.user] == 0 {
+				total--
+			}
+			if typed[tu] == 0 {
+				cur[e.lt]--
+			}
+		}
+	}
+	return c
+}
+
+// EOF: "peak.go"
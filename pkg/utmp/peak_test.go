@@ -0,0 +1,41 @@
+// File: "peak_test.go"
+
+package utmp
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPeakConcurrency(t *testing.T) {
+	at := func(sec int64) time.Time { return time.Unix(sec, 0) }
+	ip := net.ParseIP("192.0.2.7")
+	sessions := []Session{
+		{User: "alice", TTY: "pts/0", Host: "192.0.2.7", IP: ip, Login: at(100), Logout: at(400), End: SESSION_LOGOUT},
+		{User: "alice", TTY: "pts/1", Host: "192.0.2.7", IP: ip, Login: at(150), Logout: at(250), End: SESSION_LOGOUT},
+		{User: "bob", TTY: "pts/2", Host: "192.0.2.8", IP: net.ParseIP("192.0.2.8"), Login: at(200), Logout: at(300), End: SESSION_LOGOUT},
+		{User: "carol", TTY: "tty1", Login: at(300), Logout: at(500), End: SESSION_LOGOUT},
+		{User: "dave", TTY: "tty2", Login: at(350), Logout: at(450), End: SESSION_CRASH},
+		{User: "eve", TTY: "tty3", Login: at(600), End: SESSION_ACTIVE},
+	}
+
+	c := PeakConcurrency(sessions, time.Time{}, at(1000))
+	require.Equal(t, Peak{Users: 3, Time: at(350)}, c.Peak) // bob logged out at 300
+	require.Equal(t, Peak{Users: 2, Time: at(200)}, c.Types[REMOTE])
+	require.Equal(t, Peak{Users: 2, Time: at(350)}, c.Types[LOCAL])
+	require.NotContains(t, c.Types, LOCAL_X)
+
+	// Period
+	c = PeakConcurrency(sessions, at(420), at(1000))
+	require.Equal(t, Peak{Users: 2, Time: at(420)}, c.Peak)
+	c = PeakConcurrency(sessions, at(2000), at(3000))
+	require.Equal(t, Peak{Users: 1, Time: at(2000)}, c.Peak) // active session
+	c = PeakConcurrency(nil, time.Time{}, time.Time{})
+	require.Equal(t, Peak{}, c.Peak)
+	require.Empty(t, c.Types)
+}
+
+// EOF: "peak_test.go"
@@ -0,0 +1,122 @@
+// File: "perf.go"
+
+package utmp
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Статистика производительности: скорость разбора записей, попадания
+// в таблицы строк (Interner), задержки обращений к /proc и к базе
+// пользователей. Собирается только после EnablePerfStat(true).
+// Performance statistics.
+type PerfStat struct {
+	Records int64 // Parsed records
+	Bytes   int64 // Parsed bytes (uncompressed)
+
+	InternHits   int64 // Interner lookups of already seen strings
+	InternMisses int64 // Interner lookups of new strings
+
+	ProcCalls int64         // /proc lookups (EUID, cmdline, environ, ...)
+	ProcTime  time.Duration // Total time of /proc lookups
+
+	UserCalls int64         // User database lookups (os/user)
+	UserTime  time.Duration // Total time of user database lookups
+
+	UserCacheHits   int64 // User info cache hits
+	UserCacheMisses int64 // User info cache misses
+}
+
+// Счётчики производительности.
+var perf struct {
+	on                       atomic.Bool
+	records                  atomic.Int64
+	internHits, internMisses atomic.Int64
+	procCalls, procTime      atomic.Int64
+	userCalls, userTime      atomic.Int64
+	userHits, userMisses     atomic.Int64
+}
+
+// Включить/выключить сбор статистики производительности.
+// Enable/disable performance statistics.
+func EnablePerfStat(on bool) {
+	perf.on.Store(on)
+}
+
+// Получить статистику производительности (с момента запуска или
+// вызова ResetPerfStat()).
+// Get performance statistics.
+func GetPerfStat() PerfStat {
+	records := perf.records.Load()
+	return PerfStat{
+		Records:      records,
+		Bytes:        records * RECORD_SIZE,
+		InternHits:   perf.internHits.Load(),
+		InternMisses: perf.internMisses.Load(),
+		ProcCalls:    perf.procCalls.Load(),
+		ProcTime:     time.Duration(perf.procTime.Load()),
+		UserCalls:    perf.userCalls.Load(),
+		UserTime:     time.Duration(perf.userTime.Load()),
+
+		UserCacheHits:   perf.userHits.Load(),
+		UserCacheMisses: perf.userMisses.Load()}
+}
+
+// Сбросить статистику производительности.
+// Reset performance statistics.
+func ResetPerfStat() {
+	for _, c := range []*atomic.Int64{
+		&perf.records, &perf.internHits, &perf.internMisses,
+		&perf.procCalls, &perf.procTime, &perf.userCalls, &perf.userTime,
+		&perf.userHits, &perf.userMisses} {
+		c.Store(0)
+	}
+}
+
+// Доля попаданий в таблицы строк (0...1).
+// Interner hit rate.
+func (p PerfStat) InternHitRate() float64 {
+	if n := p.InternHits + p.InternMisses; n != 0 {
+		return float64(p.InternHits) / float64(n)
+	}
+	return 0
+}
+
+// Доля попаданий в кэш информации о пользователях (0...1).
+// User info cache hit rate.
+func (p PerfStat) UserCacheHitRate() float64 {
+	if n := p.UserCacheHits + p.UserCacheMisses; n != 0 {
+		return float64(p.UserCacheHits) / float64(n)
+	}
+	return 0
+}
+
+// Учесть обращение к /proc (вызывать как `defer perfProc(time.Now())`).
+func perfProc(t0 time.Time) {
+	if perf.on.Load() {
+		perf.procCalls.Add(1)
+		perf.procTime.Add(int64(time.Since(t0)))
+	}
+}
+
+// Учесть обращение к базе пользователей.
+func perfUser(t0 time.Time) {
+	if perf.on.Load() {
+		perf.userCalls.Add(1)
+		perf.userTime.Add(int64(time.Since(t0)))
+	}
+}
+
+// Учесть обращение к кэшу информации о пользователях.
+func perfUserCache(hit bool) {
+	if perf.on.Load() {
+		if hit {
+			perf.userHits.Add(1)
+		} else {
+			perf.userMisses.Add(1)
+		}
+	}
+}
+
+// EOF: "perf.go"
@@ -0,0 +1,177 @@
+// File: "policy.go"
+
+package utmp
+
+import (
+	"fmt"
+	"slices"
+	"sync/atomic"
+)
+
+// Кандидат в активные пользователи (запись utmp и тип входа).
+// Active user candidate.
+type Candidate struct {
+	*User
+	Type       LoginType // Login type
+	Privileged bool      // Privileged user (see IsPrivileged())
+}
+
+// Правило выбора активного (основного) пользователя узла среди
+// вошедших пользователей (см. LoginStat.Active).
+// Active user selection policy.
+type ActivePolicy interface {
+	// Выбрать активного пользователя (nil - нет активного пользователя),
+	// кандидаты упорядочены по времени входа
+	Select(c []Candidate) *User
+}
+
+// Функция выбора активного пользователя.
+// Active user selection callback.
+type ActivePolicyFunc func(c []Candidate) *User
+
+func (f ActivePolicyFunc) Select(c []Candidate) *User { return f(c) }
+
+// Встроенные правила выбора активного пользователя.
+// Built-in active user selection policies.
+const (
+	POLICY_DEFAULT = iota // max login type, privileged users demoted
+	POLICY_SEAT0          // prefer local seat0 user (see User.Seat())
+	POLICY_RECENT         // prefer most recent login (privileged demoted)
+)
+
+// Названия правил (для файла конфигурации).
+var ActivePolicyStr = [...]string{"default", "seat0", "recent"}
+
+// Встроенные правила.
+var activePolicies = [...]ActivePolicy{
+	ActivePolicyFunc(selectDefault),
+	ActivePolicyFunc(selectSeat0),
+	ActivePolicyFunc(selectRecent)}
+
+// Получить встроенное правило по названию ("default", "seat0", "recent").
+// Parse built-in active user selection policy.
+func ParseActivePolicy(s string) (ActivePolicy, error) {
+	for i, name := range ActivePolicyStr {
+		if name == s {
+			return activePolicies[i], nil
+		}
+	}
+	return nil, fmt.Errorf("unknown active policy '%s'", s)
+}
+
+// Правило выбора по умолчанию (см. POLICY_DEFAULT).
+// Default active user selection policy.
+func DefaultActivePolicy() ActivePolicy { return activePolicies[POLICY_DEFAULT] }
+
+// Правило выбора: первый вошедший пользователь из списка names
+// (киоски, выделенные операторы), иначе - правило fallback
+// (nil - правило по умолчанию).
+// Prefer configured users policy.
+func PreferUsers(fallback ActivePolicy, names ...string) ActivePolicy {
+	if fallback == nil {
+		fallback = DefaultActivePolicy()
+	}
+	names = slices.Clone(names)
+	return ActivePolicyFunc(func(c []Candidate) *User {
+		for _, name := range names {
+			// most recent session of preferred user
+			for i := len(c) - 1; i >= 0; i-- {
+				if c[i].Name == name {
+					return c[i].User
+				}
+			}
+		}
+		return fallback.Select(c)
+	})
+}
+
+// Заданное правило выбора (атомарно заменяемое).
+var activePolicy atomic.Pointer[ActivePolicy]
+
+// Задать правило выбора активного пользователя (nil - правило из
+// конфигурации, см. Config.ActivePolicy и Config.ActiveUsers).
+// Set active user selection policy (thread safe).
+func SetActivePolicy(p ActivePolicy) {
+	if p == nil {
+		activePolicy.Store(nil)
+	} else {
+		activePolicy.Store(&p)
+	}
+}
+
+// Текущее правило выбора активного пользователя.
+func currentActivePolicy() ActivePolicy {
+	if p := activePolicy.Load(); p != nil {
+		return *p
+	}
+	return curDetector.Load().active
+}
+
+// Скомпилировать правило выбора из конфигурации.
+func compileActivePolicy(c Config) (ActivePolicy, error) {
+	p := DefaultActivePolicy()
+	if c.ActivePolicy != "" {
+		var err error
+		p, err = ParseActivePolicy(c.ActivePolicy)
+		if err != nil {
+			return nil, fmt.Errorf("active_policy: %w", err)
+		}
+	}
+	if len(c.ActiveUsers) != 0 {
+		p = PreferUsers(p, c.ActiveUsers...)
+	}
+	return p, nil
+}
+
+// Максимальный тип входа, привилегированные пользователи (root)
+// выбираются только если нет других пользователей (или если
+// привилегированный пользователь вошёл с более "сильным" типом входа).
+func selectDefault(c []Candidate) *User {
+	var best *Candidate
+	for i, u := range c {
+		if u.Privileged {
+			if best == nil || best.Privileged {
+				best = &c[i]
+			}
+		} else if best == nil || best.Type <= u.Type {
+			best = &c[i]
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	return best.User
+}
+
+// Локальный пользователь рабочего места SEAT0 (X сеанс предпочтительнее
+// текстовой консоли, см. User.Seat()), иначе - правило по умолчанию.
+func selectSeat0(c []Candidate) *User {
+	seat0 := make([]Candidate, 0, len(c))
+	for _, u := range c {
+		if u.seat(u.Type) == SEAT0 {
+			seat0 = append(seat0, u)
+		}
+	}
+	if len(seat0) != 0 {
+		return selectDefault(seat0)
+	}
+	return selectDefault(c)
+}
+
+// Последний вошедший пользователь (привилегированные - только если нет
+// других).
+func selectRecent(c []Candidate) *User {
+	var best *Candidate
+	for i, u := range c {
+		if best == nil || best.Privileged || !u.Privileged &&
+			!u.Time.Before(best.Time) {
+			best = &c[i]
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	return best.User
+}
+
+// EOF: "policy.go"
@@ -0,0 +1,112 @@
+// File: "policy_test.go"
+
+package utmp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestActivePolicy(t *testing.T) {
+	defer SetActivePolicy(nil)
+	defer SetConfig(DefaultConfig())
+
+	t0 := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	console := &User{Name: "alice", TTY: "tty1", Time: t0}
+	seat1 := &User{Name: "bob", TTY: ":1", Time: t0.Add(time.Minute)}
+	kiosk := &User{Name: "kiosk", TTY: "pts/0", Time: t0.Add(2 * time.Minute)}
+	root := &User{Name: "root", TTY: "tty2", Time: t0.Add(3 * time.Minute)}
+	c := []Candidate{
+		{console, LOCAL, false},
+		{seat1, LOCAL_X, false},
+		{kiosk, REMOTE, false},
+		{root, LOCAL, true}}
+
+	p := DefaultActivePolicy()
+	require.Equal(t, seat1, p.Select(c))
+	require.Equal(t, root, p.Select(c[3:]))
+	require.Nil(t, p.Select(nil))
+
+	p, err := ParseActivePolicy("seat0")
+	require.NoError(t, err)
+	require.Equal(t, console, p.Select(c))
+	require.Equal(t, kiosk, p.Select(c[2:3]))
+
+	p, err = ParseActivePolicy("recent")
+	require.NoError(t, err)
+	require.Equal(t, kiosk, p.Select(c))
+
+	_, err = ParseActivePolicy("oldest")
+	require.Error(t, err)
+
+	p = PreferUsers(nil, "nobody", "kiosk")
+	require.Equal(t, kiosk, p.Select(c))
+	require.Equal(t, seat1, p.Select(c[:2]))
+
+	// policy from config, SetActivePolicy() takes precedence
+	require.NoError(t, SetConfig(Config{ActivePolicy: "seat0"}))
+	require.Equal(t, console, currentActivePolicy().Select(c))
+	require.NoError(t, SetConfig(Config{ActiveUsers: []string{"kiosk"}}))
+	require.Equal(t, kiosk, currentActivePolicy().Select(c))
+	require.Error(t, SetConfig(Config{ActivePolicy: "oldest"}))
+	SetActivePolicy(ActivePolicyFunc(func([]Candidate) *User { return nil }))
+	require.Nil(t, currentActivePolicy().Select(c))
+}
+
+func TestSeats(t *testing.T) {
+	users := Users{
+		{Name: "alice", TTY: ":0", offline: true},
+		{Name: "bob", TTY: ":1", offline: true},
+		{Name: "carol", TTY: "tty2", offline: true},
+		{Name: "dave", TTY: "pts/0", Host: "10.0.0.5", offline: true}}
+	require.Equal(t, SEAT0, users[0].Seat())
+	require.Equal(t, ":1", users[1].Seat())
+	require.Equal(t, "", users[3].Seat())
+
+	info := func(name string) (*LoginInfo, error) {
+		return &LoginInfo{UserInfo: UserInfo{Name: name}}, nil
+	}
+	stat := users.loginStat(info)
+	require.Len(t, stat.Seats, 2)
+	require.Equal(t, "alice", stat.Seats[SEAT0].Name)
+	require.Equal(t, "bob", stat.Seats[":1"].Name)
+	require.Nil(t, users[3:].loginStat(info).Seats)
+
+	// user lists by category
+	require.Equal(t, []string{"alice", "bob"}, stat.LocalXUsers)
+	require.Equal(t, []string{"carol"}, stat.LocalUsers)
+	require.Equal(t, []string{"dave"}, stat.RemoteUsers)
+	require.Nil(t, stat.RemoteXUsers)
+	require.Equal(t, map[string]int{"alice": 1, "bob": 1, "carol": 1, "dave": 1}, stat.Logons)
+}
+
+func TestPrivileged(t *testing.T) {
+	defer SetConfig(DefaultConfig())
+
+	users := Users{
+		{Name: "admin", TTY: "tty1", offline: true},
+		{Name: "bob", TTY: "tty2", offline: true}}
+	info := func(name string) (*LoginInfo, error) {
+		return &LoginInfo{UserInfo: UserInfo{Name: name}}, nil
+	}
+	require.True(t, IsPrivileged("root"))
+	require.False(t, IsPrivileged("admin"))
+	stat := users.loginStat(info)
+	require.False(t, stat.LocalRoot)
+	require.Equal(t, 2, stat.Local)
+	require.Equal(t, "bob", stat.Active.Name)
+
+	require.NoError(t, SetConfig(Config{Privileged: []string{"^(root|admin)$"}}))
+	require.True(t, IsPrivileged("admin"))
+	stat = users.loginStat(info)
+	require.True(t, stat.LocalRoot)
+	require.Equal(t, 1, stat.Local)
+	require.Equal(t, "bob", stat.Active.Name) // privileged user demoted
+	stat = users[:1].loginStat(info)
+	require.Equal(t, "admin", stat.Active.Name)
+	require.Error(t, SetConfig(Config{Privileged: []string{"("}}))
+}
+
+// EOF: "policy_test.go"
@@ -0,0 +1,49 @@
+// File: "privileged.go"
+
+package utmp
+
+import (
+	"slices"
+	"strings"
+)
+
+// Регулярное выражение имён привилегированных пользователей по умолчанию.
+// Default privileged users regexp.
+const PRIVILEGED_USER = "^root$"
+
+// Проверить, является ли пользователь привилегированным: имя соответствует
+// Config.Privileged (по умолчанию только root) или пользователь состоит
+// в одной из групп Config.PrivilegedGroups (wheel, sudo, ...).
+// Привилегированные пользователи учитываются в LoginStat флагами
+// LocalRoot/RemoteRoot, а не в счётчиках обычных пользователей.
+// Check user is privileged (root, admin, wheel members, ...).
+func IsPrivileged(name string) bool {
+	return isPrivileged(name, Offline())
+}
+
+// Проверить, является ли пользователь привилегированным (offline - без
+// проверки групп по базе пользователей).
+func isPrivileged(name string, offline bool) bool {
+	d := curDetector.Load()
+	for _, re := range d.priv {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	if len(d.conf.PrivilegedGroups) == 0 || currentUserDB(offline) == nil {
+		return false
+	}
+
+	u, err := cachedUserInfo(name)
+	if err != nil {
+		return false
+	}
+	for _, g := range strings.Split(u.Groups, ",") {
+		if slices.Contains(d.conf.PrivilegedGroups, g) {
+			return true
+		}
+	}
+	return false
+}
+
+// EOF: "privileged.go"
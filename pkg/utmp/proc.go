@@ -5,15 +5,51 @@ package utmp
 import (
 	"bufio"
 	"bytes"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 )
 
+// Ошибка обращения к /proc в режиме автономного анализа (см. SetOffline()).
+// /proc lookup is disabled by offline analysis mode.
+var ErrOffline = errors.New("offline analysis: /proc and user lookups are disabled")
+
+// Признак режима автономного анализа.
+var offline atomic.Bool
+
+// Включить/выключить режим автономного анализа скопированных с другого
+// узла файлов: обращения к /proc (EUID, командная строка и окружение
+// процессов, прослушиваемые порты) и к базе пользователей (os/user)
+// не выполняются и возвращают ErrOffline, поэтому X сеансы не уточняются
+// до REMOTE_X (XRDP/VNC/x2go), сеансы Wayland определяются как LOCAL,
+// опция UseEUID и поиск сеансов su/sudo не действуют, о пользователях
+// известны только имена. Признак отражается в LoginStat.Offline.
+// Для отдельного вызова см. GetUsersOpts.Offline.
+// Enable/disable offline analysis mode (no /proc and user lookups).
+func SetOffline(on bool) {
+	offline.Store(on)
+}
+
+// Проверить режим автономного анализа.
+// Check offline analysis mode.
+func Offline() bool {
+	return offline.Load()
+}
+
 // Получить эффективный User ID по Process ID.
 // Get EUID by PID.
 func GetEUID(pid uint32) (int, error) {
+	if Offline() {
+		return 0, ErrOffline
+	}
+	defer perfProc(time.Now())
 	status := fmt.Sprintf("/proc/%d/status", pid)
 	file, err := os.Open(status)
 	if err != nil {
@@ -37,6 +73,10 @@ func GetEUID(pid uint32) (int, error) {
 // Получить строку запуска процесса по Process ID.
 // Get CmdLine by PID
 func GetCmdline(pid uint32) (string, error) {
+	if Offline() {
+		return "", ErrOffline
+	}
+	defer perfProc(time.Now())
 	file := fmt.Sprintf("/proc/%d/cmdline", pid)
 	cmd, err := os.ReadFile(file)
 	if err != nil {
@@ -47,4 +87,262 @@ func GetCmdline(pid uint32) (string, error) {
 	return string(cmd), nil
 }
 
+// Получить значение переменной окружения процесса по Process ID
+// (ok=false, если переменная не задана).
+// Get environment variable of process by PID.
+func GetEnv(pid uint32, name string) (value string, ok bool, err error) {
+	if Offline() {
+		return "", false, ErrOffline
+	}
+	defer perfProc(time.Now())
+	file := fmt.Sprintf("/proc/%d/environ", pid)
+	env, err := os.ReadFile(file)
+	if err != nil {
+		return "", false, err
+	}
+	prefix := name + "="
+	for _, kv := range strings.Split(string(env), "\x00") {
+		if v, found := strings.CutPrefix(kv, prefix); found {
+			return v, true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// Получить множество прослушиваемых TCP портов (IPv4 и IPv6)
+// из /proc/net/tcp и /proc/net/tcp6.
+// Get set of listening TCP ports.
+func GetListenPorts() (map[int]bool, error) {
+	if Offline() {
+		return nil, ErrOffline
+	}
+	defer perfProc(time.Now())
+	ports := make(map[int]bool)
+	for i, fname := range []string{"/proc/net/tcp", "/proc/net/tcp6"} {
+		file, err := os.Open(fname)
+		if err != nil {
+			if i != 0 {
+				continue // IPv6 may be disabled
+			}
+			return nil, err
+		}
+
+		scanner := bufio.NewScanner(file)
+		scanner.Scan() // skip header
+		for scanner.Scan() {
+			// line: "sl local_address rem_address st ..."
+			fds := strings.Fields(scanner.Text())
+			if len(fds) < 4 || fds[3] != "0A" { // TCP_LISTEN
+				continue
+			}
+			_, hex, ok := strings.Cut(fds[1], ":")
+			if !ok {
+				continue
+			}
+			port, err := strconv.ParseUint(hex, 16, 16)
+			if err == nil {
+				ports[int(port)] = true
+			}
+		}
+		file.Close()
+	}
+	return ports, nil
+}
+
+// Получить время простоя терминала по времени последнего доступа
+// к устройству (как в утилите `w`), например для "pts/3".
+// Get TTY idle time by device access time.
+func GetTTYIdle(tty string) (time.Duration, error) {
+	dev := filepath.Join("/dev", tty)
+	var st syscall.Stat_t
+	err := syscall.Stat(dev, &st)
+	if err != nil {
+		return 0, &os.PathError{Op: "stat", Path: dev, Err: err}
+	}
+	atime := time.Unix(st.Atim.Sec, st.Atim.Nsec)
+	idle := time.Since(atime)
+	if idle < 0 {
+		idle = 0
+	}
+	return idle, nil
+}
+
+// Частота системного таймера для /proc/<pid>/stat (USER_HZ, постоянна
+// для ABI Linux).
+// Clock ticks per second in /proc/<pid>/stat.
+const CLK_TCK = 100
+
+// Время загрузки системы из /proc/stat (читается один раз).
+var bootTime = sync.OnceValues(func() (time.Time, error) {
+	file, err := os.Open("/proc/stat")
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// line: "btime 1700000000"
+		fds := strings.Fields(scanner.Text())
+		if len(fds) == 2 && fds[0] == "btime" {
+			sec, err := strconv.ParseInt(fds[1], 10, 64)
+			if err != nil {
+				return time.Time{}, fmt.Errorf("bad btime in %s: %w", file.Name(), err)
+			}
+			return time.Unix(sec, 0), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf(`can't find "^btime " in %s`, file.Name())
+})
+
+// Получить время загрузки системы (btime из /proc/stat, точность 1 с).
+// Get system boot time.
+func GetBootTime() (time.Time, error) {
+	if Offline() {
+		return time.Time{}, ErrOffline
+	}
+	return bootTime()
+}
+
+// Получить идентификатор текущей загрузки системы (UUID, меняется при
+// каждой загрузке) из /proc/sys/kernel/random/boot_id.
+// Get current boot ID.
+func GetBootID() (string, error) {
+	if Offline() {
+		return "", ErrOffline
+	}
+	data, err := os.ReadFile("/proc/sys/kernel/random/boot_id")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// Получить время запуска процесса по Process ID (точность - 1/CLK_TCK с,
+// время загрузки системы - 1 с).
+// Get process start time by PID.
+func GetProcStart(pid uint32) (time.Time, error) {
+	if Offline() {
+		return time.Time{}, ErrOffline
+	}
+	defer perfProc(time.Now())
+	file := fmt.Sprintf("/proc/%d/stat", pid)
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	// man proc, look /proc/pid/stat: "pid (comm) state ppid ... starttime ..."
+	// comm may contain spaces and ')', so split after last ')'
+	i := bytes.LastIndexByte(data, ')')
+	fds := strings.Fields(string(data[i+1:]))
+	if i < 0 || len(fds) < 20 {
+		return time.Time{}, fmt.Errorf("bad format of %s", file)
+	}
+	ticks, err := strconv.ParseUint(fds[19], 10, 64) // field 22
+	if err != nil {
+		return time.Time{}, fmt.Errorf("bad starttime in %s: %w", file, err)
+	}
+
+	boot, err := bootTime()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return boot.Add(time.Duration(ticks) * time.Second / CLK_TCK), nil
+}
+
+// Сведения о процессе для определения сеансов su/sudo.
+// Process credentials.
+type Proc struct {
+	PID      uint32 // Process ID
+	PPID     uint32 // Parent process ID
+	EUID     int    // Effective user ID
+	LoginUID int    // Audit login user ID (-1 if unset)
+}
+
+// Получить login UID процесса (неизменяемый UID пользователя, вошедшего
+// в систему, наследуется через su/sudo) из /proc/<pid>/loginuid.
+// Возвращает -1, если login UID не установлен.
+// Get audit login UID by PID.
+func GetLoginUID(pid uint32) (int, error) {
+	if Offline() {
+		return -1, ErrOffline
+	}
+	defer perfProc(time.Now())
+	return getLoginUID(pid)
+}
+
+func getLoginUID(pid uint32) (int, error) {
+	file := fmt.Sprintf("/proc/%d/loginuid", pid)
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return -1, err
+	}
+	uid, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 32)
+	if err != nil {
+		return -1, fmt.Errorf("bad loginuid in %s: %w", file, err)
+	}
+	if uid == 0xFFFFFFFF { // (uid_t)-1
+		return -1, nil
+	}
+	return int(uid), nil
+}
+
+// Получить сведения о процессе по Process ID.
+// Get process credentials by PID.
+func GetProc(pid uint32) (Proc, error) {
+	p := Proc{PID: pid, EUID: -1, LoginUID: -1}
+	if Offline() {
+		return p, ErrOffline
+	}
+	defer perfProc(time.Now())
+	file, err := os.Open(fmt.Sprintf("/proc/%d/status", pid))
+	if err != nil {
+		return p, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fds := strings.Fields(scanner.Text())
+		if len(fds) >= 2 && fds[0] == "PPid:" {
+			ppid, _ := strconv.ParseUint(fds[1], 10, 32)
+			p.PPID = uint32(ppid)
+		} else if len(fds) >= 5 && fds[0] == "Uid:" {
+			p.EUID, _ = strconv.Atoi(fds[2])
+		}
+	}
+	if p.EUID < 0 {
+		return p, fmt.Errorf(`can't find "^Uid: " in %s`, file.Name())
+	}
+
+	p.LoginUID, _ = getLoginUID(pid)
+	return p, nil
+}
+
+// Получить сведения обо всех процессах системы (процессы, завершившиеся
+// во время обхода /proc, пропускаются).
+// Get credentials of all processes.
+func GetProcs() ([]Proc, error) {
+	if Offline() {
+		return nil, ErrOffline
+	}
+	dir, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+	procs := make([]Proc, 0, len(dir))
+	for _, e := range dir {
+		pid, err := strconv.ParseUint(e.Name(), 10, 32)
+		if err != nil || !e.IsDir() {
+			continue // not a process
+		}
+		p, err := GetProc(uint32(pid))
+		if err == nil {
+			procs = append(procs, p)
+		}
+	}
+	return procs, nil
+}
+
 // EOF: "proc.go"
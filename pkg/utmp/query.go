@@ -0,0 +1,158 @@
+// File: "query.go"
+
+package utmp
+
+import (
+	"fmt"
+	"net"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Выбрать пользователей, для которых f возвращает true (записи не
+// копируются, исходный список не изменяется).
+// Filter users by predicate.
+func (users Users) Filter(f func(u *User) bool) Users {
+	res := make(Users, 0, len(users))
+	for _, u := range users {
+		if f(u) {
+			res = append(res, u)
+		}
+	}
+	return res
+}
+
+// Выбрать пользователей с заданными именами.
+// Filter users by names.
+func (users Users) FilterByName(names ...string) Users {
+	return users.Filter(func(u *User) bool {
+		return slices.Contains(names, u.Name)
+	})
+}
+
+// Выбрать пользователей с заданными типами входа.
+// Filter users by login types.
+func (users Users) FilterByType(types ...LoginType) Users {
+	return users.Filter(func(u *User) bool {
+		return slices.Contains(types, u.LoginType())
+	})
+}
+
+// Выбрать пользователей, вошедших с заданных узлов (поле Host).
+// Filter users by remote hosts.
+func (users Users) FilterByHost(hosts ...string) Users {
+	return users.Filter(func(u *User) bool {
+		return slices.Contains(hosts, u.Host)
+	})
+}
+
+// Выбрать пользователей, вошедших из заданных сетей (поле IP).
+// Filter users by remote networks.
+func (users Users) FilterByNet(nets ...*net.IPNet) Users {
+	return users.Filter(func(u *User) bool {
+		if u.IP == nil {
+			return false
+		}
+		return slices.ContainsFunc(nets, func(n *net.IPNet) bool {
+			return n.Contains(u.IP)
+		})
+	})
+}
+
+// Выбрать пользователей по шаблонам терминала ("pts/*", "tty[1-6]",
+// ":0", см. path.Match).
+// Filter users by TTY patterns.
+func (users Users) FilterByTTY(patterns ...string) Users {
+	return users.Filter(func(u *User) bool {
+		return slices.ContainsFunc(patterns, func(p string) bool {
+			ok, _ := path.Match(p, u.TTY)
+			return ok
+		})
+	})
+}
+
+// Выбрать пользователей, вошедших не ранее t.
+// Filter users logged in since t.
+func (users Users) Since(t time.Time) Users {
+	return users.Filter(func(u *User) bool {
+		return !u.Time.Before(t)
+	})
+}
+
+// Получить отсортированный список имён пользователей (без повторов).
+// Get sorted unique usernames.
+func (users Users) Names() []string {
+	names := make([]string, 0, len(users))
+	for _, u := range users {
+		names = append(names, u.Name)
+	}
+	slices.Sort(names)
+	return slices.Compact(names)
+}
+
+// Сгруппировать записи по именам пользователей.
+// Group users by name.
+func (users Users) GroupByUser() map[string]Users {
+	groups := make(map[string]Users)
+	for _, u := range users {
+		groups[u.Name] = append(groups[u.Name], u)
+	}
+	return groups
+}
+
+// Поле сортировки списка пользователей.
+// Users sort field.
+type SortField int
+
+const (
+	SORT_TIME SortField = iota // login time (default)
+	SORT_NAME                  // username
+	SORT_TTY                   // TTY device
+	SORT_HOST                  // remote host
+	SORT_PID                   // process ID
+	SORT_TYPE                  // login type
+)
+
+// Названия полей сортировки (для опций командной строки).
+var SortFieldStr = [...]string{"time", "name", "tty", "host", "pid", "type"}
+
+// Получить поле сортировки по названию ("time", "name", "tty", ...).
+// Parse users sort field.
+func ParseSortField(s string) (SortField, error) {
+	for i, name := range SortFieldStr {
+		if name == s {
+			return SortField(i), nil
+		}
+	}
+	return SORT_TIME, fmt.Errorf("unknown sort field '%s'", s)
+}
+
+// Получить копию списка, отсортированную по полю field (порядок записей
+// с равными значениями поля сохраняется).
+// Get users sorted by field.
+func (users Users) SortBy(field SortField) Users {
+	res := slices.Clone(users)
+	var cmp func(a, b *User) int
+	switch field {
+	case SORT_NAME:
+		cmp = func(a, b *User) int { return strings.Compare(a.Name, b.Name) }
+	case SORT_TTY:
+		cmp = func(a, b *User) int { return strings.Compare(a.TTY, b.TTY) }
+	case SORT_HOST:
+		cmp = func(a, b *User) int { return strings.Compare(a.Host, b.Host) }
+	case SORT_PID:
+		cmp = func(a, b *User) int { return int(a.PID) - int(b.PID) }
+	case SORT_TYPE:
+		cmp = func(a, b *User) int { return int(a.LoginType()) - int(b.LoginType()) }
+	default: // SORT_TIME
+		sort.Stable(UsersByTime(res))
+		return res
+	}
+	slices.SortStableFunc(res, cmp)
+	return res
+}
+
+// EOF: "query.go"
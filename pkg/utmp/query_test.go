@@ -0,0 +1,47 @@
+// File: "query_test.go"
+
+package utmp
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuery(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	alice := &User{Name: "alice", TTY: "tty1", PID: 30, Time: t0, offline: true}
+	bob := &User{Name: "bob", TTY: "pts/0", PID: 10, Host: "10.0.0.5",
+		IP: net.IPv4(10, 0, 0, 5), Time: t0.Add(time.Hour), offline: true}
+	bob2 := &User{Name: "bob", TTY: "pts/1", PID: 20, Host: "gw.example.com",
+		IP: net.IPv4(192, 168, 1, 7), Time: t0.Add(2 * time.Hour), offline: true}
+	users := Users{bob2, alice, bob}
+
+	require.Equal(t, Users{bob2, bob}, users.FilterByName("bob"))
+	require.Equal(t, Users{alice}, users.FilterByType(LOCAL))
+	require.Equal(t, Users{bob2}, users.FilterByHost("gw.example.com"))
+	_, lan, _ := net.ParseCIDR("10.0.0.0/8")
+	require.Equal(t, Users{bob}, users.FilterByNet(lan))
+	require.Equal(t, Users{bob2, bob}, users.FilterByTTY("pts/*"))
+	require.Equal(t, Users{bob2}, users.Since(t0.Add(90*time.Minute)))
+	require.Equal(t, Users{bob}, users.FilterByTTY("pts/*").Since(t0).FilterByNet(lan))
+	require.Empty(t, users.FilterByName())
+
+	require.Equal(t, []string{"alice", "bob"}, users.Names())
+	require.Equal(t, map[string]Users{"alice": {alice}, "bob": {bob2, bob}}, users.GroupByUser())
+
+	require.Equal(t, Users{alice, bob, bob2}, users.SortBy(SORT_TIME))
+	require.Equal(t, Users{alice, bob2, bob}, users.SortBy(SORT_NAME))
+	require.Equal(t, Users{bob, bob2, alice}, users.SortBy(SORT_PID))
+	require.Equal(t, Users{bob2, alice, bob}, users) // unchanged
+
+	f, err := ParseSortField("tty")
+	require.NoError(t, err)
+	require.Equal(t, Users{bob, bob2, alice}, users.SortBy(f))
+	_, err = ParseSortField("uid")
+	require.Error(t, err)
+}
+
+// EOF: "query_test.go"
@@ -0,0 +1,71 @@
+// File: "record.go"
+
+package utmp
+
+import (
+	"net"
+	"time"
+)
+
+// Структурированное (декодированное) представление записи `Utmp`.
+// Все поля записи сохраняются без потерь, в т.ч. для записей
+// ACCOUNTING (тип 9) из архивов Solaris и неизвестных типов.
+// Decoded Utmp record (lossless, including ACCOUNTING records).
+type Record struct {
+	Type        int       // Type of record (raw value)
+	TypeName    string    // Type of record as string
+	PID         uint32    // PID of process (0 for RUN_LVL)
+	RunLevel    string    // Run level (RUN_LVL only)
+	Line        string    // Device name of tty - "/dev/"
+	ID          string    // Terminal name suffix, or inittab ID
+	User        string    // Username
+	Host        string    // Hostname for remote login, or kernel version
+	IP          net.IP    // IPv4 address of remote host
+	AddrV6      [4]int32  // Raw IP address of remote host
+	Termination int16     // Process termination status
+	Exit        int16     // Process exit status
+	Session     int32     // Session ID
+	Time        time.Time // Time entry was made
+}
+
+// Тип записи в виде строки (в т.ч. для неизвестных типов).
+// Type of record as string (safe for unknown types).
+func TypeName(t int) string {
+	if t >= 0 && t < len(TypeString) {
+		return TypeString[t]
+	}
+	return "UNKNOWN"
+}
+
+// Декодировать запись `Utmp` в структуру `Record`.
+// Decode Utmp to Record.
+func (u *Utmp) Decode() Record {
+	return u.DecodeWith(nil)
+}
+
+// Декодировать запись `Utmp` в структуру `Record`, используя таблицу
+// повторяющихся строк (для чтения больших файлов).
+// Decode Utmp to Record with string interning.
+func (u *Utmp) DecodeWith(in *Interner) Record {
+	r := Record{
+		Type:        int(u.Type),
+		TypeName:    TypeName(int(u.Type)),
+		PID:         u.ProcessID(),
+		Line:        in.Str(u.Line[:]),
+		ID:          in.Str(u.ID[:]),
+		User:        in.Str(u.User[:]),
+		Host:        in.Str(u.Host[:]),
+		IP:          IPv4(u.AddrV6),
+		AddrV6:      u.AddrV6,
+		Termination: u.Exit.Termination,
+		Exit:        u.Exit.Exit,
+		Session:     u.Session,
+		Time:        Time(u.TV)}
+
+	if rl, ok := u.RunLevel(); ok {
+		r.RunLevel = RunLevelStr(rl)
+	}
+	return r
+}
+
+// EOF: "record.go"
@@ -0,0 +1,132 @@
+// File: "replay.go"
+
+package utmp
+
+import (
+	"time"
+)
+
+// Запрос восстановления событий из wtmp (см. Login.Replay()).
+type replayReq struct {
+	since time.Time  // начало интервала
+	err   chan error // результат
+}
+
+// Восстановить события входа/выхода из журнала wtmp (см. LoginOpts.Wtmp)
+// начиная с момента since и отправить их подписчикам по порядку через
+// обычные каналы событий с признаком LoginEvent.Historical (например,
+// после перезапуска службы мониторинга). Пока события отправляются,
+// изменения utmp файла не обрабатываются.
+// Replay login/logout events from wtmp since time.
+func (l *Login) Replay(since time.Time) error {
+	r := replayReq{since, make(chan error, 1)}
+	select {
+	case l.replay <- r:
+	case <-l.done:
+		return ErrClosed
+	}
+	select {
+	case err := <-r.err:
+		return err
+	case <-l.done:
+		return ErrClosed
+	}
+}
+
+// Восстановить события из wtmp (выполняется горутиной разбора).
+func (l *Login) replayWtmp(since time.Time) error {
+	f, err := Open(l.wtmp)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// Записи разбираются без /proc: процессы прошлых сеансов завершены
+	opts := GetUsersOpts{Offline: true}
+	b := newUserBase(opts, time.Time{})
+	types := make(ttyTypes) // logged users
+	primed := false         // types contains users logged in before since
+	s := NewScanner(f)
+	s.SkipEmpty = true
+	for s.Scan() {
+		u := s.Record()
+		switch u.Type {
+		case BOOT_TIME, USER_PROCESS, DEAD_PROCESS:
+		default:
+			continue
+		}
+		t := Time(u.TV)
+		if t.Before(since) {
+			b.add(u)
+			continue // restore state only
+		}
+		if !primed { // users logged in before since
+			for _, u := range b.users() {
+				types[UserTTY{u.Name, u.TTY}] = u.LoginType()
+			}
+			primed = true
+		}
+		b.add(u)
+
+		users := b.users()
+		evt := historicalEvent(t, users, types)
+		evt.Received = time.Now()
+		if l.raw {
+			evt.Records = []Utmp{*u}
+		}
+		if len(evt.Login)+len(evt.Logout) == 0 {
+			continue
+		}
+		select {
+		case l.parsed <- parsedUtmp{hist: &evt}:
+		case <-l.done:
+			return ErrClosed
+		}
+	}
+	return s.Err()
+}
+
+// Сформировать историческое событие: сравнить пользователей в системе
+// с прежними (logged обновляется).
+func historicalEvent(t time.Time, users Users, logged ttyTypes) LoginEvent {
+	evt := LoginEvent{
+		Time:       t,
+		Types:      make(map[UserTTY]LoginType),
+		Labels:     Labels(),
+		Historical: true}
+
+	now := make(ttyTypes, len(users))
+	for _, u := range users {
+		ut := UserTTY{u.Name, u.TTY}
+		now[ut] = u.LoginType()
+		if _, ok := logged[ut]; !ok {
+			logged[ut] = now[ut]
+			evt.Login = append(evt.Login, ut)
+			evt.Types[ut] = now[ut]
+		}
+	}
+	for ut, t := range logged {
+		if _, ok := now[ut]; !ok {
+			delete(logged, ut)
+			evt.Logout = append(evt.Logout, ut)
+			evt.Types[ut] = t
+		}
+	}
+
+	// Информация о пользователях - только имя и метрики (без обращения
+	// к базе пользователей)
+	info := func(name string) (*LoginInfo, error) {
+		return &LoginInfo{
+			UserInfo:  UserInfo{Name: name},
+			UserLogin: users.GetUserLogin(name)}, nil
+	}
+	evt.Users = []LoginInfo{}
+	for _, name := range users.Names() {
+		li, _ := info(name)
+		evt.Users = append(evt.Users, *li)
+	}
+	evt.Stat = users.loginStat(info)
+	return evt
+}
+
+// EOF: "replay.go"
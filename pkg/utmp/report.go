@@ -0,0 +1,113 @@
+// File: "report.go"
+
+package utmp
+
+import (
+	"sort"
+	"time"
+)
+
+// Число источников входа в отчёте по умолчанию.
+// Default number of top sources in usage report.
+const REPORT_TOP = 10
+
+// Опции отчёта об использовании.
+// Usage report options.
+type ReportOpts struct {
+	Since time.Time // начало периода (нулевое - вход первого сеанса)
+	Until time.Time // конец периода (нулевое - now)
+	Top   int       // число источников входа (0 - REPORT_TOP)
+}
+
+// Сеансы пользователя за период.
+// User sessions summary.
+type UserUsage struct {
+	User     string        // Username
+	Sessions int           // Number of sessions
+	Time     time.Duration // Connect time (clipped by period)
+}
+
+// Сводный отчёт об использовании системы за период.
+// Aggregate usage report.
+type UsageReport struct {
+	Since    time.Time          // Period begin (first login if not set)
+	Until    time.Time          // Period end
+	Sessions int                // Number of sessions in period
+	Time     time.Duration      // Total connect time
+	Users    []UserUsage        // Sessions per user (sorted by sessions)
+	Peak     int                // Peak concurrent users (see PeakConcurrency)
+	PeakTime time.Time          // First time of peak
+	PeakType map[LoginType]Peak // Peak by login type
+	Hours    [24]int            // Logins by hour of day (local time)
+	Sources  []SourceStat       // Top remote sources (see SourceReport)
+}
+
+// Построить сводный отчёт по сеансам, пересекающим период [Since, Until]:
+// сеансы и время подключения по пользователям, пиковое число одновременно
+// вошедших пользователей, гистограмма входов по часам суток и наиболее
+// частые источники удалённых входов. Активные сеансы считаются до Until
+// (или now).
+// Build usage report.
+func Report(sessions []Session, opts ReportOpts, now time.Time) UsageReport {
+	if opts.Top <= 0 {
+		opts.Top = REPORT_TOP
+	}
+	r := UsageReport{Since: opts.Since, Until: opts.Until}
+	if r.Until.IsZero() || r.Until.After(now) {
+		r.Until = now
+	}
+
+	var period []Session
+	users := make(map[string]*UserUsage)
+	for i := range sessions {
+		s := &sessions[i]
+		from, to, ok := s.clip(r.Since, r.Until)
+		if !ok {
+			continue // out of period
+		}
+		period = append(period, *s)
+		r.Sessions++
+		r.Time += to.Sub(from)
+
+		u := users[s.User]
+		if u == nil {
+			u = &UserUsage{User: s.User}
+			users[s.User] = u
+		}
+		u.Sessions++
+		u.Time += to.Sub(from)
+
+		if !s.Login.Before(from) { // login in period
+			r.Hours[s.Login.Local().Hour()]++
+		}
+	}
+	if r.Since.IsZero() && len(period) != 0 {
+		r.Since = period[0].Login
+		for i := range period {
+			if period[i].Login.Before(r.Since) {
+				r.Since = period[i].Login
+			}
+		}
+	}
+
+	for _, u := range users {
+		r.Users = append(r.Users, *u)
+	}
+	sort.Slice(r.Users, func(i, j int) bool {
+		if r.Users[i].Sessions != r.Users[j].Sessions {
+			return r.Users[i].Sessions > r.Users[j].Sessions
+		}
+		return r.Users[i].User < r.Users[j].User
+	})
+
+	peak := PeakConcurrency(period, r.Since, r.Until)
+	r.Peak, r.PeakTime, r.PeakType = peak.Peak.Users, peak.Peak.Time, peak.Types
+
+	r.Sources = SourceReport(period, SOURCE_BY_HOST)
+	if len(r.Sources) > opts.Top {
+		r.Sources = r.Sources[:opts.Top]
+	}
+	return r
+}
+
+// EOF: "report.go"
@@ -0,0 +1,54 @@
+// File: "report_test.go"
+
+package utmp
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReport(t *testing.T) {
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local)
+	at := func(h, m int) time.Time { return day.Add(time.Duration(h)*time.Hour + time.Duration(m)*time.Minute) }
+	ip := net.ParseIP("192.0.2.7")
+	sessions := []Session{
+		{User: "carol", Login: at(-3, 0), Logout: at(-2, 0), End: SESSION_LOGOUT}, // before period
+		{User: "alice", Login: at(-1, 0), Logout: at(1, 0), End: SESSION_LOGOUT},
+		{User: "alice", Host: "192.0.2.7", IP: ip, Login: at(9, 0), Logout: at(12, 0), End: SESSION_LOGOUT},
+		{User: "bob", Host: "192.0.2.7", IP: ip, Login: at(9, 30), Logout: at(10, 0), End: SESSION_LOGOUT},
+		{User: "alice", TTY: "tty1", Login: at(10, 0), Logout: at(11, 0), End: SESSION_LOGOUT},
+		{User: "bob", Host: "host.example.com", Login: at(10, 0), End: SESSION_ACTIVE},
+	}
+
+	r := Report(sessions, ReportOpts{Since: day, Top: 1}, at(12, 0))
+	require.Equal(t, day, r.Since)
+	require.Equal(t, at(12, 0), r.Until)
+	require.Equal(t, 5, r.Sessions)
+	require.Equal(t, 7*time.Hour+30*time.Minute, r.Time)
+	require.Equal(t, []UserUsage{
+		{User: "alice", Sessions: 3, Time: 5 * time.Hour},
+		{User: "bob", Sessions: 2, Time: 2*time.Hour + 30*time.Minute}}, r.Users)
+	require.Equal(t, 2, r.Peak) // alice twice is one user
+	require.Equal(t, at(9, 30), r.PeakTime)
+	require.Equal(t, 2, r.Hours[9])
+	require.Equal(t, 2, r.Hours[10])
+	require.Equal(t, 0, r.Hours[23]) // login before period
+	require.Len(t, r.Sources, 1)
+	require.Equal(t, "192.0.2.7", r.Sources[0].Source)
+	require.Equal(t, 2, r.Sources[0].Sessions)
+
+	// Logout and login at the same time is not concurrent
+	r = Report(sessions[2:4], ReportOpts{Until: at(12, 0)}, at(12, 0))
+	require.Equal(t, at(9, 0), r.Since)
+	require.Equal(t, 2, r.Peak)
+	r = Report([]Session{
+		{User: "alice", Login: at(1, 0), Logout: at(2, 0), End: SESSION_LOGOUT},
+		{User: "bob", Login: at(2, 0), Logout: at(3, 0), End: SESSION_LOGOUT}}, ReportOpts{}, at(4, 0))
+	require.Equal(t, 1, r.Peak)
+	require.Equal(t, at(1, 0), r.PeakTime)
+}
+
+// EOF: "report_test.go"
@@ -0,0 +1,66 @@
+// File: "resolve.go"
+
+package utmp
+
+import "sync"
+
+// Число одновременных запросов к базе пользователей при пакетном
+// получении информации о пользователях.
+// Max concurrent user info lookups.
+const RESOLVE_WORKERS = 8
+
+// Результат запроса информации о пользователе.
+type resolved struct {
+	info *UserInfo
+	err  error
+}
+
+// Получить информацию о пользователях пакетно: имена без повторов,
+// запросы (через кэш) выполняются параллельно не более чем в `workers`
+// горутинах (workers <= 0 - RESOLVE_WORKERS).
+func resolveUserInfo(names []string, workers int) map[string]resolved {
+	res := make(map[string]resolved, len(names))
+	uniq := make([]string, 0, len(names))
+	for _, name := range names {
+		if _, ok := res[name]; !ok {
+			res[name] = resolved{}
+			uniq = append(uniq, name)
+		}
+	}
+
+	if workers <= 0 {
+		workers = RESOLVE_WORKERS
+	}
+	workers = min(workers, len(uniq))
+	if workers <= 1 { // nothing to parallelize
+		for _, name := range uniq {
+			info, err := cachedUserInfo(name)
+			res[name] = resolved{info, err}
+		}
+		return res
+	}
+
+	jobs := make(chan string)
+	var mx sync.Mutex
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for name := range jobs {
+				info, err := cachedUserInfo(name)
+				mx.Lock()
+				res[name] = resolved{info, err}
+				mx.Unlock()
+			}
+		}()
+	}
+	for _, name := range uniq {
+		jobs <- name
+	}
+	close(jobs)
+	wg.Wait()
+	return res
+}
+
+// EOF: "resolve.go"
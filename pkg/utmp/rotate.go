@@ -0,0 +1,152 @@
+// File: "rotate.go"
+
+package utmp
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Суффиксы имён файлов после ротации: "wtmp.1", "wtmp.2.gz",
+// "wtmp-202401", "wtmp-20240101.xz", "wtmp-20240101.zst".
+// Suffix of rotated file names.
+var reRotated = regexp.MustCompile(`^(\.[0-9]+|-[0-9]{6,8})(\.gz|\.xz|\.zst)?$`)
+
+// Найти файлы, полученные ротацией файла `fname` (в том же каталоге),
+// и вернуть их список вместе с самим `fname` в хронологическом порядке
+// (по времени первой записи в файле).
+// Find rotated files of fname (result includes fname, oldest first).
+func FindRotated(fname string) ([]string, error) {
+	if fname == "" {
+		fname = DefaultFile
+	}
+
+	dir, base := filepath.Split(fname)
+	if dir == "" {
+		dir = "."
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	type file struct {
+		name string
+		time time.Time
+	}
+	files := []file{}
+
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, base) ||
+			!reRotated.MatchString(name[len(base):]) {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		t, err := firstRecordTime(path)
+		if err != nil {
+			continue // skip empty or unreadable files
+		}
+		files = append(files, file{path, t})
+	}
+
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].time.Before(files[j].time)
+	})
+
+	names := make([]string, 0, len(files)+1)
+	for _, f := range files {
+		names = append(names, f.name)
+	}
+	return append(names, fname), nil
+}
+
+// Получить время первой записи файла.
+func firstRecordTime(fname string) (time.Time, error) {
+	r, err := Open(fname)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer r.Close()
+
+	s := NewScanner(r)
+	if !s.Scan() {
+		if err = s.Err(); err == nil {
+			err = io.EOF
+		}
+		return time.Time{}, err
+	}
+	return Time(s.Record().TV), nil
+}
+
+// Открыть файл `fname` вместе с файлами ротации как единый поток записей
+// в хронологическом порядке. Неполная запись в конце каждого файла
+// отбрасывается, чтобы не нарушить выравнивание записей.
+// Open fname and its rotated files as one record stream (oldest first).
+func OpenRotated(fname string) (io.ReadCloser, error) {
+	names, err := FindRotated(fname)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &multiFile{}
+	for _, name := range names {
+		r, err := Open(name)
+		if err != nil {
+			m.Close()
+			return nil, err
+		}
+		m.files = append(m.files, r)
+		m.readers = append(m.readers, &alignedReader{r: r})
+	}
+	m.Reader = io.MultiReader(m.readers...)
+	return m, nil
+}
+
+// Набор последовательно читаемых файлов.
+type multiFile struct {
+	io.Reader
+	files   []io.Closer
+	readers []io.Reader
+}
+
+func (m *multiFile) Close() error {
+	var errs []error
+	for _, f := range m.files {
+		errs = append(errs, f.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// Чтение только целых записей (неполная запись в конце отбрасывается).
+type alignedReader struct {
+	r   io.Reader
+	buf [RECORD_SIZE]byte
+	off int
+	n   int
+}
+
+func (a *alignedReader) Read(p []byte) (int, error) {
+	if a.off == a.n {
+		n, err := io.ReadFull(a.r, a.buf[:])
+		if err != nil {
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				err = io.EOF // drop incomplete record
+			}
+			return 0, err
+		}
+		a.off, a.n = 0, n
+	}
+	n := copy(p, a.buf[a.off:a.n])
+	a.off += n
+	return n, nil
+}
+
+// EOF: "rotate.go"
@@ -0,0 +1,105 @@
+// File: "rotate_test.go"
+
+package utmp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Записать файл ротации с одной записью входа пользователя user
+// (tail - байты неполной записи в конце, gz - сжать gzip).
+func writeRotated(t *testing.T, fname, user string, sec int32, tail int, gz bool) {
+	var buf bytes.Buffer
+	u := testRecord(USER_PROCESS, 100, "pts/0", "ts/0", user, "", sec)
+	require.NoError(t, binary.Write(&buf, binary.LittleEndian, &u))
+	buf.Write(make([]byte, tail))
+	data := buf.Bytes()
+	if gz {
+		var zbuf bytes.Buffer
+		z := gzip.NewWriter(&zbuf)
+		_, err := z.Write(data)
+		require.NoError(t, err)
+		require.NoError(t, z.Close())
+		data = zbuf.Bytes()
+	}
+	require.NoError(t, os.WriteFile(fname, data, 0644))
+}
+
+func TestFindRotated(t *testing.T) {
+	dir := t.TempDir()
+	wtmp := filepath.Join(dir, "wtmp")
+	writeRotated(t, wtmp, "e", 5000, 0, false)
+	writeRotated(t, wtmp+".1", "d", 4000, 0, false)
+	writeRotated(t, wtmp+".2.gz", "c", 3000, 0, true)
+	writeRotated(t, wtmp+"-20260101", "b", 2000, 0, false)
+	writeRotated(t, wtmp+"-202512.gz", "a", 1000, 0, true)
+
+	// not rotated files of wtmp, empty file
+	writeRotated(t, wtmp+".bak", "x", 100, 0, false)
+	writeRotated(t, wtmp+"x", "x", 100, 0, false)
+	writeRotated(t, wtmp+"-2026", "x", 100, 0, false)
+	writeRotated(t, wtmp+".1.bz2", "x", 100, 0, false)
+	require.NoError(t, os.WriteFile(wtmp+".3", nil, 0644))
+	require.NoError(t, os.Mkdir(wtmp+".4", 0755))
+
+	names, err := FindRotated(wtmp)
+	require.NoError(t, err)
+	require.Equal(t, []string{wtmp + "-202512.gz", wtmp + "-20260101", wtmp + ".2.gz",
+		wtmp + ".1", wtmp}, names)
+
+	// missing generations: order by time of first record, not by name
+	require.NoError(t, os.Remove(wtmp+".1"))
+	require.NoError(t, os.Remove(wtmp+"-20260101"))
+	writeRotated(t, wtmp+".5", "f", 500, 0, false)
+	names, err = FindRotated(wtmp)
+	require.NoError(t, err)
+	require.Equal(t, []string{wtmp + ".5", wtmp + "-202512.gz", wtmp + ".2.gz", wtmp}, names)
+
+	// fname itself is returned even if missing
+	names, err = FindRotated(filepath.Join(dir, "btmp"))
+	require.NoError(t, err)
+	require.Equal(t, []string{filepath.Join(dir, "btmp")}, names)
+
+	_, err = FindRotated(filepath.Join(dir, "no", "wtmp"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestOpenRotated(t *testing.T) {
+	dir := t.TempDir()
+	wtmp := filepath.Join(dir, "wtmp")
+	writeRotated(t, wtmp, "c", 3000, 0, false)
+	writeRotated(t, wtmp+".1", "b", 2000, 100, false) // partial record
+	writeRotated(t, wtmp+".2.gz", "a", 1000, RECORD_SIZE-1, true)
+
+	r, err := OpenRotated(wtmp)
+	require.NoError(t, err)
+	data, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	require.Len(t, data, 3*RECORD_SIZE)
+
+	// records stay aligned: partial records are dropped
+	s := NewScanner(bytes.NewReader(data))
+	users := []string{}
+	for s.Scan() {
+		users = append(users, Str(s.Record().User[:]))
+	}
+	require.NoError(t, s.Err())
+	require.Equal(t, []string{"a", "b", "c"}, users)
+	require.Zero(t, s.Stat().Broken)
+
+	// fname is required
+	require.NoError(t, os.Remove(wtmp))
+	_, err = OpenRotated(wtmp)
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+// EOF: "rotate_test.go"
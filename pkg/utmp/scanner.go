@@ -0,0 +1,312 @@
+// File: "scanner.go"
+
+package utmp
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"runtime/debug"
+	"time"
+)
+
+// Размер одной записи `utmp` в байтах.
+// Size of one Utmp record in bytes.
+const RECORD_SIZE = 384
+
+// Статистика "слотов" utmp файла, собранная при последовательном чтении.
+// Utmp slot statistics collected by Scanner.
+type ScanStat struct {
+	Records int // Total number of records read
+	Empty   int // EMPTY records (unused slots)
+	Partial int // Records with valid type but zeroed TTY and ID fields
+	Dead    int // DEAD_PROCESS records (free slots ready to reuse)
+	Reused  int // Records whose TTY+ID key was already seen in another slot
+	Broken  int // Trailing bytes of incomplete record (0 or 1)
+
+	Duplicates int // Collapsed duplicate USER_PROCESS records (see Scanner.Dedup)
+}
+
+// Число последних записей входа, среди которых ищутся дубликаты.
+// Number of recent login records to look for duplicates.
+const DEDUP_DEPTH = 8
+
+// Период проверки отмены контекста (в записях).
+// Context cancellation check period (records).
+const CTX_CHECK = 1024
+
+// Ключ записи входа для поиска дубликатов.
+type dupKey struct {
+	user, tty string
+	pid       uint32
+}
+
+// Последовательное чтение записей `Utmp` из utmp/wtmp/btmp файла
+// (по аналогии с bufio.Scanner).
+// После достижения конца файла метод Scan() можно вызвать повторно
+// (режим "tail -f"), неполная запись в конце файла сохраняется до
+// следующего вызова.
+// Scanner reads Utmp records one by one.
+type Scanner struct {
+	SkipEmpty bool // skip EMPTY and partially zeroed records
+
+	// Пропускать повторные записи USER_PROCESS (некоторые PAM стеки пишут
+	// несколько записей на один вход): совпадают пользователь, терминал
+	// и PID, время отличается не более чем на Dedup (0 - не пропускать).
+	// Collapse duplicate login records within this time (0 - disabled).
+	Dedup time.Duration
+
+	r    io.Reader          // source of records
+	data []byte             // memory mapped records (if r == nil)
+	pos  int                // offset of next record in data
+	cl   io.Closer          // closed by Close() (or nil)
+	buf  [RECORD_SIZE]byte  // raw record buffer
+	n    int                // number of bytes in buffer
+	rec  Utmp               // last decoded record
+	err  error              // first non-EOF error
+	stat ScanStat           // slot statistics
+	keys map[TTYID]struct{} // set of seen slot keys
+	last [DEDUP_DEPTH]struct {
+		key dupKey
+		t   time.Time
+	} // ring of recent login records
+	lastN int             // number of login records in ring
+	perfN int64           // number of records added to PerfStat
+	ctx   context.Context // cancellation of long scan (or nil)
+	ctxN  int             // records until next check of ctx
+}
+
+// Создать новый Scanner для чтения записей из `r` (чтение буферизуется).
+// Create new Scanner to read records from r.
+func NewScanner(r io.Reader) *Scanner {
+	return &Scanner{
+		r:    bufio.NewReaderSize(r, READ_BUF_SIZE),
+		keys: make(map[TTYID]struct{})}
+}
+
+// Прочитать следующую запись. Возвращает false в конце файла или при ошибке.
+// Advance to the next record (returns false on EOF or error).
+func (s *Scanner) Scan() bool {
+	if s.scan() {
+		return true
+	}
+	if perf.on.Load() { // see EnablePerfStat()
+		n := int64(s.stat.Records + s.stat.Duplicates)
+		perf.records.Add(n - s.perfN)
+		s.perfN = n
+	}
+	return false
+}
+
+// Прервать чтение при отмене контекста: Scan() возвращает false,
+// Err() - ошибку контекста (context.Canceled/DeadlineExceeded).
+// Stop scan on context cancellation.
+func (s *Scanner) SetContext(ctx context.Context) {
+	s.ctx = ctx
+	s.ctxN = 0
+}
+
+// Прочитать следующую запись (см. Scan()).
+func (s *Scanner) scan() bool {
+	if s.err != nil {
+		return false
+	}
+
+	if s.ctx != nil {
+		if s.ctxN--; s.ctxN < 0 {
+			if err := s.ctx.Err(); err != nil {
+				s.err = err
+				return false
+			}
+			s.ctxN = CTX_CHECK
+		}
+	}
+
+	if s.r == nil {
+		return s.scanMapped()
+	}
+
+	for {
+		n, err := io.ReadFull(s.r, s.buf[s.n:])
+		s.n += n
+		if err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				if s.n != 0 {
+					s.stat.Broken = 1 // incomplete record at the end of file
+				}
+				return false
+			}
+			s.err = err
+			return false
+		}
+		s.n = 0
+		s.stat.Broken = 0
+
+		err = DecodeUtmp(s.buf[:], &s.rec)
+		if err != nil {
+			s.err = err
+			return false
+		}
+
+		if s.isDuplicate() {
+			continue
+		}
+		if s.count() || !s.SkipEmpty {
+			return true
+		}
+	}
+}
+
+// Проверить, что запись входа - дубликат недавней записи.
+func (s *Scanner) isDuplicate() bool {
+	u := &s.rec
+	if s.Dedup <= 0 || u.Type != USER_PROCESS {
+		return false
+	}
+
+	key := dupKey{Str(u.User[:]), Str(u.Line[:]), u.ProcessID()}
+	t := Time(u.TV)
+	n := min(s.lastN, DEDUP_DEPTH)
+	for i := 0; i < n; i++ {
+		p := &s.last[i]
+		if p.key == key && t.Sub(p.t).Abs() <= s.Dedup {
+			s.stat.Duplicates++
+			return true
+		}
+	}
+
+	p := &s.last[s.lastN%DEDUP_DEPTH]
+	p.key, p.t = key, t
+	s.lastN++
+	return false
+}
+
+// Прочитать следующую запись из отображения файла в память (не дальше
+// размера файла на момент отображения, см. OpenMapped()).
+func (s *Scanner) scanMapped() (ok bool) {
+	defer debug.SetPanicOnFault(debug.SetPanicOnFault(true))
+	defer s.recoverFault(&ok)
+	for {
+		if len(s.data)-s.pos < RECORD_SIZE {
+			if s.pos != len(s.data) {
+				s.stat.Broken = 1 // incomplete record at the end of file
+			}
+			return false
+		}
+		DecodeUtmp(s.data[s.pos:], &s.rec)
+		s.pos += RECORD_SIZE
+
+		if s.isDuplicate() {
+			continue
+		}
+		if s.count() || !s.SkipEmpty {
+			return true
+		}
+	}
+}
+
+// Учесть запись в статистике, вернуть false для пустого слота.
+// Update slot statistics, return false if slot is empty.
+func (s *Scanner) count() bool {
+	u := &s.rec
+	s.stat.Records++
+
+	if u.IsEmpty() {
+		s.stat.Empty++
+		return false
+	}
+
+	if u.IsPartial() {
+		s.stat.Partial++
+		return false
+	}
+
+	if u.Type == DEAD_PROCESS {
+		s.stat.Dead++
+	}
+
+	if u.Type >= INIT_PROCESS && u.Type <= DEAD_PROCESS {
+		key := TTYID{Str(u.Line[:]), Str(u.ID[:])}
+		if _, ok := s.keys[key]; ok {
+			s.stat.Reused++
+		} else {
+			s.keys[key] = struct{}{}
+		}
+	}
+	return true
+}
+
+// Последняя прочитанная запись (действительна до следующего вызова Scan()).
+// Last record read by Scan() (valid until next call of Scan).
+func (s *Scanner) Record() *Utmp {
+	return &s.rec
+}
+
+// Первая ошибка чтения (конец файла ошибкой не считается).
+// First non-EOF error.
+func (s *Scanner) Err() error {
+	return s.err
+}
+
+// Освободить ресурсы Scanner, созданного OpenMapped() (для Scanner,
+// созданного NewScanner(), ничего не делает - источник записей
+// закрывается вызывающей стороной).
+// Release resources of Scanner created by OpenMapped().
+func (s *Scanner) Close() error {
+	if s.cl == nil {
+		return nil
+	}
+	err := s.cl.Close()
+	s.cl, s.data, s.pos = nil, nil, 0
+	return err
+}
+
+// Статистика слотов на текущий момент.
+// Current slot statistics.
+func (s *Scanner) Stat() ScanStat {
+	return s.stat
+}
+
+// Признак пустого (не используемого) слота.
+// Record is EMPTY slot.
+func (u *Utmp) IsEmpty() bool {
+	return u.Type == EMPTY
+}
+
+// Признак частично обнуленной записи процесса (нет ни TTY, ни ID).
+// Process record with zeroed TTY and ID fields.
+func (u *Utmp) IsPartial() bool {
+	if u.Type < INIT_PROCESS || u.Type > DEAD_PROCESS {
+		return false
+	}
+	return u.Line[0] == 0 && u.ID == [4]int8{}
+}
+
+// Собрать статистику слотов utmp/wtmp/btmp файла.
+// Get slot statistics of utmp/wtmp/btmp file.
+func GetScanStat(fname string) (ScanStat, error) {
+	return GetScanStatWith(fname, GetUsersOpts{})
+}
+
+// Собрать статистику слотов utmp/wtmp/btmp файла с учётом опций
+// (в т.ч. число пропущенных дубликатов при заданном opts.Dedup).
+// Get slot statistics of utmp/wtmp/btmp file with options.
+func GetScanStatWith(fname string, opts GetUsersOpts) (ScanStat, error) {
+	if fname == "" {
+		fname = DefaultFile
+	}
+
+	f, err := opts.Open(fname)
+	if err != nil {
+		return ScanStat{}, err
+	}
+	defer f.Close()
+
+	s := opts.NewScanner(f)
+	for s.Scan() {
+	}
+	return s.Stat(), s.Err()
+}
+
+// EOF: "scanner.go"
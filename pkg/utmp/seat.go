@@ -0,0 +1,91 @@
+// File: "seat.go"
+
+package utmp
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Основное рабочее место (seat) systemd-logind.
+// Default logind seat.
+const SEAT0 = "seat0"
+
+// Каталог сведений о сеансах systemd-logind.
+// logind sessions state directory.
+const LOGIND_SESSIONS = "/run/systemd/sessions"
+
+// Терминалы и X дисплей основного рабочего места (виртуальные консоли
+// и дисплей ":0").
+var seat0TTY = regexp.MustCompile(`^(:0(\.[0-9]+)?|tty[0-9]+)$`)
+
+// X дисплей ":N[.S]".
+var displayTTY = regexp.MustCompile(`^(:[0-9]+)(\.[0-9]+)?$`)
+
+// Получить рабочее место (seat) сеанса процесса из сведений
+// systemd-logind (по /proc/<pid>/sessionid). Возвращает "", если сеанс
+// не привязан к рабочему месту (например, удалённый вход).
+// Get logind seat of process session.
+func GetSeat(pid uint32) (string, error) {
+	if Offline() {
+		return "", ErrOffline
+	}
+	defer perfProc(time.Now())
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/sessionid", pid))
+	if err != nil {
+		return "", err
+	}
+	id := strings.TrimSpace(string(data))
+	if id == "4294967295" { // (unsigned)-1: no audit session
+		return "", nil
+	}
+
+	file, err := os.Open(filepath.Join(LOGIND_SESSIONS, id))
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if seat, ok := strings.CutPrefix(scanner.Text(), "SEAT="); ok {
+			return seat, nil
+		}
+	}
+	return "", scanner.Err()
+}
+
+// Определить рабочее место (seat) локального пользователя: по сведениям
+// systemd-logind, иначе по терминалу - виртуальные консоли и дисплей ":0"
+// относятся к SEAT0, прочие X дисплеи ":N" считаются отдельными рабочими
+// местами (возвращается имя дисплея). Для удалённых пользователей
+// возвращает "".
+// Get seat of local user.
+func (u *User) Seat() string {
+	return u.seat(u.LoginType())
+}
+
+// Определить рабочее место пользователя с типом входа t.
+func (u *User) seat(t LoginType) string {
+	if t != LOCAL_X && t != LOCAL {
+		return ""
+	}
+	if pid := u.procPID(); pid != 0 && !Offline() {
+		if seat, err := GetSeat(pid); err == nil && seat != "" {
+			return seat
+		}
+	}
+	if seat0TTY.MatchString(u.TTY) {
+		return SEAT0
+	}
+	if m := displayTTY.FindStringSubmatch(u.TTY); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// EOF: "seat.go"
@@ -0,0 +1,88 @@
+// File: "seek.go"
+
+package utmp
+
+import (
+	"io"
+	"time"
+)
+
+// Допуск на нарушение хронологического порядка записей wtmp
+// (например, после коррекции системных часов).
+// Tolerance of wtmp records disorder for SeekToTime().
+var SeekTolerance = 5 * time.Minute
+
+// Максимальное число записей, просматриваемых назад после двоичного поиска.
+// Max number of records to scan back after binary search.
+var SeekBacktrack = 1024
+
+// Установить позицию файла на первую запись со временем >= t, используя
+// двоичный поиск по записям фиксированного размера (записи wtmp/btmp
+// упорядочены по времени). После поиска выполняется проход назад
+// в пределах `SeekTolerance` для учёта небольшого нарушения порядка,
+// поэтому часть записей до момента `t` может быть прочитана - их нужно
+// фильтровать (см. GetUsersOpts.InRange()). Возвращает новую позицию.
+// Seek f to the first record with time >= t (binary search).
+func SeekToTime(f io.ReadSeeker, t time.Time) (int64, error) {
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+	n := size / RECORD_SIZE
+
+	// Время записи с номером i (пустые записи пропускаются вперед)
+	timeAt := func(i int64) (time.Time, int64, error) {
+		var u Utmp
+		buf := make([]byte, RECORD_SIZE)
+		for ; i < n; i++ {
+			_, err := f.Seek(i*RECORD_SIZE, io.SeekStart)
+			if err != nil {
+				return time.Time{}, i, err
+			}
+			_, err = io.ReadFull(f, buf)
+			if err != nil {
+				return time.Time{}, i, err
+			}
+			err = DecodeUtmp(buf, &u)
+			if err != nil {
+				return time.Time{}, i, err
+			}
+			if !u.IsEmpty() && u.TV.Sec != 0 {
+				return Time(u.TV), i, nil
+			}
+		}
+		return time.Time{}, n, nil // no more records
+	}
+
+	// Двоичный поиск первой записи со временем >= t
+	lo, hi := int64(0), n
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		tm, i, err := timeAt(mid)
+		if err != nil {
+			return 0, err
+		}
+		if i >= n || !tm.Before(t) {
+			hi = mid
+		} else {
+			lo = i + 1
+		}
+	}
+
+	// Проход назад с допуском на нарушение порядка
+	edge := t.Add(-SeekTolerance)
+	for back := 0; lo > 0 && back < SeekBacktrack; back++ {
+		tm, i, err := timeAt(lo - 1)
+		if err != nil {
+			return 0, err
+		}
+		if i == lo-1 && tm.Before(edge) {
+			break
+		}
+		lo--
+	}
+
+	return f.Seek(lo*RECORD_SIZE, io.SeekStart)
+}
+
+// EOF: "seek.go"
@@ -0,0 +1,46 @@
+// File: "seek_test.go"
+
+package utmp
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeekToTime(t *testing.T) {
+	recs := []Utmp{}
+	for i := 0; i < 100; i++ {
+		recs = append(recs, testRecord(USER_PROCESS, uint32(i), "pts/0", "ts/0", "bob", "", int32(1000+i*600)))
+	}
+	recs[50] = testRecord(EMPTY, 0, "", "", "", "", 0)
+	recs[60].TV.Sec += 900 // slight disorder (less than SeekTolerance)
+
+	f, err := os.Open(testFile(t, recs...))
+	require.NoError(t, err)
+	defer f.Close()
+
+	pos, err := SeekToTime(f, time.Unix(1000+70*600, 0))
+	require.NoError(t, err)
+	require.Equal(t, int64(70*RECORD_SIZE), pos)
+
+	pos, err = SeekToTime(f, time.Unix(1000+61*600+200, 0))
+	require.NoError(t, err)
+	require.Equal(t, int64(60*RECORD_SIZE), pos) // tolerance pass
+
+	pos, err = SeekToTime(f, time.Unix(1000+51*600, 0))
+	require.NoError(t, err)
+	require.Equal(t, int64(50*RECORD_SIZE), pos) // EMPTY record before
+
+	pos, err = SeekToTime(f, time.Unix(0, 0))
+	require.NoError(t, err)
+	require.Equal(t, int64(0), pos)
+
+	pos, err = SeekToTime(f, time.Unix(1000+200*600, 0))
+	require.NoError(t, err)
+	require.Equal(t, int64(100*RECORD_SIZE), pos)
+}
+
+// EOF: "seek_test.go"
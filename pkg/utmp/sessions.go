@@ -0,0 +1,242 @@
+// File: "sessions.go"
+
+package utmp
+
+import (
+	"context"
+	"net"
+	"sort"
+	"time"
+)
+
+// Способ завершения сеанса пользователя.
+// How session ended.
+var SessionEndStr = [...]string{"active", "logout", "gone", "down", "crash"}
+
+type SessionEnd int
+
+const (
+	SESSION_ACTIVE SessionEnd = iota // сеанс не завершен (пользователь в системе)
+	SESSION_LOGOUT                   // штатный выход пользователя (DEAD_PROCESS)
+	SESSION_GONE                     // новый вход на тот же терминал без выхода
+	SESSION_DOWN                     // останов системы (RUN_LVL "shutdown")
+	SESSION_CRASH                    // перезагрузка без останова (BOOT_TIME)
+)
+
+// Способ завершения сеанса в виде строки.
+// Session end as string.
+func (e SessionEnd) String() string {
+	if e >= 0 && int(e) < len(SessionEndStr) {
+		return SessionEndStr[e]
+	}
+	return ""
+}
+
+// Сеанс пользователя: пара записей вход/выход из wtmp файла.
+// User session (login/logout pair from wtmp).
+type Session struct {
+	User   string     // Username
+	TTY    string     // TTY device
+	ID     string     // Terminal name suffix
+	PID    uint32     // PID of login process
+	Host   string     // Login from
+	IP     net.IP     // IPv4 address
+	SID    int32      // Session ID
+	Login  time.Time  // Login time
+	Logout time.Time  // Logout time (zero for active session)
+	End    SessionEnd // How session ended
+	Auth   *AuthInfo  // SSH authentication (see pkg/authlog)
+}
+
+// Длительность сеанса (для активного сеанса - до момента `now`).
+// Session duration (up to `now` for active session).
+func (s *Session) Duration(now time.Time) time.Duration {
+	if s.End == SESSION_ACTIVE {
+		return now.Sub(s.Login)
+	}
+	return s.Logout.Sub(s.Login)
+}
+
+// Прочитать wtmp файл и сопоставить записи входа и выхода пользователей
+// в список сеансов (аналог команды `last`), сортированный по времени входа.
+// С временным окном возвращаются сеансы, пересекающие его (в т.ч. начатые
+// до Since; сеансы, не завершённые к Until, - активные), сеансы
+// игнорируемых пользователей (см. IsIgnored()) пропускаются.
+// Get user sessions from wtmp (sorted by login time).
+func GetSessions(fname string, opts GetUsersOpts) ([]Session, error) {
+	return GetSessionsContext(context.Background(), fname, opts)
+}
+
+// Вариант GetSessions() с возможностью прервать чтение длинного файла
+// отменой контекста (возвращается ошибка контекста).
+// Get user sessions from wtmp with cancellation.
+func GetSessionsContext(ctx context.Context, fname string, opts GetUsersOpts) ([]Session, error) {
+	if fname == "" {
+		fname = DefaultFile
+	}
+
+	f, err := opts.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	pr := NewPairer()
+	s := opts.NewScannerContext(ctx, f)
+	s.SkipEmpty = true
+	for s.Scan() {
+		u := s.Record()
+		if !opts.Until.IsZero() && Time(u.TV).After(opts.Until) {
+			continue // skip records after time window (before Since - state only)
+		}
+		pr.Add(u)
+	}
+	if err = s.Err(); err != nil {
+		return nil, err
+	}
+
+	// Сеансы, пересекающие окно [Since, Until]
+	sessions := pr.Sessions()
+	result := make([]Session, 0, len(sessions))
+	for _, p := range sessions {
+		if opts.InWindow(p.Login, p.Logout) {
+			result = append(result, p)
+		}
+	}
+	return result, nil
+}
+
+// Инкрементальное сопоставление записей входа и выхода в сеансы:
+// записи wtmp подаются по порядку (Add()), открытые сеансы прошлого
+// сопоставления можно восстановить (Restore()), например, чтобы
+// продолжить чтение файла с места остановки.
+// Incremental session pairing.
+type Pairer struct {
+	sessions []*Session
+	restored map[*Session]bool
+
+	// открытые сеансы
+	base  map[UserTTY]*Session
+	pbase map[TTYPID]*Session
+	ibase map[TTYID]*Session
+
+	in        *Interner
+	normalize func(string) string
+}
+
+// Создать Pairer (не потокобезопасен).
+// Create session pairer.
+func NewPairer() *Pairer {
+	return &Pairer{
+		restored:  make(map[*Session]bool),
+		base:      make(map[UserTTY]*Session),
+		pbase:     make(map[TTYPID]*Session),
+		ibase:     make(map[TTYID]*Session),
+		in:        NewInterner(0),
+		normalize: newNormalizer()}
+}
+
+// Восстановить активный сеанс прошлого сопоставления. Повторная запись
+// входа того же сеанса (тот же PID, время с точностью до секунды)
+// игнорируется.
+// Restore active session.
+func (pr *Pairer) Restore(s Session) {
+	p := &s
+	p.Logout, p.End = time.Time{}, SESSION_ACTIVE
+	pr.restored[p] = true
+	pr.open(p)
+}
+
+// Добавить открытый сеанс.
+func (pr *Pairer) open(p *Session) {
+	if old, ok := pr.base[UserTTY{p.User, p.TTY}]; ok {
+		pr.close(old, p.Login, SESSION_GONE)
+	}
+	pr.sessions = append(pr.sessions, p)
+	pr.base[UserTTY{p.User, p.TTY}] = p
+	pr.pbase[TTYPID{p.TTY, p.PID}] = p
+	pr.ibase[TTYID{p.TTY, p.ID}] = p
+}
+
+// Закрыть сеанс и удалить его из множеств открытых сеансов.
+func (pr *Pairer) close(p *Session, t time.Time, end SessionEnd) {
+	p.Logout, p.End = t, end
+	delete(pr.base, UserTTY{p.User, p.TTY})
+	delete(pr.pbase, TTYPID{p.TTY, p.PID})
+	delete(pr.ibase, TTYID{p.TTY, p.ID})
+}
+
+// Закрыть все открытые сеансы.
+func (pr *Pairer) closeAll(t time.Time, end SessionEnd) {
+	for _, p := range pr.base {
+		pr.close(p, t, end)
+	}
+}
+
+// Обработать очередную запись wtmp.
+// Add wtmp record.
+func (pr *Pairer) Add(u *Utmp) {
+	in := pr.in
+	t := Time(u.TV)
+	switch u.Type {
+	case BOOT_TIME: // type 2
+		pr.closeAll(t, SESSION_CRASH)
+
+	case RUN_LVL: // type 1
+		if in.Str(u.User[:]) == "shutdown" {
+			pr.closeAll(t, SESSION_DOWN)
+		}
+
+	case USER_PROCESS: // type 7 => user login
+		p := &Session{
+			User:  pr.normalize(in.Str(u.User[:])),
+			TTY:   in.Str(u.Line[:]),
+			ID:    in.Str(u.ID[:]),
+			PID:   u.ProcessID(),
+			Host:  in.Str(u.Host[:]),
+			IP:    IPv4(u.AddrV6),
+			SID:   u.Session,
+			Login: t}
+		if IsIgnored(p.User) {
+			return // skip ignored user
+		}
+		if old, ok := pr.base[UserTTY{p.User, p.TTY}]; ok && pr.restored[old] &&
+			old.PID == p.PID && old.Login.Unix() == t.Unix() {
+			return // restored session is read again
+		}
+		pr.open(p)
+
+	case DEAD_PROCESS: // type 8 => user logout
+		user := pr.normalize(in.Str(u.User[:]))
+		tty := in.Str(u.Line[:])
+
+		p, ok := pr.base[UserTTY{user, tty}]
+		if !ok && user == "" { // logout record in wtmp with User=""
+			p, ok = pr.pbase[TTYPID{tty, u.ProcessID()}]
+			if !ok {
+				p, ok = pr.ibase[TTYID{tty, in.Str(u.ID[:])}]
+			}
+		}
+		if ok {
+			pr.close(p, t, SESSION_LOGOUT)
+		}
+	} // switch
+}
+
+// Сеансы, сортированные по времени входа: начатые после создания
+// Pairer и восстановленные сеансы, завершённые после восстановления.
+// Get paired sessions (sorted by login time).
+func (pr *Pairer) Sessions() []Session {
+	list := make([]Session, 0, len(pr.sessions))
+	for _, p := range pr.sessions {
+		if !pr.restored[p] || p.End != SESSION_ACTIVE {
+			list = append(list, *p)
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].Login.Before(list[j].Login)
+	})
+	return list
+}
+
+// EOF: "sessions.go"
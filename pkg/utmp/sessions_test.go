@@ -0,0 +1,265 @@
+// File: "sessions_test.go"
+
+package utmp
+
+import (
+	"context"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Create test utmp record
+func testRecord(Type int16, pid uint32, line, id, user, host string, sec int32) Utmp {
+	u := Utmp{Type: Type}
+	binary.LittleEndian.PutUint32(u.PID[:], pid)
+	copy8 := func(dst []int8, src string) {
+		for i := 0; i < len(src) && i < len(dst); i++ {
+			dst[i] = int8(src[i])
+		}
+	}
+	copy8(u.Line[:], line)
+	copy8(u.ID[:], id)
+	copy8(u.User[:], user)
+	copy8(u.Host[:], host)
+	u.TV.Sec = sec
+	return u
+}
+
+// Write test records to temporary wtmp file
+func testFile(t *testing.T, recs ...Utmp) string {
+	fname := filepath.Join(t.TempDir(), "wtmp")
+	f, err := os.Create(fname)
+	require.NoError(t, err)
+	defer f.Close()
+	for i := range recs {
+		require.NoError(t, binary.Write(f, binary.LittleEndian, &recs[i]))
+	}
+	return fname
+}
+
+func TestGetSessions(t *testing.T) {
+	fname := testFile(t,
+		testRecord(BOOT_TIME, 0, "~", "~~", "reboot", "6.1.0", 1000),
+		testRecord(USER_PROCESS, 101, "tty1", "tty1", "alice", "", 1010),
+		testRecord(USER_PROCESS, 102, "pts/0", "ts/0", "bob", "10.0.0.5", 1020),
+		testRecord(EMPTY, 0, "", "", "", "", 0),
+		testRecord(DEAD_PROCESS, 102, "pts/0", "ts/0", "", "", 1100),
+		testRecord(USER_PROCESS, 103, "pts/1", "ts/1", "carol", "10.0.0.6", 1200),
+		testRecord(BOOT_TIME, 0, "~", "~~", "reboot", "6.1.0", 2000),
+		testRecord(USER_PROCESS, 104, "tty1", "tty1", "alice", "", 2010),
+	)
+
+	sessions, err := GetSessions(fname, GetUsersOpts{})
+	require.NoError(t, err)
+	require.Len(t, sessions, 4)
+
+	require.Equal(t, "alice", sessions[0].User)
+	require.Equal(t, SESSION_CRASH, sessions[0].End)
+	require.Equal(t, "bob", sessions[1].User)
+	require.Equal(t, SESSION_LOGOUT, sessions[1].End)
+	require.Equal(t, 80*time.Second, sessions[1].Duration(time.Now()))
+	require.Equal(t, "carol", sessions[2].User)
+	require.Equal(t, SESSION_CRASH, sessions[2].End)
+	require.Equal(t, SESSION_ACTIVE, sessions[3].End)
+
+	// Time window: sessions started before Since are kept
+	sessions, err = GetSessions(fname, GetUsersOpts{
+		Since: time.Unix(1500, 0)})
+	require.NoError(t, err)
+	require.Len(t, sessions, 3)
+	require.Equal(t, time.Unix(1010, 0), sessions[0].Login)
+	require.Equal(t, SESSION_CRASH, sessions[0].End)
+
+	users, err := GetUsersWith(fname, GetUsersOpts{Until: time.Unix(1500, 0)})
+	require.NoError(t, err)
+	require.Len(t, users, 2)
+}
+
+func TestGetSessionsWindow(t *testing.T) {
+	defer SetConfig(DefaultConfig())
+	fname := testFile(t,
+		testRecord(BOOT_TIME, 0, "~", "~~", "reboot", "6.1.0", 1000),
+		testRecord(USER_PROCESS, 101, "tty1", "tty1", "alice", "", 1010),
+		testRecord(USER_PROCESS, 102, "pts/0", "ts/0", "bob", "10.0.0.5", 1020),
+		testRecord(DEAD_PROCESS, 102, "pts/0", "ts/0", "", "", 1100),
+		testRecord(USER_PROCESS, 103, "pts/1", "ts/1", "carol", "10.0.0.6", 1200),
+		testRecord(DEAD_PROCESS, 103, "pts/1", "ts/1", "", "", 1300),
+		testRecord(USER_PROCESS, 104, "pts/2", "ts/2", "ansible", "10.0.0.7", 1400),
+		testRecord(DEAD_PROCESS, 101, "tty1", "tty1", "", "", 1500),
+	)
+
+	// Straddling sessions: alice (1010-1500) and bob (1020-1100) overlap
+	// window, alice is still logged in at Until, carol is after window
+	sessions, err := GetSessions(fname, GetUsersOpts{
+		Since: time.Unix(1050, 0), Until: time.Unix(1150, 0)})
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+	require.Equal(t, "alice", sessions[0].User)
+	require.Equal(t, time.Unix(1010, 0), sessions[0].Login)
+	require.Equal(t, SESSION_ACTIVE, sessions[0].End)
+	require.Equal(t, "bob", sessions[1].User)
+	require.Equal(t, SESSION_LOGOUT, sessions[1].End)
+
+	// bob logged out before Since
+	sessions, err = GetSessions(fname, GetUsersOpts{Since: time.Unix(1250, 0)})
+	require.NoError(t, err)
+	require.Len(t, sessions, 3)
+	require.Equal(t, []string{"alice", "carol", "ansible"},
+		[]string{sessions[0].User, sessions[1].User, sessions[2].User})
+	require.Equal(t, time.Unix(1500, 0), sessions[0].Logout)
+
+	// Users logged in before Since are kept (state at Until)
+	users, err := GetUsersWith(fname, GetUsersOpts{
+		Since: time.Unix(1250, 0), Until: time.Unix(1450, 0), Offline: true})
+	require.NoError(t, err)
+	require.Equal(t, []string{"alice", "ansible"}, users.Names())
+
+	// Ignored users
+	require.NoError(t, SetConfig(Config{IgnoreUsers: []string{"^ansible$"}}))
+	sessions, err = GetSessions(fname, GetUsersOpts{Since: time.Unix(1250, 0)})
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+	require.Equal(t, "carol", sessions[1].User)
+}
+
+func TestPairer(t *testing.T) {
+	pr := NewPairer()
+	pr.Restore(Session{User: "alice", TTY: "tty1", ID: "tty1", PID: 101,
+		Login: time.Unix(1010, 0)})
+	pr.Restore(Session{User: "bob", TTY: "pts/0", ID: "ts/0", PID: 102,
+		Login: time.Unix(1020, 0)})
+	require.Empty(t, pr.Sessions()) // nothing is changed
+
+	recs := []Utmp{
+		testRecord(USER_PROCESS, 101, "tty1", "tty1", "alice", "", 1010), // read again
+		testRecord(DEAD_PROCESS, 102, "pts/0", "ts/0", "", "", 1100),
+		testRecord(USER_PROCESS, 103, "pts/1", "ts/1", "carol", "10.0.0.6", 1200),
+	}
+	for i := range recs {
+		pr.Add(&recs[i])
+	}
+	sessions := pr.Sessions()
+	require.Len(t, sessions, 2)
+	require.Equal(t, "bob", sessions[0].User)
+	require.Equal(t, SESSION_LOGOUT, sessions[0].End)
+	require.Equal(t, time.Unix(1100, 0), sessions[0].Logout)
+	require.Equal(t, "carol", sessions[1].User)
+	require.Equal(t, SESSION_ACTIVE, sessions[1].End)
+
+	// restored session is closed by reboot
+	boot := testRecord(BOOT_TIME, 0, "~", "~~", "reboot", "6.1.0", 2000)
+	pr.Add(&boot)
+	sessions = pr.Sessions()
+	require.Len(t, sessions, 3)
+	require.Equal(t, "alice", sessions[0].User)
+	require.Equal(t, SESSION_CRASH, sessions[0].End)
+	require.Equal(t, SESSION_CRASH, sessions[2].End)
+}
+
+func TestContext(t *testing.T) {
+	recs := []Utmp{}
+	for i := 0; i < 3*CTX_CHECK; i++ {
+		recs = append(recs, testRecord(USER_PROCESS, uint32(i), "pts/0", "ts/0", "alice", "", int32(1000+i)))
+	}
+	fname := testFile(t, recs...)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	users, err := GetUsersContext(ctx, fname, GetUsersOpts{Offline: true})
+	require.NoError(t, err)
+	require.Len(t, users, 1)
+
+	cancel()
+	_, err = GetUsersContext(ctx, fname, GetUsersOpts{Offline: true})
+	require.ErrorIs(t, err, context.Canceled)
+	_, err = GetSessionsContext(ctx, fname, GetUsersOpts{})
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestDedup(t *testing.T) {
+	fname := testFile(t,
+		testRecord(USER_PROCESS, 101, "pts/0", "ts/0", "alice", "10.0.0.5", 1000),
+		testRecord(USER_PROCESS, 101, "pts/0", "ts/0", "alice", "10.0.0.5", 1000),
+		testRecord(USER_PROCESS, 101, "pts/0", "ts/0", "alice", "10.0.0.5", 1001),
+		testRecord(USER_PROCESS, 102, "pts/1", "ts/1", "alice", "10.0.0.5", 1001),
+		testRecord(DEAD_PROCESS, 101, "pts/0", "ts/0", "", "", 1100),
+		testRecord(USER_PROCESS, 101, "pts/0", "ts/0", "alice", "10.0.0.5", 1200),
+	)
+
+	sessions, err := GetSessions(fname, GetUsersOpts{})
+	require.NoError(t, err)
+	require.Len(t, sessions, 5)
+
+	opts := GetUsersOpts{Dedup: time.Second}
+	sessions, err = GetSessions(fname, opts)
+	require.NoError(t, err)
+	require.Len(t, sessions, 3)
+
+	st, err := GetScanStatWith(fname, opts)
+	require.NoError(t, err)
+	require.Equal(t, 2, st.Duplicates)
+	require.Equal(t, 4, st.Records)
+}
+
+func TestCurrentBoot(t *testing.T) {
+	boot, err := GetBootTime()
+	require.NoError(t, err)
+	id, err := GetBootID()
+	require.NoError(t, err)
+	require.Len(t, id, 36) // UUID
+
+	before := int32(boot.Add(-time.Hour).Unix())
+	after := int32(boot.Add(time.Second).Unix())
+	fname := testFile(t,
+		testRecord(USER_PROCESS, 101, "pts/0", "ts/0", "alice", "10.0.0.5", before),
+		testRecord(USER_PROCESS, 102, "pts/1", "ts/1", "bob", "10.0.0.6", after),
+	)
+
+	users, err := GetUsersWith(fname, GetUsersOpts{})
+	require.NoError(t, err)
+	require.Len(t, users, 2)
+
+	users, err = GetUsersWith(fname, GetUsersOpts{CurrentBoot: true})
+	require.NoError(t, err)
+	require.Len(t, users, 1)
+	require.Equal(t, "bob", users[0].Name)
+}
+
+func TestNormalizeUser(t *testing.T) {
+	defer SetConfig(DefaultConfig())
+	fname := testFile(t,
+		testRecord(USER_PROCESS, 101, "pts/0", "ts/0", "alice", "10.0.0.5", 1000),
+		testRecord(USER_PROCESS, 102, "pts/1", "ts/1", `CORP\alice`, "10.0.0.6", 1010),
+		testRecord(USER_PROCESS, 103, "pts/2", "ts/2", "alice@corp", "10.0.0.7", 1020),
+		testRecord(DEAD_PROCESS, 103, "pts/2", "ts/2", "alice@corp", "", 1100),
+	)
+
+	require.NoError(t, SetConfig(Config{UserMap: []UserMap{
+		{Match: `^[^\\]+\\(.+)$`, Replace: "$1"},
+		{Match: `^([^@]+)@corp$`, Replace: "$1"}}}))
+	require.Equal(t, "alice", NormalizeUser(`CORP\alice`))
+	require.Equal(t, "bob", NormalizeUser("bob"))
+
+	sessions, err := GetSessions(fname, GetUsersOpts{})
+	require.NoError(t, err)
+	require.Len(t, sessions, 3)
+	for _, s := range sessions {
+		require.Equal(t, "alice", s.User)
+	}
+	require.Equal(t, SESSION_LOGOUT, sessions[2].End)
+
+	users, err := GetUsersWith(fname, GetUsersOpts{})
+	require.NoError(t, err)
+	require.Len(t, users, 2)
+	require.Equal(t, 2, users.GetUserLogin("alice").Logons)
+
+	SetUserNormalizer(func(name string) string { return "x" + name })
+	defer SetUserNormalizer(nil)
+	require.Equal(t, "xbob", NormalizeUser("bob"))
+}
+
+// EOF: "sessions_test.go"
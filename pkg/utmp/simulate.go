@@ -0,0 +1,221 @@
+// File: "simulate.go"
+
+package utmp
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Опции генератора синтетических событий входа/выхода.
+// Simulator options.
+type SimOpts struct {
+	Rate  float64 // Events per second
+	Users int     // Size of synthetic user population
+	Seed  int64   // Random seed (0 - by current time)
+}
+
+// Генератор синтетического потока событий LoginEvent (без чтения utmp),
+// реализует интерфейс Loginer - используется для нагрузочного
+// тестирования потребителей событий.
+// Synthetic LoginEvent stream generator (implements Loginer).
+type Simulator struct {
+	opts     SimOpts
+	rnd      *rand.Rand
+	evtChan  chan LoginEvent
+	done     chan struct{}
+	users    Users        // synthetic logged users
+	ttys     int          // last allocated pts number
+	logins   []LoginInfo  // synthetic user information
+	stat     LoginStat    // synthetic statistics
+	mx       sync.RWMutex // protect logins and stat
+	wg       sync.WaitGroup
+	closeOne sync.Once
+}
+
+// Проверка соответствия интерфейсу.
+var _ Loginer = (*Simulator)(nil)
+
+// Создать и запустить генератор синтетических событий.
+// Create and start simulator.
+func NewSimulator(opts SimOpts) (*Simulator, error) {
+	if opts.Rate <= 0 {
+		return nil, fmt.Errorf("bad rate %v (must be > 0)", opts.Rate)
+	}
+	if opts.Users <= 0 {
+		return nil, fmt.Errorf("bad number of users %d (must be > 0)", opts.Users)
+	}
+	if opts.Seed == 0 {
+		opts.Seed = time.Now().UnixNano()
+	}
+
+	s := &Simulator{
+		opts:    opts,
+		rnd:     rand.New(rand.NewSource(opts.Seed)),
+		evtChan: make(chan LoginEvent),
+		done:    make(chan struct{})}
+
+	s.wg.Add(1)
+	go s.run()
+	return s, nil
+}
+
+// Разобрать частоту событий: "50/s", "600/m", "1000/h" или "50".
+// Parse event rate (events per second).
+func ParseRate(s string) (float64, error) {
+	num, unit, _ := strings.Cut(s, "/")
+	rate, err := strconv.ParseFloat(num, 64)
+	if err != nil || rate <= 0 {
+		return 0, fmt.Errorf("bad rate '%s'", s)
+	}
+	switch unit {
+	case "", "s":
+	case "m":
+		rate /= 60
+	case "h":
+		rate /= 3600
+	default:
+		return 0, fmt.Errorf("bad rate unit '%s'", s)
+	}
+	return rate, nil
+}
+
+// Горутина генерации событий.
+func (s *Simulator) run() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / s.opts.Rate))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+		}
+
+		evt := s.step(time.Now())
+		select {
+		case s.evtChan <- evt:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// Сгенерировать одно событие (вход или выход пользователя).
+func (s *Simulator) step(now time.Time) LoginEvent {
+	evt := LoginEvent{Time: now, Labels: Labels()}
+
+	// число сеансов колеблется около половины популяции
+	if len(s.users) == 0 || s.rnd.Intn(s.opts.Users) >= len(s.users) {
+		u := s.newUser(now)
+		s.users = append(s.users, u)
+		evt.Login = []UserTTY{{u.Name, u.TTY}}
+	} else {
+		i := s.rnd.Intn(len(s.users))
+		u := s.users[i]
+		s.users = append(s.users[:i], s.users[i+1:]...)
+		evt.Logout = []UserTTY{{u.Name, u.TTY}}
+	}
+
+	// информация о пользователях (как в Login.readUtmp())
+	logins := []LoginInfo{}
+	umap := make(map[string]int)
+	for _, u := range s.users {
+		info, _ := s.loginInfo(u.Name)
+		if ix, ok := umap[u.Name]; ok {
+			logins[ix] = *info
+		} else {
+			umap[u.Name] = len(logins)
+			logins = append(logins, *info)
+		}
+	}
+	stat := s.users.loginStat(s.loginInfo)
+
+	s.mx.Lock()
+	s.logins, s.stat = logins, stat
+	s.mx.Unlock()
+
+	evt.Users = make([]LoginInfo, len(logins))
+	copy(evt.Users, logins)
+	evt.Stat = stat
+	return evt
+}
+
+// Создать сеанс случайного пользователя: ssh (60%), консоль (25%),
+// локальный X (15%).
+func (s *Simulator) newUser(now time.Time) *User {
+	n := s.rnd.Intn(s.opts.Users)
+	u := &User{Name: fmt.Sprintf("sim%04d", n), Time: now}
+
+	switch p := s.rnd.Intn(100); {
+	case p < 60:
+		s.ttys++
+		u.TTY = fmt.Sprintf("pts/%d", s.ttys)
+		u.IP = net.IPv4(10, byte(n>>8), byte(n), byte(1+s.rnd.Intn(254)))
+		u.Host = u.IP.String()
+	case p < 85:
+		u.TTY = fmt.Sprintf("tty%d", 1+s.rnd.Intn(6))
+	default:
+		u.TTY = fmt.Sprintf(":%d", s.rnd.Intn(4))
+		u.ID = u.TTY
+	}
+	return u
+}
+
+// Синтетическая информация о пользователе (без обращения к базе
+// пользователей системы).
+func (s *Simulator) loginInfo(name string) (*LoginInfo, error) {
+	n, _ := strconv.Atoi(strings.TrimPrefix(name, "sim"))
+	uid := strconv.Itoa(100000 + n)
+	return &LoginInfo{
+		UserInfo: UserInfo{
+			Name:        name,
+			UID:         uid,
+			GID:         uid,
+			DisplayName: "Simulated user " + strconv.Itoa(n),
+			HomeDir:     "/home/" + name,
+			Groups:      "users"},
+		UserLogin: s.users.GetUserLogin(name)}, nil
+}
+
+// Остановить генератор (канал событий закрывается).
+// Stop simulator.
+func (s *Simulator) Close() {
+	s.closeOne.Do(func() {
+		close(s.done)
+		s.wg.Wait()
+		close(s.evtChan)
+	})
+}
+
+// Канал событий.
+// Get event channel.
+func (s *Simulator) C() <-chan LoginEvent {
+	return s.evtChan
+}
+
+// Информация о синтетических пользователях.
+// Get synthetic logged user information.
+func (s *Simulator) GetUsers() []LoginInfo {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	logins := make([]LoginInfo, len(s.logins))
+	copy(logins, s.logins)
+	return logins
+}
+
+// Статистика синтетических пользователей.
+// Get synthetic logged user statistics.
+func (s *Simulator) GetStat() LoginStat {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	return s.stat
+}
+
+// EOF: "simulate.go"
@@ -0,0 +1,42 @@
+// File: "simulate_test.go"
+
+package utmp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRate(t *testing.T) {
+	r, err := ParseRate("50/s")
+	require.NoError(t, err)
+	require.Equal(t, 50.0, r)
+
+	r, err = ParseRate("600/m")
+	require.NoError(t, err)
+	require.Equal(t, 10.0, r)
+
+	_, err = ParseRate("10/d")
+	require.Error(t, err)
+}
+
+func TestSimulator(t *testing.T) {
+	s, err := NewSimulator(SimOpts{Rate: 1000, Users: 20, Seed: 1})
+	require.NoError(t, err)
+
+	logged := 0
+	for i := 0; i < 100; i++ {
+		evt := <-s.C()
+		require.Equal(t, 1, len(evt.Login)+len(evt.Logout))
+		logged += len(evt.Login) - len(evt.Logout)
+		require.GreaterOrEqual(t, logged, 0)
+	}
+	require.NotEmpty(t, s.GetStat().Active)
+	s.Close()
+
+	_, ok := <-s.C()
+	require.False(t, ok)
+}
+
+// EOF: "simulate_test.go"
@@ -0,0 +1,93 @@
+// File: "sources.go"
+
+package utmp
+
+import (
+	"net"
+	"sort"
+	"time"
+)
+
+// Способ группировки источников входа для SourceReport().
+// Grouping of login sources.
+const (
+	SOURCE_BY_HOST    = "host"    // by remote host/IP
+	SOURCE_BY_NETWORK = "network" // by network label (see Config.Networks)
+)
+
+// Сводка удалённых сеансов по источнику входа.
+// Remote sessions summary by login source.
+type SourceStat struct {
+	Source   string    // Remote host/IP or network label
+	IP       net.IP    // IP address (by host only, may be nil)
+	Network  string    // Network label (see Config.Networks) or ""
+	Sessions int       // Number of sessions
+	Users    []string  // Distinct users (sorted)
+	First    time.Time // First login time
+	Last     time.Time // Last login time
+}
+
+// Сгруппировать удалённые сеансы по источнику входа (узлу/IP или метке
+// сети, см. SOURCE_BY_*). Локальные сеансы и X дисплеи (":0")
+// пропускаются. Результат сортирован по убыванию числа сеансов.
+// Summarize remote sessions by source.
+func SourceReport(sessions []Session, by string) []SourceStat {
+	xDisplay := curDetector.Load().xDisplay
+	stats := make(map[string]*SourceStat)
+	users := make(map[string]map[string]struct{})
+
+	for i := range sessions {
+		s := &sessions[i]
+		if len(s.IP) == 0 && (s.Host == "" || xDisplay.MatchString(s.Host)) {
+			continue // local session
+		}
+
+		src := s.Host
+		if len(s.IP) != 0 {
+			src = s.IP.String()
+		}
+		network := NetworkLabel(s.IP)
+		if by == SOURCE_BY_NETWORK {
+			src = network
+			if src == "" {
+				src = "-" // not described network
+			}
+		}
+
+		st := stats[src]
+		if st == nil {
+			st = &SourceStat{Source: src, Network: network, First: s.Login}
+			if by != SOURCE_BY_NETWORK {
+				st.IP = s.IP
+			}
+			stats[src] = st
+			users[src] = make(map[string]struct{})
+		}
+		st.Sessions++
+		if s.Login.Before(st.First) {
+			st.First = s.Login
+		}
+		if s.Login.After(st.Last) {
+			st.Last = s.Login
+		}
+		users[src][s.User] = struct{}{}
+	}
+
+	report := make([]SourceStat, 0, len(stats))
+	for src, st := range stats {
+		for u := range users[src] {
+			st.Users = append(st.Users, u)
+		}
+		sort.Strings(st.Users)
+		report = append(report, *st)
+	}
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].Sessions != report[j].Sessions {
+			return report[i].Sessions > report[j].Sessions
+		}
+		return report[i].Source < report[j].Source
+	})
+	return report
+}
+
+// EOF: "sources.go"
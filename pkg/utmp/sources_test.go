@@ -0,0 +1,43 @@
+// File: "sources_test.go"
+
+package utmp
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSourceReport(t *testing.T) {
+	require.NoError(t, SetConfig(Config{Networks: map[string][]string{"office": {"10.0.0.0/8"}}}))
+	defer SetConfig(DefaultConfig())
+
+	at := func(sec int64) time.Time { return time.Unix(sec, 0) }
+	sessions := []Session{
+		{User: "alice", Host: "10.0.0.5", IP: net.ParseIP("10.0.0.5"), Login: at(10)},
+		{User: "bob", Host: "10.0.0.5", IP: net.ParseIP("10.0.0.5"), Login: at(30)},
+		{User: "alice", Host: "10.1.0.1", IP: net.ParseIP("10.1.0.1"), Login: at(20)},
+		{User: "carol", Host: "vpn.example.com", Login: at(40)},
+		{User: "dave", Host: ":0", Login: at(50)}, // local X
+		{User: "eve", TTY: "tty1", Login: at(60)}, // local
+	}
+
+	report := SourceReport(sessions, SOURCE_BY_HOST)
+	require.Len(t, report, 3)
+	require.Equal(t, "10.0.0.5", report[0].Source)
+	require.Equal(t, "office", report[0].Network)
+	require.Equal(t, 2, report[0].Sessions)
+	require.Equal(t, []string{"alice", "bob"}, report[0].Users)
+	require.Equal(t, at(10), report[0].First)
+	require.Equal(t, at(30), report[0].Last)
+
+	report = SourceReport(sessions, SOURCE_BY_NETWORK)
+	require.Len(t, report, 2)
+	require.Equal(t, "office", report[0].Source)
+	require.Equal(t, 3, report[0].Sessions)
+	require.Equal(t, "-", report[1].Source)
+}
+
+// EOF: "sources_test.go"
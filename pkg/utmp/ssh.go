@@ -0,0 +1,185 @@
+// File: "ssh.go"
+
+package utmp
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Максимальная глубина поиска процесса sshd среди предков лидера сеанса.
+// Max depth of sshd process lookup.
+const SSH_DEPTH = 8
+
+// TCP соединение SSH сеанса.
+// SSH connection of session.
+type SSHConn struct {
+	PID        uint32 // PID процесса sshd, владеющего сокетом соединения
+	LocalIP    net.IP // адрес, на котором принято соединение
+	LocalPort  int    // порт sshd (обычно 22)
+	RemoteIP   net.IP // адрес клиента
+	RemotePort int    // порт клиента
+}
+
+// Соединение в виде "10.0.0.5:50122->192.168.1.1:22 sshd=1234".
+// String representation.
+func (c *SSHConn) String() string {
+	return net.JoinHostPort(c.RemoteIP.String(), strconv.Itoa(c.RemotePort)) + "->" +
+		net.JoinHostPort(c.LocalIP.String(), strconv.Itoa(c.LocalPort)) +
+		" sshd=" + strconv.FormatUint(uint64(c.PID), 10)
+}
+
+// Определить TCP соединения удалённых сеансов SSH: среди лидера сеанса
+// и его предков с именем sshd* (sshd, sshd-session) ищется ближайший
+// процесс, владеющий сокетом установленного соединения из /proc/net/tcp
+// и /proc/net/tcp6. Заполняет поле User.SSH (адреса и порты соединения,
+// PID процесса sshd - для точечной блокировки или завершения сеанса).
+// Сокеты чужих процессов доступны только root. Имеет смысл только для
+// utmp текущего узла.
+// Detect SSH connections of remote sessions by /proc.
+func (users Users) DetectSSH() {
+	if len(users) == 0 || Offline() {
+		return
+	}
+	conns, err := readTCPConns()
+	if err != nil {
+		return // no /proc
+	}
+	for _, u := range users {
+		if u.Stale || u.PID == 0 {
+			continue
+		}
+		if t := u.LoginType(); t != REMOTE && t != REMOTE_X {
+			continue
+		}
+		u.SSH = sshConn(u.PID, conns)
+	}
+}
+
+// Найти соединение процесса sshd среди pid и его предков (nil - нет).
+func sshConn(pid uint32, conns map[uint64]*SSHConn) *SSHConn {
+	for i := 0; i < SSH_DEPTH && pid > 1; i++ {
+		comm, err := os.ReadFile(fmt.Sprintf("/proc/%d/comm", pid))
+		if err != nil {
+			return nil
+		}
+		if strings.HasPrefix(string(comm), "sshd") {
+			if c := sockConn(pid, conns); c != nil {
+				return c
+			}
+		}
+		p, err := GetProc(pid)
+		if err != nil {
+			return nil
+		}
+		pid = p.PPID
+	}
+	return nil
+}
+
+// Соединение, сокетом которого владеет процесс (nil - нет).
+func sockConn(pid uint32, conns map[uint64]*SSHConn) *SSHConn {
+	dir := fmt.Sprintf("/proc/%d/fd", pid)
+	fds, err := os.ReadDir(dir)
+	if err != nil {
+		return nil // not permitted
+	}
+	for _, fd := range fds {
+		link, err := os.Readlink(dir + "/" + fd.Name())
+		if err != nil {
+			continue
+		}
+		// link: "socket:[12345]"
+		s, ok := strings.CutPrefix(link, "socket:[")
+		if !ok {
+			continue
+		}
+		inode, err := strconv.ParseUint(strings.TrimSuffix(s, "]"), 10, 64)
+		if err != nil {
+			continue
+		}
+		if c := conns[inode]; c != nil {
+			conn := *c
+			conn.PID = pid
+			return &conn
+		}
+	}
+	return nil
+}
+
+// Прочитать установленные TCP соединения (по inode сокета).
+func readTCPConns() (map[uint64]*SSHConn, error) {
+	conns := make(map[uint64]*SSHConn)
+	for i, fname := range []string{"/proc/net/tcp", "/proc/net/tcp6"} {
+		file, err := os.Open(fname)
+		if err != nil {
+			if i != 0 {
+				continue // IPv6 may be disabled
+			}
+			return nil, err
+		}
+		err = parseProcNetTCP(file, conns)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", fname, err)
+		}
+	}
+	return conns, nil
+}
+
+// Разобрать установленные соединения /proc/net/tcp[6].
+func parseProcNetTCP(r io.Reader, conns map[uint64]*SSHConn) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Scan() // skip header
+	for scanner.Scan() {
+		// line: "sl local_address rem_address st tx:rx tr:when retrnsmt uid timeout inode ..."
+		fds := strings.Fields(scanner.Text())
+		if len(fds) < 10 || fds[3] != "01" { // TCP_ESTABLISHED
+			continue
+		}
+		lip, lport, err1 := parseHexAddr(fds[1])
+		rip, rport, err2 := parseHexAddr(fds[2])
+		inode, err3 := strconv.ParseUint(fds[9], 10, 64)
+		if err1 != nil || err2 != nil || err3 != nil || inode == 0 {
+			continue
+		}
+		conns[inode] = &SSHConn{
+			LocalIP: lip, LocalPort: lport,
+			RemoteIP: rip, RemotePort: rport}
+	}
+	return scanner.Err()
+}
+
+// Разобрать адрес "0100007F:0016" (слова IP в порядке байт узла,
+// IPv4-mapped IPv6 адреса приводятся к IPv4).
+func parseHexAddr(s string) (net.IP, int, error) {
+	a, p, ok := strings.Cut(s, ":")
+	if !ok {
+		return nil, 0, fmt.Errorf("bad address '%s'", s)
+	}
+	port, err := strconv.ParseUint(p, 16, 16)
+	if err != nil {
+		return nil, 0, fmt.Errorf("bad port '%s'", s)
+	}
+	b, err := hex.DecodeString(a)
+	if err != nil || (len(b) != net.IPv4len && len(b) != net.IPv6len) {
+		return nil, 0, fmt.Errorf("bad address '%s'", s)
+	}
+	for i := 0; i < len(b); i += 4 { // host order words -> network order
+		binary.BigEndian.PutUint32(b[i:], binary.NativeEndian.Uint32(b[i:]))
+	}
+	ip := net.IP(b)
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	}
+	return ip, int(port), nil
+}
+
+// EOF: "ssh.go"
@@ -0,0 +1,69 @@
+// File: "ssh_test.go"
+
+package utmp
+
+import (
+	"net"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseProcNetTCP(t *testing.T) {
+	const tcp = `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000:0016 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 1001 1 0000000000000000 100 0 0 10 0
+   1: 0101A8C0:0016 0500000A:C3CA 01 00000000:00000000 02:000A7B2C 00000000     0        0 1002 2 0000000000000000 20 4 29 10 -1
+   2: 0101A8C0:0016 0600000A:C3CB 06 00000000:00000000 03:00000B2C 00000000     0        0 0 3 0000000000000000
+`
+	const tcp6 = `  sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 000080FE00000000FF00000201000000:0016 000080FE00000000FF00000205000000:D431 01 00000000:00000000 02:00000A1E 00000000     0        0 1003 2 0000000000000000 20 4 31 10 -1
+   1: 0000000000000000FFFF00000101A8C0:0016 0000000000000000FFFF00000700000A:D432 01 00000000:00000000 02:00000A1E 00000000     0        0 1004 2 0000000000000000 20 4 31 10 -1
+`
+	conns := make(map[uint64]*SSHConn)
+	require.NoError(t, parseProcNetTCP(strings.NewReader(tcp), conns))
+	require.NoError(t, parseProcNetTCP(strings.NewReader(tcp6), conns))
+	require.Len(t, conns, 3) // established only
+
+	c := conns[1002]
+	require.Equal(t, "10.0.0.5:50122->192.168.1.1:22 sshd=0", c.String())
+	require.Equal(t, "[fe80::200:ff:0:5]:54321->[fe80::200:ff:0:1]:22 sshd=0", conns[1003].String())
+	require.Equal(t, "10.0.0.7", conns[1004].RemoteIP.String())
+	require.Len(t, conns[1004].RemoteIP, net.IPv4len)
+}
+
+func TestSockConn(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("no TCP:", err)
+	}
+	defer ln.Close()
+	c, err := net.Dial("tcp4", ln.Addr().String())
+	require.NoError(t, err)
+	defer c.Close()
+	s, err := ln.Accept()
+	require.NoError(t, err)
+	defer s.Close()
+
+	conns, err := readTCPConns()
+	if err != nil {
+		t.Skip("no /proc/net/tcp:", err)
+	}
+	// Both ends belong to this process: find the accepted one by ports
+	pid := uint32(os.Getpid())
+	conn := sockConn(pid, conns)
+	require.NotNil(t, conn)
+	require.Equal(t, pid, conn.PID)
+	require.Equal(t, "127.0.0.1", conn.LocalIP.String())
+	require.Equal(t, "127.0.0.1", conn.RemoteIP.String())
+
+	local := s.LocalAddr().(*net.TCPAddr).Port
+	remote := c.LocalAddr().(*net.TCPAddr).Port
+	ports := []int{conn.LocalPort, conn.RemotePort}
+	require.ElementsMatch(t, []int{local, remote}, ports)
+
+	require.Nil(t, sshConn(pid, conns)) // test binary is not sshd
+}
+
+// EOF: "ssh_test.go"
@@ -0,0 +1,81 @@
+// File: "str.go"
+
+package utmp
+
+// Максимальное число строк в Interner по умолчанию.
+// Default max number of strings in Interner.
+const INTERN_MAX = 4096
+
+// Длина строки в поле записи (до первого нулевого символа).
+// Length of NUL terminated string in Utmp field.
+func StrLen(src []int8) int {
+	for i, v := range src {
+		if v == 0 {
+			return i
+		}
+	}
+	return len(src)
+}
+
+// Добавить строку из поля записи к `dst` (без выделения памяти, если
+// ёмкости `dst` достаточно).
+// Append NUL terminated string from Utmp field to dst.
+func StrAppend(dst []byte, src []int8) []byte {
+	for _, v := range src[:StrLen(src)] {
+		dst = append(dst, byte(v))
+	}
+	return dst
+}
+
+// Таблица повторяющихся строк (имена пользователей, терминалы, узлы):
+// при чтении больших wtmp файлов одни и те же строки встречаются
+// в миллионах записей, Interner возвращает один экземпляр строки без
+// выделения памяти для уже встречавшихся значений.
+// Не потокобезопасен. Нулевой указатель допустим (эквивалент Str()).
+// String interning table for Utmp fields (not thread safe).
+type Interner struct {
+	max int               // max number of strings
+	m   map[string]string // interned strings
+	buf []byte            // reusable buffer
+}
+
+// Создать Interner не более чем на `max` строк (0 - INTERN_MAX).
+// При переполнении таблица очищается.
+// Create Interner for max strings.
+func NewInterner(max int) *Interner {
+	if max <= 0 {
+		max = INTERN_MAX
+	}
+	return &Interner{
+		max: max,
+		m:   make(map[string]string),
+		buf: make([]byte, 0, HOSTSIZE)}
+}
+
+// Получить строку из поля записи (аналог Str()).
+// Get interned string from Utmp field.
+func (in *Interner) Str(src []int8) string {
+	if in == nil {
+		return Str(src)
+	}
+
+	in.buf = StrAppend(in.buf[:0], src)
+	if s, ok := in.m[string(in.buf)]; ok { // no allocation on lookup
+		if perf.on.Load() {
+			perf.internHits.Add(1)
+		}
+		return s
+	}
+	if perf.on.Load() {
+		perf.internMisses.Add(1)
+	}
+
+	if len(in.m) >= in.max {
+		clear(in.m)
+	}
+	s := string(in.buf)
+	in.m[s] = s
+	return s
+}
+
+// EOF: "str.go"
@@ -0,0 +1,68 @@
+// File: "str_test.go"
+
+package utmp
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStr(t *testing.T) {
+	u := testRecord(USER_PROCESS, 1, "pts/0", "ts/0", "alice", "", 0)
+	require.Equal(t, "alice", Str(u.User[:]))
+	require.Equal(t, "", Str(u.Host[:]))
+	require.Equal(t, "ts/0", Str(u.ID[:])) // not NUL terminated
+	require.Equal(t, []byte("<pts/0"), StrAppend([]byte("<"), u.Line[:]))
+
+	in := NewInterner(2)
+	a := in.Str(u.User[:])
+	require.Equal(t, "alice", a)
+	require.Equal(t, "pts/0", in.Str(u.Line[:]))
+	require.Equal(t, 0, int(testing.AllocsPerRun(10, func() { in.Str(u.User[:]) })))
+	require.Equal(t, "ts/0", in.Str(u.ID[:])) // overflow
+	require.Len(t, in.m, 1)
+
+	var nilIn *Interner
+	require.Equal(t, "alice", nilIn.Str(u.User[:]))
+}
+
+func TestPerfStat(t *testing.T) {
+	EnablePerfStat(true)
+	defer EnablePerfStat(false)
+	ResetPerfStat()
+
+	s := NewScanner(bytes.NewReader(benchData(10)))
+	in := NewInterner(0)
+	for s.Scan() {
+		in.Str(s.Record().User[:])
+	}
+	require.False(t, s.Scan()) // counted once
+
+	ps := GetPerfStat()
+	require.Equal(t, int64(10), ps.Records)
+	require.Equal(t, int64(10*RECORD_SIZE), ps.Bytes)
+	require.Equal(t, int64(9), ps.InternHits)
+	require.Equal(t, int64(1), ps.InternMisses)
+	require.InDelta(t, 0.9, ps.InternHitRate(), 1e-9)
+}
+
+func BenchmarkStr(b *testing.B) {
+	u := testRecord(USER_PROCESS, 1, "pts/0", "ts/0", "alice", "10.0.0.5", 0)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		u.Decode()
+	}
+}
+
+func BenchmarkInterner(b *testing.B) {
+	u := testRecord(USER_PROCESS, 1, "pts/0", "ts/0", "alice", "10.0.0.5", 0)
+	in := NewInterner(0)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		u.DecodeWith(in)
+	}
+}
+
+// EOF: "str_test.go"
@@ -0,0 +1,241 @@
+// File: "subscribe.go"
+
+package utmp
+
+import (
+	"slices"
+	"sync"
+)
+
+// Опции подписки на события (см. SubscribeWith()).
+// Нулевое значение - все события в небуферизированный канал.
+// Subscription options.
+type SubscribeOpts struct {
+	Buffer   int      // размер буфера канала (0 - не буферизирован, 1 для OVERFLOW_DROP_OLDEST)
+	Overflow Overflow // поведение при переполнении канала
+
+	// Только входы/выходы заданных пользователей (nil - всех)
+	Users []string
+
+	// Только входы/выходы заданных типов (nil - всех)
+	Types []LoginType
+
+	// Только события, при которых сменился активный пользователь
+	// (Stat.Active: имя, тип входа или выход)
+	ActiveOnly bool
+}
+
+// Подписчик на события `Login` (см. Subscribe()).
+type subscriber struct {
+	in       chan LoginEvent // очередь от dispatcherFn
+	out      chan LoginEvent // канал подписчика
+	overflow Overflow        // поведение при переполнении `out`
+	quit     chan struct{}   // отписка (см. Unsubscribe())
+	quitOne  sync.Once       // однократная отписка
+	filter   SubscribeOpts   // фильтр событий
+	active   activeUser      // активный пользователь последнего события
+}
+
+// Активный пользователь (для определения его смены).
+type activeUser struct {
+	name string
+	t    LoginType
+}
+
+// Подписчики по каналу.
+type subMap map[<-chan LoginEvent]*subscriber
+
+// Подписаться на события входа/выхода: независимый канал с буфером
+// `buffer` событий и поведением `overflow` при его переполнении (канал
+// C() - подписка, созданная NewLoginWith()). Подписчик получает события,
+// сформированные после подписки (текущее состояние - GetUsers(),
+// GetStat()). Канал закрывается в Unsubscribe() или Close().
+// Subscribe to login/logout events.
+func (l *Login) Subscribe(buffer int, overflow Overflow) <-chan LoginEvent {
+	return l.SubscribeWith(SubscribeOpts{Buffer: buffer, Overflow: overflow})
+}
+
+// Вариант Subscribe() с фильтром событий (см. `SubscribeOpts`): из событий
+// удаляются входы/выходы (Login, Logout) и пользователи (Users), не
+// прошедшие фильтр, события без входов/выходов не отправляются.
+// Subscribe to filtered login/logout events.
+func (l *Login) SubscribeWith(opts SubscribeOpts) <-chan LoginEvent {
+	buffer := max(opts.Buffer, 0)
+	if opts.Overflow == OVERFLOW_DROP_OLDEST {
+		buffer = max(buffer, 1) // nothing to drop in unbuffered channel
+	}
+	sub := &subscriber{
+		in:       make(chan LoginEvent, LOGIN_QUEUE),
+		out:      make(chan LoginEvent, buffer),
+		overflow: opts.Overflow,
+		quit:     make(chan struct{}),
+		filter:   opts}
+	if active := l.GetStat().Active; active != nil {
+		sub.active = activeUser{active.Name, active.Type}
+	}
+
+	l.subMx.Lock()
+	defer l.subMx.Unlock()
+	if l.closed {
+		close(sub.out)
+		return sub.out
+	}
+	l.subs[sub.out] = sub
+	l.wg.Add(1)
+	go subscriberFn(l, sub)
+	return sub.out
+}
+
+// Отписаться: канал, полученный от Subscribe() (или C()), закрывается,
+// события в нём отбрасываются. Повторный вызов безопасен.
+// Unsubscribe from events.
+func (l *Login) Unsubscribe(c <-chan LoginEvent) {
+	l.subMx.Lock()
+	sub, ok := l.subs[c]
+	delete(l.subs, c)
+	l.subMx.Unlock()
+	if ok {
+		sub.quitOne.Do(func() { close(sub.quit) })
+	}
+}
+
+// Текущий список подписчиков.
+func (l *Login) subscribers() []*subscriber {
+	l.subMx.RLock()
+	defer l.subMx.RUnlock()
+	subs := make([]*subscriber, 0, len(l.subs))
+	for _, sub := range l.subs {
+		subs = append(subs, sub)
+	}
+	return subs
+}
+
+// Применить фильтр подписчика к событию (вызывается только из
+// dispatcherFn), false - событие не отправлять.
+func (sub *subscriber) apply(evt LoginEvent) (LoginEvent, bool) {
+	f := &sub.filter
+	prev := sub.active
+	sub.active = activeUser{}
+	if a := evt.Stat.Active; a != nil {
+		sub.active = activeUser{a.Name, a.Type}
+	}
+	if f.ActiveOnly && sub.active == prev {
+		return evt, false
+	}
+	if f.Users == nil && f.Types == nil {
+		return evt, true
+	}
+
+	match := func(ut UserTTY) bool {
+		return (f.Users == nil || slices.Contains(f.Users, ut.User)) &&
+			(f.Types == nil || slices.Contains(f.Types, evt.Types[ut]))
+	}
+	evt.Login = filterTTY(evt.Login, match)
+	evt.Logout = filterTTY(evt.Logout, match)
+	if len(evt.Login)+len(evt.Logout) == 0 && !f.ActiveOnly {
+		return evt, false
+	}
+
+	users := []LoginInfo{}
+	for _, u := range evt.Users {
+		if (f.Users == nil || slices.Contains(f.Users, u.Name)) &&
+			(f.Types == nil || slices.Contains(f.Types, u.Type)) {
+			users = append(users, u)
+		}
+	}
+	evt.Users = users
+	return evt, true
+}
+
+// Отфильтровать список входов/выходов (исходный список не изменяется).
+func filterTTY(uts []UserTTY, match func(UserTTY) bool) []UserTTY {
+	var res []UserTTY
+	for _, ut := range uts {
+		if match(ut) {
+			res = append(res, ut)
+		}
+	}
+	return res
+}
+
+// Горутина доставки событий подписчику (единственный отправитель
+// в канал подписчика, закрывает его при отписке или Close()).
+func subscriberFn(l *Login, sub *subscriber) {
+	defer l.wg.Done()
+	defer close(sub.out)
+
+	if sub.overflow == OVERFLOW_COALESCE {
+		sub.sendCoalesce(l)
+		return
+	}
+
+	for {
+		select {
+		case evt := <-sub.in:
+			if sub.overflow == OVERFLOW_DROP_OLDEST {
+				sub.sendDropOldest(l, evt)
+				continue
+			}
+			select {
+			case sub.out <- evt:
+			case <-sub.quit:
+				return
+			case <-l.done:
+				return
+			}
+		case <-sub.quit:
+			return
+		case <-l.done:
+			return
+		}
+	}
+}
+
+// Отправить событие, при заполненном буфере отбросить самое старое
+// (буфер канала подписчика не пустой, см. SubscribeWith()).
+func (sub *subscriber) sendDropOldest(l *Login, evt LoginEvent) {
+	for {
+		select {
+		case sub.out <- evt:
+			return
+		default:
+		}
+		select {
+		case <-sub.out: // drop oldest (or received by consumer already)
+			l.dropped.Add(1)
+		case <-sub.quit:
+			return
+		case <-l.done:
+			return
+		default:
+		}
+	}
+}
+
+// Отправлять события, объединяя их, пока получатель занят.
+func (sub *subscriber) sendCoalesce(l *Login) {
+	var pending *LoginEvent
+	for {
+		var out chan<- LoginEvent
+		var evt LoginEvent
+		if pending != nil {
+			out, evt = sub.out, *pending
+		}
+		select {
+		case e := <-sub.in:
+			if pending == nil {
+				pending = &e
+			} else {
+				coalesce(pending, e)
+			}
+		case out <- evt:
+			pending = nil
+		case <-sub.quit:
+			return
+		case <-l.done:
+			return
+		}
+	}
+}
+
+// EOF: "subscribe.go"
@@ -0,0 +1,112 @@
+// File: "system.go"
+
+package utmp
+
+import (
+	"time"
+)
+
+// Типы системных событий.
+// Type of system event.
+var SystemEventTypeStr = [...]string{"", "boot", "run_level", "shutdown", "clock"}
+
+type SystemEventType int
+
+const (
+	SYS_UNKNOWN   SystemEventType = iota // тип события не определен
+	SYS_BOOT                             // загрузка системы (BOOT_TIME)
+	SYS_RUN_LEVEL                        // смена уровня выполнения (RUN_LVL)
+	SYS_SHUTDOWN                         // останов системы (RUN_LVL "shutdown")
+	SYS_CLOCK                            // изменение системного времени (OLD_TIME + NEW_TIME)
+)
+
+// Событие изменения состояния системы по данным wtmp.
+// System state change event (boot, run level, clock change).
+type SystemEvent struct {
+	Type      SystemEventType // Type of event
+	Time      time.Time       // Time of event (NewTime for clock change)
+	RunLevel  string          // New run level (SYS_RUN_LEVEL, SYS_SHUTDOWN)
+	PrevLevel string          // Previous run level (if known)
+	Kernel    string          // Kernel version (SYS_BOOT, SYS_RUN_LEVEL)
+	OldTime   time.Time       // Time before clock change (SYS_CLOCK)
+	NewTime   time.Time       // Time after clock change (SYS_CLOCK)
+	Delta     time.Duration   // Clock adjustment "NewTime - OldTime" (SYS_CLOCK)
+}
+
+// Тип события в виде строки.
+// Type of system event as string.
+func (t SystemEventType) String() string {
+	if t >= 0 && int(t) < len(SystemEventTypeStr) {
+		return SystemEventTypeStr[t]
+	}
+	return ""
+}
+
+// Прочитать wtmp файл и вернуть список системных событий: загрузки,
+// смены уровня выполнения и коррекции системных часов.
+// Get system events (boot, run level changes, clock adjustments) from wtmp.
+func SystemEvents(fname string) ([]SystemEvent, error) {
+	if fname == "" {
+		fname = DefaultFile
+	}
+
+	f, err := Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	events := []SystemEvent{}
+	var old *SystemEvent // pending OLD_TIME record
+
+	s := NewScanner(f)
+	s.SkipEmpty = true
+	for s.Scan() {
+		u := s.Record()
+
+		switch u.Type {
+		case BOOT_TIME: // type 2
+			events = append(events, SystemEvent{
+				Type:   SYS_BOOT,
+				Time:   Time(u.TV),
+				Kernel: Str(u.Host[:])})
+
+		case RUN_LVL: // type 1
+			rl, _ := u.RunLevel()
+			e := SystemEvent{
+				Type:     SYS_RUN_LEVEL,
+				Time:     Time(u.TV),
+				RunLevel: RunLevelStr(rl),
+				Kernel:   Str(u.Host[:])}
+			if prev, ok := u.PrevRunLevel(); ok {
+				e.PrevLevel = RunLevelStr(prev)
+			}
+			if Str(u.User[:]) == "shutdown" {
+				e.Type = SYS_SHUTDOWN
+			}
+			events = append(events, e)
+
+		case OLD_TIME: // type 4
+			old = &SystemEvent{Type: SYS_CLOCK, OldTime: Time(u.TV)}
+
+		case NEW_TIME: // type 3
+			e := SystemEvent{Type: SYS_CLOCK, NewTime: Time(u.TV)}
+			if old != nil {
+				e.OldTime = old.OldTime
+				e.Delta = e.NewTime.Sub(e.OldTime)
+				old = nil
+			}
+			e.Time = e.NewTime
+			events = append(events, e)
+		} // switch
+	} // for
+
+	if old != nil { // OLD_TIME without NEW_TIME
+		old.Time = old.OldTime
+		events = append(events, *old)
+	}
+
+	return events, s.Err()
+}
+
+// EOF: "system.go"
@@ -0,0 +1,100 @@
+// File: "system_test.go"
+
+package utmp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Уровень выполнения для поля PID записи RUN_LVL (new + 256 * prev).
+func runLevelPID(rl, prev rune) uint32 {
+	return uint32(rl) + 256*uint32(prev)
+}
+
+func TestSystemEvents(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		recs []Utmp
+		want []SystemEvent
+	}{{
+		name: "boot",
+		recs: []Utmp{
+			testRecord(BOOT_TIME, 0, "~", "~~", "reboot", "6.1.0", 1000),
+			testRecord(USER_PROCESS, 101, "tty1", "tty1", "alice", "", 1010),
+		},
+		want: []SystemEvent{{Type: SYS_BOOT, Time: time.Unix(1000, 0), Kernel: "6.1.0"}},
+	}, {
+		name: "run level after boot",
+		recs: []Utmp{
+			testRecord(RUN_LVL, runLevelPID('5', 'N'), "~", "~~", "runlevel", "6.1.0", 1001),
+			testRecord(RUN_LVL, runLevelPID('3', '5'), "~", "~~", "runlevel", "6.1.0", 1002),
+		},
+		want: []SystemEvent{
+			{Type: SYS_RUN_LEVEL, Time: time.Unix(1001, 0), RunLevel: "5", PrevLevel: "N", Kernel: "6.1.0"},
+			{Type: SYS_RUN_LEVEL, Time: time.Unix(1002, 0), RunLevel: "3", PrevLevel: "5", Kernel: "6.1.0"},
+		},
+	}, {
+		name: "previous level unknown",
+		recs: []Utmp{
+			testRecord(RUN_LVL, runLevelPID('3', 0), "~", "~~", "runlevel", "", 1001),
+		},
+		want: []SystemEvent{{Type: SYS_RUN_LEVEL, Time: time.Unix(1001, 0), RunLevel: "3"}},
+	}, {
+		name: "shutdown",
+		recs: []Utmp{
+			testRecord(RUN_LVL, runLevelPID('0', '5'), "~", "~~", "shutdown", "6.1.0", 2000),
+		},
+		want: []SystemEvent{
+			{Type: SYS_SHUTDOWN, Time: time.Unix(2000, 0), RunLevel: "0", PrevLevel: "5", Kernel: "6.1.0"},
+		},
+	}, {
+		name: "clock change",
+		recs: []Utmp{
+			testRecord(OLD_TIME, 0, "|", "", "", "", 1000),
+			testRecord(NEW_TIME, 0, "{", "", "", "", 4600),
+			testRecord(OLD_TIME, 0, "|", "", "", "", 5000),
+			testRecord(NEW_TIME, 0, "{", "", "", "", 4990),
+		},
+		want: []SystemEvent{
+			{Type: SYS_CLOCK, Time: time.Unix(4600, 0), OldTime: time.Unix(1000, 0),
+				NewTime: time.Unix(4600, 0), Delta: time.Hour},
+			{Type: SYS_CLOCK, Time: time.Unix(4990, 0), OldTime: time.Unix(5000, 0),
+				NewTime: time.Unix(4990, 0), Delta: -10 * time.Second},
+		},
+	}, {
+		name: "unpaired clock records",
+		recs: []Utmp{
+			testRecord(NEW_TIME, 0, "{", "", "", "", 3000),
+			testRecord(OLD_TIME, 0, "|", "", "", "", 4000),
+		},
+		want: []SystemEvent{
+			{Type: SYS_CLOCK, Time: time.Unix(3000, 0), NewTime: time.Unix(3000, 0)},
+			{Type: SYS_CLOCK, Time: time.Unix(4000, 0), OldTime: time.Unix(4000, 0)},
+		},
+	}, {
+		name: "no system events",
+		recs: []Utmp{
+			testRecord(USER_PROCESS, 101, "tty1", "tty1", "alice", "", 1010),
+			testRecord(DEAD_PROCESS, 101, "tty1", "tty1", "", "", 1020),
+		},
+		want: []SystemEvent{},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			events, err := SystemEvents(testFile(t, tc.recs...))
+			require.NoError(t, err)
+			require.Equal(t, tc.want, events)
+		})
+	}
+}
+
+func TestSystemEventType(t *testing.T) {
+	require.Equal(t, "boot", SYS_BOOT.String())
+	require.Equal(t, "clock", SYS_CLOCK.String())
+	require.Equal(t, "", SystemEventType(-1).String())
+	require.Equal(t, "", SystemEventType(100).String())
+}
+
+// EOF: "system_test.go"
@@ -0,0 +1,212 @@
+// File: "tree.go"
+
+package utmp
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Процесс дерева процессов сеанса.
+// Process of session process tree.
+type ProcNode struct {
+	PID        uint32      // Process ID
+	PGRP       int         // Process group ID
+	Cmd        string      // Command line ("[comm]" if empty)
+	Start      time.Time   // Start time
+	Foreground bool        // In foreground process group of its terminal
+	Children   []*ProcNode // Child processes (ordered by start time)
+}
+
+// Сведения о процессе из /proc/<pid>/stat.
+type procStat struct {
+	pid, ppid, pgrp, tpgid int
+	tty                    uint64 // tty_nr (0 - no terminal)
+	start                  uint64 // starttime (ticks since boot)
+	comm                   string
+}
+
+// Прочитать /proc/<pid>/stat всех процессов (процессы, завершившиеся
+// во время обхода /proc, пропускаются).
+func procStats() ([]procStat, error) {
+	dir, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+	list := make([]procStat, 0, len(dir))
+	for _, e := range dir {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue // not a process
+		}
+		data, err := os.ReadFile("/proc/" + e.Name() + "/stat")
+		if err != nil {
+			continue // process is gone
+		}
+		// "pid (comm) state ppid pgrp session tty_nr tpgid ... starttime ..."
+		i, j := bytes.IndexByte(data, '('), bytes.LastIndexByte(data, ')')
+		if i < 0 || j < i {
+			continue
+		}
+		fds := strings.Fields(string(data[j+1:]))
+		if len(fds) < 20 {
+			continue
+		}
+		p := procStat{pid: pid, comm: string(data[i+1 : j])}
+		p.ppid, _ = strconv.Atoi(fds[1])
+		p.pgrp, _ = strconv.Atoi(fds[2])
+		p.tty, _ = strconv.ParseUint(fds[4], 10, 64)
+		p.tpgid, _ = strconv.Atoi(fds[5])
+		p.start, _ = strconv.ParseUint(fds[19], 10, 64)
+		list = append(list, p)
+	}
+	return list, nil
+}
+
+// Построить дерево процессов с корнем pid по списку процессов.
+func procTree(procs []procStat, pid int) *ProcNode {
+	children := make(map[int][]int, len(procs)) // PPID -> indexes
+	root := -1
+	for i := range procs {
+		children[procs[i].ppid] = append(children[procs[i].ppid], i)
+		if procs[i].pid == pid {
+			root = i
+		}
+	}
+	if root < 0 {
+		return nil
+	}
+	boot, _ := bootTime()
+	var build func(i, depth int) *ProcNode
+	build = func(i, depth int) *ProcNode {
+		p := &procs[i]
+		n := &ProcNode{
+			PID:        uint32(p.pid),
+			PGRP:       p.pgrp,
+			Start:      boot.Add(time.Duration(p.start) * time.Second / CLK_TCK),
+			Foreground: p.tty != 0 && p.pgrp == p.tpgid}
+		if cmd, err := GetCmdline(n.PID); err == nil && cmd != "" {
+			n.Cmd = cmd
+		} else {
+			n.Cmd = "[" + p.comm + "]"
+		}
+		if depth < 64 { // PID loops are impossible, but be careful
+			for _, c := range children[p.pid] {
+				n.Children = append(n.Children, build(c, depth+1))
+			}
+		}
+		sort.SliceStable(n.Children, func(a, b int) bool {
+			return n.Children[a].Start.Before(n.Children[b].Start)
+		})
+		return n
+	}
+	return build(root, 0)
+}
+
+// Получить дерево процессов с корнем pid (лидер сеанса).
+// Get process tree of process.
+func GetProcTree(pid uint32) (*ProcNode, error) {
+	if Offline() {
+		return nil, ErrOffline
+	}
+	defer perfProc(time.Now())
+	procs, err := procStats()
+	if err != nil {
+		return nil, err
+	}
+	n := procTree(procs, int(pid))
+	if n == nil {
+		return nil, fmt.Errorf("no process %d", pid)
+	}
+	return n, nil
+}
+
+// Обойти дерево процессов в глубину (depth - глубина от корня).
+// Walk process tree.
+func (n *ProcNode) Walk(fn func(p *ProcNode, depth int)) {
+	var walk func(p *ProcNode, depth int)
+	walk = func(p *ProcNode, depth int) {
+		fn(p, depth)
+		for _, c := range p.Children {
+			walk(c, depth+1)
+		}
+	}
+	if n != nil {
+		walk(n, 0)
+	}
+}
+
+// Текущая команда сеанса, как в колонке WHAT утилиты `w`: лидер группы
+// процессов переднего плана терминала (или последний запущенный процесс
+// этой группы), без терминала - последний запущенный процесс дерева.
+// Foreground or most recent process of tree.
+func (n *ProcNode) What() *ProcNode {
+	var fg, last *ProcNode
+	n.Walk(func(p *ProcNode, depth int) {
+		if p.Foreground {
+			switch {
+			case fg == nil:
+				fg = p
+			case int(fg.PID) == fg.PGRP: // group leader is found
+			case int(p.PID) == p.PGRP || p.Start.After(fg.Start):
+				fg = p
+			}
+		}
+		if last == nil || !p.Start.Before(last.Start) {
+			last = p
+		}
+	})
+	if fg != nil {
+		return fg
+	}
+	return last
+}
+
+// Напечатать дерево процессов (как `ps f`) с отступом indent.
+// Print process tree.
+func (n *ProcNode) Print(w io.Writer, indent string) {
+	n.Walk(func(p *ProcNode, depth int) {
+		mark := " "
+		if p.Foreground {
+			mark = "+"
+		}
+		fmt.Fprintf(w, "%s%7d%s %s%s\n", indent, p.PID, mark,
+			strings.Repeat("  ", depth), p.Cmd)
+	})
+}
+
+// Определить текущие команды сеансов по деревьям процессов лидеров
+// сеансов (см. ProcNode.What()). Заполняет User.What и, если tree,
+// User.Procs. В режиме автономного анализа ничего не делает. Имеет смысл
+// только для utmp текущего узла.
+// Detect current commands of sessions.
+func (users Users) DetectWhat(tree bool) {
+	if len(users) == 0 || Offline() {
+		return
+	}
+	procs, err := procStats()
+	if err != nil {
+		return // no /proc
+	}
+	for _, u := range users {
+		if u.Stale || u.offline || u.PID == 0 {
+			continue
+		}
+		n := procTree(procs, int(u.PID))
+		if n == nil {
+			continue // process is gone
+		}
+		u.What = n.What().Cmd
+		if tree {
+			u.Procs = n
+		}
+	}
+}
+
+// EOF: "tree.go"
@@ -0,0 +1,66 @@
+// File: "tree_test.go"
+
+package utmp
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcTree(t *testing.T) {
+	// sshd(100) -> bash(101, pts) -> vim(110, foreground) + make(120) -> cc(121)
+	procs := []procStat{
+		{pid: 100, ppid: 1, pgrp: 100, tpgid: -1, start: 10, comm: "sshd"},
+		{pid: 101, ppid: 100, pgrp: 101, tty: 34816, tpgid: 110, start: 11, comm: "bash"},
+		{pid: 120, ppid: 101, pgrp: 120, tty: 34816, tpgid: 110, start: 30, comm: "make"},
+		{pid: 121, ppid: 120, pgrp: 120, tty: 34816, tpgid: 110, start: 31, comm: "cc"},
+		{pid: 110, ppid: 101, pgrp: 110, tty: 34816, tpgid: 110, start: 20, comm: "vim"},
+		{pid: 200, ppid: 1, pgrp: 200, start: 5, comm: "cron"},
+	}
+	n := procTree(procs, 100)
+	require.NotNil(t, n)
+	var pids []uint32
+	n.Walk(func(p *ProcNode, depth int) { pids = append(pids, p.PID) })
+	require.Equal(t, []uint32{100, 101, 110, 120, 121}, pids) // by start time
+	require.Equal(t, uint32(110), n.What().PID)
+
+	// No foreground process: most recent one
+	for i := range procs {
+		procs[i].tpgid = -1
+	}
+	n = procTree(procs, 100)
+	require.Equal(t, uint32(121), n.What().PID)
+	require.Nil(t, procTree(procs, 4242))
+
+	var buf bytes.Buffer
+	n.Print(&buf, "")
+	require.Contains(t, buf.String(), "    121 ")
+}
+
+func TestDetectWhat(t *testing.T) {
+	n, err := GetProcTree(uint32(os.Getpid()))
+	if err != nil {
+		t.Skip("no /proc:", err)
+	}
+	require.Equal(t, uint32(os.Getpid()), n.PID)
+	require.NotEmpty(t, n.Cmd)
+
+	users := Users{{Name: "alice", PID: uint32(os.Getpid())}, {Name: "bob"}}
+	users.DetectWhat(true)
+	require.NotEmpty(t, users[0].What)
+	require.NotNil(t, users[0].Procs)
+	require.Empty(t, users[1].What)
+
+	SetOffline(true)
+	defer SetOffline(false)
+	users[0].What = ""
+	users.DetectWhat(false)
+	require.Empty(t, users[0].What) // no-op
+	_, err = GetProcTree(1)
+	require.ErrorIs(t, err, ErrOffline)
+}
+
+// EOF: "tree_test.go"
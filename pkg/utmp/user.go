@@ -3,58 +3,42 @@
 package utmp
 
 import (
-	"os/user"
 	"strconv"
-	"strings"
+	"time"
 )
 
 // Получить эффективное имя пользователя по Process ID.
 // Get effective username by PID.
 func GetUserByPID(pid uint32) (username string, err error) {
+	db := currentUserDB(Offline())
+	if db == nil {
+		return "", ErrOffline
+	}
 	euid, err := GetEUID(pid)
 	if err != nil {
 		return "", err
 	}
 
-	u, err := user.LookupId(strconv.Itoa(euid))
+	defer perfUser(time.Now())
+	u, err := db.LookupUID(strconv.Itoa(euid))
 	if err != nil {
-		return "", err
+		return "", noSuchUser(err)
 	}
-	return u.Username, nil
+	return u.Name, nil
 }
 
-// Получить информацию о пользователе из стандартной структуры `os/user.User`.
-// Get user info by username delivered from `os/user.User`
+// Получить информацию о пользователе из базы пользователей (см. SetUserDB(),
+// по умолчанию - стандартная структура `os/user.User`).
+// Если пользователь не найден - ошибка ErrNoSuchUser.
+// Get user info by username from user database.
 func GetUserInfo(username string) (info *UserInfo, err error) {
-	u, err := user.Lookup(username)
-	if err != nil {
-		return nil, err
-	}
-
-	info = &UserInfo{
-		UID:         u.Uid,
-		GID:         u.Gid,
-		Name:        u.Username,
-		DisplayName: u.Name,
-		HomeDir:     u.HomeDir}
-
-	// Find groups that the user is a member of
-	gids, err := u.GroupIds()
-	if err != nil {
-		return nil, err
+	db := currentUserDB(Offline())
+	if db == nil {
+		return nil, ErrOffline
 	}
-
-	var groups []string
-	for _, gid := range gids {
-		grp, err := user.LookupGroupId(gid)
-		if err != nil {
-			return info, err
-		}
-		groups = append(groups, grp.Name)
-	}
-
-	info.Groups = strings.Join(groups, ",")
-	return info, nil
+	defer perfUser(time.Now())
+	info, err = db.LookupUser(username)
+	return info, noSuchUser(err)
 }
 
 // EOF: "user.go"
@@ -0,0 +1,254 @@
+// File: "userdb.go"
+
+package utmp
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// Источник информации о пользователях и группах (база пользователей
+// текущего узла или passwd/group файлы другой системы).
+// User/group database.
+type UserDB interface {
+	LookupUser(name string) (*UserInfo, error) // by username
+	LookupUID(uid string) (*UserInfo, error)   // by user ID
+	LookupGID(gid string) (string, error)      // group name by group ID
+}
+
+// База пользователей текущего узла (os/user).
+// User database of running host.
+type OSUserDB struct{}
+
+// База пользователей из passwd/group файлов (например, смонтированного
+// образа диска или корня контейнера). Неизменяема после создания.
+// User database read from passwd/group files.
+type FileUserDB struct {
+	users  map[string]*passwdEntry // by name
+	uids   map[string]*passwdEntry // by UID
+	groups map[string]string       // GID -> name
+	member map[string][]string     // username -> supplementary GIDs
+}
+
+// Запись passwd файла.
+type passwdEntry struct {
+	name, uid, gid, gecos, home string
+}
+
+// Текущая база пользователей (nil - OSUserDB).
+var userDB atomic.Pointer[UserDB]
+
+// Задать базу пользователей (nil - база текущего узла).
+// Кэш информации о пользователях сбрасывается.
+// Set user database (thread safe).
+func SetUserDB(db UserDB) {
+	if db == nil {
+		userDB.Store(nil)
+	} else {
+		userDB.Store(&db)
+	}
+	InvalidateUserInfo()
+}
+
+// Получить базу пользователей: заданную SetUserDB() или базу текущего
+// узла (nil, если offline - база текущего узла не используется).
+func currentUserDB(offline bool) UserDB {
+	if db := userDB.Load(); db != nil {
+		return *db
+	}
+	if offline {
+		return nil
+	}
+	return OSUserDB{}
+}
+
+func (OSUserDB) LookupUser(name string) (*UserInfo, error) {
+	u, err := user.Lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	return osUserInfo(u)
+}
+
+func (OSUserDB) LookupUID(uid string) (*UserInfo, error) {
+	u, err := user.LookupId(uid)
+	if err != nil {
+		return nil, err
+	}
+	return osUserInfo(u)
+}
+
+func (OSUserDB) LookupGID(gid string) (string, error) {
+	g, err := user.LookupGroupId(gid)
+	if err != nil {
+		return "", err
+	}
+	return g.Name, nil
+}
+
+// Получить информацию о пользователе из стандартной структуры `os/user.User`.
+func osUserInfo(u *user.User) (*UserInfo, error) {
+	info := &UserInfo{
+		UID:         u.Uid,
+		GID:         u.Gid,
+		Name:        u.Username,
+		DisplayName: u.Name,
+		HomeDir:     u.HomeDir}
+
+	// Find groups that the user is a member of
+	gids, err := u.GroupIds()
+	if err != nil {
+		return nil, err
+	}
+
+	var groups []string
+	for _, gid := range gids {
+		grp, err := user.LookupGroupId(gid)
+		if err != nil {
+			return info, err
+		}
+		groups = append(groups, grp.Name)
+	}
+
+	info.Groups = strings.Join(groups, ",")
+	return info, nil
+}
+
+// Прочитать базу пользователей из passwd и group файлов.
+// Read user database from passwd and group files.
+func ReadUserDB(passwd, group string) (*FileUserDB, error) {
+	db := &FileUserDB{
+		users:  make(map[string]*passwdEntry),
+		uids:   make(map[string]*passwdEntry),
+		groups: make(map[string]string),
+		member: make(map[string][]string)}
+
+	// line: "name:password:UID:GID:GECOS:directory:shell"
+	err := readColonFile(passwd, 6, func(fds []string) {
+		e := &passwdEntry{
+			name:  fds[0],
+			uid:   fds[2],
+			gid:   fds[3],
+			gecos: fds[4],
+			home:  fds[5]}
+		if _, ok := db.users[e.name]; !ok { // first entry wins
+			db.users[e.name] = e
+		}
+		if _, ok := db.uids[e.uid]; !ok {
+			db.uids[e.uid] = e
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// line: "group_name:password:GID:user_list"
+	err = readColonFile(group, 4, func(fds []string) {
+		if _, ok := db.groups[fds[2]]; !ok {
+			db.groups[fds[2]] = fds[0]
+		}
+		for _, name := range strings.Split(fds[3], ",") {
+			if name != "" {
+				db.member[name] = append(db.member[name], fds[2])
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// Прочитать базу пользователей из etc/passwd и etc/group каталога
+// `root` (корень образа диска или контейнера).
+// Read user database of system mounted at root.
+func ReadUserDBRoot(root string) (*FileUserDB, error) {
+	return ReadUserDB(
+		filepath.Join(root, "etc", "passwd"),
+		filepath.Join(root, "etc", "group"))
+}
+
+// Прочитать файл из строк с полями, разделёнными ':' (комментарии
+// и строки NIS "+"/"-" пропускаются).
+func readColonFile(fname string, n int, fn func(fds []string)) error {
+	file, err := os.Open(fname)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == '+' || line[0] == '-' {
+			continue
+		}
+		fds := strings.Split(line, ":")
+		if len(fds) < n {
+			continue // malformed line
+		}
+		fn(fds)
+	}
+	if err = scanner.Err(); err != nil {
+		return fmt.Errorf("%s: %w", fname, err)
+	}
+	return nil
+}
+
+func (db *FileUserDB) LookupUser(name string) (*UserInfo, error) {
+	e, ok := db.users[name]
+	if !ok {
+		return nil, user.UnknownUserError(name)
+	}
+	return db.info(e), nil
+}
+
+func (db *FileUserDB) LookupUID(uid string) (*UserInfo, error) {
+	e, ok := db.uids[uid]
+	if !ok {
+		id, _ := strconv.Atoi(uid)
+		return nil, user.UnknownUserIdError(id)
+	}
+	return db.info(e), nil
+}
+
+func (db *FileUserDB) LookupGID(gid string) (string, error) {
+	name, ok := db.groups[gid]
+	if !ok {
+		return "", user.UnknownGroupIdError(gid)
+	}
+	return name, nil
+}
+
+// Информация о пользователе по записи passwd (основная группа первой).
+func (db *FileUserDB) info(e *passwdEntry) *UserInfo {
+	name, _, _ := strings.Cut(e.gecos, ",") // full name (as os/user)
+	info := &UserInfo{
+		Name:        e.name,
+		UID:         e.uid,
+		GID:         e.gid,
+		DisplayName: name,
+		HomeDir:     e.home}
+
+	groups := []string{}
+	seen := make(map[string]bool)
+	for _, gid := range append([]string{e.gid}, db.member[e.name]...) {
+		if seen[gid] {
+			continue
+		}
+		seen[gid] = true
+		if g, ok := db.groups[gid]; ok {
+			groups = append(groups, g)
+		}
+	}
+	info.Groups = strings.Join(groups, ",")
+	return info
+}
+
+// EOF: "userdb.go"
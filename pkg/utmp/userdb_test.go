@@ -0,0 +1,136 @@
+// File: "userdb_test.go"
+
+package utmp
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileUserDB(t *testing.T) {
+	root := t.TempDir()
+	etc := filepath.Join(root, "etc")
+	require.NoError(t, os.Mkdir(etc, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(etc, "passwd"), []byte(
+		"# comment\n"+
+			"root:x:0:0:root:/root:/bin/bash\n"+
+			"alice:x:1000:1000:Alice Smith,,,:/home/alice:/bin/bash\n"+
+			"broken line\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(etc, "group"), []byte(
+		"root:x:0:\n"+
+			"alice:x:1000:\n"+
+			"wheel:x:10:alice,bob\n"+
+			"docker:x:999:alice\n"), 0644))
+
+	db, err := ReadUserDBRoot(root)
+	require.NoError(t, err)
+
+	info, err := db.LookupUser("alice")
+	require.NoError(t, err)
+	require.Equal(t, UserInfo{
+		Name:        "alice",
+		UID:         "1000",
+		GID:         "1000",
+		DisplayName: "Alice Smith",
+		HomeDir:     "/home/alice",
+		Groups:      "alice,wheel,docker"}, *info)
+
+	info, err = db.LookupUID("0")
+	require.NoError(t, err)
+	require.Equal(t, "root", info.Name)
+
+	_, err = db.LookupUser("bob")
+	require.ErrorAs(t, err, new(user.UnknownUserError))
+
+	// Offline analysis resolves users by file database
+	SetUserDB(db)
+	defer SetUserDB(nil)
+	SetOffline(true)
+	defer SetOffline(false)
+
+	info, err = GetUserInfo("alice")
+	require.NoError(t, err)
+	require.Equal(t, "1000", info.UID)
+	require.Equal(t, "alice", GroupOf("alice"))
+}
+
+// База пользователей со счётчиком запросов.
+type countingDB struct {
+	UserDB
+	n atomic.Int32
+}
+
+func (db *countingDB) LookupUser(name string) (*UserInfo, error) {
+	db.n.Add(1)
+	return db.UserDB.LookupUser(name)
+}
+
+func TestUserInfoCache(t *testing.T) {
+	fdb := &FileUserDB{
+		users:  map[string]*passwdEntry{"alice": {name: "alice", uid: "1000", gid: "1000"}},
+		groups: map[string]string{"1000": "alice"}}
+	db := &countingDB{UserDB: fdb}
+	SetUserDB(db)
+	defer SetUserDB(nil)
+	defer SetUserInfoTTL(USER_INFO_TTL)
+
+	users := Users{{Name: "alice"}}
+	for i := 0; i < 3; i++ {
+		li, err := users.GetLoginInfo("alice")
+		require.NoError(t, err)
+		require.Equal(t, "1000", li.UID)
+	}
+	require.EqualValues(t, 1, db.n.Load())
+
+	// Errors are not cached
+	_, err := users.GetLoginInfo("bob")
+	require.Error(t, err)
+	_, err = users.GetLoginInfo("bob")
+	require.Error(t, err)
+	require.EqualValues(t, 3, db.n.Load())
+
+	InvalidateUserInfo("alice")
+	_, err = users.GetLoginInfo("alice")
+	require.NoError(t, err)
+	require.EqualValues(t, 4, db.n.Load())
+
+	// Expired entries are looked up again
+	SetUserInfoTTL(time.Nanosecond)
+	_, err = users.GetLoginInfo("alice")
+	require.NoError(t, err)
+	time.Sleep(time.Millisecond)
+	_, err = users.GetLoginInfo("alice")
+	require.NoError(t, err)
+	require.EqualValues(t, 6, db.n.Load())
+}
+
+func TestResolveUserInfo(t *testing.T) {
+	fdb := &FileUserDB{users: map[string]*passwdEntry{
+		"alice": {name: "alice", uid: "1000"},
+		"bob":   {name: "bob", uid: "1001"},
+		"carol": {name: "carol", uid: "1002"}}}
+	db := &countingDB{UserDB: fdb}
+	SetUserDB(db)
+	defer SetUserDB(nil)
+	SetUserInfoTTL(0) // no cache: count every lookup
+	defer SetUserInfoTTL(USER_INFO_TTL)
+
+	names := []string{"alice", "bob", "alice", "carol", "dave", "bob", "alice"}
+	for _, workers := range []int{1, 3, 0} {
+		db.n.Store(0)
+		res := resolveUserInfo(names, workers)
+		require.EqualValues(t, 4, db.n.Load()) // one lookup per user
+		require.Len(t, res, 4)
+		require.Equal(t, "1001", res["bob"].info.UID)
+		require.Equal(t, "1002", res["carol"].info.UID)
+		require.Error(t, res["dave"].err)
+	}
+}
+
+// EOF: "userdb_test.go"
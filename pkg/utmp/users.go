@@ -3,11 +3,8 @@
 package utmp
 
 import (
-	"errors"
-	"io"
+	"context"
 	"net"
-	"os"
-	"regexp"
 	"sort"
 	"time"
 )
@@ -23,6 +20,55 @@ type User struct {
 	SID  int32     // Session ID
 	ID   string    // Terminal name suffix
 	Time time.Time // Time
+
+	EUser    string // Effective user of su/sudo session (see GetUsersOpts.Elevated)
+	Elevated bool   // Session has processes with other effective user (su/sudo)
+
+	Multiplexed bool // Entry created by tmux/screen (see GetUsersOpts.Mux)
+
+	SSH  *SSHConn  // SSH connection of remote session (see GetUsersOpts.SSH)
+	Auth *AuthInfo // SSH authentication of session (see pkg/authlog)
+
+	Idle time.Duration // TTY idle time, -1 if unknown (see GetUsersOpts.Idle)
+
+	What  string    // Current command of session (see GetUsersOpts.What)
+	Procs *ProcNode // Process tree of session leader (see GetUsersOpts.Tree)
+
+	Stale bool // PID is reused by unrelated process (see GetUsersOpts.Stale)
+
+	offline bool  // offline analysis (see GetUsersOpts.Offline)
+	raw     *Utmp // login record (see LoginOpts.RawRecords)
+}
+
+// Допустимое превышение времени запуска процесса над временем записи
+// utmp (неточность времени загрузки системы, коррекция часов).
+// Max process start time after utmp record time for live session.
+var StaleSlack = 5 * time.Second
+
+// Проверить, что PID записи не занят посторонним процессом: процесс,
+// запущенный позже записи о входе (более чем на StaleSlack), не может
+// быть лидером сеанса - запись устарела, PID использован повторно.
+// Устанавливает и возвращает User.Stale (если процесса нет или
+// /proc недоступен - false).
+// Check PID of user entry is reused by unrelated process.
+func (u *User) CheckStale() bool {
+	u.Stale = false
+	if u.PID == 0 || u.offline {
+		return false
+	}
+	start, err := GetProcStart(u.PID)
+	if err == nil && start.Sub(u.Time) > StaleSlack {
+		u.Stale = true
+	}
+	return u.Stale
+}
+
+// PID лидера сеанса для обращения к /proc (0 для устаревших записей).
+func (u *User) procPID() uint32 {
+	if u.Stale || u.offline {
+		return 0
+	}
+	return u.PID
 }
 
 // Список пользователей в системе на основе `utmp` файла.
@@ -37,31 +83,17 @@ func (u UsersByTime) Len() int           { return len(u) }
 func (u UsersByTime) Swap(i, j int)      { u[i], u[j] = u[j], u[i] }
 func (u UsersByTime) Less(i, j int) bool { return u[i].Time.Before(u[j].Time) }
 
-// Определить тип входа пользователя по данным из `utmp` файла.
+// Определить тип входа пользователя по данным из `utmp` файла
+// (см. Classifier).
 // Get user logon type (0...4).
 func (u *User) LoginType() LoginType {
-	reX := regexp.MustCompile("^:[0-9]+$") // user logged to X
-	reRDP := regexp.MustCompile(XRDP_CMD)  // user logged by XRDP
-	msX := reX.MatchString
-	msRDP := reRDP.MatchString
-
-	t := UNKNOWN
-	if msX(u.Host) || msX(u.ID) || msX(u.TTY) { // e.g. ":1"
-		if u.IP.Equal(net.IP{}) { // IP is empty
-			t = LOCAL_X
-			cmd, err := GetCmdline(u.PID)
-			if err == nil && msRDP(cmd) {
-				t = REMOTE_X // XRDP
-			}
-		}
-	} else {
-		if u.IP.Equal(net.IP{}) && u.Host == "" { // IP and Host is empty
-			t = LOCAL
-		} else {
-			t = REMOTE
-		}
-	}
-	return t
+	return Classify(u)
+}
+
+// Получить метку сети, из которой вошел пользователь (см. `Config`).
+// Get network label of remote user.
+func (u *User) Network() string {
+	return NetworkLabel(u.IP)
 }
 
 // Чтение utmp файла и формирования списка пользователей системы,
@@ -69,113 +101,229 @@ func (u *User) LoginType() LoginType {
 // (fname - путь к файлу utmp, обычно "/var/run/utmp").
 // Get users currently logged in to the current host (fname - path to utmp file).
 func GetUsers(fname string, useEUID bool) (Users, error) {
+	return GetUsersWith(fname, GetUsersOpts{UseEUID: useEUID})
+}
+
+// Вариант GetUsers() с дополнительными опциями (см. `GetUsersOpts`).
+// Get users currently logged in with options.
+func GetUsersWith(fname string, opts GetUsersOpts) (Users, error) {
+	return GetUsersContext(context.Background(), fname, opts)
+}
+
+// Вариант GetUsersWith() с возможностью прервать чтение длинного файла
+// отменой контекста (возвращается ошибка контекста).
+// Get users currently logged in with cancellation.
+func GetUsersContext(ctx context.Context, fname string, opts GetUsersOpts) (Users, error) {
+	b, err := readUserBase(ctx, fname, opts, false)
+	if err != nil {
+		return Users{}, err
+	}
+	return b.users(), nil
+} // func UsersRead()
+
+// Прочитать utmp/wtmp/btmp файл и получить множество вошедших
+// пользователей (raw - сохранять исходные записи, см. userBase.raw).
+func readUserBase(ctx context.Context, fname string, opts GetUsersOpts, raw bool) (*userBase, error) {
 	if fname == "" {
 		fname = DefaultFile
 	}
 
 	// Open utmp/wtmp/btmp file
-	f, err := os.Open(fname)
+	f, err := opts.Open(fname)
 	if err != nil {
-		return Users{}, err // can't open file
+		return nil, err // can't open file
 	}
 	defer f.Close()
 
-	// инициализировать множества пользователей в системе
-	base := make(map[UserTTY]*User)
-	pbase := make(map[TTYPID]*User)
-	ibase := make(map[TTYID]*User)
-
-	// Read utmp/wtmp/btmp file
-	for {
-		var u Utmp
-		err = Read(f, &u)
-		if err != nil {
-			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
-				break
+	// время текущей загрузки системы
+	var boot time.Time
+	if opts.CurrentBoot {
+		boot, _ = GetBootTime() // zero if unknown
+	}
+
+	// Read utmp/wtmp/btmp file (skip EMPTY and partially zeroed slots)
+	b := newUserBase(opts, boot)
+	b.raw = raw
+	s := opts.NewScannerContext(ctx, f)
+	s.SkipEmpty = true
+	for s.Scan() {
+		b.add(s.Record())
+	} // for
+	if err = s.Err(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Множества вошедших пользователей при последовательном разборе записей
+// utmp/wtmp/btmp файла.
+type userBase struct {
+	opts      GetUsersOpts
+	offline   bool                // offline analysis
+	useEUID   bool                // use EUID(PID)
+	boot      time.Time           // current boot time (or zero)
+	normalize func(string) string // username normalization
+	base      map[UserTTY]*User   // logged users by user+TTY
+	pbase     map[TTYPID]*User    // logged users by TTY+PID
+	ibase     map[TTYID]*User     // logged users by TTY+ID
+
+	// Сохранять исходные записи входа (User.raw) и выхода (dead)
+	raw  bool
+	dead map[UserTTY]Utmp // last logout records by user+TTY
+}
+
+// Создать пустое множество вошедших пользователей.
+func newUserBase(opts GetUsersOpts, boot time.Time) *userBase {
+	offline := opts.Offline || Offline()
+	b := &userBase{
+		opts:      opts,
+		offline:   offline,
+		useEUID:   opts.UseEUID && !offline,
+		boot:      boot,
+		normalize: newNormalizer()}
+	b.reset()
+	return b
+}
+
+// Очистить множества (загрузка системы).
+func (b *userBase) reset() {
+	b.base = make(map[UserTTY]*User)
+	b.pbase = make(map[TTYPID]*User)
+	b.ibase = make(map[TTYID]*User)
+}
+
+// Учесть очередную запись utmp/wtmp/btmp файла.
+func (b *userBase) add(u *Utmp) {
+	if !b.opts.Until.IsZero() && Time(u.TV).After(b.opts.Until) {
+		return // skip records after time window (before Since - state only)
+	}
+
+	Type := int(u.Type)
+	if Type == BOOT_TIME { // type 2
+		b.reset()
+	} else if Type == USER_PROCESS || Type == DEAD_PROCESS { // type 7 or 8
+		user := b.normalize(Str(u.User[:]))
+		pid := u.ProcessID()
+		tty := Str(u.Line[:])
+		id := Str(u.ID[:])
+
+		ut := UserTTY{user, tty}
+		tp := TTYPID{tty, pid}
+		ti := TTYID{tty, id}
+
+		p, ok := b.base[ut]
+
+		if Type == USER_PROCESS { // user login
+			if IsIgnored(user) {
+				return // skip ignored user
+			}
+			if Time(u.TV).Before(b.boot) {
+				return // skip login before current boot
 			}
-			return Users{}, err
-		}
 
-		Type := int(u.Type)
-		if Type == BOOT_TIME { // type 2
-			base = make(map[UserTTY]*User)
-			pbase = make(map[TTYPID]*User)
-			ibase = make(map[TTYID]*User)
-		} else if Type == USER_PROCESS || Type == DEAD_PROCESS { // type 7 or 8
-			user := Str(u.User[:])
-			pid := PID(u.PID)
-			tty := Str(u.Line[:])
-			id := Str(u.ID[:])
-
-			ut := UserTTY{user, tty}
-			tp := TTYPID{tty, pid}
-			ti := TTYID{tty, id}
-
-			p, ok := base[ut]
-
-			if Type == USER_PROCESS { // user login
-				nu := User{
-					Name: user,
-					PID:  pid,
-					TTY:  tty,
-					Host: Str(u.Host[:]),
-					IP:   IPv4(u.AddrV6),
-					SID:  u.Session,
-					ID:   Str(u.ID[:]),
-					Time: Time(u.TV),
-				}
+			nu := User{
+				Name: user,
+				PID:  pid,
+				TTY:  tty,
+				Host: Str(u.Host[:]),
+				IP:   IPv4(u.AddrV6),
+				SID:  u.Session,
+				ID:   Str(u.ID[:]),
+				Time: Time(u.TV),
 
-				Type := nu.LoginType()
-				if Type == LOCAL && useEUID { // FIXME: some magic condition
-					// Get real username by effective UID(pid)
-					user, err := GetUserByPID(pid)
-					if err == nil {
-						nu.Name = user
-					} else {
-						// Do not show error (may read wtmp/btmp)
-						// log.Printf("error: %v", err)
-					}
-				}
+				offline: b.offline,
+			}
+			if b.raw {
+				rec := *u
+				nu.raw = &rec
+			}
 
-				if ok {
-					if nu.Time.After(p.Time) {
-						base[ut] = &nu // update base
-						pbase[tp] = &nu
-						ibase[ti] = &nu
-					}
+			if b.useEUID {
+				nu.CheckStale()
+			}
+			Type := nu.LoginType()
+			if Type == LOCAL && b.useEUID && !nu.Stale { // FIXME: some magic condition
+				// Get real username by effective UID(pid)
+				user, err := GetUserByPID(pid)
+				if err == nil {
+					nu.Name = user
 				} else {
-					base[ut] = &nu // add to base
-					pbase[tp] = &nu
-					ibase[ti] = &nu
+					// Do not show error (may read wtmp/btmp)
+					// log.Printf("error: %v", err)
 				}
-			} else { // Type == DEAD_PROCESS => user logout
-				if user == "" {
-					// logout record in wtmp with User=""
-					if u, ok := pbase[tp]; ok { // find logged TTY+PID
-						ut.User = u.Name
-					} else if u, ok := ibase[ti]; ok { // find logged TTY+ID
-						ut.User = u.Name
-					}
+			}
+
+			if ok {
+				if nu.Time.After(p.Time) {
+					b.base[ut] = &nu // update base
+					b.pbase[tp] = &nu
+					b.ibase[ti] = &nu
+				}
+			} else {
+				b.base[ut] = &nu // add to base
+				b.pbase[tp] = &nu
+				b.ibase[ti] = &nu
+			}
+		} else { // Type == DEAD_PROCESS => user logout
+			if user == "" {
+				// logout record in wtmp with User=""
+				if u, ok := b.pbase[tp]; ok { // find logged TTY+PID
+					ut.User = u.Name
+				} else if u, ok := b.ibase[ti]; ok { // find logged TTY+ID
+					ut.User = u.Name
 				}
+			}
 
-				// delete from base
-				delete(base, ut)
-				delete(pbase, tp)
-				delete(ibase, ti)
+			if b.raw {
+				if _, ok := b.base[ut]; ok {
+					if b.dead == nil {
+						b.dead = make(map[UserTTY]Utmp)
+					}
+					b.dead[ut] = *u
+				}
 			}
+
+			// delete from base
+			delete(b.base, ut)
+			delete(b.pbase, tp)
+			delete(b.ibase, ti)
 		}
-	} // for
+	}
+}
 
+// Получить список вошедших пользователей (копии записей, сортированные
+// по времени входа).
+func (b *userBase) users() Users {
 	// Transform map to slice
-	users := make(Users, 0, len(base))
-	for _, u := range base {
-		users = append(users, u)
+	users := make(Users, 0, len(b.base))
+	for _, u := range b.base {
+		nu := *u // base may be updated by next records
+		if !b.useEUID && !b.offline && b.opts.checkStale() {
+			nu.CheckStale()
+		}
+		users = append(users, &nu)
 	}
 
 	// Sort by Time
 	sort.Sort(UsersByTime(users))
-	return users, nil
-} // func UsersRead()
+
+	if !b.offline {
+		if b.opts.Elevated {
+			users.DetectElevated()
+		}
+		if b.opts.SSH {
+			users.DetectSSH()
+		}
+		if b.opts.Idle {
+			users.DetectIdle()
+		}
+		if b.opts.What || b.opts.Tree {
+			users.DetectWhat(b.opts.Tree)
+		}
+		users = users.DetectMultiplexed(b.opts.Mux)
+	}
+	return users
+}
 
 // Get user logon info by username
 func (users Users) GetUserLogin(name string) (ul UserLogin) {
@@ -193,19 +341,55 @@ func (users Users) GetUserLogin(name string) (ul UserLogin) {
 
 // Вернуть полную информацию о пользователе в системе.
 // Fill full user information.
+// Информация о пользователе кэшируется (см. SetUserInfoTTL()).
+// В режиме автономного анализа база пользователей текущего узла
+// не используется (заполняется только имя, если не задана SetUserDB()).
 func (users Users) GetLoginInfo(name string) (*LoginInfo, error) {
-	info, err := GetUserInfo(name)
+	ul := users.GetUserLogin(name)
+	var auth []AuthInfo
+	idle := time.Duration(-1)
+	for _, u := range users {
+		if u.Name != name {
+			continue
+		}
+		if u.Auth != nil {
+			auth = append(auth, *u.Auth)
+		}
+		if u.Idle >= 0 && (idle < 0 || u.Idle < idle) {
+			idle = u.Idle // least idle session
+		}
+	}
+	if users.isOffline() && currentUserDB(true) == nil {
+		return &LoginInfo{
+			UserInfo:  UserInfo{Name: name},
+			UserLogin: ul,
+			Auth:      auth,
+			Idle:      idle}, nil
+	}
+	info, err := cachedUserInfo(name)
 	if err != nil {
 		return nil, err
 	}
-	ul := users.GetUserLogin(name)
 	return &LoginInfo{
 		UserInfo:  *info,
-		UserLogin: ul}, nil
+		UserLogin: ul,
+		Auth:      auth,
+		Idle:      idle}, nil
+}
+
+// Признак автономного анализа (глобальный или для списка пользователей).
+func (users Users) isOffline() bool {
+	return Offline() || (len(users) != 0 && users[0].offline)
 }
 
 // Get logged user statistics
 func (users Users) GetLoginStat() LoginStat {
+	return users.loginStat(users.GetLoginInfo)
+}
+
+// Статистика пользователей (info - источник информации об активном
+// пользователе).
+func (users Users) loginStat(info func(name string) (*LoginInfo, error)) LoginStat {
 	total := make(map[string]int)   // total logged users "Local + Remote + root"
 	localX := make(map[string]int)  // users logged in X session
 	local := make(map[string]int)   // local logged users (excluding root)
@@ -214,15 +398,25 @@ func (users Users) GetLoginStat() LoginStat {
 	unknown := make(map[string]int) // unknown logged users (must be empty)
 	localRoot := false              // local root logged
 	remoteRoot := false             // remote root logged
-	user := (*User)(nil)            // main active user on host or nil
-	Type := UNKNOWN                 // type of active user
 	var active *LoginInfo           // main (active) user
+	groups := make(map[string]int)  // logged users by group
+	offline := users.isOffline()    // no user database lookups
+	var cand []Candidate            // active user candidates
+
+	privileged := make(map[string]bool) // privileged users (root, ...)
 
 	for _, u := range users {
+		if total[u.Name] == 0 {
+			if g := groupOf(u.Name, offline); g != "" {
+				groups[g]++
+			}
+			privileged[u.Name] = isPrivileged(u.Name, offline)
+		}
 		total[u.Name]++
 		t := u.LoginType() // determinate user type
+		cand = append(cand, Candidate{u, t, privileged[u.Name]})
 
-		if u.Name == "root" {
+		if privileged[u.Name] {
 			switch t {
 			case LOCAL_X, LOCAL:
 				localRoot = true
@@ -232,10 +426,6 @@ func (users Users) GetLoginStat() LoginStat {
 				localRoot = true // unknown root as local
 				unknown[u.Name]++
 			} // switch
-
-			if user == nil || user.Name == "root" {
-				user, Type = u, t
-			}
 		} else { // regular user
 			switch t {
 			case LOCAL_X:
@@ -249,19 +439,40 @@ func (users Users) GetLoginStat() LoginStat {
 			default: // UNKNOWN
 				unknown[u.Name]++
 			} // switch
-
-			if user == nil || Type <= t {
-				user, Type = u, t
-			}
 		}
 	} // for
 
-	if user != nil {
-		active, _ = users.GetLoginInfo(user.Name)
+	policy := currentActivePolicy()
+	if user := policy.Select(cand); user != nil {
+		active, _ = info(user.Name)
+	}
+
+	// active user by seat (multi-seat systems)
+	bySeat := make(map[string][]Candidate)
+	for _, c := range cand {
+		if seat := c.seat(c.Type); seat != "" {
+			bySeat[seat] = append(bySeat[seat], c)
+		}
+	}
+	var seats map[string]*LoginInfo
+	for seat, c := range bySeat {
+		if user := policy.Select(c); user != nil {
+			if seats == nil {
+				seats = make(map[string]*LoginInfo)
+			}
+			seats[seat], _ = info(user.Name)
+		}
 	}
 
 	// Return result
 	return LoginStat{
+		LocalXUsers:  sortedKeys(localX),
+		LocalUsers:   sortedKeys(local),
+		RemoteXUsers: sortedKeys(remoteX),
+		RemoteUsers:  sortedKeys(remote),
+		UnknownUsers: sortedKeys(unknown),
+		Logons:       total,
+
 		Total:      len(total),
 		LocalX:     len(localX),
 		Local:      len(local),
@@ -270,7 +481,23 @@ func (users Users) GetLoginStat() LoginStat {
 		Unknown:    len(unknown),
 		LocalRoot:  localRoot,
 		RemoteRoot: remoteRoot,
-		Active:     active}
+		Active:     active,
+		Groups:     groups,
+		Seats:      seats,
+		Offline:    offline}
+}
+
+// Отсортированные ключи (nil для пустого словаря).
+func sortedKeys(m map[string]int) []string {
+	if len(m) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
 }
 
 // EOF: "users.go"
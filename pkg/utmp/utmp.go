@@ -78,20 +78,19 @@ type TimeVal struct {
 }
 
 // Read one record of Utmp from binary file
+// (unbuffered, use Reader or Scanner for sequential reading)
+// Returns io.EOF at the end of file, ErrCorruptRecord for truncated
+// record or record of invalid type
 func Read(file io.Reader, utmp *Utmp) error {
-	return binary.Read(file, binary.LittleEndian, utmp)
+	var buf [RECORD_SIZE]byte
+	return readRecord(file, buf[:], utmp)
 }
 
 // Convert Utmp chars to string
+// (see also StrAppend() and Interner to avoid allocations)
 func Str(src []int8) string {
-	b := make([]byte, 0, len(src))
-	for _, v := range src {
-		if v == 0 {
-			break
-		}
-		b = append(b, byte(v))
-	}
-	return string(b)
+	var buf [HOSTSIZE]byte // on stack
+	return string(StrAppend(buf[:0], src))
 }
 
 // Convert time stamp to Unix time
@@ -100,18 +99,51 @@ func Time(tv TimeVal) time.Time {
 }
 
 // Get PID from Utmp
+// (low-level helper, prefer Utmp.ProcessID())
 func PID(pid [4]byte) uint32 {
 	return binary.LittleEndian.Uint32(pid[:])
 }
 
 // Get RunLevel from Utmp
+// (low-level helper, prefer Utmp.RunLevel())
 func RunLvl(pid [4]byte) string {
-	b := pid[0]
-	if b > 0x20 {
-		return fmt.Sprintf("%c", b)
+	return RunLevelStr(rune(pid[0]))
+}
+
+// Convert run level to string ("5", "S" or "0x00")
+func RunLevelStr(rl rune) string {
+	if rl > 0x20 {
+		return fmt.Sprintf("%c", rl)
 	} else {
-		return fmt.Sprintf("0x%02X", b)
+		return fmt.Sprintf("0x%02X", rl)
+	}
+}
+
+// Поле PID перегружено: для записей RUN_LVL в нём хранится уровень
+// выполнения, для остальных - идентификатор процесса.
+// Get Process ID from record (0 for RUN_LVL records)
+func (u *Utmp) ProcessID() uint32 {
+	if u.Type == RUN_LVL {
+		return 0
+	}
+	return PID(u.PID)
+}
+
+// Get run level from RUN_LVL record (ok=false for other types)
+func (u *Utmp) RunLevel() (rl rune, ok bool) {
+	if u.Type != RUN_LVL {
+		return 0, false
+	}
+	return rune(u.PID[0]), true
+}
+
+// Get previous run level from RUN_LVL record
+// (sysvinit writes "PID = new + 256 * prev", ok=false if unknown)
+func (u *Utmp) PrevRunLevel() (rl rune, ok bool) {
+	if u.Type != RUN_LVL || u.PID[1] == 0 {
+		return 0, false
 	}
+	return rune(u.PID[1]), true
 }
 
 // Get IPv4 address from AddrV6
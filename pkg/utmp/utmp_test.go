@@ -17,4 +17,33 @@ func TestUTMP(t *testing.T) {
 	l.Close()
 }
 
+func TestRunLevel(t *testing.T) {
+	for _, tc := range []struct {
+		typ       int16
+		pid       uint32
+		pidOut    uint32
+		rl, prev  rune
+		rlOk, pOk bool
+	}{
+		{RUN_LVL, '5' + 256*'N', 0, '5', 'N', true, true}, // first after boot
+		{RUN_LVL, '3' + 256*'5', 0, '3', '5', true, true},
+		{RUN_LVL, '0' + 256*'3', 0, '0', '3', true, true}, // shutdown
+		{RUN_LVL, '2', 0, '2', 0, true, false},            // previous is unknown
+		{USER_PROCESS, 12345, 12345, 0, 0, false, false},
+		{BOOT_TIME, 0, 0, 0, 0, false, false},
+		{DEAD_PROCESS, '5' + 256*'N', '5' + 256*'N', 0, 0, false, false},
+	} {
+		u := testRecord(tc.typ, tc.pid, "~", "~~", "runlevel", "", 1000)
+		require.Equal(t, tc.pidOut, u.ProcessID(), "%d/%#x", tc.typ, tc.pid)
+		rl, ok := u.RunLevel()
+		require.Equal(t, tc.rl, rl, "%d/%#x", tc.typ, tc.pid)
+		require.Equal(t, tc.rlOk, ok, "%d/%#x", tc.typ, tc.pid)
+		prev, ok := u.PrevRunLevel()
+		require.Equal(t, tc.prev, prev, "%d/%#x", tc.typ, tc.pid)
+		require.Equal(t, tc.pOk, ok, "%d/%#x", tc.typ, tc.pid)
+	}
+	require.Equal(t, "N", RunLevelStr('N'))
+	require.Equal(t, "0x00", RunLevelStr(0))
+}
+
 // EOF: "utmp_test.go"
@@ -0,0 +1,234 @@
+// File: "conn.go"
+
+package utmpdbus
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Имя, путь и интерфейс шины сообщений.
+const (
+	busName  = "org.freedesktop.DBus"
+	busPath  = "/org/freedesktop/DBus"
+	busIface = "org.freedesktop.DBus"
+)
+
+// Соединение с шиной D-Bus.
+type conn struct {
+	c      net.Conn
+	r      *bufio.Reader
+	wmx    sync.Mutex // запись сообщений
+	serial uint32     // последний номер отправленного сообщения
+	name   string     // уникальное имя соединения (":1.42")
+}
+
+// Подключиться к шине по адресу D-Bus ("unix:path=...;unix:abstract=...")
+// и аутентифицироваться (SASL EXTERNAL), вызвать Hello.
+func dial(address string, timeout time.Duration) (*conn, error) {
+	var errs []error
+	for _, addr := range strings.Split(address, ";") {
+		transport, params, _ := strings.Cut(addr, ":")
+		if transport != "unix" {
+			errs = append(errs, fmt.Errorf("unsupported transport %q", transport))
+			continue
+		}
+		var path string
+		for _, kv := range strings.Split(params, ",") {
+			k, v, _ := strings.Cut(kv, "=")
+			switch k {
+			case "path":
+				path = unescape(v)
+			case "abstract":
+				path = "@" + unescape(v)
+			}
+		}
+		if path == "" {
+			errs = append(errs, fmt.Errorf("no path in %q", addr))
+			continue
+		}
+		c, err := net.DialTimeout("unix", path, timeout)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		cn := &conn{c: c, r: bufio.NewReader(c)}
+		c.SetDeadline(time.Now().Add(timeout))
+		if err := cn.auth(); err != nil {
+			c.Close()
+			return nil, err
+		}
+		reply, err := cn.call(busName, busPath, busIface, "Hello", "", nil)
+		if err == nil {
+			cn.name, err = reply.bodyString()
+		}
+		if err != nil {
+			c.Close()
+			return nil, fmt.Errorf("dbus: Hello: %w", err)
+		}
+		c.SetDeadline(time.Time{})
+		return cn, nil
+	}
+	if address == "" {
+		return nil, errors.New("dbus: no bus address")
+	}
+	return nil, fmt.Errorf("dbus: %s: %w", address, errors.Join(errs...))
+}
+
+// Подключиться к шине от имени пользователя uid/gid (сеансовая шина
+// другого пользователя, процесс root): эффективные UID/GID меняются только
+// в отдельном потоке подключения (сокет запоминает учётные данные при
+// connect, аутентификация EXTERNAL - по эффективному UID потока).
+func dialAs(address string, uid, gid int, timeout time.Duration) (*conn, error) {
+	if os.Geteuid() != 0 || uid == 0 {
+		return dial(address, timeout)
+	}
+	type result struct {
+		cn  *conn
+		err error
+	}
+	res := make(chan result, 1)
+	go func() {
+		// Поток не возвращается в пул, если учётные данные не восстановлены
+		runtime.LockOSThread()
+		egid := os.Getegid()
+		if err := setresgid(-1, gid, -1); err != nil {
+			runtime.UnlockOSThread()
+			res <- result{err: fmt.Errorf("dbus: setresgid: %w", err)}
+			return
+		}
+		if err := setresuid(-1, uid, -1); err != nil {
+			if setresgid(-1, egid, -1) == nil {
+				runtime.UnlockOSThread()
+			}
+			res <- result{err: fmt.Errorf("dbus: setresuid: %w", err)}
+			return
+		}
+		cn, err := dial(address, timeout)
+		if setresuid(-1, 0, -1) == nil && setresgid(-1, egid, -1) == nil {
+			runtime.UnlockOSThread()
+		}
+		res <- result{cn, err}
+	}()
+	r := <-res
+	return r.cn, r.err
+}
+
+// setresuid()/setresgid() только текущего потока (syscall.Setresuid()
+// меняет учётные данные всех потоков процесса), -1 - не менять.
+func setresuid(ruid, euid, suid int) error {
+	_, _, e := syscall.RawSyscall(syscall.SYS_SETRESUID, uintptr(ruid), uintptr(euid), uintptr(suid))
+	if e != 0 {
+		return e
+	}
+	return nil
+}
+
+func setresgid(rgid, egid, sgid int) error {
+	_, _, e := syscall.RawSyscall(syscall.SYS_SETRESGID, uintptr(rgid), uintptr(egid), uintptr(sgid))
+	if e != 0 {
+		return e
+	}
+	return nil
+}
+
+// Раскрыть %XX в значении адреса.
+func unescape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' && i+2 < len(s) {
+			if v, err := strconv.ParseUint(s[i+1:i+3], 16, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 2
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// Аутентификация SASL EXTERNAL (по UID процесса).
+func (cn *conn) auth() error {
+	uid := hex.EncodeToString([]byte(strconv.Itoa(os.Geteuid())))
+	if _, err := fmt.Fprintf(cn.c, "\x00AUTH EXTERNAL %s\r\n", uid); err != nil {
+		return fmt.Errorf("dbus: %w", err)
+	}
+	line, err := cn.r.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("dbus: auth: %w", err)
+	}
+	if !strings.HasPrefix(line, "OK ") {
+		return fmt.Errorf("dbus: auth: %s", strings.TrimSpace(line))
+	}
+	if _, err := cn.c.Write([]byte("BEGIN\r\n")); err != nil {
+		return fmt.Errorf("dbus: %w", err)
+	}
+	return nil
+}
+
+// Отправить сообщение (номер сообщения назначается здесь).
+func (cn *conn) send(m *message) error {
+	cn.wmx.Lock()
+	defer cn.wmx.Unlock()
+	cn.serial++
+	m.serial = cn.serial
+	_, err := cn.c.Write(m.marshal())
+	return err
+}
+
+// Вызвать метод и дождаться ответа (только до запуска цикла чтения:
+// прочие сообщения пропускаются).
+func (cn *conn) call(dest, path, iface, member, sig string, body []byte) (*message, error) {
+	m := &message{typ: msgMethodCall, dest: dest, path: path, iface: iface,
+		member: member, sig: sig, body: body}
+	if err := cn.send(m); err != nil {
+		return nil, err
+	}
+	for {
+		reply, err := readMessage(cn.r)
+		if err != nil {
+			return nil, err
+		}
+		if reply.replySerial != m.serial {
+			continue
+		}
+		if reply.typ == msgError {
+			msg, _ := reply.bodyString()
+			return nil, fmt.Errorf("%s: %s", reply.errName, msg)
+		}
+		return reply, nil
+	}
+}
+
+// Первое значение тела - строка.
+func (m *message) bodyString() (string, error) {
+	if !strings.HasPrefix(m.sig, "s") {
+		return "", errMessage
+	}
+	d := &decoder{b: m.body, order: m.order}
+	s := d.string()
+	return s, d.err
+}
+
+// Первое значение тела - uint32.
+func (m *message) bodyUint32() (uint32, error) {
+	if !strings.HasPrefix(m.sig, "u") {
+		return 0, errMessage
+	}
+	d := &decoder{b: m.body, order: m.order}
+	v := d.uint32()
+	return v, d.err
+}
+
+// EOF: "conn.go"
@@ -0,0 +1,214 @@
+// File: "notifier.go"
+
+package utmpdbus
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/azorg/gousers/v2/pkg/sink"
+	"github.com/azorg/gousers/v2/pkg/utmp"
+)
+
+// Служба уведомлений рабочего стола (freedesktop Desktop Notifications).
+// Desktop notifications service.
+const (
+	NOTIFY_NAME      = "org.freedesktop.Notifications"
+	NOTIFY_PATH      = "/org/freedesktop/Notifications"
+	NOTIFY_INTERFACE = "org.freedesktop.Notifications"
+)
+
+// Имя приложения уведомлений по умолчанию.
+// Default notification application name.
+const NOTIFY_APP = "gousers"
+
+// Уровни срочности уведомлений (hint "urgency").
+// Notification urgency levels.
+const (
+	URGENCY_LOW      = 0
+	URGENCY_NORMAL   = 1
+	URGENCY_CRITICAL = 2
+)
+
+// Опции уведомлений рабочего стола.
+// Desktop notifier options.
+type NotifierOpts struct {
+	// Адрес сеансовой шины ("" - шина активного графического сеанса:
+	// для root - /run/user/<UID>/bus активного пользователя local_x,
+	// иначе DBUS_SESSION_BUS_ADDRESS или /run/user/<EUID>/bus)
+	Address string
+
+	AppName string        // имя приложения ("" - NOTIFY_APP)
+	Expire  time.Duration // время показа (0 - по умолчанию сервера)
+	Logouts bool          // уведомлять и о выходах (по умолчанию только входы)
+}
+
+// Уведомления рабочего стола о входах/выходах ("user alice logged in
+// remotely from 10.0.0.5"), реализует sink.Sink. Соединение с шиной
+// создаётся на каждое событие: активный пользователь может смениться.
+// Desktop notifier.
+type Notifier struct {
+	opts   NotifierOpts
+	mx     sync.Mutex
+	closed bool
+}
+
+// Создать получателя уведомлений рабочего стола.
+// Create desktop notifier.
+func NewNotifier(opts NotifierOpts) *Notifier {
+	if opts.AppName == "" {
+		opts.AppName = NOTIFY_APP
+	}
+	return &Notifier{opts: opts}
+}
+
+// Показать уведомления о входах (и выходах) события. Если нет активного
+// графического сеанса, уведомления не показываются (не ошибка).
+// Send notifications.
+func (n *Notifier) Send(evt utmp.LoginEvent) error {
+	n.mx.Lock()
+	defer n.mx.Unlock()
+	if n.closed {
+		return net.ErrClosed
+	}
+	var list []sink.Entry
+	for _, e := range sink.Entries(evt) {
+		if e.Action == "logout" && !n.opts.Logouts {
+			continue
+		}
+		if a := evt.Stat.Active; a != nil && e.User == a.Name && e.Type == utmp.LOCAL_X {
+			continue // owner's own graphical session
+		}
+		list = append(list, e)
+	}
+	if len(list) == 0 {
+		return nil
+	}
+
+	address, uid, gid := n.bus(evt.Stat.Active)
+	if address == "" {
+		return nil // no graphical session
+	}
+	cn, err := dialAs(address, uid, gid, TIMEOUT)
+	if err != nil {
+		return err
+	}
+	defer cn.c.Close()
+	cn.c.SetDeadline(time.Now().Add(TIMEOUT))
+	for _, e := range list {
+		if err := n.notify(cn, e); err != nil {
+			return fmt.Errorf("dbus: Notify: %w", err)
+		}
+	}
+	return nil
+}
+
+// Адрес сеансовой шины и пользователь подключения ("" - нет шины).
+func (n *Notifier) bus(active *utmp.LoginInfo) (address string, uid, gid int) {
+	uid, gid = os.Geteuid(), os.Getegid()
+	if n.opts.Address != "" {
+		return n.opts.Address, uid, gid
+	}
+	if uid != 0 {
+		if address = SessionBus(); address == "" {
+			address = "unix:path=/run/user/" + strconv.Itoa(uid) + "/bus"
+		}
+		return address, uid, gid
+	}
+	if active == nil || active.Type != utmp.LOCAL_X {
+		return "", 0, 0
+	}
+	u, err := strconv.Atoi(active.UID)
+	if err != nil {
+		return "", 0, 0
+	}
+	g, err := strconv.Atoi(active.GID)
+	if err != nil {
+		return "", 0, 0
+	}
+	return "unix:path=/run/user/" + active.UID + "/bus", u, g
+}
+
+// Вызвать Notify(app_name, replaces_id, app_icon, summary, body, actions,
+// hints, expire_timeout).
+func (n *Notifier) notify(cn *conn, e sink.Entry) error {
+	summary, body := Message(e)
+	urgency, icon := byte(URGENCY_NORMAL), "dialog-information"
+	if e.Privileged || e.Remote() {
+		urgency, icon = URGENCY_CRITICAL, "dialog-warning"
+	}
+	if e.Action == "logout" {
+		urgency, icon = URGENCY_LOW, "dialog-information"
+	}
+	expire := int32(-1)
+	if n.opts.Expire > 0 {
+		expire = int32(n.opts.Expire / time.Millisecond)
+	}
+
+	enc := &encoder{}
+	enc.string(n.opts.AppName)
+	enc.uint32(0)
+	enc.string(icon)
+	enc.string(summary)
+	enc.string(body)
+	enc.array(4, func() {}) // no actions
+	enc.array(8, func() {
+		enc.strct(func() {
+			enc.string("urgency")
+			enc.signature("y")
+			enc.byte(urgency)
+		})
+	})
+	enc.uint32(uint32(expire))
+	_, err := cn.call(NOTIFY_NAME, NOTIFY_PATH, NOTIFY_INTERFACE, "Notify",
+		"susssasa{sv}i", enc.b)
+	return err
+}
+
+// Заголовок и текст уведомления о входе/выходе.
+// Notification summary and body.
+func Message(e sink.Entry) (summary, body string) {
+	if e.Action == "logout" {
+		return "Logout: " + e.User, fmt.Sprintf("user %s logged out (%s)", e.User, e.TTY)
+	}
+	from := e.Host
+	if e.IP != nil && e.IP.String() != e.Host {
+		if from == "" {
+			from = e.IP.String()
+		} else {
+			from += " (" + e.IP.String() + ")"
+		}
+	}
+	how := "locally on " + e.TTY
+	switch e.Type {
+	case utmp.REMOTE, utmp.REMOTE_X:
+		how = "remotely"
+		if from != "" {
+			how += " from " + from
+		}
+		if e.Type == utmp.REMOTE_X {
+			how += " (graphical session)"
+		}
+	case utmp.LOCAL_X:
+		how = "locally (graphical session)"
+	}
+	return "Login: " + e.User, "user " + e.User + " logged in " + how
+}
+
+// Завершить работу (повторный вызов безопасен).
+// Close notifier.
+func (n *Notifier) Close() error {
+	n.mx.Lock()
+	defer n.mx.Unlock()
+	n.closed = true
+	return nil
+}
+
+// Проверка интерфейса получателя.
+var _ sink.Sink = (*Notifier)(nil)
+
+// EOF: "notifier.go"
@@ -0,0 +1,371 @@
+// File: "utmpdbus.go"
+
+/*
+Пакет `utmpdbus` - служба D-Bus сведений о вошедших пользователях для
+компонентов рабочего стола и системных служб: имя NAME, объект PATH,
+интерфейс INTERFACE:
+
+	ListUsers() -> a(sussxu)        - вошедшие пользователи
+	GetActive() -> (sussxu)         - активный пользователь (имя "" - нет)
+	signal SessionsChanged(a(sss) login, a(sss) logout, s active)
+
+Пользователь (sussxu): имя, UID, полное имя, тип входа (remote,
+remote_x, local, local_x), время последнего входа (Unix time), число
+входов. Вход/выход (sss): имя, терминал, тип входа. Поддерживаются
+также org.freedesktop.DBus.Introspectable и org.freedesktop.DBus.Peer.
+
+Реализация не использует внешних зависимостей (протокол D-Bus
+кодируется вручную, транспорт - Unix socket, аутентификация EXTERNAL).
+
+Для системной шины владение именем разрешается политикой, например
+/etc/dbus-1/system.d/org.gousers.Sessions.conf:
+
+	<busconfig>
+	  <policy user="root">
+	    <allow own="org.gousers.Sessions"/>
+	  </policy>
+	  <policy context="default">
+	    <allow send_destination="org.gousers.Sessions"/>
+	  </policy>
+	</busconfig>
+
+Пример:
+
+	srv, err := utmpdbus.New(l, utmpdbus.Opts{})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer srv.Close()
+
+Notifier - получатель sink.Sink, показывающий уведомления рабочего стола
+(org.freedesktop.Notifications) о входах в активном графическом сеансе:
+
+	n := utmpdbus.NewNotifier(utmpdbus.NotifierOpts{})
+	stop := sink.Forward(l, n, func(err error) { log.Print(err) })
+	defer stop()
+
+Package utmpdbus is the dependency free D-Bus service of logged users
+and desktop notifier of logins.
+*/
+package utmpdbus
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/azorg/gousers/v2/dto"
+	"github.com/azorg/gousers/v2/pkg/utmp"
+	"github.com/azorg/gousers/v2/pkg/utmphttp"
+)
+
+// Имя службы, путь объекта и интерфейс.
+// Service name, object path and interface.
+const (
+	NAME      = "org.gousers.Sessions"
+	PATH      = "/org/gousers/Sessions"
+	INTERFACE = "org.gousers.Sessions"
+)
+
+// Адрес системной шины по умолчанию (DBUS_SYSTEM_BUS_ADDRESS не задан).
+// Default system bus address.
+const SYSTEM_BUS = "unix:path=/run/dbus/system_bus_socket"
+
+// Время ожидания подключения к шине.
+// Bus connect timeout.
+const TIMEOUT = 10 * time.Second
+
+// Сигнатуры значений.
+const (
+	sigUser    = "(sussxu)"
+	sigLogin   = "a(sss)"
+	sigChanged = "a(sss)a(sss)s"
+)
+
+// Описание объекта (org.freedesktop.DBus.Introspectable).
+const introspect = `<!DOCTYPE node PUBLIC "-//freedesktop//DTD D-BUS Object Introspection 1.0//EN"
+ "http://www.freedesktop.org/standards/dbus/1.0/introspect.dtd">
+<node>
+ <interface name="` + INTERFACE + `">
+  <method name="ListUsers">
+   <arg name="users" type="a` + sigUser + `" direction="out"/>
+  </method>
+  <method name="GetActive">
+   <arg name="user" type="` + sigUser + `" direction="out"/>
+  </method>
+  <signal name="SessionsChanged">
+   <arg name="login" type="` + sigLogin + `"/>
+   <arg name="logout" type="` + sigLogin + `"/>
+   <arg name="active" type="s"/>
+  </signal>
+ </interface>
+ <interface name="org.freedesktop.DBus.Introspectable">
+  <method name="Introspect">
+   <arg name="xml" type="s" direction="out"/>
+  </method>
+ </interface>
+ <interface name="org.freedesktop.DBus.Peer">
+  <method name="Ping"/>
+ </interface>
+</node>
+`
+
+// Опции службы.
+// Service options.
+type Opts struct {
+	// Адрес шины ("" - системная шина: DBUS_SYSTEM_BUS_ADDRESS или
+	// SYSTEM_BUS, см. также SessionBus())
+	Address string
+
+	Name   string       // имя службы ("" - NAME)
+	Logger *slog.Logger // журнал ошибок (nil - без журнала)
+}
+
+// Служба D-Bus.
+// D-Bus service.
+type Service struct {
+	l    *utmp.Login
+	opts Opts
+	cn   *conn
+	evt  <-chan utmp.LoginEvent
+	wg   sync.WaitGroup
+}
+
+// Адрес сеансовой шины (DBUS_SESSION_BUS_ADDRESS).
+// Session bus address.
+func SessionBus() string {
+	return os.Getenv("DBUS_SESSION_BUS_ADDRESS")
+}
+
+// Подключиться к шине, занять имя службы и обслуживать вызовы до Close().
+// Start D-Bus service.
+func New(l *utmp.Login, opts Opts) (*Service, error) {
+	if opts.Address == "" {
+		opts.Address = os.Getenv("DBUS_SYSTEM_BUS_ADDRESS")
+		if opts.Address == "" {
+			opts.Address = SYSTEM_BUS
+		}
+	}
+	if opts.Name == "" {
+		opts.Name = NAME
+	}
+	cn, err := dial(opts.Address, TIMEOUT)
+	if err != nil {
+		return nil, err
+	}
+
+	// RequestName(name, DBUS_NAME_FLAG_DO_NOT_QUEUE)
+	e := &encoder{}
+	e.string(opts.Name)
+	e.uint32(4)
+	cn.c.SetDeadline(time.Now().Add(TIMEOUT))
+	reply, err := cn.call(busName, busPath, busIface, "RequestName", "su", e.b)
+	var res uint32
+	if err == nil {
+		res, err = reply.bodyUint32()
+	}
+	if err == nil && res != 1 && res != 4 { // primary owner, already owner
+		err = errors.New("name is owned by another connection")
+	}
+	if err != nil {
+		cn.c.Close()
+		return nil, fmt.Errorf("dbus: RequestName %s: %w", opts.Name, err)
+	}
+	cn.c.SetDeadline(time.Time{})
+
+	s := &Service{l: l, opts: opts, cn: cn}
+	s.evt = l.Subscribe(utmp.LOGIN_QUEUE, utmp.OVERFLOW_COALESCE)
+	s.wg.Add(2)
+	go s.serve()
+	go s.signal()
+	return s, nil
+}
+
+// Уникальное имя соединения службы на шине.
+// Unique bus name of service connection.
+func (s *Service) UniqueName() string {
+	return s.cn.name
+}
+
+// Отключиться от шины.
+// Close service.
+func (s *Service) Close() error {
+	s.l.Unsubscribe(s.evt)
+	s.cn.c.Close()
+	s.wg.Wait()
+	return nil
+}
+
+// Обработать вызовы методов.
+func (s *Service) serve() {
+	defer s.wg.Done()
+	for {
+		m, err := readMessage(s.cn.r)
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				s.error("read message", err)
+			}
+			return
+		}
+		if m.typ != msgMethodCall {
+			continue // signals (NameAcquired...), replies
+		}
+		reply := s.call(m)
+		if m.flags&flagNoReplyExpected != 0 {
+			continue
+		}
+		reply.dest, reply.replySerial = m.sender, m.serial
+		if err := s.cn.send(reply); err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				s.error("send reply", err)
+			}
+			return
+		}
+	}
+}
+
+// Выполнить вызов метода.
+func (s *Service) call(m *message) *message {
+	e := &encoder{}
+	reply := &message{typ: msgMethodReturn}
+	switch {
+	case m.iface == "org.freedesktop.DBus.Peer" && m.member == "Ping":
+		return reply
+
+	case m.iface == "org.freedesktop.DBus.Introspectable" && m.member == "Introspect":
+		xml := introspect
+		if m.path != PATH {
+			xml = node(m.path)
+			if xml == "" {
+				return errorReply("org.freedesktop.DBus.Error.UnknownObject",
+					"no such object "+m.path)
+			}
+		}
+		e.string(xml)
+		reply.sig, reply.body = "s", e.b
+		return reply
+	}
+
+	if m.path != PATH {
+		return errorReply("org.freedesktop.DBus.Error.UnknownObject", "no such object "+m.path)
+	}
+	if m.iface != "" && m.iface != INTERFACE {
+		return errorReply("org.freedesktop.DBus.Error.UnknownInterface", "no such interface "+m.iface)
+	}
+	switch m.member {
+	case "ListUsers":
+		e.array(8, func() {
+			for _, li := range s.l.GetUsers() {
+				user(e, &li)
+			}
+		})
+		reply.sig = "a" + sigUser
+
+	case "GetActive":
+		user(e, s.l.GetStat().Active)
+		reply.sig = sigUser
+
+	default:
+		return errorReply("org.freedesktop.DBus.Error.UnknownMethod", "no such method "+m.member)
+	}
+	reply.body = e.b
+	return reply
+}
+
+// Описание промежуточного узла дерева объектов ("" - нет узла).
+func node(path string) string {
+	prefix := strings.TrimSuffix(path, "/") + "/"
+	if !strings.HasPrefix(PATH, prefix) {
+		return ""
+	}
+	child, _, _ := strings.Cut(PATH[len(prefix):], "/")
+	return "<node>\n <node name=\"" + child + "\"/>\n</node>\n"
+}
+
+// Закодировать пользователя (sussxu), nil - пустая структура.
+func user(e *encoder, li *utmp.LoginInfo) {
+	e.strct(func() {
+		if li == nil {
+			e.string("")
+			e.uint32(0)
+			e.string("")
+			e.string("")
+			e.int64(0)
+			e.uint32(0)
+			return
+		}
+		u := utmphttp.User(*li)
+		uid, _ := strconv.ParseUint(u.UID, 10, 32)
+		var logon int64
+		if !u.LogonTime.IsZero() {
+			logon = u.LogonTime.Unix()
+		}
+		e.string(u.Name)
+		e.uint32(uint32(uid))
+		e.string(u.DisplayName)
+		e.string(u.LogonType)
+		e.int64(logon)
+		e.uint32(uint32(u.Logons))
+	})
+}
+
+// Ответ-ошибка.
+func errorReply(name, text string) *message {
+	e := &encoder{}
+	e.string(text)
+	return &message{typ: msgError, errName: name, sig: "s", body: e.b}
+}
+
+// Рассылать сигнал SessionsChanged при входах/выходах.
+func (s *Service) signal() {
+	defer s.wg.Done()
+	for evt := range s.evt {
+		if evt.Historical || len(evt.Login)+len(evt.Logout) == 0 {
+			continue
+		}
+		e := &encoder{}
+		list := func(uts []utmp.UserTTY) {
+			e.array(8, func() {
+				for _, ut := range uts {
+					e.strct(func() {
+						e.string(ut.User)
+						e.string(ut.TTY)
+						e.string(dto.LogonType[evt.Types[ut]])
+					})
+				}
+			})
+		}
+		list(evt.Login)
+		list(evt.Logout)
+		active := ""
+		if evt.Stat.Active != nil {
+			active = evt.Stat.Active.Name
+		}
+		e.string(active)
+
+		err := s.cn.send(&message{typ: msgSignal, path: PATH, iface: INTERFACE,
+			member: "SessionsChanged", sig: sigChanged, body: e.b})
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				s.error("send signal", err)
+			}
+			return
+		}
+	}
+}
+
+// Записать ошибку в журнал.
+func (s *Service) error(msg string, err error) {
+	if s.opts.Logger != nil {
+		s.opts.Logger.Error("dbus: "+msg, "err", err)
+	}
+}
+
+// EOF: "utmpdbus.go"
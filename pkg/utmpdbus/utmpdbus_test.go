@@ -0,0 +1,292 @@
+// File: "utmpdbus_test.go"
+
+package utmpdbus
+
+import (
+	"bufio"
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/azorg/gousers/v2/pkg/sink"
+	"github.com/azorg/gousers/v2/pkg/utmp"
+)
+
+func TestMessage(t *testing.T) {
+	e := &encoder{}
+	e.array(8, func() {
+		e.strct(func() {
+			e.string("alice")
+			e.int64(-1)
+		})
+	})
+	m := &message{typ: msgSignal, serial: 7, path: PATH, iface: INTERFACE,
+		member: "X", replySerial: 3, sig: "a(sx)", body: e.b}
+	data := m.marshal()
+	require.Zero(t, (len(data)-len(e.b))%8) // body is 8 aligned
+
+	got, err := readMessage(bufio.NewReader(strings.NewReader(string(data))))
+	require.NoError(t, err)
+	require.Equal(t, m.path, got.path)
+	require.Equal(t, m.member, got.member)
+	require.Equal(t, m.sig, got.sig)
+	require.Equal(t, uint32(3), got.replySerial)
+	require.Equal(t, uint32(7), got.serial)
+
+	d := &decoder{b: got.body, order: got.order}
+	require.Equal(t, uint32(24), d.uint32()) // array length (from first element)
+	d.align(8)
+	require.Equal(t, "alice", d.string())
+	require.Equal(t, int64(-1), d.int64())
+	require.NoError(t, d.err)
+
+	_, err = readMessage(bufio.NewReader(strings.NewReader(string(data[:20]))))
+	require.Error(t, err)
+
+	require.Equal(t, "/run/x y", unescape("/run/x%20y"))
+}
+
+func TestService(t *testing.T) {
+	dir := t.TempDir()
+	fname := filepath.Join(dir, "utmp")
+	require.NoError(t, os.WriteFile(fname, nil, 0644))
+	login := func(user, line string) {
+		u := utmp.Utmp{Type: utmp.USER_PROCESS}
+		for i := range line {
+			u.Line[i] = int8(line[i])
+		}
+		for i := range user {
+			u.User[i] = int8(user[i])
+		}
+		u.TV.Sec = int32(time.Now().Unix())
+		f, err := os.OpenFile(fname, os.O_WRONLY|os.O_APPEND, 0)
+		require.NoError(t, err)
+		require.NoError(t, binary.Write(f, binary.LittleEndian, &u))
+		require.NoError(t, f.Close())
+	}
+	login("root", "tty1")
+
+	l, err := utmp.NewLoginWith(fname, utmp.LoginOpts{})
+	require.NoError(t, err)
+	defer l.Close()
+
+	// Minimal bus: EXTERNAL auth, Hello, RequestName
+	sock := filepath.Join(dir, "bus")
+	ln, err := net.Listen("unix", sock)
+	require.NoError(t, err)
+	defer ln.Close()
+	type busConn struct {
+		c    net.Conn
+		r    *bufio.Reader
+		name string
+	}
+	bus := make(chan busConn, 1)
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		r := bufio.NewReader(c)
+		if b, _ := r.ReadByte(); b != 0 {
+			return
+		}
+		if line, _ := r.ReadString('\n'); !strings.HasPrefix(line, "AUTH EXTERNAL ") {
+			return
+		}
+		c.Write([]byte("OK 0123456789abcdef\r\n"))
+		if line, _ := r.ReadString('\n'); line != "BEGIN\r\n" {
+			return
+		}
+		for {
+			m, err := readMessage(r)
+			if err != nil {
+				return
+			}
+			e := &encoder{}
+			reply := &message{typ: msgMethodReturn, replySerial: m.serial}
+			switch m.member {
+			case "Hello":
+				e.string(":1.7")
+				reply.sig = "s"
+			case "RequestName":
+				e.uint32(1) // primary owner
+				reply.sig = "u"
+			}
+			reply.body = e.b
+			c.Write(reply.marshal())
+			if m.member == "RequestName" {
+				d := &decoder{b: m.body, order: m.order}
+				bus <- busConn{c, r, d.string()}
+				return
+			}
+		}
+	}()
+
+	srv, err := New(l, Opts{Address: "unix:path=" + sock})
+	require.NoError(t, err)
+	defer srv.Close()
+	require.Equal(t, ":1.7", srv.UniqueName())
+	b := <-bus
+	require.Equal(t, NAME, b.name)
+	b.c.SetDeadline(time.Now().Add(10 * time.Second))
+
+	serial := uint32(100)
+	call := func(path, iface, member string) *message {
+		serial++
+		m := &message{typ: msgMethodCall, serial: serial, path: path,
+			iface: iface, member: member, sender: ":1.9", dest: NAME}
+		_, err := b.c.Write(m.marshal())
+		require.NoError(t, err)
+		reply, err := readMessage(b.r)
+		require.NoError(t, err)
+		require.Equal(t, serial, reply.replySerial)
+		require.Equal(t, ":1.9", reply.dest)
+		return reply
+	}
+
+	reply := call(PATH, INTERFACE, "ListUsers")
+	require.Equal(t, byte(msgMethodReturn), reply.typ)
+	require.Equal(t, "a"+sigUser, reply.sig)
+	d := &decoder{b: reply.body, order: reply.order}
+	require.NotZero(t, d.uint32())
+	d.align(8)
+	require.Equal(t, "root", d.string())
+	require.Equal(t, uint32(0), d.uint32()) // uid
+
+	reply = call(PATH, "", "GetActive")
+	require.Equal(t, sigUser, reply.sig)
+
+	reply = call(PATH, INTERFACE, "Reboot")
+	require.Equal(t, byte(msgError), reply.typ)
+	require.Equal(t, "org.freedesktop.DBus.Error.UnknownMethod", reply.errName)
+	reply = call("/", INTERFACE, "ListUsers")
+	require.Equal(t, "org.freedesktop.DBus.Error.UnknownObject", reply.errName)
+
+	reply = call("/org", "org.freedesktop.DBus.Introspectable", "Introspect")
+	xml, err := reply.bodyString()
+	require.NoError(t, err)
+	require.Contains(t, xml, `<node name="gousers"/>`)
+	reply = call(PATH, "org.freedesktop.DBus.Introspectable", "Introspect")
+	xml, err = reply.bodyString()
+	require.NoError(t, err)
+	require.Contains(t, xml, `<signal name="SessionsChanged">`)
+	reply = call(PATH, "org.freedesktop.DBus.Peer", "Ping")
+	require.Equal(t, byte(msgMethodReturn), reply.typ)
+
+	// SessionsChanged signal
+	login("alice", "pts/0")
+	m, err := readMessage(b.r)
+	require.NoError(t, err)
+	require.Equal(t, byte(msgSignal), m.typ)
+	require.Equal(t, "SessionsChanged", m.member)
+	require.Equal(t, sigChanged, m.sig)
+	d = &decoder{b: m.body, order: m.order}
+	require.NotZero(t, d.uint32())
+	d.align(8)
+	require.Equal(t, []string{"alice", "pts/0", "local"}, []string{d.string(), d.string(), d.string()})
+	require.Zero(t, d.uint32()) // no logouts
+	d.align(8)
+	d.string() // active user
+	require.NoError(t, d.err)
+}
+
+func TestNotifier(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "bus")
+	ln, err := net.Listen("unix", sock)
+	require.NoError(t, err)
+	defer ln.Close()
+	calls := make(chan *message, 4)
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			r := bufio.NewReader(c)
+			r.ReadByte()
+			r.ReadString('\n')
+			c.Write([]byte("OK 0123456789abcdef\r\n"))
+			r.ReadString('\n')
+			for {
+				m, err := readMessage(r)
+				if err != nil {
+					break
+				}
+				e := &encoder{}
+				reply := &message{typ: msgMethodReturn, replySerial: m.serial, sig: "u"}
+				if m.member == "Hello" {
+					e.string(":1.8")
+					reply.sig = "s"
+				} else {
+					e.uint32(42)
+					calls <- m
+				}
+				reply.body = e.b
+				c.Write(reply.marshal())
+			}
+			c.Close()
+		}
+	}()
+
+	alice := utmp.UserTTY{User: "alice", TTY: "pts/3"}
+	bob := utmp.UserTTY{User: "bob", TTY: ":0"}
+	u := utmp.Utmp{Type: utmp.USER_PROCESS}
+	for i, c := range "alice" {
+		u.User[i] = int8(c)
+	}
+	for i, c := range "pts/3" {
+		u.Line[i] = int8(c)
+	}
+	u.AddrV6[0] = 0x0500000a // 10.0.0.5
+	active := &utmp.LoginInfo{}
+	active.Name, active.Type = "bob", utmp.LOCAL_X
+	evt := utmp.LoginEvent{
+		Login:   []utmp.UserTTY{alice, bob},
+		Logout:  []utmp.UserTTY{{User: "carol", TTY: "tty2"}},
+		Types:   map[utmp.UserTTY]utmp.LoginType{alice: utmp.REMOTE, bob: utmp.LOCAL_X},
+		Records: []utmp.Utmp{u},
+		Stat:    utmp.LoginStat{Active: active}}
+
+	n := NewNotifier(NotifierOpts{Address: "unix:path=" + sock, Expire: 5 * time.Second})
+	require.NoError(t, n.Send(evt))
+	m := <-calls
+	require.Equal(t, NOTIFY_NAME, m.dest)
+	require.Equal(t, NOTIFY_PATH, m.path)
+	require.Equal(t, "Notify", m.member)
+	require.Equal(t, "susssasa{sv}i", m.sig)
+	d := &decoder{b: m.body, order: m.order}
+	require.Equal(t, NOTIFY_APP, d.string())
+	require.Zero(t, d.uint32())
+	require.Equal(t, "dialog-warning", d.string())
+	require.Equal(t, "Login: alice", d.string())
+	require.Equal(t, "user alice logged in remotely from 10.0.0.5", d.string())
+	require.Zero(t, d.uint32()) // actions
+	n2 := d.uint32()            // hints
+	d.align(8)
+	require.Equal(t, "urgency", d.string())
+	require.Equal(t, "y", d.signature())
+	require.Equal(t, byte(URGENCY_CRITICAL), d.byte())
+	require.NotZero(t, n2)
+	require.Equal(t, uint32(5000), d.uint32())
+	require.NoError(t, d.err)
+	require.Len(t, calls, 0) // own graphical session and logout are skipped
+
+	require.NoError(t, n.Close())
+	require.Error(t, n.Send(evt))
+
+	_, body := Message(sink.Entry{Action: "login", User: "bob", TTY: "tty1", Type: utmp.LOCAL})
+	require.Equal(t, "user bob logged in locally on tty1", body)
+	_, body = Message(sink.Entry{Action: "login", User: "eve", Host: "ws1",
+		IP: net.IPv4(10, 0, 0, 7), Type: utmp.REMOTE_X})
+	require.Equal(t, "user eve logged in remotely from ws1 (10.0.0.7) (graphical session)", body)
+	_, body = Message(sink.Entry{Action: "logout", User: "carol", TTY: "tty2"})
+	require.Equal(t, "user carol logged out (tty2)", body)
+}
+
+// EOF: "utmpdbus_test.go"
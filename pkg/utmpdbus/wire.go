@@ -0,0 +1,271 @@
+// File: "wire.go"
+
+package utmpdbus
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Типы сообщений D-Bus.
+const (
+	msgMethodCall   = 1
+	msgMethodReturn = 2
+	msgError        = 3
+	msgSignal       = 4
+)
+
+// Флаг сообщения: ответ не нужен.
+const flagNoReplyExpected = 0x1
+
+// Коды полей заголовка.
+const (
+	fieldPath        = 1
+	fieldInterface   = 2
+	fieldMember      = 3
+	fieldErrorName   = 4
+	fieldReplySerial = 5
+	fieldDestination = 6
+	fieldSender      = 7
+	fieldSignature   = 8
+)
+
+// Наибольший размер сообщения (спецификация D-Bus: 128 МиБ).
+const maxMessage = 128 * 1024 * 1024
+
+// Ошибка разбора сообщения.
+var errMessage = errors.New("dbus: malformed message")
+
+// Сообщение D-Bus (тело закодировано, см. encoder).
+type message struct {
+	typ         byte
+	flags       byte
+	serial      uint32
+	path        string
+	iface       string
+	member      string
+	errName     string
+	replySerial uint32
+	dest        string
+	sender      string
+	sig         string
+	body        []byte
+	order       binary.ByteOrder // порядок байт тела
+}
+
+// Закодировать сообщение (little endian).
+func (m *message) marshal() []byte {
+	e := &encoder{}
+	e.byte('l')
+	e.byte(m.typ)
+	e.byte(m.flags)
+	e.byte(1) // protocol version
+	e.uint32(uint32(len(m.body)))
+	e.uint32(m.serial)
+	e.array(8, func() {
+		str := func(code byte, sig, v string) {
+			if v != "" {
+				e.align(8)
+				e.byte(code)
+				e.signature(sig)
+				if sig == "g" {
+					e.signature(v)
+				} else {
+					e.string(v)
+				}
+			}
+		}
+		str(fieldPath, "o", m.path)
+		str(fieldInterface, "s", m.iface)
+		str(fieldMember, "s", m.member)
+		str(fieldErrorName, "s", m.errName)
+		if m.replySerial != 0 {
+			e.align(8)
+			e.byte(fieldReplySerial)
+			e.signature("u")
+			e.uint32(m.replySerial)
+		}
+		str(fieldDestination, "s", m.dest)
+		str(fieldSender, "s", m.sender)
+		str(fieldSignature, "g", m.sig)
+	})
+	e.align(8)
+	return append(e.b, m.body...)
+}
+
+// Прочитать сообщение.
+func readMessage(r *bufio.Reader) (*message, error) {
+	hdr := make([]byte, 16)
+	if _, err := io.ReadFull(r, hdr); err != nil {
+		return nil, err
+	}
+	m := &message{typ: hdr[1], flags: hdr[2]}
+	switch hdr[0] {
+	case 'l':
+		m.order = binary.LittleEndian
+	case 'B':
+		m.order = binary.BigEndian
+	default:
+		return nil, errMessage
+	}
+	bodyLen := m.order.Uint32(hdr[4:])
+	m.serial = m.order.Uint32(hdr[8:])
+	fieldsLen := m.order.Uint32(hdr[12:])
+	if bodyLen > maxMessage || fieldsLen > maxMessage {
+		return nil, errMessage
+	}
+	n := int(fieldsLen)
+	n += (8 - (16+n)%8) % 8 // padding
+	rest := make([]byte, n+int(bodyLen))
+	if _, err := io.ReadFull(r, rest); err != nil {
+		return nil, err
+	}
+
+	d := &decoder{b: append(hdr, rest[:n]...), pos: 16, order: m.order}
+	end := 16 + int(fieldsLen)
+	for d.err == nil && d.pos < end {
+		d.align(8)
+		code := d.byte()
+		switch sig := d.signature(); sig {
+		case "s", "o":
+			v := d.string()
+			switch code {
+			case fieldPath:
+				m.path = v
+			case fieldInterface:
+				m.iface = v
+			case fieldMember:
+				m.member = v
+			case fieldErrorName:
+				m.errName = v
+			case fieldDestination:
+				m.dest = v
+			case fieldSender:
+				m.sender = v
+			}
+		case "u":
+			v := d.uint32()
+			if code == fieldReplySerial {
+				m.replySerial = v
+			}
+		case "g":
+			v := d.signature()
+			if code == fieldSignature {
+				m.sig = v
+			}
+		default: // not used by the bus
+			return nil, fmt.Errorf("dbus: unsupported header field type %q", sig)
+		}
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	m.body = rest[n:]
+	return m, nil
+}
+
+// Кодировщик значений D-Bus (little endian, выравнивание от начала буфера).
+type encoder struct {
+	b []byte
+}
+
+func (e *encoder) align(n int) {
+	for len(e.b)%n != 0 {
+		e.b = append(e.b, 0)
+	}
+}
+
+func (e *encoder) byte(v byte) {
+	e.b = append(e.b, v)
+}
+
+func (e *encoder) uint32(v uint32) {
+	e.align(4)
+	e.b = binary.LittleEndian.AppendUint32(e.b, v)
+}
+
+func (e *encoder) int64(v int64) {
+	e.align(8)
+	e.b = binary.LittleEndian.AppendUint64(e.b, uint64(v))
+}
+
+func (e *encoder) string(v string) {
+	e.uint32(uint32(len(v)))
+	e.b = append(append(e.b, v...), 0)
+}
+
+func (e *encoder) signature(v string) {
+	e.b = append(append(append(e.b, byte(len(v))), v...), 0)
+}
+
+// Массив: длина, выравнивание первого элемента, элементы (fn).
+func (e *encoder) array(elemAlign int, fn func()) {
+	e.uint32(0)
+	pos := len(e.b)
+	e.align(elemAlign)
+	start := len(e.b)
+	fn()
+	binary.LittleEndian.PutUint32(e.b[pos-4:], uint32(len(e.b)-start))
+}
+
+// Структура (выравнивание 8).
+func (e *encoder) strct(fn func()) {
+	e.align(8)
+	fn()
+}
+
+// Декодировщик значений D-Bus.
+type decoder struct {
+	b     []byte
+	pos   int
+	order binary.ByteOrder
+	err   error
+}
+
+func (d *decoder) align(n int) {
+	d.pos += (n - d.pos%n) % n
+}
+
+func (d *decoder) next(n int) []byte {
+	if d.err != nil || d.pos+n > len(d.b) {
+		d.err = errMessage
+		return make([]byte, n)
+	}
+	d.pos += n
+	return d.b[d.pos-n : d.pos]
+}
+
+func (d *decoder) byte() byte {
+	return d.next(1)[0]
+}
+
+func (d *decoder) uint32() uint32 {
+	d.align(4)
+	return d.order.Uint32(d.next(4))
+}
+
+func (d *decoder) int64() int64 {
+	d.align(8)
+	return int64(d.order.Uint64(d.next(8)))
+}
+
+func (d *decoder) string() string {
+	n := d.uint32()
+	if n > maxMessage {
+		d.err = errMessage
+		return ""
+	}
+	s := d.next(int(n) + 1)
+	return string(s[:n])
+}
+
+func (d *decoder) signature() string {
+	n := d.byte()
+	s := d.next(int(n) + 1)
+	return string(s[:n])
+}
+
+// EOF: "wire.go"
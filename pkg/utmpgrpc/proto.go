@@ -0,0 +1,242 @@
+// File: "proto.go"
+
+package utmpgrpc
+
+import (
+	"encoding/binary"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/azorg/gousers/v2/dto"
+)
+
+// Минимальная реализация кодирования Protocol Buffers (без внешних
+// зависимостей и генерации кода) для сообщений gousers.proto:
+// сообщения кодируются вручную, поля со значением по умолчанию
+// не записываются (proto3).
+// Minimal protobuf encoding of gousers.proto messages.
+
+// Protobuf wire types
+const (
+	pbVARINT = 0
+	pbBYTES  = 2
+)
+
+// Буфер кодирования сообщения.
+type pbuf []byte
+
+func (b *pbuf) tag(field, wire int) {
+	*b = binary.AppendUvarint(*b, uint64(field)<<3|uint64(wire))
+}
+
+func (b *pbuf) uint(field int, v uint64) {
+	if v != 0 {
+		b.tag(field, pbVARINT)
+		*b = binary.AppendUvarint(*b, v)
+	}
+}
+
+func (b *pbuf) int(field int, v int64) {
+	b.uint(field, uint64(v)) // int32/int64: negative as 10 bytes
+}
+
+func (b *pbuf) bool(field int, v bool) {
+	if v {
+		b.uint(field, 1)
+	}
+}
+
+func (b *pbuf) bytes(field int, v []byte) {
+	b.tag(field, pbBYTES)
+	*b = binary.AppendUvarint(*b, uint64(len(v)))
+	*b = append(*b, v...)
+}
+
+func (b *pbuf) string(field int, v string) {
+	if v != "" {
+		b.bytes(field, []byte(v))
+	}
+}
+
+func (b *pbuf) strings(field int, list []string) {
+	for _, v := range list {
+		b.bytes(field, []byte(v)) // repeated: empty strings kept
+	}
+}
+
+// Вложенное сообщение (записывается и пустое: признак наличия).
+func (b *pbuf) message(field int, m pbuf) {
+	b.bytes(field, m)
+}
+
+// google.protobuf.Timestamp (нулевое время не записывается).
+func (b *pbuf) time(field int, t time.Time) {
+	if t.IsZero() {
+		return
+	}
+	var m pbuf
+	m.int(1, t.Unix())
+	m.int(2, int64(t.Nanosecond()))
+	b.message(field, m)
+}
+
+// map<string, string> (ключи по возрастанию).
+func (b *pbuf) stringMap(field int, kv map[string]string) {
+	for _, k := range sortedKeys(kv) {
+		var m pbuf
+		m.string(1, k)
+		m.string(2, kv[k])
+		b.message(field, m)
+	}
+}
+
+// map<string, int32> (ключи по возрастанию).
+func (b *pbuf) intMap(field int, kv map[string]int) {
+	for _, k := range sortedKeys(kv) {
+		var m pbuf
+		m.string(1, k)
+		m.int(2, int64(kv[k]))
+		b.message(field, m)
+	}
+}
+
+func sortedKeys[V any](kv map[string]V) []string {
+	keys := make([]string, 0, len(kv))
+	for k := range kv {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// Закодировать User.
+func encodeUser(u dto.User) pbuf {
+	var b pbuf
+	b.string(1, u.Name)
+	b.string(2, u.UID)
+	b.string(3, u.GID)
+	b.string(4, u.DisplayName)
+	b.string(5, u.HomeDir)
+	b.string(6, u.Groups)
+	b.string(7, u.LogonType)
+	b.time(8, u.LogonTime)
+	b.int(9, int64(u.Logons))
+	b.stringMap(10, u.Labels)
+	return b
+}
+
+// Закодировать ListUsersResponse.
+func encodeUsers(users []dto.User) pbuf {
+	var b pbuf
+	for _, u := range users {
+		b.message(1, encodeUser(u))
+	}
+	return b
+}
+
+// Закодировать UsersStat.
+func encodeStat(s dto.UsersStat) pbuf {
+	var b pbuf
+	b.int(1, int64(s.Total))
+	b.int(2, int64(s.LocalX))
+	b.int(3, int64(s.Local))
+	b.int(4, int64(s.RemoteX))
+	b.int(5, int64(s.Remote))
+	b.int(6, int64(s.Unknown))
+	b.bool(7, s.LocalRoot)
+	b.bool(8, s.RemoteRoot)
+	b.string(9, s.Active)
+	b.strings(10, s.LocalXUsers)
+	b.strings(11, s.LocalUsers)
+	b.strings(12, s.RemoteXUsers)
+	b.strings(13, s.RemoteUsers)
+	b.strings(14, s.UnknownUsers)
+	b.intMap(15, s.Logons)
+	b.intMap(16, s.Groups)
+	b.stringMap(17, s.Seats)
+	b.bool(18, s.Offline)
+	b.string(19, s.BootID)
+	b.stringMap(20, s.Labels)
+	return b
+}
+
+// Закодировать UserTTY.
+func encodeUserTTY(ut dto.UserTTY) pbuf {
+	var b pbuf
+	b.string(1, ut.User)
+	b.string(2, ut.TTY)
+	b.string(3, ut.LogonType)
+	return b
+}
+
+// Закодировать LoginEvent.
+func encodeEvent(e dto.LoginEvent, snapshot bool) pbuf {
+	var b pbuf
+	b.uint(1, e.Seq)
+	b.time(2, e.Time)
+	for _, ut := range e.Login {
+		b.message(3, encodeUserTTY(ut))
+	}
+	for _, ut := range e.Logout {
+		b.message(4, encodeUserTTY(ut))
+	}
+	for _, u := range e.Users {
+		b.message(5, encodeUser(u))
+	}
+	b.message(6, encodeStat(e.Stat))
+	b.bool(7, e.Partial)
+	b.int(8, int64(e.Coalesced))
+	b.bool(9, e.Resync)
+	b.bool(10, e.Historical)
+	b.bool(11, snapshot)
+	return b
+}
+
+// Некорректное сообщение protobuf.
+var errProto = errors.New("malformed protobuf message")
+
+// Получить строковое поле сообщения (последнее значение, неизвестные
+// поля пропускаются), например GetUserRequest.name.
+func decodeString(msg []byte, field int) (string, error) {
+	var s string
+	for len(msg) > 0 {
+		key, n := binary.Uvarint(msg)
+		if n <= 0 {
+			return "", errProto
+		}
+		msg = msg[n:]
+		switch key & 7 {
+		case 0: // varint
+			_, n = binary.Uvarint(msg)
+			if n <= 0 {
+				return "", errProto
+			}
+			msg = msg[n:]
+		case 1: // fixed64
+			if len(msg) < 8 {
+				return "", errProto
+			}
+			msg = msg[8:]
+		case 2: // length delimited
+			l, n := binary.Uvarint(msg)
+			if n <= 0 || l > uint64(len(msg)-n) {
+				return "", errProto
+			}
+			if int(key>>3) == field {
+				s = string(msg[n : n+int(l)])
+			}
+			msg = msg[n+int(l):]
+		case 5: // fixed32
+			if len(msg) < 4 {
+				return "", errProto
+			}
+			msg = msg[4:]
+		default:
+			return "", errProto
+		}
+	}
+	return s, nil
+}
+
+// EOF: "proto.go"
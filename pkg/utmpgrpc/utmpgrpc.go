@@ -0,0 +1,212 @@
+// File: "utmpgrpc.go"
+
+/*
+Пакет `utmpgrpc` - служба gRPC сведений о вошедших пользователях
+(см. gousers.proto): ListUsers, GetUser, GetStat и поток событий
+WatchEvents по данным `utmp.Login`, для клиентов с типизированным
+API вместо разбора JSON.
+
+Реализация не использует внешних зависимостей: обработчик net/http
+разбирает протокол gRPC (HTTP/2, сообщения без сжатия), сообщения
+кодируются вручную. Стандартный сервер net/http поддерживает HTTP/2
+только с TLS, поэтому клиенты подключаются по TLS:
+
+	srv := &http.Server{Addr: ":50051", Handler: utmpgrpc.Handler(l)}
+	log.Fatal(srv.ListenAndServeTLS("cert.pem", "key.pem"))
+
+Клиентский код для любого языка генерируется из gousers.proto.
+
+Package utmpgrpc is the dependency free gRPC service of logged users.
+*/
+package utmpgrpc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/azorg/gousers/v2/dto"
+	"github.com/azorg/gousers/v2/pkg/utmp"
+	"github.com/azorg/gousers/v2/pkg/utmphttp"
+)
+
+// Имя службы (package.service из gousers.proto).
+// Full service name.
+const SERVICE = "gousers.v1.Gousers"
+
+// Наибольший размер запроса.
+// Max request message size.
+const MAX_REQUEST = 64 * 1024
+
+// Коды завершения gRPC (google.golang.org/grpc/codes).
+// gRPC status codes.
+const (
+	CODE_OK               = 0
+	CODE_INVALID_ARGUMENT = 3
+	CODE_NOT_FOUND        = 5
+	CODE_UNIMPLEMENTED    = 12
+	CODE_INTERNAL         = 13
+	CODE_UNAVAILABLE      = 14
+)
+
+// Ошибки разбора запроса.
+var (
+	errCompressed = errors.New("compressed messages are not supported")
+	errTooLarge   = errors.New("request message is too large")
+)
+
+// Обработчик gRPC запросов.
+type handler struct {
+	l *utmp.Login
+}
+
+// Создать обработчик gRPC службы SERVICE (для http.Server с TLS).
+// Create gRPC handler.
+func Handler(l *utmp.Login) http.Handler {
+	return &handler{l: l}
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.ProtoMajor != 2 || r.Method != http.MethodPost ||
+		!strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+		http.Error(w, "gRPC (HTTP/2) requests only", http.StatusUnsupportedMediaType)
+		return
+	}
+	w.Header().Set("Content-Type", "application/grpc")
+
+	msg, err := readMessage(r.Body)
+	if errors.Is(err, errCompressed) {
+		status(w, CODE_UNIMPLEMENTED, err.Error())
+		return
+	} else if err != nil {
+		status(w, CODE_INVALID_ARGUMENT, err.Error())
+		return
+	}
+
+	switch r.URL.Path {
+	case "/" + SERVICE + "/ListUsers":
+		users := []dto.User{}
+		for _, li := range h.l.GetUsers() {
+			users = append(users, utmphttp.User(li))
+		}
+		writeMessage(w, encodeUsers(users))
+		status(w, CODE_OK, "")
+
+	case "/" + SERVICE + "/GetUser":
+		name, err := decodeString(msg, 1)
+		if err != nil {
+			status(w, CODE_INVALID_ARGUMENT, err.Error())
+			return
+		}
+		for _, li := range h.l.GetUsers() {
+			if li.Name == name {
+				writeMessage(w, encodeUser(utmphttp.User(li)))
+				status(w, CODE_OK, "")
+				return
+			}
+		}
+		status(w, CODE_NOT_FOUND, "user not logged in")
+
+	case "/" + SERVICE + "/GetStat":
+		writeMessage(w, encodeStat(utmphttp.Stat(h.l.GetStat())))
+		status(w, CODE_OK, "")
+
+	case "/" + SERVICE + "/WatchEvents":
+		h.watchEvents(w, r)
+
+	default:
+		status(w, CODE_UNIMPLEMENTED, "unknown method "+r.URL.Path)
+	}
+}
+
+// WatchEvents: снимок состояния, затем события до отмены запроса
+// клиентом или завершения Login.
+func (h *handler) watchEvents(w http.ResponseWriter, r *http.Request) {
+	c := h.l.Subscribe(utmp.LOGIN_QUEUE, utmp.OVERFLOW_COALESCE)
+	defer h.l.Unsubscribe(c)
+
+	snap := dto.LoginEvent{Users: []dto.User{}}
+	for _, li := range h.l.GetUsers() {
+		snap.Users = append(snap.Users, utmphttp.User(li))
+	}
+	snap.Stat = utmphttp.Stat(h.l.GetStat())
+	if !writeMessage(w, encodeEvent(snap, true)) {
+		return
+	}
+
+	for {
+		select {
+		case evt, ok := <-c:
+			if !ok {
+				status(w, CODE_UNAVAILABLE, "service stopped")
+				return
+			}
+			if !writeMessage(w, encodeEvent(utmphttp.Event(evt), false)) {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// Прочитать сообщение запроса (префикс: флаг сжатия и длина).
+func readMessage(body io.Reader) ([]byte, error) {
+	var hdr [5]byte
+	if _, err := io.ReadFull(body, hdr[:]); err != nil {
+		return nil, errProto
+	}
+	if hdr[0] != 0 {
+		return nil, errCompressed
+	}
+	n := binary.BigEndian.Uint32(hdr[1:])
+	if n > MAX_REQUEST {
+		return nil, errTooLarge
+	}
+	msg := make([]byte, n)
+	if _, err := io.ReadFull(body, msg); err != nil {
+		return nil, errProto
+	}
+	return msg, nil
+}
+
+// Отправить сообщение ответа (false - клиент отключился).
+func writeMessage(w http.ResponseWriter, msg pbuf) bool {
+	var hdr [5]byte
+	binary.BigEndian.PutUint32(hdr[1:], uint32(len(msg)))
+	if _, err := w.Write(append(hdr[:], msg...)); err != nil {
+		return false
+	}
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return true
+}
+
+// Завершить вызов с кодом (трейлеры grpc-status, grpc-message).
+func status(w http.ResponseWriter, code int, msg string) {
+	w.Header().Set(http.TrailerPrefix+"Grpc-Status", strconv.Itoa(code))
+	if msg != "" {
+		w.Header().Set(http.TrailerPrefix+"Grpc-Message", percentEncode(msg))
+	}
+}
+
+// Кодирование grpc-message: байты вне 0x20-0x7E и '%' - %XX.
+func percentEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < 0x20 || c > 0x7E || c == '%' {
+			fmt.Fprintf(&b, "%%%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// EOF: "utmpgrpc.go"
@@ -0,0 +1,144 @@
+// File: "utmpgrpc_test.go"
+
+package utmpgrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/azorg/gousers/v2/pkg/utmp"
+)
+
+// Fields of protobuf message (wire type 2 only, other types are skipped)
+func fields(t *testing.T, msg []byte) map[int][][]byte {
+	f := make(map[int][][]byte)
+	for len(msg) > 0 {
+		key, n := binary.Uvarint(msg)
+		require.Greater(t, n, 0)
+		msg = msg[n:]
+		switch key & 7 {
+		case 0:
+			_, n = binary.Uvarint(msg)
+			require.Greater(t, n, 0)
+			msg = msg[n:]
+		case 2:
+			l, n := binary.Uvarint(msg)
+			require.Greater(t, n, 0)
+			f[int(key>>3)] = append(f[int(key>>3)], msg[n:n+int(l)])
+			msg = msg[n+int(l):]
+		default:
+			t.Fatalf("unexpected wire type %d", key&7)
+		}
+	}
+	return f
+}
+
+func TestHandler(t *testing.T) {
+	data, err := os.ReadFile("../utmp/testdata/wtmp/debian12.wtmp")
+	require.NoError(t, err)
+	fname := filepath.Join(t.TempDir(), "wtmp")
+	require.NoError(t, os.WriteFile(fname, data, 0644))
+	l, err := utmp.NewLogin(fname, false)
+	require.NoError(t, err)
+	defer l.Close()
+
+	srv := httptest.NewUnstartedServer(Handler(l))
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	defer srv.Close()
+
+	// unary call: response messages and trailers
+	call := func(method string, req []byte) ([][]byte, http.Header) {
+		body := append([]byte{0, 0, 0, 0, byte(len(req))}, req...)
+		r, err := http.NewRequest("POST", srv.URL+"/"+SERVICE+"/"+method, bytes.NewReader(body))
+		require.NoError(t, err)
+		r.Header.Set("Content-Type", "application/grpc")
+		r.Header.Set("TE", "trailers")
+		resp, err := srv.Client().Do(r)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, 2, resp.ProtoMajor)
+		data, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		var msgs [][]byte
+		for len(data) > 0 {
+			n := binary.BigEndian.Uint32(data[1:5])
+			msgs = append(msgs, data[5:5+n])
+			data = data[5+n:]
+		}
+		return msgs, resp.Trailer
+	}
+
+	msgs, tr := call("ListUsers", nil)
+	require.Equal(t, "0", tr.Get("Grpc-Status"))
+	require.Len(t, msgs, 1)
+	users := fields(t, msgs[0])[1]
+	require.NotEmpty(t, users)
+	name := fields(t, users[0])[1][0]
+
+	var req pbuf
+	req.string(1, string(name))
+	msgs, tr = call("GetUser", req)
+	require.Equal(t, "0", tr.Get("Grpc-Status"))
+	require.Equal(t, name, fields(t, msgs[0])[1][0])
+
+	req = nil
+	req.string(1, "nosuchuser0")
+	msgs, tr = call("GetUser", req)
+	require.Equal(t, "5", tr.Get("Grpc-Status"))
+	require.Empty(t, msgs)
+
+	msgs, tr = call("GetStat", nil)
+	require.Equal(t, "0", tr.Get("Grpc-Status"))
+	require.Len(t, msgs, 1)
+
+	_, tr = call("NoSuchMethod", nil)
+	require.Equal(t, "12", tr.Get("Grpc-Status"))
+
+	// stream: snapshot first
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r, err := http.NewRequestWithContext(ctx, "POST", srv.URL+"/"+SERVICE+"/WatchEvents",
+		bytes.NewReader([]byte{0, 0, 0, 0, 0}))
+	require.NoError(t, err)
+	r.Header.Set("Content-Type", "application/grpc")
+	resp, err := srv.Client().Do(r)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	var hdr [5]byte
+	_, err = io.ReadFull(resp.Body, hdr[:])
+	require.NoError(t, err)
+	msg := make([]byte, binary.BigEndian.Uint32(hdr[1:]))
+	_, err = io.ReadFull(resp.Body, msg)
+	require.NoError(t, err)
+	require.Len(t, fields(t, msg)[5], len(users))
+	require.Equal(t, []byte{11<<3 | pbVARINT, 1}, msg[len(msg)-2:]) // snapshot
+}
+
+func TestDecodeString(t *testing.T) {
+	var b pbuf
+	b.uint(2, 300)
+	b.string(1, "alice")
+	b.bool(3, true)
+	s, err := decodeString(b, 1)
+	require.NoError(t, err)
+	require.Equal(t, "alice", s)
+
+	_, err = decodeString([]byte{0x0A, 0x05, 'a'}, 1)
+	require.ErrorIs(t, err, errProto)
+}
+
+func TestPercentEncode(t *testing.T) {
+	require.Equal(t, "a%25b%0A%D0%96", percentEncode("a%b\n\xd0\x96"))
+}
+
+// EOF: "utmpgrpc_test.go"
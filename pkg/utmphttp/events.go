@@ -0,0 +1,277 @@
+// File: "events.go"
+
+package utmphttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/azorg/gousers/v2/dto"
+	"github.com/azorg/gousers/v2/pkg/siem"
+	"github.com/azorg/gousers/v2/pkg/sink"
+	"github.com/azorg/gousers/v2/pkg/utmp"
+)
+
+// Число последних событий, хранимых для переподключения клиентов
+// (Last-Event-ID).
+// Size of event history.
+const HISTORY = 256
+
+// Период комментариев-пульса потока событий по умолчанию.
+// Default heartbeat interval of event stream.
+const HEARTBEAT = 15 * time.Second
+
+// Задержка переподключения, сообщаемая клиентам (поле retry).
+// Reconnection delay suggested to clients.
+const RETRY = 3 * time.Second
+
+// Событие истории: представление JSON и входы/выходы (для форматов
+// SIEM, см. пакет siem).
+type record struct {
+	evt     dto.LoginEvent
+	entries []sink.Entry
+}
+
+// Последние события Login (единственная подписка обработчика).
+type history struct {
+	mx     sync.Mutex
+	events []record      // последние события по возрастанию Seq
+	base   uint64        // Seq последнего вытесненного события
+	last   uint64        // Seq последнего события
+	wake   chan struct{} // закрывается при новом событии
+	closed bool          // Login закрыт
+}
+
+// Подписаться на события Login и хранить последние HISTORY событий.
+func newHistory(l *utmp.Login) *history {
+	h := &history{wake: make(chan struct{})}
+	c := l.Subscribe(utmp.LOGIN_QUEUE, utmp.OVERFLOW_DROP_OLDEST)
+	go func() {
+		for evt := range c {
+			h.add(record{Event(evt), sink.Entries(evt)})
+		}
+		h.mx.Lock()
+		h.closed = true
+		close(h.wake)
+		h.mx.Unlock()
+	}()
+	return h
+}
+
+// Добавить событие и разбудить ожидающих.
+func (h *history) add(rec record) {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+	if len(h.events) == HISTORY {
+		h.base = h.events[0].evt.Seq
+		h.events = h.events[1:]
+	}
+	h.events = append(h.events, rec)
+	h.last = rec.evt.Seq
+	close(h.wake)
+	h.wake = make(chan struct{})
+}
+
+// Получить события после события с номером seq, канал ожидания следующих
+// событий и признак, что события после seq сохранены полностью (иначе
+// нужен снимок состояния).
+func (h *history) since(seq uint64) (events []record, wake <-chan struct{}, ok bool) {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+	ok = seq >= h.base && seq <= h.last
+	if ok {
+		for _, rec := range h.events {
+			if rec.evt.Seq > seq {
+				events = append(events, rec)
+			}
+		}
+	}
+	return events, h.wake, ok
+}
+
+// Номер последнего события и признак завершения Login.
+func (h *history) state() (uint64, bool) {
+	h.mx.Lock()
+	defer h.mx.Unlock()
+	return h.last, h.closed
+}
+
+// GET /events: поток событий входа/выхода (Server-Sent Events). Первым
+// отправляется снимок состояния (event: snapshot), затем события
+// (event: login) с id = LoginEvent.Seq. При переподключении с заголовком
+// Last-Event-ID (или параметром last_event_id) пропущенные события
+// отправляются из истории, если они в ней сохранились, иначе - снимок.
+// С параметром format=cef|ecs события отправляются в формате SIEM (строка
+// data на каждый вход/выход), снимки состояния не отправляются.
+func (h *handler) events(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	var enc siem.Encoder
+	if format := r.URL.Query().Get("format"); format != "" {
+		var err error
+		enc, err = siem.NewEncoder(format, siem.Opts{})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	snapshot := func(seq uint64) bool {
+		if enc != nil {
+			return true // no snapshots in SIEM formats
+		}
+		return h.send(w, "snapshot", h.snapshot(seq))
+	}
+
+	id := r.Header.Get("Last-Event-ID")
+	if id == "" {
+		id = r.URL.Query().Get("last_event_id")
+	}
+	var seq uint64
+	resume := false
+	if id != "" {
+		var err error
+		seq, err = strconv.ParseUint(id, 10, 64)
+		if err != nil {
+			http.Error(w, "bad Last-Event-ID", http.StatusBadRequest)
+			return
+		}
+		resume = true
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // nginx
+	fmt.Fprintf(w, "retry: %d\n\n", RETRY.Milliseconds())
+
+	events, wake, ok := h.hist.since(seq)
+	if !resume || !ok {
+		seq, _ = h.hist.state()
+		if !snapshot(seq) {
+			return
+		}
+		events, wake, _ = h.hist.since(seq)
+	}
+	flusher.Flush()
+
+	heartbeat := h.opts.Heartbeat
+	if heartbeat <= 0 {
+		heartbeat = HEARTBEAT
+	}
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+	for {
+		for _, rec := range events {
+			if enc != nil {
+				if !sendEncoded(w, enc, rec) {
+					return
+				}
+			} else if !h.send(w, "login", rec.evt) {
+				return
+			}
+			seq = rec.evt.Seq
+		}
+		flusher.Flush()
+
+		select {
+		case <-wake:
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+		if _, closed := h.hist.state(); closed {
+			return
+		}
+		events, wake, ok = h.hist.since(seq)
+		if !ok { // events dropped from history (slow client)
+			seq, _ = h.hist.state()
+			if !snapshot(seq) {
+				return
+			}
+			events, wake, _ = h.hist.since(seq)
+		}
+	}
+}
+
+// Снимок текущего состояния в виде события без входов/выходов.
+func (h *handler) snapshot(seq uint64) dto.LoginEvent {
+	evt := dto.LoginEvent{Seq: seq, Time: time.Now(), Users: []dto.User{}}
+	for _, li := range h.l.GetUsers() {
+		evt.Users = append(evt.Users, User(li))
+	}
+	evt.Stat = Stat(h.l.GetStat())
+	return evt
+}
+
+// Отправить событие SSE (false - ошибка записи, клиент отключился).
+func (h *handler) send(w http.ResponseWriter, name string, evt dto.LoginEvent) bool {
+	data, err := json.Marshal(&evt)
+	if err != nil {
+		h.error("json.Marshal()", err)
+		return false
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", evt.Seq, name, data)
+	return err == nil
+}
+
+// Отправить событие SSE в формате SIEM (строка data на каждый вход/выход,
+// события без входов/выходов пропускаются).
+func sendEncoded(w http.ResponseWriter, enc siem.Encoder, rec record) bool {
+	if len(rec.entries) == 0 {
+		return true
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "id: %d\nevent: login\n", rec.evt.Seq)
+	for _, e := range rec.entries {
+		b.WriteString("data: ")
+		b.Write(enc.Entry(e))
+		b.WriteByte('\n')
+	}
+	b.WriteByte('\n')
+	_, err := w.Write(b.Bytes())
+	return err == nil
+}
+
+// Преобразовать utmp.LoginEvent в dto.LoginEvent.
+// Repack utmp.LoginEvent to dto.LoginEvent.
+func Event(evt utmp.LoginEvent) dto.LoginEvent {
+	uts := func(list []utmp.UserTTY) []dto.UserTTY {
+		var res []dto.UserTTY
+		for _, ut := range list {
+			res = append(res, dto.UserTTY{
+				User:      ut.User,
+				TTY:       ut.TTY,
+				LogonType: dto.LogonType[evt.Types[ut]]})
+		}
+		return res
+	}
+	e := dto.LoginEvent{
+		Seq:        evt.Seq,
+		Time:       evt.Time,
+		Login:      uts(evt.Login),
+		Logout:     uts(evt.Logout),
+		Users:      []dto.User{},
+		Stat:       Stat(evt.Stat),
+		Partial:    evt.Partial,
+		Coalesced:  evt.Coalesced,
+		Resync:     evt.Resync,
+		Historical: evt.Historical}
+	for _, li := range evt.Users {
+		e.Users = append(e.Users, User(li))
+	}
+	return e
+}
+
+// EOF: "events.go"
@@ -0,0 +1,131 @@
+// File: "events_test.go"
+
+package utmphttp
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/azorg/gousers/v2/dto"
+	"github.com/azorg/gousers/v2/pkg/utmp"
+)
+
+// Server-Sent Event
+type sse struct {
+	id, event string
+	data      dto.LoginEvent
+}
+
+// Read next event (comments and retry field are skipped)
+func readSSE(t *testing.T, r *bufio.Reader) sse {
+	var e sse
+	for {
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "" && e.event != "":
+			return e
+		case strings.HasPrefix(line, "id: "):
+			e.id = line[4:]
+		case strings.HasPrefix(line, "event: "):
+			e.event = line[7:]
+		case strings.HasPrefix(line, "data: "):
+			require.NoError(t, json.Unmarshal([]byte(line[6:]), &e.data))
+		}
+	}
+}
+
+func TestEvents(t *testing.T) {
+	fname := filepath.Join(t.TempDir(), "utmp")
+	require.NoError(t, os.WriteFile(fname, nil, 0644))
+	login := func(user, line string) {
+		u := utmp.Utmp{Type: utmp.USER_PROCESS}
+		for i := range line {
+			u.Line[i] = int8(line[i])
+		}
+		for i := range user {
+			u.User[i] = int8(user[i])
+		}
+		u.TV.Sec = int32(time.Now().Unix())
+		f, err := os.OpenFile(fname, os.O_WRONLY|os.O_APPEND, 0)
+		require.NoError(t, err)
+		require.NoError(t, binary.Write(f, binary.LittleEndian, &u))
+		require.NoError(t, f.Close())
+	}
+
+	l, err := utmp.NewLoginWith(fname, utmp.LoginOpts{})
+	require.NoError(t, err)
+	defer l.Close()
+	srv := httptest.NewServer(NewHandler(l, Opts{Heartbeat: 50 * time.Millisecond}))
+	defer srv.Close()
+
+	get := func(lastID string) (*bufio.Reader, func()) {
+		req, err := http.NewRequest("GET", srv.URL+"/events", nil)
+		require.NoError(t, err)
+		if lastID != "" {
+			req.Header.Set("Last-Event-ID", lastID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+		t.Cleanup(func() { resp.Body.Close() })
+		return bufio.NewReader(resp.Body), func() { resp.Body.Close() }
+	}
+
+	// initial snapshot, then live events
+	r, stop := get("")
+	e := readSSE(t, r)
+	require.Equal(t, "snapshot", e.event)
+	require.Empty(t, e.data.Users)
+	login("alice", "tty1")
+	e = readSSE(t, r)
+	require.Equal(t, "login", e.event)
+	require.Equal(t, []dto.UserTTY{{User: "alice", TTY: "tty1", LogonType: "local"}}, e.data.Login)
+	first := e.id
+	stop()
+
+	// reconnection: missed events from history
+	login("bob", "tty2")
+	time.Sleep(200 * time.Millisecond)
+	r, stop = get(first)
+	e = readSSE(t, r)
+	require.Equal(t, "login", e.event)
+	require.Equal(t, "bob", e.data.Login[0].User)
+	stop()
+
+	// SIEM format: no snapshot, one data line per login
+	resp, err := http.Get(srv.URL + "/events?format=cef&last_event_id=" + first)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+	r = bufio.NewReader(resp.Body)
+	for {
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+		if strings.HasPrefix(line, "data: ") {
+			require.True(t, strings.HasPrefix(line, "data: CEF:0|azorg|gousers|2|login|User login|"), line)
+			require.Contains(t, line, " duser=bob ")
+			break
+		}
+	}
+	resp.Body.Close()
+
+	// unknown Last-Event-ID: snapshot
+	r, stop = get("100000")
+	e = readSSE(t, r)
+	require.Equal(t, "snapshot", e.event)
+	require.Len(t, e.data.Users, 2)
+	stop()
+}
+
+// EOF: "events_test.go"
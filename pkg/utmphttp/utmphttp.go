@@ -0,0 +1,307 @@
+// File: "utmphttp.go"
+
+/*
+Пакет `utmphttp` - HTTP обработчик (net/http) для встраивания в службы:
+сведения о вошедших пользователях и статистика из `utmp.Login` (в памяти)
+и сеансы из wtmp в виде JSON (типы пакета `dto`).
+
+Обработчик подключается к собственному мультиплексору и middleware
+(аутентификация и т.п.) приложения:
+
+	l, err := utmp.NewLogin("", false)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer l.Close()
+	mux.Handle("/gousers/", auth(http.StripPrefix("/gousers", utmphttp.Handler(l))))
+
+Пути (только GET/HEAD):
+
+	/users         - вошедшие пользователи ([]dto.User)
+	/users/<name>  - пользователь (dto.User), 404 - не вошёл
+	/stat          - статистика (dto.UsersStat)
+	/sessions      - сеансы ([]dto.Session), параметр since=<время>
+	                 (только при заданном Opts.Sessions)
+	/events        - поток событий (Server-Sent Events, dto.LoginEvent):
+	                 снимок состояния, события входа/выхода, пульс,
+	                 переподключение по Last-Event-ID (LoginEvent.Seq)
+
+Параметр format=cef|ecs путей /sessions и /events - представление
+сеансов и входов/выходов в формате SIEM (см. пакет siem).
+
+Обработчик подписывается на события `Login` при создании (подписка
+завершается при Close()).
+
+Package utmphttp is the embeddable net/http handler of logged users.
+*/
+package utmphttp
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/azorg/gousers/v2/dto"
+	"github.com/azorg/gousers/v2/pkg/siem"
+	"github.com/azorg/gousers/v2/pkg/utmp"
+)
+
+// Опции обработчика (см. NewHandler()).
+// Handler options.
+type Opts struct {
+	// Файл сеансов для /sessions (обычно wtmp, "" - путь не обслуживается)
+	Sessions string
+
+	// Опции чтения файла сеансов (Since заменяется параметром since)
+	GetUsersOpts utmp.GetUsersOpts
+
+	// Журнал ошибок (nil - не вести)
+	Logger *slog.Logger
+
+	// Период пульса потока /events (0 - HEARTBEAT)
+	Heartbeat time.Duration
+}
+
+// HTTP обработчик (см. описание пакета).
+// HTTP handler.
+type handler struct {
+	l    *utmp.Login
+	opts Opts
+	mux  *http.ServeMux
+	hist *history // последние события для /events
+}
+
+// Создать обработчик вошедших пользователей, статистики и событий (без
+// /sessions).
+// Create handler.
+func Handler(l *utmp.Login) http.Handler {
+	return NewHandler(l, Opts{})
+}
+
+// Вариант Handler() с опциями (см. `Opts`).
+// Create handler with options.
+func NewHandler(l *utmp.Login, opts Opts) http.Handler {
+	h := &handler{l: l, opts: opts, mux: http.NewServeMux()}
+	h.hist = newHistory(l)
+	h.mux.HandleFunc("/users", h.users)
+	h.mux.HandleFunc("/users/", h.user)
+	h.mux.HandleFunc("/stat", h.stat)
+	h.mux.HandleFunc("/events", h.events)
+	if opts.Sessions != "" {
+		h.mux.HandleFunc("/sessions", h.sessions)
+	}
+	return h
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	h.mux.ServeHTTP(w, r)
+}
+
+// GET /users
+func (h *handler) users(w http.ResponseWriter, r *http.Request) {
+	users := []dto.User{}
+	for _, li := range h.l.GetUsers() {
+		users = append(users, User(li))
+	}
+	h.write(w, users)
+}
+
+// GET /users/<name>
+func (h *handler) user(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/users/")
+	for _, li := range h.l.GetUsers() {
+		if li.Name == name {
+			h.write(w, User(li))
+			return
+		}
+	}
+	http.Error(w, "user not logged in", http.StatusNotFound)
+}
+
+// GET /stat
+func (h *handler) stat(w http.ResponseWriter, r *http.Request) {
+	h.write(w, Stat(h.l.GetStat()))
+}
+
+// GET /sessions?since=<time>&format=<cef|ecs>
+func (h *handler) sessions(w http.ResponseWriter, r *http.Request) {
+	var enc siem.Encoder
+	format := r.URL.Query().Get("format")
+	if format != "" {
+		var err error
+		enc, err = siem.NewEncoder(format, siem.Opts{})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	opts := h.opts.GetUsersOpts
+	if s := r.URL.Query().Get("since"); s != "" {
+		since, err := ParseTime(s)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		opts.Since = since
+	}
+	sessions, err := utmp.GetSessionsContext(r.Context(), h.opts.Sessions, opts)
+	if err != nil {
+		h.error("can't read sessions", err)
+		http.Error(w, "can't read sessions", http.StatusInternalServerError)
+		return
+	}
+	if enc != nil { // one CEF line or ECS document per session
+		if format == siem.FORMAT_ECS {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+		} else {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		}
+		now := time.Now()
+		for _, s := range sessions {
+			w.Write(append(enc.Session(s, now), '\n'))
+		}
+		return
+	}
+	h.write(w, Sessions(sessions, time.Now()))
+}
+
+// Записать ответ в JSON.
+func (h *handler) write(w http.ResponseWriter, v any) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		h.error("json.Marshal()", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(append(data, '\n'))
+}
+
+// Записать ошибку в журнал (если задан).
+func (h *handler) error(msg string, err error) {
+	if h.opts.Logger != nil {
+		h.opts.Logger.Error(msg, "err", err)
+	}
+}
+
+// Разобрать время ("2006-01-02 15:04:05", "2006-01-02 15:04",
+// "2006-01-02" в местном времени или RFC 3339).
+// Parse time.
+func ParseTime(s string) (time.Time, error) {
+	for _, layout := range []string{
+		"2006-01-02 15:04:05",
+		"2006-01-02 15:04",
+		"2006-01-02",
+		time.RFC3339,
+	} {
+		t, err := time.ParseInLocation(layout, s, time.Local)
+		if err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("can't parse time '%s'", s)
+}
+
+// Преобразовать utmp.LoginInfo в dto.User.
+// Repack utmp.LoginInfo to dto.User.
+func User(li utmp.LoginInfo) dto.User {
+	var auth []dto.Auth
+	for _, a := range li.Auth {
+		auth = append(auth, Auth(a))
+	}
+	return dto.User{
+		Name:        li.Name,
+		UID:         li.UID,
+		GID:         li.GID,
+		DisplayName: li.DisplayName,
+		HomeDir:     li.HomeDir,
+		Groups:      li.Groups,
+		LogonType:   dto.LogonType[li.Type],
+		LogonTime:   li.Time,
+		Logons:      li.Logons,
+		Labels:      utmp.Labels(),
+		Auth:        auth,
+		Idle:        int64(max(li.Idle, 0) / time.Second)}
+}
+
+// Преобразовать utmp.AuthInfo в dto.Auth.
+// Repack utmp.AuthInfo to dto.Auth.
+func Auth(a utmp.AuthInfo) dto.Auth {
+	return dto.Auth{
+		Method:      a.Method,
+		KeyType:     a.KeyType,
+		Fingerprint: a.Fingerprint,
+		CertID:      a.CertID,
+		Port:        a.Port,
+		Time:        a.Time}
+}
+
+// Преобразовать utmp.LoginStat в dto.UsersStat.
+// Repack utmp.LoginStat to dto.UsersStat.
+func Stat(us utmp.LoginStat) dto.UsersStat {
+	stat := dto.UsersStat{
+		Total:      us.Total,
+		LocalX:     us.LocalX,
+		Local:      us.Local,
+		RemoteX:    us.RemoteX,
+		Remote:     us.Remote,
+		Unknown:    us.Unknown,
+		LocalRoot:  us.LocalRoot,
+		RemoteRoot: us.RemoteRoot,
+		Groups:     us.Groups,
+		Offline:    us.Offline,
+		Labels:     utmp.Labels(),
+
+		LocalXUsers:  us.LocalXUsers,
+		LocalUsers:   us.LocalUsers,
+		RemoteXUsers: us.RemoteXUsers,
+		RemoteUsers:  us.RemoteUsers,
+		UnknownUsers: us.UnknownUsers,
+		Logons:       us.Logons}
+	if us.Active != nil {
+		stat.Active = us.Active.Name
+	}
+	for seat, li := range us.Seats {
+		if li != nil {
+			if stat.Seats == nil {
+				stat.Seats = make(map[string]string)
+			}
+			stat.Seats[seat] = li.Name
+		}
+	}
+	stat.BootID, _ = utmp.GetBootID()
+	return stat
+}
+
+// Преобразовать сеансы в []dto.Session (длительность активных сеансов -
+// до момента now).
+// Repack sessions to dto.Session list.
+func Sessions(sessions []utmp.Session, now time.Time) []dto.Session {
+	list := make([]dto.Session, 0, len(sessions))
+	for _, s := range sessions {
+		ds := dto.Session{
+			User:     s.User,
+			TTY:      s.TTY,
+			Host:     s.Host,
+			Login:    s.Login,
+			Logout:   s.Logout,
+			Duration: s.Duration(now).Truncate(time.Second).String(),
+			End:      s.End.String()}
+		if s.Auth != nil {
+			a := Auth(*s.Auth)
+			ds.Auth = &a
+		}
+		list = append(list, ds)
+	}
+	return list
+}
+
+// EOF: "utmphttp.go"
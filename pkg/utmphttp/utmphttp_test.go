@@ -0,0 +1,78 @@
+// File: "utmphttp_test.go"
+
+package utmphttp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/azorg/gousers/v2/dto"
+	"github.com/azorg/gousers/v2/pkg/utmp"
+)
+
+func TestHandler(t *testing.T) {
+	data, err := os.ReadFile("../utmp/testdata/wtmp/debian12.wtmp")
+	require.NoError(t, err)
+	fname := filepath.Join(t.TempDir(), "wtmp")
+	require.NoError(t, os.WriteFile(fname, data, 0644))
+
+	l, err := utmp.NewLogin(fname, false)
+	require.NoError(t, err)
+	defer l.Close()
+
+	get := func(h http.Handler, method, target string, v any) int {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+		if w.Code == http.StatusOK && v != nil {
+			require.Equal(t, "application/json", w.Header().Get("Content-Type"))
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), v))
+		}
+		return w.Code
+	}
+
+	h := Handler(l)
+	var users []dto.User
+	require.Equal(t, http.StatusOK, get(h, "GET", "/users", &users))
+	require.NotEmpty(t, users)
+
+	var user dto.User
+	require.Equal(t, http.StatusOK, get(h, "GET", "/users/"+users[0].Name, &user))
+	require.Equal(t, users[0].Name, user.Name)
+	require.Equal(t, http.StatusNotFound, get(h, "GET", "/users/nosuchuser0", nil))
+
+	var stat dto.UsersStat
+	require.Equal(t, http.StatusOK, get(h, "GET", "/stat", &stat))
+	require.Equal(t, len(users), stat.Total)
+
+	require.Equal(t, http.StatusMethodNotAllowed, get(h, "POST", "/stat", nil))
+	require.Equal(t, http.StatusNotFound, get(h, "GET", "/sessions", nil))
+
+	h = NewHandler(l, Opts{Sessions: fname})
+	var all, since []dto.Session
+	require.Equal(t, http.StatusOK, get(h, "GET", "/sessions", &all))
+	require.NotEmpty(t, all)
+	last := all[len(all)-1].Login.Format("2006-01-02T15:04:05Z07:00")
+	require.Equal(t, http.StatusOK, get(h, "GET", "/sessions?since="+last, &since))
+	require.LessOrEqual(t, len(since), len(all))
+	require.NotEmpty(t, since)
+	require.Equal(t, http.StatusBadRequest, get(h, "GET", "/sessions?since=bad", nil))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/sessions?format=ecs", nil))
+	require.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+	lines := strings.Split(strings.TrimSuffix(w.Body.String(), "\n"), "\n")
+	require.Len(t, lines, len(all))
+	var doc map[string]any
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &doc))
+	require.Equal(t, all[0].User, doc["user"].(map[string]any)["name"])
+	require.Equal(t, http.StatusBadRequest, get(h, "GET", "/sessions?format=leef", nil))
+}
+
+// EOF: "utmphttp_test.go"
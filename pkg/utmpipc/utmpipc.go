@@ -0,0 +1,371 @@
+// File: "utmpipc.go"
+
+/*
+Пакет `utmpipc` - локальный сокет (Unix domain socket, обычно
+/run/gousers.sock) для служб узла, которым нужны сведения о вошедших
+пользователях без подключения библиотеки и без TCP.
+
+Протокол: сообщения JSON с префиксом длины (4 байта, big endian) в обе
+стороны. Клиент отправляет запросы dto.IPCRequest, сервер отвечает
+dto.IPCResponse с тем же ID:
+
+	{"id":1,"method":"users"}              - вошедшие пользователи (users)
+	{"id":2,"method":"user","user":"alice"} - пользователь (user)
+	{"id":3,"method":"stat"}               - статистика (stat)
+	{"id":4,"method":"subscribe"}          - подписка на события (event)
+	{"id":5,"method":"unsubscribe"}        - отмена подписки
+
+После subscribe сервер присылает ответы с ID запроса подписки: снимок
+состояния (событие без входов/выходов), затем события входа/выхода.
+Запросы можно отправлять и во время подписки.
+
+	ln, err := utmpipc.Listen(utmpipc.SOCKET, 0660)
+	if err != nil {
+		log.Fatal(err)
+	}
+	srv := utmpipc.NewServer(l, utmpipc.Opts{})
+	go srv.Serve(ln)
+	defer srv.Close()
+
+Package utmpipc is the local Unix socket service of logged users.
+*/
+package utmpipc
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/azorg/gousers/v2/dto"
+	"github.com/azorg/gousers/v2/pkg/utmp"
+	"github.com/azorg/gousers/v2/pkg/utmphttp"
+)
+
+// Путь сокета по умолчанию.
+// Default socket path.
+const SOCKET = "/run/gousers.sock"
+
+// Методы запросов.
+// Request methods.
+const (
+	METHOD_USERS       = "users"
+	METHOD_USER        = "user"
+	METHOD_STAT        = "stat"
+	METHOD_SUBSCRIBE   = "subscribe"
+	METHOD_UNSUBSCRIBE = "unsubscribe"
+)
+
+// Наибольший размер запроса.
+// Max request message size.
+const MAX_REQUEST = 64 * 1024
+
+// Время ожидания записи ответа по умолчанию (медленный клиент отключается).
+// Default response write timeout.
+const WRITE_TIMEOUT = 10 * time.Second
+
+// Ошибки разбора сообщения.
+// Message errors.
+var (
+	ErrTooLarge   = errors.New("message is too large")
+	ErrBadMessage = errors.New("bad message")
+)
+
+// Опции сервера.
+// Server options.
+type Opts struct {
+	WriteTimeout time.Duration // время ожидания записи (0 - WRITE_TIMEOUT)
+	Logger       *slog.Logger  // журнал ошибок (nil - без журнала)
+}
+
+// Сервер локального сокета.
+// Local socket server.
+type Server struct {
+	l      *utmp.Login
+	opts   Opts
+	mx     sync.Mutex
+	lns    map[net.Listener]struct{}
+	conns  map[*conn]struct{}
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// Соединение клиента.
+type conn struct {
+	srv *Server
+	c   net.Conn
+	wmx sync.Mutex             // запись ответов
+	sub chan struct{}          // закрывается при отмене подписки (nil - нет подписки)
+	evt <-chan utmp.LoginEvent // события подписки
+}
+
+// Создать сервер сведений о пользователях из l.
+// Create local socket server.
+func NewServer(l *utmp.Login, opts Opts) *Server {
+	if opts.WriteTimeout <= 0 {
+		opts.WriteTimeout = WRITE_TIMEOUT
+	}
+	return &Server{
+		l:     l,
+		opts:  opts,
+		lns:   make(map[net.Listener]struct{}),
+		conns: make(map[*conn]struct{})}
+}
+
+// Создать сокет path с правами mode. Оставшийся от прежнего запуска
+// сокет удаляется, если к нему никто не подключён.
+// Listen on Unix socket.
+func Listen(path string, mode os.FileMode) (net.Listener, error) {
+	if fi, err := os.Lstat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		if c, err := net.Dial("unix", path); err == nil {
+			c.Close()
+			return nil, fmt.Errorf("ipc: %s is in use", path)
+		}
+		os.Remove(path)
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("ipc: %w", err)
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("ipc: %w", err)
+	}
+	return ln, nil
+}
+
+// Принимать соединения до закрытия ln или сервера.
+// Serve connections.
+func (s *Server) Serve(ln net.Listener) error {
+	s.mx.Lock()
+	if s.closed {
+		s.mx.Unlock()
+		ln.Close()
+		return net.ErrClosed
+	}
+	s.lns[ln] = struct{}{}
+	s.mx.Unlock()
+
+	for {
+		c, err := ln.Accept()
+		if err != nil {
+			s.mx.Lock()
+			delete(s.lns, ln)
+			closed := s.closed
+			s.mx.Unlock()
+			if closed {
+				return nil
+			}
+			return fmt.Errorf("ipc: %w", err)
+		}
+
+		cn := &conn{srv: s, c: c}
+		s.mx.Lock()
+		if s.closed {
+			s.mx.Unlock()
+			c.Close()
+			return nil
+		}
+		s.conns[cn] = struct{}{}
+		s.wg.Add(1)
+		s.mx.Unlock()
+		go cn.serve()
+	}
+}
+
+// Закрыть сокеты и соединения клиентов.
+// Close server.
+func (s *Server) Close() error {
+	s.mx.Lock()
+	s.closed = true
+	for ln := range s.lns {
+		ln.Close()
+	}
+	for cn := range s.conns {
+		cn.c.Close()
+	}
+	s.mx.Unlock()
+	s.wg.Wait()
+	return nil
+}
+
+// Обработать запросы клиента.
+func (cn *conn) serve() {
+	s := cn.srv
+	defer func() {
+		cn.unsubscribe()
+		cn.c.Close()
+		s.mx.Lock()
+		delete(s.conns, cn)
+		s.mx.Unlock()
+		s.wg.Done()
+	}()
+
+	for {
+		var req dto.IPCRequest
+		err := ReadMessage(cn.c, &req, MAX_REQUEST)
+		if errors.Is(err, io.EOF) || errors.Is(err, net.ErrClosed) {
+			return
+		} else if errors.Is(err, ErrBadMessage) { // framing is intact
+			if !cn.write(dto.IPCResponse{Error: err.Error()}) {
+				return
+			}
+			continue
+		} else if err != nil {
+			cn.write(dto.IPCResponse{Error: err.Error()})
+			s.error("read request", err)
+			return
+		}
+		if !cn.handle(req) {
+			return
+		}
+	}
+}
+
+// Выполнить запрос (false - ошибка записи ответа).
+func (cn *conn) handle(req dto.IPCRequest) bool {
+	l := cn.srv.l
+	resp := dto.IPCResponse{ID: req.ID}
+	switch req.Method {
+	case METHOD_USERS:
+		resp.Users = []dto.User{}
+		for _, li := range l.GetUsers() {
+			resp.Users = append(resp.Users, utmphttp.User(li))
+		}
+
+	case METHOD_USER:
+		resp.Error = "user not logged in"
+		for _, li := range l.GetUsers() {
+			if li.Name == req.User {
+				user := utmphttp.User(li)
+				resp.User, resp.Error = &user, ""
+				break
+			}
+		}
+
+	case METHOD_STAT:
+		stat := utmphttp.Stat(l.GetStat())
+		resp.Stat = &stat
+
+	case METHOD_SUBSCRIBE:
+		if cn.sub != nil {
+			resp.Error = "already subscribed"
+			break
+		}
+		return cn.subscribe(req.ID)
+
+	case METHOD_UNSUBSCRIBE:
+		cn.unsubscribe()
+
+	default:
+		resp.Error = fmt.Sprintf("unknown method %q", req.Method)
+	}
+	return cn.write(resp)
+}
+
+// Подписаться на события: снимок состояния, затем события до отмены
+// подписки, отключения клиента или завершения Login.
+func (cn *conn) subscribe(id uint64) bool {
+	l := cn.srv.l
+	cn.evt = l.Subscribe(utmp.LOGIN_QUEUE, utmp.OVERFLOW_COALESCE)
+	cn.sub = make(chan struct{})
+
+	snap := dto.LoginEvent{Time: time.Now(), Users: []dto.User{}}
+	for _, li := range l.GetUsers() {
+		snap.Users = append(snap.Users, utmphttp.User(li))
+	}
+	snap.Stat = utmphttp.Stat(l.GetStat())
+	if !cn.write(dto.IPCResponse{ID: id, Event: &snap}) {
+		return false
+	}
+
+	done := cn.sub
+	cn.srv.wg.Add(1)
+	go func(c <-chan utmp.LoginEvent) {
+		defer cn.srv.wg.Done()
+		for evt := range c {
+			e := utmphttp.Event(evt)
+			if !cn.write(dto.IPCResponse{ID: id, Event: &e}) {
+				cn.c.Close() // end serve()
+				break
+			}
+		}
+		select {
+		case <-done: // unsubscribed
+		default: // Login stopped
+			cn.write(dto.IPCResponse{ID: id, Error: "service stopped"})
+			cn.c.Close()
+		}
+	}(cn.evt)
+	return true
+}
+
+// Отменить подписку (если есть).
+func (cn *conn) unsubscribe() {
+	if cn.sub != nil {
+		close(cn.sub)
+		cn.srv.l.Unsubscribe(cn.evt)
+		cn.sub, cn.evt = nil, nil
+	}
+}
+
+// Отправить ответ (false - ошибка записи).
+func (cn *conn) write(resp dto.IPCResponse) bool {
+	cn.wmx.Lock()
+	defer cn.wmx.Unlock()
+	cn.c.SetWriteDeadline(time.Now().Add(cn.srv.opts.WriteTimeout))
+	err := WriteMessage(cn.c, &resp)
+	if err != nil && !errors.Is(err, net.ErrClosed) {
+		cn.srv.error("write response", err)
+	}
+	return err == nil
+}
+
+// Записать ошибку в журнал.
+func (s *Server) error(msg string, err error) {
+	if s.opts.Logger != nil {
+		s.opts.Logger.Error("ipc: "+msg, "err", err)
+	}
+}
+
+// Прочитать сообщение: длина (4 байта, big endian) и JSON (max - наибольший
+// размер, 0 - без ограничения).
+// Read length-prefixed JSON message.
+func ReadMessage(r io.Reader, v any, max int) error {
+	var hdr [4]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return err
+	}
+	n := binary.BigEndian.Uint32(hdr[:])
+	if max > 0 && n > uint32(max) {
+		return ErrTooLarge
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return io.ErrUnexpectedEOF
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%w: %v", ErrBadMessage, err)
+	}
+	return nil
+}
+
+// Записать сообщение: длина (4 байта, big endian) и JSON.
+// Write length-prefixed JSON message.
+func WriteMessage(w io.Writer, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	msg := binary.BigEndian.AppendUint32(make([]byte, 0, 4+len(data)), uint32(len(data)))
+	_, err = w.Write(append(msg, data...))
+	return err
+}
+
+// EOF: "utmpipc.go"
@@ -0,0 +1,120 @@
+// File: "utmpipc_test.go"
+
+package utmpipc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/azorg/gousers/v2/dto"
+	"github.com/azorg/gousers/v2/pkg/utmp"
+)
+
+func TestMessage(t *testing.T) {
+	var b bytes.Buffer
+	require.NoError(t, WriteMessage(&b, dto.IPCRequest{ID: 1, Method: "stat"}))
+	require.Equal(t, uint32(b.Len()-4), binary.BigEndian.Uint32(b.Bytes()))
+
+	var req dto.IPCRequest
+	require.NoError(t, ReadMessage(bytes.NewReader(b.Bytes()), &req, 0))
+	require.Equal(t, dto.IPCRequest{ID: 1, Method: "stat"}, req)
+	require.ErrorIs(t, ReadMessage(bytes.NewReader(b.Bytes()), &req, 8), ErrTooLarge)
+	require.ErrorIs(t, ReadMessage(bytes.NewReader([]byte{0, 0, 0, 1, '{'}), &req, 0), ErrBadMessage)
+}
+
+func TestServer(t *testing.T) {
+	dir := t.TempDir()
+	fname := filepath.Join(dir, "utmp")
+	require.NoError(t, os.WriteFile(fname, nil, 0644))
+	login := func(user, line string) {
+		u := utmp.Utmp{Type: utmp.USER_PROCESS}
+		for i := range line {
+			u.Line[i] = int8(line[i])
+		}
+		for i := range user {
+			u.User[i] = int8(user[i])
+		}
+		u.TV.Sec = int32(time.Now().Unix())
+		f, err := os.OpenFile(fname, os.O_WRONLY|os.O_APPEND, 0)
+		require.NoError(t, err)
+		require.NoError(t, binary.Write(f, binary.LittleEndian, &u))
+		require.NoError(t, f.Close())
+	}
+	login("root", "tty1")
+
+	l, err := utmp.NewLoginWith(fname, utmp.LoginOpts{})
+	require.NoError(t, err)
+	defer l.Close()
+
+	sock := filepath.Join(dir, "gousers.sock")
+	ln, err := Listen(sock, 0600)
+	require.NoError(t, err)
+	fi, err := os.Stat(sock)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+
+	srv := NewServer(l, Opts{})
+	done := make(chan error, 1)
+	go func() { done <- srv.Serve(ln) }()
+
+	_, err = Listen(sock, 0600)
+	require.ErrorContains(t, err, "in use")
+
+	c, err := net.Dial("unix", sock)
+	require.NoError(t, err)
+	defer c.Close()
+	call := func(req dto.IPCRequest) dto.IPCResponse {
+		require.NoError(t, WriteMessage(c, req))
+		var resp dto.IPCResponse
+		require.NoError(t, ReadMessage(c, &resp, 0))
+		return resp
+	}
+
+	resp := call(dto.IPCRequest{ID: 1, Method: METHOD_USERS})
+	require.Equal(t, uint64(1), resp.ID)
+	require.Len(t, resp.Users, 1)
+	require.Equal(t, "root", resp.Users[0].Name)
+
+	resp = call(dto.IPCRequest{ID: 2, Method: METHOD_USER, User: "root"})
+	require.Empty(t, resp.Error)
+	require.Equal(t, "root", resp.User.Name)
+	resp = call(dto.IPCRequest{ID: 3, Method: METHOD_USER, User: "nosuchuser0"})
+	require.Equal(t, "user not logged in", resp.Error)
+
+	resp = call(dto.IPCRequest{ID: 4, Method: METHOD_STAT})
+	require.Equal(t, 1, resp.Stat.Total)
+
+	resp = call(dto.IPCRequest{ID: 5, Method: "reboot"})
+	require.Contains(t, resp.Error, "unknown method")
+
+	// Subscription: snapshot, then events
+	resp = call(dto.IPCRequest{ID: 6, Method: METHOD_SUBSCRIBE})
+	require.Equal(t, uint64(6), resp.ID)
+	require.Len(t, resp.Event.Users, 1)
+	require.Empty(t, resp.Event.Login)
+	resp = call(dto.IPCRequest{ID: 7, Method: METHOD_SUBSCRIBE})
+	require.Equal(t, "already subscribed", resp.Error)
+
+	login("alice", "pts/0")
+	resp = dto.IPCResponse{}
+	c.SetReadDeadline(time.Now().Add(10 * time.Second))
+	require.NoError(t, ReadMessage(c, &resp, 0))
+	require.Equal(t, uint64(6), resp.ID)
+	require.Equal(t, []dto.UserTTY{{User: "alice", TTY: "pts/0", LogonType: "local"}}, resp.Event.Login)
+
+	resp = call(dto.IPCRequest{ID: 8, Method: METHOD_UNSUBSCRIBE})
+	require.Equal(t, dto.IPCResponse{ID: 8}, resp)
+
+	require.NoError(t, srv.Close())
+	require.NoError(t, <-done)
+	require.Error(t, ReadMessage(c, &resp, 0)) // connection closed
+}
+
+// EOF: "utmpipc_test.go"